@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/notify"
+	"github.com/adam/masterapp/pkg/receiver"
+)
+
+// newAlertMonitor creates a monitor for the configured notifiers, or returns
+// nil when no notifier is configured
+func newAlertMonitor(cfg *config.Config) (*notify.Monitor, error) {
+	var notifiers []notify.Notifier
+	if cfg.AlertWebhook != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.AlertWebhook))
+	}
+	if cfg.AlertSlackWebhook != "" {
+		notifiers = append(notifiers, notify.NewSlackNotifier(cfg.AlertSlackWebhook))
+	}
+	if cfg.AlertSMTPAddr != "" {
+		var recipients []string
+		for _, to := range strings.Split(cfg.AlertSMTPTo, ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				recipients = append(recipients, to)
+			}
+		}
+		smtpNotifier, err := notify.NewSMTPNotifier(notify.SMTPOptions{
+			Addr:     cfg.AlertSMTPAddr,
+			From:     cfg.AlertSMTPFrom,
+			To:       recipients,
+			Username: cfg.AlertSMTPUser,
+			Password: cfg.AlertSMTPPassword,
+		})
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, smtpNotifier)
+	}
+
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+	return notify.NewMonitor(notifiers...), nil
+}
+
+// watchSender alerts when the HTTP sender stays unhealthy
+func (p *pipeline) watchSender(cfg *config.Config, sender network.Sender) {
+	if p.alertMonitor == nil || !cfg.OutputsTo("http") || cfg.AlertUnhealthyAfter <= 0 {
+		return
+	}
+	p.alertMonitor.AddCondition(notify.Condition{
+		Name: "sender_unhealthy",
+		For:  cfg.AlertUnhealthyAfter,
+		Check: func() (bool, string) {
+			return !sender.IsHealthy(), fmt.Sprintf("cannot deliver data to %s", cfg.TargetURL)
+		},
+	})
+}
+
+// watchReceiver alerts when a running receiver stops emitting signals
+func (p *pipeline) watchReceiver(cfg *config.Config, dataReceiver receiver.DataReceiver) {
+	if p.alertMonitor == nil || cfg.AlertStallAfter <= 0 {
+		return
+	}
+	p.alertMonitor.AddCondition(notify.StalledCondition("receiver_stalled", cfg.AlertStallAfter, func() (int64, bool) {
+		stats := dataReceiver.Stats()
+		return stats.SignalsEmitted + stats.SignalsDropped, stats.Running
+	}))
+}
+
+// watchDrift alerts when the stationarity check keeps finding the system
+// changing during chunks
+func (p *pipeline) watchDrift(cfg *config.Config) {
+	if p.alertMonitor == nil || p.stationarityChecker == nil || cfg.AlertDriftAfter <= 0 {
+		return
+	}
+	p.alertMonitor.AddCondition(notify.Condition{
+		Name: "system_drift",
+		For:  cfg.AlertDriftAfter,
+		Check: func() (bool, string) {
+			stationarity := p.latestStationarity.Load()
+			if stationarity == nil || stationarity.Stationary {
+				return false, "chunks are stationary"
+			}
+			return true, fmt.Sprintf("system keeps changing during chunks, |Z| drifted %.3g%% and phase %.3g° in the last one",
+				stationarity.MagnitudeDriftPercent, stationarity.PhaseDriftDeg)
+		},
+	})
+}
+
+// watchKramersKronig alerts when the spectra keep failing the Kramers-Kronig
+// test, i.e. the cell is not measured in a linear, stable state
+func (p *pipeline) watchKramersKronig(cfg *config.Config) {
+	if p.alertMonitor == nil || p.kkChecker == nil || cfg.AlertKKAfter <= 0 {
+		return
+	}
+	p.alertMonitor.AddCondition(notify.Condition{
+		Name: "kk_fail",
+		For:  cfg.AlertKKAfter,
+		Check: func() (bool, string) {
+			result := p.latestKK.Load()
+			if result == nil || result.Valid {
+				return false, "spectra pass the Kramers-Kronig test"
+			}
+			return true, fmt.Sprintf("spectra keep failing the Kramers-Kronig test, residual of %.3g%% in the last one", result.MaxResidualPercent)
+		},
+	})
+}
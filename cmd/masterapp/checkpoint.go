@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/output"
+)
+
+// inputFingerprint identifies the inputs and settings that determine the
+// positions stored in a checkpoint. It is empty for runs that cannot resume.
+func inputFingerprint(cfg *config.Config) string {
+	fileState := func(path string) string {
+		info, err := os.Stat(path)
+		if err != nil {
+			return path
+		}
+		return fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	switch runMode(cfg) {
+	case "impedance-csv":
+		return output.Fingerprint("impedance-csv", fileState(cfg.ImpedanceCSV))
+	case "direct":
+		return output.Fingerprint("direct", cfg.CircuitType, strconv.Itoa(cfg.SpectraCount))
+	case "file":
+		if cfg.WindowLength > 0 || cfg.WindowPeriods > 0 {
+			return "" // Analysis windows span several file signal pairs
+		}
+		// Everything that moves chunk boundaries, as positions are chunk indices
+		return output.Fingerprint("file", fileState(cfg.VoltageFile), fileState(cfg.CurrentFile),
+			strconv.FormatFloat(cfg.SampleRate, 'g', -1, 64), cfg.From, cfg.To,
+			strconv.Itoa(cfg.ChunkSamples), cfg.ChunkDuration.String(),
+			strconv.FormatFloat(cfg.GapThreshold, 'g', -1, 64), cfg.ElectrodePairs, cfg.ParseMode)
+	default:
+		return ""
+	}
+}
+
+// restoreCheckpoint enables checkpointing and returns the position to resume
+// from; the zero checkpoint means starting from the beginning
+func (p *pipeline) restoreCheckpoint(cfg *config.Config) output.Checkpoint {
+	p.checkpointFingerprint = inputFingerprint(cfg)
+	if p.checkpointFingerprint == "" {
+		log.Printf("Warning: checkpointing supports file input without analysis windows, direct EIS and impedance CSV input only; ignoring -checkpoint")
+		return output.Checkpoint{}
+	}
+	p.checkpoints = output.NewCheckpointStore(cfg.Checkpoint)
+
+	checkpoint, found, err := p.checkpoints.Load()
+	if err != nil {
+		log.Printf("Warning: starting from the beginning: %v", err)
+		return output.Checkpoint{}
+	}
+	if !found {
+		return output.Checkpoint{}
+	}
+	if checkpoint.Mode != runMode(cfg) || checkpoint.Fingerprint != p.checkpointFingerprint {
+		log.Printf("Warning: checkpoint %s belongs to different inputs, starting from the beginning", cfg.Checkpoint)
+		return output.Checkpoint{}
+	}
+
+	log.Printf("Resuming %s run from checkpoint %s saved at %s", checkpoint.Mode, cfg.Checkpoint, checkpoint.UpdatedAt.Format("15:04:05"))
+	return checkpoint
+}
+
+// saveCheckpoint persists the current position when checkpointing is enabled
+func (p *pipeline) saveCheckpoint(checkpoint output.Checkpoint) {
+	if p.checkpoints == nil {
+		return
+	}
+	checkpoint.Fingerprint = p.checkpointFingerprint
+	checkpoint.UpdatedAt = p.clock.Now()
+	if err := p.checkpoints.Save(checkpoint); err != nil {
+		log.Printf("Error saving checkpoint: %v", err)
+	}
+}
+
+// clearCheckpoint removes the checkpoint once the input has been fully processed
+func (p *pipeline) clearCheckpoint() {
+	if p.checkpoints == nil {
+		return
+	}
+	if err := p.checkpoints.Clear(); err != nil {
+		log.Printf("Error clearing checkpoint: %v", err)
+		return
+	}
+	log.Printf("Input completed, checkpoint %s cleared", p.checkpoints.Path())
+}
+
+// resumeDataFile prepares the direct EIS data file for appending after a
+// resume. Rows written after the checkpoint are dropped when its offset is
+// known, otherwise only the metadata footer is removed
+func resumeDataFile(file *os.File, offset int64) error {
+	if offset <= 0 {
+		return output.TrimCSVFooter(file)
+	}
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := file.Seek(offset, io.SeekStart)
+	return err
+}
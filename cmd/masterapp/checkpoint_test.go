@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+func TestInputFingerprint_FileChunking(t *testing.T) {
+	base := func() *config.Config {
+		cfg := config.NewConfig()
+		cfg.UseFileData = true
+		return cfg
+	}
+	reference := inputFingerprint(base())
+	if reference == "" {
+		t.Fatal("inputFingerprint() is empty for file input")
+	}
+
+	for name, change := range map[string]func(cfg *config.Config){
+		"chunk samples":   func(cfg *config.Config) { cfg.ChunkSamples = 500 },
+		"chunk duration":  func(cfg *config.Config) { cfg.ChunkDuration = 2 * time.Second },
+		"gap threshold":   func(cfg *config.Config) { cfg.GapThreshold = 3 },
+		"electrode pairs": func(cfg *config.Config) { cfg.ElectrodePairs = "WE-RE" },
+		"parse mode":      func(cfg *config.Config) { cfg.ParseMode = "repair" },
+	} {
+		cfg := base()
+		change(cfg)
+		if inputFingerprint(cfg) == reference {
+			t.Errorf("changing the %s keeps the fingerprint", name)
+		}
+	}
+}
+
+func TestResumeDataFile(t *testing.T) {
+	const rows = "z_real,z_imag\n1,2\n3,4\n"
+	tests := []struct {
+		name   string
+		offset int64
+		want   string
+	}{
+		{"drops rows after the checkpoint", int64(len("z_real,z_imag\n1,2\n")), "z_real,z_imag\n1,2\n5,6\n"},
+		{"trims the footer without an offset", 0, rows + "5,6\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv")
+			if err := os.WriteFile(path, []byte(rows+"# spectra: 2\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.OpenFile(path, os.O_RDWR, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if err := resumeDataFile(file, tt.offset); err != nil {
+				t.Fatalf("resumeDataFile() error = %v", err)
+			}
+			if _, err := file.WriteString("5,6\n"); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("data file = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/adam/masterapp/pkg/compare"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// runCompareCommand reports the per-spectrum error of one impedance CSV
+// against another, e.g. a fitted reconstruction against generated truth
+func runCompareCommand(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	out := fs.String("out", "", "Directory for report.csv, report.json and plots (empty = summary only)")
+	plots := fs.Bool("plots", false, "Write a Nyquist overlay SVG per spectrum and an error summary SVG to -out")
+	delimiter := fs.String("delimiter", "", "CSV field delimiter of both files (default ',')")
+	decimal := fs.String("decimal", "", "CSV decimal separator of both files (default '.')")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp compare [flags] REFERENCE.csv CANDIDATE.csv\n\n")
+		fmt.Fprintf(fs.Output(), "Pairs the spectra of two impedance CSV files by spectrum number and reports\nthe relative error of the candidate against the reference at the candidate frequencies.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a reference and a candidate file, got %d arguments", fs.NArg())
+	}
+	if *plots && *out == "" {
+		return fmt.Errorf("-plots requires -out")
+	}
+
+	dialect, err := signal.ParseCSVDialect(*delimiter, *decimal, "", false)
+	if err != nil {
+		return err
+	}
+	loader := signal.NewDataLoaderWithDialect(dialect)
+	reference, err := loader.LoadImpedanceFromCSV(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("reference %s: %w", fs.Arg(0), err)
+	}
+	candidate, err := loader.LoadImpedanceFromCSV(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("candidate %s: %w", fs.Arg(1), err)
+	}
+
+	report, err := compare.CompareSets(reference, candidate)
+	if err != nil {
+		return err
+	}
+	for _, s := range report.Spectra {
+		log.Printf("Spectrum %d: %d points, RMS error %.3g%%, max %.3g%% at %.4g Hz, max phase error %.3g°",
+			s.Spectrum, s.Points, s.RMSRelativeError, s.MaxRelativeError, s.MaxErrorFrequency, s.MaxPhaseError)
+	}
+	if len(report.MissingCandidates) > 0 {
+		log.Printf("Warning: reference spectra %v have no candidate", report.MissingCandidates)
+	}
+	if len(report.MissingReferences) > 0 {
+		log.Printf("Warning: candidate spectra %v have no reference", report.MissingReferences)
+	}
+	log.Printf("Compared %d spectra: mean RMS error %.3g%%, worst spectrum %d with %.3g%%",
+		len(report.Spectra), report.MeanRMSRelative, report.WorstSpectrum, report.WorstRMSRelative)
+
+	if *out == "" {
+		return nil
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	if err := report.WriteCSV(filepath.Join(*out, "report.csv")); err != nil {
+		return err
+	}
+	if err := report.WriteJSON(filepath.Join(*out, "report.json")); err != nil {
+		return err
+	}
+	if *plots {
+		if err := writeComparePlots(*out, report, reference, candidate); err != nil {
+			return err
+		}
+	}
+	log.Printf("Wrote comparison report to %s", *out)
+	return nil
+}
+
+// writeComparePlots writes the error summary and a Nyquist overlay per compared spectrum
+func writeComparePlots(dir string, report compare.Report, reference, candidate []signal.ImpedanceDataWithIteration) error {
+	write := func(name string, plot func(f *os.File) error) error {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if err := plot(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	if err := write("errors.svg", func(f *os.File) error { return report.WriteErrorSVG(f) }); err != nil {
+		return err
+	}
+	references := make(map[int]signal.ImpedanceData, len(reference))
+	for _, spectrum := range reference {
+		references[spectrum.Iteration] = spectrum.ImpedanceData
+	}
+	for _, spectrum := range candidate {
+		ref, ok := references[spectrum.Iteration]
+		if !ok {
+			continue
+		}
+		title := fmt.Sprintf("Spectrum %d", spectrum.Iteration)
+		err := write(fmt.Sprintf("nyquist_%d.svg", spectrum.Iteration), func(f *os.File) error {
+			return compare.WriteNyquistSVG(f, title, ref, spectrum.ImpedanceData)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/adam/masterapp/pkg/dataset"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// runDatasetCommand converts stored spectra into a train-ready NPZ dataset
+func runDatasetCommand(args []string) error {
+	fs := flag.NewFlagSet("dataset", flag.ExitOnError)
+	defaults := dataset.DefaultOptions()
+	out := fs.String("out", "dataset.npz", "Output NPZ file; a JSON description is written next to it")
+	points := fs.Int("grid-points", defaults.GridPoints, "Number of log-spaced frequencies of the common grid")
+	fmin := fs.Float64("fmin", 0, "Lower grid frequency in Hz (0 = highest minimum frequency of all spectra)")
+	fmax := fs.Float64("fmax", 0, "Upper grid frequency in Hz (0 = lowest maximum frequency of all spectra)")
+	label := fs.String("label", "", "Metadata field (channel, probe, device_serial) or label key providing the class of each spectrum")
+	delimiter := fs.String("delimiter", "", "CSV field delimiter of impedance files (default ',')")
+	decimal := fs.String("decimal", "", "CSV decimal separator of impedance files (default '.')")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp dataset [flags] FILE|DIR...\n\n")
+		fmt.Fprintf(fs.Output(), "Converts JSON measurements and impedance CSV files into a NumPy .npz dataset\nwith spectra on a fixed frequency grid, normalized features and metadata labels.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files given")
+	}
+
+	dialect, err := signal.ParseCSVDialect(*delimiter, *decimal, "", false)
+	if err != nil {
+		return err
+	}
+	samples, err := dataset.LoadSamples(fs.Args(), signal.NewDataLoaderWithDialect(dialect))
+	if err != nil {
+		return err
+	}
+
+	ds, err := dataset.Build(samples, dataset.Options{
+		GridPoints:   *points,
+		MinFrequency: *fmin,
+		MaxFrequency: *fmax,
+		LabelKey:     *label,
+	})
+	if err != nil {
+		return err
+	}
+	if err := ds.WriteNPZ(*out); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d spectra on %d frequencies from %.4g to %.4g Hz to %s (%s)",
+		len(ds.IDs), len(ds.Frequencies), ds.Frequencies[0], ds.Frequencies[len(ds.Frequencies)-1], *out, dataset.DescriptionPath(*out))
+	if *label != "" {
+		log.Printf("Classes of '%s': %v", *label, ds.LabelNames)
+	}
+	return nil
+}
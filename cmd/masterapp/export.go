@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/output"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// runExportCommand converts an impedance CSV into an Arrow IPC file that
+// pyarrow can memory-map instead of parsing the CSV again, or into the Z(f, t)
+// matrix of the spectra as CSV or Parquet, chosen by the output extension
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	perBatch := fs.Int("spectra-per-batch", 1000, "Spectra per Arrow record batch")
+	delimiter := fs.String("delimiter", "", "CSV field delimiter of the input (default ',')")
+	decimal := fs.String("decimal", "", "CSV decimal separator of the input (default '.')")
+	circuit := fs.String("circuit", "", "Equivalent circuit of the cell, stored as an attribute of matrix outputs")
+	deviceSerial := fs.String("device-serial", "", "Serial number of the acquisition device, stored as an attribute of matrix outputs")
+	labels := fs.String("labels", "", "Further attributes of matrix outputs, e.g. 'campaign=aging,temp=25C'")
+	outputDelimiter := fs.String("output-delimiter", "", "CSV field delimiter of a matrix CSV output (default ',')")
+	outputDecimal := fs.String("output-decimal", "", "CSV decimal separator of a matrix CSV output (default '.')")
+	notation := fs.String("notation", "", "Number notation of a matrix CSV output: fixed, scientific or shortest (default shortest)")
+	precision := fs.Int("precision", 0, "Digits of matrix CSV output numbers, see -notation (default exact)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp export [flags] INPUT.csv OUTPUT.{arrow,csv,parquet,nc}\n\n")
+		fmt.Fprintf(fs.Output(), "OUTPUT.arrow (any other extension) streams an impedance CSV into an Arrow IPC (Feather v2) file with the columns\n%v, readable with pyarrow.ipc.open_file or pyarrow.feather.read_table.\n\n", output.ArrowColumns)
+		fmt.Fprintf(fs.Output(), "OUTPUT.csv and OUTPUT.parquet hold the Z(f, t) matrix: one row per spectrum with the columns\nspectrum, time, quality_flags, re_<f> and im_<f> per frequency of the first spectrum, onto which later\nspectra are interpolated.\n\n")
+		fmt.Fprintf(fs.Output(), "OUTPUT.nc holds the same matrix as NetCDF for xarray.open_dataset: z_real and z_imag over the\ndimensions (time, frequency), with units and the attributes below.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected an input and an output file, got %d arguments", fs.NArg())
+	}
+	if *perBatch < 1 {
+		return fmt.Errorf("-spectra-per-batch must be positive, got %d", *perBatch)
+	}
+
+	dialect, err := signal.ParseCSVDialect(*delimiter, *decimal, "", false)
+	if err != nil {
+		return err
+	}
+	format, err := output.ParseCSVFormat(*outputDelimiter, *outputDecimal, *notation, *precision)
+	if err != nil {
+		return err
+	}
+	var attributes []output.MetadataField
+	if *circuit != "" {
+		attributes = append(attributes, output.MetadataField{Key: "circuit", Value: *circuit})
+	}
+	if *deviceSerial != "" {
+		attributes = append(attributes, output.MetadataField{Key: "device_serial", Value: *deviceSerial})
+	}
+	labelMap, err := signal.ParseLabels(*labels)
+	if err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(labelMap)) {
+		attributes = append(attributes, output.MetadataField{Key: key, Value: labelMap[key]})
+	}
+
+	switch strings.ToLower(filepath.Ext(fs.Arg(1))) {
+	case ".csv", ".parquet", ".nc":
+		return exportMatrix(fs.Arg(0), fs.Arg(1), dialect, format, attributes)
+	default:
+		return exportArrow(fs.Arg(0), fs.Arg(1), dialect, *perBatch)
+	}
+}
+
+// exportArrow streams the spectra of input into an Arrow IPC file
+func exportArrow(input, outputPath string, dialect signal.CSVDialect, perBatch int) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffered := bufio.NewWriterSize(file, 1<<20)
+	writer, err := output.NewArrowWriter(buffered)
+	if err != nil {
+		return err
+	}
+
+	var batch []signal.ImpedanceDataWithIteration
+	spectra, points := 0, 0
+	loader := signal.NewDataLoaderWithDialect(dialect)
+	err = loader.StreamImpedanceFromCSV(input, func(spectrum signal.ImpedanceDataWithIteration, progress float64) error {
+		batch = append(batch, spectrum)
+		spectra++
+		points += len(spectrum.ImpedanceData.Impedance)
+		if len(batch) < perBatch {
+			return nil
+		}
+		err := writer.WriteBatch(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("input %s: %w", input, err)
+	}
+	if err := writer.WriteBatch(batch); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Exported %d spectra with %d points to %s", spectra, points, outputPath)
+	return nil
+}
+
+// exportMatrix assembles the spectra of input into their Z(f, t) matrix and
+// writes it as CSV, Parquet or NetCDF
+func exportMatrix(input, outputPath string, dialect signal.CSVDialect, format output.CSVFormat, attributes []output.MetadataField) error {
+	matrix := output.ImpedanceMatrix{Attributes: attributes, Format: format}
+	loader := signal.NewDataLoaderWithDialect(dialect)
+	err := loader.StreamImpedanceFromCSV(input, func(spectrum signal.ImpedanceDataWithIteration, progress float64) error {
+		return matrix.Add(spectrum)
+	})
+	if err != nil {
+		return fmt.Errorf("input %s: %w", input, err)
+	}
+	if len(matrix.Impedance) == 0 {
+		return fmt.Errorf("input %s holds no spectra", input)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffered := bufio.NewWriterSize(file, 1<<20)
+	switch strings.ToLower(filepath.Ext(outputPath)) {
+	case ".parquet":
+		err = matrix.WriteParquet(buffered)
+	case ".nc":
+		err = matrix.WriteNetCDF(buffered)
+	default:
+		err = matrix.WriteCSV(buffered)
+	}
+	if err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Exported the %d × %d impedance matrix (spectra × frequencies) to %s", len(matrix.Impedance), len(matrix.Frequencies), outputPath)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// runInspectCommand reports the detected layout and content of input files without running the pipeline
+func runInspectCommand(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print reports as JSON")
+	delimiter := fs.String("delimiter", "", "Override the sniffed CSV delimiter")
+	decimal := fs.String("decimal", "", "Override the sniffed decimal separator")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp inspect [flags] FILE...\n\n")
+		fmt.Fprintf(fs.Output(), "Examines time-domain or impedance CSV files and reports columns, sample rate,\nduration, spectra count and problems.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files given")
+	}
+
+	var dialect *signal.CSVDialect
+	if *delimiter != "" || *decimal != "" {
+		d, err := signal.ParseCSVDialect(*delimiter, *decimal, "", false)
+		if err != nil {
+			return err
+		}
+		dialect = &d
+	}
+
+	reports := make([]*signal.FileReport, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		report, err := signal.InspectFile(path, dialect)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printFileReport(report)
+	}
+	return nil
+}
+
+// printFileReport writes a human-readable file report to stdout
+func printFileReport(r *signal.FileReport) {
+	delimiter := r.Delimiter
+	if delimiter == "\t" {
+		delimiter = "tab"
+	}
+
+	fmt.Printf("File:       %s\n", r.Path)
+	fmt.Printf("Kind:       %s\n", r.Kind)
+	fmt.Printf("Dialect:    delimiter '%s', decimal '%s'\n", delimiter, r.Decimal)
+	if r.HasHeader {
+		fmt.Printf("Columns:    %s\n", strings.Join(r.Columns, ", "))
+	} else {
+		fmt.Printf("Columns:    (no header)\n")
+	}
+	fmt.Printf("Rows:       %d (%d invalid)\n", r.Rows, r.InvalidRows)
+
+	switch r.Kind {
+	case signal.FileKindTimeDomain:
+		if r.StartTime != nil {
+			fmt.Printf("Start:      %s\n", r.StartTime.Format(time.RFC3339Nano))
+			fmt.Printf("End:        %s\n", r.EndTime.Format(time.RFC3339Nano))
+		}
+		fmt.Printf("Duration:   %.3f s\n", r.DurationSeconds)
+		fmt.Printf("Rate:       %.3f Hz (estimated, max jitter %.3g s, %d gaps, %d irregular steps)\n", r.EstimatedSampleRate, r.TimestampJitter, r.Gaps, r.IrregularSteps)
+		fmt.Printf("Values:     %.6g .. %.6g\n", r.ValueMin, r.ValueMax)
+	case signal.FileKindImpedance:
+		fmt.Printf("Spectra:    %d (%d-%d points each)\n", r.Spectra, r.MinPointsSpectrum, r.MaxPointsSpectrum)
+		fmt.Printf("Frequency:  %.6g .. %.6g Hz\n", r.FrequencyMin, r.FrequencyMax)
+	}
+
+	if len(r.Problems) == 0 {
+		fmt.Printf("Problems:   none\n")
+		return
+	}
+	fmt.Printf("Problems:\n")
+	for _, problem := range r.Problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+}
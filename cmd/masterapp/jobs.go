@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	ossignal "os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/adam/masterapp/pkg/impedance"
+	"github.com/adam/masterapp/pkg/jobs"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// maxGeneratedSpectra limits the spectra of one generation job
+const maxGeneratedSpectra = 100000
+
+// fileJobParams are the parameters of a "file" job
+type fileJobParams struct {
+	VoltageFile string    `json:"voltage_file"`          // Relative to the served root
+	CurrentFile string    `json:"current_file"`          // Relative to the served root
+	SampleRate  float64   `json:"sample_rate,omitempty"` // 0 = the -rate of the service
+	Frequencies []float64 `json:"frequencies,omitempty"` // Lock-in frequencies; empty = FFT
+}
+
+// generateJobParams are the parameters of a "generate" job
+type generateJobParams struct {
+	Circuit string `json:"circuit"` // simple, medium or complex
+	Spectra int    `json:"spectra"`
+}
+
+// spectraResult is the result of both job kinds
+type spectraResult struct {
+	Spectra []signal.ImpedanceData `json:"spectra"`
+	Failed  int                    `json:"failed,omitempty"` // Chunks without a spectrum
+}
+
+// runServeJobsCommand serves a job queue so that several analysts can share
+// one instance for file processing and generation
+func runServeJobsCommand(args []string) error {
+	fs := flag.NewFlagSet("serve-jobs", flag.ExitOnError)
+	addr := fs.String("addr", ":8082", "Listen address of the job API")
+	concurrency := fs.Int("concurrency", 2, "Jobs running at the same time")
+	capacity := fs.Int("queue", 100, "Jobs waiting to run before submissions are refused")
+	keep := fs.Int("keep", 100, "Finished jobs kept for status, log and result queries")
+	root := fs.String("root", ".", "Directory that file jobs read their CSV files from")
+	sampleRate := fs.Float64("rate", 1000, "Sample rate of file jobs that do not set one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp serve-jobs [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs submitted jobs over REST: POST /jobs {\"kind\": \"file\"|\"generate\", \"params\": {...}},\nthen GET /jobs/{id}, /jobs/{id}/log and /jobs/{id}/result; DELETE /jobs/{id} cancels.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *sampleRate <= 0 {
+		return fmt.Errorf("-rate must be positive, got %g", *sampleRate)
+	}
+	if info, err := os.Stat(*root); err != nil || !info.IsDir() {
+		return fmt.Errorf("-root %s is not a directory", *root)
+	}
+
+	queue, err := jobs.NewQueue(jobs.Options{Concurrency: *concurrency, Capacity: *capacity, Keep: *keep}, map[string]jobs.Runner{
+		"file":     fileJobRunner(*root, *sampleRate),
+		"generate": runGenerateJob,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := &http.Server{Addr: *addr, Handler: queue.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		queue.Run(ctx)
+	}()
+	log.Printf("Serving jobs on %s with %d workers (kinds: %v)", *addr, *concurrency, queue.Kinds())
+
+	signalChan := make(chan os.Signal, 1)
+	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-signalChan:
+		log.Println("Shutdown signal received, cancelling running jobs...")
+	case err := <-served:
+		return err
+	}
+	cancel()
+	<-workersDone
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// fileJobRunner processes a voltage/current CSV pair below root into spectra
+func fileJobRunner(root string, defaultRate float64) jobs.Runner {
+	return func(ctx context.Context, raw json.RawMessage, logger *log.Logger) (interface{}, error) {
+		var params fileJobParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+		if params.VoltageFile == "" || params.CurrentFile == "" {
+			return nil, fmt.Errorf("voltage_file and current_file are required")
+		}
+		if params.SampleRate == 0 {
+			params.SampleRate = defaultRate
+		}
+
+		// Paths are confined to root, whatever they contain
+		voltageFile := filepath.Join(root, filepath.Clean("/"+params.VoltageFile))
+		currentFile := filepath.Join(root, filepath.Clean("/"+params.CurrentFile))
+		loader := signal.NewDataLoader()
+		voltages, currents, err := loader.LoadVoltageAndCurrentFromCSV(voltageFile, currentFile, params.SampleRate)
+		if err != nil {
+			return nil, err
+		}
+		logger.Printf("Loaded %d chunks at %g Hz from %s and %s", len(voltages), params.SampleRate, params.VoltageFile, params.CurrentFile)
+
+		calculator := impedance.NewCalculator()
+		if len(params.Frequencies) > 0 {
+			calculator = impedance.NewLockInCalculator(params.Frequencies)
+			logger.Printf("Using lock-in estimation at %d frequencies", len(params.Frequencies))
+		}
+		result := spectraResult{Spectra: make([]signal.ImpedanceData, 0, len(voltages))}
+		for i := range voltages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			data, err := calculator.CalculateImpedance(voltages[i], currents[i])
+			if err != nil {
+				logger.Printf("Chunk %d: %v", i, err)
+				result.Failed++
+				continue
+			}
+			result.Spectra = append(result.Spectra, data)
+		}
+		logger.Printf("Computed %d spectra, %d chunks failed", len(result.Spectra), result.Failed)
+		return result, nil
+	}
+}
+
+// runGenerateJob generates synthetic spectra of a circuit complexity
+func runGenerateJob(ctx context.Context, raw json.RawMessage, logger *log.Logger) (interface{}, error) {
+	params := generateJobParams{Circuit: "simple", Spectra: 10}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+	switch params.Circuit {
+	case "simple", "medium", "complex":
+	default:
+		return nil, fmt.Errorf("unknown circuit '%s' (simple, medium or complex)", params.Circuit)
+	}
+	if params.Spectra < 1 || params.Spectra > maxGeneratedSpectra {
+		return nil, fmt.Errorf("spectra must be between 1 and %d, got %d", maxGeneratedSpectra, params.Spectra)
+	}
+
+	generator := impedance.NewEISGenerator()
+	circuit := getCircuitParameters(params.Circuit)
+	if err := circuit.Validate(params.Spectra); err != nil {
+		return nil, fmt.Errorf("invalid circuit: %w", err)
+	}
+	for _, warning := range circuit.Plausibility(params.Spectra) {
+		logger.Printf("Warning: implausible circuit: %s", warning)
+	}
+	logger.Printf("Generating %d %s spectra", params.Spectra, params.Circuit)
+	result := spectraResult{Spectra: make([]signal.ImpedanceData, 0, params.Spectra)}
+	for i := 0; i < params.Spectra; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Spectra = append(result.Spectra, generator.GenerateEISSpectrum(circuit))
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	ossignal "os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/impedance"
+	"github.com/adam/masterapp/pkg/loadtest"
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// runLoadTestCommand sends generated spectra to a collector at a fixed rate
+// and concurrency and reports throughput, latency percentiles and errors
+func runLoadTestCommand(args []string) error {
+	defaults := config.NewConfig()
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	target := fs.String("target", defaults.TargetURL, "Target URL of the collector")
+	rate := fs.Float64("rate", 50, "Requests started per second (0 = as fast as the workers manage)")
+	concurrency := fs.Int("concurrency", 4, "Workers sending at the same time, each with its own connections")
+	duration := fs.Duration("duration", 30*time.Second, "Length of the test (0 = until -requests are sent)")
+	requests := fs.Int("requests", 0, "Total requests (0 = until -duration elapsed)")
+	circuit := fs.String("circuit", "medium", "Circuit complexity of the generated spectra: simple, medium or complex")
+	batch := fs.Int("batch", 0, "Spectra per request sent to the batch endpoint (0 = one spectrum per request)")
+	payloads := fs.Int("payloads", 100, "Distinct spectra generated before the test and sent in turn")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp loadtest [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Sends generated spectra to -target like masterapp -output=http does and reports\nthroughput, latency percentiles and errors. Every request carries a new measurement ID.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	switch *circuit {
+	case "simple", "medium", "complex":
+	default:
+		return fmt.Errorf("unknown circuit '%s' (simple, medium or complex)", *circuit)
+	}
+	if *batch < 0 || *payloads < 1 {
+		return fmt.Errorf("-batch cannot be negative and -payloads must be positive")
+	}
+
+	// Generating spectra up front keeps the generator out of the latencies
+	generator := impedance.NewEISGenerator()
+	parameters := getCircuitParameters(*circuit)
+	if err := parameters.Validate(*payloads); err != nil {
+		return fmt.Errorf("invalid circuit: %w", err)
+	}
+	spectra := make([]signal.ImpedanceData, *payloads)
+	for i := range spectra {
+		spectra[i] = generator.GenerateEISSpectrum(parameters)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signalChan := make(chan os.Signal, 1)
+	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer ossignal.Stop(signalChan)
+	go func() {
+		<-signalChan
+		cancel()
+	}()
+
+	log.Printf("Load testing %s: rate %g/s, %d workers, %d spectra per request", *target, *rate, *concurrency, max(*batch, 1))
+
+	// The sender logs every request, which would flood the terminal
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	report, err := loadtest.Run(ctx, loadtest.Options{
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Classify:    classifySendError,
+	}, func(worker int) loadtest.Request {
+		sender := network.NewSender(*target)
+		next := worker
+		return func(ctx context.Context) error {
+			if *batch == 0 {
+				spectrum := spectra[next%len(spectra)]
+				spectrum.Identity = signal.NewIdentity()
+				next++
+				return sender.SendImpedanceData(spectrum)
+			}
+			items := make([]signal.ImpedanceDataWithIteration, *batch)
+			for i := range items {
+				spectrum := spectra[next%len(spectra)]
+				spectrum.Identity = signal.NewIdentity()
+				items[i] = signal.ImpedanceDataWithIteration{ImpedanceData: spectrum, Iteration: i + 1}
+				next++
+			}
+			return sender.SendBatchImpedanceData(items)
+		}
+	})
+	log.SetOutput(logOutput)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	fmt.Print(report)
+	return nil
+}
+
+// classifySendError groups sender errors by HTTP status or failure kind
+func classifySendError(err error) string {
+	var networkErr config.NetworkError
+	if errors.As(err, &networkErr) && networkErr.Status > 0 {
+		return fmt.Sprintf("HTTP %d", networkErr.Status)
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.As(err, &networkErr):
+		return "transport error"
+	default:
+		return err.Error()
+	}
+}
@@ -3,45 +3,74 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net/http"
 	"os"
 	ossignal "os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/adam/masterapp/pkg/api"
+	"github.com/adam/masterapp/pkg/clock"
 	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/features"
+	"github.com/adam/masterapp/pkg/fft"
 	"github.com/adam/masterapp/pkg/impedance"
+	"github.com/adam/masterapp/pkg/inference"
 	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/notify"
+	"github.com/adam/masterapp/pkg/output"
+	"github.com/adam/masterapp/pkg/quality"
 	"github.com/adam/masterapp/pkg/receiver"
 	"github.com/adam/masterapp/pkg/signal"
 	eisgen "github.com/adam/masterapp/pkg/impedance"
 )
 
+// commands maps subcommand names to their entry points
+var commands = map[string]func(args []string) error{
+	"inspect":    runInspectCommand,
+	"dataset":    runDatasetCommand,
+	"compare":    runCompareCommand,
+	"export":     runExportCommand,
+	"serve-jobs": runServeJobsCommand,
+	"schedule":   runScheduleCommand,
+	"loadtest":   runLoadTestCommand,
+	"replay":     runReplayCommand,
+	"report":     runReportCommand,
+}
+
 func main() {
-	var (
-		targetURL     = flag.String("target", "http://localhost:8080/eis-data", "Target URL for sending EIS data")
-		sampleRate    = flag.Float64("rate", 200000.0, "Sample rate in Hz")
-		samplesPerSec = flag.Int("samples", 200, "Number of samples per second")
-		useFileData   = flag.Bool("file", false, "Use file-based data input instead of synthetic data")
-		voltageFile   = flag.String("voltage", "examples/data/voltage_10s.csv", "Path to voltage CSV file")
-		currentFile   = flag.String("current", "examples/data/current_10s.csv", "Path to current CSV file")
-		outputMode    = flag.String("output", "console", "Output mode: 'http' (send via HTTP), 'console' (print JSON to files), or 'csv' (print CSV format)")
-		useDirectEIS  = flag.Bool("direct", false, "Use direct EIS generation (like Python impedance_data.csv) instead of FFT approach")
-		circuitType   = flag.String("circuit", "simple", "Circuit complexity: 'simple' (R(CR)), 'medium' (R(Q(R(QR)))), 'complex' (multi-stage)")
-		spectraCount  = flag.Int("spectra", 5, "Number of spectra to generate for direct EIS mode")
-		impedanceCSV  = flag.String("impedance-csv", "", "Path to impedance CSV file (Frequency_Hz,Z_real,Z_imag,Spectrum_Number)")
-	)
-	flag.Parse()
-
-	// Create and validate configuration
-	cfg := &config.Config{
-		TargetURL:        *targetURL,
-		SampleRate:       *sampleRate,
-		SamplesPerSecond: *samplesPerSec,
+	// Dispatch subcommands before parsing pipeline flags
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
+	// serve-ingest runs the pipeline on chunks pushed by remote acquirers
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve-ingest" {
+		args = append([]string{"-serve-ingest"}, args[1:]...)
+	}
+
+	// Load configuration (defaults < environment < config file < flags) and validate it
+	cfg, err := config.Load(flag.CommandLine, args)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -49,44 +78,557 @@ func main() {
 	}
 
 	log.Println("Starting Dynamic Electrochemical Impedance Spectroscopy (DEIS) processor")
+	if cfg.Profile != "" {
+		log.Printf("Profile: %s", cfg.Profile)
+	}
 	log.Printf("Target URL: %s", cfg.TargetURL)
 	log.Printf("Sample rate: %.1f Hz", cfg.SampleRate)
 	log.Printf("Samples per second: %d", cfg.SamplesPerSecond)
 
+	// Select the clock used to timestamp generated data and outputs
+	p := newPipeline()
+	if cfg.Clock == "simulated" {
+		start := time.Unix(0, 0).UTC()
+		if cfg.ClockStart != "" {
+			start, _ = time.Parse(time.RFC3339Nano, cfg.ClockStart) // Validated with the config
+		}
+		p.clock = clock.NewSimulatedClock(start)
+		log.Printf("Using simulated clock starting at %s", start.Format(time.RFC3339Nano))
+	}
+
+	// Resolve input CSV dialect and parse mode
+	p.loaderOptions.Dialect, err = signal.ParseCSVDialect(cfg.CSVDelimiter, cfg.CSVDecimal, cfg.CSVThousands, cfg.CSVLazyQuotes)
+	if err != nil {
+		log.Fatalf("Invalid CSV dialect: %v", err)
+	}
+	p.csvFormat, err = output.ParseCSVFormat(cfg.CSVOutputDelimiter, cfg.CSVOutputDecimal, cfg.CSVOutputNotation, cfg.CSVOutputPrecision)
+	if err != nil {
+		log.Fatalf("Invalid CSV output format: %v", err)
+	}
+	p.outputConvention, err = signal.ParseConvention(cfg.ImagSign, cfg.PhaseUnit, cfg.FrequencyOrder)
+	if err != nil {
+		log.Fatalf("Invalid output convention: %v", err)
+	}
+	p.normalizeOrder, err = signal.ParseFrequencyOrder(cfg.NormalizeFrequencies)
+	if err != nil {
+		log.Fatalf("Invalid frequency normalization: %v", err)
+	}
+	p.normalizeSpectra = p.normalizeOrder != signal.OrderAsComputed
+	if cfg.SignSecret != "" {
+		p.requestSigner, err = network.NewSigner(cfg.SignSecret, p.clock)
+		if err != nil {
+			log.Fatalf("Invalid signing secret: %v", err)
+		}
+		log.Printf("Signing HTTP requests with HMAC-SHA256 in the %s header", network.SignatureHeader)
+		if cfg.Transport != "http" {
+			log.Printf("Warning: spectra sent over transport=%s are not signed, only heartbeats and raw chunks", cfg.Transport)
+		}
+	}
+	if cfg.DeliveryAudit != "" {
+		p.deliveryAudit, err = network.OpenAuditLog(cfg.DeliveryAudit, p.clock)
+		if err != nil {
+			log.Fatalf("Cannot open delivery audit log: %v", err)
+		}
+		defer p.deliveryAudit.Close()
+		log.Printf("Recording delivery attempts in %s", cfg.DeliveryAudit)
+	}
+	p.loaderOptions.ParseMode, err = signal.ParseParseMode(cfg.ParseMode)
+	if err != nil {
+		log.Fatalf("Invalid parse mode: %v", err)
+	}
+	p.loaderOptions.Clock = p.clock
+	p.loaderOptions.Window, err = signal.ParseTimeWindow(cfg.From, cfg.To)
+	if err != nil {
+		log.Fatalf("Invalid time window: %v", err)
+	}
+	if p.loaderOptions.Window.IsSet() {
+		log.Printf("Time window: %s", p.loaderOptions.Window)
+	}
+	p.loaderOptions.ChunkSamples, p.loaderOptions.ChunkDuration = cfg.ChunkSamples, cfg.ChunkDuration
+	// Check the timing of file data processed by the FFT pipeline
+	if cfg.UseFileData && cfg.ImpedanceCSV == "" && !cfg.UseDirectEIS {
+		if cfg.AutoRate {
+			cfg.SampleRate, err = receiver.DetectFileSampleRate(cfg.VoltageFile, cfg.CurrentFile, p.loaderOptions.Dialect, cfg.RateTolerance)
+			if err != nil {
+				log.Fatalf("Cannot detect the sample rate from the file timestamps, pass -rate instead: %v", err)
+			}
+			log.Printf("Sample rate detected from timestamps: %.1f Hz", cfg.SampleRate)
+		} else {
+			rate, err := receiver.CheckFileSampleRate(cfg.VoltageFile, cfg.CurrentFile, cfg.SampleRate, p.loaderOptions.Dialect, cfg.RateTolerance, receiver.RateMismatchPolicy(cfg.RateMismatch))
+			if err != nil {
+				log.Fatalf("Sample rate check failed: %v", err)
+			}
+			if rate != cfg.SampleRate {
+				cfg.SampleRate = rate
+				log.Printf("Sample rate corrected to %.1f Hz", cfg.SampleRate)
+			}
+		}
+		if err := cfg.ValidateSampleRate(); err != nil {
+			log.Fatalf("Configuration validation failed at the file sample rate: %v", err)
+		}
+		if cfg.LombScargleJitter > 0 && cfg.Estimator == "fft" {
+			report, err := signal.InspectFile(cfg.VoltageFile, &p.loaderOptions.Dialect)
+			if err != nil {
+				log.Fatalf("Cannot inspect the timestamps of %s: %v", cfg.VoltageFile, err)
+			}
+			if jitter := report.TimestampJitter * report.EstimatedSampleRate; jitter > cfg.LombScargleJitter {
+				log.Printf("Timestamp jitter of %.3g sample periods exceeds %g, using the Lomb-Scargle estimator", jitter, cfg.LombScargleJitter)
+				cfg.Estimator = "lombscargle"
+			}
+		}
+		p.loaderOptions.SampleTimes = cfg.Estimator == "lombscargle"
+		p.loaderOptions.GapThreshold = cfg.GapThreshold
+	}
+
+	// Record what this run consumes and produces for the summary and manifest
+	p.manifest = output.NewManifestRecorder(runMode(cfg), cfg.Redacted(), p.clock)
+	if cfg.Manifest != "" {
+		for _, path := range []string{cfg.ConfigFile, cfg.ImpedanceCSV} {
+			if path == "" {
+				continue
+			}
+			if err := p.manifest.AddInput(path); err != nil {
+				log.Printf("Warning: cannot hash input %s for the run manifest: %v", path, err)
+			}
+		}
+		if cfg.UseFileData {
+			for _, path := range []string{cfg.VoltageFile, cfg.CurrentFile} {
+				if err := p.manifest.AddInput(path); err != nil {
+					log.Printf("Warning: cannot hash input %s for the run manifest: %v", path, err)
+				}
+			}
+		}
+	}
+	defer func() {
+		manifest := p.manifest.Finish()
+		log.Printf("Run summary: %s", manifest.Summary())
+		if cfg.Manifest == "" {
+			return
+		}
+		if err := output.WriteManifest(cfg.Manifest, manifest); err != nil {
+			log.Printf("Error writing run manifest: %v", err)
+			return
+		}
+		log.Printf("Run manifest written to: %s", cfg.Manifest)
+	}()
+
+	// Prepare output file layout
+	p.outputPaths, err = output.NewPathTemplate(cfg.OutputDir, cfg.OutputTemplate, cfg.CellID)
+	if err != nil {
+		log.Fatalf("Invalid output template: %v", err)
+	}
+	p.emitBode = cfg.EmitBode
+	p.sendFmin, p.sendFmax = cfg.SendFmin, cfg.SendFmax
+	if cfg.EmitQuality {
+		p.qualityAnalyzer = quality.NewAnalyzer()
+		p.minSNR = cfg.MinSNR
+	}
+	p.emitFeatures = cfg.EmitFeatures
+	if cfg.EmitFeatures || cfg.Model != "" {
+		p.featureExtractor = features.NewExtractor()
+	}
+	if cfg.Model != "" {
+		p.classifier, err = newClassifier(cfg)
+		if err != nil {
+			log.Fatalf("Failed to load model: %v", err)
+		}
+		defer p.classifier.Close()
+		log.Printf("Classifying spectra with model %s", cfg.Model)
+	}
+	if cfg.LinearityLimit > 0 {
+		p.linearityChecker, err = quality.NewAmplitudeLimitChecker(cfg.LinearityLimit)
+		if err != nil {
+			log.Fatalf("Invalid linearity limit: %v", err)
+		}
+		p.blockNonlinear = cfg.LinearityAction == "block"
+	}
+	if cfg.AliasLimit > 0 {
+		p.aliasingChecker, err = quality.NewGuardBandChecker(cfg.AliasLimit, cfg.AnalogBandwidth)
+		if err != nil {
+			log.Fatalf("Invalid alias limit: %v", err)
+		}
+		p.blockAliased = cfg.AliasAction == "block"
+	}
+	if cfg.StationarityWindows > 0 {
+		p.stationarityChecker, err = quality.NewSpectrogramChecker(cfg.StationarityWindows, cfg.StationarityLimit, cfg.StationarityPhaseLimit)
+		if err != nil {
+			log.Fatalf("Invalid stationarity check: %v", err)
+		}
+		p.blockNonStationary = cfg.StationarityAction == "block"
+	}
+	if cfg.KKLimit > 0 {
+		p.kkChecker, err = quality.NewLinKKChecker(cfg.KKLimit)
+		if err != nil {
+			log.Fatalf("Invalid Kramers-Kronig limit: %v", err)
+		}
+	}
+
+	// Keep the raw chunks behind spectra for later re-analysis
+	p.rawDownsample = cfg.RawDownsample
+	switch cfg.RawChunks {
+	case "file":
+		p.rawSink = output.NewRawFileSink(filepath.Join(cfg.OutputDir, "raw"))
+	case "http":
+		rawHTTPSink, err := network.NewRawHTTPSink(cfg.TargetURL, cfg.RawPath)
+		if err != nil {
+			log.Fatalf("Invalid raw chunk endpoint: %v", err)
+		}
+		rawHTTPSink.UseSigner(p.requestSigner)
+		p.rawSink = rawHTTPSink
+	}
+
+	// Decompose an arbitrary excitation waveform for the generator and lock-in reference
+	if cfg.ExcitationWaveform != "" {
+		p.waveformTones, p.waveformDC, err = p.loadExcitationWaveform(cfg)
+		if err != nil {
+			log.Fatalf("Failed to load excitation waveform: %v", err)
+		}
+	}
+
+	// Annotate measurements with the configured metadata
+	labels, err := signal.ParseLabels(cfg.Labels)
+	if err != nil {
+		log.Fatalf("Invalid labels: %v", err)
+	}
+	p.measurementMetadata = signal.Metadata{
+		Channel:      cfg.Channel,
+		Probe:        cfg.Probe,
+		DeviceSerial: cfg.DeviceSerial,
+		Labels:       labels,
+	}
+
+	// Enforce output retention policy in the background
+	retentionBytes, _ := config.ParseByteSize(cfg.RetentionSize)
+	retention := output.RetentionPolicy{
+		MaxFiles:      cfg.RetentionFiles,
+		MaxTotalBytes: retentionBytes,
+		MaxAge:        cfg.RetentionAge,
+	}
+	if retention.Enabled() {
+		janitor := output.NewJanitor(cfg.OutputDir, retention)
+		janitor.Start(cfg.RetentionInterval)
+		defer janitor.Stop()
+	}
+
+	// Start REST status API if requested
+	var apiServer *api.Server
+	if cfg.StatusAddr != "" {
+		apiServer = api.NewServer(cfg.StatusAddr)
+		if err := apiServer.Start(); err != nil {
+			log.Fatalf("Failed to start REST API: %v", err)
+		}
+		defer apiServer.Shutdown(context.Background())
+		if cfg.GrafanaHistory > 0 {
+			p.grafanaDatasource = api.NewGrafanaDatasource(cfg.GrafanaHistory)
+			apiServer.Handle("/grafana/", http.StripPrefix("/grafana", p.grafanaDatasource))
+			log.Printf("Serving the last %d spectra as a Grafana datasource at /grafana", cfg.GrafanaHistory)
+		}
+		if p.deliveryAudit != nil {
+			apiServer.Handle("/deliveries", p.deliveryAudit)
+		}
+	}
+
+	// Wait for the target server to report ready before sending anything
+	if cfg.WaitForTarget && cfg.OutputsTo("http") {
+		probe := network.ProbeOptions{
+			HealthPath:     cfg.HealthPath,
+			Timeout:        cfg.WaitTimeout,
+			InitialBackoff: cfg.WaitBackoff,
+			MaxBackoff:     10 * cfg.WaitBackoff,
+		}
+		if err := network.WaitForTarget(context.Background(), cfg.TargetURL, probe); err != nil {
+			log.Fatalf("Target did not become ready: %v", err)
+		}
+	}
+
+	// Report liveness to the collector independently of measurement traffic
+	if cfg.HeartbeatInterval > 0 {
+		p.heartbeater, err = network.NewHeartbeaterWithClock(cfg.TargetURL, cfg.HeartbeatPath, p.clock)
+		if err != nil {
+			log.Fatalf("Invalid heartbeat endpoint: %v", err)
+		}
+		p.heartbeater.UseSigner(p.requestSigner)
+		p.heartbeater.Start(cfg.HeartbeatInterval)
+		defer p.heartbeater.Stop()
+	}
+
+	// Notify operators about persistent pipeline problems
+	p.alertMonitor, err = newAlertMonitor(cfg)
+	if err != nil {
+		log.Fatalf("Invalid alert configuration: %v", err)
+	}
+	if p.alertMonitor != nil {
+		p.alertMonitor.Start(cfg.AlertInterval)
+		defer p.alertMonitor.Stop()
+	}
+
+	// Resume from the checkpoint of an interrupted run with the same inputs
+	var resume output.Checkpoint
+	if cfg.Checkpoint != "" {
+		resume = p.restoreCheckpoint(cfg)
+	}
+
 	// Check if using impedance CSV file input
-	if *impedanceCSV != "" {
-		log.Printf("Using impedance CSV file input: %s", *impedanceCSV)
-		runImpedanceCSVMode(cfg, *outputMode, *impedanceCSV)
+	if cfg.ImpedanceCSV != "" {
+		log.Printf("Using impedance CSV file input: %s", cfg.ImpedanceCSV)
+		p.runImpedanceCSVMode(cfg, resume)
 		return
 	}
 
 	// Check if using direct EIS generation mode
-	if *useDirectEIS {
+	if cfg.UseDirectEIS {
 		log.Println("Using direct EIS generation (Python impedance_data.csv approach)")
-		runDirectEISMode(cfg, *outputMode, *circuitType, *spectraCount)
+		p.runDirectEISMode(cfg, resume)
 		return
 	}
 
 	// Initialize data receiver based on mode (traditional FFT approach)
 	var dataReceiver receiver.DataReceiver
-	var err error
 
-	if *useFileData {
+	if cfg.UseFileData {
 		log.Printf("Using file-based data input:")
-		log.Printf("  Voltage file: %s", *voltageFile)
-		log.Printf("  Current file: %s", *currentFile)
-		dataReceiver, err = receiver.NewFileReceiver(*voltageFile, *currentFile, cfg.SampleRate)
+		log.Printf("  Voltage file: %s", cfg.VoltageFile)
+		log.Printf("  Current file: %s", cfg.CurrentFile)
+		if cfg.ElectrodePairs != "" {
+			var pairs []signal.ElectrodePair
+			if pairs, err = signal.ParseElectrodePairs(cfg.ElectrodePairs); err != nil {
+				log.Fatalf("Invalid electrode pairs: %v", err)
+			}
+			log.Printf("  Electrode pairs: %v", pairs)
+			dataReceiver, err = receiver.NewElectrodeFileReceiver(cfg.VoltageFile, cfg.CurrentFile, pairs, cfg.SampleRate, p.loaderOptions)
+		} else {
+			dataReceiver, err = receiver.NewFileReceiverWithOptions(cfg.VoltageFile, cfg.CurrentFile, cfg.SampleRate, p.loaderOptions)
+		}
 		if err != nil {
 			log.Fatalf("Failed to create file receiver: %v", err)
 		}
+		if seeker, ok := dataReceiver.(receiver.Seeker); ok && resume.FileIndex > 0 {
+			if err := seeker.Seek(resume.FileIndex); err != nil {
+				log.Fatalf("Failed to resume file input: %v", err)
+			}
+			p.pairsProcessed = resume.FileIndex
+		}
+	} else if cfg.OPCUAEndpoint != "" {
+		log.Printf("Using OPC UA input from %s", cfg.OPCUAEndpoint)
+		opcuaRate := cfg.OPCUASampleRate
+		if opcuaRate == 0 {
+			opcuaRate = 1 / cfg.OPCUASamplingInterval.Seconds()
+		}
+		dataReceiver, err = receiver.NewOPCUAReceiver(receiver.OPCUAOptions{
+			Endpoint:           cfg.OPCUAEndpoint,
+			VoltageNode:        cfg.OPCUAVoltageNode,
+			CurrentNode:        cfg.OPCUACurrentNode,
+			SamplingInterval:   cfg.OPCUASamplingInterval,
+			PublishingInterval: cfg.OPCUAPublishingInterval,
+			SampleRate:         cfg.OPCUASampleRate,
+			ChunkSamples:       cfg.ChunkSize(opcuaRate, cfg.SamplesPerSecond),
+		})
+		if err != nil {
+			log.Fatalf("Failed to create OPC UA receiver: %v", err)
+		}
+	} else if cfg.ModbusAddress != "" {
+		log.Printf("Using Modbus input from %s", cfg.ModbusAddress)
+		dataReceiver, err = receiver.NewModbusReceiver(receiver.ModbusOptions{
+			Address:         cfg.ModbusAddress,
+			Unit:            byte(cfg.ModbusUnit),
+			VoltageRegister: uint16(cfg.ModbusVoltageRegister),
+			CurrentRegister: uint16(cfg.ModbusCurrentRegister),
+			Format:          cfg.ModbusFormat,
+			WordSwap:        cfg.ModbusWordSwap,
+			VoltageScale:    cfg.ModbusVoltageScale,
+			VoltageOffset:   cfg.ModbusVoltageOffset,
+			CurrentScale:    cfg.ModbusCurrentScale,
+			CurrentOffset:   cfg.ModbusCurrentOffset,
+			PollInterval:    cfg.ModbusPollInterval,
+			ChunkSamples:    cfg.ChunkSize(1/cfg.ModbusPollInterval.Seconds(), cfg.SamplesPerSecond),
+		})
+		if err != nil {
+			log.Fatalf("Failed to create Modbus receiver: %v", err)
+		}
+	} else if cfg.SCPIAddress != "" {
+		log.Printf("Using SCPI instrument input from %s", cfg.SCPIAddress)
+		profile, err := receiver.LookupSCPIProfile(cfg.SCPIProfile)
+		if err != nil {
+			log.Fatalf("Invalid SCPI profile: %v", err)
+		}
+		dataReceiver, err = receiver.NewSCPIReceiver(receiver.SCPIOptions{
+			Address:             cfg.SCPIAddress,
+			CurrentAddress:      cfg.SCPICurrentAddress,
+			Profile:             profile,
+			Points:              cfg.SCPIPoints,
+			SampleRate:          cfg.SCPISampleRate,
+			VoltageScale:        cfg.SCPIVoltageScale,
+			CurrentScale:        cfg.SCPICurrentScale,
+			ExcitationAddress:   cfg.SCPIExcitationAddress,
+			ExcitationFrequency: cfg.SCPIExcitationFrequency,
+			ExcitationAmplitude: cfg.SCPIExcitationAmplitude,
+			ExcitationOffset:    cfg.SCPIExcitationOffset,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create SCPI receiver: %v", err)
+		}
+	} else if cfg.UseAudio {
+		log.Println("Using audio interface input")
+		dataReceiver, err = receiver.NewAudioReceiver(receiver.AudioOptions{
+			Device:         cfg.AudioDevice,
+			Command:        strings.Fields(cfg.AudioCommand),
+			Format:         cfg.AudioFormat,
+			SampleRate:     cfg.AudioSampleRate,
+			Channels:       cfg.AudioChannels,
+			VoltageChannel: cfg.AudioVoltageChannel,
+			CurrentChannel: cfg.AudioCurrentChannel,
+			VoltageScale:   cfg.AudioVoltageScale,
+			CurrentScale:   cfg.AudioCurrentScale,
+			ChunkSamples:   cfg.ChunkSize(cfg.AudioSampleRate, cfg.SamplesPerSecond),
+		})
+		if err != nil {
+			log.Fatalf("Failed to create audio receiver: %v", err)
+		}
+	} else if cfg.SPIDevice != "" {
+		log.Printf("Using SPI ADC input from %s", cfg.SPIDevice)
+		dataReceiver, err = receiver.NewSPIReceiver(receiver.SPIOptions{
+			Device:         cfg.SPIDevice,
+			SpeedHz:        uint32(cfg.SPISpeed),
+			Chip:           cfg.SPIChip,
+			VRef:           cfg.SPIVRef,
+			VoltageChannel: cfg.SPIVoltageChannel,
+			CurrentChannel: cfg.SPICurrentChannel,
+			VoltageScale:   cfg.SPIVoltageScale,
+			VoltageOffset:  cfg.SPIVoltageOffset,
+			CurrentScale:   cfg.SPICurrentScale,
+			CurrentOffset:  cfg.SPICurrentOffset,
+			SampleRate:     cfg.SPISampleRate,
+			ChunkSamples:   cfg.ChunkSize(cfg.SPISampleRate, cfg.SamplesPerSecond),
+		})
+		if err != nil {
+			log.Fatalf("Failed to create SPI receiver: %v", err)
+		}
+	} else if cfg.ServeIngest {
+		log.Printf("Using chunks pushed to %s%s", cfg.IngestAddr, cfg.IngestPath)
+		dataReceiver, err = receiver.NewIngestReceiver(receiver.IngestOptions{
+			Addr:       cfg.IngestAddr,
+			Path:       cfg.IngestPath,
+			SampleRate: cfg.SampleRate,
+			Loader:     p.loaderOptions,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create ingest receiver: %v", err)
+		}
 	} else {
 		log.Println("Using synthetic data generation")
-		dataReceiver = receiver.NewReceiver(cfg.SampleRate, cfg.SamplesPerSecond)
+		excitation, voltageDC := signal.DefaultExcitation(), 1.0
+		if len(p.waveformTones) > 0 {
+			excitation, voltageDC = p.waveformTones, p.waveformDC
+		}
+		circuit := signal.RandlesCircuit{Rs: cfg.SimRs, Rct: cfg.SimRct, Q: cfg.SimQ, N: cfg.SimN}
+		for _, warning := range circuit.Plausibility(toneRange(excitation)) {
+			log.Printf("Warning: implausible simulated cell: %s", warning)
+		}
+		generator := signal.NewGeneratorWithOptions(p.clock, signal.GeneratorOptions{
+			Circuit:      circuit,
+			Excitation:   excitation,
+			VoltageDC:    voltageDC,
+			VoltageNoise: cfg.SimVoltageNoise,
+			CurrentNoise: cfg.SimCurrentNoise,
+			Seed:         cfg.SimSeed,
+		})
+		dataReceiver = receiver.NewReceiverWithGenerator(cfg.SampleRate, cfg.ChunkSize(cfg.SampleRate, cfg.SamplesPerSecond), p.clock, generator)
+	}
+
+	if cfg.WindowLength > 0 || cfg.WindowPeriods > 0 {
+		dataReceiver, err = receiver.NewSegmentingReceiver(dataReceiver, receiver.SegmentOptions{
+			WindowLength:        cfg.WindowLength,
+			Overlap:             cfg.WindowOverlap,
+			Periods:             cfg.WindowPeriods,
+			ExcitationFrequency: cfg.ExcitationFrequency,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create segmenting receiver: %v", err)
+		}
 	}
 
 	// Initialize other components
 	calculator := impedance.NewCalculator()
-	sender := network.NewSender(cfg.TargetURL)
+	if cfg.Estimator == "lockin" {
+		frequencies, _ := config.ParseFloatList(cfg.ExcitationFrequencies) // Validated with the config
+		if len(frequencies) == 0 && len(p.waveformTones) > 0 {
+			frequencies = referenceFrequencies(p.waveformTones)
+			log.Printf("Lock-in reference: %d frequencies of the excitation waveform", len(frequencies))
+		}
+		calculator = impedance.NewLockInCalculator(frequencies)
+		log.Printf("Using lock-in impedance estimator")
+	} else if cfg.Estimator == "lombscargle" {
+		frequencies, _ := config.ParseFloatList(cfg.ExcitationFrequencies) // Validated with the config
+		if len(frequencies) == 0 && len(p.waveformTones) > 0 {
+			frequencies = referenceFrequencies(p.waveformTones)
+		}
+		calculator = impedance.NewLombScargleCalculator(frequencies)
+		log.Printf("Using Lomb-Scargle impedance estimator")
+	}
+	switch {
+	case cfg.EstimateChannelDelay:
+		calculator = impedance.NewDelayEstimatingCalculator(calculator)
+		log.Printf("Estimating the channel delay from the first %d chunks", impedance.DelayEstimationChunks)
+	case cfg.ChannelDelay != 0:
+		calculator = impedance.NewDelayCompensatingCalculator(calculator, cfg.ChannelDelay)
+		log.Printf("Removing a channel delay of %v from the current", cfg.ChannelDelay)
+	}
+	if cfg.VoltageChain != "" || cfg.CurrentChain != "" {
+		voltageChain, err := impedance.ParseChannelResponse(cfg.VoltageChain)
+		if err != nil {
+			log.Fatalf("Invalid voltage chain: %v", err)
+		}
+		currentChain, err := impedance.ParseChannelResponse(cfg.CurrentChain)
+		if err != nil {
+			log.Fatalf("Invalid current chain: %v", err)
+		}
+		calculator = impedance.NewCompensatingCalculator(calculator, voltageChain, currentChain)
+		log.Printf("Compensating acquisition chains: voltage %s; current %s", voltageChain, currentChain)
+	}
+	if cfg.Shunt != "" {
+		shunt, err := impedance.ParseShunt(cfg.Shunt)
+		if err != nil {
+			log.Fatalf("Invalid shunt: %v", err)
+		}
+		calculator = impedance.NewShuntCalculator(calculator, shunt)
+		log.Printf("Current measured across a shunt of %s", shunt)
+	}
+	sender := p.newSender(cfg)
+	if cfg.OutputsTo("http") && cfg.BatchesSpectra() {
+		batching, err := network.NewBatchingSender(sender, network.FlushPolicy{
+			MaxCount: cfg.SendBatchCount,
+			MaxBytes: cfg.SendBatchBytes,
+			MaxAge:   cfg.SendBatchAge,
+		})
+		if err != nil {
+			log.Fatalf("Invalid batch flush policy: %v", err)
+		}
+		log.Printf("Accumulating spectra into batches (count %d, bytes %d, age %v)", cfg.SendBatchCount, cfg.SendBatchBytes, cfg.SendBatchAge)
+		sender = batching
+	}
+	defer closeSender(sender)
+	sink, _ := p.newSink(cfg.Outputs(), sender, 0)
+	defer p.closeSink(sink)
+
+	if apiServer != nil {
+		apiServer.RegisterStatus("receiver", func() interface{} { return dataReceiver.Stats() })
+		apiServer.RegisterStatus("sender", func() interface{} {
+			return map[string]bool{"healthy": sender.IsHealthy()}
+		})
+		if p.batchSummaries != nil {
+			apiServer.Handle("/batch-summary", p.batchSummaries)
+		}
+	}
+	p.watchSender(cfg, sender)
+	p.watchReceiver(cfg, dataReceiver)
+	p.watchDrift(cfg)
+	p.watchKramersKronig(cfg)
+	if p.heartbeater != nil {
+		p.heartbeater.RegisterStatus("receiver", func() interface{} { return dataReceiver.Stats() })
+		if cfg.OutputsTo("http") {
+			p.heartbeater.RegisterHealth(sender.IsHealthy)
+		}
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -97,6 +639,7 @@ func main() {
 	signalChan := make(chan os.Signal, 1)
 	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
+	processorDone := make(chan struct{})
 	wg.Add(2)
 
 	// Start data receiver
@@ -110,12 +653,23 @@ func main() {
 	// Start signal processor
 	go func() {
 		defer wg.Done()
-		processSignals(ctx, dataReceiver, calculator, sender, *outputMode)
+		defer close(processorDone)
+		p.processSignals(ctx, dataReceiver, calculator, sink)
 	}()
 
-	// Wait for shutdown signal
-	<-signalChan
-	log.Println("Shutdown signal received, stopping...")
+	// Wait for shutdown signal, or for the end of the input when requested
+	select {
+	case <-signalChan:
+		log.Println("Shutdown signal received, stopping...")
+	case <-processorDone:
+		if cfg.ExitOnComplete {
+			log.Println("Input exhausted, stopping...")
+		} else {
+			log.Println("Input exhausted, waiting for shutdown signal (use -exit-on-complete to exit automatically)")
+			<-signalChan
+			log.Println("Shutdown signal received, stopping...")
+		}
+	}
 
 	// Cancel context to stop all goroutines
 	cancel()
@@ -129,129 +683,493 @@ func main() {
 	log.Println("DEIS processor stopped")
 }
 
-func processSignals(ctx context.Context, dataReceiver receiver.DataReceiver, calculator impedance.Calculator, sender network.Sender, outputMode string) {
+func (p *pipeline) processSignals(ctx context.Context, dataReceiver receiver.DataReceiver, calculator impedance.Calculator, sink output.Sink) {
+	// The checkpoint advances after every pair whose spectrum was written and
+	// flushed, and stops at the first failure so a resume repeats that pair
+	stalled := false
+	checkpointPair := func(written bool) {
+		p.pairsProcessed++
+		if p.checkpoints == nil || stalled {
+			return
+		}
+		if written {
+			if err := sink.Flush(); err != nil {
+				log.Printf("Error flushing outputs: %v", err)
+				p.manifest.RecordError(err)
+				written = false
+			}
+		}
+		if !written {
+			stalled = true
+			log.Printf("Warning: output of signal pair %d failed, the checkpoint stays before it", p.pairsProcessed)
+			return
+		}
+		p.saveCheckpoint(output.Checkpoint{Mode: "file", FileIndex: p.pairsProcessed})
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Signal processor stopping due to context cancellation")
 			return
-		case voltageSignal := <-dataReceiver.GetVoltageChannel():
-			select {
-			case currentSignal := <-dataReceiver.GetCurrentChannel():
-				impedanceData, err := calculator.CalculateImpedance(voltageSignal, currentSignal)
-				if err != nil {
-					log.Printf("Error calculating impedance: %v", err)
-					continue
-				}
-
-				if outputMode == "console" {
-					// Convert to EISMeasurement for file output
-					measurement, err := calculator.ProcessEISMeasurement(voltageSignal, currentSignal)
-					if err != nil {
-						log.Printf("Error processing EIS measurement: %v", err)
-						continue
-					}
-					printEISMeasurement(measurement, "json")
-				} else if outputMode == "csv" {
-					// Convert to EISMeasurement for CSV output
-					measurement, err := calculator.ProcessEISMeasurement(voltageSignal, currentSignal)
-					if err != nil {
-						log.Printf("Error processing EIS measurement: %v", err)
-						continue
-					}
-					printEISMeasurement(measurement, "csv")
-				} else {
-					// Send impedance data with voltage via HTTP
-					if err := sender.SendImpedanceData(impedanceData); err != nil {
-						log.Printf("Error sending impedance data: %v", err)
-
-						// Check if sender is unhealthy and log warning
-						if !sender.IsHealthy() {
-							log.Printf("Warning: Data sender is unhealthy")
-						}
+		case <-dataReceiver.Done():
+			// The receiver emits everything before signalling completion, so
+			// whatever is still buffered is the tail of the input
+			for drained := false; !drained; {
+				select {
+				case voltageSignal, ok := <-dataReceiver.GetVoltageChannel():
+					if !ok {
+						drained = true
+						break
 					}
+					checkpointPair(p.processSignalPair(voltageSignal, dataReceiver, calculator, sink))
+				default:
+					drained = true
 				}
-			default:
-				log.Println("Warning: No current signal available for voltage signal")
 			}
+			if stalled {
+				log.Printf("Keeping the checkpoint, resume to process the failed signal pairs again")
+			} else {
+				p.clearCheckpoint()
+			}
+			log.Println("Signal processor stopping: input exhausted")
+			return
+		case voltageSignal, ok := <-dataReceiver.GetVoltageChannel():
+			if !ok {
+				// The receiver closes its channels once it has stopped producing
+				log.Println("Signal processor stopping: receiver closed its channels")
+				return
+			}
+			checkpointPair(p.processSignalPair(voltageSignal, dataReceiver, calculator, sink))
 		}
 	}
 }
 
-var measurementCounter int
-
-func printEISMeasurement(measurement interface{}, format string) {
-	measurementCounter++
-
-	if format == "csv" {
-		printCSVMeasurement(measurement)
+// storeRawChunk hands the time-domain chunk behind a spectrum to the raw chunk sink
+func (p *pipeline) storeRawChunk(identity signal.Identity, voltageSignal, currentSignal signal.Signal) {
+	voltage, err := voltageSignal.Downsample(p.rawDownsample)
+	if err != nil {
+		log.Printf("Error downsampling raw voltage chunk: %v", err)
+		return
+	}
+	current, err := currentSignal.Downsample(p.rawDownsample)
+	if err != nil {
+		log.Printf("Error downsampling raw current chunk: %v", err)
 		return
 	}
 
-	// Create output directory if it doesn't exist
-	outputDir := filepath.Join("output", "json")
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Printf("Error creating output directory: %v", err)
+	if err := p.rawSink.WriteRawChunk(signal.RawChunk{Identity: identity, Voltage: voltage, Current: current}); err != nil {
+		log.Printf("Error storing raw chunk: %v", err)
+		p.manifest.RecordError(err)
 		return
 	}
+	if fileSink, ok := p.rawSink.(*output.RawFileSink); ok {
+		p.manifest.RecordOutput(fileSink.Path(identity.ID))
+	}
+}
 
-	// Generate filename with timestamp and counter
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("eis_measurement_%s_%03d.json", timestamp, measurementCounter)
-	filePath := filepath.Join(outputDir, filename)
+// checkLinearity warns about excitation lines above the linearity limit and
+// returns the resulting quality flag and whether the chunk may be processed
+func (p *pipeline) checkLinearity(voltageSignal signal.Signal) (signal.QualityFlags, bool) {
+	violations, err := p.linearityChecker.CheckLinearity(voltageSignal)
+	if err != nil {
+		log.Printf("Error checking excitation linearity: %v", err)
+		return 0, true
+	}
+	if len(violations) == 0 {
+		return 0, true
+	}
 
-	// Marshal JSON with pretty formatting
-	jsonData, err := json.MarshalIndent(measurement, "", "  ")
+	action := "keeping"
+	if p.blockNonlinear {
+		action = "dropping"
+	}
+	log.Printf("Warning: excitation exceeds the linearity limit at %d frequencies, %s chunk", len(violations), action)
+	for _, v := range violations {
+		log.Printf("  %s", v)
+	}
+	return signal.FlagNonlinearity, !p.blockNonlinear
+}
+
+// normalizeSpectrum sorts the spectrum by frequency and merges duplicate bins
+// if normalization is enabled; spectra with invalid frequencies are logged
+// and must be dropped
+func (p *pipeline) normalizeSpectrum(data *signal.ImpedanceData) bool {
+	if !p.normalizeSpectra {
+		return true
+	}
+	normalized, report, err := signal.NormalizeFrequencies(*data, p.normalizeOrder)
 	if err != nil {
-		log.Printf("Error marshaling EIS measurement: %v", err)
-		return
+		log.Printf("Dropping spectrum %d: %v", data.Sequence, err)
+		p.manifest.RecordError(err)
+		return false
 	}
+	if report.Reordered || report.Merged > 0 {
+		p.normalizeLogged.Do(func() {
+			log.Printf("Normalizing spectra to %s frequencies (spectrum %d: reordered %v, %d duplicate bins merged; not logged again)",
+				p.normalizeOrder, data.Sequence, report.Reordered, report.Merged)
+		})
+	}
+	*data = normalized
+	return true
+}
 
-	// Write to file
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
-		log.Printf("Error writing EIS measurement to file %s: %v", filePath, err)
+// checkKramersKronig marks a spectrum failing the Kramers-Kronig test with
+// the kk_fail flag if the test is enabled
+func (p *pipeline) checkKramersKronig(data *signal.ImpedanceData) {
+	if p.kkChecker == nil {
 		return
 	}
-
-	log.Printf("EIS measurement saved to: %s", filePath)
+	result, err := p.kkChecker.CheckKramersKronig(*data)
+	if err != nil {
+		log.Printf("Error testing spectrum %d against the Kramers-Kronig relations: %v", data.Sequence, err)
+		return
+	}
+	p.latestKK.Store(&result)
+	if !result.Valid {
+		log.Printf("Warning: spectrum %d fails the Kramers-Kronig test, residual of %.3g%% with %d RC elements",
+			data.Sequence, result.MaxResidualPercent, result.Elements)
+		data.QualityFlags |= signal.FlagKKFail
+	}
 }
 
-func printCSVMeasurement(measurement interface{}) {
-	eisMeasurement, ok := measurement.(signal.EISMeasurement)
-	if !ok {
-		log.Printf("Error: Invalid measurement type for CSV output")
+// annotateSpectrum adds the scalar spectrum features and the model prediction
+// to impedance data if requested
+func (p *pipeline) annotateSpectrum(data *signal.ImpedanceData) {
+	if p.featureExtractor == nil {
 		return
 	}
+	spectrumFeatures, err := p.featureExtractor.Extract(*data)
+	if err != nil {
+		log.Printf("Error extracting spectrum features: %v", err)
+		return
+	}
+	if p.emitFeatures {
+		data.Features = &spectrumFeatures
+	}
 
-	// Create CSV output directory if it doesn't exist
-	outputDir := filepath.Join("output", "csv")
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Printf("Error creating CSV output directory: %v", err)
+	if p.classifier == nil {
 		return
 	}
+	prediction, err := p.classifier.Classify(spectrumFeatures)
+	if err != nil {
+		log.Printf("Error classifying spectrum: %v", err)
+		return
+	}
+	data.Prediction = &prediction
+}
 
-	// Generate CSV filename with timestamp and counter
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("eis_measurement_%s_%03d.csv", timestamp, measurementCounter)
-	filePath := filepath.Join(outputDir, filename)
+// recordSpectrum keeps the spectrum for the Grafana datasource if it is served
+func (p *pipeline) recordSpectrum(data signal.ImpedanceData) {
+	if p.grafanaDatasource != nil {
+		p.grafanaDatasource.Record(data)
+	}
+}
+
+// newClassifier loads the configured ONNX model with its class names and input normalization
+func newClassifier(cfg *config.Config) (inference.Classifier, error) {
+	options := inference.Options{ModelPath: cfg.Model, LibraryPath: cfg.ModelLibrary}
+	if cfg.ModelLabels != "" {
+		for _, label := range strings.Split(cfg.ModelLabels, ",") {
+			options.Labels = append(options.Labels, strings.TrimSpace(label))
+		}
+	}
+	if cfg.ModelNormalization != "" {
+		if err := options.LoadNormalization(cfg.ModelNormalization); err != nil {
+			return nil, err
+		}
+	}
+	return inference.NewONNXClassifier(options)
+}
 
-	// Create CSV file
-	file, err := os.Create(filePath)
+// checkAliasing warns about channels with power near the Nyquist frequency
+// and returns the resulting quality flag and whether the chunk may be processed
+func (p *pipeline) checkAliasing(voltageSignal, currentSignal signal.Signal) (signal.QualityFlags, bool) {
+	violations, err := p.aliasingChecker.CheckAliasing(voltageSignal, currentSignal)
+	action := "keeping"
+	if p.blockAliased {
+		action = "dropping"
+	}
+	var bandwidthErr config.ValidationError
+	if errors.As(err, &bandwidthErr) {
+		// The analog bandwidth exceeds the Nyquist frequency of the chunk
+		log.Printf("Warning: aliasing, %v, %s chunk", err, action)
+		return signal.FlagAliasing, !p.blockAliased
+	}
 	if err != nil {
-		log.Printf("Error creating CSV file %s: %v", filePath, err)
-		return
+		log.Printf("Error checking aliasing: %v", err)
+		return 0, true
+	}
+	if len(violations) == 0 {
+		return 0, true
 	}
-	defer file.Close()
 
-	// Write CSV header
-	fmt.Fprintf(file, "frequency,real,imag\n")
-	
-	// Write impedance data
-	for _, point := range eisMeasurement {
-		fmt.Fprintf(file, "%.6g,%.6f,%.6f\n", point.Frequency, point.Real, point.Imag)
+	log.Printf("Warning: possible aliasing, power near the Nyquist frequency in %d channels, %s chunk", len(violations), action)
+	for _, v := range violations {
+		log.Printf("  %s", v)
 	}
+	return signal.FlagAliasing, !p.blockAliased
+}
 
-	log.Printf("EIS measurement CSV saved to: %s", filePath)
+// checkStationarity compares the sub-windows of a chunk, warns if the system
+// changed during it and reports whether the chunk may be processed. The
+// result is nil if the check failed.
+func (p *pipeline) checkStationarity(voltageSignal, currentSignal signal.Signal) (*signal.Stationarity, bool) {
+	stationarity, err := p.stationarityChecker.CheckStationarity(voltageSignal, currentSignal)
+	if err != nil {
+		log.Printf("Error checking stationarity: %v", err)
+		return nil, true
+	}
+	p.latestStationarity.Store(&stationarity)
+	if stationarity.Stationary {
+		return &stationarity, true
+	}
+
+	action := "marking"
+	if p.blockNonStationary {
+		action = "dropping"
+	}
+	log.Printf("Warning: system changed during the chunk, |Z| drifted %.3g%% and phase %.3g° across %d sub-windows, %s chunk",
+		stationarity.MagnitudeDriftPercent, stationarity.PhaseDriftDeg, stationarity.Windows, action)
+	return &stationarity, !p.blockNonStationary
+}
+
+// processSignalPair pairs a voltage signal with the next current signal and
+// outputs their impedance. It reports false if the spectrum could not be
+// written; dropped chunks count as written.
+func (p *pipeline) processSignalPair(voltageSignal signal.Signal, dataReceiver receiver.DataReceiver, calculator impedance.Calculator, sink output.Sink) (written bool) {
+	written = true
+	// Report the outcome to receivers tracking their chunks, e.g. upload jobs,
+	// also when the chunk cannot be paired
+	var result *signal.ImpedanceData
+	if recorder, ok := dataReceiver.(receiver.ResultRecorder); ok {
+		defer func() { recorder.RecordResult(voltageSignal, result) }()
+	}
+
+	select {
+	case currentSignal, ok := <-dataReceiver.GetCurrentChannel():
+		if !ok {
+			log.Println("Warning: Current channel closed before voltage signal could be paired")
+			return
+		}
+		// Problems of chunks that are kept are marked in the quality flags
+		var flags signal.QualityFlags
+		if voltageSignal.RepairedSamples > 0 || currentSignal.RepairedSamples > 0 {
+			flags |= signal.FlagRepairedSamples
+		}
+		if voltageSignal.Gap > 0 || currentSignal.Gap > 0 {
+			flags |= signal.FlagGap
+		}
+		if p.linearityChecker != nil {
+			flag, ok := p.checkLinearity(voltageSignal)
+			if !ok {
+				return
+			}
+			flags |= flag
+		}
+		if p.aliasingChecker != nil {
+			flag, ok := p.checkAliasing(voltageSignal, currentSignal)
+			if !ok {
+				return
+			}
+			flags |= flag
+		}
+		var stationarity *signal.Stationarity
+		if p.stationarityChecker != nil {
+			var ok bool
+			if stationarity, ok = p.checkStationarity(voltageSignal, currentSignal); !ok {
+				return
+			}
+			if !stationarity.Stationary {
+				flags |= signal.FlagNonStationary
+			}
+		}
+		started := time.Now()
+		impedanceData, err := calculator.CalculateImpedance(voltageSignal, currentSignal)
+		if err != nil {
+			log.Printf("Error calculating impedance: %v", err)
+			p.manifest.RecordError(err)
+			return
+		}
+		p.manifest.RecordSpectrum(time.Since(started))
+		impedanceData.Metadata = impedanceData.Metadata.Merge(p.measurementMetadata)
+		impedanceData.Stationarity = stationarity
+		if p.qualityAnalyzer != nil {
+			chunkQuality, err := p.qualityAnalyzer.AnalyzeChunk(voltageSignal, currentSignal)
+			if err != nil {
+				log.Printf("Error analyzing signal quality: %v", err)
+			} else {
+				impedanceData.Quality = &chunkQuality
+				flags |= quality.Flags(chunkQuality, p.minSNR)
+			}
+		}
+		impedanceData.QualityFlags = flags
+		if !p.normalizeSpectrum(&impedanceData) {
+			return
+		}
+		p.checkKramersKronig(&impedanceData)
+		p.annotateSpectrum(&impedanceData)
+		result = &impedanceData
+		p.recordSpectrum(impedanceData)
+		if p.rawSink != nil {
+			p.storeRawChunk(impedanceData.Identity, voltageSignal, currentSignal)
+		}
+
+		written = p.writeSpectrum(sink, signal.ImpedanceDataWithIteration{ImpedanceData: impedanceData}) == nil
+	default:
+		log.Println("Warning: No current signal available for voltage signal")
+	}
+	return written
+}
+
+// pipeline holds the settings and components shared by the processing
+// modes, set up once from the configuration
+type pipeline struct {
+	clock    clock.Clock
+	manifest *output.ManifestRecorder // Inputs, outputs and errors of the run
+
+	// Input and output formats
+	loaderOptions    signal.LoaderOptions
+	outputPaths      *output.PathTemplate
+	csvFormat        output.CSVFormat
+	outputConvention signal.Convention
+	emitBode         bool
+	sendFmin         float64
+	sendFmax         float64
+	normalizeSpectra bool
+	normalizeOrder   signal.FrequencyOrder
+	normalizeLogged  sync.Once
+
+	// Delivery
+	batchSummaries    *network.SummarySender
+	requestSigner     *network.Signer
+	deliveryAudit     *network.AuditLog
+	heartbeater       *network.Heartbeater
+	alertMonitor      *notify.Monitor
+	grafanaDatasource *api.GrafanaDatasource
+	rawSink           output.RawChunkSink
+	rawDownsample     int
+
+	// Chunk checks and spectrum annotations
+	measurementMetadata signal.Metadata
+	qualityAnalyzer     quality.Analyzer
+	minSNR              float64
+	linearityChecker    quality.LinearityChecker
+	aliasingChecker     quality.AliasingChecker
+	stationarityChecker quality.StationarityChecker
+	latestStationarity  atomic.Pointer[signal.Stationarity] // Watched by the system_drift alert
+	blockNonlinear      bool
+	blockAliased        bool
+	blockNonStationary  bool
+	kkChecker           quality.KKChecker
+	latestKK            atomic.Pointer[quality.KKResult] // Watched by the kk_fail alert
+	featureExtractor    features.Extractor
+	emitFeatures        bool
+	classifier          inference.Classifier
+
+	// Excitation waveform decomposed for the generator and lock-in reference
+	waveformTones []signal.Tone
+	waveformDC    float64
+
+	// Checkpointing, enabled when checkpoints is set
+	checkpoints           *output.CheckpointStore
+	checkpointFingerprint string
+	pairsProcessed        int
+}
+
+// newPipeline returns a pipeline on the system clock with nothing enabled
+func newPipeline() *pipeline {
+	return &pipeline{clock: clock.NewSystemClock()}
+}
+
+// newSink creates the sinks of the output modes, sending through sender in
+// batches of batchSize spectra (0 = one by one). The sending sink is also
+// returned, nil without the http mode.
+func (p *pipeline) newSink(modes []string, sender network.Sender, batchSize int) (output.Sink, *network.SenderSink) {
+	options := output.FileSinkOptions{
+		Paths:    p.outputPaths,
+		Clock:    p.clock,
+		Bode:     p.emitBode,
+		Format:   p.csvFormat,
+		Manifest: p.manifest,
+	}
+	var sinks []output.Sink
+	var sending *network.SenderSink
+	for _, mode := range modes {
+		switch mode {
+		case "http":
+			sending = network.NewSenderSink(sender, batchSize)
+			sinks = append(sinks, sending)
+		case "console":
+			sinks = append(sinks, output.NewJSONDirSink(options))
+		case "csv":
+			sinks = append(sinks, output.NewCSVDirSink(options))
+		case "ndjson":
+			sink, err := output.NewNDJSONSink(options)
+			if err != nil {
+				log.Fatalf("Failed to create NDJSON output: %v", err)
+			}
+			sinks = append(sinks, sink)
+		}
+	}
+	sink := output.NewBandSink(output.NewMultiSink(sinks...), p.sendFmin, p.sendFmax)
+	return output.NewConventionSink(sink, p.outputConvention), sending
+}
+
+// writeSpectrum hands a spectrum to the output sinks, logging failures
+func (p *pipeline) writeSpectrum(sink output.Sink, item signal.ImpedanceDataWithIteration) error {
+	err := sink.Write(item)
+	if err != nil {
+		log.Printf("Error writing spectrum: %v", err)
+		p.manifest.RecordError(err)
+	}
+	return err
+}
+
+// closeSink flushes and closes the output sinks, logging failures
+func (p *pipeline) closeSink(sink output.Sink) {
+	if err := sink.Close(); err != nil {
+		log.Printf("Error closing outputs: %v", err)
+		p.manifest.RecordError(err)
+	}
+}
+
+// runMode names the pipeline selected by the configuration
+func runMode(cfg *config.Config) string {
+	switch {
+	case cfg.ImpedanceCSV != "":
+		return "impedance-csv"
+	case cfg.UseDirectEIS:
+		return "direct"
+	case cfg.UseFileData:
+		return "file"
+	case cfg.OPCUAEndpoint != "":
+		return "opcua"
+	case cfg.ModbusAddress != "":
+		return "modbus"
+	case cfg.SCPIAddress != "":
+		return "scpi"
+	case cfg.UseAudio:
+		return "audio"
+	case cfg.SPIDevice != "":
+		return "spi"
+	case cfg.ServeIngest:
+		return "ingest"
+	default:
+		return "synthetic"
+	}
+}
+
+// toneRange returns the lowest and highest frequency of tones
+func toneRange(tones []signal.Tone) (float64, float64) {
+	if len(tones) == 0 {
+		return 0, 0
+	}
+	fmin, fmax := tones[0].Frequency, tones[0].Frequency
+	for _, tone := range tones[1:] {
+		fmin, fmax = math.Min(fmin, tone.Frequency), math.Max(fmax, tone.Frequency)
+	}
+	return fmin, fmax
 }
 
 // getCircuitParameters returns circuit parameters based on complexity level
@@ -298,21 +1216,234 @@ func getCircuitParameters(circuitType string) eisgen.CircuitParameters {
 	}
 }
 
+// datasetHeader describes how a generated_eis_data file was produced
+func (p *pipeline) datasetHeader(cfg *config.Config, params eisgen.CircuitParameters) output.CSVMetadata {
+	header := output.CSVMetadata{}
+	header.Add("generator", "masterapp direct EIS")
+	header.Add("app_version", output.BuildVersion())
+	header.Add("circuit", cfg.CircuitType)
+	header.Add("model", "Rs + (Rct || CPE), Rct = Rct_initial + spectrum * Rct_growth")
+	header.Add("parameters", fmt.Sprintf("Rs=%g Rct_initial=%g Rct_growth=%g Q=%g n=%g",
+		params.Rs, params.RctInitial, params.RctGrowth, params.Q, params.N))
+	header.Add("spectra", cfg.SpectraCount)
+	header.Add("started", p.clock.Now().Format(time.RFC3339))
+	if !p.measurementMetadata.IsZero() {
+		if metadata, err := json.Marshal(p.measurementMetadata); err == nil {
+			header.Add("metadata", string(metadata))
+		}
+	}
+	return header
+}
+
+// waveformThreshold is the fraction of the strongest component down to which
+// excitation waveforms are reproduced by the synthetic generator
+const waveformThreshold = 1e-3
+
+// loadExcitationWaveform loads and scales the excitation waveform and
+// decomposes it into tones
+func (p *pipeline) loadExcitationWaveform(cfg *config.Config) ([]signal.Tone, float64, error) {
+	waveform, err := signal.LoadWaveform(cfg.ExcitationWaveform, cfg.SampleRate, p.loaderOptions.Dialect)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range waveform.Values {
+		waveform.Values[i] *= cfg.ExcitationScale
+	}
+	if waveform.SampleRate != cfg.SampleRate {
+		log.Printf("Warning: excitation waveform is sampled at %g Hz, acquisition at %g Hz", waveform.SampleRate, cfg.SampleRate)
+	}
+
+	tones, dc, err := fft.Tones(waveform, waveformThreshold)
+	if err != nil {
+		return nil, 0, err
+	}
+	log.Printf("Excitation waveform %s: %d samples at %g Hz, %d tones from %g to %g Hz",
+		cfg.ExcitationWaveform, len(waveform.Values), waveform.SampleRate, len(tones), tones[0].Frequency, tones[len(tones)-1].Frequency)
+	return tones, dc, nil
+}
+
+// referenceFrequencies returns the frequencies of the tones strong enough to
+// serve as lock-in references, at least 5% of the strongest
+func referenceFrequencies(tones []signal.Tone) []float64 {
+	strongest := 0.0
+	for _, tone := range tones {
+		strongest = math.Max(strongest, tone.Amplitude)
+	}
+	var frequencies []float64
+	for _, tone := range tones {
+		if tone.Amplitude >= 0.05*strongest {
+			frequencies = append(frequencies, tone.Frequency)
+		}
+	}
+	return frequencies
+}
+
+// newSender creates the network sender for the configured transport
+func (p *pipeline) newSender(cfg *config.Config) network.Sender {
+	sender := p.newTransportSender(cfg)
+	if compactor, ok := sender.(network.GridCompactor); ok {
+		compactor.UseSharedGrid(cfg.SharedGrid)
+	}
+	if signer, ok := sender.(network.RequestSigner); ok {
+		signer.UseSigner(p.requestSigner)
+	}
+	if logger, ok := sender.(network.SendLogger); ok {
+		logger.UseSendLog(network.SendLog(cfg.SendLog))
+	}
+	if encoder, ok := sender.(network.PayloadEncoder); ok {
+		encoder.UseEncoding(signal.EncodeOptions{
+			Flat:               cfg.FlatImpedance,
+			Precision:          cfg.JSONPrecision,
+			OmitMagnitudePhase: cfg.OmitMagnitudePhase,
+			CompactFrequencies: cfg.CompactFrequencies,
+			Float32:            cfg.Float32,
+			Summary:            cfg.BatchSummary,
+		})
+	}
+	if p.deliveryAudit != nil {
+		if auditor, ok := sender.(network.DeliveryAuditor); ok {
+			auditor.UseAuditLog(p.deliveryAudit)
+		} else {
+			sender = network.NewAuditingSender(sender, p.deliveryAudit, transportTarget(cfg))
+		}
+	}
+	if cfg.Capture != "" {
+		recorder, err := network.NewRecordingSender(sender, cfg.Capture, captureSettings(cfg))
+		if err != nil {
+			log.Fatalf("Cannot create capture file: %v", err)
+		}
+		log.Printf("Recording outbound payloads to %s", cfg.Capture)
+		sender = recorder
+	}
+	if cfg.ImpairsNetwork() {
+		impaired, err := network.NewImpairedSender(sender, network.ImpairmentOptions{
+			Latency:        cfg.SimLatency,
+			Jitter:         cfg.SimJitter,
+			DropPercent:    cfg.SimDropPercent,
+			ReorderPercent: cfg.SimReorderPercent,
+			Seed:           cfg.SimNetSeed,
+		})
+		if err != nil {
+			log.Fatalf("Invalid network impairments: %v", err)
+		}
+		log.Printf("Simulating network impairments: latency %v ± %v, %g%% dropped, %g%% reordered",
+			cfg.SimLatency, cfg.SimJitter, cfg.SimDropPercent, cfg.SimReorderPercent)
+		sender = impaired
+	}
+	if cfg.BatchSummary {
+		p.batchSummaries = network.NewSummarySender(sender)
+		sender = p.batchSummaries
+	}
+	return sender
+}
+
+// newTransportSender creates the sender of the configured transport
+func (p *pipeline) newTransportSender(cfg *config.Config) network.Sender {
+	switch cfg.Transport {
+	case "websocket":
+		sender, err := network.NewWSSenderWithClock(cfg.TargetURL, cfg.StreamPath, p.clock)
+		if err != nil {
+			log.Fatalf("Invalid WebSocket stream endpoint: %v", err)
+		}
+		return sender
+	case "unix":
+		sender, err := network.NewUnixSenderWithClock(cfg.IPCSocket, p.clock)
+		if err != nil {
+			log.Fatalf("Invalid IPC socket: %v", err)
+		}
+		return sender
+	case "nats":
+		sender, err := network.NewNATSSenderWithClock(cfg.NATSURL, network.NATSOptions{
+			Subject:   cfg.NATSSubject,
+			JetStream: cfg.NATSJetStream,
+		}, p.clock)
+		if err != nil {
+			log.Fatalf("Invalid NATS server: %v", err)
+		}
+		return sender
+	}
+
+	sender := network.NewSenderWithTransport(cfg.TargetURL, p.clock, network.TransportOptions{
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConns,
+		IdleConnTimeout:     cfg.HTTPIdleTimeout,
+		KeepAlive:           cfg.HTTPKeepAlive,
+		HTTP2:               cfg.HTTP2,
+		DNSCacheTTL:         cfg.DNSCacheTTL,
+
+		DialTimeout:           cfg.HTTPDialTimeout,
+		TLSHandshakeTimeout:   cfg.HTTPTLSTimeout,
+		ResponseHeaderTimeout: cfg.HTTPResponseTimeout,
+		RequestTimeout:        cfg.HTTPTimeout,
+	})
+	if router, ok := sender.(network.EndpointRouter); ok {
+		if err := router.UseEndpoints(network.EndpointPaths{Single: cfg.SinglePath, Batch: cfg.BatchPath}); err != nil {
+			log.Fatalf("Invalid endpoint paths: %v", err)
+		}
+	}
+	return sender
+}
+
+// transportTarget describes where the configured transport delivers to
+func transportTarget(cfg *config.Config) string {
+	switch cfg.Transport {
+	case "unix":
+		return "unix://" + cfg.IPCSocket
+	case "nats":
+		return cfg.Redacted().NATSURL + "/" + cfg.NATSSubject
+	}
+	return cfg.TargetURL
+}
+
+// closeSender releases connections held by senders that keep them open
+func closeSender(sender network.Sender) {
+	if closer, ok := sender.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Warning: failed to close sender: %v", err)
+		}
+	}
+}
+
 // runDirectEISMode runs the direct EIS generation mode (like Python code)
-func runDirectEISMode(cfg *config.Config, outputMode, circuitType string, spectraCount int) {
+func (p *pipeline) runDirectEISMode(cfg *config.Config, resume output.Checkpoint) {
+	circuitType, spectraCount := cfg.CircuitType, cfg.SpectraCount
 	log.Println("Starting Direct EIS generation mode")
 	log.Printf("Circuit complexity: %s", circuitType)
-	log.Printf("Generating %d spectra", spectraCount)
+	log.Printf("Generating %d spectra in batches of %d every %v", spectraCount, cfg.BatchSize, cfg.BatchInterval)
 	
 	// Create EIS generator with parameters based on circuit complexity
-	eisGenerator := eisgen.NewEISGenerator()
+	eisGenerator := eisgen.NewEISGeneratorWithClock(p.clock)
+	eisGenerator.SetCurrentSpectrum(resume.Spectrum)
 	params := getCircuitParameters(circuitType)
+	if err := params.Validate(spectraCount); err != nil {
+		log.Fatalf("Invalid circuit parameters: %v", err)
+	}
+	for _, warning := range params.Plausibility(spectraCount) {
+		log.Printf("Warning: implausible circuit: %s", warning)
+	}
 	
 	log.Printf("Circuit parameters: Rs=%.1f, Rct_initial=%.1f, Q=%.2e, n=%.2f", 
 		params.Rs, params.RctInitial, params.Q, params.N)
 		
-	// Create network sender
-	sender := network.NewSender(cfg.TargetURL)
+	// Create network sender and outputs; the CSV output is the generated data
+	// file, written in every mode
+	sender := p.newSender(cfg)
+	defer closeSender(sender)
+	sink, _ := p.newSink(slices.DeleteFunc(cfg.Outputs(), func(mode string) bool { return mode == "csv" }), sender, cfg.BatchSize)
+	defer p.closeSink(sink)
+
+	p.watchSender(cfg, sender)
+	p.watchKramersKronig(cfg)
+
+	// Report generation progress with every heartbeat
+	var generated atomic.Int64
+	if p.heartbeater != nil {
+		p.heartbeater.RegisterStatus("generator", func() interface{} {
+			return map[string]int64{"generated": generated.Load(), "total": int64(spectraCount)}
+		})
+		if cfg.OutputsTo("http") {
+			p.heartbeater.RegisterHealth(sender.IsHealthy)
+		}
+	}
 	
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -322,28 +1453,68 @@ func runDirectEISMode(cfg *config.Config, outputMode, circuitType string, spectr
 	signalChan := make(chan os.Signal, 1)
 	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 	
-	// Create output file with circuit type in name
-	outputFilePath := fmt.Sprintf("generated_eis_data_%s.csv", circuitType)
-	if _, err := os.Stat("/root/data"); err == nil {
-		// Running in Docker container
-		outputFilePath = fmt.Sprintf("/root/data/generated_eis_data_%s.csv", circuitType)
+	// Create output file with circuit type in name inside the configured data directory
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
 	}
-	outputFile, err := os.Create(outputFilePath)
+	outputFilePath := filepath.Join(cfg.DataDir, fmt.Sprintf("generated_eis_data_%s.csv", circuitType))
+	// A resumed run appends to the data file of the interrupted run, in place
+	// of its metadata footer
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume.Spectrum > 0 {
+		flags = os.O_CREATE | os.O_RDWR
+	}
+	outputFile, err := os.OpenFile(outputFilePath, flags, 0644)
 	if err != nil {
 		log.Fatalf("Failed to create output file: %v", err)
 	}
 	defer outputFile.Close()
+	if resume.Spectrum > 0 {
+		if err := resumeDataFile(outputFile, resume.DataOffset); err != nil {
+			log.Fatalf("Failed to resume output file: %v", err)
+		}
+	}
 	
-	// Write CSV header
-	fmt.Fprintf(outputFile, "Z_real,Z_imag,Spectrum_Number,Frequency_Hz\n")
+	// Write the metadata block and CSV header
+	dataWriter := p.csvFormat.NewWriter(outputFile)
+	if resume.Spectrum == 0 {
+		if _, err := p.datasetHeader(cfg, params).WriteTo(outputFile); err != nil {
+			log.Printf("Warning: failed to write metadata header: %v", err)
+		}
+		dataWriter.Write([]string{"Z_real", "Z_imag", "Spectrum_Number", "Frequency_Hz"})
+		dataWriter.Flush()
+	}
+	defer func() {
+		footer := output.CSVMetadata{}
+		footer.Add("finished", p.clock.Now().Format(time.RFC3339))
+		footer.Add("spectra_written", eisGenerator.GetCurrentSpectrum()-resume.Spectrum)
+		footer.Add("last_spectrum", eisGenerator.GetCurrentSpectrum()-1)
+		if _, err := footer.WriteTo(outputFile); err != nil {
+			log.Printf("Warning: failed to write metadata footer: %v", err)
+		}
+	}()
+	p.manifest.RecordOutput(outputFilePath)
 	log.Printf("Created output file: %s", outputFilePath)
 	
-	// Batch processing: generate 10 spectra per batch every second
-	ticker := time.NewTicker(1 * time.Second)
+	// Batch processing: generate batchSize spectra per batch every interval
+	ticker := time.NewTicker(cfg.BatchInterval)
 	defer ticker.Stop()
 	
 	measurementCounter := 1
-	batchSize := 10
+	batchSize := cfg.BatchSize
+
+	// The checkpoint advances after every batch that was written and flushed,
+	// and stops at the first failure so a resume generates that batch again
+	stalled := false
+	finish := func() {
+		log.Printf("Generated all %d spectra, stopping...", spectraCount)
+		if stalled {
+			log.Printf("Keeping the checkpoint, resume to generate the failed batches again")
+		} else {
+			p.clearCheckpoint()
+		}
+		cancel()
+	}
 	
 	for {
 		select {
@@ -367,7 +1538,16 @@ func runDirectEISMode(cfg *config.Config, outputMode, circuitType string, spectr
 				}
 				
 				// Generate EIS spectrum
+				started := time.Now()
 				impedanceData := eisGenerator.GenerateEISSpectrum(params)
+				p.manifest.RecordSpectrum(time.Since(started))
+				impedanceData.Metadata = impedanceData.Metadata.Merge(p.measurementMetadata)
+				if !p.normalizeSpectrum(&impedanceData) {
+					continue
+				}
+				p.checkKramersKronig(&impedanceData)
+				p.annotateSpectrum(&impedanceData)
+				p.recordSpectrum(impedanceData)
 				
 				// Create batch item with iteration number for proper ordering
 				batchItem := signal.ImpedanceDataWithIteration{
@@ -377,19 +1557,33 @@ func runDirectEISMode(cfg *config.Config, outputMode, circuitType string, spectr
 				batch = append(batch, batchItem)
 				
 				// Always save to CSV file
-				for j, z := range impedanceData.Impedance {
-					fmt.Fprintf(outputFile, "%.12e,%.12e,%d,%.12e\n", 
-						real(z), imag(z), currentSpectrum, impedanceData.Frequencies[j])
+				presented := p.outputConvention.Apply(impedanceData.Band(p.sendFmin, p.sendFmax))
+				for j, z := range presented.Impedance {
+					dataWriter.Write([]string{
+						p.csvFormat.Float(real(z), 'e', 12),
+						p.csvFormat.Float(imag(z), 'e', 12),
+						strconv.Itoa(currentSpectrum),
+						p.csvFormat.Float(presented.Frequencies[j], 'e', 12),
+					})
 				}
 			}
 			
 			if len(batch) == 0 {
-				log.Printf("Generated all %d spectra, stopping...", spectraCount)
-				cancel()
+				finish()
 				return
 			}
 			
-			outputFile.Sync() // Ensure data is written to disk
+			written := true
+			dataWriter.Flush()
+			if err := dataWriter.Error(); err != nil {
+				log.Printf("Error writing data file: %v", err)
+				p.manifest.RecordError(err)
+				written = false
+			} else if err := outputFile.Sync(); err != nil { // Ensure data is written to disk
+				log.Printf("Error syncing data file: %v", err)
+				p.manifest.RecordError(err)
+				written = false
+			}
 			
 			log.Printf("Generated batch of %d spectra (iterations %d-%d) at %s", 
 				len(batch), 
@@ -397,115 +1591,148 @@ func runDirectEISMode(cfg *config.Config, outputMode, circuitType string, spectr
 				batch[len(batch)-1].Iteration,
 				time.Now().Format("15:04:05"))
 			
-			// Output based on mode
-			switch outputMode {
-			case "http":
-				// Send batch via HTTP to goimpcore
-				if err := sender.SendBatchImpedanceData(batch); err != nil {
-					log.Printf("Error sending batch impedance data: %v", err)
-				}
-				
-			case "console":
-				// Save individual measurements to JSON files
-				for _, item := range batch {
-					eisMeasurement := make(signal.EISMeasurement, len(item.ImpedanceData.Impedance))
-					for j, z := range item.ImpedanceData.Impedance {
-						eisMeasurement[j] = signal.ImpedancePoint{
-							Frequency: item.ImpedanceData.Frequencies[j],
-							Real:      real(z),
-							Imag:      imag(z),
-						}
-					}
-					printEISMeasurement(eisMeasurement, "json")
+			// Hand the batch to the outputs, sending it as one
+			for _, item := range batch {
+				if p.writeSpectrum(sink, item) != nil {
+					written = false
 				}
-				
-			case "csv":
-				// Already saved above
+			}
+			if err := sink.Flush(); err != nil {
+				log.Printf("Error flushing outputs: %v", err)
+				p.manifest.RecordError(err)
+				written = false
 			}
 			
 			measurementCounter += len(batch)
+			generated.Add(int64(len(batch)))
+			if advancer, ok := p.clock.(clock.Advancer); ok {
+				advancer.Advance(cfg.BatchInterval)
+			}
+			if !written && !stalled {
+				stalled = true
+				log.Printf("Warning: output of spectra %d-%d failed, the checkpoint stays before them", batch[0].Iteration, batch[len(batch)-1].Iteration)
+			}
+			if !stalled {
+				// The data file length lets a resume drop rows generated after the checkpoint
+				offset, _ := outputFile.Seek(0, io.SeekCurrent)
+				p.saveCheckpoint(output.Checkpoint{Mode: "direct", Spectrum: eisGenerator.GetCurrentSpectrum(), DataOffset: offset})
+			}
 			
 			// Check if we've generated all spectra
-			if eisGenerator.GetCurrentSpectrum() >= 100 {
-				log.Println("Generated all 100 spectra, stopping...")
-				cancel()
+			if eisGenerator.GetCurrentSpectrum() >= spectraCount {
+				finish()
 				return
 			}
 		}
 	}
 }
 
-// runImpedanceCSVMode reads impedance data from CSV file and sends it to target
-func runImpedanceCSVMode(cfg *config.Config, outputMode, csvPath string) {
+// runImpedanceCSVMode streams impedance data from CSV file and sends it to target in chunks
+func (p *pipeline) runImpedanceCSVMode(cfg *config.Config, resume output.Checkpoint) {
+	csvPath := cfg.ImpedanceCSV
 	log.Println("Starting Impedance CSV mode")
 	log.Printf("Reading impedance data from: %s", csvPath)
-	
+
 	// Create data loader
-	dataLoader := signal.NewDataLoader()
-	csvLoader, ok := dataLoader.(*signal.CSVDataLoader)
-	if !ok {
-		log.Fatalf("Failed to create CSV data loader")
+	dataLoader := signal.NewDataLoaderWithOptions(p.loaderOptions)
+
+	// Create network sender and outputs, sending chunks of spectra as batches
+	sender := p.newSender(cfg)
+	defer closeSender(sender)
+	sink, sending := p.newSink(cfg.Outputs(), sender, cfg.CSVChunkSize)
+
+	p.watchSender(cfg, sender)
+	p.watchKramersKronig(cfg)
+
+	// Report streaming progress with every heartbeat
+	var streamed atomic.Int64
+	if p.heartbeater != nil {
+		p.heartbeater.RegisterStatus("impedance_csv", func() interface{} {
+			return map[string]int64{"spectra_read": streamed.Load()}
+		})
+		if sending != nil {
+			p.heartbeater.RegisterHealth(sender.IsHealthy)
+		}
 	}
-	
-	// Load impedance data from CSV
-	impedanceData, err := csvLoader.LoadImpedanceFromCSV(csvPath)
-	if err != nil {
-		log.Fatalf("Failed to load impedance data: %v", err)
+
+	if resume.SpectraRead > 0 {
+		log.Printf("Skipping %d spectra delivered before the checkpoint", resume.SpectraRead)
 	}
-	
-	log.Printf("Loaded %d spectra from CSV file", len(impedanceData))
-	
-	// Create network sender
-	sender := network.NewSender(cfg.TargetURL)
-	
-	// Wait a bit for goimpcore to be ready (in Docker environment)
-	log.Println("Waiting 5 seconds for target server to be ready...")
-	time.Sleep(5 * time.Second)
-	
-	// Output based on mode
-	switch outputMode {
-	case "http":
-		// Send all spectra as a single batch to goimpcore
-		log.Printf("Sending %d spectra as batch to: %s", len(impedanceData), cfg.TargetURL)
-		
-		if err := sender.SendBatchImpedanceData(impedanceData); err != nil {
-			log.Printf("Error sending batch impedance data: %v", err)
-		} else {
-			log.Printf("Successfully sent batch of %d spectra", len(impedanceData))
-		}
-		
-	case "console":
-		// Save individual measurements to JSON files
-		log.Printf("Saving %d spectra to JSON files", len(impedanceData))
-		
-		for _, item := range impedanceData {
-			eisMeasurement := make(signal.EISMeasurement, len(item.ImpedanceData.Impedance))
-			for j, z := range item.ImpedanceData.Impedance {
-				eisMeasurement[j] = signal.ImpedancePoint{
-					Frequency: item.ImpedanceData.Frequencies[j],
-					Real:      real(z),
-					Imag:      imag(z),
-				}
+
+	spectraRead := 0
+	chunksSent := 0
+	sendErrors := 0
+	lastProgress := 0.0
+
+	// Sent chunks are logged and checkpointed, spectra are delivered once sent.
+	// The checkpoint records the file position of the last spectrum sent, and
+	// stops advancing at the first failed chunk so a resume sends it again.
+	readAt := make(map[uint64]int) // File position of every spectrum by sequence number
+	if sending != nil {
+		sending.OnBatch(func(chunk []signal.ImpedanceDataWithIteration, err error) {
+			chunksSent++
+			last := readAt[chunk[len(chunk)-1].ImpedanceData.Sequence]
+			for _, item := range chunk {
+				delete(readAt, item.ImpedanceData.Sequence)
 			}
-			printEISMeasurement(eisMeasurement, "json")
-		}
-		
-	case "csv":
-		// Save each spectrum as separate CSV file
-		log.Printf("Saving %d spectra to CSV files", len(impedanceData))
-		
-		for _, item := range impedanceData {
-			eisMeasurement := make(signal.EISMeasurement, len(item.ImpedanceData.Impedance))
-			for j, z := range item.ImpedanceData.Impedance {
-				eisMeasurement[j] = signal.ImpedancePoint{
-					Frequency: item.ImpedanceData.Frequencies[j],
-					Real:      real(z),
-					Imag:      imag(z),
-				}
+			if err != nil {
+				sendErrors++
+				return
+			}
+			log.Printf("Sent chunk %d: spectra %d-%d (%d read, %.1f%% of file)",
+				chunksSent, chunk[0].Iteration, chunk[len(chunk)-1].Iteration, spectraRead, lastProgress*100)
+			if sendErrors == 0 {
+				p.saveCheckpoint(output.Checkpoint{Mode: "impedance-csv", SpectraRead: last})
 			}
-			printEISMeasurement(eisMeasurement, "csv")
+		})
+	}
+
+	err := dataLoader.StreamImpedanceFromCSV(csvPath, func(item signal.ImpedanceDataWithIteration, progress float64) error {
+		spectraRead++
+		streamed.Store(int64(spectraRead))
+		if spectraRead <= resume.SpectraRead {
+			return nil // Delivered before the checkpoint
+		}
+		p.manifest.RecordSpectrum(0)
+		lastProgress = progress
+		item.ImpedanceData.Metadata = item.ImpedanceData.Metadata.Merge(p.measurementMetadata)
+		if !p.normalizeSpectrum(&item.ImpedanceData) {
+			return nil
+		}
+		p.checkKramersKronig(&item.ImpedanceData)
+		p.annotateSpectrum(&item.ImpedanceData)
+		p.recordSpectrum(item.ImpedanceData)
+
+		if sending != nil {
+			readAt[item.ImpedanceData.Sequence] = spectraRead
+		}
+		if p.writeSpectrum(sink, item) != nil {
+			sendErrors++
 		}
+		if sending == nil && sendErrors == 0 {
+			p.saveCheckpoint(output.Checkpoint{Mode: "impedance-csv", SpectraRead: spectraRead})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to load impedance data: %v", err)
 	}
-	
-	log.Println("Impedance CSV processing completed")
+	for _, report := range dataLoader.ParseReports() {
+		if !report.Clean() {
+			log.Printf("Parse report: %s", report)
+		}
+	}
+
+	// Send the final partial chunk
+	p.closeSink(sink)
+
+	if sending != nil {
+		log.Printf("Sent %d spectra in %d chunks (%d failed) to: %s", spectraRead, chunksSent, sendErrors, cfg.TargetURL)
+	}
+	log.Printf("Impedance CSV processing completed: %d spectra", spectraRead)
+	if sendErrors > 0 {
+		log.Printf("Keeping the checkpoint, resume to deliver the failed spectra again")
+		return
+	}
+	p.clearCheckpoint()
 }
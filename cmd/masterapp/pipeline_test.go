@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/golden"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the pipeline tests with the current outputs")
+
+// runMainEnv makes the test binary run main instead of the tests, so each
+// pipeline test gets a fresh process with its own flags
+const runMainEnv = "MASTERAPP_PIPELINE_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// payloadCapture records the requests a pipeline sends to its target
+type payloadCapture struct {
+	mu       sync.Mutex
+	payloads map[string][]byte
+}
+
+func (c *payloadCapture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	endpoint := strings.ReplaceAll(strings.Trim(r.URL.Path, "/"), "/", "_")
+	if endpoint == "" {
+		endpoint = "root"
+	}
+	c.mu.Lock()
+	name := fmt.Sprintf("http/%03d_%s.json", len(c.payloads)+1, endpoint)
+	c.payloads[name] = body
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// runPipeline runs masterapp with args in dir and returns its output files and
+// HTTP payloads keyed by golden file name
+func runPipeline(t *testing.T, dir string, args []string) map[string][]byte {
+	t.Helper()
+
+	capture := &payloadCapture{payloads: make(map[string][]byte)}
+	server := httptest.NewServer(capture)
+	defer server.Close()
+
+	cmd := exec.Command(os.Args[0], append([]string{"-target=" + server.URL}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = []string{runMainEnv + "=1"}
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "MASTERAPP_") {
+			cmd.Env = append(cmd.Env, variable)
+		}
+	}
+	log, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("pipeline failed: %v\n%s", err, log)
+	}
+
+	outputs, err := golden.ReadDir(filepath.Join(dir, "out"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("reading outputs: %v", err)
+	}
+	if outputs == nil {
+		outputs = make(map[string][]byte)
+	}
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	for name, payload := range capture.payloads {
+		outputs[name] = payload
+	}
+	return outputs
+}
+
+func TestPipelineGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("file input is paced at one chunk per second")
+	}
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileInput := []string{
+		"-file",
+		"-voltage=" + filepath.Join(fixtures, "voltage.csv"),
+		"-current=" + filepath.Join(fixtures, "current.csv"),
+		"-rate=200", "-samples=200", "-exit-on-complete",
+	}
+	common := []string{"-clock=simulated", "-output-dir=out", "-output-template={format}/eis_{counter}.{ext}"}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"file_console", append(fileInput, "-output=console", "-bode", "-quality", "-features")},
+		{"file_csv", append(fileInput, "-output=csv", "-channel=ch1", "-labels=cell=A")},
+		{"file_http_lockin", append(fileInput, "-output=http", "-estimator=lockin", "-excitation-frequencies=1,2,5,10,20,50")},
+		{"impedance_csv_http", []string{"-impedance-csv=" + filepath.Join(fixtures, "impedance.csv"), "-output=http", "-csv-chunk-size=2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			outputs := runPipeline(t, t.TempDir(), append(common, tt.args...))
+			if len(outputs) == 0 {
+				t.Fatal("pipeline produced no output")
+			}
+			golden.CompareDir(t, filepath.Join("testdata", "golden", tt.name), outputs, *update, golden.Options{
+				Tolerance: golden.Tolerance{Abs: 1e-6, Rel: 1e-6}, // CSV outputs are rounded to 6 decimals
+				Ignore:    []string{"id"},                         // Random UUIDs
+			})
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	ossignal "os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/network"
+)
+
+// captureOptions decide how the sender encodes and delivers payloads. They
+// are recorded in the session record of -capture files and applied again by
+// the replay command.
+var captureOptions = []string{
+	"transport", "target_url", "stream_path", "single_path", "batch_path", "ipc_socket", "nats_url", "nats_subject", "nats_jetstream",
+	"shared_grid", "flat_impedance", "json_precision", "omit_magnitude_phase", "compact_frequencies", "float32", "batch_summary", "sign_secret",
+	"http_max_idle_conns", "http_idle_timeout", "http_keep_alive", "http2", "dns_cache_ttl",
+	"http_dial_timeout", "http_tls_timeout", "http_response_timeout", "http_timeout",
+}
+
+// captureSettings returns the captureOptions of cfg with secrets redacted
+func captureSettings(cfg *config.Config) map[string]string {
+	redacted := cfg.Redacted()
+	settings := make(map[string]string, len(captureOptions))
+	for _, name := range captureOptions {
+		settings[name], _ = redacted.Get(name) // Known options
+	}
+	return settings
+}
+
+// replayConfig returns the sender configuration of a capture: defaults and
+// MASTERAPP_* environment, overridden by the recorded settings. Redacted
+// secrets must be given again in the environment.
+func replayConfig(settings map[string]string) (*config.Config, error) {
+	cfg := config.NewConfig()
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	for name, value := range settings {
+		if strings.Contains(value, "REDACTED") {
+			env := config.EnvPrefix + strings.ToUpper(name)
+			if _, ok := os.LookupEnv(env); !ok {
+				return nil, fmt.Errorf("the capture redacts %s, set %s to replay it", name, env)
+			}
+			continue
+		}
+		if err := cfg.Set(name, value); err != nil {
+			return nil, fmt.Errorf("capture session: %w", err)
+		}
+	}
+	cfg.Capture = ""
+	return cfg, nil
+}
+
+// runReplayCommand sends the payloads of a -capture file to a collector
+// again, to reproduce what it received during the recorded run
+func runReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	target := fs.String("target", "", "Target URL receiving the replayed payloads with transport=http or websocket (default: the recorded target)")
+	speed := fs.Float64("speed", 1, "Replay speed: 1 keeps the recorded timing, 10 is ten times faster, 0 sends without pauses")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp replay [flags] CAPTURE.ndjson\n\n")
+		fmt.Fprintf(fs.Output(), "Sends every payload recorded with -capture again, in the recorded order and,\nunless -speed=0, with the recorded gaps. Transport, encoding and signing\nfollow the settings recorded with the capture; redacted secrets such as the\nsigning secret are taken from the MASTERAPP_* environment.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one capture file, got %d arguments", fs.NArg())
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	settings, err := network.ReadCaptureSettings(file)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		log.Printf("%s has no session record, replaying with the default transport and encoding", fs.Arg(0))
+	}
+	cfg, err := replayConfig(settings)
+	if err != nil {
+		return err
+	}
+	if *target != "" {
+		cfg.TargetURL = *target
+	}
+	p := newPipeline()
+	if cfg.SignSecret != "" {
+		if p.requestSigner, err = network.NewSigner(cfg.SignSecret, p.clock); err != nil {
+			return err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signalChan := make(chan os.Signal, 1)
+	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer ossignal.Stop(signalChan)
+	go func() {
+		<-signalChan
+		cancel()
+	}()
+
+	sender := p.newSender(cfg)
+	defer closeSender(sender)
+
+	log.Printf("Replaying %s over %s to %s at speed %g", fs.Arg(0), cfg.Transport, transportTarget(cfg), *speed)
+	start := time.Now()
+	stats, err := network.ReplayCapture(ctx, file, sender, *speed)
+	log.Printf("Replayed %d payloads in %s, %d failed", stats.Sent+stats.Failed, time.Since(start).Round(time.Millisecond), stats.Failed)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
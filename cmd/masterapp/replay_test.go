@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+func TestReplayConfig_AppliesCaptureSettings(t *testing.T) {
+	recorded := config.NewConfig()
+	recorded.Transport = "websocket"
+	recorded.TargetURL = "http://collector:8080/eis-data"
+	recorded.FlatImpedance = true
+	recorded.Float32 = true
+	recorded.JSONPrecision = 6
+	recorded.SignSecret = "secret"
+	settings := captureSettings(recorded)
+	if settings["sign_secret"] != "REDACTED" {
+		t.Errorf("recorded sign_secret = %q, want it redacted", settings["sign_secret"])
+	}
+
+	if _, err := replayConfig(settings); err == nil {
+		t.Error("replayConfig() succeeded without the redacted signing secret")
+	}
+
+	t.Setenv(config.EnvPrefix+"SIGN_SECRET", "secret")
+	cfg, err := replayConfig(settings)
+	if err != nil {
+		t.Fatalf("replayConfig() error = %v", err)
+	}
+	if cfg.Transport != recorded.Transport || cfg.TargetURL != recorded.TargetURL || !cfg.FlatImpedance || !cfg.Float32 || cfg.JSONPrecision != 6 || cfg.SignSecret != "secret" {
+		t.Errorf("replayConfig() = transport %q, target %q, flat %v, float32 %v, precision %d, secret %q",
+			cfg.Transport, cfg.TargetURL, cfg.FlatImpedance, cfg.Float32, cfg.JSONPrecision, cfg.SignSecret)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/report"
+)
+
+// runReportCommand renders the output directory of a run into a standalone
+// HTML report and optionally converts it to PDF
+func runReportCommand(args []string) error {
+	defaults := report.DefaultOptions()
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	out := fs.String("out", "", "HTML file to write (default RUN_DIR/report.html)")
+	pdf := fs.Bool("pdf", false, "Also print the report to a PDF next to the HTML file with wkhtmltopdf or headless Chromium")
+	title := fs.String("title", defaults.Title, "Report title")
+	overlay := fs.Int("overlay", defaults.OverlaySpectra, "Spectra drawn in the Nyquist and Bode overlays, evenly spread over the run")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp report [flags] RUN_DIR\n\n")
+		fmt.Fprintf(fs.Output(), "Renders the console (JSON) spectra and manifest.json of a run directory into one HTML file:\nrun summary, Nyquist and Bode overlays, parameter trends, quality flags and fit results.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one run directory, got %d arguments", fs.NArg())
+	}
+	if *overlay < 1 {
+		return fmt.Errorf("-overlay must be positive, got %d", *overlay)
+	}
+
+	run, err := report.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	htmlPath := *out
+	if htmlPath == "" {
+		htmlPath = filepath.Join(fs.Arg(0), "report.html")
+	}
+	file, err := os.Create(htmlPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	buffered := bufio.NewWriter(file)
+	options := report.Options{Title: *title, OverlaySpectra: *overlay}
+	if err := run.WriteHTML(buffered, options); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Report of %d spectra written to %s", len(run.Spectra), htmlPath)
+
+	if !*pdf {
+		return nil
+	}
+	pdfPath := strings.TrimSuffix(htmlPath, filepath.Ext(htmlPath)) + ".pdf"
+	if err := printPDF(htmlPath, pdfPath); err != nil {
+		return err
+	}
+	log.Printf("PDF report written to %s", pdfPath)
+	return nil
+}
+
+// printPDF converts an HTML file to PDF with the first converter found in PATH
+func printPDF(htmlPath, pdfPath string) error {
+	absolute, err := filepath.Abs(htmlPath)
+	if err != nil {
+		return err
+	}
+	converters := []struct {
+		name string
+		args []string
+	}{
+		{"wkhtmltopdf", []string{"--quiet", "--enable-local-file-access", absolute, pdfPath}},
+		{"chromium", nil},
+		{"chromium-browser", nil},
+		{"google-chrome", nil},
+	}
+	for _, converter := range converters {
+		path, err := exec.LookPath(converter.name)
+		if err != nil {
+			continue
+		}
+		args := converter.args
+		if args == nil {
+			args = []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf=" + pdfPath, "file://" + absolute}
+		}
+		if output, err := exec.Command(path, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %w: %s", converter.name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return fmt.Errorf("PDF output needs wkhtmltopdf or a Chromium browser in PATH")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	ossignal "os/signal"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/adam/masterapp/pkg/schedule"
+)
+
+// entryNamePattern keeps entry names usable as directory names
+var entryNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// schedulePlan is the JSON file read by the schedule command
+type schedulePlan struct {
+	Entries []scheduleEntry `json:"entries"`
+}
+
+// scheduleEntry is one campaign run at the times of its cron expression
+type scheduleEntry struct {
+	Name     string   `json:"name"`               // Directory below -runs-dir
+	Schedule string   `json:"schedule"`           // Cron expression, e.g. "0 */2 * * *"
+	Cell     string   `json:"cell"`               // Passed as -cell
+	Profile  string   `json:"profile,omitempty"`  // Passed as -profile
+	Duration string   `json:"duration,omitempty"` // Run time before SIGTERM, e.g. "15m"; empty = until done
+	Args     []string `json:"args"`               // Pipeline flags, e.g. ["-direct", "-spectra=20"]
+
+	cron     *schedule.Cron
+	duration time.Duration
+}
+
+// runScheduleCommand runs generation or file replay campaigns at the times
+// of a plan, one directory per run
+func runScheduleCommand(args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	runsDir := fs.String("runs-dir", "runs", "Directory receiving <entry>/<start time> run directories")
+	dryRun := fs.Bool("dry-run", false, "Print the next trigger times of every entry and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: masterapp schedule [flags] PLAN.json\n\n")
+		fmt.Fprintf(fs.Output(), "Runs the pipeline at cron times per entry of PLAN.json:\n")
+		fmt.Fprintf(fs.Output(), "  {\"entries\": [{\"name\": \"cell-a-nightly\", \"schedule\": \"0 2 * * *\", \"cell\": \"A\",\n")
+		fmt.Fprintf(fs.Output(), "    \"profile\": \"lab-200k\", \"duration\": \"30m\", \"args\": [\"-file\", \"-exit-on-complete\"]}]}\n")
+		fmt.Fprintf(fs.Output(), "Each run writes its output, data, manifest.json and run.log to its own directory.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one plan file, got %d arguments", fs.NArg())
+	}
+
+	entries, err := loadSchedulePlan(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		now := time.Now()
+		for _, entry := range entries {
+			fmt.Printf("%s (%s):\n", entry.Name, entry.cron)
+			next := now
+			for i := 0; i < 5; i++ {
+				if next = entry.cron.Next(next); next.IsZero() {
+					fmt.Println("  never")
+					break
+				}
+				fmt.Printf("  %s\n", next.Format(time.RFC3339))
+			}
+		}
+		return nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the masterapp executable: %w", err)
+	}
+	scheduler := &campaignScheduler{executable: executable, runsDir: *runsDir, running: make(map[string]*exec.Cmd)}
+	return scheduler.run(entries)
+}
+
+// loadSchedulePlan reads and validates the entries of a plan file
+func loadSchedulePlan(path string) ([]scheduleEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan schedulePlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if len(plan.Entries) == 0 {
+		return nil, fmt.Errorf("plan %s has no entries", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		if !entryNamePattern.MatchString(entry.Name) {
+			return nil, fmt.Errorf("entry %d: name '%s' must consist of letters, digits, '.', '_' and '-'", i, entry.Name)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("entry %d: duplicate name '%s'", i, entry.Name)
+		}
+		names[entry.Name] = true
+		if entry.Cell == "" {
+			return nil, fmt.Errorf("entry %s: cell is required", entry.Name)
+		}
+		if entry.cron, err = schedule.Parse(entry.Schedule); err != nil {
+			return nil, fmt.Errorf("entry %s: %w", entry.Name, err)
+		}
+		if entry.Duration != "" {
+			if entry.duration, err = time.ParseDuration(entry.Duration); err != nil || entry.duration <= 0 {
+				return nil, fmt.Errorf("entry %s: invalid duration '%s'", entry.Name, entry.Duration)
+			}
+		}
+	}
+	return plan.Entries, nil
+}
+
+// campaignScheduler starts the runs of plan entries as child processes
+type campaignScheduler struct {
+	executable string
+	runsDir    string
+	mu         sync.Mutex
+	running    map[string]*exec.Cmd // By entry name
+	wg         sync.WaitGroup
+}
+
+// run triggers entries until SIGINT or SIGTERM, then stops the running
+// campaigns and waits for them
+func (s *campaignScheduler) run(entries []scheduleEntry) error {
+	signalChan := make(chan os.Signal, 1)
+	ossignal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer ossignal.Stop(signalChan)
+
+	for {
+		now := time.Now()
+		var due []int
+		var next time.Time
+		for i, entry := range entries {
+			trigger := entry.cron.Next(now)
+			switch {
+			case trigger.IsZero():
+			case next.IsZero() || trigger.Before(next):
+				next, due = trigger, []int{i}
+			case trigger.Equal(next):
+				due = append(due, i)
+			}
+		}
+		if next.IsZero() {
+			log.Println("No entry will trigger again")
+			s.wg.Wait()
+			return nil
+		}
+		log.Printf("Next run at %s: %d entries", next.Format(time.RFC3339), len(due))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-signalChan:
+			timer.Stop()
+			log.Println("Shutdown signal received, stopping running campaigns...")
+			s.stopAll()
+			s.wg.Wait()
+			return nil
+		case <-timer.C:
+		}
+		for _, i := range due {
+			if err := s.start(entries[i], next); err != nil {
+				log.Printf("Entry %s: %v", entries[i].Name, err)
+			}
+		}
+	}
+}
+
+// start launches one run of entry in its own directory unless the previous
+// run is still going
+func (s *campaignScheduler) start(entry scheduleEntry, trigger time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.running[entry.Name]; busy {
+		return fmt.Errorf("previous run still in progress, skipping the %s run", trigger.Format(time.RFC3339))
+	}
+
+	dir := filepath.Join(s.runsDir, entry.Name, trigger.Format("20060102T150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "run.log"))
+	if err != nil {
+		return err
+	}
+
+	// The run directory and cell always override the entry arguments
+	args := append([]string{}, entry.Args...)
+	if entry.Profile != "" {
+		args = append(args, "-profile="+entry.Profile)
+	}
+	args = append(args,
+		"-cell="+entry.Cell,
+		"-output-dir="+dir,
+		"-data-dir="+dir,
+		"-manifest="+filepath.Join(dir, "manifest.json"),
+		"-exit-on-complete",
+	)
+	cmd := exec.Command(s.executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	s.running[entry.Name] = cmd
+	log.Printf("Started %s for cell %s in %s (pid %d)", entry.Name, entry.Cell, dir, cmd.Process.Pid)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer logFile.Close()
+		var limit *time.Timer
+		if entry.duration > 0 {
+			limit = time.AfterFunc(entry.duration, func() {
+				log.Printf("Entry %s reached its duration of %s, stopping it", entry.Name, entry.duration)
+				cmd.Process.Signal(syscall.SIGTERM)
+			})
+		}
+		err := cmd.Wait()
+		if limit != nil {
+			limit.Stop()
+		}
+
+		s.mu.Lock()
+		delete(s.running, entry.Name)
+		s.mu.Unlock()
+		if err != nil {
+			log.Printf("Run %s finished: %v (see %s)", dir, err, logFile.Name())
+		} else {
+			log.Printf("Run %s finished", dir)
+		}
+	}()
+	return nil
+}
+
+// stopAll asks every running campaign to shut down
+func (s *campaignScheduler) stopAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, cmd := range s.running {
+		log.Printf("Stopping %s (pid %d)", name, cmd.Process.Pid)
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/adam/masterapp
 
 go 1.24.4
+
+require github.com/yalue/onnxruntime_go v1.27.0
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/features"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// GrafanaSpectrumTarget is the table target returning the latest spectrum of
+// the queried range with one row per frequency
+const GrafanaSpectrumTarget = "spectrum"
+
+// grafanaTrends maps the time series targets to their value per spectrum
+var grafanaTrends = map[string]func(data signal.ImpedanceData) (float64, bool){
+	"hf_intercept": func(data signal.ImpedanceData) (float64, bool) {
+		return featureValue(data, func(f signal.SpectrumFeatures) float64 { return f.HighFrequencyIntercept })
+	},
+	"lf_intercept": func(data signal.ImpedanceData) (float64, bool) {
+		return featureValue(data, func(f signal.SpectrumFeatures) float64 { return f.LowFrequencyIntercept })
+	},
+	"semicircle_diameter": func(data signal.ImpedanceData) (float64, bool) {
+		return featureValue(data, func(f signal.SpectrumFeatures) float64 { return f.SemicircleDiameter })
+	},
+	"characteristic_frequency": func(data signal.ImpedanceData) (float64, bool) {
+		return featureValue(data, func(f signal.SpectrumFeatures) float64 { return f.CharacteristicFrequency })
+	},
+	"warburg_slope": func(data signal.ImpedanceData) (float64, bool) {
+		if data.Features == nil || data.Features.WarburgSlope == nil {
+			return 0, false
+		}
+		return *data.Features.WarburgSlope, true
+	},
+	"prediction_class": func(data signal.ImpedanceData) (float64, bool) {
+		if data.Prediction == nil {
+			return 0, false
+		}
+		return float64(data.Prediction.Class), true
+	},
+	"prediction_score": func(data signal.ImpedanceData) (float64, bool) {
+		if data.Prediction == nil || data.Prediction.Class >= len(data.Prediction.Scores) {
+			return 0, false
+		}
+		return data.Prediction.Scores[data.Prediction.Class], true
+	},
+}
+
+// featureValue returns one scalar feature of a spectrum, if it has features
+func featureValue(data signal.ImpedanceData, value func(signal.SpectrumFeatures) float64) (float64, bool) {
+	if data.Features == nil {
+		return 0, false
+	}
+	return value(*data.Features), true
+}
+
+// GrafanaDatasource serves recent spectra through the SimpleJSON datasource
+// contract: GET / for the connection test, POST /search for the target names
+// and POST /query for parameter trends and the latest spectrum. The Infinity
+// datasource can POST the same query bodies. Spectra are kept in a ring of
+// fixed capacity, so memory stays bounded however long the process runs.
+type GrafanaDatasource struct {
+	mu        sync.RWMutex
+	spectra   []signal.ImpedanceData
+	next      int
+	extractor features.Extractor
+}
+
+// grafanaQuery is the body of a SimpleJSON /query request
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+	MaxDataPoints int `json:"maxDataPoints"`
+}
+
+// grafanaSeries is a time series response with [value, unix ms] datapoints
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaColumn describes one column of a table response
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// grafanaTable is a table response
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]float64     `json:"rows"`
+}
+
+// NewGrafanaDatasource creates a datasource keeping the most recent capacity spectra
+func NewGrafanaDatasource(capacity int) *GrafanaDatasource {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &GrafanaDatasource{
+		spectra:   make([]signal.ImpedanceData, 0, capacity),
+		extractor: features.NewExtractor(),
+	}
+}
+
+// Record adds a spectrum, replacing the oldest one when the ring is full.
+// Features are extracted here if the pipeline did not attach them, so the
+// trends are available without -features.
+func (g *GrafanaDatasource) Record(data signal.ImpedanceData) {
+	if data.Features == nil {
+		if spectrumFeatures, err := g.extractor.Extract(data); err == nil {
+			data.Features = &spectrumFeatures
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.spectra) < cap(g.spectra) {
+		g.spectra = append(g.spectra, data)
+		return
+	}
+	g.spectra[g.next] = data
+	g.next = (g.next + 1) % len(g.spectra)
+}
+
+// Targets returns the names /search offers
+func (g *GrafanaDatasource) Targets() []string {
+	targets := []string{GrafanaSpectrumTarget}
+	for name := range grafanaTrends {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// ServeHTTP routes the SimpleJSON endpoints
+func (g *GrafanaDatasource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/search":
+		g.handleSearch(w, r)
+	case "/query":
+		g.handleQuery(w, r)
+	case "/annotations":
+		writeJSON(w, http.StatusOK, []interface{}{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleSearch lists the targets containing the requested text
+func (g *GrafanaDatasource) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid search request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches := []string{}
+	for _, target := range g.Targets() {
+		if strings.Contains(target, request.Target) {
+			matches = append(matches, target)
+		}
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
+
+// handleQuery answers every target of the query for the spectra in its range
+func (g *GrafanaDatasource) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var query grafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	spectra := g.spectraBetween(query.Range.From, query.Range.To)
+	response := make([]interface{}, 0, len(query.Targets))
+	for _, target := range query.Targets {
+		if target.Target == GrafanaSpectrumTarget {
+			response = append(response, spectrumTable(spectra))
+			continue
+		}
+		trend, ok := grafanaTrends[target.Target]
+		if !ok {
+			http.Error(w, "unknown target "+target.Target, http.StatusBadRequest)
+			return
+		}
+		response = append(response, trendSeries(target.Target, trend, spectra, query.MaxDataPoints))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// spectraBetween returns the recorded spectra of the range in time order;
+// a zero bound leaves that side open
+func (g *GrafanaDatasource) spectraBetween(from, to time.Time) []signal.ImpedanceData {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var result []signal.ImpedanceData
+	for i := range g.spectra {
+		data := g.spectra[(g.next+i)%len(g.spectra)]
+		if (!from.IsZero() && data.Timestamp.Before(from)) || (!to.IsZero() && data.Timestamp.After(to)) {
+			continue
+		}
+		result = append(result, data)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return result
+}
+
+// trendSeries collects one value per spectrum, keeping every n-th point when
+// there are more than maxPoints
+func trendSeries(name string, trend func(signal.ImpedanceData) (float64, bool), spectra []signal.ImpedanceData, maxPoints int) grafanaSeries {
+	series := grafanaSeries{Target: name, Datapoints: [][2]float64{}}
+	for _, data := range spectra {
+		if value, ok := trend(data); ok {
+			series.Datapoints = append(series.Datapoints, [2]float64{value, float64(data.Timestamp.UnixMilli())})
+		}
+	}
+	if maxPoints > 0 && len(series.Datapoints) > maxPoints {
+		stride := (len(series.Datapoints) + maxPoints - 1) / maxPoints
+		kept := series.Datapoints[:0]
+		for i := 0; i < len(series.Datapoints); i += stride {
+			kept = append(kept, series.Datapoints[i])
+		}
+		series.Datapoints = kept
+	}
+	return series
+}
+
+// spectrumTable returns the last spectrum as rows of frequency, real and
+// imaginary part, magnitude and phase, e.g. for a Nyquist XY chart
+func spectrumTable(spectra []signal.ImpedanceData) grafanaTable {
+	table := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{Text: "frequency", Type: "number"},
+			{Text: "real", Type: "number"},
+			{Text: "imag", Type: "number"},
+			{Text: "magnitude", Type: "number"},
+			{Text: "phase", Type: "number"},
+		},
+		Rows: [][]float64{},
+	}
+	if len(spectra) == 0 {
+		return table
+	}
+
+	data := spectra[len(spectra)-1]
+	magnitude, phase := data.CalculateMagnitudePhase()
+	for i, z := range data.Impedance {
+		if i >= len(data.Frequencies) {
+			break
+		}
+		table.Rows = append(table.Rows, []float64{data.Frequencies[i], real(z), imag(z), magnitude[i], phase[i]})
+	}
+	return table
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestGrafanaDatasource(t *testing.T) {
+	start := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	datasource := NewGrafanaDatasource(3)
+	for i := 0; i < 5; i++ {
+		datasource.Record(signal.ImpedanceData{
+			Timestamp:   start.Add(time.Duration(i) * time.Minute),
+			Frequencies: []float64{1, 10},
+			Impedance:   []complex128{complex(float64(10+i), -1), complex(float64(5+i), -2)},
+			Features:    &signal.SpectrumFeatures{HighFrequencyIntercept: float64(i)},
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/grafana/", http.StripPrefix("/grafana", datasource))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/grafana/")
+	if err != nil {
+		t.Fatalf("connection test: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("connection test status = %d, want 200", response.StatusCode)
+	}
+
+	post := func(path, body string, v interface{}) int {
+		t.Helper()
+		response, err := http.Post(server.URL+"/grafana"+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+				t.Fatalf("decoding %s response: %v", path, err)
+			}
+		}
+		return response.StatusCode
+	}
+
+	var targets []string
+	post("/search", `{"target":"intercept"}`, &targets)
+	if len(targets) != 2 || targets[0] != "hf_intercept" || targets[1] != "lf_intercept" {
+		t.Errorf("search targets = %v, want the intercepts", targets)
+	}
+
+	// The ring keeps the last three spectra; the range selects two of them
+	query := `{"range":{"from":"2026-05-01T08:02:00Z","to":"2026-05-01T08:03:30Z"},"targets":[{"target":"hf_intercept"},{"target":"spectrum"}]}`
+	var results []json.RawMessage
+	if status := post("/query", query, &results); status != http.StatusOK || len(results) != 2 {
+		t.Fatalf("query status = %d with %d results, want 200 with 2", status, len(results))
+	}
+
+	var series grafanaSeries
+	if err := json.Unmarshal(results[0], &series); err != nil {
+		t.Fatalf("decoding series: %v", err)
+	}
+	want := [][2]float64{{2, float64(start.Add(2 * time.Minute).UnixMilli())}, {3, float64(start.Add(3 * time.Minute).UnixMilli())}}
+	if len(series.Datapoints) != len(want) || series.Datapoints[0] != want[0] || series.Datapoints[1] != want[1] {
+		t.Errorf("datapoints = %v, want %v", series.Datapoints, want)
+	}
+
+	var table grafanaTable
+	if err := json.Unmarshal(results[1], &table); err != nil {
+		t.Fatalf("decoding table: %v", err)
+	}
+	if table.Type != "table" || len(table.Rows) != 2 || table.Rows[0][0] != 1 || table.Rows[0][1] != 13 || table.Rows[1][2] != -2 {
+		t.Errorf("table = %+v, want the spectrum at 08:03", table)
+	}
+
+	if status := post("/query", `{"targets":[{"target":"unknown"}]}`, &results); status != http.StatusBadRequest {
+		t.Errorf("unknown target status = %d, want 400", status)
+	}
+}
+
+func TestTrendSeries_MaxDataPoints(t *testing.T) {
+	var spectra []signal.ImpedanceData
+	for i := 0; i < 10; i++ {
+		spectra = append(spectra, signal.ImpedanceData{Features: &signal.SpectrumFeatures{SemicircleDiameter: float64(i)}})
+	}
+	series := trendSeries("semicircle_diameter", grafanaTrends["semicircle_diameter"], spectra, 4)
+	if len(series.Datapoints) != 4 || series.Datapoints[1][0] != 3 {
+		t.Errorf("datapoints = %v, want every third of 10 points", series.Datapoints)
+	}
+}
@@ -0,0 +1,4 @@
+package api
+
+// StatusFunc returns a JSON-serializable status snapshot for a component
+type StatusFunc func() interface{}
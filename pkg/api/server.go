@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Server exposes a small REST API reporting the state of the running pipeline
+type Server struct {
+	addr      string
+	mux       *http.ServeMux
+	server    *http.Server
+	startedAt time.Time
+	mu        sync.RWMutex
+	sources   map[string]StatusFunc
+}
+
+// NewServer creates a new REST API server listening on addr
+func NewServer(addr string) *Server {
+	s := &Server{
+		addr:      addr,
+		mux:       http.NewServeMux(),
+		startedAt: time.Now(),
+		sources:   make(map[string]StatusFunc),
+	}
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// RegisterStatus adds a named component to the /status response
+func (s *Server) RegisterStatus(name string, fn StatusFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[name] = fn
+}
+
+// Handle registers an additional handler on the API mux
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start begins serving requests in the background
+func (s *Server) Start() error {
+	if s.addr == "" {
+		return config.NewValidationError("StatusAddr", "listen address cannot be empty")
+	}
+
+	go func() {
+		log.Printf("REST API listening on %s", s.addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("REST API server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// handleStatus reports uptime and the status of every registered component
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	status := make(map[string]interface{}, len(s.sources)+2)
+	for name, fn := range s.sources {
+		status[name] = fn()
+	}
+	s.mu.RUnlock()
+
+	status["started_at"] = s.startedAt
+	status["uptime_seconds"] = time.Since(s.startedAt).Seconds()
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding API response: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_HandleStatus(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	s.startedAt = time.Now().Add(-time.Minute)
+	s.RegisterStatus("receiver", func() interface{} { return map[string]int{"signals_emitted": 3} })
+	s.RegisterStatus("sender", func() interface{} { return map[string]bool{"healthy": true} })
+	server := httptest.NewServer(s.mux)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	var status struct {
+		Receiver      map[string]int  `json:"receiver"`
+		Sender        map[string]bool `json:"sender"`
+		StartedAt     time.Time       `json:"started_at"`
+		UptimeSeconds float64         `json:"uptime_seconds"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Receiver["signals_emitted"] != 3 || !status.Sender["healthy"] {
+		t.Errorf("sources = %v, %v, want the registered receiver and sender status", status.Receiver, status.Sender)
+	}
+	if !status.StartedAt.Equal(s.startedAt) {
+		t.Errorf("started_at = %v, want %v", status.StartedAt, s.startedAt)
+	}
+	if status.UptimeSeconds < 60 || status.UptimeSeconds > 120 {
+		t.Errorf("uptime_seconds = %g, want about 60", status.UptimeSeconds)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		request, err := http.NewRequest(method, server.URL+"/status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s /status = %d, want 405", method, response.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// SystemClock reads the wall clock
+type SystemClock struct{}
+
+// NewSystemClock creates a clock backed by time.Now
+func NewSystemClock() Clock {
+	return SystemClock{}
+}
+
+// Now returns the current wall-clock time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SimulatedClock starts at a fixed time and only advances when producers
+// report how much signal time they generated, so timestamps are derived from
+// sample offsets and runs are reproducible
+type SimulatedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewSimulatedClock creates a simulated clock starting at start
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start}
+}
+
+// Now returns the simulated time
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the simulated time forward by d
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// AdvanceSamples advances c by the duration of n samples at sampleRate when c
+// is a simulated clock; wall clocks are left alone
+func AdvanceSamples(c Clock, n int, sampleRate float64) {
+	if advancer, ok := c.(Advancer); ok && sampleRate > 0 {
+		advancer.Advance(time.Duration(float64(n) / sampleRate * float64(time.Second)))
+	}
+}
+
+// OrSystem returns c, or the system clock when c is nil
+func OrSystem(c Clock) Clock {
+	if c == nil {
+		return NewSystemClock()
+	}
+	return c
+}
@@ -0,0 +1,23 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulatedClock_AdvanceSamples(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewSimulatedClock(start)
+
+	if got := clk.Now(); !got.Equal(start) {
+		t.Fatalf("expected %v, got %v", start, got)
+	}
+
+	AdvanceSamples(clk, 200, 200000)
+	if got := clk.Now().Sub(start); got != time.Millisecond {
+		t.Errorf("expected 200 samples at 200 kHz to advance 1ms, got %v", got)
+	}
+
+	// Wall clocks are not advanced and must not panic
+	AdvanceSamples(NewSystemClock(), 200, 200000)
+}
@@ -0,0 +1,15 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock provides the current time to components that timestamp data
+type Clock interface {
+	Now() time.Time
+}
+
+// Advancer is implemented by clocks whose time only moves when told to
+type Advancer interface {
+	Advance(d time.Duration)
+}
@@ -0,0 +1,128 @@
+package compare
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SpectrumError quantifies how far a candidate spectrum deviates from its
+// reference. Relative errors are in percent of the reference |Z|.
+type SpectrumError struct {
+	Spectrum          int     `json:"spectrum"`
+	Points            int     `json:"points"`              // Candidate points within the reference frequency range
+	RMSRelativeError  float64 `json:"rms_relative_error"`  // RMS of |Zc - Zr| / |Zr|
+	MaxRelativeError  float64 `json:"max_relative_error"`  // Largest |Zc - Zr| / |Zr|
+	MaxErrorFrequency float64 `json:"max_error_frequency"` // Frequency in Hz of the largest relative error
+	RMSMagnitudeError float64 `json:"rms_magnitude_error"` // RMS of (|Zc| - |Zr|) / |Zr|
+	MaxPhaseError     float64 `json:"max_phase_error_deg"` // Largest |arg Zc - arg Zr| in degrees
+}
+
+// Report holds the errors of all paired spectra
+type Report struct {
+	Spectra           []SpectrumError `json:"spectra"`
+	MissingCandidates []int           `json:"missing_candidates,omitempty"` // Reference spectra without a candidate
+	MissingReferences []int           `json:"missing_references,omitempty"` // Candidate spectra without a reference
+	MeanRMSRelative   float64         `json:"mean_rms_relative_error"`
+	WorstSpectrum     int             `json:"worst_spectrum"`
+	WorstRMSRelative  float64         `json:"worst_rms_relative_error"`
+}
+
+// CompareSpectrum evaluates the reference at the candidate frequencies,
+// interpolating in log frequency where the grids differ
+func CompareSpectrum(reference, candidate signal.ImpedanceData) (SpectrumError, error) {
+	if len(reference.Frequencies) < 2 || len(reference.Frequencies) != len(reference.Impedance) {
+		return SpectrumError{}, config.NewValidationError("Reference", "reference needs at least 2 points with one impedance each")
+	}
+	if len(candidate.Frequencies) != len(candidate.Impedance) {
+		return SpectrumError{}, config.NewValidationError("Candidate", "candidate frequencies and impedance differ in length")
+	}
+
+	low, high := math.Inf(1), 0.0
+	for _, f := range reference.Frequencies {
+		low, high = math.Min(low, f), math.Max(high, f)
+	}
+	var frequencies []float64
+	var values []complex128
+	for i, f := range candidate.Frequencies {
+		if f >= low*(1-1e-9) && f <= high*(1+1e-9) {
+			frequencies = append(frequencies, f)
+			values = append(values, candidate.Impedance[i])
+		}
+	}
+	if len(frequencies) == 0 {
+		return SpectrumError{}, config.NewProcessingError("spectrum comparison",
+			fmt.Errorf("no candidate frequency within the reference range %g to %g Hz", low, high))
+	}
+	expected := signal.InterpolateImpedance(reference.Frequencies, reference.Impedance, frequencies)
+
+	result := SpectrumError{Points: len(frequencies)}
+	var sumRelative, sumMagnitude float64
+	for i, z := range values {
+		magnitude := cmplx.Abs(expected[i])
+		if magnitude == 0 {
+			return SpectrumError{}, config.NewProcessingError("spectrum comparison", fmt.Errorf("reference |Z| is 0 at %g Hz", frequencies[i]))
+		}
+		relative := cmplx.Abs(z-expected[i]) / magnitude * 100
+		magnitudeError := (cmplx.Abs(z) - magnitude) / magnitude * 100
+		phaseError := math.Abs(cmplx.Phase(z/expected[i])) * 180 / math.Pi
+
+		sumRelative += relative * relative
+		sumMagnitude += magnitudeError * magnitudeError
+		if relative > result.MaxRelativeError {
+			result.MaxRelativeError, result.MaxErrorFrequency = relative, frequencies[i]
+		}
+		result.MaxPhaseError = math.Max(result.MaxPhaseError, phaseError)
+	}
+	result.RMSRelativeError = math.Sqrt(sumRelative / float64(len(values)))
+	result.RMSMagnitudeError = math.Sqrt(sumMagnitude / float64(len(values)))
+	return result, nil
+}
+
+// CompareSets pairs spectra by iteration number and compares each pair
+func CompareSets(reference, candidate []signal.ImpedanceDataWithIteration) (Report, error) {
+	references := make(map[int]signal.ImpedanceData, len(reference))
+	for _, spectrum := range reference {
+		references[spectrum.Iteration] = spectrum.ImpedanceData
+	}
+	paired := make(map[int]bool, len(candidate))
+
+	var report Report
+	for _, spectrum := range candidate {
+		ref, ok := references[spectrum.Iteration]
+		if !ok {
+			report.MissingReferences = append(report.MissingReferences, spectrum.Iteration)
+			continue
+		}
+		paired[spectrum.Iteration] = true
+
+		result, err := CompareSpectrum(ref, spectrum.ImpedanceData)
+		if err != nil {
+			return Report{}, config.NewProcessingError(fmt.Sprintf("spectrum %d", spectrum.Iteration), err)
+		}
+		result.Spectrum = spectrum.Iteration
+		report.Spectra = append(report.Spectra, result)
+	}
+	for iteration := range references {
+		if !paired[iteration] {
+			report.MissingCandidates = append(report.MissingCandidates, iteration)
+		}
+	}
+	sort.Ints(report.MissingCandidates)
+
+	if len(report.Spectra) == 0 {
+		return Report{}, config.NewValidationError("Spectra", "no spectrum numbers appear in both files")
+	}
+	sort.Slice(report.Spectra, func(i, j int) bool { return report.Spectra[i].Spectrum < report.Spectra[j].Spectrum })
+	for _, result := range report.Spectra {
+		report.MeanRMSRelative += result.RMSRelativeError / float64(len(report.Spectra))
+		if result.RMSRelativeError >= report.WorstRMSRelative {
+			report.WorstSpectrum, report.WorstRMSRelative = result.Spectrum, result.RMSRelativeError
+		}
+	}
+	return report, nil
+}
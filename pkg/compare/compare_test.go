@@ -0,0 +1,103 @@
+package compare
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// spectrum returns an R_s + R_ct||C spectrum at the given frequencies, scaled by gain
+func spectrum(iteration int, frequencies []float64, gain complex128) signal.ImpedanceDataWithIteration {
+	data := signal.ImpedanceData{Frequencies: frequencies}
+	for _, f := range frequencies {
+		zc := 1 / complex(0, 2*math.Pi*f*1e-3)
+		data.Impedance = append(data.Impedance, gain*(10+20*zc/(20+zc)))
+	}
+	return signal.ImpedanceDataWithIteration{Iteration: iteration, ImpedanceData: data}
+}
+
+func logGrid(fmin, fmax float64, points int) []float64 {
+	grid := make([]float64, points)
+	for i := range grid {
+		grid[i] = fmin * math.Pow(fmax/fmin, float64(i)/float64(points-1))
+	}
+	return grid
+}
+
+func TestCompareSpectrum(t *testing.T) {
+	reference := logGrid(0.1, 1e4, 51)
+	tests := []struct {
+		name         string
+		candidate    []float64
+		gain         complex128
+		wantPoints   int
+		wantRMS      float64
+		wantPhaseDeg float64
+		tolerance    float64
+		wantErr      bool
+	}{
+		{"identical grid", reference, 1, 51, 0, 0, 1e-9, false},
+		{"scaled by 1%", reference, 1.01, 51, 1, 0, 1e-6, false},
+		{"rotated by 1 degree", reference, complex(math.Cos(math.Pi/180), math.Sin(math.Pi/180)), 51, 100 * 2 * math.Sin(math.Pi/360), 1, 1e-6, false},
+		{"other grid within range", logGrid(1, 1e3, 17), 1, 17, 0, 0, 0.5, false},
+		{"partial overlap", logGrid(1e3, 1e6, 13), 1, 5, 0, 0, 0.5, false},
+		{"no overlap", logGrid(1e5, 1e6, 5), 1, 0, 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareSpectrum(spectrum(1, reference, 1).ImpedanceData, spectrum(1, tt.candidate, tt.gain).ImpedanceData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareSpectrum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Points != tt.wantPoints {
+				t.Errorf("Points = %d, want %d", got.Points, tt.wantPoints)
+			}
+			if math.Abs(got.RMSRelativeError-tt.wantRMS) > tt.tolerance {
+				t.Errorf("RMSRelativeError = %g, want %g", got.RMSRelativeError, tt.wantRMS)
+			}
+			if math.Abs(got.MaxPhaseError-tt.wantPhaseDeg) > math.Max(tt.tolerance, 1e-6) {
+				t.Errorf("MaxPhaseError = %g, want %g", got.MaxPhaseError, tt.wantPhaseDeg)
+			}
+		})
+	}
+}
+
+func TestCompareSets(t *testing.T) {
+	grid := logGrid(0.1, 1e4, 21)
+	reference := []signal.ImpedanceDataWithIteration{spectrum(1, grid, 1), spectrum(2, grid, 1), spectrum(3, grid, 1)}
+	candidate := []signal.ImpedanceDataWithIteration{spectrum(2, grid, 1.05), spectrum(1, grid, 1.01), spectrum(4, grid, 1)}
+
+	report, err := CompareSets(reference, candidate)
+	if err != nil {
+		t.Fatalf("CompareSets() error = %v", err)
+	}
+	if len(report.Spectra) != 2 || report.Spectra[0].Spectrum != 1 || report.Spectra[1].Spectrum != 2 {
+		t.Fatalf("Spectra = %+v, want spectra 1 and 2 in order", report.Spectra)
+	}
+	if report.WorstSpectrum != 2 || math.Abs(report.WorstRMSRelative-5) > 1e-6 || math.Abs(report.MeanRMSRelative-3) > 1e-6 {
+		t.Errorf("summary = worst %d (%g%%), mean %g%%, want worst 2 (5%%), mean 3%%",
+			report.WorstSpectrum, report.WorstRMSRelative, report.MeanRMSRelative)
+	}
+	if len(report.MissingCandidates) != 1 || report.MissingCandidates[0] != 3 {
+		t.Errorf("MissingCandidates = %v, want [3]", report.MissingCandidates)
+	}
+	if len(report.MissingReferences) != 1 || report.MissingReferences[0] != 4 {
+		t.Errorf("MissingReferences = %v, want [4]", report.MissingReferences)
+	}
+
+	if _, err := CompareSets(reference, candidate[2:]); err == nil {
+		t.Error("CompareSets() without common spectra should fail")
+	}
+
+	var svg bytes.Buffer
+	if err := report.WriteErrorSVG(&svg); err != nil || !strings.HasPrefix(svg.String(), "<svg") || !strings.HasSuffix(svg.String(), "</svg>\n") {
+		t.Errorf("WriteErrorSVG() error = %v, output %q", err, svg.String())
+	}
+}
@@ -0,0 +1,55 @@
+package compare
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// reportColumns is the header of the CSV error report
+var reportColumns = []string{
+	"spectrum", "points", "rms_relative_error_pct", "max_relative_error_pct",
+	"max_error_frequency_hz", "rms_magnitude_error_pct", "max_phase_error_deg",
+}
+
+// WriteCSV writes one row of errors per compared spectrum
+func (r Report) WriteCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return config.NewProcessingError("report writing", err)
+	}
+
+	w := csv.NewWriter(file)
+	w.Write(reportColumns)
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', 6, 64) }
+	for _, s := range r.Spectra {
+		w.Write([]string{
+			strconv.Itoa(s.Spectrum), strconv.Itoa(s.Points), format(s.RMSRelativeError), format(s.MaxRelativeError),
+			format(s.MaxErrorFrequency), format(s.RMSMagnitudeError), format(s.MaxPhaseError),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return config.NewProcessingError("report writing", err)
+	}
+	if err := file.Close(); err != nil {
+		return config.NewProcessingError("report writing", err)
+	}
+	return nil
+}
+
+// WriteJSON writes the report including its summary as indented JSON
+func (r Report) WriteJSON(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return config.NewProcessingError("report writing", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return config.NewProcessingError("report writing", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package compare
+
+import (
+	"io"
+
+	"github.com/adam/masterapp/pkg/plot"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// WriteNyquistSVG plots the reference as a line and the candidate as markers
+// in the Nyquist plane (Re Z against -Im Z)
+func WriteNyquistSVG(w io.Writer, title string, reference, candidate signal.ImpedanceData) error {
+	nyquist := func(name, color string, data signal.ImpedanceData, points bool) plot.Series {
+		series := plot.Series{Name: name, Color: color, Points: points}
+		for _, z := range data.Impedance {
+			series.X = append(series.X, real(z))
+			series.Y = append(series.Y, -imag(z))
+		}
+		return series
+	}
+	return plot.WriteSVG(w, title, "Re Z (Ω)", "-Im Z (Ω)", []plot.Series{
+		nyquist("reference", "#1f77b4", reference, false),
+		nyquist("candidate", "#d62728", candidate, true),
+	})
+}
+
+// WriteErrorSVG plots the RMS and maximum relative error of every spectrum
+func (r Report) WriteErrorSVG(w io.Writer) error {
+	rms := plot.Series{Name: "RMS relative error", Color: "#1f77b4"}
+	peak := plot.Series{Name: "max relative error", Color: "#d62728", Points: true}
+	for _, s := range r.Spectra {
+		rms.X, rms.Y = append(rms.X, float64(s.Spectrum)), append(rms.Y, s.RMSRelativeError)
+		peak.X, peak.Y = append(peak.X, float64(s.Spectrum)), append(peak.Y, s.MaxRelativeError)
+	}
+	return plot.WriteSVG(w, "Relative error per spectrum", "spectrum", "error (%)", []plot.Series{rms, peak})
+}
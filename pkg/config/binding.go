@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to upper-cased JSON option names to form environment variable names
+const EnvPrefix = "MASTERAPP_"
+
+// Load builds the configuration from defaults, environment, config file,
+// profile and command-line flags, in increasing order of precedence:
+//
+//	defaults < MASTERAPP_* environment < JSON config file < profile < flags
+//
+// The config file path itself comes from -config or MASTERAPP_CONFIG, and the
+// profile name from -profile, the file's "profile" key or MASTERAPP_PROFILE.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	flagged := NewConfig()
+	if err := flagged.BindFlags(fs); err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := NewConfig()
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	path := cfg.ConfigFile
+	if isFlagSet(fs, "config") {
+		path = flagged.ConfigFile
+	}
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = path
+	}
+
+	profile := cfg.Profile
+	if isFlagSet(fs, "profile") {
+		profile = flagged.Profile
+	}
+	if profile != "" {
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+
+	var setErr error
+	fs.Visit(func(f *flag.Flag) {
+		if setErr == nil {
+			setErr = cfg.Set(f.Name, f.Value.String())
+		}
+	})
+	if setErr != nil {
+		return nil, setErr
+	}
+
+	return cfg, nil
+}
+
+// BindFlags registers a flag for every configurable field, using the current values as defaults
+func (c *Config) BindFlags(fs *flag.FlagSet) error {
+	return c.eachOption(func(opt option) error {
+		fs.Var(fieldValue{opt.value}, opt.flag, opt.usage)
+		return nil
+	})
+}
+
+// ApplyEnv sets options from MASTERAPP_* environment variables found via lookup
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return c.eachOption(func(opt option) error {
+		value, ok := lookup(opt.env)
+		if !ok {
+			return nil
+		}
+		if err := setField(opt.value, value); err != nil {
+			return NewValidationError(opt.env, err.Error())
+		}
+		return nil
+	})
+}
+
+// LoadFile applies options from a JSON configuration file keyed by JSON option
+// names. An optional "profiles" object maps profile names to option bundles.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NewProcessingError("config file reading", err)
+	}
+
+	values, err := decodeJSONObject(data)
+	if err != nil {
+		return NewProcessingError("config file parsing", fmt.Errorf("%s: %w", path, err))
+	}
+
+	if err := c.extractProfiles(values); err != nil {
+		return err
+	}
+
+	return c.applyValues(values)
+}
+
+// Set assigns an option by flag or JSON name from its string representation
+func (c *Config) Set(name, value string) error {
+	found := false
+	err := c.eachOption(func(opt option) error {
+		if opt.flag != name && opt.json != name {
+			return nil
+		}
+		found = true
+		if err := setField(opt.value, value); err != nil {
+			return NewValidationError(opt.flag, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return NewValidationError(name, "unknown configuration option")
+	}
+	return nil
+}
+
+// Get returns an option by flag or JSON name in the form accepted by Set
+func (c *Config) Get(name string) (string, error) {
+	value, found := "", false
+	c.eachOption(func(opt option) error {
+		if opt.flag == name || opt.json == name {
+			value, found = formatField(opt.value), true
+		}
+		return nil
+	})
+	if !found {
+		return "", NewValidationError(name, "unknown configuration option")
+	}
+	return value, nil
+}
+
+// applyValues assigns decoded JSON values keyed by JSON option names
+func (c *Config) applyValues(values map[string]interface{}) error {
+	for key, raw := range values {
+		value, err := jsonValueString(raw)
+		if err != nil {
+			return NewValidationError(key, err.Error())
+		}
+		if err := c.Set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// option describes a single configurable field
+type option struct {
+	flag  string
+	json  string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// eachOption calls fn for every field carrying a flag tag
+func (c *Config) eachOption(fn func(opt option) error) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		flagName := field.Tag.Get("flag")
+		if flagName == "" {
+			continue
+		}
+
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		env := field.Tag.Get("env")
+		if env == "" {
+			env = EnvPrefix + strings.ToUpper(jsonName)
+		}
+
+		opt := option{
+			flag:  flagName,
+			json:  jsonName,
+			env:   env,
+			usage: field.Tag.Get("usage"),
+			value: v.Field(i),
+		}
+		if err := fn(opt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setField parses value according to the field's kind and assigns it
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported option type %s", field.Type())
+	}
+
+	return nil
+}
+
+// formatField renders a field value in the form accepted by setField
+func formatField(field reflect.Value) string {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(field.Int()).String()
+	}
+
+	switch field.Kind() {
+	case reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, 64)
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
+
+// fieldValue adapts a struct field to the flag.Value interface
+type fieldValue struct {
+	field reflect.Value
+}
+
+func (fv fieldValue) String() string {
+	if !fv.field.IsValid() {
+		return ""
+	}
+	return formatField(fv.field)
+}
+
+func (fv fieldValue) Set(value string) error {
+	return setField(fv.field, value)
+}
+
+// IsBoolFlag allows boolean options to be given without a value
+func (fv fieldValue) IsBoolFlag() bool {
+	return fv.field.IsValid() && fv.field.Kind() == reflect.Bool
+}
+
+// decodeJSONObject decodes a JSON object preserving number literals
+func decodeJSONObject(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// jsonValueString converts a decoded scalar JSON value to its option string form
+func jsonValueString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", raw)
+	}
+}
+
+// isFlagSet reports whether the named flag was given explicitly
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
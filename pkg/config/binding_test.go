@@ -0,0 +1,122 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "masterapp.json")
+	if err := os.WriteFile(path, []byte(`{"sample_rate": 1000000, "samples_per_second": 500, "circuit_type": "medium"}`), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	t.Setenv("MASTERAPP_CONFIG", path)
+	t.Setenv("MASTERAPP_SAMPLE_RATE", "5000")
+	t.Setenv("MASTERAPP_OUTPUT_MODE", "http")
+	t.Setenv("MASTERAPP_SPECTRA_COUNT", "7")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Load(fs, []string{"-samples=250", "-direct"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"file overrides env", cfg.SampleRate, 1000000.0},
+		{"flag overrides file", cfg.SamplesPerSecond, 250},
+		{"env applies when unset elsewhere", cfg.OutputMode, "http"},
+		{"env int option", cfg.SpectraCount, 7},
+		{"file-only option", cfg.CircuitType, "medium"},
+		{"bool flag without value", cfg.UseDirectEIS, true},
+		{"default kept", cfg.VoltageFile, "examples/data/voltage_10s.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_SetUnknownOption(t *testing.T) {
+	cfg := NewConfig()
+	if err := cfg.Set("no-such-option", "1"); err == nil {
+		t.Errorf("Set() expected error for unknown option")
+	}
+	if err := cfg.Set("rate", "not-a-number"); err == nil {
+		t.Errorf("Set() expected error for invalid float")
+	}
+}
+
+func TestConfig_GetRoundTrip(t *testing.T) {
+	source := NewConfig()
+	source.Transport = "websocket"
+	source.HTTPTimeout = 1500 * time.Millisecond
+	source.JSONPrecision = 6
+	source.Float32 = true
+
+	cfg := NewConfig()
+	for _, name := range []string{"transport", "http_timeout", "json-precision", "float32"} {
+		value, err := source.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", name, err)
+		}
+		if err := cfg.Set(name, value); err != nil {
+			t.Fatalf("Set(%q, %q) error = %v", name, value, err)
+		}
+	}
+	if cfg.Transport != source.Transport || cfg.HTTPTimeout != source.HTTPTimeout || cfg.JSONPrecision != source.JSONPrecision || !cfg.Float32 {
+		t.Errorf("options after Get and Set = %q %v %d %v", cfg.Transport, cfg.HTTPTimeout, cfg.JSONPrecision, cfg.Float32)
+	}
+	if _, err := cfg.Get("no-such-option"); err == nil {
+		t.Errorf("Get() expected error for unknown option")
+	}
+}
+
+func TestLoad_Profile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "masterapp.json")
+	content := `{
+		"output_mode": "console",
+		"profiles": {
+			"bench": {"sample_rate": 50000, "output_mode": "csv", "circuit_type": "complex"}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Load(fs, []string{"-config", path, "-profile", "bench", "-circuit", "simple"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.SampleRate != 50000 {
+		t.Errorf("expected profile sample rate 50000, got %v", cfg.SampleRate)
+	}
+	if cfg.OutputMode != "csv" {
+		t.Errorf("expected profile to override file output mode, got %s", cfg.OutputMode)
+	}
+	if cfg.CircuitType != "simple" {
+		t.Errorf("expected flag to override profile circuit, got %s", cfg.CircuitType)
+	}
+
+	if err := cfg.ApplyProfile("docker-sim"); err != nil {
+		t.Errorf("expected built-in profile to apply, got %v", err)
+	}
+	if err := cfg.ApplyProfile("missing"); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+}
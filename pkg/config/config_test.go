@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfig_ValidateSampleRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		field     string // Empty when valid
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"zero rate", func(c *Config) { c.SampleRate = 0 }, "SampleRate"},
+		{"bandwidth above Nyquist", func(c *Config) { c.AnalogBandwidth = 600 }, "AnalogBandwidth"},
+		{"excitation at Nyquist", func(c *Config) { c.ExcitationFrequency = 500 }, "ExcitationFrequency"},
+		{"lock-in frequency above Nyquist", func(c *Config) { c.ExcitationFrequencies = "10,100,750" }, "ExcitationFrequencies"},
+		{"window of one sample", func(c *Config) { c.WindowLength = time.Millisecond }, "WindowLength"},
+		{"window of two samples", func(c *Config) { c.WindowLength = 2 * time.Millisecond }, ""},
+		{"per-chunk rate sources", func(c *Config) {
+			c.UseFileData, c.UseDirectEIS, c.AnalogBandwidth = false, false, 600
+			c.SPIDevice = "/dev/spidev0.0"
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.UseFileData, cfg.SampleRate = true, 1000
+			tt.configure(cfg)
+			err := cfg.ValidateSampleRate()
+			var validation ValidationError
+			switch {
+			case tt.field == "" && err != nil:
+				t.Errorf("ValidateSampleRate() error = %v", err)
+			case tt.field != "" && (!errors.As(err, &validation) || validation.Field != tt.field):
+				t.Errorf("ValidateSampleRate() error = %v, want a %s validation error", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateSignSecretTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		wantErr   bool
+	}{
+		{"http", func(c *Config) {}, false},
+		{"websocket", func(c *Config) { c.Transport = "websocket" }, true},
+		{"unix", func(c *Config) { c.Transport, c.IPCSocket = "unix", "/tmp/eis.sock" }, true},
+		{"websocket with heartbeats", func(c *Config) { c.Transport, c.HeartbeatInterval = "websocket", time.Second }, false},
+		{"websocket with raw chunks", func(c *Config) { c.Transport, c.RawChunks = "websocket", "http" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.SignSecret = "secret"
+			tt.configure(cfg)
+			err := cfg.Validate()
+			var validation ValidationError
+			isSignSecret := errors.As(err, &validation) && validation.Field == "SignSecret"
+			if isSignSecret != tt.wantErr {
+				t.Errorf("Validate() error = %v, want a SignSecret error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateKKLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *Config)
+		wantErr   bool
+	}{
+		{"disabled", func(c *Config) {}, false},
+		{"negative", func(c *Config) { c.KKLimit = -1 }, true},
+		{"fft bins of raw signals", func(c *Config) { c.KKLimit = 1 }, true},
+		{"lock-in spectra", func(c *Config) { c.KKLimit, c.Estimator, c.ExcitationFrequencies = 1, "lockin", "1,10,100" }, false},
+		{"impedance csv", func(c *Config) { c.KKLimit, c.ImpedanceCSV = 1, "impedance.csv" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.configure(cfg)
+			err := cfg.Validate()
+			var validation ValidationError
+			isKKLimit := errors.As(err, &validation) && validation.Field == "KKLimit"
+			if isKKLimit != tt.wantErr {
+				t.Errorf("Validate() error = %v, want a KKLimit error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ var (
 	ErrServerUnavailable       = errors.New("target server is unavailable")
 	ErrJSONMarshalFailed       = errors.New("failed to marshal data to JSON")
 	ErrInvalidHTTPResponse     = errors.New("invalid HTTP response")
+	ErrInvalidSignature        = errors.New("invalid payload signature")
 )
 
 type ValidationError struct {
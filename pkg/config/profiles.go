@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinProfiles bundles settings for common scenarios. Profiles of the same
+// name defined under "profiles" in the config file take precedence.
+var builtinProfiles = map[string]map[string]interface{}{
+	"lab-200k": {
+		"sample_rate":        "200000",
+		"samples_per_second": "200",
+		"use_file_data":      "true",
+		"output_mode":        "csv",
+	},
+	"docker-sim": {
+		"target_url":      "http://goimpcore:8080",
+		"use_direct_eis":  "true",
+		"circuit_type":    "medium",
+		"spectra_count":   "100",
+		"output_mode":     "http",
+		"data_dir":        "/root/data",
+		"wait_for_target": "true",
+	},
+}
+
+// ApplyProfile applies the options bundled in the named profile
+func (c *Config) ApplyProfile(name string) error {
+	values, ok := c.profiles[name]
+	if !ok {
+		values, ok = builtinProfiles[name]
+	}
+	if !ok {
+		return NewValidationError("Profile",
+			fmt.Sprintf("unknown profile '%s' (available: %s)", name, strings.Join(c.ProfileNames(), ", ")))
+	}
+
+	if err := c.applyValues(values); err != nil {
+		return NewProcessingError(fmt.Sprintf("profile '%s'", name), err)
+	}
+	c.Profile = name
+	return nil
+}
+
+// ProfileNames returns the names of all built-in and file-defined profiles
+func (c *Config) ProfileNames() []string {
+	seen := make(map[string]bool)
+	for name := range builtinProfiles {
+		seen[name] = true
+	}
+	for name := range c.profiles {
+		seen[name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extractProfiles removes the "profiles" section from decoded config file values
+func (c *Config) extractProfiles(values map[string]interface{}) error {
+	raw, ok := values["profiles"]
+	if !ok {
+		return nil
+	}
+	delete(values, "profiles")
+
+	profiles, ok := raw.(map[string]interface{})
+	if !ok {
+		return NewValidationError("profiles", "profiles must be a JSON object")
+	}
+
+	if c.profiles == nil {
+		c.profiles = make(map[string]map[string]interface{})
+	}
+	for name, rawProfile := range profiles {
+		profile, ok := rawProfile.(map[string]interface{})
+		if !ok {
+			return NewValidationError("profiles", fmt.Sprintf("profile '%s' must be a JSON object", name))
+		}
+		c.profiles[name] = profile
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/features"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Sample is one spectrum with its annotations as read from disk
+type Sample struct {
+	ID          string
+	Frequencies []float64
+	Impedance   []complex128
+	Metadata    signal.Metadata
+}
+
+// Options configures how samples become a dataset
+type Options struct {
+	GridPoints   int     // Number of log-spaced frequencies of the common grid
+	MinFrequency float64 // Lower grid bound in Hz; 0 = highest minimum of all samples
+	MaxFrequency float64 // Upper grid bound in Hz; 0 = lowest maximum of all samples
+	LabelKey     string  // Metadata field (channel, probe, device_serial) or label key providing the class; empty = unlabeled
+}
+
+// DefaultOptions returns a 50 point grid over the range all samples cover
+func DefaultOptions() Options {
+	return Options{GridPoints: 50}
+}
+
+// Dataset is a train-ready set of spectra on a fixed frequency grid. Row i of
+// every matrix belongs to IDs[i].
+type Dataset struct {
+	IDs          []string
+	Frequencies  []float64   // Common grid in Hz, ascending
+	Real         [][]float64 // Re Z interpolated onto the grid, in Ω
+	Imag         [][]float64 // Im Z interpolated onto the grid, in Ω
+	FeatureNames []string
+	Features     [][]float64 // Z-score normalized spectrum features
+	FeatureMean  []float64   // Mean of each raw feature, to normalize new data alike
+	FeatureStd   []float64   // Standard deviation of each raw feature (1 where constant)
+	LabelKey     string
+	LabelNames   []string // Class names in index order
+	Labels       []int64  // Index into LabelNames, -1 where the metadata has no label
+}
+
+// Build interpolates the samples onto a common log-spaced grid, extracts and
+// normalizes their features and encodes their labels
+func Build(samples []Sample, options Options) (*Dataset, error) {
+	if len(samples) == 0 {
+		return nil, config.NewValidationError("Samples", "no spectra to build a dataset from")
+	}
+	if options.GridPoints < 2 {
+		return nil, config.NewValidationError("GridPoints", "the frequency grid needs at least 2 points")
+	}
+
+	grid, err := commonGrid(samples, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &Dataset{Frequencies: grid, FeatureNames: signal.FeatureNames, LabelKey: options.LabelKey}
+	extractor := features.NewExtractor()
+	classes := make(map[string]int64)
+	for _, sample := range samples {
+		re := make([]float64, len(grid))
+		im := make([]float64, len(grid))
+		for i, z := range signal.InterpolateImpedance(sample.Frequencies, sample.Impedance, grid) {
+			re[i], im[i] = real(z), imag(z)
+		}
+
+		spectrumFeatures, err := extractor.Extract(signal.ImpedanceData{Frequencies: sample.Frequencies, Impedance: sample.Impedance})
+		if err != nil {
+			return nil, config.NewProcessingError(fmt.Sprintf("features of %s", sample.ID), err)
+		}
+
+		ds.IDs = append(ds.IDs, sample.ID)
+		ds.Real = append(ds.Real, re)
+		ds.Imag = append(ds.Imag, im)
+		ds.Features = append(ds.Features, spectrumFeatures.Vector())
+
+		label := int64(-1)
+		if name, ok := labelOf(sample.Metadata, options.LabelKey); ok {
+			if _, known := classes[name]; !known {
+				classes[name] = int64(len(ds.LabelNames))
+				ds.LabelNames = append(ds.LabelNames, name)
+			}
+			label = classes[name]
+		}
+		ds.Labels = append(ds.Labels, label)
+	}
+
+	ds.FeatureMean, ds.FeatureStd = normalize(ds.Features)
+	return ds, nil
+}
+
+// commonGrid returns the log-spaced grid within the configured bounds or the
+// frequency range covered by every sample
+func commonGrid(samples []Sample, options Options) ([]float64, error) {
+	low, high := 0.0, math.Inf(1)
+	for _, sample := range samples {
+		if len(sample.Frequencies) < 2 || len(sample.Frequencies) != len(sample.Impedance) {
+			return nil, config.NewValidationError("Spectrum", fmt.Sprintf("%s needs at least 2 points with one impedance each", sample.ID))
+		}
+		sampleLow, sampleHigh := math.Inf(1), 0.0
+		for _, f := range sample.Frequencies {
+			sampleLow, sampleHigh = math.Min(sampleLow, f), math.Max(sampleHigh, f)
+		}
+		low, high = math.Max(low, sampleLow), math.Min(high, sampleHigh)
+	}
+	if options.MinFrequency > 0 {
+		low = options.MinFrequency
+	}
+	if options.MaxFrequency > 0 {
+		high = options.MaxFrequency
+	}
+	if low <= 0 || high <= low {
+		return nil, config.NewValidationError("Frequencies",
+			fmt.Sprintf("the spectra share no positive frequency range (%g to %g Hz)", low, high))
+	}
+
+	grid := make([]float64, options.GridPoints)
+	step := math.Log(high/low) / float64(options.GridPoints-1)
+	for i := range grid {
+		grid[i] = low * math.Exp(step*float64(i))
+	}
+	grid[len(grid)-1] = high
+	return grid, nil
+}
+
+// labelOf returns the class name stored under key in the metadata
+func labelOf(metadata signal.Metadata, key string) (string, bool) {
+	var value string
+	switch key {
+	case "":
+		return "", false
+	case "channel":
+		value = metadata.Channel
+	case "probe":
+		value = metadata.Probe
+	case "device_serial":
+		value = metadata.DeviceSerial
+	default:
+		value = metadata.Labels[key]
+	}
+	return value, value != ""
+}
+
+// normalize z-scores the columns of rows in place and returns their raw mean
+// and standard deviation
+func normalize(rows [][]float64) ([]float64, []float64) {
+	columns := len(rows[0])
+	mean := make([]float64, columns)
+	std := make([]float64, columns)
+	for _, row := range rows {
+		for j, v := range row {
+			mean[j] += v / float64(len(rows))
+		}
+	}
+	for _, row := range rows {
+		for j, v := range row {
+			std[j] += (v - mean[j]) * (v - mean[j]) / float64(len(rows))
+		}
+	}
+	for j := range std {
+		std[j] = math.Sqrt(std[j])
+		if std[j] == 0 {
+			std[j] = 1 // Constant feature, normalized to 0
+		}
+	}
+	for _, row := range rows {
+		for j := range row {
+			row[j] = (row[j] - mean[j]) / std[j]
+		}
+	}
+	return mean, std
+}
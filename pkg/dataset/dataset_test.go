@@ -0,0 +1,124 @@
+package dataset
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// arc returns an R_s + R_ct||C spectrum from fmax down to fmin at 10 points per decade
+func arc(id string, rs, rct float64, fmin, fmax float64, labels map[string]string) Sample {
+	sample := Sample{ID: id, Metadata: signal.Metadata{Labels: labels}}
+	for exponent := math.Log10(fmax); exponent >= math.Log10(fmin)-1e-9; exponent -= 0.1 {
+		f := math.Pow(10, exponent)
+		zc := 1 / complex(0, 2*math.Pi*f*1e-3)
+		sample.Frequencies = append(sample.Frequencies, f)
+		sample.Impedance = append(sample.Impedance, complex(rs, 0)+complex(rct, 0)*zc/(complex(rct, 0)+zc))
+	}
+	return sample
+}
+
+func TestBuild(t *testing.T) {
+	samples := []Sample{
+		arc("a", 10, 20, 0.01, 1e4, map[string]string{"state": "healthy"}),
+		arc("b", 12, 40, 0.1, 1e5, map[string]string{"state": "aged"}),
+		arc("c", 11, 30, 0.01, 1e5, nil),
+	}
+
+	ds, err := Build(samples, Options{GridPoints: 6, LabelKey: "state"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// The grid spans the range all spectra cover
+	if math.Abs(ds.Frequencies[0]-0.1) > 1e-9 || math.Abs(ds.Frequencies[5]-1e4) > 1e-6 {
+		t.Errorf("grid = %v, want 0.1 to 1e4 Hz", ds.Frequencies)
+	}
+	if math.Abs(ds.Frequencies[1]-1) > 1e-9 {
+		t.Errorf("grid is not log-spaced: %v", ds.Frequencies)
+	}
+	if got := ds.Real[0][5]; math.Abs(got-10) > 0.1 {
+		t.Errorf("Re Z of a at 10 kHz = %v, want about R_s = 10", got)
+	}
+
+	wantLabels := []int64{0, 1, -1}
+	for i, want := range wantLabels {
+		if ds.Labels[i] != want {
+			t.Errorf("label %d = %d, want %d", i, ds.Labels[i], want)
+		}
+	}
+	if strings.Join(ds.LabelNames, ",") != "healthy,aged" {
+		t.Errorf("label names = %v", ds.LabelNames)
+	}
+
+	// Normalized features have zero mean
+	for j := range ds.FeatureNames {
+		mean := 0.0
+		for _, row := range ds.Features {
+			mean += row[j]
+		}
+		if math.Abs(mean) > 1e-9 {
+			t.Errorf("feature %s has mean %v after normalization", ds.FeatureNames[j], mean)
+		}
+	}
+
+	if _, err := Build(append(samples, arc("d", 10, 20, 1e5, 1e6, nil)), DefaultOptions()); err == nil {
+		t.Errorf("expected error for spectra without a common frequency range")
+	}
+}
+
+func TestDataset_WriteNPZ(t *testing.T) {
+	ds, err := Build([]Sample{arc("a", 10, 20, 0.1, 1e3, nil), arc("b", 10, 30, 0.1, 1e3, nil)}, Options{GridPoints: 3})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "set.npz")
+	if err := ds.WriteNPZ(path); err != nil {
+		t.Fatalf("WriteNPZ() error = %v", err)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer archive.Close()
+
+	arrays := make(map[string][]byte)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		arrays[file.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+
+	npy := arrays["real.npy"]
+	if len(npy) < 10 || string(npy[:6]) != "\x93NUMPY" {
+		t.Fatalf("real.npy has no NumPy magic")
+	}
+	headerLength := int(binary.LittleEndian.Uint16(npy[8:10]))
+	if (10+headerLength)%64 != 0 {
+		t.Errorf("data offset %d is not 64-byte aligned", 10+headerLength)
+	}
+	if header := string(npy[10 : 10+headerLength]); !strings.Contains(header, "'shape': (2, 3)") || !strings.Contains(header, "'<f8'") {
+		t.Errorf("unexpected header %q", header)
+	}
+	data := npy[10+headerLength:]
+	if len(data) != 2*3*8 {
+		t.Fatalf("real.npy holds %d data bytes, want %d", len(data), 2*3*8)
+	}
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(data[3*8:])); got != ds.Real[1][0] {
+		t.Errorf("real[1][0] = %v, want %v", got, ds.Real[1][0])
+	}
+
+	if header := string(arrays["labels.npy"]); !strings.Contains(header, "'shape': (2,)") || !strings.Contains(header, "'<i8'") {
+		t.Errorf("unexpected labels header")
+	}
+}
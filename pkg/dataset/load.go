@@ -0,0 +1,120 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// measurementFile is the JSON measurement written by the console output mode
+type measurementFile struct {
+	ID       string                  `json:"id"`
+	Metadata signal.Metadata         `json:"metadata"`
+	Points   []signal.ImpedancePoint `json:"points"`
+}
+
+// LoadSamples reads spectra from files and directories. Directories are
+// searched recursively for .json measurement files (console output or
+// impedance data) and .csv impedance files, read with the given loader.
+func LoadSamples(paths []string, loader signal.DataLoader) ([]Sample, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, config.NewProcessingError("dataset input", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		var found []string
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := strings.ToLower(filepath.Ext(file)); !entry.IsDir() && (ext == ".json" || ext == ".csv") {
+				found = append(found, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, config.NewProcessingError("dataset input", err)
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+
+	var samples []Sample
+	for _, file := range files {
+		var loaded []Sample
+		var err error
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			loaded, err = loadJSONSample(file)
+		} else {
+			loaded, err = loadCSVSamples(file, loader)
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, loaded...)
+	}
+	return samples, nil
+}
+
+// loadJSONSample reads a console output measurement or serialized impedance data
+func loadJSONSample(file string) ([]Sample, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, config.NewProcessingError("dataset input", err)
+	}
+
+	var measurement measurementFile
+	if err := json.Unmarshal(content, &measurement); err != nil {
+		return nil, config.NewProcessingError("dataset input", fmt.Errorf("%s: %w", file, err))
+	}
+	sample := Sample{ID: measurement.ID, Metadata: measurement.Metadata}
+	for _, point := range measurement.Points {
+		sample.Frequencies = append(sample.Frequencies, point.Frequency)
+		sample.Impedance = append(sample.Impedance, complex(point.Real, point.Imag))
+	}
+
+	if len(measurement.Points) == 0 {
+		var data signal.ImpedanceData
+		if err := json.Unmarshal(content, &data); err != nil {
+			return nil, config.NewProcessingError("dataset input", fmt.Errorf("%s: %w", file, err))
+		}
+		sample.Frequencies, sample.Impedance = data.Frequencies, data.Impedance
+	}
+
+	if sample.ID == "" {
+		sample.ID = file
+	}
+	return []Sample{sample}, nil
+}
+
+// loadCSVSamples reads every spectrum of an impedance CSV file
+func loadCSVSamples(file string, loader signal.DataLoader) ([]Sample, error) {
+	spectra, err := loader.LoadImpedanceFromCSV(file)
+	if err != nil {
+		return nil, config.NewProcessingError("dataset input", fmt.Errorf("%s: %w", file, err))
+	}
+
+	samples := make([]Sample, 0, len(spectra))
+	for _, spectrum := range spectra {
+		samples = append(samples, Sample{
+			ID:          fmt.Sprintf("%s#%d", file, spectrum.Iteration),
+			Frequencies: spectrum.ImpedanceData.Frequencies,
+			Impedance:   spectrum.ImpedanceData.Impedance,
+			Metadata:    spectrum.ImpedanceData.Metadata,
+		})
+	}
+	return samples, nil
+}
@@ -0,0 +1,138 @@
+package dataset
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Description is the JSON sidecar of an NPZ dataset holding everything that
+// is not a numeric array
+type Description struct {
+	Samples      int       `json:"samples"`
+	IDs          []string  `json:"ids"`
+	Arrays       []string  `json:"arrays"`
+	FeatureNames []string  `json:"feature_names"`
+	FeatureMean  []float64 `json:"feature_mean"`
+	FeatureStd   []float64 `json:"feature_std"`
+	LabelKey     string    `json:"label_key,omitempty"`
+	LabelNames   []string  `json:"label_names,omitempty"`
+}
+
+// npyArray is one array of an NPZ archive
+type npyArray struct {
+	name  string
+	descr string // NumPy type, '<f8' or '<i8'
+	shape []int
+	data  interface{} // []float64 or []int64 in row-major order
+}
+
+// WriteNPZ writes the dataset as a NumPy .npz archive (numpy.load) with the
+// arrays frequencies (G), real and imag (N×G), features (N×F), feature_mean
+// and feature_std (F) and labels (N), plus a JSON description at
+// DescriptionPath(path). The arrays map one-to-one to Parquet columns.
+func (ds *Dataset) WriteNPZ(path string) error {
+	arrays := []npyArray{
+		{"frequencies", "<f8", []int{len(ds.Frequencies)}, ds.Frequencies},
+		{"real", "<f8", []int{len(ds.Real), len(ds.Frequencies)}, flatten(ds.Real)},
+		{"imag", "<f8", []int{len(ds.Imag), len(ds.Frequencies)}, flatten(ds.Imag)},
+		{"features", "<f8", []int{len(ds.Features), len(ds.FeatureNames)}, flatten(ds.Features)},
+		{"feature_mean", "<f8", []int{len(ds.FeatureMean)}, ds.FeatureMean},
+		{"feature_std", "<f8", []int{len(ds.FeatureStd)}, ds.FeatureStd},
+		{"labels", "<i8", []int{len(ds.Labels)}, ds.Labels},
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return config.NewProcessingError("dataset writing", err)
+	}
+	archive := zip.NewWriter(file)
+	description := Description{
+		Samples:      len(ds.IDs),
+		IDs:          ds.IDs,
+		FeatureNames: ds.FeatureNames,
+		FeatureMean:  ds.FeatureMean,
+		FeatureStd:   ds.FeatureStd,
+		LabelKey:     ds.LabelKey,
+		LabelNames:   ds.LabelNames,
+	}
+	for _, array := range arrays {
+		entry, err := archive.Create(array.name + ".npy")
+		if err == nil {
+			err = writeNPY(entry, array)
+		}
+		if err != nil {
+			file.Close()
+			return config.NewProcessingError("dataset writing", fmt.Errorf("%s: %w", array.name, err))
+		}
+		description.Arrays = append(description.Arrays, array.name)
+	}
+	if err := archive.Close(); err != nil {
+		file.Close()
+		return config.NewProcessingError("dataset writing", err)
+	}
+	if err := file.Close(); err != nil {
+		return config.NewProcessingError("dataset writing", err)
+	}
+
+	content, err := json.MarshalIndent(description, "", "  ")
+	if err != nil {
+		return config.NewProcessingError("dataset writing", err)
+	}
+	if err := os.WriteFile(DescriptionPath(path), content, 0644); err != nil {
+		return config.NewProcessingError("dataset writing", err)
+	}
+	return nil
+}
+
+// DescriptionPath returns the JSON sidecar path of an NPZ dataset
+func DescriptionPath(npzPath string) string {
+	return strings.TrimSuffix(npzPath, ".npz") + ".json"
+}
+
+// writeNPY writes an array in NumPy format version 1.0
+func writeNPY(w io.Writer, array npyArray) error {
+	shape := make([]string, len(array.shape))
+	for i, n := range array.shape {
+		shape[i] = fmt.Sprint(n)
+	}
+	tuple := strings.Join(shape, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", array.descr, tuple)
+
+	// Magic, version and header length precede the header, padded so the data is 64-byte aligned
+	const preamble = 10
+	padding := 64 - (preamble+len(header)+1)%64
+	header += strings.Repeat(" ", padding%64) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	if err := binary.Write(&buf, binary.LittleEndian, array.data); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// flatten concatenates rows in row-major order
+func flatten(rows [][]float64) []float64 {
+	var flat []float64
+	for _, row := range rows {
+		flat = append(flat, row...)
+	}
+	if flat == nil {
+		flat = []float64{}
+	}
+	return flat
+}
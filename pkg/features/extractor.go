@@ -0,0 +1,119 @@
+package features
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// minTailPoints is the number of points below the arc needed to fit a Warburg slope
+const minTailPoints = 3
+
+// DefaultExtractor reads features off the Nyquist plot of a spectrum with a
+// single (possibly depressed) semicircle and an optional diffusion tail
+type DefaultExtractor struct{}
+
+// NewExtractor creates a new spectrum feature extractor
+func NewExtractor() Extractor {
+	return &DefaultExtractor{}
+}
+
+// nyquistPoint is one point of the Nyquist plot, x = Re Z and y = -Im Z
+type nyquistPoint struct {
+	frequency, x, y float64
+}
+
+// Extract computes the features of one spectrum.
+//
+// Walking from high to low frequency, the HF intercept is where -Im Z turns
+// positive (interpolated; the highest-frequency Re Z if it never is
+// inductive), the apex is the first local maximum of -Im Z and the LF
+// intercept is the following local minimum. Without such a minimum the arc is
+// incomplete and the LF intercept is extrapolated from the apex assuming a
+// symmetric arc. Points below the minimum form the diffusion tail.
+func (e *DefaultExtractor) Extract(data signal.ImpedanceData) (signal.SpectrumFeatures, error) {
+	if len(data.Impedance) != len(data.Frequencies) {
+		return signal.SpectrumFeatures{}, config.NewValidationError("Length", "impedance and frequencies must have the same length")
+	}
+	if len(data.Impedance) < 3 {
+		return signal.SpectrumFeatures{}, config.NewProcessingError("feature extraction",
+			fmt.Errorf("at least 3 points are required, got %d", len(data.Impedance)))
+	}
+
+	points := make([]nyquistPoint, len(data.Impedance))
+	for i, z := range data.Impedance {
+		points[i] = nyquistPoint{frequency: data.Frequencies[i], x: real(z), y: -imag(z)}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].frequency > points[j].frequency })
+
+	// High-frequency intercept
+	start := 0
+	hf := points[0].x
+	for start < len(points) && points[start].y <= 0 {
+		start++
+	}
+	if start == len(points) {
+		return signal.SpectrumFeatures{}, config.NewProcessingError("feature extraction", fmt.Errorf("spectrum has no capacitive arc"))
+	}
+	if start > 0 {
+		hf = interpolateX(points[start-1], points[start])
+	}
+
+	// Arc apex: first local maximum of -Im Z
+	apex := start
+	for apex+1 < len(points) && points[apex+1].y >= points[apex].y {
+		apex++
+	}
+
+	// Arc end: following local minimum of -Im Z
+	end := apex
+	for end+1 < len(points) && points[end+1].y <= points[end].y {
+		end++
+	}
+
+	features := signal.SpectrumFeatures{
+		HighFrequencyIntercept:  hf,
+		CharacteristicFrequency: points[apex].frequency,
+	}
+	if end == len(points)-1 && apex < end {
+		features.LowFrequencyIntercept = 2*points[apex].x - hf // Arc not closed within the spectrum
+	} else {
+		features.LowFrequencyIntercept = points[end].x
+	}
+	features.SemicircleDiameter = features.LowFrequencyIntercept - hf
+
+	if tail := points[end:]; len(tail) >= minTailPoints && end > apex {
+		if slope, ok := fitSlope(tail); ok {
+			features.WarburgSlope = &slope
+		}
+	}
+	return features, nil
+}
+
+// interpolateX returns Re Z where the segment from a to b crosses -Im Z = 0
+func interpolateX(a, b nyquistPoint) float64 {
+	if b.y == a.y {
+		return b.x
+	}
+	return a.x + (b.x-a.x)*(0-a.y)/(b.y-a.y)
+}
+
+// fitSlope fits y = slope·x + c by least squares
+func fitSlope(points []nyquistPoint) (float64, bool) {
+	n := float64(len(points))
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		sx += p.x
+		sy += p.y
+		sxx += p.x * p.x
+		sxy += p.x * p.y
+	}
+	denominator := n*sxx - sx*sx
+	if math.Abs(denominator) < 1e-12*math.Max(1, sxx) {
+		return 0, false
+	}
+	return (n*sxy - sx*sy) / denominator, true
+}
@@ -0,0 +1,81 @@
+package features
+
+import (
+	"math"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// randles returns the spectrum of R_s + C || (R_ct + W) at 10 points per
+// decade from 100 kHz down to 1 mHz, with Warburg coefficient sigma (0 = none)
+func randles(rs, rct, c, sigma float64) signal.ImpedanceData {
+	var data signal.ImpedanceData
+	for exponent := 5.0; exponent >= -3; exponent -= 0.1 {
+		f := math.Pow(10, exponent)
+		w := 2 * math.Pi * f
+		faradaic := complex(rct, 0) + complex(sigma/math.Sqrt(w), -sigma/math.Sqrt(w))
+		capacitor := 1 / complex(0, w*c)
+		data.Frequencies = append(data.Frequencies, f)
+		data.Impedance = append(data.Impedance, complex(rs, 0)+faradaic*capacitor/(faradaic+capacitor))
+	}
+	return data
+}
+
+func TestDefaultExtractor_Extract(t *testing.T) {
+	inductive := randles(10, 20, 1e-3, 0)
+	for i, f := range inductive.Frequencies {
+		inductive.Impedance[i] += complex(0, 2*math.Pi*f*1e-6) // Cable inductance
+	}
+
+	tests := []struct {
+		name      string
+		data      signal.ImpedanceData
+		wantHF    float64
+		wantLF    float64
+		wantSlope float64 // 0 = no Warburg tail
+		tolerance float64
+	}{
+		{"semicircle", randles(10, 20, 1e-3, 0), 10, 30, 0, 0.1},
+		{"semicircle with inductance", inductive, 10, 30, 0, 0.1},
+		{"semicircle with diffusion tail", randles(10, 20, 1e-3, 2), 10, 30, 1, 2},
+	}
+
+	extractor := NewExtractor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features, err := extractor.Extract(tt.data)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if math.Abs(features.HighFrequencyIntercept-tt.wantHF) > tt.tolerance {
+				t.Errorf("HF intercept = %v, want %v", features.HighFrequencyIntercept, tt.wantHF)
+			}
+			if math.Abs(features.LowFrequencyIntercept-tt.wantLF) > tt.tolerance {
+				t.Errorf("LF intercept = %v, want %v", features.LowFrequencyIntercept, tt.wantLF)
+			}
+			if diameter := features.LowFrequencyIntercept - features.HighFrequencyIntercept; features.SemicircleDiameter != diameter {
+				t.Errorf("diameter = %v, want %v", features.SemicircleDiameter, diameter)
+			}
+
+			// Apex at 1/(2π R_ct C) within one grid step
+			fc := 1 / (2 * math.Pi * 20 * 1e-3)
+			if ratio := features.CharacteristicFrequency / fc; ratio < math.Pow(10, -0.1) || ratio > math.Pow(10, 0.1) {
+				t.Errorf("characteristic frequency = %v, want about %v", features.CharacteristicFrequency, fc)
+			}
+
+			switch {
+			case tt.wantSlope == 0 && features.WarburgSlope != nil:
+				t.Errorf("unexpected Warburg slope %v", *features.WarburgSlope)
+			case tt.wantSlope != 0 && features.WarburgSlope == nil:
+				t.Errorf("missing Warburg slope")
+			case tt.wantSlope != 0 && math.Abs(*features.WarburgSlope-tt.wantSlope) > 0.2:
+				t.Errorf("Warburg slope = %v, want about %v", *features.WarburgSlope, tt.wantSlope)
+			}
+		})
+	}
+
+	if _, err := extractor.Extract(signal.ImpedanceData{Frequencies: []float64{1, 2, 3}, Impedance: []complex128{1, 1}}); err == nil {
+		t.Errorf("expected error for mismatched lengths")
+	}
+}
@@ -0,0 +1,10 @@
+package features
+
+import (
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Extractor derives scalar features from impedance spectra
+type Extractor interface {
+	Extract(data signal.ImpedanceData) (signal.SpectrumFeatures, error)
+}
@@ -0,0 +1,91 @@
+package fft
+
+import (
+	"fmt"
+	"math/cmplx"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// EstimateDelay returns how far delayed lags reference, from the peak of
+// their cross-correlation refined to a fraction of a sample by a parabola
+// through the peak and its neighbours. A negative delay means delayed leads.
+// Any phase between the signals themselves, such as that of a reactive cell
+// between voltage and current, is included, so the result is the logger
+// offset only for in-phase signals: a resistive calibration load or the
+// excitation recorded by both loggers.
+func EstimateDelay(reference, delayed signal.Signal) (time.Duration, error) {
+	if err := signal.ValidateSignalsMatch(reference, delayed); err != nil {
+		return 0, config.NewProcessingError("delay estimation", err)
+	}
+	n := len(reference.Values)
+	if n < 3 {
+		return 0, config.NewProcessingError("delay estimation", config.ErrInvalidSignalLength)
+	}
+
+	// Zero padding to a power of two of at least 2n makes the circular
+	// correlation of the transforms a linear one
+	size := 1
+	for size < 2*n {
+		size <<= 1
+	}
+	processor := &DefaultProcessor{validator: signal.NewValidator()}
+	referenceFFT, err := processor.computeFFT(centeredPadded(reference.Values, size))
+	if err != nil {
+		return 0, config.NewProcessingError("delay estimation", err)
+	}
+	delayedFFT, err := processor.computeFFT(centeredPadded(delayed.Values, size))
+	if err != nil {
+		return 0, config.NewProcessingError("delay estimation", err)
+	}
+	for i := range referenceFFT {
+		referenceFFT[i] = cmplx.Conj(referenceFFT[i]) * delayedFFT[i]
+	}
+	correlation, err := processor.computeInverseFFT(referenceFFT)
+	if err != nil {
+		return 0, config.NewProcessingError("delay estimation", err)
+	}
+
+	// Lags -(n-1)..n-1 sit at the ends of the correlation
+	at := func(lag int) float64 {
+		if lag < 0 {
+			lag += size
+		}
+		return real(correlation[lag])
+	}
+	best := 0
+	for lag := -(n - 1); lag < n; lag++ {
+		if at(lag) > at(best) {
+			best = lag
+		}
+	}
+	if at(best) <= 0 {
+		return 0, config.NewProcessingError("delay estimation", fmt.Errorf("signals are not correlated"))
+	}
+
+	offset := 0.0
+	if best > -(n-1) && best < n-1 {
+		left, center, right := at(best-1), at(best), at(best+1)
+		if curvature := left - 2*center + right; curvature < 0 {
+			offset = 0.5 * (left - right) / curvature
+		}
+	}
+	seconds := (float64(best) + offset) / reference.SampleRate
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// centeredPadded returns values without their mean, zero padded to size
+func centeredPadded(values []float64, size int) []complex128 {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	padded := make([]complex128, size)
+	for i, v := range values {
+		padded[i] = complex(v-mean, 0)
+	}
+	return padded
+}
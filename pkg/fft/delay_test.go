@@ -0,0 +1,49 @@
+package fft
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// multisine samples tones of 1 V at frequencies, shifted later by delay
+func multisine(sampleRate float64, n int, frequencies []float64, delay time.Duration) signal.Signal {
+	values := make([]float64, n)
+	for i := range values {
+		tm := float64(i)/sampleRate - delay.Seconds()
+		for _, f := range frequencies {
+			values[i] += math.Sin(2 * math.Pi * f * tm)
+		}
+	}
+	return signal.Signal{Timestamp: time.Unix(0, 0), Values: values, SampleRate: sampleRate}
+}
+
+func TestEstimateDelay(t *testing.T) {
+	const sampleRate = 10000.0
+	frequencies := []float64{1, 5, 10, 25, 50, 100, 250, 500}
+	reference := multisine(sampleRate, 10000, frequencies, 0)
+
+	// Whole, fractional and negative sample offsets
+	for _, delay := range []time.Duration{0, time.Millisecond, 37 * time.Microsecond, -1234 * time.Microsecond} {
+		got, err := EstimateDelay(reference, multisine(sampleRate, 10000, frequencies, delay))
+		if err != nil {
+			t.Fatalf("EstimateDelay(%v) error = %v", delay, err)
+		}
+		if diff := got - delay; diff < -5*time.Microsecond || diff > 5*time.Microsecond {
+			t.Errorf("EstimateDelay() = %v, want %v within 5µs (1/20 sample)", got, delay)
+		}
+	}
+}
+
+func TestEstimateDelay_Invalid(t *testing.T) {
+	short := signal.Signal{Values: []float64{1, 2}, SampleRate: 100}
+	if _, err := EstimateDelay(short, short); err == nil {
+		t.Error("EstimateDelay() of 2 samples succeeded, want an error")
+	}
+	constant := signal.Signal{Values: []float64{1, 1, 1, 1}, SampleRate: 100}
+	if _, err := EstimateDelay(constant, constant); err == nil {
+		t.Error("EstimateDelay() of constant signals succeeded, want an error")
+	}
+}
@@ -0,0 +1,130 @@
+package fft
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// LowestExcitationFrequency returns the lowest non-DC frequency whose spectral
+// magnitude is at least threshold times the strongest component. The result is
+// quantized to the signal's frequency resolution (sample rate / length).
+func LowestExcitationFrequency(sig signal.Signal, threshold float64) (float64, error) {
+	frequencies, err := ExcitationFrequencies(sig, threshold)
+	if err != nil {
+		return 0, err
+	}
+	return frequencies[0], nil
+}
+
+// ExcitationFrequencies returns, in ascending order, the non-DC spectral peaks
+// whose magnitude is at least threshold times the strongest component
+func ExcitationFrequencies(sig signal.Signal, threshold float64) ([]float64, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, config.NewValidationError("Threshold", "threshold must be in (0, 1]")
+	}
+	if len(sig.Values) < 2 {
+		return nil, config.NewProcessingError("excitation detection", config.ErrInvalidSignalLength)
+	}
+
+	// Remove the DC offset so it cannot mask weak excitation components
+	mean := 0.0
+	for _, v := range sig.Values {
+		mean += v
+	}
+	mean /= float64(len(sig.Values))
+
+	centered := sig
+	centered.Values = make([]float64, len(sig.Values))
+	for i, v := range sig.Values {
+		centered.Values[i] = v - mean
+	}
+
+	processor := NewProcessor()
+	spectrum, err := processor.ProcessSignal(centered)
+	if err != nil {
+		return nil, err
+	}
+	positive, err := processor.GetPositiveFrequencies(spectrum)
+	if err != nil {
+		return nil, err
+	}
+
+	strongest := 0.0
+	for _, v := range positive.Values[1:] {
+		if magnitude := cmplx.Abs(v); magnitude > strongest {
+			strongest = magnitude
+		}
+	}
+	if strongest == 0 {
+		return nil, config.NewProcessingError("excitation detection", fmt.Errorf("signal has no AC components"))
+	}
+
+	// Keep local maxima above the threshold so leakage next to a peak is not reported
+	var frequencies []float64
+	for i := 1; i < len(positive.Values); i++ {
+		magnitude := cmplx.Abs(positive.Values[i])
+		if magnitude < threshold*strongest {
+			continue
+		}
+		if i > 1 && magnitude < cmplx.Abs(positive.Values[i-1]) {
+			continue
+		}
+		if i+1 < len(positive.Values) && magnitude < cmplx.Abs(positive.Values[i+1]) {
+			continue
+		}
+		frequencies = append(frequencies, positive.Frequencies[i])
+	}
+	return frequencies, nil
+}
+
+// Tones decomposes one period of a waveform into the sine components whose
+// amplitude is at least threshold times the strongest one, plus its mean.
+// Played back periodically the tones reproduce the waveform up to the
+// discarded components.
+func Tones(waveform signal.Signal, threshold float64) ([]signal.Tone, float64, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, 0, config.NewValidationError("Threshold", "threshold must be in (0, 1]")
+	}
+
+	if waveform.Timestamp.IsZero() {
+		waveform.Timestamp = time.Unix(0, 0) // Waveforms loaded from files carry no time
+	}
+
+	processor := NewProcessorWithMode(SpectrumSingleSided)
+	spectrum, err := processor.ProcessSignal(waveform)
+	if err != nil {
+		return nil, 0, err
+	}
+	positive, err := processor.GetPositiveFrequencies(spectrum)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	strongest := 0.0
+	for _, v := range positive.Values[1:] {
+		strongest = math.Max(strongest, cmplx.Abs(v))
+	}
+	if strongest == 0 {
+		return nil, 0, config.NewProcessingError("waveform decomposition", fmt.Errorf("waveform has no AC components"))
+	}
+
+	var tones []signal.Tone
+	for i := 1; i < len(positive.Values); i++ {
+		amplitude := cmplx.Abs(positive.Values[i])
+		if amplitude < threshold*strongest {
+			continue
+		}
+		// A cosine bin X = A·e^{jφ} is the sine A·sin(ωt + φ + π/2)
+		tones = append(tones, signal.Tone{
+			Frequency: positive.Frequencies[i],
+			Amplitude: amplitude,
+			Phase:     cmplx.Phase(positive.Values[i]) + math.Pi/2,
+		})
+	}
+	return tones, real(positive.Values[0]), nil
+}
@@ -0,0 +1,65 @@
+package fft
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestLowestExcitationFrequency(t *testing.T) {
+	const sampleRate = 256.0
+	values := make([]float64, 256)
+	for i := range values {
+		tm := float64(i) / sampleRate
+		values[i] = 1.0 + 0.001*math.Sin(2*math.Pi*2*tm) + 0.2*math.Sin(2*math.Pi*4*tm) + 0.1*math.Sin(2*math.Pi*16*tm)
+	}
+	sig := signal.Signal{Timestamp: time.Now(), Values: values, SampleRate: sampleRate}
+
+	got, err := LowestExcitationFrequency(sig, 0.1)
+	if err != nil {
+		t.Fatalf("LowestExcitationFrequency() error = %v", err)
+	}
+	if got != 4 {
+		t.Errorf("expected 4 Hz (2 Hz component is below threshold), got %v", got)
+	}
+
+	flat := signal.Signal{Timestamp: time.Now(), Values: []float64{1, 1, 1, 1}, SampleRate: 4}
+	if _, err := LowestExcitationFrequency(flat, 0.1); err == nil {
+		t.Errorf("expected error for signal without AC components")
+	}
+}
+
+func TestTones(t *testing.T) {
+	const sampleRate = 64.0
+	values := make([]float64, 64)
+	for i := range values {
+		tm := float64(i) / sampleRate
+		values[i] = 0.5 + 0.2*math.Sin(2*math.Pi*3*tm+0.4) + 0.05*math.Cos(2*math.Pi*10*tm)
+	}
+	waveform := signal.Signal{Values: values, SampleRate: sampleRate}
+
+	tones, mean, err := Tones(waveform, 0.01)
+	if err != nil {
+		t.Fatalf("Tones() error = %v", err)
+	}
+	if math.Abs(mean-0.5) > 1e-9 {
+		t.Errorf("mean = %v, want 0.5", mean)
+	}
+	if len(tones) != 2 {
+		t.Fatalf("got %d tones, want 2: %+v", len(tones), tones)
+	}
+
+	// Played back, the tones reproduce the waveform
+	for i, want := range values {
+		tm := float64(i) / sampleRate
+		got := mean
+		for _, tone := range tones {
+			got += tone.Amplitude * math.Sin(2*math.Pi*tone.Frequency*tm+tone.Phase)
+		}
+		if math.Abs(got-want) > 1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, got, want)
+		}
+	}
+}
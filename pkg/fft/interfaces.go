@@ -8,5 +8,6 @@ import (
 type Processor interface {
 	ProcessSignal(sig signal.Signal) (signal.ComplexSignal, error)
 	GetPositiveFrequencies(complexSignal signal.ComplexSignal) (signal.ComplexSignal, error)
+	InverseTransform(spectrum signal.ComplexSignal) (signal.Signal, error)
 	ValidateSignal(sig signal.Signal) error
 }
\ No newline at end of file
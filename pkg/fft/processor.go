@@ -9,15 +9,34 @@ import (
 	"github.com/adam/masterapp/pkg/signal"
 )
 
+// SpectrumMode selects how GetPositiveFrequencies converts a two-sided spectrum
+type SpectrumMode int
+
+const (
+	// SpectrumRaw returns the first N/2 bins unscaled. Ratios such as U(f)/I(f)
+	// are unaffected by scaling, so this is what impedance calculation uses.
+	SpectrumRaw SpectrumMode = iota
+	// SpectrumSingleSided returns bins 0..N/2 scaled to signal amplitudes: DC
+	// and Nyquist by 1/N, interior bins by 2/N to fold in the negative half
+	SpectrumSingleSided
+)
+
 // DefaultProcessor implements FFT processing with validation
 type DefaultProcessor struct {
 	validator signal.Validator
+	mode      SpectrumMode
 }
 
-// NewProcessor creates a new FFT processor
+// NewProcessor creates a new FFT processor returning raw positive-frequency bins
 func NewProcessor() Processor {
+	return NewProcessorWithMode(SpectrumRaw)
+}
+
+// NewProcessorWithMode creates a new FFT processor using the given positive-frequency conversion
+func NewProcessorWithMode(mode SpectrumMode) Processor {
 	return &DefaultProcessor{
 		validator: signal.NewValidator(),
+		mode:      mode,
 	}
 }
 
@@ -66,7 +85,60 @@ func (fft *DefaultProcessor) ProcessSignal(sig signal.Signal) (signal.ComplexSig
 	return result, nil
 }
 
-// GetPositiveFrequencies extracts only the positive frequency components
+// InverseTransform reconstructs the time-domain signal from a full two-sided
+// spectrum as returned by ProcessSignal. The sample rate follows from the bin
+// spacing; imaginary parts left by non-Hermitian spectra are dropped.
+func (fft *DefaultProcessor) InverseTransform(spectrum signal.ComplexSignal) (signal.Signal, error) {
+	if err := fft.validator.ValidateComplexSignal(spectrum); err != nil {
+		return signal.Signal{}, config.NewProcessingError("input validation", err)
+	}
+
+	n := len(spectrum.Values)
+	if n < 2 {
+		return signal.Signal{}, config.NewProcessingError("inverse FFT", fmt.Errorf("need at least 2 bins to derive the sample rate, got %d", n))
+	}
+	sampleRate := math.Abs(spectrum.Frequencies[1]) * float64(n) // Bin 1 is -fs/2 when n = 2
+	if sampleRate <= 0 {
+		return signal.Signal{}, config.NewProcessingError("inverse FFT", config.ErrInvalidSampleRate)
+	}
+
+	values, err := fft.computeInverseFFT(spectrum.Values)
+	if err != nil {
+		return signal.Signal{}, config.NewProcessingError("inverse FFT computation", err)
+	}
+
+	result := signal.Signal{
+		Timestamp:  spectrum.Timestamp,
+		Values:     make([]float64, n),
+		SampleRate: sampleRate,
+	}
+	for i, v := range values {
+		result.Values[i] = real(v)
+	}
+	return result, nil
+}
+
+// computeInverseFFT computes the inverse transform through the forward one:
+// IFFT(X) = conj(FFT(conj(X))) / N
+func (fft *DefaultProcessor) computeInverseFFT(x []complex128) ([]complex128, error) {
+	conjugated := make([]complex128, len(x))
+	for i, v := range x {
+		conjugated[i] = cmplx.Conj(v)
+	}
+
+	transformed, err := fft.computeFFT(conjugated)
+	if err != nil {
+		return nil, err
+	}
+	scale := complex(1/float64(len(x)), 0)
+	for i, v := range transformed {
+		transformed[i] = cmplx.Conj(v) * scale
+	}
+	return transformed, nil
+}
+
+// GetPositiveFrequencies extracts only the positive frequency components,
+// converted according to the processor's SpectrumMode
 func (fft *DefaultProcessor) GetPositiveFrequencies(complexSignal signal.ComplexSignal) (signal.ComplexSignal, error) {
 	if err := fft.validator.ValidateComplexSignal(complexSignal); err != nil {
 		return signal.ComplexSignal{}, config.NewProcessingError("input validation", err)
@@ -77,6 +149,10 @@ func (fft *DefaultProcessor) GetPositiveFrequencies(complexSignal signal.Complex
 		return signal.ComplexSignal{}, config.ErrInvalidSignalLength
 	}
 	
+	if fft.mode == SpectrumSingleSided {
+		return fft.singleSided(complexSignal)
+	}
+
 	halfN := n / 2
 	if halfN == 0 {
 		halfN = 1
@@ -95,6 +171,36 @@ func (fft *DefaultProcessor) GetPositiveFrequencies(complexSignal signal.Complex
 	return result, nil
 }
 
+// singleSided folds a two-sided spectrum into amplitude-scaled bins 0..N/2.
+// For even N the Nyquist bin is kept once and reported at +fs/2.
+func (fft *DefaultProcessor) singleSided(complexSignal signal.ComplexSignal) (signal.ComplexSignal, error) {
+	n := len(complexSignal.Values)
+	bins := n/2 + 1
+
+	values := make([]complex128, bins)
+	frequencies := make([]float64, bins)
+	for k := 0; k < bins; k++ {
+		scale := 2 / float64(n)
+		if k == 0 || (n%2 == 0 && k == n/2) {
+			scale = 1 / float64(n)
+		}
+		values[k] = complexSignal.Values[k] * complex(scale, 0)
+		frequencies[k] = math.Abs(complexSignal.Frequencies[k])
+	}
+
+	result := signal.ComplexSignal{
+		Timestamp:   complexSignal.Timestamp,
+		Values:      values,
+		Frequencies: frequencies,
+	}
+
+	if err := fft.validator.ValidatePositiveFrequencySignal(result); err != nil {
+		return signal.ComplexSignal{}, config.NewProcessingError("result validation", err)
+	}
+
+	return result, nil
+}
+
 // computeFFT performs the actual FFT computation using radix-2 algorithm
 func (fft *DefaultProcessor) computeFFT(x []complex128) ([]complex128, error) {
 	n := len(x)
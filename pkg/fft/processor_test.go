@@ -1,6 +1,8 @@
 package fft
 
 import (
+	"math"
+	"math/cmplx"
 	"testing"
 	"time"
 
@@ -115,4 +117,93 @@ func TestDefaultProcessor_GetPositiveFrequencies(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestDefaultProcessor_SingleSidedAmplitudes(t *testing.T) {
+	const n = 16
+	const sampleRate = 16.0
+	values := make([]float64, n)
+	for i := range values {
+		tm := float64(i) / sampleRate
+		// 2.0 DC, amplitude 3 at 2 Hz, amplitude 0.5 at Nyquist (8 Hz)
+		values[i] = 2.0 + 3.0*math.Cos(2*math.Pi*2*tm) + 0.5*math.Cos(math.Pi*float64(i))
+	}
+
+	fftProcessor := NewProcessorWithMode(SpectrumSingleSided)
+	spectrum, err := fftProcessor.ProcessSignal(signal.Signal{Timestamp: time.Now(), Values: values, SampleRate: sampleRate})
+	if err != nil {
+		t.Fatalf("ProcessSignal() error = %v", err)
+	}
+	result, err := fftProcessor.GetPositiveFrequencies(spectrum)
+	if err != nil {
+		t.Fatalf("GetPositiveFrequencies() error = %v", err)
+	}
+
+	if len(result.Values) != n/2+1 {
+		t.Fatalf("expected %d bins including Nyquist, got %d", n/2+1, len(result.Values))
+	}
+	if result.Frequencies[n/2] != 8 {
+		t.Errorf("expected Nyquist bin at +8 Hz, got %v", result.Frequencies[n/2])
+	}
+
+	expected := map[int]float64{0: 2.0, 2: 3.0, n / 2: 0.5}
+	for k, v := range result.Values {
+		if got := cmplx.Abs(v); math.Abs(got-expected[k]) > 1e-9 {
+			t.Errorf("bin %d (%v Hz): expected amplitude %v, got %v", k, result.Frequencies[k], expected[k], got)
+		}
+	}
+}
+
+func TestDefaultProcessor_InverseTransform(t *testing.T) {
+	fftProcessor := NewProcessor()
+
+	tests := []struct {
+		name     string
+		spectrum signal.ComplexSignal
+		wantErr  bool
+	}{
+		{
+			name: "two bins",
+			spectrum: signal.ComplexSignal{
+				Timestamp:   time.Now(),
+				Values:      []complex128{3, -1},
+				Frequencies: []float64{0, -1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "single bin",
+			spectrum: signal.ComplexSignal{
+				Timestamp:   time.Now(),
+				Values:      []complex128{1},
+				Frequencies: []float64{0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero bin spacing",
+			spectrum: signal.ComplexSignal{
+				Timestamp:   time.Now(),
+				Values:      []complex128{1, 1},
+				Frequencies: []float64{0, 0},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fftProcessor.InverseTransform(tt.spectrum)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InverseTransform() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if result.SampleRate != 2 || result.Values[0] != 1 || result.Values[1] != 2 {
+				t.Errorf("InverseTransform() = %v at %g Hz, want [1 2] at 2 Hz", result.Values, result.SampleRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// randomSignal is a real signal of random length (radix-2 and DFT paths alike)
+// whose samples span several orders of magnitude
+type randomSignal signal.Signal
+
+// Generate implements quick.Generator
+func (randomSignal) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 1 + r.Intn(300)
+	if r.Intn(2) == 0 {
+		n = 1 << r.Intn(10) // Powers of 2 up to 512
+	}
+	amplitude := math.Pow(10, float64(r.Intn(13)-6)) // 1e-6 .. 1e6
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = amplitude * r.NormFloat64()
+	}
+	return reflect.ValueOf(randomSignal{Timestamp: time.Unix(1700000000, 0), Values: values, SampleRate: float64(1 + r.Intn(10000))})
+}
+
+// coefficient is a scale factor between -10 and 10
+type coefficient float64
+
+// Generate implements quick.Generator
+func (coefficient) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(coefficient(20*r.Float64() - 10))
+}
+
+// quickConfig runs every property on 200 reproducible cases
+var quickConfig = &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))}
+
+// peak returns the largest sample magnitude, the scale of rounding errors
+func peak(values []float64) float64 {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, math.Abs(v))
+	}
+	return max
+}
+
+func TestProperty_InverseRoundTrip(t *testing.T) {
+	processor := NewProcessor()
+	roundTrip := func(x randomSignal) bool {
+		if len(x.Values) < 2 {
+			return true // The sample rate of a single bin is undefined
+		}
+		spectrum, err := processor.ProcessSignal(signal.Signal(x))
+		if err != nil {
+			t.Logf("ProcessSignal() error = %v", err)
+			return false
+		}
+		y, err := processor.InverseTransform(spectrum)
+		if err != nil {
+			t.Logf("InverseTransform() error = %v", err)
+			return false
+		}
+		if len(y.Values) != len(x.Values) || math.Abs(y.SampleRate-x.SampleRate) > 1e-9*x.SampleRate {
+			t.Logf("got %d samples at %g Hz, want %d at %g Hz", len(y.Values), y.SampleRate, len(x.Values), x.SampleRate)
+			return false
+		}
+		tolerance := 1e-9 * peak(x.Values)
+		for i := range x.Values {
+			if math.Abs(y.Values[i]-x.Values[i]) > tolerance {
+				t.Logf("n=%d: sample %d = %g, want %g", len(x.Values), i, y.Values[i], x.Values[i])
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProperty_Linearity(t *testing.T) {
+	processor := NewProcessor().(*DefaultProcessor)
+	linear := func(x randomSignal, ca, cb coefficient) bool {
+		a, b := float64(ca), float64(cb)
+		n := len(x.Values)
+		y := make([]complex128, n)
+		combined := make([]complex128, n)
+		xs := make([]complex128, n)
+		for i, v := range x.Values {
+			xs[i] = complex(v, 0)
+			y[i] = complex(math.Sin(float64(i)), math.Cos(float64(3*i))) * complex(peak(x.Values), 0)
+			combined[i] = complex(a, 0)*xs[i] + complex(b, 0)*y[i]
+		}
+
+		fx, err1 := processor.computeFFT(append([]complex128(nil), xs...))
+		fy, err2 := processor.computeFFT(append([]complex128(nil), y...))
+		fc, err3 := processor.computeFFT(combined)
+		if err1 != nil || err2 != nil || err3 != nil {
+			t.Logf("computeFFT() errors = %v, %v, %v", err1, err2, err3)
+			return false
+		}
+		tolerance := 1e-9 * float64(n) * peak(x.Values) * (math.Abs(a) + math.Abs(b) + 1)
+		for k := range fc {
+			if cmplx.Abs(fc[k]-(complex(a, 0)*fx[k]+complex(b, 0)*fy[k])) > tolerance {
+				t.Logf("n=%d: bin %d = %v, want %v", n, k, fc[k], complex(a, 0)*fx[k]+complex(b, 0)*fy[k])
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(linear, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProperty_Parseval(t *testing.T) {
+	processor := NewProcessor()
+	parseval := func(x randomSignal) bool {
+		spectrum, err := processor.ProcessSignal(signal.Signal(x))
+		if err != nil {
+			t.Logf("ProcessSignal() error = %v", err)
+			return false
+		}
+
+		var timeEnergy, frequencyEnergy float64
+		for _, v := range x.Values {
+			timeEnergy += v * v
+		}
+		for _, v := range spectrum.Values {
+			frequencyEnergy += real(v)*real(v) + imag(v)*imag(v)
+		}
+		frequencyEnergy /= float64(len(x.Values))
+
+		if math.Abs(frequencyEnergy-timeEnergy) > 1e-9*timeEnergy {
+			t.Logf("n=%d: spectral energy %g, want %g", len(x.Values), frequencyEnergy, timeEnergy)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(parseval, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,246 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Tolerance bounds the accepted difference between a golden and an actual
+// number: |got - want| <= Abs + Rel*|want|
+type Tolerance struct {
+	Abs float64
+	Rel float64
+}
+
+// DefaultTolerance accepts differences from reordered floating point operations
+var DefaultTolerance = Tolerance{Abs: 1e-9, Rel: 1e-6}
+
+// Options configures a comparison
+type Options struct {
+	Tolerance Tolerance
+	Ignore    []string // JSON keys and CSV columns whose values vary between runs, e.g. random IDs
+}
+
+// within reports whether got matches want within the tolerance
+func (t Tolerance) within(want, got float64) bool {
+	if math.IsNaN(want) || math.IsNaN(got) {
+		return math.IsNaN(want) && math.IsNaN(got)
+	}
+	return math.Abs(got-want) <= t.Abs+t.Rel*math.Abs(want)
+}
+
+// Compare compares the content of a file by its extension: .json documents
+// structurally, .csv files cell by cell and anything else byte for byte.
+// Numbers are compared within the tolerance.
+func Compare(name string, want, got []byte, options Options) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return compareJSON(want, got, options)
+	case ".csv":
+		return compareCSV(want, got, options)
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("content differs")
+	}
+	return nil
+}
+
+// compareJSON decodes both documents and compares them value by value
+func compareJSON(want, got []byte, options Options) error {
+	var wantValue, gotValue interface{}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		return fmt.Errorf("golden file is not JSON: %w", err)
+	}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		return fmt.Errorf("output is not JSON: %w", err)
+	}
+	ignore := make(map[string]bool, len(options.Ignore))
+	for _, key := range options.Ignore {
+		ignore[key] = true
+	}
+	return compareValue("$", wantValue, gotValue, options.Tolerance, ignore)
+}
+
+// compareValue walks two decoded JSON values and reports the first difference
+func compareValue(path string, want, got interface{}, tolerance Tolerance, ignore map[string]bool) error {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: got %T, want object", path, got)
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		for key := range g {
+			if _, ok := w[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ignore[key] {
+				continue
+			}
+			wv, inWant := w[key]
+			gv, inGot := g[key]
+			if !inWant || !inGot {
+				return fmt.Errorf("%s.%s: present in golden %v, in output %v", path, key, inWant, inGot)
+			}
+			if err := compareValue(path+"."+key, wv, gv, tolerance, ignore); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: got %T, want array", path, got)
+		}
+		if len(g) != len(w) {
+			return fmt.Errorf("%s: got %d elements, want %d", path, len(g), len(w))
+		}
+		for i := range w {
+			if err := compareValue(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], tolerance, ignore); err != nil {
+				return err
+			}
+		}
+		return nil
+	case float64:
+		g, ok := got.(float64)
+		if !ok || !tolerance.within(w, g) {
+			return fmt.Errorf("%s: got %v, want %v", path, got, w)
+		}
+		return nil
+	}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		return fmt.Errorf("%s: got %v, want %v", path, got, want)
+	}
+	return nil
+}
+
+// compareCSV compares two CSV files cell by cell, numerically where both
+// cells are numbers
+func compareCSV(want, got []byte, options Options) error {
+	read := func(content []byte) ([][]string, error) {
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.FieldsPerRecord = -1
+		return reader.ReadAll()
+	}
+	wantRows, err := read(want)
+	if err != nil {
+		return fmt.Errorf("golden file is not CSV: %w", err)
+	}
+	gotRows, err := read(got)
+	if err != nil {
+		return fmt.Errorf("output is not CSV: %w", err)
+	}
+	if len(gotRows) != len(wantRows) {
+		return fmt.Errorf("got %d rows, want %d", len(gotRows), len(wantRows))
+	}
+
+	// Columns are ignored by the name in the first row holding it, so comment
+	// lines above the header do not matter
+	ignored := make(map[int]bool)
+	for i, row := range wantRows {
+		if len(row) != len(gotRows[i]) {
+			return fmt.Errorf("row %d: got %d fields, want %d", i+1, len(gotRows[i]), len(row))
+		}
+		for j, cell := range row {
+			for _, name := range options.Ignore {
+				if cell == name {
+					ignored[j] = true
+				}
+			}
+			if ignored[j] || cell == gotRows[i][j] {
+				continue
+			}
+			wantNumber, errWant := strconv.ParseFloat(cell, 64)
+			gotNumber, errGot := strconv.ParseFloat(gotRows[i][j], 64)
+			if errWant != nil || errGot != nil || !options.Tolerance.within(wantNumber, gotNumber) {
+				return fmt.Errorf("row %d, column %d: got %q, want %q", i+1, j+1, gotRows[i][j], cell)
+			}
+		}
+	}
+	return nil
+}
+
+// CompareDir checks the outputs, keyed by slash separated path, against the
+// files below dir. Golden files without an output and outputs without a golden
+// file fail the test. With update set the golden files are rewritten instead.
+func CompareDir(t testing.TB, dir string, outputs map[string][]byte, update bool, options Options) {
+	t.Helper()
+
+	if update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("removing golden files: %v", err)
+		}
+		for name, content := range outputs {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("writing golden file: %v", err)
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatalf("writing golden file: %v", err)
+			}
+		}
+		t.Logf("updated %d golden files in %s", len(outputs), dir)
+		return
+	}
+
+	goldenFiles, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading golden files (run with -update to create them): %v", err)
+	}
+	names := make([]string, 0, len(goldenFiles))
+	for name := range goldenFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got, ok := outputs[name]
+		if !ok {
+			t.Errorf("%s: golden file has no matching output", name)
+			continue
+		}
+		if err := Compare(name, goldenFiles[name], got, options); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for name := range outputs {
+		if _, ok := goldenFiles[name]; !ok {
+			t.Errorf("%s: output has no golden file (run with -update to accept it)", name)
+		}
+	}
+}
+
+// ReadDir reads every file below dir, keyed by slash separated relative path
+func ReadDir(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,53 @@
+package golden
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	options := Options{Tolerance: DefaultTolerance, Ignore: []string{"id"}}
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		got     string
+		wantErr bool
+	}{
+		{"json equal", "a.json", `{"f":[1,2.5],"unit":"Ω"}`, `{"unit":"Ω","f":[1,2.5]}`, false},
+		{"json within tolerance", "a.json", `{"real":47.609644738}`, `{"real":47.609644738000004}`, false},
+		{"json beyond tolerance", "a.json", `{"real":47.6}`, `{"real":47.7}`, true},
+		{"json ignored key", "a.json", `{"id":"a","v":1}`, `{"id":"b","v":1}`, false},
+		{"json missing key", "a.json", `{"v":1,"w":2}`, `{"v":1}`, true},
+		{"json extra element", "a.json", `[1,2]`, `[1,2,3]`, true},
+		{"json string differs", "a.json", `{"unit":"V"}`, `{"unit":"A"}`, true},
+		{"csv within tolerance", "a.csv", "f,real\n1,10.0000001\n", "f,real\n1,10.0000002\n", false},
+		{"csv beyond tolerance", "a.csv", "f,real\n1,10\n", "f,real\n1,11\n", true},
+		{"csv ignored column", "a.csv", "f,id\n1,a\n", "f,id\n1,b\n", false},
+		{"csv text differs", "a.csv", "f,unit\n1,V\n", "f,unit\n1,A\n", true},
+		{"csv extra row", "a.csv", "f\n1\n", "f\n1\n2\n", true},
+		{"other files exact", "a.txt", "1.0", "1.00", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(tt.file, []byte(tt.want), []byte(tt.got), options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToleranceWithin(t *testing.T) {
+	tolerance := Tolerance{Abs: 1e-6, Rel: 1e-3}
+	if !tolerance.within(0, 1e-7) || tolerance.within(0, 1e-5) {
+		t.Error("absolute tolerance not applied near zero")
+	}
+	if !tolerance.within(1000, 1000.9) || tolerance.within(1000, 1001.1) {
+		t.Error("relative tolerance not applied")
+	}
+	if !tolerance.within(math.NaN(), math.NaN()) || tolerance.within(1, math.NaN()) {
+		t.Error("NaN must only match NaN")
+	}
+}
@@ -38,32 +38,57 @@ func (ic *DefaultCalculator) ValidateSignals(voltageSignal, currentSignal signal
 
 // CalculateImpedance computes complex impedance Z(f) = U(f)/I(f) from voltage and current signals
 func (ic *DefaultCalculator) CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error) {
+	measurement, err := ic.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+
+	return measurement.Impedance, nil
+}
+
+// Measure transforms each signal once and returns the positive-frequency
+// spectra together with the impedance computed from them
+func (ic *DefaultCalculator) Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error) {
 	if err := ic.ValidateSignals(voltageSignal, currentSignal); err != nil {
-		return signal.ImpedanceData{}, config.NewProcessingError("signal validation", err)
+		return Measurement{}, config.NewProcessingError("signal validation", err)
 	}
 
 	voltageFFT, err := ic.fftProcessor.ProcessSignal(voltageSignal)
 	if err != nil {
-		return signal.ImpedanceData{}, config.NewProcessingError("voltage FFT processing", err)
+		return Measurement{}, config.NewProcessingError("voltage FFT processing", err)
 	}
 	
 	currentFFT, err := ic.fftProcessor.ProcessSignal(currentSignal)
 	if err != nil {
-		return signal.ImpedanceData{}, config.NewProcessingError("current FFT processing", err)
+		return Measurement{}, config.NewProcessingError("current FFT processing", err)
 	}
 
 	voltageFFT, err = ic.fftProcessor.GetPositiveFrequencies(voltageFFT)
 	if err != nil {
-		return signal.ImpedanceData{}, config.NewProcessingError("voltage positive frequencies", err)
+		return Measurement{}, config.NewProcessingError("voltage positive frequencies", err)
 	}
 	
 	currentFFT, err = ic.fftProcessor.GetPositiveFrequencies(currentFFT)
 	if err != nil {
-		return signal.ImpedanceData{}, config.NewProcessingError("current positive frequencies", err)
+		return Measurement{}, config.NewProcessingError("current positive frequencies", err)
+	}
+
+	impedanceData, err := ic.CalculateImpedanceFromSpectra(voltageFFT, currentFFT)
+	if err != nil {
+		return Measurement{}, err
 	}
+	impedanceData.Metadata = voltageSignal.Metadata.WithUnit(signal.UnitOhm)
+
+	return Measurement{Voltage: voltageFFT, Current: currentFFT, Impedance: impedanceData}, nil
+}
 
-	if len(voltageFFT.Values) != len(currentFFT.Values) {
-		return signal.ImpedanceData{}, config.NewProcessingError("impedance calculation", config.ErrMismatchedSignalLength)
+// CalculateImpedanceFromSpectra computes Z(f) = U(f)/I(f) from voltage and
+// current spectra that are already in the frequency domain, e.g. positive FFT
+// bins, STFT frames or lock-in amplitudes. Bins without measurable current
+// yield 0.
+func (ic *DefaultCalculator) CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error) {
+	if err := validateSpectra(ic.validator, voltageFFT, currentFFT); err != nil {
+		return signal.ImpedanceData{}, config.NewProcessingError("spectrum validation", err)
 	}
 
 	impedance := make([]complex128, len(voltageFFT.Values))
@@ -81,15 +106,7 @@ func (ic *DefaultCalculator) CalculateImpedance(voltageSignal, currentSignal sig
 		}
 	}
 
-	impedanceData := signal.ImpedanceData{
-		Timestamp:   voltageSignal.Timestamp,
-		Impedance:   impedance,
-		Frequencies: voltageFFT.Frequencies,
-	}
-
-	magnitude, phase := impedanceData.CalculateMagnitudePhase()
-	impedanceData.Magnitude = magnitude
-	impedanceData.Phase = phase
+	impedanceData := signal.NewImpedanceData(voltageFFT.Timestamp, voltageFFT.Frequencies, impedance)
 
 	if err := ic.validator.ValidateImpedanceData(impedanceData); err != nil {
 		return signal.ImpedanceData{}, config.NewProcessingError("impedance data validation", err)
@@ -98,27 +115,26 @@ func (ic *DefaultCalculator) CalculateImpedance(voltageSignal, currentSignal sig
 	return impedanceData, nil
 }
 
-// ProcessEISMeasurement performs a complete EIS measurement including FFT and impedance calculation
-func (ic *DefaultCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
-	if err := ic.ValidateSignals(voltageSignal, currentSignal); err != nil {
-		return signal.EISMeasurement{}, config.NewProcessingError("signal validation", err)
+// validateSpectra validates voltage and current spectra and checks that they
+// share their frequency grid
+func validateSpectra(validator signal.Validator, voltageFFT, currentFFT signal.ComplexSignal) error {
+	if err := validator.ValidateComplexSignal(voltageFFT); err != nil {
+		return config.NewValidationError("VoltageSpectrum", err.Error())
 	}
 
+	if err := validator.ValidateComplexSignal(currentFFT); err != nil {
+		return config.NewValidationError("CurrentSpectrum", err.Error())
+	}
+
+	return signal.ValidateSpectraMatch(voltageFFT, currentFFT)
+}
 
+// ProcessEISMeasurement performs a complete EIS measurement including FFT and impedance calculation
+func (ic *DefaultCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
 	impedanceData, err := ic.CalculateImpedance(voltageSignal, currentSignal)
 	if err != nil {
 		return signal.EISMeasurement{}, config.NewProcessingError("impedance calculation", err)
 	}
 
-	// Convert to EISMeasurement format
-	measurement := make(signal.EISMeasurement, len(impedanceData.Impedance))
-	for i, z := range impedanceData.Impedance {
-		measurement[i] = signal.ImpedancePoint{
-			Frequency: impedanceData.Frequencies[i],
-			Real:      real(z),
-			Imag:      imag(z),
-		}
-	}
-
-	return measurement, nil
+	return impedanceData.ToPoints(), nil
 }
\ No newline at end of file
@@ -0,0 +1,181 @@
+package impedance
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestDefaultCalculator_RecoversGeneratorCircuit(t *testing.T) {
+	circuits := []signal.RandlesCircuit{
+		signal.DefaultCircuit(),
+		{Rs: 15, Rct: 50, Q: 5e-6, N: 0.75},
+	}
+
+	for _, circuit := range circuits {
+		generator := signal.NewGeneratorWithOptions(clock.NewSimulatedClock(time.Unix(0, 0)), signal.GeneratorOptions{
+			Circuit:      circuit,
+			VoltageDC:    1,
+			VoltageNoise: 0.001,
+			CurrentNoise: 0.00001,
+			Seed:         1,
+		})
+		voltage, err := generator.GenerateVoltageSignal(1024, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, err := generator.GenerateCurrentSignal(1024, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := NewCalculator().CalculateImpedance(voltage, current)
+		if err != nil {
+			t.Fatalf("CalculateImpedance() error = %v", err)
+		}
+
+		for _, tone := range signal.DefaultExcitation() {
+			bin := int(tone.Frequency) // 1 Hz resolution
+			got, want := data.Impedance[bin], circuit.Impedance(tone.Frequency)
+			if math.Abs(data.Frequencies[bin]-tone.Frequency) > 1e-9 {
+				t.Fatalf("bin %d is %g Hz, want %g Hz", bin, data.Frequencies[bin], tone.Frequency)
+			}
+			if cmplx.Abs(got-want)/cmplx.Abs(want) > 0.02 {
+				t.Errorf("%+v: Z(%g Hz) = %v, want %v", circuit, tone.Frequency, got, want)
+			}
+		}
+	}
+}
+
+func TestCalculateImpedanceFromSpectra(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	spectrum := func(values ...complex128) signal.ComplexSignal {
+		frequencies := make([]float64, len(values))
+		for i := range frequencies {
+			frequencies[i] = float64(10 * (i + 1))
+		}
+		return signal.ComplexSignal{Timestamp: now, Values: values, Frequencies: frequencies}
+	}
+	shifted := spectrum(1, 1)
+	shifted.Frequencies = []float64{10, 25}
+
+	tests := []struct {
+		name       string
+		calculator Calculator
+		voltage    signal.ComplexSignal
+		current    signal.ComplexSignal
+		want       []complex128
+		wantErr    bool
+	}{
+		{
+			name:       "fft divides every bin",
+			calculator: NewCalculator(),
+			voltage:    spectrum(10, complex(0, 4), 3),
+			current:    spectrum(2, complex(0, 2), 0),
+			want:       []complex128{5, 2, 0},
+		},
+		{
+			name:       "lock-in drops bins without current",
+			calculator: NewLockInCalculator(nil),
+			voltage:    spectrum(10, complex(0, 4), 3),
+			current:    spectrum(2, complex(0, 2), 0),
+			want:       []complex128{5, 2},
+		},
+		{
+			name:       "different grids",
+			calculator: NewCalculator(),
+			voltage:    spectrum(1, 1),
+			current:    shifted,
+			wantErr:    true,
+		},
+		{
+			name:       "different lengths",
+			calculator: NewLockInCalculator(nil),
+			voltage:    spectrum(1, 1),
+			current:    spectrum(1),
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.calculator.CalculateImpedanceFromSpectra(tt.voltage, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculateImpedanceFromSpectra() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(data.Impedance) != len(tt.want) || len(data.Magnitude) != len(tt.want) {
+				t.Fatalf("got %d points (%d magnitudes), want %d", len(data.Impedance), len(data.Magnitude), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if cmplx.Abs(data.Impedance[i]-want) > 1e-12 {
+					t.Errorf("Z[%d] = %v, want %v", i, data.Impedance[i], want)
+				}
+			}
+			if data.Metadata.Unit != signal.UnitOhm || !data.Timestamp.Equal(now) {
+				t.Errorf("metadata %+v at %v, want unit %q at %v", data.Metadata, data.Timestamp, signal.UnitOhm, now)
+			}
+		})
+	}
+}
+
+// countingProcessor counts the signals transformed by the wrapped processor
+type countingProcessor struct {
+	fft.Processor
+	transforms int
+}
+
+func (cp *countingProcessor) ProcessSignal(sig signal.Signal) (signal.ComplexSignal, error) {
+	cp.transforms++
+	return cp.Processor.ProcessSignal(sig)
+}
+
+func TestDefaultCalculator_TransformsEachSignalOnce(t *testing.T) {
+	generator := signal.NewGeneratorWithOptions(clock.NewSimulatedClock(time.Unix(0, 0)), signal.GeneratorOptions{
+		Circuit:   signal.DefaultCircuit(),
+		VoltageDC: 1,
+		Seed:      1,
+	})
+	voltage, err := generator.GenerateVoltageSignal(1024, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := generator.GenerateCurrentSignal(1024, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processor := &countingProcessor{Processor: fft.NewProcessor()}
+	calculator := &DefaultCalculator{fftProcessor: processor, validator: signal.NewValidator()}
+
+	if _, err := calculator.ProcessEISMeasurement(voltage, current); err != nil {
+		t.Fatalf("ProcessEISMeasurement() error = %v", err)
+	}
+	if processor.transforms != 2 {
+		t.Errorf("ProcessEISMeasurement() ran %d FFTs, want 2", processor.transforms)
+	}
+
+	processor.transforms = 0
+	measurement, err := calculator.Measure(voltage, current)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if processor.transforms != 2 {
+		t.Errorf("Measure() ran %d FFTs, want 2", processor.transforms)
+	}
+	for i, z := range measurement.Impedance.Impedance {
+		if cmplx.Abs(measurement.Current.Values[i]) < 1e-10 {
+			continue
+		}
+		if want := measurement.Voltage.Values[i] / measurement.Current.Values[i]; cmplx.Abs(z-want) > 1e-9*cmplx.Abs(want) {
+			t.Fatalf("Z[%d] = %v, want U/I = %v", i, z, want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package impedance
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// LowPass is a Butterworth low-pass stage, the usual anti-alias filter in
+// front of an ADC
+type LowPass struct {
+	Order  int     // Number of poles
+	Cutoff float64 // -3 dB frequency in Hz
+}
+
+// Response returns the complex gain of the filter at f
+func (lp LowPass) Response(f float64) complex128 {
+	s := complex(0, f/lp.Cutoff)
+	gain := complex(1, 0)
+	for k := 1; k <= lp.Order; k++ {
+		pole := cmplx.Exp(complex(0, math.Pi*float64(2*k+lp.Order-1)/float64(2*lp.Order)))
+		gain /= s - pole
+	}
+	return gain
+}
+
+// ResponseTable is a measured frequency response. Gain and unwrapped phase
+// are interpolated separately, linearly in log frequency between the points,
+// and held constant beyond the first and last point.
+type ResponseTable struct {
+	Frequencies []float64 // Strictly increasing, above 0
+	Gains       []float64 // Linear
+	Phases      []float64 // Radians
+}
+
+// LoadResponseTable reads a CSV file with a header and the columns
+// frequency in Hz, gain (linear) and unwrapped phase in degrees. Frequencies
+// must be above 0 and strictly increasing.
+func LoadResponseTable(path string) (*ResponseTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("response table %s: %w", path, err)
+	}
+	table := &ResponseTable{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("response table %s: %w", path, err)
+		}
+		var values [3]float64
+		for i, field := range record {
+			if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+				return nil, fmt.Errorf("response table %s: invalid number '%s'", path, field)
+			}
+		}
+		if values[0] <= 0 || values[1] <= 0 {
+			return nil, fmt.Errorf("response table %s: frequency and gain must be positive", path)
+		}
+		if n := len(table.Frequencies); n > 0 && values[0] <= table.Frequencies[n-1] {
+			return nil, fmt.Errorf("response table %s: frequencies must be strictly increasing, %g Hz follows %g Hz", path, values[0], table.Frequencies[n-1])
+		}
+		table.Frequencies = append(table.Frequencies, values[0])
+		table.Gains = append(table.Gains, values[1])
+		table.Phases = append(table.Phases, values[2]*math.Pi/180)
+	}
+	if len(table.Frequencies) == 0 {
+		return nil, fmt.Errorf("response table %s has no points", path)
+	}
+	return table, nil
+}
+
+// Response returns the interpolated complex gain at f
+func (rt *ResponseTable) Response(f float64) complex128 {
+	n := len(rt.Frequencies)
+	j := sort.SearchFloat64s(rt.Frequencies, f)
+	switch {
+	case j == 0:
+		return cmplx.Rect(rt.Gains[0], rt.Phases[0])
+	case j == n:
+		return cmplx.Rect(rt.Gains[n-1], rt.Phases[n-1])
+	}
+	t := math.Log(f/rt.Frequencies[j-1]) / math.Log(rt.Frequencies[j]/rt.Frequencies[j-1])
+	gain := rt.Gains[j-1] + t*(rt.Gains[j]-rt.Gains[j-1])
+	phase := rt.Phases[j-1] + t*(rt.Phases[j]-rt.Phases[j-1])
+	return cmplx.Rect(gain, phase)
+}
+
+// ChannelResponse describes the known acquisition chain of one channel:
+// a group delay, anti-alias filters and a measured response, all in series
+type ChannelResponse struct {
+	Delay    time.Duration
+	LowPass  []LowPass
+	Measured *ResponseTable
+}
+
+// ParseChannelResponse parses a comma separated chain such as
+// "delay=12us,lowpass=4@20000,response=cal.csv"; lowpass stages are
+// order@cutoff in Hz and may repeat
+func ParseChannelResponse(spec string) (ChannelResponse, error) {
+	var response ChannelResponse
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return ChannelResponse{}, config.NewValidationError("ChannelResponse", fmt.Sprintf("'%s' is not key=value", item))
+		}
+		switch strings.TrimSpace(key) {
+		case "delay":
+			delay, err := time.ParseDuration(value)
+			if err != nil {
+				return ChannelResponse{}, config.NewValidationError("ChannelResponse", fmt.Sprintf("invalid delay '%s'", value))
+			}
+			response.Delay += delay
+		case "lowpass":
+			order, cutoff, ok := strings.Cut(value, "@")
+			stage := LowPass{}
+			var err error
+			if ok {
+				stage.Order, err = strconv.Atoi(order)
+			}
+			if err == nil && ok {
+				stage.Cutoff, err = strconv.ParseFloat(cutoff, 64)
+			}
+			if !ok || err != nil || stage.Order < 1 || stage.Order > 16 || stage.Cutoff <= 0 {
+				return ChannelResponse{}, config.NewValidationError("ChannelResponse", fmt.Sprintf("lowpass '%s' must be order@cutoff with order 1-16 and cutoff in Hz", value))
+			}
+			response.LowPass = append(response.LowPass, stage)
+		case "response":
+			if response.Measured != nil {
+				return ChannelResponse{}, config.NewValidationError("ChannelResponse", "only one response table per channel")
+			}
+			table, err := LoadResponseTable(value)
+			if err != nil {
+				return ChannelResponse{}, config.NewValidationError("ChannelResponse", err.Error())
+			}
+			response.Measured = table
+		default:
+			return ChannelResponse{}, config.NewValidationError("ChannelResponse", fmt.Sprintf("unknown element '%s' (delay, lowpass or response)", key))
+		}
+	}
+	return response, nil
+}
+
+// IsZero reports whether the chain is transparent
+func (cr ChannelResponse) IsZero() bool {
+	return cr.Delay == 0 && len(cr.LowPass) == 0 && cr.Measured == nil
+}
+
+// Response returns the complex gain of the whole chain at f
+func (cr ChannelResponse) Response(f float64) complex128 {
+	gain := cmplx.Rect(1, -2*math.Pi*f*cr.Delay.Seconds())
+	for _, stage := range cr.LowPass {
+		gain *= stage.Response(f)
+	}
+	if cr.Measured != nil {
+		gain *= cr.Measured.Response(f)
+	}
+	return gain
+}
+
+// String describes the chain for logs
+func (cr ChannelResponse) String() string {
+	var parts []string
+	if cr.Delay != 0 {
+		parts = append(parts, fmt.Sprintf("delay %v", cr.Delay))
+	}
+	for _, stage := range cr.LowPass {
+		parts = append(parts, fmt.Sprintf("%d-pole Butterworth at %g Hz", stage.Order, stage.Cutoff))
+	}
+	if cr.Measured != nil {
+		parts = append(parts, fmt.Sprintf("measured response of %d points", len(cr.Measured.Frequencies)))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CompensatingCalculator wraps a Calculator and divides the voltage and
+// current spectra by the responses of their acquisition chains before the
+// impedance is computed, so that Z = U/I · H_I/H_U no longer carries the
+// delays and filter phases of the hardware
+type CompensatingCalculator struct {
+	Calculator
+	voltage ChannelResponse
+	current ChannelResponse
+}
+
+// NewCompensatingCalculator creates a calculator removing the voltage and
+// current chain responses
+func NewCompensatingCalculator(calculator Calculator, voltage, current ChannelResponse) *CompensatingCalculator {
+	return &CompensatingCalculator{Calculator: calculator, voltage: voltage, current: current}
+}
+
+// CalculateImpedance computes the impedance of the compensated spectra
+func (cc *CompensatingCalculator) CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error) {
+	measurement, err := cc.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+	return measurement.Impedance, nil
+}
+
+// Measure transforms the signals with the wrapped calculator and removes the
+// chain responses from both spectra and the impedance
+func (cc *CompensatingCalculator) Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error) {
+	measurement, err := cc.Calculator.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return measurement, err
+	}
+	measurement.Voltage = divideResponse(measurement.Voltage, cc.voltage.Response)
+	measurement.Current = divideResponse(measurement.Current, cc.current.Response)
+	return remeasure(cc.Calculator, measurement)
+}
+
+// CalculateImpedanceFromSpectra removes the chain responses from the spectra
+// before dividing
+func (cc *CompensatingCalculator) CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error) {
+	return cc.Calculator.CalculateImpedanceFromSpectra(divideResponse(voltageFFT, cc.voltage.Response), divideResponse(currentFFT, cc.current.Response))
+}
+
+// ProcessEISMeasurement performs a complete EIS measurement with compensated
+// spectra
+func (cc *CompensatingCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
+	impedanceData, err := cc.CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.EISMeasurement{}, config.NewProcessingError("impedance calculation", err)
+	}
+	return impedanceData.ToPoints(), nil
+}
+
+// divideResponse returns spectrum with every value divided by the response
+// at its frequency
+func divideResponse(spectrum signal.ComplexSignal, response func(f float64) complex128) signal.ComplexSignal {
+	corrected := spectrum
+	corrected.Values = make([]complex128, len(spectrum.Values))
+	for i, v := range spectrum.Values {
+		corrected.Values[i] = v / response(spectrum.Frequencies[i])
+	}
+	return corrected
+}
+
+// remeasure recomputes the impedance of corrected spectra, keeping the
+// identity and metadata of the original impedance
+func remeasure(calculator Calculator, measurement Measurement) (Measurement, error) {
+	impedanceData, err := calculator.CalculateImpedanceFromSpectra(measurement.Voltage, measurement.Current)
+	if err != nil {
+		return Measurement{}, err
+	}
+	impedanceData.Identity = measurement.Impedance.Identity
+	impedanceData.Metadata = measurement.Impedance.Metadata
+	measurement.Impedance = impedanceData
+	return measurement, nil
+}
@@ -0,0 +1,94 @@
+package impedance
+
+import (
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestLowPass_Response(t *testing.T) {
+	for order := 1; order <= 4; order++ {
+		gain := LowPass{Order: order, Cutoff: 1000}.Response(1000)
+		if magnitude := cmplx.Abs(gain); math.Abs(magnitude-1/math.Sqrt2) > 1e-9 {
+			t.Errorf("order %d: |H(fc)| = %g, want 1/√2", order, magnitude)
+		}
+		if phase := cmplx.Phase(gain) * 180 / math.Pi; math.Abs(math.Mod(phase+45*float64(order)+540, 360)-180) > 1e-6 {
+			t.Errorf("order %d: phase at cutoff %g°, want %d°", order, phase, -45*order)
+		}
+	}
+}
+
+func TestCompensatingCalculator(t *testing.T) {
+	const sampleRate = 8192.0
+	const resistance = 10.0
+	voltageChain := ChannelResponse{LowPass: []LowPass{{Order: 2, Cutoff: 800}}}
+	currentChain := ChannelResponse{Delay: 150 * time.Microsecond, LowPass: []LowPass{{Order: 1, Cutoff: 300}}}
+
+	// Tones across a resistor as seen through the two chains
+	frequencies := []float64{1, 10, 100, 250, 500}
+	voltage := make([]float64, 8192)
+	current := make([]float64, 8192)
+	for i := range voltage {
+		tm := float64(i) / sampleRate
+		for _, f := range frequencies {
+			hu, hi := voltageChain.Response(f), currentChain.Response(f)
+			voltage[i] += cmplx.Abs(hu) * math.Sin(2*math.Pi*f*tm+cmplx.Phase(hu))
+			current[i] += cmplx.Abs(hi) / resistance * math.Sin(2*math.Pi*f*tm+cmplx.Phase(hi))
+		}
+	}
+	now := time.Unix(0, 0)
+	voltageSignal := signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate}
+	currentSignal := signal.Signal{Timestamp: now, Values: current, SampleRate: sampleRate}
+
+	result, err := NewCompensatingCalculator(NewCalculator(), voltageChain, currentChain).CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		t.Fatalf("CalculateImpedance() error = %v", err)
+	}
+	for i, f := range result.Frequencies {
+		switch f {
+		case 1, 10, 100, 250, 500:
+			if err := cmplx.Abs(result.Impedance[i] - resistance); err > 0.01 {
+				t.Errorf("%g Hz: Z = %v, want %g ohm", f, result.Impedance[i], resistance)
+			}
+		}
+	}
+}
+
+func TestParseChannelResponse(t *testing.T) {
+	table := filepath.Join(t.TempDir(), "cal.csv")
+	os.WriteFile(table, []byte("frequency_hz,gain,phase_deg\n10,1,0\n1000,0.5,-90\n"), 0644)
+
+	response, err := ParseChannelResponse("delay=12us, lowpass=4@20000, lowpass=1@5000, response=" + table)
+	if err != nil {
+		t.Fatalf("ParseChannelResponse() error = %v", err)
+	}
+	if response.Delay != 12*time.Microsecond || len(response.LowPass) != 2 || response.LowPass[0] != (LowPass{Order: 4, Cutoff: 20000}) {
+		t.Errorf("ParseChannelResponse() = %+v", response)
+	}
+	if gain := response.Measured.Response(100); math.Abs(cmplx.Abs(gain)-0.75) > 1e-9 {
+		t.Errorf("measured |H(100 Hz)| = %g, want 0.75 halfway in log frequency", cmplx.Abs(gain))
+	}
+
+	// Tables that cannot be interpolated in log frequency
+	var invalid []string
+	for name, content := range map[string]string{
+		"zero.csv":       "frequency_hz,gain,phase_deg\n0,1,0\n1000,0.5,-90\n",
+		"duplicate.csv":  "frequency_hz,gain,phase_deg\n10,1,0\n10,0.5,-90\n",
+		"decreasing.csv": "frequency_hz,gain,phase_deg\n1000,0.5,-90\n10,1,0\n",
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		os.WriteFile(path, []byte(content), 0644)
+		invalid = append(invalid, "response="+path)
+	}
+
+	for _, spec := range append([]string{"delay", "delay=soon", "lowpass=4", "lowpass=0@100", "lowpass=2@-1", "notch=50", "response=missing.csv"}, invalid...) {
+		if _, err := ParseChannelResponse(spec); err == nil {
+			t.Errorf("ParseChannelResponse(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package impedance
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// DelayEstimationChunks is the number of signal pairs whose median
+// cross-correlation delay becomes the estimated channel delay
+const DelayEstimationChunks = 5
+
+// DelayCompensatingCalculator wraps a Calculator and removes a constant time
+// offset between the voltage and current loggers before the impedance is
+// computed. A current recorded τ late has the spectrum I(f)·exp(-j2πfτ), so
+// its spectrum is rotated back by exp(j2πfτ), which delays by fractions of a
+// sample as exactly as by whole ones. Even 1 ms of skew turns the phase at
+// 250 Hz by 90°.
+type DelayCompensatingCalculator struct {
+	Calculator
+	mu        sync.Mutex
+	delay     time.Duration
+	estimates []time.Duration // Estimates so far while the delay is being estimated
+	estimate  bool
+}
+
+// NewDelayCompensatingCalculator creates a calculator removing delay, the
+// lag of the current behind the voltage (negative if the current leads)
+func NewDelayCompensatingCalculator(calculator Calculator, delay time.Duration) *DelayCompensatingCalculator {
+	return &DelayCompensatingCalculator{Calculator: calculator, delay: delay}
+}
+
+// NewDelayEstimatingCalculator creates a calculator estimating the delay
+// from the cross-correlation of the first DelayEstimationChunks signal pairs,
+// see fft.EstimateDelay, and removing their median from then on. The
+// channels must be in phase during these chunks.
+func NewDelayEstimatingCalculator(calculator Calculator) *DelayCompensatingCalculator {
+	return &DelayCompensatingCalculator{Calculator: calculator, estimate: true}
+}
+
+// Delay returns the delay currently removed
+func (dc *DelayCompensatingCalculator) Delay() time.Duration {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.delay
+}
+
+// CalculateImpedance computes the impedance of the delay corrected signals
+func (dc *DelayCompensatingCalculator) CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error) {
+	measurement, err := dc.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+	return measurement.Impedance, nil
+}
+
+// Measure transforms the signals with the wrapped calculator and corrects the
+// current spectrum and impedance by the channel delay
+func (dc *DelayCompensatingCalculator) Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error) {
+	delay := dc.update(voltageSignal, currentSignal)
+	measurement, err := dc.Calculator.Measure(voltageSignal, currentSignal)
+	if err != nil || delay == 0 {
+		return measurement, err
+	}
+
+	measurement.Current = compensateDelay(measurement.Current, delay)
+	return remeasure(dc.Calculator, measurement)
+}
+
+// CalculateImpedanceFromSpectra corrects the current spectrum by the channel
+// delay before dividing
+func (dc *DelayCompensatingCalculator) CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error) {
+	return dc.Calculator.CalculateImpedanceFromSpectra(voltageFFT, compensateDelay(currentFFT, dc.Delay()))
+}
+
+// ProcessEISMeasurement performs a complete EIS measurement of the delay
+// corrected signals
+func (dc *DelayCompensatingCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
+	impedanceData, err := dc.CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.EISMeasurement{}, config.NewProcessingError("impedance calculation", err)
+	}
+	return impedanceData.ToPoints(), nil
+}
+
+// update adds the estimate of one signal pair while the delay is estimated
+// and returns the delay to remove from it, the median of the estimates so far
+func (dc *DelayCompensatingCalculator) update(voltageSignal, currentSignal signal.Signal) time.Duration {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if !dc.estimate {
+		return dc.delay
+	}
+
+	estimate, err := fft.EstimateDelay(voltageSignal, currentSignal)
+	if err != nil {
+		log.Printf("Channel delay estimation skipped a chunk: %v", err)
+		return dc.delay
+	}
+	dc.estimates = append(dc.estimates, estimate)
+	sorted := append([]time.Duration(nil), dc.estimates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	dc.delay = sorted[len(sorted)/2]
+	if len(dc.estimates) == DelayEstimationChunks {
+		dc.estimate = false
+		log.Printf("Estimated channel delay: current lags voltage by %v (from %v)", dc.delay, dc.estimates)
+	}
+	return dc.delay
+}
+
+// compensateDelay returns spectrum advanced by delay: every value is rotated
+// by exp(j2πfτ)
+func compensateDelay(spectrum signal.ComplexSignal, delay time.Duration) signal.ComplexSignal {
+	if delay == 0 {
+		return spectrum
+	}
+	return divideResponse(spectrum, ChannelResponse{Delay: delay}.Response)
+}
@@ -0,0 +1,91 @@
+package impedance
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// resistorSignals samples a multisine across a resistor with the current
+// logger lagging by skew
+func resistorSignals(resistance float64, skew time.Duration) (signal.Signal, signal.Signal) {
+	const sampleRate = 8192.0
+	frequencies := []float64{1, 10, 100, 250, 500}
+	voltage := make([]float64, 8192)
+	current := make([]float64, 8192)
+	for i := range voltage {
+		tm := float64(i) / sampleRate
+		for _, f := range frequencies {
+			voltage[i] += math.Sin(2 * math.Pi * f * tm)
+			current[i] += math.Sin(2*math.Pi*f*(tm-skew.Seconds())) / resistance
+		}
+	}
+	now := time.Unix(0, 0)
+	return signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate},
+		signal.Signal{Timestamp: now, Values: current, SampleRate: sampleRate}
+}
+
+// maxPhaseAt returns the largest impedance phase in degrees at the tones
+func maxPhaseAt(t *testing.T, data signal.ImpedanceData) float64 {
+	t.Helper()
+	worst := 0.0
+	for i, f := range data.Frequencies {
+		switch f {
+		case 1, 10, 100, 250, 500:
+			worst = math.Max(worst, math.Abs(cmplx.Phase(data.Impedance[i])*180/math.Pi))
+		}
+	}
+	return worst
+}
+
+func TestDelayCompensatingCalculator(t *testing.T) {
+	skew := 1300 * time.Microsecond // 10.6 samples
+	voltage, current := resistorSignals(10, skew)
+
+	skewed, err := NewCalculator().CalculateImpedance(voltage, current)
+	if err != nil {
+		t.Fatalf("CalculateImpedance() error = %v", err)
+	}
+	if phase := maxPhaseAt(t, skewed); phase < 45 {
+		t.Fatalf("uncorrected phase %.1f°, want the skew to show", phase)
+	}
+
+	tests := []struct {
+		name       string
+		calculator *DelayCompensatingCalculator
+	}{
+		{name: "configured", calculator: NewDelayCompensatingCalculator(NewCalculator(), skew)},
+		{name: "estimated", calculator: NewDelayEstimatingCalculator(NewCalculator())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrected, err := tt.calculator.CalculateImpedance(voltage, current)
+			if err != nil {
+				t.Fatalf("CalculateImpedance() error = %v", err)
+			}
+			if phase := maxPhaseAt(t, corrected); phase > 0.5 {
+				t.Errorf("corrected phase %.2f° of a resistor, want about 0", phase)
+			}
+			if delay := tt.calculator.Delay(); delay < skew-10*time.Microsecond || delay > skew+10*time.Microsecond {
+				t.Errorf("Delay() = %v, want %v", delay, skew)
+			}
+		})
+	}
+}
+
+func TestDelayCompensatingCalculator_FixesEstimate(t *testing.T) {
+	calculator := NewDelayEstimatingCalculator(NewCalculator())
+	voltage, current := resistorSignals(10, 500*time.Microsecond)
+	for i := 0; i < DelayEstimationChunks; i++ {
+		calculator.Measure(voltage, current)
+	}
+	// Signals with another lag afterwards must not move the estimate
+	later, _ := resistorSignals(10, 0)
+	calculator.Measure(later, current)
+	if delay := calculator.Delay(); delay < 490*time.Microsecond || delay > 510*time.Microsecond {
+		t.Errorf("Delay() = %v after the estimation chunks, want 500µs", delay)
+	}
+}
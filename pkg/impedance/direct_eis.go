@@ -1,22 +1,32 @@
 package impedance
 
 import (
+	"fmt"
 	"math"
 	"math/cmplx"
-	"time"
+	"slices"
 
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
 	"github.com/adam/masterapp/pkg/signal"
 )
 
 // EISGenerator generates EIS data directly from circuit models (like the Python code)
 type EISGenerator struct {
 	spectrumCounter int
+	clock           clock.Clock
 }
 
-// NewEISGenerator creates a new EIS data generator
+// NewEISGenerator creates a new EIS data generator timestamping with the wall clock
 func NewEISGenerator() *EISGenerator {
+	return NewEISGeneratorWithClock(clock.NewSystemClock())
+}
+
+// NewEISGeneratorWithClock creates a new EIS data generator timestamping with the given clock
+func NewEISGeneratorWithClock(c clock.Clock) *EISGenerator {
 	return &EISGenerator{
 		spectrumCounter: 0,
+		clock:           clock.OrSystem(c),
 	}
 }
 
@@ -29,6 +39,50 @@ type CircuitParameters struct {
 	N          float64 // CPE exponent
 }
 
+// Frequency range of GenerateLogFrequencies
+const (
+	generatedMinFrequency = 0.01
+	generatedMaxFrequency = 1e5
+)
+
+// RctAt returns the charge transfer resistance of the given spectrum number
+func (p CircuitParameters) RctAt(spectrum int) float64 {
+	return p.RctInitial + float64(spectrum)*p.RctGrowth
+}
+
+// Validate checks that every one of spectra generated spectra describes a
+// physical cell, see signal.RandlesCircuit.Validate; R_ct may shrink as long
+// as it stays positive
+func (p CircuitParameters) Validate(spectra int) error {
+	if math.IsNaN(p.RctGrowth) || math.IsInf(p.RctGrowth, 0) {
+		return config.NewValidationError("RctGrowth", fmt.Sprintf("R_ct growth must be finite, got %g", p.RctGrowth))
+	}
+	if err := (signal.RandlesCircuit{Rs: p.Rs, Rct: p.RctInitial, Q: p.Q, N: p.N}).Validate(); err != nil {
+		return err
+	}
+	if last := p.RctAt(max(spectra-1, 0)); !(last > 0) {
+		return config.NewValidationError("RctGrowth", fmt.Sprintf("R_ct reaches %g Ω at spectrum %d", last, spectra-1))
+	}
+	return nil
+}
+
+// Plausibility describes implausible aspects of the first and last of spectra
+// generated spectra, see signal.RandlesCircuit.Plausibility
+func (p CircuitParameters) Plausibility(spectra int) []string {
+	first := signal.RandlesCircuit{Rs: p.Rs, Rct: p.RctInitial, Q: p.Q, N: p.N}
+	warnings := first.Plausibility(generatedMinFrequency, generatedMaxFrequency)
+	if last := p.RctAt(max(spectra-1, 0)); last != first.Rct {
+		lastCircuit := first
+		lastCircuit.Rct = last
+		for _, warning := range lastCircuit.Plausibility(generatedMinFrequency, generatedMaxFrequency) {
+			if !slices.Contains(warnings, warning) {
+				warnings = append(warnings, fmt.Sprintf("spectrum %d: %s", spectra-1, warning))
+			}
+		}
+	}
+	return warnings
+}
+
 // GenerateLogFrequencies creates logarithmically spaced frequencies like the Python code
 func (g *EISGenerator) GenerateLogFrequencies(numPoints int) []float64 {
 	// Python: frequencies = np.logspace(5, -2, 50)  # 100kHz to 0.01Hz
@@ -78,17 +132,8 @@ func (g *EISGenerator) GenerateEISSpectrum(params CircuitParameters) signal.Impe
 		impedance[i] = ZTotal
 	}
 
-	// Create ImpedanceData structure
-	data := signal.ImpedanceData{
-		Timestamp:   time.Now(),
-		Impedance:   impedance,
-		Frequencies: frequencies,
-	}
-
-	// Calculate magnitude and phase
-	magnitude, phase := data.CalculateMagnitudePhase()
-	data.Magnitude = magnitude
-	data.Phase = phase
+	// Create ImpedanceData structure with magnitude and phase
+	data := signal.NewImpedanceData(g.clock.Now(), frequencies, impedance)
 
 	// Increment spectrum counter for next call (simulates time evolution)
 	g.spectrumCounter++
@@ -112,6 +157,11 @@ func (g *EISGenerator) ResetCounter() {
 	g.spectrumCounter = 0
 }
 
+// SetCurrentSpectrum continues generation at the given spectrum number, e.g. when resuming a run
+func (g *EISGenerator) SetCurrentSpectrum(spectrum int) {
+	g.spectrumCounter = spectrum
+}
+
 // GetCurrentSpectrum returns current spectrum number
 func (g *EISGenerator) GetCurrentSpectrum() int {
 	return g.spectrumCounter
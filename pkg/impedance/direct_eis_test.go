@@ -0,0 +1,46 @@
+package impedance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCircuitParameters_Validate(t *testing.T) {
+	defaults := NewEISGenerator().GetDefaultParameters()
+	shrinking := defaults
+	shrinking.RctGrowth = -1
+
+	tests := []struct {
+		name    string
+		params  CircuitParameters
+		spectra int
+		wantErr bool
+	}{
+		{name: "defaults", params: defaults, spectra: 1000},
+		{name: "shrinking R_ct stays positive", params: shrinking, spectra: 20},
+		{name: "shrinking R_ct reaches zero", params: shrinking, spectra: 21, wantErr: true},
+		{name: "CPE exponent above 1", params: CircuitParameters{Rs: 10, RctInitial: 20, Q: 1e-5, N: 1.2}, spectra: 1, wantErr: true},
+		{name: "negative Q", params: CircuitParameters{Rs: 10, RctInitial: 20, Q: -1e-5, N: 0.8}, spectra: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(tt.spectra); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%d) error = %v, wantErr %v", tt.spectra, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCircuitParameters_Plausibility(t *testing.T) {
+	if warnings := NewEISGenerator().GetDefaultParameters().Plausibility(1000); len(warnings) != 0 {
+		t.Errorf("default parameters: Plausibility() = %q, want none", warnings)
+	}
+
+	// Growing R_ct moves the arc apex below the generated frequencies
+	params := CircuitParameters{Rs: 10, RctInitial: 20, RctGrowth: 1e4, Q: 1e-2, N: 1}
+	warnings := params.Plausibility(100)
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "spectrum 99: arc apex") {
+		t.Errorf("Plausibility() = %q, want the apex of the last spectrum", warnings)
+	}
+}
@@ -4,9 +4,20 @@ import (
 	"github.com/adam/masterapp/pkg/signal"
 )
 
+// Measurement holds the voltage and current spectra of one signal pair
+// together with the impedance derived from them, so callers needing U(f) or
+// I(f) as well as Z(f) share one transform of each signal
+type Measurement struct {
+	Voltage   signal.ComplexSignal // Voltage spectrum at the analyzed frequencies
+	Current   signal.ComplexSignal // Current spectrum on the same frequency grid
+	Impedance signal.ImpedanceData
+}
+
 // Calculator defines the interface for impedance calculations
 type Calculator interface {
 	CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error)
+	Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error)
+	CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error)
 	ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error)
 	ValidateSignals(voltageSignal, currentSignal signal.Signal) error
 }
\ No newline at end of file
@@ -0,0 +1,176 @@
+package impedance
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// lockInDetectionThreshold is the fraction of the strongest voltage component an
+// excitation must reach when frequencies are detected rather than configured
+const lockInDetectionThreshold = 0.05
+
+// LockInCalculator estimates impedance by synchronous detection: voltage and
+// current are correlated with reference sine and cosine waves at each
+// excitation frequency over the whole block, which rejects broadband noise
+// outside the excitation lines better than dividing FFT bins
+type LockInCalculator struct {
+	frequencies []float64
+	validator   signal.Validator
+	demodulate  func(sig signal.Signal, origin time.Time, frequency float64) complex128 // Complex amplitude of sig at frequency, phase referred to origin
+}
+
+// NewLockInCalculator creates a lock-in impedance calculator for the given
+// excitation frequencies in Hz. When none are given they are detected from the
+// spectrum of each voltage signal.
+func NewLockInCalculator(frequencies []float64) Calculator {
+	return &LockInCalculator{
+		frequencies: frequencies,
+		validator:   signal.NewValidator(),
+		demodulate:  demodulate,
+	}
+}
+
+// ValidateSignals validates that voltage and current signals are compatible
+func (lc *LockInCalculator) ValidateSignals(voltageSignal, currentSignal signal.Signal) error {
+	if err := lc.validator.ValidateSignal(voltageSignal); err != nil {
+		return config.NewValidationError("VoltageSignal", err.Error())
+	}
+
+	if err := lc.validator.ValidateSignal(currentSignal); err != nil {
+		return config.NewValidationError("CurrentSignal", err.Error())
+	}
+
+	return signal.ValidateSignalsMatch(voltageSignal, currentSignal)
+}
+
+// CalculateImpedance computes Z(f) = U(f)/I(f) at each excitation frequency below Nyquist
+func (lc *LockInCalculator) CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error) {
+	measurement, err := lc.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+
+	return measurement.Impedance, nil
+}
+
+// Measure demodulates both signals at each excitation frequency below Nyquist
+// and returns the complex amplitudes together with the impedance computed
+// from them; the impedance leaves out frequencies without measurable current
+func (lc *LockInCalculator) Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error) {
+	if err := lc.ValidateSignals(voltageSignal, currentSignal); err != nil {
+		return Measurement{}, config.NewProcessingError("signal validation", err)
+	}
+
+	frequencies := lc.frequencies
+	if len(frequencies) == 0 {
+		detected, err := fft.ExcitationFrequencies(voltageSignal, lockInDetectionThreshold)
+		if err != nil {
+			return Measurement{}, config.NewProcessingError("excitation detection", err)
+		}
+		frequencies = detected
+	}
+
+	nyquist := voltageSignal.SampleRate / 2
+	voltageSpectrum := signal.ComplexSignal{Timestamp: voltageSignal.Timestamp}
+	currentSpectrum := signal.ComplexSignal{Timestamp: currentSignal.Timestamp}
+	for _, frequency := range frequencies {
+		if frequency <= 0 || frequency >= nyquist {
+			continue
+		}
+		voltageSpectrum.Frequencies = append(voltageSpectrum.Frequencies, frequency)
+		voltageSpectrum.Values = append(voltageSpectrum.Values, lc.demodulate(voltageSignal, voltageSignal.Timestamp, frequency))
+		currentSpectrum.Frequencies = append(currentSpectrum.Frequencies, frequency)
+		currentSpectrum.Values = append(currentSpectrum.Values, lc.demodulate(currentSignal, voltageSignal.Timestamp, frequency))
+	}
+	if len(voltageSpectrum.Values) == 0 {
+		return Measurement{}, config.NewProcessingError("impedance calculation",
+			config.NewValidationError("Frequencies", "no excitation frequency below Nyquist"))
+	}
+
+	result, err := lc.CalculateImpedanceFromSpectra(voltageSpectrum, currentSpectrum)
+	if err != nil {
+		return Measurement{}, err
+	}
+	result.Metadata = voltageSignal.Metadata.WithUnit(signal.UnitOhm)
+
+	return Measurement{Voltage: voltageSpectrum, Current: currentSpectrum, Impedance: result}, nil
+}
+
+// CalculateImpedanceFromSpectra computes Z(f) = U(f)/I(f) from complex
+// amplitudes on a common frequency grid, dropping frequencies without
+// measurable current
+func (lc *LockInCalculator) CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error) {
+	if err := validateSpectra(lc.validator, voltageFFT, currentFFT); err != nil {
+		return signal.ImpedanceData{}, config.NewProcessingError("spectrum validation", err)
+	}
+
+	var frequencies []float64
+	var impedance []complex128
+	for i, frequency := range voltageFFT.Frequencies {
+		voltage, current := voltageFFT.Values[i], currentFFT.Values[i]
+		if cmplx.Abs(current) < 1e-10 {
+			continue
+		}
+
+		z := voltage / current
+		if cmplx.IsNaN(z) || cmplx.IsInf(z) {
+			return signal.ImpedanceData{}, config.NewProcessingError("impedance calculation",
+				config.NewValidationError("Impedance", fmt.Sprintf("invalid impedance value at %g Hz", frequency)))
+		}
+
+		frequencies = append(frequencies, frequency)
+		impedance = append(impedance, z)
+	}
+
+	if len(impedance) == 0 {
+		return signal.ImpedanceData{}, config.NewProcessingError("impedance calculation",
+			config.NewValidationError("Frequencies", "no frequency with measurable current"))
+	}
+
+	result := signal.NewImpedanceData(voltageFFT.Timestamp, frequencies, impedance)
+
+	if err := lc.validator.ValidateImpedanceData(result); err != nil {
+		return signal.ImpedanceData{}, config.NewProcessingError("impedance data validation", err)
+	}
+
+	return result, nil
+}
+
+// ProcessEISMeasurement performs a complete lock-in EIS measurement
+func (lc *LockInCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
+	impedanceData, err := lc.CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.EISMeasurement{}, config.NewProcessingError("impedance calculation", err)
+	}
+
+	return impedanceData.ToPoints(), nil
+}
+
+// demodulate returns the complex amplitude of sig at frequency, i.e. the
+// in-phase and quadrature averages of the mean-removed signal multiplied by
+// reference cosine and sine waves. Evenly spaced signals are referred to their
+// first sample, so origin is not used.
+func demodulate(sig signal.Signal, _ time.Time, frequency float64) complex128 {
+	mean := 0.0
+	for _, v := range sig.Values {
+		mean += v
+	}
+	mean /= float64(len(sig.Values))
+
+	var inPhase, quadrature float64
+	omega := 2 * math.Pi * frequency / sig.SampleRate
+	for i, v := range sig.Values {
+		sin, cos := math.Sincos(omega * float64(i))
+		inPhase += (v - mean) * cos
+		quadrature -= (v - mean) * sin
+	}
+
+	scale := 2 / float64(len(sig.Values))
+	return complex(inPhase*scale, quadrature*scale)
+}
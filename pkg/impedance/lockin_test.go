@@ -0,0 +1,64 @@
+package impedance
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestLockInCalculator_RecoversImpedance(t *testing.T) {
+	const sampleRate = 1000.0
+	frequencies := []float64{5, 20, 50}
+	want := map[float64]complex128{
+		5:  cmplx.Rect(30, -0.6),
+		20: cmplx.Rect(18, -0.3),
+		50: cmplx.Rect(11, -0.1),
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	voltage := make([]float64, 1000)
+	current := make([]float64, 1000)
+	for i := range voltage {
+		tm := float64(i) / sampleRate
+		for _, f := range frequencies {
+			z := want[f]
+			voltage[i] += 0.1 * math.Sin(2*math.Pi*f*tm)
+			current[i] += 0.1 / cmplx.Abs(z) * math.Sin(2*math.Pi*f*tm-cmplx.Phase(z))
+		}
+		voltage[i] += 1.0 + 0.01*rng.NormFloat64()
+		current[i] += 0.05 + 0.0005*rng.NormFloat64()
+	}
+
+	now := time.Now()
+	voltageSignal := signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate}
+	currentSignal := signal.Signal{Timestamp: now, Values: current, SampleRate: sampleRate}
+
+	tests := []struct {
+		name        string
+		frequencies []float64
+	}{
+		{name: "configured frequencies", frequencies: frequencies},
+		{name: "detected frequencies", frequencies: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewLockInCalculator(tt.frequencies).CalculateImpedance(voltageSignal, currentSignal)
+			if err != nil {
+				t.Fatalf("CalculateImpedance() error = %v", err)
+			}
+			if len(result.Frequencies) != len(frequencies) {
+				t.Fatalf("expected %d frequencies, got %v", len(frequencies), result.Frequencies)
+			}
+			for i, f := range result.Frequencies {
+				if relErr := cmplx.Abs(result.Impedance[i]-want[f]) / cmplx.Abs(want[f]); relErr > 0.02 {
+					t.Errorf("%g Hz: expected %v, got %v (relative error %.3f)", f, want[f], result.Impedance[i], relErr)
+				}
+			}
+		})
+	}
+}
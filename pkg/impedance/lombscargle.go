@@ -0,0 +1,71 @@
+package impedance
+
+import (
+	"math"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// NewLombScargleCalculator creates an impedance calculator for irregularly
+// sampled signals. Like the lock-in calculator it evaluates voltage and current
+// at each excitation frequency, detected from the voltage spectrum when none
+// are given, but the amplitudes come from a generalized Lomb-Scargle
+// periodogram: a least squares fit of a sine, a cosine and an offset at the
+// actual sample times (Signal.SampleTimes), which are not assumed to be evenly
+// spaced. Signals without sample times are taken as evenly spaced.
+// Sample times of the current are measured from the voltage timestamp, so the
+// jitter of each file's first sample does not become a phase error.
+func NewLombScargleCalculator(frequencies []float64) Calculator {
+	return &LockInCalculator{
+		frequencies: frequencies,
+		validator:   signal.NewValidator(),
+		demodulate:  lombScargle,
+	}
+}
+
+// sampleTime returns the time of sample i in seconds since origin. Evenly
+// spaced signals without sample times are referred to their first sample.
+func sampleTime(sig signal.Signal, origin time.Time, i int) float64 {
+	if len(sig.SampleTimes) == len(sig.Values) {
+		return sig.Timestamp.Sub(origin).Seconds() + sig.SampleTimes[i]
+	}
+	return float64(i) / sig.SampleRate
+}
+
+// lombScargle returns the complex amplitude of sig at frequency from the least
+// squares fit v(t) ≈ a·cos(ωt) + b·sin(ωt) + c at the sample times. The
+// amplitude a - ib follows the convention of demodulate, with which it agrees
+// for evenly spaced samples over whole periods.
+func lombScargle(sig signal.Signal, origin time.Time, frequency float64) complex128 {
+	n := float64(len(sig.Values))
+	omega := 2 * math.Pi * frequency
+
+	// Sums of the normal equations, centred so the offset c drops out
+	var sumC, sumS, sumY, sumCC, sumSS, sumCS, sumYC, sumYS float64
+	for i, v := range sig.Values {
+		sin, cos := math.Sincos(omega * sampleTime(sig, origin, i))
+		sumC += cos
+		sumS += sin
+		sumY += v
+		sumCC += cos * cos
+		sumSS += sin * sin
+		sumCS += cos * sin
+		sumYC += v * cos
+		sumYS += v * sin
+	}
+	meanC, meanS, meanY := sumC/n, sumS/n, sumY/n
+	cc := sumCC/n - meanC*meanC
+	ss := sumSS/n - meanS*meanS
+	cs := sumCS/n - meanC*meanS
+	yc := sumYC/n - meanY*meanC
+	ys := sumYS/n - meanY*meanS
+
+	determinant := cc*ss - cs*cs
+	if determinant <= 1e-12 {
+		return 0 // Sample times cannot tell the sine from the cosine at this frequency
+	}
+	a := (yc*ss - ys*cs) / determinant
+	b := (ys*cc - yc*cs) / determinant
+	return complex(a, -b)
+}
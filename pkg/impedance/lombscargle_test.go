@@ -0,0 +1,134 @@
+package impedance
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestLombScargleCalculator_JitteredSampling(t *testing.T) {
+	const sampleRate = 1000.0
+	tests := []struct {
+		frequency float64
+		want      complex128
+	}{
+		{frequency: 20, want: cmplx.Rect(30, -0.6)},
+		{frequency: 150, want: cmplx.Rect(18, -0.3)},
+		{frequency: 350, want: cmplx.Rect(11, -0.1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%g Hz", tt.frequency), func(t *testing.T) {
+			// Voltage and current loggers with independent jitter of up to ±40% of a period
+			rng := rand.New(rand.NewSource(1))
+			now := time.Now()
+			sample := func(amplitude, phase float64) signal.Signal {
+				sig := signal.Signal{Timestamp: now, Values: make([]float64, 1000), SampleTimes: make([]float64, 1000), SampleRate: sampleRate}
+				for i := range sig.Values {
+					tm := (float64(i) + 0.8*(rng.Float64()-0.5)) / sampleRate
+					sig.SampleTimes[i] = tm
+					sig.Values[i] = 1 + amplitude*math.Sin(2*math.Pi*tt.frequency*tm+phase)
+				}
+				return sig
+			}
+			voltageSignal := sample(0.1, 0)
+			currentSignal := sample(0.1/cmplx.Abs(tt.want), -cmplx.Phase(tt.want))
+
+			result, err := NewLombScargleCalculator([]float64{tt.frequency}).CalculateImpedance(voltageSignal, currentSignal)
+			if err != nil {
+				t.Fatalf("CalculateImpedance() error = %v", err)
+			}
+			if relErr := cmplx.Abs(result.Impedance[0]-tt.want) / cmplx.Abs(tt.want); relErr > 1e-6 {
+				t.Errorf("expected %v, got %v (relative error %.2g)", tt.want, result.Impedance[0], relErr)
+			}
+
+			// Assuming even sampling misplaces every sample by its jitter
+			lockIn, err := NewLockInCalculator([]float64{tt.frequency}).CalculateImpedance(voltageSignal, currentSignal)
+			if err != nil {
+				t.Fatalf("lock-in CalculateImpedance() error = %v", err)
+			}
+			if relErr := cmplx.Abs(lockIn.Impedance[0]-tt.want) / cmplx.Abs(tt.want); tt.frequency > 100 && relErr < 0.01 {
+				t.Errorf("expected the lock-in estimate %v to suffer from the jitter", lockIn.Impedance[0])
+			}
+		})
+	}
+}
+
+func TestLombScargleCalculator_LoadedFilesWithDifferentFirstSampleJitter(t *testing.T) {
+	const sampleRate, frequency = 1000.0, 150.0
+	want := cmplx.Rect(18, -0.3)
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+
+	// The first voltage sample comes late and the first current sample early,
+	// so the loaded signals have timestamps 0.6 ms apart
+	rng := rand.New(rand.NewSource(3))
+	writeFile := func(name string, firstJitter, amplitude, phase float64) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, "timestamp,time_offset,%s\n", name)
+		for i := 0; i < 1000; i++ {
+			jitter := 0.4 * (rng.Float64() - 0.5)
+			if i == 0 {
+				jitter = firstJitter
+			}
+			tm := (float64(i) + jitter) / sampleRate
+			value := 1 + amplitude*math.Sin(2*math.Pi*frequency*tm+phase)
+			fmt.Fprintf(&b, "%s,%.9f,%.12f\n", start.Add(time.Duration(tm*float64(time.Second))).Format(time.RFC3339Nano), tm, value)
+		}
+		path := filepath.Join(t.TempDir(), name+".csv")
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	voltageFile := writeFile("voltage", 0.3, 0.1, 0)
+	currentFile := writeFile("current", -0.3, 0.1/cmplx.Abs(want), -cmplx.Phase(want))
+
+	loader := signal.NewDataLoaderWithOptions(signal.LoaderOptions{Dialect: signal.DefaultCSVDialect, SampleTimes: true})
+	voltageSignals, currentSignals, err := loader.LoadVoltageAndCurrentFromCSV(voltageFile, currentFile, sampleRate)
+	if err != nil {
+		t.Fatalf("LoadVoltageAndCurrentFromCSV() error = %v", err)
+	}
+
+	result, err := NewLombScargleCalculator([]float64{frequency}).CalculateImpedance(voltageSignals[0], currentSignals[0])
+	if err != nil {
+		t.Fatalf("CalculateImpedance() error = %v", err)
+	}
+	if relErr := cmplx.Abs(result.Impedance[0]-want) / cmplx.Abs(want); relErr > 1e-4 {
+		t.Errorf("expected %v, got %v (relative error %.2g)", want, result.Impedance[0], relErr)
+	}
+}
+
+func TestLombScargleCalculator_MatchesLockInOnEvenSampling(t *testing.T) {
+	const sampleRate = 1000.0
+	rng := rand.New(rand.NewSource(2))
+	voltage := make([]float64, 1000)
+	current := make([]float64, 1000)
+	for i := range voltage {
+		tm := float64(i) / sampleRate
+		voltage[i] = 1 + 0.1*math.Sin(2*math.Pi*10*tm) + 0.01*rng.NormFloat64()
+		current[i] = 0.05 + 0.004*math.Sin(2*math.Pi*10*tm-0.4) + 0.0005*rng.NormFloat64()
+	}
+	now := time.Now()
+	voltageSignal := signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate}
+	currentSignal := signal.Signal{Timestamp: now, Values: current, SampleRate: sampleRate}
+
+	lockIn, err := NewLockInCalculator([]float64{10}).CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		t.Fatalf("lock-in CalculateImpedance() error = %v", err)
+	}
+	lombScargle, err := NewLombScargleCalculator([]float64{10}).CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		t.Fatalf("Lomb-Scargle CalculateImpedance() error = %v", err)
+	}
+	if diff := cmplx.Abs(lombScargle.Impedance[0] - lockIn.Impedance[0]); diff > 1e-3*cmplx.Abs(lockIn.Impedance[0]) {
+		t.Errorf("expected Lomb-Scargle %v to match lock-in %v", lombScargle.Impedance[0], lockIn.Impedance[0])
+	}
+}
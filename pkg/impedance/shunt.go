@@ -0,0 +1,186 @@
+package impedance
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Shunt is the known impedance across which the current channel measures a
+// voltage, either constant or a calibration table interpolated linearly in
+// log frequency
+type Shunt struct {
+	Constant    complex128
+	Frequencies []float64    // Calibration frequencies in Hz, empty for a constant shunt
+	Impedance   []complex128 // Calibrated impedance at Frequencies
+}
+
+// ParseShunt parses a shunt given as resistance in ohms ("10"), complex
+// impedance in ohms ("10+0.2i") or a calibration CSV file with a header and
+// the columns frequency in Hz, real and imaginary part in ohms
+func ParseShunt(spec string) (Shunt, error) {
+	if resistance, err := strconv.ParseFloat(spec, 64); err == nil {
+		return newConstantShunt(complex(resistance, 0))
+	}
+	if impedance, err := strconv.ParseComplex(spec, 128); err == nil {
+		return newConstantShunt(impedance)
+	}
+	if _, err := os.Stat(spec); err != nil {
+		return Shunt{}, config.NewValidationError("Shunt", fmt.Sprintf("'%s' is neither an impedance in ohms nor a calibration file", spec))
+	}
+	shunt, err := LoadShunt(spec)
+	if err != nil {
+		return Shunt{}, config.NewValidationError("Shunt", err.Error())
+	}
+	return shunt, nil
+}
+
+// newConstantShunt checks that a constant shunt carries measurable current
+func newConstantShunt(impedance complex128) (Shunt, error) {
+	if impedance == 0 {
+		return Shunt{}, config.NewValidationError("Shunt", "shunt impedance cannot be zero")
+	}
+	return Shunt{Constant: impedance}, nil
+}
+
+// LoadShunt reads a shunt calibration CSV file with a header and the columns
+// frequency in Hz, real and imaginary part of the impedance in ohms.
+// Frequencies must be strictly increasing.
+func LoadShunt(path string) (Shunt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Shunt{}, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	if _, err := reader.Read(); err != nil {
+		return Shunt{}, fmt.Errorf("shunt calibration %s: %w", path, err)
+	}
+	var shunt Shunt
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Shunt{}, fmt.Errorf("shunt calibration %s: %w", path, err)
+		}
+		var values [3]float64
+		for i, field := range record {
+			if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+				return Shunt{}, fmt.Errorf("shunt calibration %s: invalid number '%s'", path, field)
+			}
+		}
+		if values[0] <= 0 || (values[1] == 0 && values[2] == 0) {
+			return Shunt{}, fmt.Errorf("shunt calibration %s: frequency must be positive and impedance non-zero", path)
+		}
+		if n := len(shunt.Frequencies); n > 0 && values[0] <= shunt.Frequencies[n-1] {
+			return Shunt{}, fmt.Errorf("shunt calibration %s: frequencies must be strictly increasing, %g Hz follows %g Hz", path, values[0], shunt.Frequencies[n-1])
+		}
+		shunt.Frequencies = append(shunt.Frequencies, values[0])
+		shunt.Impedance = append(shunt.Impedance, complex(values[1], values[2]))
+	}
+	if len(shunt.Frequencies) == 0 {
+		return Shunt{}, fmt.Errorf("shunt calibration %s has no points", path)
+	}
+	return shunt, nil
+}
+
+// ImpedanceAt returns the shunt impedance at f; calibrations hold their edge
+// values outside the calibrated range
+func (s Shunt) ImpedanceAt(f float64) complex128 {
+	if len(s.Frequencies) == 0 {
+		return s.Constant
+	}
+	return signal.InterpolateImpedance(s.Frequencies, s.Impedance, []float64{f})[0]
+}
+
+// String describes the shunt for logs
+func (s Shunt) String() string {
+	if len(s.Frequencies) == 0 {
+		if imag(s.Constant) == 0 {
+			return fmt.Sprintf("%g Ω", real(s.Constant))
+		}
+		return fmt.Sprintf("%v Ω", s.Constant)
+	}
+	return fmt.Sprintf("calibration of %d points from %g to %g Hz", len(s.Frequencies), s.Frequencies[0], s.Frequencies[len(s.Frequencies)-1])
+}
+
+// ShuntCalculator wraps a Calculator for setups measuring the current as the
+// voltage U_s across a known shunt: the current spectrum is I = U_s/Z_s and
+// the device impedance Z = U/U_s · Z_s. The voltage channel must measure the
+// device alone, not the device and shunt in series.
+type ShuntCalculator struct {
+	Calculator
+	shunt Shunt
+}
+
+// NewShuntCalculator creates a calculator converting the shunt voltage of
+// the current channel into current
+func NewShuntCalculator(calculator Calculator, shunt Shunt) *ShuntCalculator {
+	return &ShuntCalculator{Calculator: calculator, shunt: shunt}
+}
+
+// CalculateImpedance computes the device impedance
+func (sc *ShuntCalculator) CalculateImpedance(voltageSignal, currentSignal signal.Signal) (signal.ImpedanceData, error) {
+	measurement, err := sc.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+	return measurement.Impedance, nil
+}
+
+// Measure transforms the signals with the wrapped calculator and converts
+// the shunt voltage spectrum into the current spectrum. The impedance is
+// scaled by Z_s instead of being recomputed, which would apply the
+// corrections of wrapped calculators a second time.
+func (sc *ShuntCalculator) Measure(voltageSignal, currentSignal signal.Signal) (Measurement, error) {
+	measurement, err := sc.Calculator.Measure(voltageSignal, currentSignal)
+	if err != nil {
+		return measurement, err
+	}
+	measurement.Current = divideResponse(measurement.Current, sc.shunt.ImpedanceAt)
+	measurement.Impedance = sc.scale(measurement.Impedance)
+	return measurement, nil
+}
+
+// CalculateImpedanceFromSpectra divides the voltage by the shunt voltage
+// spectrum and scales the result by Z_s
+func (sc *ShuntCalculator) CalculateImpedanceFromSpectra(voltageFFT, currentFFT signal.ComplexSignal) (signal.ImpedanceData, error) {
+	impedanceData, err := sc.Calculator.CalculateImpedanceFromSpectra(voltageFFT, currentFFT)
+	if err != nil {
+		return signal.ImpedanceData{}, err
+	}
+	return sc.scale(impedanceData), nil
+}
+
+// scale returns the impedance U/U_s multiplied by Z_s at every frequency,
+// with magnitude and phase recomputed
+func (sc *ShuntCalculator) scale(data signal.ImpedanceData) signal.ImpedanceData {
+	impedance := make([]complex128, len(data.Impedance))
+	for i, z := range data.Impedance {
+		impedance[i] = z * sc.shunt.ImpedanceAt(data.Frequencies[i])
+	}
+	scaled := data
+	scaled.Impedance = impedance
+	scaled.Magnitude, scaled.Phase = scaled.CalculateMagnitudePhase()
+	return scaled
+}
+
+// ProcessEISMeasurement performs a complete EIS measurement with the current
+// recovered from the shunt voltage
+func (sc *ShuntCalculator) ProcessEISMeasurement(voltageSignal, currentSignal signal.Signal) (signal.EISMeasurement, error) {
+	impedanceData, err := sc.CalculateImpedance(voltageSignal, currentSignal)
+	if err != nil {
+		return signal.EISMeasurement{}, config.NewProcessingError("impedance calculation", err)
+	}
+	return impedanceData.ToPoints(), nil
+}
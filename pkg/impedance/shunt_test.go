@@ -0,0 +1,87 @@
+package impedance
+
+import (
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestParseShunt(t *testing.T) {
+	calibration := filepath.Join(t.TempDir(), "shunt.csv")
+	os.WriteFile(calibration, []byte("frequency_hz,real_ohm,imag_ohm\n10,1,0\n1000,1,-2\n"), 0644)
+	unordered := filepath.Join(t.TempDir(), "unordered.csv")
+	os.WriteFile(unordered, []byte("frequency_hz,real_ohm,imag_ohm\n1000,1,-2\n10,1,0\n10,1,0\n"), 0644)
+
+	tests := []struct {
+		spec    string
+		at      float64
+		want    complex128
+		wantErr bool
+	}{
+		{"0.1", 50, 0.1, false},
+		{"10+0.2i", 50, complex(10, 0.2), false},
+		{calibration, 100, complex(1, -1), false},
+		{calibration, 1, 1, false},
+		{"0", 0, 0, true},
+		{"missing.csv", 0, 0, true},
+		{unordered, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			shunt, err := ParseShunt(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseShunt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cmplx.Abs(shunt.ImpedanceAt(tt.at)-tt.want) > 1e-9 {
+				t.Errorf("ImpedanceAt(%g) = %v, want %v", tt.at, shunt.ImpedanceAt(tt.at), tt.want)
+			}
+		})
+	}
+}
+
+func TestShuntCalculator(t *testing.T) {
+	const sampleRate = 8192.0
+	const resistance = 10.0
+	shunt := Shunt{Frequencies: []float64{1, 1000}, Impedance: []complex128{0.5, complex(0.5, -0.5)}}
+
+	// Tones across a resistor, the current seen as voltage across the shunt
+	frequencies := []float64{1, 10, 100, 250, 500}
+	voltage := make([]float64, 8192)
+	shuntVoltage := make([]float64, 8192)
+	for i := range voltage {
+		tm := float64(i) / sampleRate
+		for _, f := range frequencies {
+			zs := shunt.ImpedanceAt(f)
+			voltage[i] += math.Sin(2 * math.Pi * f * tm)
+			shuntVoltage[i] += cmplx.Abs(zs) / resistance * math.Sin(2*math.Pi*f*tm+cmplx.Phase(zs))
+		}
+	}
+	now := time.Unix(0, 0)
+	voltageSignal := signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate}
+	currentSignal := signal.Signal{Timestamp: now, Values: shuntVoltage, SampleRate: sampleRate}
+
+	measurement, err := NewShuntCalculator(NewCalculator(), shunt).Measure(voltageSignal, currentSignal)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	for i, f := range measurement.Impedance.Frequencies {
+		switch f {
+		case 1, 10, 100, 250, 500:
+			if err := cmplx.Abs(measurement.Impedance.Impedance[i] - resistance); err > 0.01 {
+				t.Errorf("%g Hz: Z = %v, want %g ohm", f, measurement.Impedance.Impedance[i], resistance)
+			}
+			if magnitude := measurement.Impedance.Magnitude[i]; math.Abs(magnitude-resistance) > 0.01 {
+				t.Errorf("%g Hz: |Z| = %g, want %g ohm", f, magnitude, resistance)
+			}
+			if ratio := cmplx.Abs(measurement.Voltage.Values[i] / measurement.Current.Values[i]); math.Abs(ratio-resistance) > 0.01 {
+				t.Errorf("%g Hz: |U/I| = %g, want the current recovered from the shunt voltage", f, ratio)
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package inference
+
+import (
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Classifier runs a model on the feature vector of a spectrum
+type Classifier interface {
+	Classify(features signal.SpectrumFeatures) (signal.Prediction, error)
+	Close() error
+}
@@ -0,0 +1,80 @@
+//go:build onnx
+
+package inference
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// ONNXClassifier runs an ONNX model through onnxruntime
+type ONNXClassifier struct {
+	options Options
+	session *ort.DynamicAdvancedSession
+	outputs int
+	mu      sync.Mutex
+}
+
+// NewONNXClassifier loads the model and initializes onnxruntime
+func NewONNXClassifier(options Options) (Classifier, error) {
+	if options.LibraryPath != "" {
+		ort.SetSharedLibraryPath(options.LibraryPath)
+	}
+	if !ort.IsInitialized() {
+		if err := ort.InitializeEnvironment(); err != nil {
+			return nil, config.NewProcessingError("onnxruntime initialization", err)
+		}
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(options.ModelPath)
+	if err != nil {
+		return nil, config.NewProcessingError("model loading", err)
+	}
+	if len(inputs) != 1 || len(outputs) < 1 {
+		return nil, config.NewValidationError("Model", fmt.Sprintf("%s must have one input and at least one output", options.ModelPath))
+	}
+	dims := outputs[0].Dimensions
+	if len(dims) != 2 || dims[1] <= 0 {
+		return nil, config.NewValidationError("Model", fmt.Sprintf("%s output must be a 1×C tensor, got %v", options.ModelPath, dims))
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(options.ModelPath,
+		[]string{inputs[0].Name}, []string{outputs[0].Name}, nil)
+	if err != nil {
+		return nil, config.NewProcessingError("model loading", err)
+	}
+	return &ONNXClassifier{options: options, session: session, outputs: int(dims[1])}, nil
+}
+
+// Classify runs the model on the features of one spectrum
+func (c *ONNXClassifier) Classify(features signal.SpectrumFeatures) (signal.Prediction, error) {
+	data := c.options.input(features)
+	input, err := ort.NewTensor(ort.NewShape(1, int64(len(data))), data)
+	if err != nil {
+		return signal.Prediction{}, config.NewProcessingError("model inference", err)
+	}
+	defer input.Destroy()
+	output, err := ort.NewEmptyTensor[float32](ort.NewShape(1, int64(c.outputs)))
+	if err != nil {
+		return signal.Prediction{}, config.NewProcessingError("model inference", err)
+	}
+	defer output.Destroy()
+
+	c.mu.Lock()
+	err = c.session.Run([]ort.Value{input}, []ort.Value{output})
+	c.mu.Unlock()
+	if err != nil {
+		return signal.Prediction{}, config.NewProcessingError("model inference", err)
+	}
+	return c.options.prediction(output.GetData()), nil
+}
+
+// Close releases the session
+func (c *ONNXClassifier) Close() error {
+	return c.session.Destroy()
+}
@@ -0,0 +1,16 @@
+//go:build !onnx
+
+package inference
+
+import (
+	"fmt"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// NewONNXClassifier is unavailable without the onnx build tag, which links
+// onnxruntime through cgo
+func NewONNXClassifier(options Options) (Classifier, error) {
+	return nil, config.NewProcessingError("model loading",
+		fmt.Errorf("built without ONNX support, rebuild with 'go build -tags onnx'"))
+}
@@ -0,0 +1,74 @@
+package inference
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/dataset"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Options configures a model. The model takes a 1×F float32 tensor of the
+// features in signal.FeatureNames order and returns a 1×C float32 tensor of
+// class scores (C = 1 for anomaly scores).
+type Options struct {
+	ModelPath   string
+	LibraryPath string    // onnxruntime shared library; empty uses the platform default name
+	Labels      []string  // Class names by score index; optional
+	Mean        []float64 // Feature means subtracted before inference; empty = none
+	Std         []float64 // Feature standard deviations divided by before inference
+}
+
+// LoadNormalization reads the feature mean and standard deviation of a dataset
+// description written by the dataset command, so features are scaled exactly
+// as during training
+func (o *Options) LoadNormalization(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return config.NewProcessingError("model normalization", err)
+	}
+	var description dataset.Description
+	if err := json.Unmarshal(content, &description); err != nil {
+		return config.NewProcessingError("model normalization", fmt.Errorf("%s: %w", path, err))
+	}
+	if len(description.FeatureMean) != len(signal.FeatureNames) || len(description.FeatureStd) != len(signal.FeatureNames) {
+		return config.NewValidationError("ModelNormalization",
+			fmt.Sprintf("%s must hold %d feature means and standard deviations", path, len(signal.FeatureNames)))
+	}
+	o.Mean, o.Std = description.FeatureMean, description.FeatureStd
+	if len(o.Labels) == 0 {
+		o.Labels = description.LabelNames
+	}
+	return nil
+}
+
+// input returns the normalized model input of the features
+func (o Options) input(features signal.SpectrumFeatures) []float32 {
+	vector := features.Vector()
+	input := make([]float32, len(vector))
+	for i, v := range vector {
+		if len(o.Mean) == len(vector) && len(o.Std) == len(vector) {
+			v = (v - o.Mean[i]) / o.Std[i]
+		}
+		input[i] = float32(v)
+	}
+	return input
+}
+
+// prediction converts model scores into a prediction
+func (o Options) prediction(scores []float32) signal.Prediction {
+	prediction := signal.Prediction{Model: filepath.Base(o.ModelPath), Scores: make([]float64, len(scores))}
+	for i, score := range scores {
+		prediction.Scores[i] = float64(score)
+		if score > scores[prediction.Class] {
+			prediction.Class = i
+		}
+	}
+	if prediction.Class < len(o.Labels) {
+		prediction.Label = o.Labels[prediction.Class]
+	}
+	return prediction
+}
@@ -0,0 +1,60 @@
+package inference
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/dataset"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestOptions_NormalizationAndPrediction(t *testing.T) {
+	description := dataset.Description{
+		FeatureMean: []float64{10, 30, 20, 8, 0},
+		FeatureStd:  []float64{1, 2, 2, 4, 1},
+		LabelNames:  []string{"healthy", "aged"},
+	}
+	content, err := json.Marshal(description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "train.json")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options := Options{ModelPath: "/models/health.onnx"}
+	if err := options.LoadNormalization(path); err != nil {
+		t.Fatalf("LoadNormalization() error = %v", err)
+	}
+
+	input := options.input(signal.SpectrumFeatures{
+		HighFrequencyIntercept:  11,
+		LowFrequencyIntercept:   34,
+		SemicircleDiameter:      23,
+		CharacteristicFrequency: 8,
+	})
+	want := []float32{1, 2, 1.5, 0, 0}
+	for i := range want {
+		if input[i] != want[i] {
+			t.Errorf("input[%d] = %v, want %v", i, input[i], want[i])
+		}
+	}
+
+	prediction := options.prediction([]float32{0.2, 0.8})
+	if prediction.Class != 1 || prediction.Label != "aged" || prediction.Model != "health.onnx" {
+		t.Errorf("prediction = %+v, want class 1 'aged' of health.onnx", prediction)
+	}
+
+	if err := (&Options{}).LoadNormalization(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("expected error for missing description")
+	}
+}
+
+func TestNewONNXClassifier_WithoutRuntime(t *testing.T) {
+	if _, err := NewONNXClassifier(Options{ModelPath: filepath.Join(t.TempDir(), "missing.onnx")}); err == nil {
+		t.Errorf("expected error for unavailable model")
+	}
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRequestBytes limits the size of a job submission
+const maxRequestBytes = 1 << 20
+
+// submission is the body of POST /jobs
+type submission struct {
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Handler returns the REST API of the queue, to be mounted at the root:
+//
+//	POST   /jobs              submit {"kind": ..., "params": {...}}
+//	GET    /jobs              list jobs, newest first
+//	GET    /jobs/{id}         job status
+//	GET    /jobs/{id}/result  result of a succeeded job
+//	GET    /jobs/{id}/log     job log as plain text
+//	DELETE /jobs/{id}         cancel a queued or running job
+func (q *Queue) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", q.handleSubmit)
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, q.List())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := q.Get(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("GET /jobs/{id}/result", q.handleResult)
+	mux.HandleFunc("GET /jobs/{id}/log", func(w http.ResponseWriter, r *http.Request) {
+		output, ok := q.Log(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(output)
+	})
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !q.Cancel(r.PathValue("id")) {
+			http.NotFound(w, r)
+			return
+		}
+		job, _ := q.Get(r.PathValue("id"))
+		writeJSON(w, http.StatusAccepted, job)
+	})
+	return mux
+}
+
+// handleSubmit queues the submitted job
+func (q *Queue) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var request submission
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job request: %v", err), http.StatusBadRequest)
+		return
+	}
+	job, err := q.Submit(request.Kind, request.Params)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleResult serves the result of a succeeded job
+func (q *Queue) handleResult(w http.ResponseWriter, r *http.Request) {
+	result, job, ok := q.Result(r.PathValue("id"))
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case job.Status == StatusQueued || job.Status == StatusRunning:
+		http.Error(w, fmt.Sprintf("job is %s", job.Status), http.StatusConflict)
+	case job.Status != StatusSucceeded:
+		http.Error(w, fmt.Sprintf("job %s: %s", job.Status, job.Error), http.StatusConflict)
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,291 @@
+// Package jobs runs submitted processing jobs with a concurrency limit and
+// serves their status, logs and results over REST.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrQueueFull is returned by Submit when the queue is at capacity
+var ErrQueueFull = errors.New("job queue is full")
+
+// maxLogBytes is the size of the log kept per job; older output is dropped
+const maxLogBytes = 1 << 20
+
+// Runner executes one kind of job. params are the parameters of the request
+// and logger writes to the job log. The result is served as JSON.
+type Runner func(ctx context.Context, params json.RawMessage, logger *log.Logger) (interface{}, error)
+
+// Job describes a submitted job
+type Job struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Status    string          `json:"status"`
+	Error     string          `json:"error,omitempty"`
+	Submitted time.Time       `json:"submitted"`
+	Started   time.Time       `json:"started,omitzero"`
+	Finished  time.Time       `json:"finished,omitzero"`
+}
+
+// job is a Job together with its log, result and cancellation
+type job struct {
+	Job
+	log    logBuffer
+	result interface{}
+	cancel context.CancelFunc
+}
+
+// Options configures a Queue
+type Options struct {
+	Concurrency int // Jobs running at the same time
+	Capacity    int // Jobs waiting to run before submissions are refused
+	Keep        int // Finished jobs kept for status and result queries
+}
+
+// Queue runs submitted jobs on a fixed number of workers
+type Queue struct {
+	options  Options
+	runners  map[string]Runner
+	pending  chan *job
+	mu       sync.Mutex
+	jobs     map[string]*job
+	finished []string // IDs of finished jobs, oldest first
+}
+
+// NewQueue creates a queue running the given kinds of jobs
+func NewQueue(options Options, runners map[string]Runner) (*Queue, error) {
+	if options.Concurrency < 1 {
+		return nil, config.NewValidationError("Concurrency", "at least one job must be allowed to run")
+	}
+	if options.Capacity < 1 {
+		return nil, config.NewValidationError("Capacity", "at least one job must be allowed to wait")
+	}
+	if options.Keep < 1 {
+		return nil, config.NewValidationError("Keep", "at least one finished job must be kept")
+	}
+	if len(runners) == 0 {
+		return nil, config.NewValidationError("Runners", "no job kinds registered")
+	}
+	return &Queue{
+		options: options,
+		runners: runners,
+		pending: make(chan *job, options.Capacity),
+		jobs:    make(map[string]*job),
+	}, nil
+}
+
+// Kinds returns the names of the registered job kinds
+func (q *Queue) Kinds() []string {
+	kinds := make([]string, 0, len(q.runners))
+	for kind := range q.runners {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Submit queues a job of kind
+func (q *Queue) Submit(kind string, params json.RawMessage) (Job, error) {
+	if _, ok := q.runners[kind]; !ok {
+		return Job{}, config.NewValidationError("Kind", fmt.Sprintf("unknown job kind '%s' (known: %v)", kind, q.Kinds()))
+	}
+	j := &job{Job: Job{
+		ID:        signal.NewUUID(),
+		Kind:      kind,
+		Params:    params,
+		Status:    StatusQueued,
+		Submitted: time.Now(),
+	}}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[j.ID] = j
+	return j.Job, nil
+}
+
+// Get returns the job with id
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// List returns all known jobs, most recently submitted first
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		list = append(list, j.Job)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Submitted.After(list[b].Submitted) })
+	return list
+}
+
+// Result returns the result of a finished job
+func (q *Queue) Result(id string) (interface{}, Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, Job{}, false
+	}
+	return j.result, j.Job, true
+}
+
+// Log returns the log output of a job
+func (q *Queue) Log(id string) ([]byte, bool) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return j.log.Bytes(), true
+}
+
+// Cancel stops a running job or drops a queued one. It reports whether the
+// job exists.
+func (q *Queue) Cancel(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return false
+	}
+	switch j.Status {
+	case StatusQueued:
+		q.finishLocked(j, StatusCancelled, nil)
+	case StatusRunning:
+		j.cancel()
+	}
+	return true
+}
+
+// Run starts the workers and blocks until ctx is cancelled. Running jobs are
+// cancelled with ctx.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-q.pending:
+					q.run(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// run executes one job unless it was cancelled while queued
+func (q *Queue) run(ctx context.Context, j *job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	if j.Status != StatusQueued {
+		q.mu.Unlock()
+		return
+	}
+	j.Status = StatusRunning
+	j.Started = time.Now()
+	j.cancel = cancel
+	runner := q.runners[j.Kind]
+	q.mu.Unlock()
+
+	logger := log.New(io.MultiWriter(&j.log, log.Writer()), fmt.Sprintf("[job %s] ", j.ID[:8]), log.LstdFlags)
+	logger.Printf("Started %s job", j.Kind)
+	result, err := runner(jobCtx, j.Params, logger)
+	status := StatusSucceeded
+	switch {
+	case jobCtx.Err() != nil:
+		status, err = StatusCancelled, nil
+	case err != nil:
+		status = StatusFailed
+	}
+	if err != nil {
+		logger.Printf("Failed: %v", err)
+	} else {
+		logger.Printf("Finished: %s", status)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.result = result
+	q.finishLocked(j, status, err)
+}
+
+// finishLocked records the outcome of j and forgets the oldest finished jobs
+// beyond the limit. It must be called with mu held.
+func (q *Queue) finishLocked(j *job, status string, err error) {
+	j.Status = status
+	j.Finished = time.Now()
+	if err != nil {
+		j.Error = err.Error()
+	}
+	q.finished = append(q.finished, j.ID)
+	if len(q.finished) > q.options.Keep {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
+
+// logBuffer keeps the last maxLogBytes of a job log
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.buf.Write(p)
+	if excess := lb.buf.Len() - maxLogBytes; excess > 0 {
+		lb.buf.Next(excess)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the log
+func (lb *logBuffer) Bytes() []byte {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return append([]byte(nil), lb.buf.Bytes()...)
+}
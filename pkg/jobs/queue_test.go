@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls the job until it reaches status
+func waitFor(t *testing.T, q *Queue, id, status string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := q.Get(id)
+		if ok && job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %q, want %q", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueue_HTTPLifecycle(t *testing.T) {
+	queue, err := NewQueue(Options{Concurrency: 1, Capacity: 4, Keep: 10}, map[string]Runner{
+		"echo": func(ctx context.Context, params json.RawMessage, logger *log.Logger) (interface{}, error) {
+			logger.Printf("echoing %s", params)
+			return params, nil
+		},
+		"fail": func(ctx context.Context, params json.RawMessage, logger *log.Logger) (interface{}, error) {
+			return nil, errors.New("broken input")
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewQueue() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	server := httptest.NewServer(queue.Handler())
+	defer server.Close()
+
+	submit := func(body string) (*http.Response, Job) {
+		resp, err := http.Post(server.URL+"/jobs", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		defer resp.Body.Close()
+		var job Job
+		json.NewDecoder(resp.Body).Decode(&job)
+		return resp, job
+	}
+
+	resp, job := submit(`{"kind": "echo", "params": {"x": 1}}`)
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/jobs/"+job.ID {
+		t.Fatalf("submit = %d, Location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	waitFor(t, queue, job.ID, StatusSucceeded)
+
+	result, _ := http.Get(server.URL + "/jobs/" + job.ID + "/result")
+	var echoed map[string]int
+	json.NewDecoder(result.Body).Decode(&echoed)
+	result.Body.Close()
+	if result.StatusCode != http.StatusOK || echoed["x"] != 1 {
+		t.Errorf("result = %d %v, want the echoed params", result.StatusCode, echoed)
+	}
+	logs, _ := queue.Log(job.ID)
+	if !strings.Contains(string(logs), `echoing {"x": 1}`) || !strings.Contains(string(logs), "Finished: succeeded") {
+		t.Errorf("log = %q, want the runner output", logs)
+	}
+
+	_, failed := submit(`{"kind": "fail"}`)
+	failedJob := waitFor(t, queue, failed.ID, StatusFailed)
+	if failedJob.Error != "broken input" {
+		t.Errorf("Error = %q, want the runner error", failedJob.Error)
+	}
+	if result, _ := http.Get(server.URL + "/jobs/" + failed.ID + "/result"); result.StatusCode != http.StatusConflict {
+		t.Errorf("result of failed job = %d, want 409", result.StatusCode)
+	}
+
+	if resp, _ := submit(`{"kind": "unknown"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown kind = %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := http.Get(server.URL + "/jobs/missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing job = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestQueue_ConcurrencyLimitAndCancel(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	queue, _ := NewQueue(Options{Concurrency: 2, Capacity: 2, Keep: 10}, map[string]Runner{
+		"block": func(ctx context.Context, params json.RawMessage, logger *log.Logger) (interface{}, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-release:
+				return "done", nil
+			}
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two jobs run, two wait, the fifth does not fit
+	var ids []string
+	for i := 0; i < 2; i++ {
+		job, err := queue.Submit("block", nil)
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	go queue.Run(ctx)
+	waitFor(t, queue, ids[0], StatusRunning)
+	waitFor(t, queue, ids[1], StatusRunning)
+	for i := 0; i < 2; i++ {
+		job, _ := queue.Submit("block", nil)
+		ids = append(ids, job.ID)
+	}
+	if _, err := queue.Submit("block", nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit() beyond capacity error = %v, want ErrQueueFull", err)
+	}
+
+	queue.Cancel(ids[0]) // Running
+	queue.Cancel(ids[3]) // Queued
+	waitFor(t, queue, ids[0], StatusCancelled)
+	waitFor(t, queue, ids[3], StatusCancelled)
+	waitFor(t, queue, ids[2], StatusRunning)
+	close(release)
+	waitFor(t, queue, ids[1], StatusSucceeded)
+	waitFor(t, queue, ids[2], StatusSucceeded)
+	if peak.Load() != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak.Load())
+	}
+}
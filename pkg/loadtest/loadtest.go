@@ -0,0 +1,194 @@
+// Package loadtest drives requests against a collector at a fixed rate and
+// concurrency and summarizes throughput, latency and errors.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Options configures a load test. It ends after Requests requests or after
+// Duration, whichever comes first; a zero limit is disabled.
+type Options struct {
+	Rate        float64       // Requests started per second; 0 = as fast as the workers manage
+	Concurrency int           // Workers sending at the same time
+	Duration    time.Duration // Length of the test
+	Requests    int           // Total requests
+
+	// Classify names the category an error is counted under (default: its text)
+	Classify func(error) string
+}
+
+// Request sends one request and reports its outcome
+type Request func(ctx context.Context) error
+
+// Report summarizes a load test
+type Report struct {
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Elapsed    time.Duration  `json:"elapsed_ns"`
+	Throughput float64        `json:"throughput"` // Successful requests per second
+	ErrorRate  float64        `json:"error_rate"` // Failed share of requests, 0 to 1
+	Mean       time.Duration  `json:"mean_ns"`
+	P50        time.Duration  `json:"p50_ns"`
+	P90        time.Duration  `json:"p90_ns"`
+	P99        time.Duration  `json:"p99_ns"`
+	Max        time.Duration  `json:"max_ns"`
+	ErrorKinds map[string]int `json:"error_kinds,omitempty"`
+}
+
+// Run starts opts.Concurrency workers, each sending the requests created
+// for it by newWorker, until a limit of opts is reached or ctx is done.
+// Latencies cover requests that succeeded and failed alike.
+func Run(ctx context.Context, opts Options, newWorker func(worker int) Request) (*Report, error) {
+	if opts.Concurrency < 1 {
+		return nil, config.NewValidationError("Concurrency", "at least one worker is required")
+	}
+	if opts.Rate < 0 || opts.Duration < 0 || opts.Requests < 0 {
+		return nil, config.NewValidationError("Rate", "rate, duration and request count cannot be negative")
+	}
+	if opts.Duration == 0 && opts.Requests == 0 {
+		return nil, config.NewValidationError("Duration", "a duration or request count is required")
+	}
+	classify := opts.Classify
+	if classify == nil {
+		classify = func(err error) string { return err.Error() }
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	tickets := make(chan struct{})
+	go dispatch(ctx, opts, tickets)
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	report := &Report{ErrorKinds: make(map[string]int)}
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		send := newWorker(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tickets {
+				began := time.Now()
+				err := send(ctx)
+				latency := time.Since(began)
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				report.Requests++
+				if err != nil {
+					report.Errors++
+					report.ErrorKinds[classify(err)]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	summarize(report, latencies)
+	return report, nil
+}
+
+// dispatch hands out one ticket per request at the configured rate and
+// closes tickets when a limit is reached
+func dispatch(ctx context.Context, opts Options, tickets chan<- struct{}) {
+	defer close(tickets)
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.Rate)
+	}
+	next := time.Now()
+	for n := 0; opts.Requests == 0 || n < opts.Requests; n++ {
+		if interval > 0 {
+			// Requests follow a fixed schedule; a late worker does not
+			// lower the offered rate, the backlog starts immediately
+			if wait := time.Until(next); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			next = next.Add(interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case tickets <- struct{}{}:
+		}
+	}
+}
+
+// summarize derives the rates and latency statistics of report
+func summarize(report *Report, latencies []time.Duration) {
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Requests-report.Errors) / report.Elapsed.Seconds()
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	report.Mean = total / time.Duration(len(latencies))
+	report.P50 = Percentile(latencies, 50)
+	report.P90 = Percentile(latencies, 90)
+	report.P99 = Percentile(latencies, 99)
+	report.Max = latencies[len(latencies)-1]
+}
+
+// Percentile returns the nearest-rank percentile p (0 to 100) of sorted
+// latencies
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// String formats the report for the terminal
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Requests:   %d in %s\n", r.Requests, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Throughput: %.1f successful requests/s\n", r.Throughput)
+	fmt.Fprintf(&b, "Errors:     %d (%.2f%%)\n", r.Errors, 100*r.ErrorRate)
+	kinds := make([]string, 0, len(r.ErrorKinds))
+	for kind := range r.ErrorKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(&b, "  %6d  %s\n", r.ErrorKinds[kind], kind)
+	}
+	fmt.Fprintf(&b, "Latency:    mean %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		r.Mean.Round(time.Microsecond), r.P50.Round(time.Microsecond), r.P90.Round(time.Microsecond),
+		r.P99.Round(time.Microsecond), r.Max.Round(time.Microsecond))
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun_RequestLimitAndErrors(t *testing.T) {
+	var sent atomic.Int64
+	report, err := Run(context.Background(), Options{Concurrency: 4, Requests: 100}, func(worker int) Request {
+		return func(ctx context.Context) error {
+			if sent.Add(1)%10 == 0 {
+				return errors.New("status 503")
+			}
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Requests != 100 || sent.Load() != 100 {
+		t.Errorf("Requests = %d with %d sent, want 100", report.Requests, sent.Load())
+	}
+	if report.Errors != 10 || report.ErrorKinds["status 503"] != 10 {
+		t.Errorf("Errors = %d, kinds %v, want 10 'status 503'", report.Errors, report.ErrorKinds)
+	}
+	if report.ErrorRate != 0.1 {
+		t.Errorf("ErrorRate = %g, want 0.1", report.ErrorRate)
+	}
+}
+
+func TestRun_Rate(t *testing.T) {
+	report, err := Run(context.Background(), Options{Concurrency: 2, Rate: 100, Duration: 300 * time.Millisecond}, func(worker int) Request {
+		return func(ctx context.Context) error { return nil }
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// 100/s for 0.3 s, allowing for timer granularity
+	if report.Requests < 20 || report.Requests > 32 {
+		t.Errorf("Requests = %d at 100/s for 300ms, want about 30", report.Requests)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(latencies, tt.p); got != tt.want {
+			t.Errorf("Percentile(%g) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestRun_Invalid(t *testing.T) {
+	noop := func(worker int) Request { return func(ctx context.Context) error { return nil } }
+	for _, opts := range []Options{
+		{Requests: 1},
+		{Concurrency: 1},
+		{Concurrency: 1, Requests: 1, Rate: -1},
+	} {
+		if _, err := Run(context.Background(), opts, noop); err == nil {
+			t.Errorf("Run(%+v) succeeded, want an error", opts)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// maxAckBytes bounds the part of a collector response kept as acknowledgement
+const maxAckBytes = 256
+
+// maxAuditDeliveries bounds the IDs an AuditLog keeps in memory
+const maxAuditDeliveries = 100000
+
+// DeliveryStatus is the outcome of a delivery attempt
+type DeliveryStatus string
+
+const (
+	// DeliveryDelivered means the target accepted the payload
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed means the payload did not reach the target or was rejected
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// DeliveryAttempt is one line of the delivery audit log
+type DeliveryAttempt struct {
+	Time       time.Time      `json:"time"`
+	ID         string         `json:"id"` // Measurement UUID, or the idempotency key of payloads without one
+	Kind       PayloadKind    `json:"kind"`
+	Target     string         `json:"target"`
+	Attempt    int            `json:"attempt"` // Counted per ID across runs appending to the same log
+	Status     DeliveryStatus `json:"status"`
+	HTTPStatus int            `json:"http_status,omitempty"`
+	Ack        string         `json:"ack,omitempty"` // Start of the collector's response body
+	Error      string         `json:"error,omitempty"`
+}
+
+// Delivery summarizes the attempts to deliver one ID; Status, HTTPStatus,
+// Ack and Error are those of the last attempt
+type Delivery struct {
+	ID           string         `json:"id"`
+	Kind         PayloadKind    `json:"kind"`
+	Target       string         `json:"target"`
+	Attempts     int            `json:"attempts"`
+	Status       DeliveryStatus `json:"status"`
+	HTTPStatus   int            `json:"http_status,omitempty"`
+	Ack          string         `json:"ack,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	FirstAttempt time.Time      `json:"first_attempt"`
+	LastAttempt  time.Time      `json:"last_attempt"`
+}
+
+// AuditLog appends every delivery attempt to a JSON lines file and keeps the
+// per-ID outcome in memory, so it can be shown which spectra reached the
+// collector. Only the most recently first attempted IDs are kept, up to
+// maxAuditDeliveries; attempts of an ID that was dropped count from 1 again.
+// A nil log records nothing.
+type AuditLog struct {
+	mu         sync.Mutex
+	file       *os.File
+	encoder    *json.Encoder
+	clock      clock.Clock
+	deliveries map[string]*Delivery
+	order      []string // IDs by first attempt
+	capacity   int      // IDs kept in deliveries
+}
+
+// OpenAuditLog opens the audit log at path, creating it if needed. Attempts
+// already in the file are loaded, so attempt counts continue across runs. A
+// last line left incomplete by a crash is cut off with a warning.
+func OpenAuditLog(path string, c clock.Clock) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	audit := &AuditLog{file: file, encoder: json.NewEncoder(file), clock: clock.OrSystem(c), deliveries: make(map[string]*Delivery), capacity: maxAuditDeliveries}
+	if err := audit.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return audit, nil
+}
+
+// load applies the attempts in the file. Every line is written with a single
+// write, so only the last one can be torn: it is truncated if it lacks its
+// newline or does not parse, while a bad line before it is an error.
+func (a *AuditLog) load() error {
+	reader := bufio.NewReader(a.file)
+	offset := int64(0)
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(content) == 0 {
+			return nil
+		}
+		var attempt DeliveryAttempt
+		parseErr := json.Unmarshal(content, &attempt)
+		if err == io.EOF || (parseErr != nil && isEOF(reader)) {
+			log.Printf("Warning: cutting off the incomplete last line %d of the delivery audit log", line)
+			return a.file.Truncate(offset)
+		}
+		if parseErr != nil {
+			return fmt.Errorf("audit log line %d: %w", line, parseErr)
+		}
+		a.apply(attempt)
+		offset += int64(len(content))
+	}
+}
+
+// isEOF reports whether reader has no more data
+func isEOF(reader *bufio.Reader) bool {
+	_, err := reader.Peek(1)
+	return err == io.EOF
+}
+
+// Record appends one attempt per ID of a payload sent to target, with the
+// HTTP status and acknowledgement of the collector when known
+func (a *AuditLog) Record(kind PayloadKind, ids []string, target string, httpStatus int, ack string, sendErr error) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	for _, id := range ids {
+		attempt := DeliveryAttempt{Time: now, ID: id, Kind: kind, Target: target, Attempt: 1, Status: DeliveryDelivered, HTTPStatus: httpStatus, Ack: ack}
+		if delivery, ok := a.deliveries[id]; ok {
+			attempt.Attempt = delivery.Attempts + 1
+		}
+		if sendErr != nil {
+			attempt.Status = DeliveryFailed
+			attempt.Error = sendErr.Error()
+		}
+		if err := a.encoder.Encode(attempt); err != nil {
+			return config.NewProcessingError("delivery audit", err)
+		}
+		a.apply(attempt)
+	}
+	return nil
+}
+
+// apply folds an attempt into the per-ID outcome
+func (a *AuditLog) apply(attempt DeliveryAttempt) {
+	delivery, ok := a.deliveries[attempt.ID]
+	if !ok {
+		delivery = &Delivery{ID: attempt.ID, FirstAttempt: attempt.Time}
+		a.deliveries[attempt.ID] = delivery
+		a.order = append(a.order, attempt.ID)
+		if len(a.order) > a.capacity {
+			delete(a.deliveries, a.order[0])
+			a.order = a.order[1:]
+		}
+	}
+	delivery.Kind = attempt.Kind
+	delivery.Target = attempt.Target
+	delivery.Attempts = attempt.Attempt
+	delivery.Status = attempt.Status
+	delivery.HTTPStatus = attempt.HTTPStatus
+	delivery.Ack = attempt.Ack
+	delivery.Error = attempt.Error
+	delivery.LastAttempt = attempt.Time
+}
+
+// Delivery returns the outcome for id
+func (a *AuditLog) Delivery(id string) (Delivery, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delivery, ok := a.deliveries[id]
+	if !ok {
+		return Delivery{}, false
+	}
+	return *delivery, true
+}
+
+// Deliveries returns up to limit outcomes with the given status (empty = any),
+// the most recently attempted first; a limit of 0 returns all
+func (a *AuditLog) Deliveries(status DeliveryStatus, limit int) []Delivery {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	deliveries := []Delivery{}
+	for _, id := range a.order {
+		if delivery := a.deliveries[id]; status == "" || delivery.Status == status {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].LastAttempt.After(deliveries[j].LastAttempt)
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// ServeHTTP answers GET requests: ?id= returns the outcome of one
+// measurement, otherwise the latest outcomes are listed, filtered by
+// ?status=delivered|failed and bounded by ?limit= (default 100)
+func (a *AuditLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	if id := query.Get("id"); id != "" {
+		delivery, ok := a.Delivery(id)
+		if !ok {
+			http.Error(w, "no delivery attempt for "+id, http.StatusNotFound)
+			return
+		}
+		writeAuditJSON(w, delivery)
+		return
+	}
+
+	status := DeliveryStatus(query.Get("status"))
+	if status != "" && status != DeliveryDelivered && status != DeliveryFailed {
+		http.Error(w, "status must be delivered or failed", http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	writeAuditJSON(w, a.Deliveries(status, limit))
+}
+
+// writeAuditJSON writes v as a JSON response
+func writeAuditJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Close closes the audit log file
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// logAuditError reports an attempt that could not be written to the audit log
+func logAuditError(err error) {
+	log.Printf("Warning: failed to write delivery audit log: %v", err)
+}
+
+// readAck returns the start of a response body as acknowledgement
+func readAck(resp *http.Response) string {
+	ack, _ := io.ReadAll(io.LimitReader(resp.Body, maxAckBytes))
+	return string(ack)
+}
+
+// AuditingSender wraps a Sender whose transport has no audit support of its
+// own and records the outcome of every payload in an AuditLog
+type AuditingSender struct {
+	Sender
+	audit  *AuditLog
+	target string
+}
+
+// NewAuditingSender creates a wrapper around sender recording deliveries to
+// target in audit
+func NewAuditingSender(sender Sender, audit *AuditLog, target string) *AuditingSender {
+	return &AuditingSender{Sender: sender, audit: audit, target: target}
+}
+
+// SendEISMeasurement sends measurement and records the outcome
+func (as *AuditingSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	err := as.Sender.SendEISMeasurement(measurement)
+	data, _ := json.Marshal(measurement)
+	as.record(PayloadMeasurement, []string{idempotencyKey(data)}, err)
+	return err
+}
+
+// SendImpedanceData sends impedanceData and records the outcome
+func (as *AuditingSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	err := as.Sender.SendImpedanceData(impedanceData)
+	as.record(PayloadSpectrum, []string{spectrumAuditID(impedanceData)}, err)
+	return err
+}
+
+// SendBatchImpedanceData sends batch and records the outcome of every spectrum
+func (as *AuditingSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	err := as.Sender.SendBatchImpedanceData(batch)
+	as.record(PayloadBatch, batchAuditIDs(batch), err)
+	return err
+}
+
+// record writes the outcome, reporting audit failures without failing the send
+func (as *AuditingSender) record(kind PayloadKind, ids []string, sendErr error) {
+	if err := as.audit.Record(kind, ids, as.target, 0, "", sendErr); err != nil {
+		logAuditError(err)
+	}
+}
+
+// Close closes the wrapped sender if it needs closing
+func (as *AuditingSender) Close() error {
+	if closer, ok := as.Sender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// spectrumAuditID returns the measurement UUID of a spectrum or, without
+// one, a digest of its content
+func spectrumAuditID(data signal.ImpedanceData) string {
+	if data.ID != "" {
+		return data.ID
+	}
+	encoded, _ := json.Marshal(data)
+	return idempotencyKey(encoded)
+}
+
+// batchAuditIDs returns the audit IDs of the spectra of a batch
+func batchAuditIDs(batch []signal.ImpedanceDataWithIteration) []string {
+	ids := make([]string, len(batch))
+	for i, item := range batch {
+		ids[i] = spectrumAuditID(item.ImpedanceData)
+	}
+	return ids
+}
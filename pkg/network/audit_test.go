@@ -0,0 +1,213 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestAuditLog_DefaultSender(t *testing.T) {
+	var reject atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reject.Load() {
+			http.Error(w, "collector overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"accepted"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	audit, err := OpenAuditLog(path, clock.NewSimulatedClock(time.Unix(1700000000, 0)))
+	if err != nil {
+		t.Fatalf("OpenAuditLog() error = %v", err)
+	}
+	sender := NewSender(server.URL)
+	sender.(DeliveryAuditor).UseAuditLog(audit)
+
+	first := signal.ImpedanceData{Identity: signal.NewIdentity(), Frequencies: []float64{1}, Impedance: []complex128{complex(10, -2)}}
+	second := signal.ImpedanceData{Identity: signal.NewIdentity(), Frequencies: []float64{1}, Impedance: []complex128{complex(11, -2)}}
+
+	// The first attempt is rejected, the retry delivered along with a new spectrum
+	reject.Store(true)
+	if err := sender.SendImpedanceData(first); err == nil {
+		t.Fatal("SendImpedanceData() error = nil, want the rejection")
+	}
+	reject.Store(false)
+	if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: first}, {ImpedanceData: second, Iteration: 1}}); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+
+	delivery, ok := audit.Delivery(first.ID)
+	if !ok {
+		t.Fatalf("Delivery(%s) not found", first.ID)
+	}
+	if delivery.Attempts != 2 || delivery.Status != DeliveryDelivered || delivery.HTTPStatus != http.StatusOK || delivery.Ack != `{"status":"accepted"}` {
+		t.Errorf("Delivery(first) = %+v, want 2 attempts delivered with the collector's ack", delivery)
+	}
+	if failed := audit.Deliveries(DeliveryFailed, 0); len(failed) != 0 {
+		t.Errorf("Deliveries(failed) = %+v, want none", failed)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A reopened log continues counting attempts
+	audit, err = OpenAuditLog(path, nil)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer audit.Close()
+	if deliveries := audit.Deliveries("", 0); len(deliveries) != 2 {
+		t.Fatalf("reopened Deliveries() = %+v, want 2", deliveries)
+	}
+	if err := audit.Record(PayloadSpectrum, []string{second.ID}, server.URL, 0, "", errors.New("connection refused")); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if delivery, _ := audit.Delivery(second.ID); delivery.Attempts != 2 || delivery.Status != DeliveryFailed || delivery.Error != "connection refused" {
+		t.Errorf("Delivery(second) = %+v, want a failed second attempt", delivery)
+	}
+}
+
+func TestAuditLog_ServeHTTP(t *testing.T) {
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "deliveries.jsonl"), clock.NewSimulatedClock(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatalf("OpenAuditLog() error = %v", err)
+	}
+	defer audit.Close()
+
+	// Deliveries through a transport without audit support of its own
+	inner := NewMockSender()
+	sender := NewAuditingSender(inner, audit, "nats://127.0.0.1:4222/masterapp")
+	sender.SendImpedanceData(signal.ImpedanceData{Identity: signal.Identity{ID: "delivered-1"}})
+	inner.FailNext(1, errors.New("no responders"))
+	sender.SendImpedanceData(signal.ImpedanceData{Identity: signal.Identity{ID: "failed-1"}})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantOne  bool // A single delivery instead of a list
+		wantIDs  []string
+	}{
+		{name: "all", query: "", wantCode: http.StatusOK, wantIDs: []string{"delivered-1", "failed-1"}},
+		{name: "failed", query: "?status=failed", wantCode: http.StatusOK, wantIDs: []string{"failed-1"}},
+		{name: "limit", query: "?limit=1", wantCode: http.StatusOK, wantIDs: []string{"delivered-1"}},
+		{name: "one", query: "?id=failed-1", wantCode: http.StatusOK, wantOne: true, wantIDs: []string{"failed-1"}},
+		{name: "unknown", query: "?id=missing", wantCode: http.StatusNotFound},
+		{name: "bad status", query: "?status=lost", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			audit.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/deliveries"+tt.query, nil))
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantCode)
+			}
+			if tt.wantIDs == nil {
+				return
+			}
+
+			var deliveries []Delivery
+			if tt.wantOne {
+				var delivery Delivery
+				if err := json.Unmarshal(recorder.Body.Bytes(), &delivery); err != nil {
+					t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+				}
+				deliveries = []Delivery{delivery}
+			} else if err := json.Unmarshal(recorder.Body.Bytes(), &deliveries); err != nil {
+				t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+			}
+			if len(deliveries) != len(tt.wantIDs) {
+				t.Fatalf("deliveries = %+v, want IDs %v", deliveries, tt.wantIDs)
+			}
+			for i, delivery := range deliveries {
+				if delivery.ID != tt.wantIDs[i] {
+					t.Errorf("delivery %d = %s, want %s", i, delivery.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAuditLog_TornLastLine(t *testing.T) {
+	line := func(attempt int) string {
+		return fmt.Sprintf(`{"time":"2024-05-15T10:00:00Z","id":"a","kind":"spectrum","target":"t","attempt":%d,"status":"delivered"}`+"\n", attempt)
+	}
+	tests := []struct {
+		name    string
+		content string
+		kept    int // Lines kept, -1 when opening fails
+	}{
+		{"complete", line(1) + line(2), 2},
+		{"unterminated", line(1) + line(2)[:40], 1},
+		{"unterminated but valid", line(1) + strings.TrimSuffix(line(2), "\n"), 1},
+		{"bad last line", line(1) + "{garbage\n", 1},
+		{"bad line before the last", "{garbage\n" + line(1), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			audit, err := OpenAuditLog(path, nil)
+			if tt.kept < 0 {
+				if err == nil {
+					audit.Close()
+					t.Fatal("OpenAuditLog() accepted a corrupt line before the last")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenAuditLog() error = %v", err)
+			}
+			if err := audit.Record(PayloadSpectrum, []string{"a"}, "t", 0, "", nil); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			audit.Close()
+
+			// The new attempt follows the kept lines and the file reopens
+			audit, err = OpenAuditLog(path, nil)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			defer audit.Close()
+			if delivery, _ := audit.Delivery("a"); delivery.Attempts != tt.kept+1 {
+				t.Errorf("Delivery(a).Attempts = %d, want %d", delivery.Attempts, tt.kept+1)
+			}
+		})
+	}
+}
+
+func TestAuditLog_BoundsDeliveries(t *testing.T) {
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "deliveries.jsonl"), nil)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() error = %v", err)
+	}
+	defer audit.Close()
+	audit.capacity = 2
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if err := audit.Record(PayloadSpectrum, []string{id}, "t", 0, "", nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	if _, ok := audit.Delivery("a"); ok {
+		t.Error("Delivery(a) kept beyond the capacity")
+	}
+	if deliveries := audit.Deliveries("", 0); len(deliveries) != 2 || deliveries[0].ID != "c" || deliveries[1].ID != "b" {
+		t.Errorf("Deliveries() = %+v, want c and b", deliveries)
+	}
+}
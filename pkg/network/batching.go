@@ -0,0 +1,132 @@
+package network
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// FlushPolicy decides when a BatchingSender sends its accumulated spectra.
+// A zero limit disables the corresponding trigger.
+type FlushPolicy struct {
+	MaxCount int           // Flush once this many spectra are buffered
+	MaxBytes int           // Flush once the buffered spectra exceed this JSON size
+	MaxAge   time.Duration // Flush once the oldest buffered spectrum is this old
+}
+
+// BatchingSender wraps a Sender and accumulates single impedance spectra into
+// batches sent through the batch endpoint of the wrapped sender
+type BatchingSender struct {
+	Sender
+	policy    FlushPolicy
+	mu        sync.Mutex
+	pending   []signal.ImpedanceDataWithIteration
+	bytes     int
+	iteration int
+	timer     *time.Timer
+}
+
+// NewBatchingSender creates a batching wrapper around sender
+func NewBatchingSender(sender Sender, policy FlushPolicy) (*BatchingSender, error) {
+	if policy.MaxCount < 0 || policy.MaxBytes < 0 || policy.MaxAge < 0 {
+		return nil, config.NewValidationError("FlushPolicy", "flush limits cannot be negative")
+	}
+	if policy.MaxCount == 0 && policy.MaxBytes == 0 && policy.MaxAge == 0 {
+		return nil, config.NewValidationError("FlushPolicy", "at least one flush limit is required")
+	}
+	return &BatchingSender{Sender: sender, policy: policy}, nil
+}
+
+// SendImpedanceData buffers impedance data and sends the batch when the
+// count or size limit is reached
+func (bs *BatchingSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	jsonData, err := json.Marshal(impedanceData)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.iteration++
+	bs.pending = append(bs.pending, signal.ImpedanceDataWithIteration{ImpedanceData: impedanceData, Iteration: bs.iteration})
+	bs.bytes += len(jsonData)
+
+	if (bs.policy.MaxCount > 0 && len(bs.pending) >= bs.policy.MaxCount) ||
+		(bs.policy.MaxBytes > 0 && bs.bytes >= bs.policy.MaxBytes) {
+		return bs.flushLocked()
+	}
+	if bs.policy.MaxAge > 0 && bs.timer == nil {
+		bs.timer = time.AfterFunc(bs.policy.MaxAge, bs.flushExpired)
+	}
+	return nil
+}
+
+// SendBatchImpedanceData sends the buffered spectra first to keep their
+// order, then the given batch
+func (bs *BatchingSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if err := bs.flushLocked(); err != nil {
+		return err
+	}
+	return bs.Sender.SendBatchImpedanceData(batch)
+}
+
+// Flush sends all buffered spectra
+func (bs *BatchingSender) Flush() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.flushLocked()
+}
+
+// Pending returns the number of buffered spectra
+func (bs *BatchingSender) Pending() int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return len(bs.pending)
+}
+
+// flushExpired sends the buffer once its oldest spectrum reached the max age
+func (bs *BatchingSender) flushExpired() {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if err := bs.flushLocked(); err != nil {
+		log.Printf("Error sending batch after %v: %v", bs.policy.MaxAge, err)
+	}
+}
+
+// flushLocked sends and clears the buffer; bs.mu must be held. A failed batch
+// is dropped, retrying is left to the wrapped sender.
+func (bs *BatchingSender) flushLocked() error {
+	if bs.timer != nil {
+		bs.timer.Stop()
+		bs.timer = nil
+	}
+	if len(bs.pending) == 0 {
+		return nil
+	}
+
+	batch := bs.pending
+	bs.pending = nil
+	bs.bytes = 0
+	return bs.Sender.SendBatchImpedanceData(batch)
+}
+
+// Close sends the remaining spectra and closes the wrapped sender if it
+// holds connections
+func (bs *BatchingSender) Close() error {
+	err := bs.Flush()
+	if closer, ok := bs.Sender.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// batchSizes returns the sizes of the batches recorded by ms
+func batchSizes(ms *MockSender) []int {
+	var sizes []int
+	for _, payload := range ms.Payloads() {
+		sizes = append(sizes, len(payload.Batch))
+	}
+	return sizes
+}
+
+func TestBatchingSender_FlushPolicies(t *testing.T) {
+	spectrum := signal.ImpedanceData{Frequencies: []float64{1, 10, 100}, Impedance: []complex128{1, 2, 3}}
+
+	tests := []struct {
+		name      string
+		policy    FlushPolicy
+		sends     int
+		wait      time.Duration
+		wantSizes []int
+	}{
+		{name: "count", policy: FlushPolicy{MaxCount: 3}, sends: 7, wantSizes: []int{3, 3}},
+		{name: "bytes", policy: FlushPolicy{MaxBytes: 1}, sends: 2, wantSizes: []int{1, 1}},
+		{name: "age", policy: FlushPolicy{MaxAge: 20 * time.Millisecond}, sends: 2, wait: 200 * time.Millisecond, wantSizes: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewMockSender()
+			sender, err := NewBatchingSender(inner, tt.policy)
+			if err != nil {
+				t.Fatalf("NewBatchingSender() error = %v", err)
+			}
+			for i := 0; i < tt.sends; i++ {
+				if err := sender.SendImpedanceData(spectrum); err != nil {
+					t.Fatalf("SendImpedanceData() error = %v", err)
+				}
+			}
+			time.Sleep(tt.wait)
+
+			sizes := batchSizes(inner)
+			if len(sizes) != len(tt.wantSizes) {
+				t.Fatalf("batch sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.wantSizes[i] {
+					t.Errorf("batch sizes = %v, want %v", sizes, tt.wantSizes)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchingSender_CloseFlushesRemainder(t *testing.T) {
+	inner := NewMockSender()
+	sender, err := NewBatchingSender(inner, FlushPolicy{MaxCount: 10})
+	if err != nil {
+		t.Fatalf("NewBatchingSender() error = %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		sender.SendImpedanceData(signal.ImpedanceData{})
+	}
+	if err := sender.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if sizes := batchSizes(inner); len(sizes) != 1 || sizes[0] != 4 {
+		t.Fatalf("batch sizes = %v, want [4]", sizes)
+	}
+	if last := inner.Payloads()[0].Batch[3].Iteration; last != 4 {
+		t.Errorf("last iteration = %d, want 4", last)
+	}
+
+	if _, err := NewBatchingSender(inner, FlushPolicy{}); err == nil {
+		t.Errorf("expected error for a policy without limits")
+	}
+}
@@ -0,0 +1,225 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// maxCaptureLine bounds one record of a capture file; large batches of
+// dense spectra take a few megabytes
+const maxCaptureLine = 256 << 20
+
+// PayloadSession is the kind of the first record of a capture file, whose
+// data holds the sender settings of the recorded run as option names and
+// values, so a replay encodes and delivers the payloads the same way
+const PayloadSession PayloadKind = "session"
+
+// CaptureRecord is one line of a capture file: a payload handed to the
+// sender, when it was handed over and how the send ended
+type CaptureRecord struct {
+	Time  time.Time       `json:"time"`
+	Kind  PayloadKind     `json:"kind"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error,omitempty"`
+}
+
+// RecordingSender wraps a Sender and appends every payload to a capture
+// file of JSON lines, which ReplayCapture sends again. Payloads are recorded
+// before encoding; the session record keeps the settings that encode them.
+type RecordingSender struct {
+	Sender
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	now     func() time.Time
+}
+
+// NewRecordingSender creates a recording wrapper around sender writing the
+// capture file path, which is truncated. The settings, if any, are written
+// first as the session record.
+func NewRecordingSender(sender Sender, path string, settings map[string]string) (*RecordingSender, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rs := &RecordingSender{Sender: sender, file: file, encoder: json.NewEncoder(file), now: time.Now}
+	if settings != nil {
+		data, err := json.Marshal(settings)
+		if err == nil {
+			err = rs.encoder.Encode(CaptureRecord{Time: rs.now(), Kind: PayloadSession, Data: data})
+		}
+		if err != nil {
+			file.Close()
+			return nil, config.NewProcessingError("capture", err)
+		}
+	}
+	return rs, nil
+}
+
+// ReadCaptureSettings returns the sender settings of the session record
+// that starts a capture file, or nil for captures without one
+func ReadCaptureSettings(r io.Reader) (map[string]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLine)
+	if !scanner.Scan() {
+		return nil, scanner.Err()
+	}
+	var record CaptureRecord
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		return nil, fmt.Errorf("capture line 1: %w", err)
+	}
+	if record.Kind != PayloadSession {
+		return nil, nil
+	}
+	var settings map[string]string
+	if err := json.Unmarshal(record.Data, &settings); err != nil {
+		return nil, fmt.Errorf("capture line 1: %w", err)
+	}
+	return settings, nil
+}
+
+// SendEISMeasurement sends and records measurement
+func (rs *RecordingSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	return rs.record(PayloadMeasurement, measurement, func() error { return rs.Sender.SendEISMeasurement(measurement) })
+}
+
+// SendImpedanceData sends and records impedanceData
+func (rs *RecordingSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	return rs.record(PayloadSpectrum, impedanceData, func() error { return rs.Sender.SendImpedanceData(impedanceData) })
+}
+
+// SendBatchImpedanceData sends and records batch
+func (rs *RecordingSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	return rs.record(PayloadBatch, batch, func() error { return rs.Sender.SendBatchImpedanceData(batch) })
+}
+
+// record sends a payload and appends it with the time it was handed over.
+// A capture that cannot be written is reported, the send itself stands.
+func (rs *RecordingSender) record(kind PayloadKind, payload interface{}, send func() error) error {
+	handed := rs.now()
+	sendErr := send()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return config.NewProcessingError("capture", err)
+	}
+	record := CaptureRecord{Time: handed, Kind: kind, Data: data}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if err := rs.encoder.Encode(record); err != nil && sendErr == nil {
+		return config.NewProcessingError("capture", err)
+	}
+	return sendErr
+}
+
+// Close closes the capture file and the wrapped sender if it holds
+// connections
+func (rs *RecordingSender) Close() error {
+	rs.mu.Lock()
+	err := rs.file.Close()
+	rs.mu.Unlock()
+	if closer, ok := rs.Sender.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// ReplayStats counts the payloads sent by ReplayCapture
+type ReplayStats struct {
+	Sent   int
+	Failed int
+}
+
+// ReplayCapture sends the payloads of a capture file through sender again,
+// which should be configured with the settings of the session record.
+// Gaps between payloads are kept, divided by speed; a speed of 0 sends
+// without pauses. Failed sends are counted, not retried.
+func ReplayCapture(ctx context.Context, r io.Reader, sender Sender, speed float64) (ReplayStats, error) {
+	var stats ReplayStats
+	if speed < 0 {
+		return stats, config.NewValidationError("Speed", "replay speed cannot be negative")
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLine)
+	var first time.Time
+	start := time.Now()
+	for line := 1; scanner.Scan(); line++ {
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return stats, fmt.Errorf("capture line %d: %w", line, err)
+		}
+		if record.Kind == PayloadSession {
+			continue // Applied to sender by the caller, see ReadCaptureSettings
+		}
+		if first.IsZero() {
+			first = record.Time
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(record.Time.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return stats, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		send, err := decodeRecord(sender, record)
+		if err != nil {
+			return stats, fmt.Errorf("capture line %d: %w", line, err)
+		}
+		if err := send(); err != nil {
+			stats.Failed++
+			continue
+		}
+		stats.Sent++
+	}
+	return stats, scanner.Err()
+}
+
+// decodeRecord decodes the payload of record into the send call of its kind
+func decodeRecord(sender Sender, record CaptureRecord) (func() error, error) {
+	switch record.Kind {
+	case PayloadMeasurement:
+		var measurement signal.EISMeasurement
+		if err := json.Unmarshal(record.Data, &measurement); err != nil {
+			return nil, err
+		}
+		return func() error { return sender.SendEISMeasurement(measurement) }, nil
+	case PayloadSpectrum:
+		var spectrum signal.ImpedanceData
+		if err := json.Unmarshal(record.Data, &spectrum); err != nil {
+			return nil, err
+		}
+		return func() error { return sender.SendImpedanceData(spectrum) }, nil
+	case PayloadBatch:
+		var batch []signal.ImpedanceDataWithIteration
+		if err := json.Unmarshal(record.Data, &batch); err != nil {
+			return nil, err
+		}
+		return func() error { return sender.SendBatchImpedanceData(batch) }, nil
+	default:
+		return nil, fmt.Errorf("unknown payload kind '%s'", record.Kind)
+	}
+}
@@ -0,0 +1,94 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestRecordingSender_ReplayRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	inner := NewMockSender()
+	settings := map[string]string{"transport": "http", "float32": "true"}
+	recorder, err := NewRecordingSender(inner, path, settings)
+	if err != nil {
+		t.Fatalf("NewRecordingSender() error = %v", err)
+	}
+	clock := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time {
+		clock = clock.Add(100 * time.Millisecond)
+		return clock
+	}
+
+	spectrum := signal.ImpedanceData{Identity: signal.NewIdentity(), Frequencies: []float64{1, 10}, Impedance: []complex128{complex(10, -2), complex(5, -1)}}
+	recorder.SendEISMeasurement(signal.EISMeasurement{{Frequency: 1, Real: 10, Imag: -2}})
+	recorder.SendImpedanceData(spectrum)
+	inner.FailNext(1, errors.New("status 503"))
+	if err := recorder.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum, Iteration: 1}}); err == nil {
+		t.Error("SendBatchImpedanceData() succeeded, want the error of the wrapped sender")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	recorded, err := ReadCaptureSettings(file)
+	if err != nil {
+		t.Fatalf("ReadCaptureSettings() error = %v", err)
+	}
+	if !reflect.DeepEqual(recorded, settings) {
+		t.Errorf("ReadCaptureSettings() = %v, want %v", recorded, settings)
+	}
+	file.Seek(0, io.SeekStart)
+	replayed := NewMockSender()
+	began := time.Now()
+	stats, err := ReplayCapture(context.Background(), file, replayed, 2)
+	if err != nil {
+		t.Fatalf("ReplayCapture() error = %v", err)
+	}
+	// Records 100ms apart, replayed at twice the speed
+	if elapsed := time.Since(began); elapsed < 90*time.Millisecond {
+		t.Errorf("replay took %v, want about 100ms", elapsed)
+	}
+	if stats.Sent != 3 || stats.Failed != 0 {
+		t.Errorf("ReplayCapture() stats = %+v, want 3 sent", stats)
+	}
+
+	payloads := replayed.Payloads()
+	if len(payloads) != 3 {
+		t.Fatalf("%d payloads replayed, want 3", len(payloads))
+	}
+	for i, kind := range []PayloadKind{PayloadMeasurement, PayloadSpectrum, PayloadBatch} {
+		if payloads[i].Kind != kind {
+			t.Errorf("payload %d kind = %s, want %s", i, payloads[i].Kind, kind)
+		}
+	}
+	got := payloads[1].Spectrum
+	if got.ID != spectrum.ID || len(got.Impedance) != 2 || got.Impedance[0] != spectrum.Impedance[0] {
+		t.Errorf("replayed spectrum = %+v, want %+v", got, spectrum)
+	}
+	if payloads[2].Batch[0].ImpedanceData.ID != spectrum.ID {
+		t.Errorf("replayed batch lost the spectrum ID")
+	}
+}
+
+func TestReplayCapture_InvalidRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	os.WriteFile(path, []byte(`{"time":"2024-05-15T10:00:00Z","kind":"unknown","data":{}}`+"\n"), 0644)
+	file, _ := os.Open(path)
+	defer file.Close()
+	if _, err := ReplayCapture(context.Background(), file, NewMockSender(), 0); err == nil {
+		t.Error("ReplayCapture() succeeded on an unknown payload kind, want an error")
+	}
+}
@@ -0,0 +1,58 @@
+package network_test
+
+import (
+	"testing"
+
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/network/sendertest"
+)
+
+func TestSenderContract(t *testing.T) {
+	tests := []struct {
+		name    string
+		harness sendertest.Harness
+	}{
+		{"http", sendertest.Harness{
+			NewCollector: func() sendertest.Collector { return sendertest.NewHTTPCollector() },
+			NewSender: func(t *testing.T, targetURL string) network.Sender {
+				return network.NewSender(targetURL)
+			},
+		}},
+		{"websocket", sendertest.Harness{
+			NewCollector: func() sendertest.Collector { return sendertest.NewWebSocketCollector() },
+			NewSender: func(t *testing.T, targetURL string) network.Sender {
+				sender, err := network.NewWSSender(targetURL, "/eis-data/stream")
+				if err != nil {
+					t.Fatalf("NewWSSender() error = %v", err)
+				}
+				return sender
+			},
+		}},
+		{"nats", sendertest.Harness{
+			NewCollector: func() sendertest.Collector { return sendertest.NewNATSCollector() },
+			NewSender: func(t *testing.T, targetURL string) network.Sender {
+				sender, err := network.NewNATSSender(targetURL, network.NATSOptions{Subject: "eis"})
+				if err != nil {
+					t.Fatalf("NewNATSSender() error = %v", err)
+				}
+				return sender
+			},
+		}},
+		{"nats-jetstream", sendertest.Harness{
+			NewCollector: func() sendertest.Collector { return sendertest.NewNATSCollector() },
+			NewSender: func(t *testing.T, targetURL string) network.Sender {
+				sender, err := network.NewNATSSender(targetURL, network.NATSOptions{Subject: "eis", JetStream: true})
+				if err != nil {
+					t.Fatalf("NewNATSSender() error = %v", err)
+				}
+				return sender
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendertest.Run(t, tt.harness)
+		})
+	}
+}
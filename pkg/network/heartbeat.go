@@ -0,0 +1,182 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Heartbeat is the liveness report POSTed periodically to the collector, so
+// that a silent device can be told apart from one without new measurements
+type Heartbeat struct {
+	InstanceID    string                 `json:"instance_id"`
+	Hostname      string                 `json:"hostname,omitempty"`
+	Sequence      uint64                 `json:"sequence"`
+	Timestamp     time.Time              `json:"timestamp"`
+	UptimeSeconds float64                `json:"uptime_seconds"`
+	Healthy       bool                   `json:"healthy"`
+	Status        map[string]interface{} `json:"status,omitempty"`
+}
+
+// Heartbeater periodically sends heartbeats describing uptime, progress and
+// health of the running pipeline
+type Heartbeater struct {
+	url        string
+	instanceID string
+	hostname   string
+	client     *http.Client
+	signer     *Signer
+	clock      clock.Clock
+	startedAt  time.Time
+	sequence   uint64
+	mu         sync.Mutex
+	health     func() bool
+	sources    map[string]func() interface{}
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewHeartbeater creates a heartbeater posting to heartbeatPath on the target's host
+func NewHeartbeater(targetURL, heartbeatPath string) (*Heartbeater, error) {
+	return NewHeartbeaterWithClock(targetURL, heartbeatPath, clock.NewSystemClock())
+}
+
+// NewHeartbeaterWithClock creates a heartbeater timestamping heartbeats and
+// measuring uptime with the given clock
+func NewHeartbeaterWithClock(targetURL, heartbeatPath string, c clock.Clock) (*Heartbeater, error) {
+	heartbeatURL, err := HealthURL(targetURL, heartbeatPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c = clock.OrSystem(c)
+	hostname, _ := os.Hostname()
+	return &Heartbeater{
+		url:        heartbeatURL,
+		instanceID: signal.NewUUID(),
+		hostname:   hostname,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		clock:      c,
+		startedAt:  c.Now(),
+		sources:    make(map[string]func() interface{}),
+		stopChan:   make(chan struct{}),
+	}, nil
+}
+
+// UseSigner signs every heartbeat with signer
+func (h *Heartbeater) UseSigner(signer *Signer) {
+	h.signer = signer
+}
+
+// RegisterHealth sets the check reported as "healthy"; without one the
+// pipeline is reported healthy as long as heartbeats are sent
+func (h *Heartbeater) RegisterHealth(fn func() bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health = fn
+}
+
+// RegisterStatus adds a named component, e.g. receiver progress, to every heartbeat
+func (h *Heartbeater) RegisterStatus(name string, fn func() interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sources[name] = fn
+}
+
+// snapshot builds the next heartbeat
+func (h *Heartbeater) snapshot() Heartbeat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	now := h.clock.Now()
+	heartbeat := Heartbeat{
+		InstanceID:    h.instanceID,
+		Hostname:      h.hostname,
+		Sequence:      h.sequence,
+		Timestamp:     now,
+		UptimeSeconds: now.Sub(h.startedAt).Seconds(),
+		Healthy:       h.health == nil || h.health(),
+	}
+	if len(h.sources) > 0 {
+		heartbeat.Status = make(map[string]interface{}, len(h.sources))
+		for name, fn := range h.sources {
+			heartbeat.Status[name] = fn()
+		}
+	}
+	return heartbeat
+}
+
+// Send posts a single heartbeat to the collector
+func (h *Heartbeater) Send() error {
+	jsonData, err := json.Marshal(h.snapshot())
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	req, err := http.NewRequest("POST", h.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return config.NewNetworkError(h.url, 0, fmt.Errorf("failed to create heartbeat request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Data-Type", "Heartbeat")
+	h.signer.Sign(req, jsonData)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return config.NewNetworkError(h.url, 0, fmt.Errorf("failed to send heartbeat: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return config.NewNetworkError(h.url, resp.StatusCode, config.ErrInvalidHTTPResponse)
+	}
+	return nil
+}
+
+// Start sends a heartbeat immediately and then at every interval until Stop is called
+func (h *Heartbeater) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("Sending heartbeats to %s every %v", h.url, interval)
+		for {
+			if err := h.Send(); err != nil {
+				log.Printf("Heartbeat error: %v", err)
+			}
+
+			select {
+			case <-h.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop halts the periodic heartbeats
+func (h *Heartbeater) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopChan)
+	})
+	h.wg.Wait()
+}
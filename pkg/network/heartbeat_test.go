@@ -0,0 +1,73 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+)
+
+func TestHeartbeater_Send(t *testing.T) {
+	received := make(chan Heartbeat, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/heartbeat" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var heartbeat Heartbeat
+		if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- heartbeat
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	simulated := clock.NewSimulatedClock(start)
+	heartbeater, err := NewHeartbeaterWithClock(server.URL+"/eis-data", "/heartbeat", simulated)
+	if err != nil {
+		t.Fatalf("NewHeartbeaterWithClock() error = %v", err)
+	}
+	healthy := true
+	heartbeater.RegisterHealth(func() bool { return healthy })
+	heartbeater.RegisterStatus("receiver", func() interface{} { return map[string]int{"processed": 3} })
+
+	if err := heartbeater.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	simulated.Advance(90 * time.Second)
+	healthy = false
+	if err := heartbeater.Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	first, second := <-received, <-received
+	if first.InstanceID == "" || first.InstanceID != second.InstanceID {
+		t.Errorf("instance IDs = %q, %q, want the same non-empty ID", first.InstanceID, second.InstanceID)
+	}
+	if first.Sequence != 1 || second.Sequence != 2 {
+		t.Errorf("sequences = %d, %d, want 1, 2", first.Sequence, second.Sequence)
+	}
+	if second.UptimeSeconds != 90 {
+		t.Errorf("uptime = %v, want 90", second.UptimeSeconds)
+	}
+	if !first.Healthy || second.Healthy {
+		t.Errorf("healthy = %v, %v, want true, false", first.Healthy, second.Healthy)
+	}
+	if _, ok := first.Status["receiver"]; !ok {
+		t.Errorf("status %v is missing the receiver component", first.Status)
+	}
+
+	down, err := NewHeartbeater(server.URL, "/missing")
+	if err != nil {
+		t.Fatalf("NewHeartbeater() error = %v", err)
+	}
+	if err := down.Send(); err == nil {
+		t.Errorf("expected error for a non-2xx heartbeat response")
+	}
+}
@@ -0,0 +1,179 @@
+package network
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// ImpairmentOptions describes the simulated network between masterapp and
+// the collector. Percentages are in [0, 100].
+type ImpairmentOptions struct {
+	Latency        time.Duration // Delay added to every delivered payload
+	Jitter         time.Duration // Uniform variation of the delay, ±Jitter
+	DropPercent    float64       // Payloads silently discarded
+	ReorderPercent float64       // Payloads held back and delivered after the next one
+	Seed           int64         // Seed of the random decisions; 0 seeds from the clock
+}
+
+// ImpairmentStats counts the payloads an ImpairedSender handled
+type ImpairmentStats struct {
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+	Dropped   int64 `json:"dropped"`
+	Reordered int64 `json:"reordered"`
+}
+
+// ImpairedSender wraps a Sender and degrades its deliveries with latency,
+// jitter, drops and reordering, so collectors can be tested against an
+// unreliable network. Dropped payloads are reported as sent, like a message
+// lost after the sender let go of it.
+type ImpairedSender struct {
+	Sender
+	options  ImpairmentOptions
+	mu       sync.Mutex
+	rng      *rand.Rand
+	held     func() error // Delivery postponed until after the next one
+	stats    ImpairmentStats
+	inFlight sync.WaitGroup // Delayed deliveries still travelling
+	sleep    func(time.Duration)
+}
+
+// NewImpairedSender creates an impairing wrapper around sender
+func NewImpairedSender(sender Sender, options ImpairmentOptions) (*ImpairedSender, error) {
+	if options.Latency < 0 || options.Jitter < 0 {
+		return nil, config.NewValidationError("ImpairmentOptions", "latency and jitter cannot be negative")
+	}
+	if options.DropPercent < 0 || options.DropPercent > 100 || options.ReorderPercent < 0 || options.ReorderPercent > 100 {
+		return nil, config.NewValidationError("ImpairmentOptions", "drop and reorder percentages must be between 0 and 100")
+	}
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &ImpairedSender{
+		Sender:  sender,
+		options: options,
+		rng:     rand.New(rand.NewSource(seed)),
+		sleep:   time.Sleep,
+	}, nil
+}
+
+// SendEISMeasurement delivers measurement through the impaired network
+func (is *ImpairedSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	return is.deliver(func() error { return is.Sender.SendEISMeasurement(measurement) })
+}
+
+// SendImpedanceData delivers impedanceData through the impaired network
+func (is *ImpairedSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	return is.deliver(func() error { return is.Sender.SendImpedanceData(impedanceData) })
+}
+
+// SendBatchImpedanceData delivers batch through the impaired network
+func (is *ImpairedSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	return is.deliver(func() error { return is.Sender.SendBatchImpedanceData(batch) })
+}
+
+// deliver applies the impairments to one send. Delayed payloads travel
+// concurrently, each on its own schedule, so latency does not limit the
+// throughput and jitter can reorder them; as the caller has moved on, their
+// errors are only counted and logged. Payloads without delay are sent before
+// deliver returns. A held payload is sent after the one following it.
+func (is *ImpairedSender) deliver(send func() error) error {
+	is.mu.Lock()
+	if is.options.DropPercent > 0 && is.rng.Float64()*100 < is.options.DropPercent {
+		is.stats.Dropped++
+		is.mu.Unlock()
+		return nil
+	}
+	delay := is.options.Latency
+	if is.options.Jitter > 0 {
+		delay += time.Duration((2*is.rng.Float64() - 1) * float64(is.options.Jitter))
+	}
+	dispatch := is.dispatcher(send, delay)
+
+	if is.held == nil && is.options.ReorderPercent > 0 && is.rng.Float64()*100 < is.options.ReorderPercent {
+		is.held = dispatch
+		is.stats.Reordered++
+		is.mu.Unlock()
+		return nil
+	}
+	held := is.held
+	is.held = nil
+	is.mu.Unlock()
+
+	err := dispatch()
+	if held != nil {
+		if heldErr := held(); err == nil {
+			err = heldErr
+		}
+	}
+	return err
+}
+
+// dispatcher returns a function handing send to the network: at once without
+// delay, otherwise on a goroutine of its own that waits for the delay
+func (is *ImpairedSender) dispatcher(send func() error, delay time.Duration) func() error {
+	if delay <= 0 {
+		return func() error { return is.count(send()) }
+	}
+	return func() error {
+		is.inFlight.Add(1)
+		go func() {
+			defer is.inFlight.Done()
+			is.sleep(delay)
+			if err := is.count(send()); err != nil {
+				log.Printf("Simulated network: delayed payload failed: %v", err)
+			}
+		}()
+		return nil
+	}
+}
+
+// count records the outcome of a send and returns its error
+func (is *ImpairedSender) count(err error) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if err != nil {
+		is.stats.Failed++
+	} else {
+		is.stats.Delivered++
+	}
+	return err
+}
+
+// Stats returns the number of delivered, failed, dropped and reordered payloads
+func (is *ImpairedSender) Stats() ImpairmentStats {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return is.stats
+}
+
+// Close delivers a payload still held back for reordering, waits for the
+// delayed payloads, logs the impairment counts and closes the wrapped sender
+// if it holds connections
+func (is *ImpairedSender) Close() error {
+	is.mu.Lock()
+	held := is.held
+	is.held = nil
+	is.mu.Unlock()
+	var err error
+	if held != nil {
+		err = held()
+	}
+	is.inFlight.Wait()
+
+	stats := is.Stats()
+	log.Printf("Simulated network: %d payloads delivered, %d failed, %d dropped, %d reordered", stats.Delivered, stats.Failed, stats.Dropped, stats.Reordered)
+	if closer, ok := is.Sender.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// spectrumOrder returns the spectra recorded by ms by their first frequency,
+// which numbers them in these tests
+func spectrumOrder(ms *MockSender) []int {
+	var numbers []int
+	for _, spectrum := range ms.Spectra() {
+		numbers = append(numbers, int(spectrum.Frequencies[0]))
+	}
+	return numbers
+}
+
+func TestImpairedSender_DropAndReorder(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       ImpairmentOptions
+		wantNumbers   []int
+		wantDropped   int64
+		wantReordered int64
+	}{
+		{name: "none", options: ImpairmentOptions{}, wantNumbers: []int{1, 2, 3, 4}},
+		{name: "drop all", options: ImpairmentOptions{DropPercent: 100}, wantDropped: 4},
+		// Every other payload is held back, as a held payload is never held twice
+		{name: "reorder all", options: ImpairmentOptions{ReorderPercent: 100}, wantNumbers: []int{2, 1, 4, 3}, wantReordered: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewMockSender()
+			sender, err := NewImpairedSender(inner, tt.options)
+			if err != nil {
+				t.Fatalf("NewImpairedSender() error = %v", err)
+			}
+			for i := 1; i <= 4; i++ {
+				if err := sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{float64(i)}}); err != nil {
+					t.Fatalf("SendImpedanceData() error = %v", err)
+				}
+			}
+
+			numbers := spectrumOrder(inner)
+			if len(numbers) != len(tt.wantNumbers) {
+				t.Fatalf("delivered spectra = %v, want %v", numbers, tt.wantNumbers)
+			}
+			for i := range numbers {
+				if numbers[i] != tt.wantNumbers[i] {
+					t.Errorf("delivered spectra = %v, want %v", numbers, tt.wantNumbers)
+					break
+				}
+			}
+			stats := sender.Stats()
+			if stats.Dropped != tt.wantDropped || stats.Reordered != tt.wantReordered {
+				t.Errorf("Stats() = %+v, want %d dropped and %d reordered", stats, tt.wantDropped, tt.wantReordered)
+			}
+		})
+	}
+}
+
+func TestImpairedSender_CloseReleasesHeldPayload(t *testing.T) {
+	inner := NewMockSender()
+	sender, _ := NewImpairedSender(inner, ImpairmentOptions{ReorderPercent: 100})
+	sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{1}})
+	if n := len(inner.Payloads()); n != 0 {
+		t.Fatalf("%d payloads delivered before Close, want the first held back", n)
+	}
+	if err := sender.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if n := len(inner.Payloads()); n != 1 {
+		t.Errorf("%d payloads delivered after Close, want 1", n)
+	}
+}
+
+func TestImpairedSender_LatencyAndJitter(t *testing.T) {
+	sender, _ := NewImpairedSender(NewMockSender(), ImpairmentOptions{Latency: 100 * time.Millisecond, Jitter: 20 * time.Millisecond, Seed: 7})
+	var mu sync.Mutex
+	var delays []time.Duration
+	sender.sleep = func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		delays = append(delays, d)
+	}
+	for i := 0; i < 50; i++ {
+		sender.SendEISMeasurement(signal.EISMeasurement{})
+	}
+	sender.Close()
+
+	if len(delays) != 50 {
+		t.Fatalf("%d delays, want 50", len(delays))
+	}
+	varied := false
+	for _, d := range delays {
+		if d < 80*time.Millisecond || d > 120*time.Millisecond {
+			t.Errorf("delay %v outside of 100ms ± 20ms", d)
+		}
+		varied = varied || d != delays[0]
+	}
+	if !varied {
+		t.Error("all delays are equal, want jitter")
+	}
+}
+
+func TestImpairedSender_LatencyDoesNotSerialize(t *testing.T) {
+	inner := NewMockSender()
+	sender, _ := NewImpairedSender(inner, ImpairmentOptions{Latency: 50 * time.Millisecond})
+	started := time.Now()
+	for i := 0; i < 20; i++ {
+		if err := sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{float64(i)}}); err != nil {
+			t.Fatalf("SendImpedanceData() error = %v", err)
+		}
+	}
+	if err := sender.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Serialized sends would take 20 latencies, concurrent ones about one
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("20 sends with 50ms latency took %v", elapsed)
+	}
+	if n := len(inner.Payloads()); n != 20 {
+		t.Errorf("%d payloads delivered after Close, want 20", n)
+	}
+}
+
+func TestImpairedSender_CountsFailedSends(t *testing.T) {
+	for _, latency := range []time.Duration{0, time.Millisecond} {
+		inner := NewMockSender()
+		inner.FailNext(1, errors.New("connection refused"))
+		sender, _ := NewImpairedSender(inner, ImpairmentOptions{Latency: latency})
+		sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{1}})
+		sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{2}})
+		sender.Close()
+
+		if stats := sender.Stats(); stats.Delivered != 1 || stats.Failed != 1 {
+			t.Errorf("latency %v: Stats() = %+v, want 1 delivered and 1 failed", latency, stats)
+		}
+	}
+}
+
+// Run with -race: delayed payloads reach the HTTP sender from their own
+// goroutines while health checks read its status
+func TestImpairedSender_DefaultSenderHealthIsRaceFree(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1)%2 == 0 {
+			http.Error(w, "collector overloaded", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	inner := NewSender(server.URL)
+	sender, _ := NewImpairedSender(inner, ImpairmentOptions{Latency: time.Millisecond, Jitter: time.Millisecond, Seed: 3})
+	done := make(chan struct{})
+	var checks sync.WaitGroup
+	checks.Add(1)
+	go func() {
+		defer checks.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				inner.IsHealthy()
+			}
+		}
+	}()
+	for i := range 10 {
+		sender.SendImpedanceData(signal.ImpedanceData{Frequencies: []float64{float64(i)}, Impedance: []complex128{1}})
+	}
+	sender.Close()
+	close(done)
+	checks.Wait()
+
+	if stats := sender.Stats(); stats.Delivered+stats.Failed != 10 {
+		t.Errorf("Stats() = %+v, want 10 payloads sent", stats)
+	}
+}
+
+func TestNewImpairedSender_Invalid(t *testing.T) {
+	for _, options := range []ImpairmentOptions{
+		{Latency: -time.Second},
+		{Jitter: -time.Second},
+		{DropPercent: 101},
+		{ReorderPercent: -1},
+	} {
+		if _, err := NewImpairedSender(NewMockSender(), options); err == nil {
+			t.Errorf("NewImpairedSender(%+v) succeeded, want an error", options)
+		}
+	}
+}
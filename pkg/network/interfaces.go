@@ -11,4 +11,34 @@ type Sender interface {
 	SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error
 	FormatAsJSON(data interface{}) (string, error)
 	IsHealthy() bool
-}
\ No newline at end of file
+}
+
+// GridCompactor is implemented by senders that can send batches in the
+// shared-grid schema, serializing a frequency grid common to all spectra once
+type GridCompactor interface {
+	UseSharedGrid(enabled bool)
+}
+
+// EndpointRouter is implemented by HTTP senders whose endpoint paths per
+// payload type can be selected, see EndpointPaths
+type EndpointRouter interface {
+	UseEndpoints(paths EndpointPaths) error
+}
+
+// RequestSigner is implemented by HTTP senders that can sign their requests,
+// see Signer
+type RequestSigner interface {
+	UseSigner(signer *Signer)
+}
+
+// DeliveryAuditor is implemented by senders that record delivery attempts
+// with the collector's acknowledgement themselves, see AuditLog
+type DeliveryAuditor interface {
+	UseAuditLog(audit *AuditLog)
+}
+
+// PayloadEncoder is implemented by JSON senders whose spectra layout,
+// precision and fields can be selected, see signal.EncodeOptions
+type PayloadEncoder interface {
+	UseEncoding(options signal.EncodeOptions)
+}
@@ -0,0 +1,350 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// IPCMessage is one gob encoded value on the local IPC stream. Type matches
+// the X-Data-Type header of the HTTP sender and selects the populated field.
+type IPCMessage struct {
+	Type        string
+	Measurement signal.EISMeasurement
+	Spectrum    signal.ImpedanceData
+	Batch       signal.ImpedanceBatch
+}
+
+// ipcSpectrum mirrors signal.ImpedanceData without its JSON marshaler, which
+// gob would otherwise use instead of the compact binary encoding. Spectra sent
+// in single precision fill the 32-bit fields instead, packed little-endian as
+// gob widens float32 to 64 bits; gob skips the empty fields.
+type ipcSpectrum struct {
+	ID            string
+	Sequence      uint64
+	Timestamp     time.Time
+	Impedance     []complex128
+	Frequencies   []float64
+	Impedance32   []byte // Real and imaginary float32 per point
+	Frequencies32 []byte
+	Metadata      signal.Metadata
+	Quality       *signal.ChunkQuality
+	QualityFlags  signal.QualityFlags
+	Stationarity  *signal.Stationarity
+	Features      *signal.SpectrumFeatures
+	Prediction    *signal.Prediction
+}
+
+// ipcMessage is the wire form of IPCMessage
+type ipcMessage struct {
+	Type        string
+	Measurement []signal.ImpedancePoint
+	Spectra     []ipcSpectrum
+	Iterations  []int
+	BatchID     string
+	Timestamp   time.Time
+}
+
+// UnixSender streams spectra gob encoded over a Unix domain socket to another
+// process on the same host, e.g. a fitting service, bypassing HTTP and JSON.
+// The connection is opened on first use and re-established after failures.
+type UnixSender struct {
+	path    string
+	clock   clock.Clock
+	mu      sync.Mutex
+	conn    net.Conn
+	encoder *gob.Encoder
+	healthy bool
+	single  bool
+	sendLog SendLog
+}
+
+// NewUnixSender creates a sender writing to the Unix socket at path
+func NewUnixSender(path string) (*UnixSender, error) {
+	return NewUnixSenderWithClock(path, clock.NewSystemClock())
+}
+
+// NewUnixSenderWithClock creates a Unix socket sender stamping batches with the given clock
+func NewUnixSenderWithClock(path string, c clock.Clock) (*UnixSender, error) {
+	if path == "" {
+		return nil, config.NewValidationError("IPCSocket", "socket path cannot be empty")
+	}
+	return &UnixSender{path: path, clock: clock.OrSystem(c), healthy: true}, nil
+}
+
+// SendEISMeasurement sends a complete EIS measurement
+func (us *UnixSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	return us.send(ipcMessage{Type: "EIS-Measurement", Measurement: measurement})
+}
+
+// SendImpedanceData sends impedance data
+func (us *UnixSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	if err := us.send(ipcMessage{Type: "Impedance-Data", Spectra: []ipcSpectrum{us.toIPCSpectrum(impedanceData)}}); err != nil {
+		return err
+	}
+	logSent(us.logMode(), "", impedanceData)
+	return nil
+}
+
+// SendBatchImpedanceData sends a batch of impedance data as one message
+func (us *UnixSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	now := us.clock.Now()
+	message := ipcMessage{
+		Type:       "Impedance-Batch",
+		BatchID:    fmt.Sprintf("batch_%d_%d", now.Unix(), len(batch)),
+		Timestamp:  now,
+		Spectra:    make([]ipcSpectrum, len(batch)),
+		Iterations: make([]int, len(batch)),
+	}
+	for i, item := range batch {
+		message.Spectra[i] = us.toIPCSpectrum(item.ImpedanceData)
+		message.Iterations[i] = item.Iteration
+	}
+	if err := us.send(message); err != nil {
+		return err
+	}
+	logSentBatch(us.logMode(), "", batch)
+	return nil
+}
+
+// send encodes a message, reconnecting once if the connection is broken
+func (us *UnixSender) send(message ipcMessage) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if us.conn == nil {
+			if err = us.connect(); err != nil {
+				break
+			}
+		}
+		us.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err = us.encoder.Encode(message); err == nil {
+			us.healthy = true
+			return nil
+		}
+		us.conn.Close()
+		us.conn = nil
+	}
+
+	us.healthy = false
+	return config.NewNetworkError("unix://"+us.path, 0, fmt.Errorf("failed to send %s: %w", message.Type, err))
+}
+
+// connect dials the socket; us.mu must be held. Every connection starts a
+// new gob stream, so the type information is sent again.
+func (us *UnixSender) connect() error {
+	conn, err := net.DialTimeout("unix", us.path, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	log.Printf("Connected IPC stream to %s", us.path)
+	us.conn = conn
+	us.encoder = gob.NewEncoder(conn)
+	return nil
+}
+
+// FormatAsJSON formats data as pretty-printed JSON
+func (us *UnixSender) FormatAsJSON(data interface{}) (string, error) {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", config.NewProcessingError("JSON formatting", config.ErrJSONMarshalFailed)
+	}
+	return string(jsonData), nil
+}
+
+// UseEncoding selects single-precision spectra with options.Float32; the
+// other options only apply to JSON
+func (us *UnixSender) UseEncoding(options signal.EncodeOptions) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.single = options.Float32
+}
+
+// UseSendLog selects what is logged about successfully sent spectra
+func (us *UnixSender) UseSendLog(mode SendLog) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.sendLog = mode
+}
+
+// logMode returns the selected send logging
+func (us *UnixSender) logMode() SendLog {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.sendLog
+}
+
+// IsHealthy returns false after a message could not be delivered
+func (us *UnixSender) IsHealthy() bool {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.healthy
+}
+
+// Close closes the socket connection, if any
+func (us *UnixSender) Close() error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.conn == nil {
+		return nil
+	}
+	err := us.conn.Close()
+	us.conn = nil
+	return err
+}
+
+// IPCDecoder reads the messages written by a UnixSender from one connection
+type IPCDecoder struct {
+	decoder *gob.Decoder
+}
+
+// NewIPCDecoder creates a decoder reading from r
+func NewIPCDecoder(r io.Reader) *IPCDecoder {
+	return &IPCDecoder{decoder: gob.NewDecoder(r)}
+}
+
+// Next decodes the next message; it returns io.EOF once the sender disconnects
+func (d *IPCDecoder) Next() (IPCMessage, error) {
+	var wire ipcMessage
+	if err := d.decoder.Decode(&wire); err != nil {
+		return IPCMessage{}, err
+	}
+
+	message := IPCMessage{Type: wire.Type, Measurement: wire.Measurement}
+	switch wire.Type {
+	case "Impedance-Data":
+		if len(wire.Spectra) != 1 {
+			return IPCMessage{}, config.NewProcessingError("IPC decoding", fmt.Errorf("expected 1 spectrum, got %d", len(wire.Spectra)))
+		}
+		message.Spectrum = fromIPCSpectrum(wire.Spectra[0])
+	case "Impedance-Batch":
+		message.Batch = signal.ImpedanceBatch{
+			BatchID:   wire.BatchID,
+			Timestamp: wire.Timestamp,
+			Spectra:   make([]signal.ImpedanceDataWithIteration, len(wire.Spectra)),
+		}
+		for i, spectrum := range wire.Spectra {
+			message.Batch.Spectra[i] = signal.ImpedanceDataWithIteration{ImpedanceData: fromIPCSpectrum(spectrum)}
+			if i < len(wire.Iterations) {
+				message.Batch.Spectra[i].Iteration = wire.Iterations[i]
+			}
+		}
+	}
+	return message, nil
+}
+
+// toIPCSpectrum converts impedance data to its wire form; magnitude and phase
+// are left out as the receiver derives them from the complex impedance
+func (us *UnixSender) toIPCSpectrum(data signal.ImpedanceData) ipcSpectrum {
+	us.mu.Lock()
+	single := us.single
+	us.mu.Unlock()
+
+	if single {
+		data32 := data.ToFloat32()
+		return ipcSpectrum{
+			ID:            data.ID,
+			Sequence:      data.Sequence,
+			Timestamp:     data.Timestamp,
+			Impedance32:   packComplex64(data32.Impedance),
+			Frequencies32: packFloat32(data32.Frequencies),
+			Metadata:      data.Metadata,
+			Quality:       data.Quality,
+			QualityFlags:  data.QualityFlags,
+			Stationarity:  data.Stationarity,
+			Features:      data.Features,
+			Prediction:    data.Prediction,
+		}
+	}
+	return ipcSpectrum{
+		ID:           data.ID,
+		Sequence:     data.Sequence,
+		Timestamp:    data.Timestamp,
+		Impedance:    data.Impedance,
+		Frequencies:  data.Frequencies,
+		Metadata:     data.Metadata,
+		Quality:      data.Quality,
+		QualityFlags: data.QualityFlags,
+		Stationarity: data.Stationarity,
+		Features:     data.Features,
+		Prediction:   data.Prediction,
+	}
+}
+
+// fromIPCSpectrum restores impedance data including magnitude and phase
+func fromIPCSpectrum(spectrum ipcSpectrum) signal.ImpedanceData {
+	data := signal.ImpedanceData{
+		Identity:     signal.Identity{ID: spectrum.ID, Sequence: spectrum.Sequence},
+		Timestamp:    spectrum.Timestamp,
+		Impedance:    spectrum.Impedance,
+		Frequencies:  spectrum.Frequencies,
+		Metadata:     spectrum.Metadata,
+		Quality:      spectrum.Quality,
+		QualityFlags: spectrum.QualityFlags,
+		Stationarity: spectrum.Stationarity,
+		Features:     spectrum.Features,
+		Prediction:   spectrum.Prediction,
+	}
+	if spectrum.Impedance == nil && spectrum.Impedance32 != nil {
+		data.Impedance = signal.ComplexToFloat64(unpackComplex64(spectrum.Impedance32))
+	}
+	if spectrum.Frequencies == nil && spectrum.Frequencies32 != nil {
+		data.Frequencies = signal.FloatsToFloat64(unpackFloat32(spectrum.Frequencies32))
+	}
+	data.Magnitude, data.Phase = data.CalculateMagnitudePhase()
+	return data
+}
+
+// packFloat32 stores values as 4 little-endian bytes each
+func packFloat32(values []float32) []byte {
+	if values == nil {
+		return nil
+	}
+	packed := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(packed[4*i:], math.Float32bits(v))
+	}
+	return packed
+}
+
+// unpackFloat32 restores the values written by packFloat32; a trailing
+// partial value is ignored
+func unpackFloat32(packed []byte) []float32 {
+	values := make([]float32, len(packed)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(packed[4*i:]))
+	}
+	return values
+}
+
+// packComplex64 stores the real and imaginary part of every value in turn
+func packComplex64(values []complex64) []byte {
+	parts := make([]float32, 0, 2*len(values))
+	for _, v := range values {
+		parts = append(parts, real(v), imag(v))
+	}
+	return packFloat32(parts)
+}
+
+// unpackComplex64 restores the values written by packComplex64
+func unpackComplex64(packed []byte) []complex64 {
+	parts := unpackFloat32(packed)
+	values := make([]complex64, len(parts)/2)
+	for i := range values {
+		values[i] = complex(parts[2*i], parts[2*i+1])
+	}
+	return values
+}
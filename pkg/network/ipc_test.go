@@ -0,0 +1,125 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"math"
+	"math/cmplx"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestUnixSender_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "masterapp.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []IPCMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var messages []IPCMessage
+		decoder := NewIPCDecoder(conn)
+		for {
+			message, err := decoder.Next()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("Next() error = %v", err)
+				}
+				break
+			}
+			messages = append(messages, message)
+		}
+		received <- messages
+	}()
+
+	sender, err := NewUnixSender(path)
+	if err != nil {
+		t.Fatalf("NewUnixSender() error = %v", err)
+	}
+	spectrum := signal.ImpedanceData{
+		Identity:    signal.NewIdentity(),
+		Frequencies: []float64{1, 10},
+		Impedance:   []complex128{100 - 5i, 90 - 20i},
+		Metadata:    signal.Metadata{Unit: "Ω", Channel: "ch1"},
+	}
+	if err := sender.SendImpedanceData(spectrum); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+	if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum, Iteration: 7}}); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+	sender.Close()
+
+	messages := <-received
+	if len(messages) != 2 {
+		t.Fatalf("received %d messages, want 2", len(messages))
+	}
+
+	got := messages[0].Spectrum
+	if messages[0].Type != "Impedance-Data" || got.ID != spectrum.ID || got.Impedance[1] != spectrum.Impedance[1] || got.Metadata.Channel != "ch1" {
+		t.Errorf("spectrum = %+v, want %+v", got, spectrum)
+	}
+	if len(got.Magnitude) != 2 || got.Magnitude[0] == 0 {
+		t.Errorf("magnitude not restored: %v", got.Magnitude)
+	}
+
+	batch := messages[1].Batch
+	if messages[1].Type != "Impedance-Batch" || len(batch.Spectra) != 1 || batch.Spectra[0].Iteration != 7 {
+		t.Errorf("batch = %+v", batch)
+	}
+}
+
+func TestUnixSender_Float32(t *testing.T) {
+	spectrum := signal.ImpedanceData{Identity: signal.NewIdentity()}
+	for i := 0; i < 1000; i++ {
+		spectrum.Frequencies = append(spectrum.Frequencies, float64(i)/3)
+		spectrum.Impedance = append(spectrum.Impedance, complex(100/float64(i+1), -math.Pi*float64(i)))
+	}
+
+	encoded := func(single bool) (*bytes.Buffer, error) {
+		sender, err := NewUnixSender("unused.sock")
+		if err != nil {
+			return nil, err
+		}
+		sender.UseEncoding(signal.EncodeOptions{Float32: single})
+		var buf bytes.Buffer
+		message := ipcMessage{Type: "Impedance-Data", Spectra: []ipcSpectrum{sender.toIPCSpectrum(spectrum)}}
+		return &buf, gob.NewEncoder(&buf).Encode(message)
+	}
+	double, err := encoded(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := encoded(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Len() > double.Len()*55/100 {
+		t.Errorf("float32 message is %d bytes, want at most 55%% of the %d byte float64 message", single.Len(), double.Len())
+	}
+
+	message, err := NewIPCDecoder(single).Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	got := message.Spectrum
+	if len(got.Impedance) != len(spectrum.Impedance) || len(got.Frequencies) != len(spectrum.Frequencies) || len(got.Magnitude) != len(spectrum.Impedance) {
+		t.Fatalf("decoded %d points, %d frequencies, %d magnitudes, want %d", len(got.Impedance), len(got.Frequencies), len(got.Magnitude), len(spectrum.Impedance))
+	}
+	for i, z := range spectrum.Impedance {
+		if cmplx.Abs(got.Impedance[i]-z) > 1e-7*cmplx.Abs(z) || math.Abs(got.Frequencies[i]-spectrum.Frequencies[i]) > 1e-7*spectrum.Frequencies[i] {
+			t.Fatalf("point %d = %v at %g Hz, want %v at %g Hz", i, got.Impedance[i], got.Frequencies[i], z, spectrum.Frequencies[i])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// PayloadKind names the Sender method that delivered a payload
+type PayloadKind string
+
+const (
+	PayloadMeasurement PayloadKind = "measurement"
+	PayloadSpectrum    PayloadKind = "spectrum"
+	PayloadBatch       PayloadKind = "batch"
+)
+
+// Payload is one call recorded by a MockSender; only the field matching Kind is set
+type Payload struct {
+	Kind        PayloadKind
+	Measurement signal.EISMeasurement
+	Spectrum    signal.ImpedanceData
+	Batch       []signal.ImpedanceDataWithIteration
+	Err         error // Error returned to the caller; failed payloads are recorded too
+}
+
+// MockSender is a Sender that records payloads in memory instead of sending
+// them, for tests and applications that consume spectra in process. Errors
+// and latency can be injected. It is safe for concurrent use.
+type MockSender struct {
+	mu       sync.Mutex
+	payloads []Payload
+	latency  time.Duration
+	err      error
+	failures int // Remaining calls failing with err; -1 = all
+	healthy  bool
+}
+
+// NewMockSender creates a healthy mock sender accepting every payload
+func NewMockSender() *MockSender {
+	return &MockSender{healthy: true}
+}
+
+// SetLatency delays every send call by d
+func (ms *MockSender) SetLatency(d time.Duration) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.latency = d
+}
+
+// FailWith makes every following call return err; nil restores success
+func (ms *MockSender) FailWith(err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.err, ms.failures = err, -1
+	if err == nil {
+		ms.failures = 0
+	}
+}
+
+// FailNext makes the next n calls return err
+func (ms *MockSender) FailNext(n int, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.err, ms.failures = err, n
+}
+
+// SendEISMeasurement records the measurement
+func (ms *MockSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	return ms.record(Payload{Kind: PayloadMeasurement, Measurement: measurement})
+}
+
+// SendImpedanceData records the spectrum
+func (ms *MockSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	return ms.record(Payload{Kind: PayloadSpectrum, Spectrum: impedanceData})
+}
+
+// SendBatchImpedanceData records the batch
+func (ms *MockSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	return ms.record(Payload{Kind: PayloadBatch, Batch: append([]signal.ImpedanceDataWithIteration(nil), batch...)})
+}
+
+// record waits for the configured latency and stores the payload with the injected error
+func (ms *MockSender) record(payload Payload) error {
+	ms.mu.Lock()
+	latency := ms.latency
+	ms.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.failures != 0 {
+		payload.Err = ms.err
+		if ms.failures > 0 {
+			ms.failures--
+		}
+	}
+	ms.healthy = payload.Err == nil
+	ms.payloads = append(ms.payloads, payload)
+	return payload.Err
+}
+
+// FormatAsJSON formats data as indented JSON like the HTTP sender
+func (ms *MockSender) FormatAsJSON(data interface{}) (string, error) {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", config.NewProcessingError("JSON formatting", config.ErrJSONMarshalFailed)
+	}
+	return string(jsonData), nil
+}
+
+// IsHealthy reports whether the last call succeeded
+func (ms *MockSender) IsHealthy() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.healthy
+}
+
+// Payloads returns a copy of all recorded calls in order
+func (ms *MockSender) Payloads() []Payload {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]Payload(nil), ms.payloads...)
+}
+
+// Spectra returns every successfully delivered spectrum in order, with
+// batches flattened
+func (ms *MockSender) Spectra() []signal.ImpedanceData {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var spectra []signal.ImpedanceData
+	for _, payload := range ms.payloads {
+		if payload.Err != nil {
+			continue
+		}
+		switch payload.Kind {
+		case PayloadSpectrum:
+			spectra = append(spectra, payload.Spectrum)
+		case PayloadBatch:
+			for _, item := range payload.Batch {
+				spectra = append(spectra, item.ImpedanceData)
+			}
+		}
+	}
+	return spectra
+}
+
+// Reset forgets the recorded payloads and injected errors
+func (ms *MockSender) Reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.payloads, ms.err, ms.failures, ms.healthy = nil, nil, 0, true
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestMockSender_RecordsPayloads(t *testing.T) {
+	ms := NewMockSender()
+	var sender Sender = ms
+
+	spectrum := signal.ImpedanceData{Frequencies: []float64{1}, Impedance: []complex128{complex(10, -1)}}
+	sender.SendImpedanceData(spectrum)
+	sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{Iteration: 1, ImpedanceData: spectrum}, {Iteration: 2, ImpedanceData: spectrum}})
+	sender.SendEISMeasurement(signal.EISMeasurement{{Frequency: 1, Real: 10, Imag: -1}})
+
+	payloads := ms.Payloads()
+	kinds := []PayloadKind{PayloadSpectrum, PayloadBatch, PayloadMeasurement}
+	if len(payloads) != len(kinds) {
+		t.Fatalf("recorded %d payloads, want %d", len(payloads), len(kinds))
+	}
+	for i, kind := range kinds {
+		if payloads[i].Kind != kind {
+			t.Errorf("payload %d kind = %s, want %s", i, payloads[i].Kind, kind)
+		}
+	}
+	if spectra := ms.Spectra(); len(spectra) != 3 {
+		t.Errorf("Spectra() returned %d spectra, want 3", len(spectra))
+	}
+
+	ms.Reset()
+	if len(ms.Payloads()) != 0 {
+		t.Error("Reset() kept payloads")
+	}
+}
+
+func TestMockSender_InjectedErrors(t *testing.T) {
+	errDown := errors.New("collector down")
+	tests := []struct {
+		name    string
+		inject  func(ms *MockSender)
+		wantErr []bool
+	}{
+		{"none", func(ms *MockSender) {}, []bool{false, false, false}},
+		{"next two", func(ms *MockSender) { ms.FailNext(2, errDown) }, []bool{true, true, false}},
+		{"all", func(ms *MockSender) { ms.FailWith(errDown) }, []bool{true, true, true}},
+		{"cleared", func(ms *MockSender) { ms.FailWith(errDown); ms.FailWith(nil) }, []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMockSender()
+			tt.inject(ms)
+			for i, wantErr := range tt.wantErr {
+				err := ms.SendImpedanceData(signal.ImpedanceData{})
+				if (err != nil) != wantErr {
+					t.Errorf("call %d error = %v, wantErr %v", i, err, wantErr)
+				}
+				if ms.IsHealthy() == wantErr {
+					t.Errorf("call %d IsHealthy() = %v after error %v", i, ms.IsHealthy(), err)
+				}
+			}
+			failed := 0
+			for _, wantErr := range tt.wantErr {
+				if wantErr {
+					failed++
+				}
+			}
+			if got := len(ms.Spectra()); got != len(tt.wantErr)-failed {
+				t.Errorf("Spectra() returned %d spectra, want %d delivered", got, len(tt.wantErr)-failed)
+			}
+		})
+	}
+}
+
+func TestMockSender_Latency(t *testing.T) {
+	ms := NewMockSender()
+	ms.SetLatency(20 * time.Millisecond)
+
+	start := time.Now()
+	ms.SendImpedanceData(signal.ImpedanceData{})
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("send took %v, want at least the 20ms latency", elapsed)
+	}
+}
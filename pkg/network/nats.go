@@ -0,0 +1,376 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsDefaultPort is the client port of NATS servers
+const natsDefaultPort = "4222"
+
+var errNATSClosed = errors.New("nats connection closed")
+
+// natsInfo is the part of the server's INFO message the client needs
+type natsInfo struct {
+	Headers     bool  `json:"headers"`
+	MaxPayload  int64 `json:"max_payload"`
+	TLSRequired bool  `json:"tls_required"`
+}
+
+// natsConnect is the CONNECT message sent after INFO
+type natsConnect struct {
+	Verbose      bool   `json:"verbose"`
+	Pedantic     bool   `json:"pedantic"`
+	Name         string `json:"name"`
+	Lang         string `json:"lang"`
+	Version      string `json:"version"`
+	Protocol     int    `json:"protocol"`
+	Headers      bool   `json:"headers"`
+	NoResponders bool   `json:"no_responders"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	AuthToken    string `json:"auth_token,omitempty"`
+}
+
+// natsReply is a message received on the connection's inbox
+type natsReply struct {
+	subject string
+	status  string // Header status line such as "503 No Responders", empty for plain messages
+	payload []byte
+}
+
+// natsHeader is one message header; a slice keeps the order stable
+type natsHeader struct {
+	key, value string
+}
+
+// natsConn is a minimal NATS client connection that publishes messages with
+// headers and receives the replies to a single inbox subscription
+type natsConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	inbox      string
+	maxPayload int64
+	writeMu    sync.Mutex
+	pongs      chan struct{}
+	replies    chan natsReply
+	mu         sync.Mutex
+	serverErr  error
+	closed     chan struct{}
+	once       sync.Once
+}
+
+// dialNATS connects to a nats:// or tls:// URL, authenticating with its user
+// info as user and password or, without password, as token
+func dialNATS(ctx context.Context, rawURL, name string) (*natsConn, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "nats" && parsed.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported nats scheme '%s'", parsed.Scheme)
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), natsDefaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	nc, err := natsHandshake(ctx, conn, parsed, name)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	nc.conn.SetDeadline(time.Time{})
+	go nc.readLoop()
+	return nc, nil
+}
+
+// natsHandshake reads INFO, upgrades to TLS if required, sends CONNECT and
+// subscribes to the inbox, confirming all of it with a PING round trip
+func natsHandshake(ctx context.Context, conn net.Conn, target *url.URL, name string) (*natsConn, error) {
+	reader := bufio.NewReader(conn)
+	line, err := readNATSLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, fmt.Errorf("expected INFO from nats server, got %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return nil, fmt.Errorf("invalid nats INFO: %w", err)
+	}
+	if !info.Headers {
+		return nil, errors.New("nats server does not support message headers (NATS 2.2 or later required)")
+	}
+
+	if target.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: target.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	connect := natsConnect{Name: name, Lang: "go", Version: "1", Protocol: 1, Headers: true, NoResponders: true}
+	if user := target.User; user != nil {
+		if password, ok := user.Password(); ok {
+			connect.User, connect.Pass = user.Username(), password
+		} else {
+			connect.AuthToken = user.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(connect)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	inbox := "_INBOX." + hex.EncodeToString(nonce)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connectJSON, inbox); err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "PONG":
+			return &natsConn{
+				conn:       conn,
+				reader:     reader,
+				inbox:      inbox,
+				maxPayload: info.MaxPayload,
+				pongs:      make(chan struct{}, 16),
+				replies:    make(chan natsReply, 16),
+				closed:     make(chan struct{}),
+			}, nil
+		case strings.HasPrefix(line, "-ERR"):
+			return nil, fmt.Errorf("nats server refused connection: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// readNATSLine reads one protocol line without its CRLF
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Publish writes a message with headers to subject; reply may be empty
+func (nc *natsConn) Publish(subject, reply string, headers []natsHeader, payload []byte, timeout time.Duration) error {
+	select {
+	case <-nc.closed:
+		return nc.closeReason()
+	default:
+	}
+
+	var header bytes.Buffer
+	header.WriteString("NATS/1.0\r\n")
+	for _, h := range headers {
+		header.WriteString(h.key + ": " + h.value + "\r\n")
+	}
+	header.WriteString("\r\n")
+	total := header.Len() + len(payload)
+	if nc.maxPayload > 0 && int64(total) > nc.maxPayload {
+		return fmt.Errorf("message of %d bytes exceeds the server's max_payload of %d", total, nc.maxPayload)
+	}
+
+	var message bytes.Buffer
+	message.Grow(total + len(subject) + len(reply) + 40)
+	message.WriteString("HPUB " + subject + " ")
+	if reply != "" {
+		message.WriteString(reply + " ")
+	}
+	message.WriteString(strconv.Itoa(header.Len()) + " " + strconv.Itoa(total) + "\r\n")
+	message.Write(header.Bytes())
+	message.Write(payload)
+	message.WriteString("\r\n")
+	return nc.write(message.Bytes(), timeout)
+}
+
+// Flush waits until the server has processed everything written so far
+func (nc *natsConn) Flush(timeout time.Duration) error {
+	if err := nc.write([]byte("PING\r\n"), timeout); err != nil {
+		return err
+	}
+	select {
+	case <-nc.pongs:
+		return nil
+	case <-nc.closed:
+		return nc.closeReason()
+	case <-time.After(timeout):
+		return errors.New("nats server did not answer PING in time")
+	}
+}
+
+// Request publishes a message and waits for the reply, e.g. a JetStream ack
+func (nc *natsConn) Request(subject string, headers []natsHeader, payload []byte, timeout time.Duration) (natsReply, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return natsReply{}, err
+	}
+	reply := nc.inbox + "." + hex.EncodeToString(nonce)
+	if err := nc.Publish(subject, reply, headers, payload, timeout); err != nil {
+		return natsReply{}, err
+	}
+
+	expired := time.After(timeout)
+	for {
+		select {
+		case message := <-nc.replies:
+			if message.subject == reply {
+				return message, nil
+			}
+			// A late reply to an earlier request that timed out
+		case <-nc.closed:
+			return natsReply{}, nc.closeReason()
+		case <-expired:
+			return natsReply{}, errors.New("no reply from nats server in time")
+		}
+	}
+}
+
+// write sends raw protocol bytes
+func (nc *natsConn) write(data []byte, timeout time.Duration) error {
+	nc.writeMu.Lock()
+	defer nc.writeMu.Unlock()
+	if timeout > 0 {
+		nc.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	_, err := nc.conn.Write(data)
+	return err
+}
+
+// readLoop answers pings and dispatches PONGs, inbox messages and errors
+func (nc *natsConn) readLoop() {
+	defer nc.markClosed(nil)
+	for {
+		line, err := readNATSLine(nc.reader)
+		if err != nil {
+			return
+		}
+		switch {
+		case line == "PING":
+			if err := nc.write([]byte("PONG\r\n"), 5*time.Second); err != nil {
+				return
+			}
+		case line == "PONG":
+			select {
+			case nc.pongs <- struct{}{}:
+			default:
+			}
+		case strings.HasPrefix(line, "MSG ") || strings.HasPrefix(line, "HMSG "):
+			message, err := nc.readMessage(line)
+			if err != nil {
+				return
+			}
+			select {
+			case nc.replies <- message:
+			default: // Nobody waits for it any more
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			nc.markClosed(fmt.Errorf("nats server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+			return
+		}
+	}
+}
+
+// readMessage reads the payload announced by a MSG or HMSG line:
+// MSG <subject> <sid> [reply] <size> or HMSG <subject> <sid> [reply] <header size> <size>
+func (nc *natsConn) readMessage(line string) (natsReply, error) {
+	fields := strings.Fields(line)
+	withHeaders := fields[0] == "HMSG"
+	minFields := 4
+	if withHeaders {
+		minFields = 5
+	}
+	if len(fields) < minFields {
+		return natsReply{}, fmt.Errorf("malformed nats message line %q", line)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return natsReply{}, err
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(nc.reader, data); err != nil {
+		return natsReply{}, err
+	}
+	data = data[:size]
+
+	message := natsReply{subject: fields[1], payload: data}
+	if withHeaders {
+		headerSize, err := strconv.Atoi(fields[len(fields)-2])
+		if err != nil || headerSize > size {
+			return natsReply{}, fmt.Errorf("malformed nats message line %q", line)
+		}
+		status, _, _ := strings.Cut(string(data[:headerSize]), "\r\n")
+		message.status = strings.TrimSpace(strings.TrimPrefix(status, "NATS/1.0"))
+		message.payload = data[headerSize:]
+	}
+	return message, nil
+}
+
+// Done returns a channel closed once the connection is no longer usable
+func (nc *natsConn) Done() <-chan struct{} {
+	return nc.closed
+}
+
+// closeReason returns the server error that ended the connection, if any
+func (nc *natsConn) closeReason() error {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if nc.serverErr != nil {
+		return nc.serverErr
+	}
+	return errNATSClosed
+}
+
+// markClosed records why the connection ended and closes it once
+func (nc *natsConn) markClosed(reason error) {
+	nc.once.Do(func() {
+		nc.mu.Lock()
+		nc.serverErr = reason
+		nc.mu.Unlock()
+		close(nc.closed)
+		nc.conn.Close()
+	})
+}
+
+// Close closes the connection
+func (nc *natsConn) Close() error {
+	nc.markClosed(nil)
+	return nil
+}
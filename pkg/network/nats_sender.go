@@ -0,0 +1,333 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// NATSCellLabel is the metadata label naming the cell in NATS subjects
+const NATSCellLabel = "cell"
+
+// NATSOptions selects the subjects and delivery guarantee of a NATSSender
+type NATSOptions struct {
+	Subject   string // Subject prefix; messages go to <Subject>.<cell>.<impedance|batch|measurement>
+	JetStream bool   // Wait for the JetStream ack of a stream capturing the subjects instead of a server round trip
+}
+
+// jsPubAck is the JetStream reply to a published message
+type jsPubAck struct {
+	Stream    string `json:"stream"`
+	Sequence  uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// NATSSender publishes measurements to a NATS server, one subject per cell
+// and payload kind, so plant consumers subscribe to exactly what they need.
+// Every message carries the Nats-Msg-Id, Content-Type and X-Data-Type
+// headers; the idempotency key as Nats-Msg-Id lets JetStream discard retried
+// duplicates. A send returns once the server (or with JetStream the stream)
+// has accepted the message. The connection is opened on first use and
+// re-established after failures.
+type NATSSender struct {
+	url         string
+	options     NATSOptions
+	clock       clock.Clock
+	dialTimeout time.Duration
+	sendTimeout time.Duration
+	mu          sync.Mutex
+	conn        *natsConn
+	healthy     bool
+	sharedGrid  bool
+	encoding    signal.EncodeOptions
+	sendLog     SendLog
+}
+
+// NewNATSSender creates a sender publishing to the NATS server at natsURL
+func NewNATSSender(natsURL string, options NATSOptions) (*NATSSender, error) {
+	return NewNATSSenderWithClock(natsURL, options, clock.NewSystemClock())
+}
+
+// NewNATSSenderWithClock creates a NATS sender stamping batches with the given clock
+func NewNATSSenderWithClock(natsURL string, options NATSOptions, c clock.Clock) (*NATSSender, error) {
+	if !strings.HasPrefix(natsURL, "nats://") && !strings.HasPrefix(natsURL, "tls://") {
+		return nil, config.NewNetworkError(natsURL, 0, config.ErrInvalidURL)
+	}
+	if err := ValidateNATSSubject(options.Subject); err != nil {
+		return nil, err
+	}
+	return &NATSSender{
+		url:         natsURL,
+		options:     options,
+		clock:       clock.OrSystem(c),
+		dialTimeout: 10 * time.Second,
+		sendTimeout: 10 * time.Second,
+		healthy:     true,
+	}, nil
+}
+
+// ValidateNATSSubject checks that subject can prefix the published subjects
+func ValidateNATSSubject(subject string) error {
+	if subject == "" {
+		return config.NewValidationError("NATSSubject", "subject prefix cannot be empty")
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" || strings.ContainsAny(token, " \t\r\n*>") {
+			return config.NewValidationError("NATSSubject", fmt.Sprintf("invalid subject prefix '%s'", subject))
+		}
+	}
+	return nil
+}
+
+// natsToken turns a metadata value into a single subject token
+func natsToken(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// natsCell returns the subject token of the cell a spectrum was measured on:
+// the "cell" label, else the channel, else "default"
+func natsCell(metadata signal.Metadata) string {
+	if cell := metadata.Labels[NATSCellLabel]; cell != "" {
+		return natsToken(cell)
+	}
+	if metadata.Channel != "" {
+		return natsToken(metadata.Channel)
+	}
+	return "default"
+}
+
+// subject builds <prefix>.<cell>.<metric>
+func (ns *NATSSender) subject(cell, metric string) string {
+	return ns.options.Subject + "." + cell + "." + metric
+}
+
+// SendEISMeasurement publishes a complete EIS measurement
+func (ns *NATSSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	jsonData, err := json.Marshal(measurement)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	return ns.publish(ns.subject("default", "measurement"), "EIS-Measurement", idempotencyKey(jsonData), jsonData)
+}
+
+// SendImpedanceData publishes impedance data to the subject of its cell
+func (ns *NATSSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	ns.mu.Lock()
+	encoding, sendLog := ns.encoding, ns.sendLog
+	ns.mu.Unlock()
+
+	jsonData, err := marshalImpedanceData(impedanceData, encoding)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	subject := ns.subject(natsCell(impedanceData.Metadata), "impedance")
+	if err := ns.publish(subject, "Impedance-Data", idempotencyKey(jsonData, impedanceData.ID), jsonData); err != nil {
+		return err
+	}
+	logSent(sendLog, "", impedanceData)
+	return nil
+}
+
+// SendBatchImpedanceData publishes a batch as one message, to the subject of
+// its cell if all spectra share one and to the "mixed" cell otherwise
+func (ns *NATSSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	now := ns.clock.Now()
+	batchData := signal.ImpedanceBatch{
+		BatchID:   fmt.Sprintf("batch_%d_%d", now.Unix(), len(batch)),
+		Timestamp: now,
+		Spectra:   batch,
+	}
+	ns.mu.Lock()
+	sharedGrid, encoding, sendLog := ns.sharedGrid, ns.encoding, ns.sendLog
+	ns.mu.Unlock()
+	if sharedGrid {
+		batchData = batchData.CompactGrid()
+	}
+	jsonData, err := marshalImpedanceBatch(batchData, encoding)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	cell := "default"
+	for i, item := range batch {
+		itemCell := natsCell(item.ImpedanceData.Metadata)
+		if i == 0 {
+			cell = itemCell
+		} else if itemCell != cell {
+			cell = "mixed"
+			break
+		}
+	}
+	if err := ns.publish(ns.subject(cell, "batch"), "Impedance-Batch", batchIdempotencyKey(batch), jsonData); err != nil {
+		return err
+	}
+	logSentBatch(sendLog, fmt.Sprintf("Successfully published batch of %d spectra", len(batch)), batch)
+	return nil
+}
+
+// publish delivers one message and waits for the server or stream to accept
+// it, reconnecting once if the current connection turns out to be broken
+func (ns *NATSSender) publish(subject, dataType, key string, payload []byte) error {
+	headers := []natsHeader{
+		{"Nats-Msg-Id", key},
+		{"Content-Type", "application/json"},
+		{"X-Data-Type", dataType},
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = ns.connect(); err != nil {
+			break
+		}
+		if err = ns.deliver(subject, headers, payload); err == nil {
+			ns.healthy = true
+			return nil
+		}
+		if _, rejected := err.(jetStreamError); rejected {
+			break // The server is fine, the stream refused the message
+		}
+		ns.conn.Close()
+		ns.conn = nil
+	}
+
+	ns.healthy = false
+	return config.NewNetworkError(redactURL(ns.url), 0, fmt.Errorf("failed to publish %s to %s: %w", dataType, subject, err))
+}
+
+// jetStreamError is a negative JetStream ack
+type jetStreamError struct {
+	message string
+}
+
+func (e jetStreamError) Error() string {
+	return "jetstream: " + e.message
+}
+
+// deliver publishes on the open connection; ns.mu must be held
+func (ns *NATSSender) deliver(subject string, headers []natsHeader, payload []byte) error {
+	if !ns.options.JetStream {
+		if err := ns.conn.Publish(subject, "", headers, payload, ns.sendTimeout); err != nil {
+			return err
+		}
+		return ns.conn.Flush(ns.sendTimeout)
+	}
+
+	reply, err := ns.conn.Request(subject, headers, payload, ns.sendTimeout)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply.status, "503") {
+		return jetStreamError{"no stream captures subject " + subject}
+	}
+	var ack jsPubAck
+	if err := json.Unmarshal(reply.payload, &ack); err != nil {
+		return jetStreamError{fmt.Sprintf("invalid ack %q", reply.payload)}
+	}
+	if ack.Error != nil {
+		return jetStreamError{fmt.Sprintf("%s (code %d)", ack.Error.Description, ack.Error.Code)}
+	}
+	return nil
+}
+
+// connect dials the server unless a live connection exists; ns.mu must be held
+func (ns *NATSSender) connect() error {
+	if ns.conn != nil {
+		select {
+		case <-ns.conn.Done():
+			ns.conn = nil
+		default:
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ns.dialTimeout)
+	defer cancel()
+	conn, err := dialNATS(ctx, ns.url, "masterapp")
+	if err != nil {
+		return err
+	}
+	log.Printf("Connected to NATS server %s", redactURL(ns.url))
+	ns.conn = conn
+	return nil
+}
+
+// redactURL removes credentials from a URL for logging
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	parsed.User = url.User("REDACTED")
+	return parsed.String()
+}
+
+// FormatAsJSON formats data as pretty-printed JSON
+func (ns *NATSSender) FormatAsJSON(data interface{}) (string, error) {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", config.NewProcessingError("JSON formatting", config.ErrJSONMarshalFailed)
+	}
+	return string(jsonData), nil
+}
+
+// IsHealthy returns false after a message could not be delivered
+func (ns *NATSSender) IsHealthy() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.healthy
+}
+
+// UseSharedGrid enables the shared-grid batch schema
+func (ns *NATSSender) UseSharedGrid(enabled bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.sharedGrid = enabled
+}
+
+// UseEncoding selects the layout, precision and fields of published spectra
+func (ns *NATSSender) UseEncoding(options signal.EncodeOptions) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.encoding = options
+}
+
+// UseSendLog selects what is logged about successfully published spectra
+func (ns *NATSSender) UseSendLog(mode SendLog) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.sendLog = mode
+}
+
+// Close closes the NATS connection, if any
+func (ns *NATSSender) Close() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.conn == nil {
+		return nil
+	}
+	err := ns.conn.Close()
+	ns.conn = nil
+	return err
+}
@@ -0,0 +1,160 @@
+package network_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/network/sendertest"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestNATSSender_SubjectsPerCell(t *testing.T) {
+	collector := sendertest.NewNATSCollector()
+	defer collector.Close()
+	sender, err := network.NewNATSSender(collector.URL(), network.NATSOptions{Subject: "plant.eis"})
+	if err != nil {
+		t.Fatalf("NewNATSSender() error = %v", err)
+	}
+	defer sender.Close()
+
+	spectrum := func(metadata signal.Metadata) signal.ImpedanceData {
+		return signal.ImpedanceData{
+			Identity:    signal.NewIdentity(),
+			Frequencies: []float64{1},
+			Impedance:   []complex128{complex(10, -2)},
+			Metadata:    metadata,
+		}
+	}
+	cellA := spectrum(signal.Metadata{Channel: "WE-RE", Labels: map[string]string{"cell": "A.7"}})
+	channel := spectrum(signal.Metadata{Channel: "WE-RE"})
+	sends := []func() error{
+		func() error { return sender.SendImpedanceData(cellA) },
+		func() error { return sender.SendImpedanceData(channel) },
+		func() error { return sender.SendImpedanceData(spectrum(signal.Metadata{})) },
+		func() error {
+			return sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: cellA}, {ImpedanceData: cellA, Iteration: 1}})
+		},
+		func() error {
+			return sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: cellA}, {ImpedanceData: channel, Iteration: 1}})
+		},
+		func() error {
+			return sender.SendEISMeasurement(signal.EISMeasurement{{Frequency: 1, Real: 10, Imag: -2}})
+		},
+	}
+	for i, send := range sends {
+		if err := send(); err != nil {
+			t.Fatalf("send %d error = %v", i, err)
+		}
+	}
+
+	want := []string{
+		"plant.eis.A_7.impedance",
+		"plant.eis.WE-RE.impedance",
+		"plant.eis.default.impedance",
+		"plant.eis.A_7.batch",
+		"plant.eis.mixed.batch",
+		"plant.eis.default.measurement",
+	}
+	deliveries := collector.Deliveries()
+	if len(deliveries) != len(want) {
+		t.Fatalf("collector received %d messages, want %d", len(deliveries), len(want))
+	}
+	for i, subject := range want {
+		if deliveries[i].Path != subject {
+			t.Errorf("message %d subject = %q, want %q", i, deliveries[i].Path, subject)
+		}
+	}
+}
+
+// natsScriptedServer accepts one connection, reports its CONNECT message and
+// answers every HPUB with a reply subject with a 503 No Responders status
+func natsScriptedServer(t *testing.T, connects chan<- string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO {\"headers\":true,\"max_payload\":1048576}\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0:
+			case fields[0] == "CONNECT":
+				connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			case fields[0] == "PING":
+				fmt.Fprintf(conn, "PONG\r\n")
+			case fields[0] == "HPUB" && len(fields) == 5:
+				size, _ := strconv.Atoi(fields[4])
+				io.ReadFull(reader, make([]byte, size+2))
+				status := "NATS/1.0 503\r\n\r\n"
+				fmt.Fprintf(conn, "HMSG %s 1 %d %d\r\n%s\r\n", fields[2], len(status), len(status), status)
+			}
+		}
+	}()
+	return listener
+}
+
+func TestNATSSender_JetStreamNoResponders(t *testing.T) {
+	connects := make(chan string, 1)
+	listener := natsScriptedServer(t, connects)
+	defer listener.Close()
+
+	sender, err := network.NewNATSSender("nats://masterapp:secret@"+listener.Addr().String(), network.NATSOptions{Subject: "eis", JetStream: true})
+	if err != nil {
+		t.Fatalf("NewNATSSender() error = %v", err)
+	}
+	defer sender.Close()
+
+	err = sender.SendImpedanceData(signal.ImpedanceData{Identity: signal.NewIdentity(), Frequencies: []float64{1}, Impedance: []complex128{1}})
+	if err == nil || !strings.Contains(err.Error(), "no stream captures subject eis.default.impedance") {
+		t.Errorf("SendImpedanceData() error = %v, want a missing stream error", err)
+	}
+	if sender.IsHealthy() {
+		t.Error("sender healthy after a rejected message")
+	}
+
+	var connect map[string]interface{}
+	if err := json.Unmarshal([]byte(<-connects), &connect); err != nil {
+		t.Fatalf("invalid CONNECT: %v", err)
+	}
+	if connect["user"] != "masterapp" || connect["pass"] != "secret" || connect["headers"] != true {
+		t.Errorf("CONNECT = %+v, want credentials from the URL and headers enabled", connect)
+	}
+}
+
+func TestValidateNATSSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		wantErr bool
+	}{
+		{"eis", false},
+		{"plant.line-2.eis", false},
+		{"", true},
+		{"eis.", true},
+		{"eis.*", true},
+		{"eis.>", true},
+		{"my eis", true},
+	}
+	for _, tt := range tests {
+		if err := network.ValidateNATSSubject(tt.subject); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateNATSSubject(%q) error = %v, wantErr %v", tt.subject, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// ProbeOptions configures readiness probing of the target server
+type ProbeOptions struct {
+	HealthPath     string
+	Timeout        time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// HealthURL derives the health endpoint URL from the target's scheme and host
+func HealthURL(targetURL, healthPath string) (string, error) {
+	parsed, err := url.Parse(targetURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", config.NewNetworkError(targetURL, 0, config.ErrInvalidURL)
+	}
+
+	health := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host, User: parsed.User}
+	ref, err := url.Parse(healthPath)
+	if err != nil {
+		return "", config.NewValidationError("HealthPath", fmt.Sprintf("invalid health path '%s'", healthPath))
+	}
+	return health.ResolveReference(ref).String(), nil
+}
+
+// WaitForTarget polls the target's health endpoint with exponential backoff
+// until it answers with a 2xx status or the probe timeout expires
+func WaitForTarget(ctx context.Context, targetURL string, opts ProbeOptions) error {
+	healthURL, err := HealthURL(targetURL, opts.HealthPath)
+	if err != nil {
+		return err
+	}
+
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = opts.InitialBackoff
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	backoff := opts.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		status, err := probeOnce(ctx, client, healthURL)
+		if err == nil && status >= 200 && status < 300 {
+			log.Printf("Target ready at %s after %d attempt(s)", healthURL, attempt)
+			return nil
+		}
+
+		if err != nil {
+			log.Printf("Target not ready (%s): %v; retrying in %v", healthURL, err, backoff)
+		} else {
+			log.Printf("Target not ready (%s): status %d; retrying in %v", healthURL, status, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return config.NewNetworkError(healthURL, status, config.ErrServerUnavailable)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// probeOnce issues a single GET against the health endpoint
+func probeOnce(ctx context.Context, client *http.Client, healthURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthURL(t *testing.T) {
+	tests := []struct {
+		target string
+		path   string
+		want   string
+	}{
+		{"http://localhost:8080/eis-data", "/health", "http://localhost:8080/health"},
+		{"https://collector.example:443", "/api/ready", "https://collector.example:443/api/ready"},
+	}
+
+	for _, tt := range tests {
+		got, err := HealthURL(tt.target, tt.path)
+		if err != nil {
+			t.Fatalf("HealthURL(%s) error = %v", tt.target, err)
+		}
+		if got != tt.want {
+			t.Errorf("HealthURL(%s, %s) = %s, want %s", tt.target, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := HealthURL("not a url", "/health"); err == nil {
+		t.Errorf("expected error for invalid target URL")
+	}
+}
+
+func TestWaitForTarget(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	opts := ProbeOptions{HealthPath: "/health", Timeout: 5 * time.Second, InitialBackoff: 10 * time.Millisecond}
+	if err := WaitForTarget(context.Background(), server.URL+"/eis-data", opts); err != nil {
+		t.Fatalf("WaitForTarget() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("expected 3 probes, got %d", got)
+	}
+
+	opts.Timeout = 50 * time.Millisecond
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	if err := WaitForTarget(context.Background(), down.URL, opts); err == nil {
+		t.Errorf("expected timeout error for unavailable target")
+	}
+}
@@ -0,0 +1,71 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// RawHTTPSink posts raw chunks as gzip-compressed JSON to the collector
+type RawHTTPSink struct {
+	url    string
+	client *http.Client
+	signer *Signer
+}
+
+// NewRawHTTPSink creates a sink posting raw chunks to rawPath on the target's host
+func NewRawHTTPSink(targetURL, rawPath string) (*RawHTTPSink, error) {
+	rawURL, err := HealthURL(targetURL, rawPath)
+	if err != nil {
+		return nil, err
+	}
+	return &RawHTTPSink{
+		url:    rawURL,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// UseSigner signs every request with signer
+func (rs *RawHTTPSink) UseSigner(signer *Signer) {
+	rs.signer = signer
+}
+
+// WriteRawChunk sends the chunk
+func (rs *RawHTTPSink) WriteRawChunk(chunk signal.RawChunk) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(chunk); err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	if err := zw.Close(); err != nil {
+		return config.NewProcessingError("raw chunk compression", err)
+	}
+
+	req, err := http.NewRequest("POST", rs.url, &body)
+	if err != nil {
+		return config.NewNetworkError(rs.url, 0, fmt.Errorf("failed to create request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-Data-Type", "Raw-Chunk")
+	req.Header.Set(IdempotencyHeader, chunk.ID)
+	rs.signer.Sign(req, body.Bytes())
+
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return config.NewNetworkError(rs.url, 0, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return config.NewNetworkError(rs.url, resp.StatusCode, config.ErrInvalidHTTPResponse)
+	}
+	return nil
+}
@@ -2,38 +2,82 @@ package network
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"time"
+	"sync/atomic"
 
+	"github.com/adam/masterapp/pkg/clock"
 	"github.com/adam/masterapp/pkg/config"
 	"github.com/adam/masterapp/pkg/signal"
 )
 
+// IdempotencyHeader carries a key that is identical for every attempt to
+// deliver the same spectra, so collectors can discard duplicates after retries
+const IdempotencyHeader = "Idempotency-Key"
+
+// legacyBatchPath is appended to the target URL to reach the batch endpoint
+// unless EndpointPaths.Batch is set
+const legacyBatchPath = "/eis-data/batch"
+
+// EndpointPaths selects the collector routes of the payload types. A path is
+// resolved against the target host like the heartbeat and raw chunk paths;
+// empty paths keep the defaults: single spectra and measurements go to the
+// target URL itself, batches to the target URL followed by /eis-data/batch.
+type EndpointPaths struct {
+	Single string // Single spectra and EIS measurements
+	Batch  string // Batches of spectra
+}
+
 // DefaultSender implements HTTP-based data transmission
 type DefaultSender struct {
-	targetURL string
-	client    *http.Client
-	healthy   bool
+	targetURL  string
+	singleURL  string
+	batchURL   string
+	client     *http.Client
+	healthy    atomic.Bool // Read by health checks while delayed payloads are sent
+	clock      clock.Clock
+	sharedGrid bool
+	encoding   signal.EncodeOptions
+	sendLog    SendLog
+	signer     *Signer
+	audit      *AuditLog
 }
 
 // NewSender creates a new network data sender
 func NewSender(targetURL string) Sender {
+	return NewSenderWithClock(targetURL, clock.NewSystemClock())
+}
+
+// NewSenderWithClock creates a new network data sender stamping batches with the given clock
+func NewSenderWithClock(targetURL string, c clock.Clock) Sender {
+	return NewSenderWithTransport(targetURL, c, DefaultTransportOptions())
+}
+
+// NewSenderWithTransport creates a new network data sender whose connections
+// to the collector are tuned by opts
+func NewSenderWithTransport(targetURL string, c clock.Clock, opts TransportOptions) Sender {
 	// Validate URL
 	if _, err := url.Parse(targetURL); err != nil {
 		log.Printf("Warning: Invalid target URL %s: %v", targetURL, err)
 	}
 
-	return &DefaultSender{
+	ds := &DefaultSender{
 		targetURL: targetURL,
+		singleURL: targetURL,
+		batchURL:  targetURL + legacyBatchPath,
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   opts.RequestTimeout,
+			Transport: NewTransport(opts),
 		},
-		healthy: true,
+		clock: clock.OrSystem(c),
 	}
+	ds.healthy.Store(true)
+	return ds
 }
 
 // SendEISMeasurement sends a complete EIS measurement to the target server
@@ -44,33 +88,28 @@ func (ds *DefaultSender) SendEISMeasurement(measurement signal.EISMeasurement) e
 
 	jsonData, err := json.Marshal(measurement)
 	if err != nil {
-		ds.healthy = false
+		ds.healthy.Store(false)
 		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
 	}
 
-	req, err := http.NewRequest("POST", ds.targetURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", ds.singleURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, 0, fmt.Errorf("failed to create request: %w", err))
+		ds.healthy.Store(false)
+		return config.NewNetworkError(ds.singleURL, 0, fmt.Errorf("failed to create request: %w", err))
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Data-Type", "EIS-Measurement")
+	key := idempotencyKey(jsonData)
+	req.Header.Set(IdempotencyHeader, key)
+	ds.signer.Sign(req, jsonData)
 
-	resp, err := ds.client.Do(req)
-	if err != nil {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, 0, fmt.Errorf("failed to send request: %w", err))
+	if err := ds.post(req, ds.singleURL, "request", PayloadMeasurement, []string{key}); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, resp.StatusCode, config.ErrInvalidHTTPResponse)
-	}
-
-	ds.healthy = true
-	log.Printf("Successfully sent EIS measurement data")
+	ds.healthy.Store(true)
+	logSent(ds.sendLog, "Successfully sent EIS measurement data", measurement.ToImpedanceData())
 	return nil
 }
 
@@ -81,43 +120,40 @@ func (ds *DefaultSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWith
 	}
 
 	// Create batch with unique ID
+	now := ds.clock.Now()
 	batchData := signal.ImpedanceBatch{
-		BatchID:   fmt.Sprintf("batch_%d_%d", time.Now().Unix(), len(batch)),
-		Timestamp: time.Now(),
+		BatchID:   fmt.Sprintf("batch_%d_%d", now.Unix(), len(batch)),
+		Timestamp: now,
 		Spectra:   batch,
 	}
+	if ds.sharedGrid {
+		batchData = batchData.CompactGrid()
+	}
 
-	jsonData, err := json.Marshal(batchData)
+	jsonData, err := marshalImpedanceBatch(batchData, ds.encoding)
 	if err != nil {
-		ds.healthy = false
+		ds.healthy.Store(false)
 		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
 	}
 
-	// Use batch endpoint
-	batchURL := ds.targetURL + "/eis-data/batch"
+	batchURL := ds.batchURL
 	req, err := http.NewRequest("POST", batchURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		ds.healthy = false
+		ds.healthy.Store(false)
 		return config.NewNetworkError(batchURL, 0, fmt.Errorf("failed to create batch request: %w", err))
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Data-Type", "Impedance-Batch")
+	req.Header.Set(IdempotencyHeader, batchIdempotencyKey(batch))
+	ds.signer.Sign(req, jsonData)
 
-	resp, err := ds.client.Do(req)
-	if err != nil {
-		ds.healthy = false
-		return config.NewNetworkError(batchURL, 0, fmt.Errorf("failed to send batch request: %w", err))
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		ds.healthy = false
-		return config.NewNetworkError(batchURL, resp.StatusCode, config.ErrInvalidHTTPResponse)
+	if err := ds.post(req, batchURL, "batch request", PayloadBatch, batchAuditIDs(batch)); err != nil {
+		return err
 	}
 
-	ds.healthy = true
-	log.Printf("Successfully sent batch of %d spectra", len(batch))
+	ds.healthy.Store(true)
+	logSentBatch(ds.sendLog, fmt.Sprintf("Successfully sent batch of %d spectra", len(batch)), batch)
 	return nil
 }
 
@@ -127,36 +163,63 @@ func (ds *DefaultSender) SendImpedanceData(impedanceData signal.ImpedanceData) e
 		return config.NewNetworkError(ds.targetURL, 0, config.ErrInvalidURL)
 	}
 
-	jsonData, err := json.Marshal(impedanceData)
+	jsonData, err := marshalImpedanceData(impedanceData, ds.encoding)
 	if err != nil {
-		ds.healthy = false
+		ds.healthy.Store(false)
 		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
 	}
 
-	req, err := http.NewRequest("POST", ds.targetURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", ds.singleURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, 0, fmt.Errorf("failed to create request: %w", err))
+		ds.healthy.Store(false)
+		return config.NewNetworkError(ds.singleURL, 0, fmt.Errorf("failed to create request: %w", err))
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Data-Type", "Impedance-Data")
+	key := idempotencyKey(jsonData, impedanceData.ID)
+	req.Header.Set(IdempotencyHeader, key)
+	ds.signer.Sign(req, jsonData)
 
+	if err := ds.post(req, ds.singleURL, "request", PayloadSpectrum, []string{key}); err != nil {
+		return err
+	}
+
+	ds.healthy.Store(true)
+	logSent(ds.sendLog, fmt.Sprintf("Successfully sent impedance data at %v", impedanceData.Timestamp.Format("15:04:05")), impedanceData)
+	return nil
+}
+
+// post sends req to target and checks the collector's answer, recording the
+// outcome for the IDs of the payload in the audit log
+func (ds *DefaultSender) post(req *http.Request, target, what string, kind PayloadKind, ids []string) error {
 	resp, err := ds.client.Do(req)
 	if err != nil {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, 0, fmt.Errorf("failed to send request: %w", err))
+		ds.healthy.Store(false)
+		err = config.NewNetworkError(target, 0, fmt.Errorf("failed to send %s: %w", what, err))
+		ds.recordDelivery(kind, ids, target, 0, "", err)
+		return err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp)
 
+	var ack string
+	if ds.audit != nil {
+		ack = readAck(resp)
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		ds.healthy = false
-		return config.NewNetworkError(ds.targetURL, resp.StatusCode, config.ErrInvalidHTTPResponse)
+		ds.healthy.Store(false)
+		err = config.NewNetworkError(target, resp.StatusCode, config.ErrInvalidHTTPResponse)
 	}
+	ds.recordDelivery(kind, ids, target, resp.StatusCode, ack, err)
+	return err
+}
 
-	ds.healthy = true
-	log.Printf("Successfully sent impedance data at %v", impedanceData.Timestamp.Format("15:04:05"))
-	return nil
+// recordDelivery writes an attempt to the audit log, reporting audit
+// failures without failing the send
+func (ds *DefaultSender) recordDelivery(kind PayloadKind, ids []string, target string, status int, ack string, sendErr error) {
+	if err := ds.audit.Record(kind, ids, target, status, ack, sendErr); err != nil {
+		logAuditError(err)
+	}
 }
 
 // FormatAsJSON formats data as pretty-printed JSON
@@ -170,5 +233,112 @@ func (ds *DefaultSender) FormatAsJSON(data interface{}) (string, error) {
 
 // IsHealthy returns the current health status of the sender
 func (ds *DefaultSender) IsHealthy() bool {
-	return ds.healthy
+	return ds.healthy.Load()
+}
+
+// UseSharedGrid enables the shared-grid batch schema
+func (ds *DefaultSender) UseSharedGrid(enabled bool) {
+	ds.sharedGrid = enabled
+}
+
+// UseEncoding selects the layout, precision and fields of sent spectra
+func (ds *DefaultSender) UseEncoding(options signal.EncodeOptions) {
+	ds.encoding = options
+}
+
+// UseSendLog selects what is logged about successful requests
+func (ds *DefaultSender) UseSendLog(mode SendLog) {
+	ds.sendLog = mode
+}
+
+// UseSigner signs every request with signer
+func (ds *DefaultSender) UseSigner(signer *Signer) {
+	ds.signer = signer
+}
+
+// UseAuditLog records every delivery attempt in audit
+func (ds *DefaultSender) UseAuditLog(audit *AuditLog) {
+	ds.audit = audit
+}
+
+// UseEndpoints routes the payload types to the given paths on the target host
+func (ds *DefaultSender) UseEndpoints(paths EndpointPaths) error {
+	if paths.Single != "" {
+		singleURL, err := HealthURL(ds.targetURL, paths.Single)
+		if err != nil {
+			return err
+		}
+		ds.singleURL = singleURL
+	}
+	if paths.Batch != "" {
+		batchURL, err := HealthURL(ds.targetURL, paths.Batch)
+		if err != nil {
+			return err
+		}
+		ds.batchURL = batchURL
+	}
+	return nil
+}
+
+// marshalImpedanceData encodes one spectrum with the given options
+func marshalImpedanceData(data signal.ImpedanceData, options signal.EncodeOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := signal.NewImpedanceEncoder(&buf)
+	encoder.SetOptions(options)
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalImpedanceBatch encodes a batch with the given options
+func marshalImpedanceBatch(batch signal.ImpedanceBatch, options signal.EncodeOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := signal.NewImpedanceEncoder(&buf)
+	encoder.SetOptions(options)
+	if err := encoder.EncodeBatch(batch); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// idempotencyKey returns the measurement UUID of a single spectrum, a digest
+// of the UUIDs of several spectra, or a digest of the payload when any
+// spectrum has no UUID
+func idempotencyKey(payload []byte, ids ...string) string {
+	if len(ids) == 1 && ids[0] != "" {
+		return ids[0]
+	}
+
+	hash := sha256.New()
+	for _, id := range ids {
+		if id == "" {
+			hash.Reset()
+			hash.Write(payload)
+			return hex.EncodeToString(hash.Sum(nil))
+		}
+		hash.Write([]byte(id))
+		hash.Write([]byte{0})
+	}
+	if len(ids) == 0 {
+		hash.Write(payload)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// batchIdempotencyKey derives the key of a batch from its spectra, ignoring
+// the batch ID and timestamp that differ between attempts
+func batchIdempotencyKey(batch []signal.ImpedanceDataWithIteration) string {
+	ids := make([]string, len(batch))
+	complete := true
+	for i, item := range batch {
+		ids[i] = item.ImpedanceData.ID
+		complete = complete && ids[i] != ""
+	}
+	if complete {
+		return idempotencyKey(nil, ids...)
+	}
+
+	spectra, _ := json.Marshal(batch) // Already marshaled successfully as part of the batch
+	return idempotencyKey(spectra)
 }
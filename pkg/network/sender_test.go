@@ -0,0 +1,161 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestDefaultSender_IdempotencyKey(t *testing.T) {
+	keys := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(IdempotencyHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL)
+	spectrum := func() signal.ImpedanceData {
+		return signal.ImpedanceData{
+			Identity:    signal.NewIdentity(),
+			Frequencies: []float64{1},
+			Impedance:   []complex128{complex(10, -2)},
+		}
+	}
+	first, second := spectrum(), spectrum()
+
+	// A single spectrum is keyed by its UUID, also on retries
+	for i := 0; i < 2; i++ {
+		if err := sender.SendImpedanceData(first); err != nil {
+			t.Fatalf("SendImpedanceData() error = %v", err)
+		}
+		if key := <-keys; key != first.ID {
+			t.Errorf("attempt %d: key = %q, want measurement UUID %q", i, key, first.ID)
+		}
+	}
+
+	// A batch is keyed by its spectra, not by its per-attempt batch ID
+	batch := []signal.ImpedanceDataWithIteration{{ImpedanceData: first}, {ImpedanceData: second, Iteration: 1}}
+	var batchKeys []string
+	for i := 0; i < 2; i++ {
+		if err := sender.SendBatchImpedanceData(batch); err != nil {
+			t.Fatalf("SendBatchImpedanceData() error = %v", err)
+		}
+		batchKeys = append(batchKeys, <-keys)
+	}
+	if batchKeys[0] == "" || batchKeys[0] != batchKeys[1] {
+		t.Errorf("batch keys = %q, want identical non-empty keys", batchKeys)
+	}
+	if err := sender.SendBatchImpedanceData(batch[:1]); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+	if key := <-keys; key == batchKeys[0] {
+		t.Errorf("different batches share the key %q", key)
+	}
+
+	// Measurements without identity are keyed by their content
+	measurement := signal.EISMeasurement{{Frequency: 1, Real: 10, Imag: -2}}
+	for i := 0; i < 2; i++ {
+		if err := sender.SendEISMeasurement(measurement); err != nil {
+			t.Fatalf("SendEISMeasurement() error = %v", err)
+		}
+	}
+	if a, b := <-keys, <-keys; a == "" || a != b {
+		t.Errorf("measurement keys = %q, %q, want identical non-empty keys", a, b)
+	}
+}
+
+func TestDefaultSender_FlatImpedance(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL)
+	sender.(PayloadEncoder).UseEncoding(signal.EncodeOptions{Flat: true})
+	spectrum := signal.ImpedanceData{
+		Identity:    signal.NewIdentity(),
+		Frequencies: []float64{1, 10},
+		Impedance:   []complex128{complex(10, -2), complex(5, -1)},
+	}
+
+	if err := sender.SendImpedanceData(spectrum); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+	body := <-bodies
+	if !bytes.Contains(body, []byte(`"impedance_real":[10,5],"impedance_imag":[-2,-1]`)) {
+		t.Errorf("body = %s, want flat impedance arrays", body)
+	}
+
+	if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum, Iteration: 1}}); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+	var batch signal.ImpedanceBatch
+	if err := json.Unmarshal(<-bodies, &batch); err != nil {
+		t.Fatalf("decoding batch: %v", err)
+	}
+	if len(batch.Spectra) != 1 || len(batch.Spectra[0].ImpedanceData.Impedance) != 2 || batch.Spectra[0].ImpedanceData.Impedance[1] != complex(5, -1) {
+		t.Errorf("decoded batch = %+v, want the sent spectrum", batch.Spectra)
+	}
+}
+
+func TestDefaultSender_Endpoints(t *testing.T) {
+	paths := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		endpoints  EndpointPaths
+		wantSingle string
+		wantBatch  string
+	}{
+		{name: "defaults", wantSingle: "/eis-data", wantBatch: "/eis-data/eis-data/batch"},
+		{name: "custom", endpoints: EndpointPaths{Single: "/api/v2/spectra", Batch: "/api/v2/spectra:batch"}, wantSingle: "/api/v2/spectra", wantBatch: "/api/v2/spectra:batch"},
+		{name: "batch only", endpoints: EndpointPaths{Batch: "/bulk"}, wantSingle: "/eis-data", wantBatch: "/bulk"},
+	}
+
+	spectrum := signal.ImpedanceData{Frequencies: []float64{1}, Impedance: []complex128{complex(10, -2)}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := NewSender(server.URL + "/eis-data")
+			if err := sender.(EndpointRouter).UseEndpoints(tt.endpoints); err != nil {
+				t.Fatalf("UseEndpoints() error = %v", err)
+			}
+
+			if err := sender.SendImpedanceData(spectrum); err != nil {
+				t.Fatalf("SendImpedanceData() error = %v", err)
+			}
+			if path := <-paths; path != tt.wantSingle {
+				t.Errorf("single spectrum path = %q, want %q", path, tt.wantSingle)
+			}
+			if err := sender.SendEISMeasurement(signal.EISMeasurement{{Frequency: 1, Real: 10}}); err != nil {
+				t.Fatalf("SendEISMeasurement() error = %v", err)
+			}
+			if path := <-paths; path != tt.wantSingle {
+				t.Errorf("measurement path = %q, want %q", path, tt.wantSingle)
+			}
+			if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum}}); err != nil {
+				t.Fatalf("SendBatchImpedanceData() error = %v", err)
+			}
+			if path := <-paths; path != tt.wantBatch {
+				t.Errorf("batch path = %q, want %q", path, tt.wantBatch)
+			}
+		})
+	}
+
+	if err := NewSender("not a url").(EndpointRouter).UseEndpoints(EndpointPaths{Batch: "/bulk"}); err == nil {
+		t.Error("UseEndpoints() on a target without host: error = nil")
+	}
+}
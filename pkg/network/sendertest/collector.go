@@ -0,0 +1,403 @@
+package sendertest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is one message as received by a collector
+type Delivery struct {
+	Path           string
+	DataType       string // X-Data-Type header or envelope type, e.g. Impedance-Data
+	IdempotencyKey string
+	ContentType    string
+	Body           []byte // The JSON payload without any envelope
+}
+
+// Collector stands in for the application receiving a sender's data
+type Collector interface {
+	URL() string             // Target URL to configure the sender with
+	Deliveries() []Delivery  // Messages received so far, in order
+	SetFailing(failing bool) // Reject everything until cleared
+	Close()                  // Stop the collector; later sends find it unreachable
+}
+
+// recorder holds the deliveries and failure state shared by the collectors
+type recorder struct {
+	mu         sync.Mutex
+	deliveries []Delivery
+	failing    bool
+}
+
+func (r *recorder) add(delivery Delivery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, delivery)
+}
+
+func (r *recorder) Deliveries() []Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Delivery(nil), r.deliveries...)
+}
+
+func (r *recorder) SetFailing(failing bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = failing
+}
+
+func (r *recorder) isFailing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failing
+}
+
+// HTTPCollector records POST requests; while failing it answers 503
+type HTTPCollector struct {
+	recorder
+	server *httptest.Server
+}
+
+// NewHTTPCollector starts an HTTP collector on a local port
+func NewHTTPCollector() *HTTPCollector {
+	c := &HTTPCollector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.isFailing() {
+			http.Error(w, "collector unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || r.Method != http.MethodPost {
+			http.Error(w, "expected a POST with a body", http.StatusBadRequest)
+			return
+		}
+		c.add(Delivery{
+			Path:           r.URL.Path,
+			DataType:       r.Header.Get("X-Data-Type"),
+			IdempotencyKey: r.Header.Get("Idempotency-Key"),
+			ContentType:    r.Header.Get("Content-Type"),
+			Body:           body,
+		})
+		w.WriteHeader(http.StatusOK)
+	}))
+	return c
+}
+
+// URL returns the base URL of the collector
+func (c *HTTPCollector) URL() string {
+	return c.server.URL
+}
+
+// Close stops the collector
+func (c *HTTPCollector) Close() {
+	c.server.Close()
+}
+
+// WebSocketCollector accepts WebSocket streams and records every JSON
+// envelope {"type", "idempotency_key", "data"}; while failing it refuses
+// upgrades and drops open streams
+type WebSocketCollector struct {
+	recorder
+	server *httptest.Server
+	connMu sync.Mutex
+	conns  map[net.Conn]bool
+}
+
+// NewWebSocketCollector starts a WebSocket collector on a local port
+func NewWebSocketCollector() *WebSocketCollector {
+	c := &WebSocketCollector{conns: make(map[net.Conn]bool)}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serve))
+	return c
+}
+
+// URL returns the base URL of the collector; senders add their stream path
+func (c *WebSocketCollector) URL() string {
+	return c.server.URL
+}
+
+// SetFailing refuses new streams and closes open ones while failing
+func (c *WebSocketCollector) SetFailing(failing bool) {
+	c.recorder.SetFailing(failing)
+	if failing {
+		c.closeConns()
+	}
+}
+
+// Close stops the collector and its open streams
+func (c *WebSocketCollector) Close() {
+	c.closeConns()
+	c.server.Close()
+}
+
+func (c *WebSocketCollector) closeConns() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+		delete(c.conns, conn)
+	}
+}
+
+// serve performs the WebSocket handshake and reads frames until the stream ends
+func (c *WebSocketCollector) serve(w http.ResponseWriter, r *http.Request) {
+	if c.isFailing() {
+		http.Error(w, "collector unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("Upgrade") != "websocket" {
+		http.Error(w, "not a websocket request", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	c.connMu.Lock()
+	c.conns[conn] = true
+	c.connMu.Unlock()
+	defer conn.Close()
+
+	accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	rw.Flush()
+
+	reader := bufio.NewReader(rw)
+	for {
+		opcode, payload, err := readClientFrame(reader)
+		if err != nil || opcode == 0x8 { // Close
+			return
+		}
+		if opcode != 0x1 { // Only text frames carry envelopes
+			continue
+		}
+		var envelope struct {
+			Type           string          `json:"type"`
+			IdempotencyKey string          `json:"idempotency_key"`
+			Data           json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &envelope); err != nil {
+			return
+		}
+		c.add(Delivery{
+			Path:           r.URL.Path,
+			DataType:       envelope.Type,
+			IdempotencyKey: envelope.IdempotencyKey,
+			ContentType:    "application/json",
+			Body:           envelope.Data,
+		})
+	}
+}
+
+// readClientFrame reads one unfragmented, masked frame as sent by clients
+func readClientFrame(r io.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	var mask [4]byte
+	if header[1]&0x80 != 0 {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+// StalledCollector accepts connections but answers nothing for a while,
+// like a collector stuck on a slow database
+type StalledCollector struct {
+	server *httptest.Server
+	stop   chan struct{}
+}
+
+// NewStalledCollector starts a collector holding every request for delay
+func NewStalledCollector(delay time.Duration) *StalledCollector {
+	c := &StalledCollector{stop: make(chan struct{})}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-c.stop:
+		}
+		http.Error(w, "too late", http.StatusGatewayTimeout)
+	}))
+	return c
+}
+
+// URL returns the base URL of the collector
+func (c *StalledCollector) URL() string {
+	return c.server.URL
+}
+
+// Close releases held requests and stops the collector
+func (c *StalledCollector) Close() {
+	close(c.stop)
+	c.server.CloseClientConnections()
+	c.server.Close()
+}
+
+// NATSCollector is a minimal NATS server recording every HPUB with its
+// Nats-Msg-Id, Content-Type and X-Data-Type headers and the subject as Path.
+// Messages with a reply subject are acknowledged like a JetStream stream
+// capturing all subjects would. While failing it drops connections.
+type NATSCollector struct {
+	recorder
+	listener net.Listener
+	connMu   sync.Mutex
+	conns    map[net.Conn]bool
+	sequence int
+}
+
+// NewNATSCollector starts a NATS collector on a local port
+func NewNATSCollector() *NATSCollector {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	c := &NATSCollector{listener: listener, conns: make(map[net.Conn]bool)}
+	go c.accept()
+	return c
+}
+
+// URL returns the nats:// URL of the collector
+func (c *NATSCollector) URL() string {
+	return "nats://" + c.listener.Addr().String()
+}
+
+// SetFailing drops open connections and refuses new ones while failing
+func (c *NATSCollector) SetFailing(failing bool) {
+	c.recorder.SetFailing(failing)
+	if failing {
+		c.closeConns()
+	}
+}
+
+// Close stops the collector and its connections
+func (c *NATSCollector) Close() {
+	c.listener.Close()
+	c.closeConns()
+}
+
+func (c *NATSCollector) closeConns() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+		delete(c.conns, conn)
+	}
+}
+
+func (c *NATSCollector) accept() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		if c.isFailing() {
+			conn.Close()
+			continue
+		}
+		c.connMu.Lock()
+		c.conns[conn] = true
+		c.connMu.Unlock()
+		go c.serve(conn)
+	}
+}
+
+// serve speaks the client protocol on one connection until it ends
+func (c *NATSCollector) serve(conn net.Conn) {
+	defer conn.Close()
+	writer := bufio.NewWriter(conn)
+	writer.WriteString(`INFO {"server_id":"sendertest","version":"2.10.0","headers":true,"max_payload":1048576}` + "\r\n")
+	writer.Flush()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			writer.WriteString("PONG\r\n")
+		case "HPUB":
+			if len(fields) < 4 {
+				return
+			}
+			headerSize, err1 := strconv.Atoi(fields[len(fields)-2])
+			size, err2 := strconv.Atoi(fields[len(fields)-1])
+			if err1 != nil || err2 != nil || headerSize > size {
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			headerReader := textproto.NewReader(bufio.NewReader(bytes.NewReader(data[:headerSize])))
+			if _, err := headerReader.ReadLine(); err != nil { // NATS/1.0 status line
+				return
+			}
+			header, err := headerReader.ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return
+			}
+			c.add(Delivery{
+				Path:           fields[1],
+				DataType:       header.Get("X-Data-Type"),
+				IdempotencyKey: header.Get("Nats-Msg-Id"),
+				ContentType:    header.Get("Content-Type"),
+				Body:           data[headerSize:size],
+			})
+			if len(fields) == 5 { // Reply subject: acknowledge like JetStream
+				c.connMu.Lock()
+				c.sequence++
+				ack := fmt.Sprintf(`{"stream":"EIS","seq":%d}`, c.sequence)
+				c.connMu.Unlock()
+				fmt.Fprintf(writer, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+			}
+		}
+		writer.Flush()
+	}
+}
@@ -0,0 +1,235 @@
+package sendertest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Harness connects a Sender implementation to the contract suite
+type Harness struct {
+	NewCollector func() Collector                                    // Collector speaking the sender's protocol
+	NewSender    func(t *testing.T, targetURL string) network.Sender // Sender delivering to targetURL
+	Timeout      time.Duration                                       // Time within which the sender gives up on a stalled collector; 0 skips the check
+}
+
+// Run checks that a sender delivers every payload type with its headers,
+// keys retries of the same spectra identically, reports collector failures as
+// config.NetworkError with matching health and recovers afterwards, and
+// releases its resources on Close
+func Run(t *testing.T, h Harness) {
+	t.Run("Delivery", func(t *testing.T) { testDelivery(t, h) })
+	t.Run("RetryKeys", func(t *testing.T) { testRetryKeys(t, h) })
+	t.Run("Outage", func(t *testing.T) { testOutage(t, h) })
+	t.Run("Unreachable", func(t *testing.T) { testUnreachable(t, h) })
+	t.Run("Stall", func(t *testing.T) { testStall(t, h) })
+	t.Run("Close", func(t *testing.T) { testClose(t, h) })
+}
+
+// spectrum returns a small spectrum with a fresh identity
+func spectrum() signal.ImpedanceData {
+	return signal.ImpedanceData{
+		Identity:    signal.NewIdentity(),
+		Frequencies: []float64{1, 10, 100},
+		Impedance:   []complex128{complex(20, -5), complex(15, -3), complex(10, -1)},
+	}
+}
+
+// start creates a collector and a sender delivering to it, both closed with the test
+func start(t *testing.T, h Harness) (Collector, network.Sender) {
+	collector := h.NewCollector()
+	t.Cleanup(collector.Close)
+	sender := h.NewSender(t, collector.URL())
+	if closer, ok := sender.(io.Closer); ok {
+		t.Cleanup(func() { closer.Close() })
+	}
+	return collector, sender
+}
+
+// waitForDeliveries polls until the collector holds n deliveries, since
+// streaming senders return before the collector has read the message
+func waitForDeliveries(t *testing.T, collector Collector, n int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries := collector.Deliveries()
+		if len(deliveries) >= n || time.Now().After(deadline) {
+			if len(deliveries) != n {
+				t.Fatalf("collector received %d deliveries, want %d", len(deliveries), n)
+			}
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// requireNetworkError fails unless err is a config.NetworkError
+func requireNetworkError(t *testing.T, err error) {
+	t.Helper()
+	var networkErr config.NetworkError
+	if err == nil {
+		t.Fatal("send succeeded, want an error")
+	}
+	if !errors.As(err, &networkErr) {
+		t.Errorf("error %v (%T) is not a config.NetworkError", err, err)
+	}
+}
+
+func testDelivery(t *testing.T, h Harness) {
+	collector, sender := start(t, h)
+	data := spectrum()
+	batch := []signal.ImpedanceDataWithIteration{{Iteration: 0, ImpedanceData: data}, {Iteration: 1, ImpedanceData: spectrum()}}
+
+	if err := sender.SendImpedanceData(data); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+	if err := sender.SendBatchImpedanceData(batch); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+	if err := sender.SendEISMeasurement(signal.EISMeasurement{{Frequency: 1, Real: 20, Imag: -5}}); err != nil {
+		t.Fatalf("SendEISMeasurement() error = %v", err)
+	}
+	if !sender.IsHealthy() {
+		t.Error("sender unhealthy after successful sends")
+	}
+
+	deliveries := waitForDeliveries(t, collector, 3)
+	for i, want := range []string{"Impedance-Data", "Impedance-Batch", "EIS-Measurement"} {
+		if deliveries[i].DataType != want {
+			t.Errorf("delivery %d data type = %q, want %q", i, deliveries[i].DataType, want)
+		}
+		if deliveries[i].ContentType != "application/json" {
+			t.Errorf("delivery %d content type = %q, want application/json", i, deliveries[i].ContentType)
+		}
+		if deliveries[i].IdempotencyKey == "" {
+			t.Errorf("delivery %d has no idempotency key", i)
+		}
+	}
+
+	var received signal.ImpedanceData
+	if err := json.Unmarshal(deliveries[0].Body, &received); err != nil {
+		t.Fatalf("spectrum payload is not JSON impedance data: %v", err)
+	}
+	if received.ID != data.ID || len(received.Impedance) != len(data.Impedance) {
+		t.Errorf("received spectrum %s with %d points, want %s with %d", received.ID, len(received.Impedance), data.ID, len(data.Impedance))
+	}
+	if deliveries[0].IdempotencyKey != data.ID {
+		t.Errorf("spectrum idempotency key = %q, want measurement UUID %q", deliveries[0].IdempotencyKey, data.ID)
+	}
+
+	var receivedBatch signal.ImpedanceBatch
+	if err := json.Unmarshal(deliveries[1].Body, &receivedBatch); err != nil {
+		t.Fatalf("batch payload is not a JSON impedance batch: %v", err)
+	}
+	if len(receivedBatch.Spectra) != len(batch) {
+		t.Errorf("received batch of %d spectra, want %d", len(receivedBatch.Spectra), len(batch))
+	}
+}
+
+func testRetryKeys(t *testing.T, h Harness) {
+	collector, sender := start(t, h)
+	data := spectrum()
+	batch := []signal.ImpedanceDataWithIteration{{ImpedanceData: data}, {Iteration: 1, ImpedanceData: spectrum()}}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := sender.SendImpedanceData(data); err != nil {
+			t.Fatalf("SendImpedanceData() attempt %d error = %v", attempt, err)
+		}
+		if err := sender.SendBatchImpedanceData(batch); err != nil {
+			t.Fatalf("SendBatchImpedanceData() attempt %d error = %v", attempt, err)
+		}
+	}
+	if err := sender.SendBatchImpedanceData(batch[:1]); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+
+	deliveries := waitForDeliveries(t, collector, 5)
+	if deliveries[0].IdempotencyKey != deliveries[2].IdempotencyKey {
+		t.Errorf("retried spectrum keys differ: %q, %q", deliveries[0].IdempotencyKey, deliveries[2].IdempotencyKey)
+	}
+	if deliveries[1].IdempotencyKey != deliveries[3].IdempotencyKey {
+		t.Errorf("retried batch keys differ: %q, %q", deliveries[1].IdempotencyKey, deliveries[3].IdempotencyKey)
+	}
+	if deliveries[4].IdempotencyKey == deliveries[1].IdempotencyKey {
+		t.Errorf("different batches share the key %q", deliveries[4].IdempotencyKey)
+	}
+}
+
+func testOutage(t *testing.T, h Harness) {
+	collector, sender := start(t, h)
+	collector.SetFailing(true)
+
+	requireNetworkError(t, sender.SendImpedanceData(spectrum()))
+	if sender.IsHealthy() {
+		t.Error("sender healthy after a rejected send")
+	}
+	requireNetworkError(t, sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum()}}))
+
+	collector.SetFailing(false)
+	if err := sender.SendImpedanceData(spectrum()); err != nil {
+		t.Fatalf("SendImpedanceData() after recovery error = %v", err)
+	}
+	if !sender.IsHealthy() {
+		t.Error("sender unhealthy after the collector recovered")
+	}
+	waitForDeliveries(t, collector, 1)
+}
+
+func testUnreachable(t *testing.T, h Harness) {
+	collector, sender := start(t, h)
+	collector.Close()
+
+	requireNetworkError(t, sender.SendImpedanceData(spectrum()))
+	if sender.IsHealthy() {
+		t.Error("sender healthy although the collector is unreachable")
+	}
+}
+
+func testStall(t *testing.T, h Harness) {
+	if h.Timeout <= 0 {
+		t.Skip("sender has no timeout configured in the harness")
+	}
+	stalled := NewStalledCollector(4 * h.Timeout)
+	t.Cleanup(stalled.Close)
+	sender := h.NewSender(t, stalled.URL())
+	if closer, ok := sender.(io.Closer); ok {
+		t.Cleanup(func() { closer.Close() })
+	}
+
+	started := time.Now()
+	requireNetworkError(t, sender.SendImpedanceData(spectrum()))
+	if elapsed := time.Since(started); elapsed > 2*h.Timeout {
+		t.Errorf("send gave up after %v, want within about %v", elapsed, h.Timeout)
+	}
+}
+
+func testClose(t *testing.T, h Harness) {
+	_, sender := start(t, h)
+	closer, ok := sender.(io.Closer)
+	if !ok {
+		t.Skip("sender holds no resources to close")
+	}
+	if err := sender.SendImpedanceData(spectrum()); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		closer.Close()
+		done <- closer.Close() // A second Close must be harmless
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("second Close() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() did not return")
+	}
+}
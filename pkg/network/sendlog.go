@@ -0,0 +1,86 @@
+package network
+
+import (
+	"fmt"
+	"log"
+	"math/cmplx"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SendLog selects what senders log about successful sends
+type SendLog string
+
+const (
+	SendLogRequests SendLog = "requests" // One line per request, the default
+	SendLogSummary  SendLog = "summary"  // One line per sent spectrum with its summary
+	SendLogQuiet    SendLog = "quiet"    // Nothing; failures are still returned
+)
+
+// SendLogger is implemented by senders whose logging of successful sends can
+// be selected, e.g. to silence per-request lines at high spectrum rates
+type SendLogger interface {
+	UseSendLog(mode SendLog)
+}
+
+// logSent logs a successful request carrying spectra according to mode;
+// request is the line logged per request, empty if none is logged
+func logSent(mode SendLog, request string, spectra ...signal.ImpedanceData) {
+	switch mode {
+	case SendLogQuiet:
+	case SendLogSummary:
+		for _, data := range spectra {
+			log.Print(summarizeSpectrum(data))
+		}
+	default:
+		if request != "" {
+			log.Print(request)
+		}
+	}
+}
+
+// logSentBatch logs a successful batch request according to mode
+func logSentBatch(mode SendLog, request string, batch []signal.ImpedanceDataWithIteration) {
+	spectra := make([]signal.ImpedanceData, len(batch))
+	for i, item := range batch {
+		spectra[i] = item.ImpedanceData
+	}
+	logSent(mode, request, spectra...)
+}
+
+// summarizeSpectrum describes a sent spectrum in one line: its point count,
+// the R_s estimate and |Z| at the lowest and highest frequency. R_s is the
+// high-frequency intercept if features were extracted and Re Z at the
+// highest frequency otherwise.
+func summarizeSpectrum(data signal.ImpedanceData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sent spectrum %d", data.Sequence)
+	if data.ID != "" {
+		fmt.Fprintf(&b, " (%s)", data.ID)
+	}
+	fmt.Fprintf(&b, ": %d points", len(data.Impedance))
+
+	points := min(len(data.Frequencies), len(data.Impedance))
+	if points == 0 {
+		return b.String()
+	}
+	low, high := 0, 0
+	for i := 1; i < points; i++ {
+		if data.Frequencies[i] < data.Frequencies[low] {
+			low = i
+		}
+		if data.Frequencies[i] > data.Frequencies[high] {
+			high = i
+		}
+	}
+	rs := real(data.Impedance[high])
+	if data.Features != nil {
+		rs = data.Features.HighFrequencyIntercept
+	}
+	fmt.Fprintf(&b, ", Rs≈%.4g Ω, |Z| %.4g Ω at %g Hz, %.4g Ω at %g Hz",
+		rs,
+		cmplx.Abs(data.Impedance[low]), data.Frequencies[low],
+		cmplx.Abs(data.Impedance[high]), data.Frequencies[high])
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package network
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSummarizeSpectrum(t *testing.T) {
+	spectrum := signal.ImpedanceData{
+		Frequencies: []float64{1000, 1, 10},
+		Impedance:   []complex128{complex(10.2, -0.5), complex(30, -4), complex(25, -8)},
+	}
+	spectrum.Identity = signal.Identity{ID: "id-1", Sequence: 7}
+	withFeatures := spectrum
+	withFeatures.Features = &signal.SpectrumFeatures{HighFrequencyIntercept: 10}
+
+	tests := []struct {
+		name string
+		data signal.ImpedanceData
+		want string
+	}{
+		{"highest frequency", spectrum, "Sent spectrum 7 (id-1): 3 points, Rs≈10.2 Ω, |Z| 30.27 Ω at 1 Hz, 10.21 Ω at 1000 Hz"},
+		{"features intercept", withFeatures, "Sent spectrum 7 (id-1): 3 points, Rs≈10 Ω, |Z| 30.27 Ω at 1 Hz, 10.21 Ω at 1000 Hz"},
+		{"empty", signal.ImpedanceData{}, "Sent spectrum 0: 0 points"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeSpectrum(tt.data); got != tt.want {
+				t.Errorf("summarizeSpectrum() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultSender_SendLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	batch := []signal.ImpedanceDataWithIteration{
+		{ImpedanceData: signal.ImpedanceData{Frequencies: []float64{1}, Impedance: []complex128{10}}},
+		{ImpedanceData: signal.ImpedanceData{Frequencies: []float64{1}, Impedance: []complex128{11}}, Iteration: 1},
+	}
+	tests := []struct {
+		mode  SendLog
+		lines int
+		want  string
+	}{
+		{SendLogRequests, 1, "Successfully sent batch of 2 spectra"},
+		{SendLogSummary, 2, "Sent spectrum 0: 1 points"},
+		{SendLogQuiet, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			logs.Reset()
+			sender := NewSender(server.URL)
+			sender.(SendLogger).UseSendLog(tt.mode)
+			if err := sender.SendBatchImpedanceData(batch); err != nil {
+				t.Fatalf("SendBatchImpedanceData() error = %v", err)
+			}
+			if lines := strings.Count(logs.String(), "\n"); lines != tt.lines || !strings.Contains(logs.String(), tt.want) {
+				t.Errorf("log = %q, want %d lines with %q", logs.String(), tt.lines, tt.want)
+			}
+		})
+	}
+}
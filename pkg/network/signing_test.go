@@ -0,0 +1,81 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSigner(t *testing.T) {
+	secret := []byte("field-device-secret")
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	signer, err := NewSigner(string(secret), clock.NewSimulatedClock(time.Unix(1700000000, 0)))
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	sender := NewSender(server.URL)
+	sender.(RequestSigner).UseSigner(signer)
+
+	spectrum := signal.ImpedanceData{Frequencies: []float64{1}, Impedance: []complex128{complex(10, -2)}}
+	if err := sender.SendImpedanceData(spectrum); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+	received := <-requests
+	if got := received.header.Get(SignatureTimestampHeader); got != "1700000000" {
+		t.Errorf("%s = %q, want the signer's clock", SignatureTimestampHeader, got)
+	}
+	if err := VerifySignature(secret, received.header, received.body); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+
+	// Changed bodies, timestamps and secrets do not verify
+	tampered := append([]byte{}, received.body...)
+	tampered[len(tampered)-2] ^= 1
+	if err := VerifySignature(secret, received.header, tampered); !errors.Is(err, config.ErrInvalidSignature) {
+		t.Errorf("VerifySignature(tampered body) error = %v, want ErrInvalidSignature", err)
+	}
+	if err := VerifySignature([]byte("other"), received.header, received.body); !errors.Is(err, config.ErrInvalidSignature) {
+		t.Errorf("VerifySignature(other secret) error = %v, want ErrInvalidSignature", err)
+	}
+	replayed := received.header.Clone()
+	replayed.Set(SignatureTimestampHeader, "1700000060")
+	if err := VerifySignature(secret, replayed, received.body); !errors.Is(err, config.ErrInvalidSignature) {
+		t.Errorf("VerifySignature(changed timestamp) error = %v, want ErrInvalidSignature", err)
+	}
+
+	// Batches are signed as well, unsigned senders add no headers
+	if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: spectrum}}); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+	if received := <-requests; VerifySignature(secret, received.header, received.body) != nil {
+		t.Error("batch request does not verify")
+	}
+	if err := NewSender(server.URL).SendImpedanceData(spectrum); err != nil {
+		t.Fatalf("SendImpedanceData() error = %v", err)
+	}
+	if received := <-requests; received.header.Get(SignatureHeader) != "" {
+		t.Errorf("unsigned sender set %s = %q", SignatureHeader, received.header.Get(SignatureHeader))
+	}
+
+	if _, err := NewSigner("", nil); err == nil {
+		t.Error("NewSigner(\"\") error = nil")
+	}
+}
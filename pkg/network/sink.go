@@ -0,0 +1,57 @@
+package network
+
+import (
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SenderSink delivers the spectra of a run through a Sender. Without a batch
+// size every spectrum is sent on its own; with one, spectra are collected and
+// sent through the batch endpoint once the batch is full or flushed.
+type SenderSink struct {
+	sender    Sender
+	batchSize int
+	pending   []signal.ImpedanceDataWithIteration
+	onBatch   func(batch []signal.ImpedanceDataWithIteration, err error)
+}
+
+// NewSenderSink creates a sink sending through sender, in batches of up to
+// batchSize spectra if batchSize is positive
+func NewSenderSink(sender Sender, batchSize int) *SenderSink {
+	return &SenderSink{sender: sender, batchSize: batchSize}
+}
+
+// OnBatch registers a function called with every batch sent and the result
+func (ss *SenderSink) OnBatch(onBatch func(batch []signal.ImpedanceDataWithIteration, err error)) {
+	ss.onBatch = onBatch
+}
+
+// Write sends the spectrum or adds it to the pending batch
+func (ss *SenderSink) Write(data signal.ImpedanceDataWithIteration) error {
+	if ss.batchSize <= 0 {
+		return ss.sender.SendImpedanceData(data.ImpedanceData)
+	}
+	ss.pending = append(ss.pending, data)
+	if len(ss.pending) < ss.batchSize {
+		return nil
+	}
+	return ss.Flush()
+}
+
+// Flush sends the pending batch, if any
+func (ss *SenderSink) Flush() error {
+	if len(ss.pending) == 0 {
+		return nil
+	}
+	err := ss.sender.SendBatchImpedanceData(ss.pending)
+	if ss.onBatch != nil {
+		ss.onBatch(ss.pending, err)
+	}
+	ss.pending = nil
+	return err
+}
+
+// Close sends the pending batch. The sender itself is left open, it may be
+// shared with heartbeats and health checks.
+func (ss *SenderSink) Close() error {
+	return ss.Flush()
+}
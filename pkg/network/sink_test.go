@@ -0,0 +1,74 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSenderSink_Batching(t *testing.T) {
+	spectrum := signal.ImpedanceData{Frequencies: []float64{1, 10}, Impedance: []complex128{1, 2}}
+
+	tests := []struct {
+		name      string
+		batchSize int
+		writes    int
+		wantKinds []PayloadKind
+		wantSizes []int
+	}{
+		{"one by one", 0, 3, []PayloadKind{PayloadSpectrum, PayloadSpectrum, PayloadSpectrum}, []int{0, 0, 0}},
+		{"full batches and remainder", 2, 5, []PayloadKind{PayloadBatch, PayloadBatch, PayloadBatch}, []int{2, 2, 1}},
+		{"single batch", 10, 3, []PayloadKind{PayloadBatch}, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMockSender()
+			sink := NewSenderSink(ms, tt.batchSize)
+			var batches int
+			sink.OnBatch(func(batch []signal.ImpedanceDataWithIteration, err error) { batches++ })
+			for i := 0; i < tt.writes; i++ {
+				if err := sink.Write(signal.ImpedanceDataWithIteration{ImpedanceData: spectrum, Iteration: i}); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			payloads := ms.Payloads()
+			if len(payloads) != len(tt.wantKinds) {
+				t.Fatalf("sent %d payloads, want %d", len(payloads), len(tt.wantKinds))
+			}
+			for i, payload := range payloads {
+				if payload.Kind != tt.wantKinds[i] || len(payload.Batch) != tt.wantSizes[i] {
+					t.Errorf("payload %d = %s of %d spectra, want %s of %d", i, payload.Kind, len(payload.Batch), tt.wantKinds[i], tt.wantSizes[i])
+				}
+			}
+			if tt.batchSize > 0 && batches != len(tt.wantKinds) {
+				t.Errorf("OnBatch called %d times, want %d", batches, len(tt.wantKinds))
+			}
+		})
+	}
+}
+
+func TestSenderSink_ReportsFailedBatch(t *testing.T) {
+	errDown := errors.New("collector down")
+	ms := NewMockSender()
+	ms.FailNext(1, errDown)
+	sink := NewSenderSink(ms, 2)
+	var reported error
+	sink.OnBatch(func(batch []signal.ImpedanceDataWithIteration, err error) { reported = err })
+
+	sink.Write(signal.ImpedanceDataWithIteration{Iteration: 1})
+	if err := sink.Write(signal.ImpedanceDataWithIteration{Iteration: 2}); !errors.Is(err, errDown) {
+		t.Errorf("Write() error = %v, want %v", err, errDown)
+	}
+	if !errors.Is(reported, errDown) {
+		t.Errorf("OnBatch error = %v, want %v", reported, errDown)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Errorf("Flush() after the failed batch error = %v, want nothing pending", err)
+	}
+}
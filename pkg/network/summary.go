@@ -0,0 +1,69 @@
+package network
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SummarySender wraps a Sender and keeps the summary of the last batch it
+// delivered, served as JSON for consumers that only poll the batch trend
+type SummarySender struct {
+	Sender
+	mu     sync.Mutex
+	latest *signal.BatchSummary
+}
+
+// NewSummarySender creates a wrapper around sender summarizing its batches
+func NewSummarySender(sender Sender) *SummarySender {
+	return &SummarySender{Sender: sender}
+}
+
+// SendBatchImpedanceData sends the batch and keeps its summary once delivered
+func (ss *SummarySender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	if err := ss.Sender.SendBatchImpedanceData(batch); err != nil {
+		return err
+	}
+	summary := signal.ImpedanceBatch{Spectra: batch}.Summarize()
+	ss.mu.Lock()
+	ss.latest = summary
+	ss.mu.Unlock()
+	return nil
+}
+
+// Latest returns the summary of the last delivered batch, nil before the first
+func (ss *SummarySender) Latest() *signal.BatchSummary {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.latest
+}
+
+// Close closes the wrapped sender if it needs closing
+func (ss *SummarySender) Close() error {
+	if closer, ok := ss.Sender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ServeHTTP answers GET requests with the latest summary, 204 before the
+// first batch
+func (ss *SummarySender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	summary := ss.Latest()
+	if summary == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Printf("Error encoding batch summary: %v", err)
+	}
+}
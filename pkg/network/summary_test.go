@@ -0,0 +1,57 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSummarySender(t *testing.T) {
+	inner := NewMockSender()
+	sender := NewSummarySender(inner)
+	get := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		sender.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/batch-summary", nil))
+		return recorder
+	}
+
+	if code := get().Code; code != http.StatusNoContent {
+		t.Errorf("status before the first batch = %d, want %d", code, http.StatusNoContent)
+	}
+
+	batch := []signal.ImpedanceDataWithIteration{
+		{ImpedanceData: signal.ImpedanceData{Frequencies: []float64{10, 1}, Impedance: []complex128{5, 105}}, Iteration: 1},
+		{ImpedanceData: signal.ImpedanceData{Frequencies: []float64{10, 1}, Impedance: []complex128{5, 125}}, Iteration: 2},
+	}
+	if err := sender.SendBatchImpedanceData(batch); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+
+	// A failed batch keeps the summary of the last delivered one
+	inner.FailNext(1, errors.New("unreachable"))
+	if err := sender.SendBatchImpedanceData(batch[:1]); err == nil {
+		t.Fatal("SendBatchImpedanceData() error = nil, want the inner error")
+	}
+
+	recorder := get()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	var summary signal.BatchSummary
+	if err := json.Unmarshal(recorder.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+	}
+	if summary.Spectra != 2 || summary.RctMean != 110 || summary.RctSlope != 20 {
+		t.Errorf("summary = %+v, want 2 spectra, R_ct mean 110 and slope 20", summary)
+	}
+
+	recorder = httptest.NewRecorder()
+	sender.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/batch-summary", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
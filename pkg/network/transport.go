@@ -0,0 +1,136 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportOptions tunes connection reuse and timeouts of the HTTP sender.
+// A zero timeout does not limit its phase.
+type TransportOptions struct {
+	MaxIdleConnsPerHost int           // Idle connections kept open to the collector
+	IdleConnTimeout     time.Duration // How long an idle connection is kept before closing it
+	KeepAlive           time.Duration // TCP keep-alive probe interval; negative disables keep-alive
+	HTTP2               bool          // Negotiate HTTP/2 with TLS collectors
+	DNSCacheTTL         time.Duration // How long resolved collector addresses are reused; 0 resolves on every dial
+
+	DialTimeout           time.Duration // Limit for opening a TCP connection
+	TLSHandshakeTimeout   time.Duration // Limit for the TLS handshake of a new connection
+	ResponseHeaderTimeout time.Duration // Limit for the response headers after the request was written
+	RequestTimeout        time.Duration // Limit for a whole request, from dialing to reading the body
+}
+
+// DefaultTransportOptions returns options that keep connections to a single
+// collector open across batches
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		HTTP2:               true,
+
+		DialTimeout:         10 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		RequestTimeout:      10 * time.Second,
+	}
+}
+
+// NewTransport builds an HTTP transport from options
+func NewTransport(opts TransportOptions) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	if opts.DNSCacheTTL > 0 {
+		transport.DialContext = newDNSCache(opts.DNSCacheTTL).dialContext(dialer)
+	}
+	transport.MaxIdleConns = 0 // No global limit, only per host
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	transport.DisableKeepAlives = opts.KeepAlive < 0
+	transport.ForceAttemptHTTP2 = opts.HTTP2
+	transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	if !opts.HTTP2 {
+		// A non-nil, empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// drainAndClose reads the rest of a response body so that its connection can
+// be reused for the next request
+func drainAndClose(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+// dnsCache remembers resolved host addresses for a fixed time
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+	mu       sync.Mutex
+	entries  map[string]dnsEntry
+}
+
+// dnsEntry holds the addresses of one host
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// newDNSCache creates a cache keeping lookups for ttl
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, resolver: net.DefaultResolver, entries: make(map[string]dnsEntry)}
+}
+
+// lookup returns cached addresses of host, resolving them when missing or expired
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// dialContext returns a dial function connecting to the cached addresses in turn
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestDefaultSender_ReusesConnections(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            TransportOptions
+		wantConnections int32
+	}{
+		{name: "default options", opts: DefaultTransportOptions(), wantConnections: 1},
+		{name: "DNS cache", opts: TransportOptions{MaxIdleConnsPerHost: 4, KeepAlive: time.Second, DNSCacheTTL: time.Minute}, wantConnections: 1},
+		{name: "keep-alive disabled", opts: TransportOptions{KeepAlive: -1}, wantConnections: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connections atomic.Int32
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status":"accepted","detail":"` + strings.Repeat("x", 1024) + `"}`))
+			}))
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					connections.Add(1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+			sender := NewSenderWithTransport(target, clock.NewSystemClock(), tt.opts)
+			for i := 0; i < 5; i++ {
+				if err := sender.SendImpedanceData(signal.ImpedanceData{Identity: signal.NewIdentity()}); err != nil {
+					t.Fatalf("SendImpedanceData() error = %v", err)
+				}
+			}
+
+			if got := connections.Load(); got != tt.wantConnections {
+				t.Errorf("opened %d connections, want %d", got, tt.wantConnections)
+			}
+		})
+	}
+}
+
+func TestDefaultSender_Timeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tests := []struct {
+		name string
+		opts TransportOptions
+	}{
+		{name: "response header", opts: TransportOptions{ResponseHeaderTimeout: 50 * time.Millisecond}},
+		{name: "whole request", opts: TransportOptions{RequestTimeout: 50 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := NewSenderWithTransport(server.URL, clock.NewSystemClock(), tt.opts)
+			start := time.Now()
+			if err := sender.SendImpedanceData(signal.ImpedanceData{Identity: signal.NewIdentity()}); err == nil {
+				t.Fatal("SendImpedanceData() error = nil, want a timeout")
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("slow collector blocked the sender for %v", elapsed)
+			}
+			if sender.IsHealthy() {
+				t.Error("sender is healthy after a timeout")
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes (RFC 6455, section 5.2)
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsGUID is appended to the client key to derive Sec-WebSocket-Accept
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxFrame bounds frames read from the peer; the collector only sends
+// control frames, which are limited to 125 bytes
+const wsMaxFrame = 1 << 20
+
+var errWSClosed = errors.New("websocket connection closed")
+
+// wsConn is a minimal client side WebSocket connection supporting unfragmented
+// text messages and the control frames needed to keep the connection alive
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// dialWebSocket opens a WebSocket connection to a ws:// or wss:// URL
+func dialWebSocket(ctx context.Context, rawURL string, header http.Header) (*wsConn, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := parsed.Host
+	if parsed.Port() == "" {
+		port := "80"
+		if parsed.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(parsed.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "ws":
+	case "wss":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: parsed.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("unsupported websocket scheme '%s'", parsed.Scheme)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	ws, err := handshake(conn, parsed, header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go ws.readLoop()
+	return ws, nil
+}
+
+// handshake performs the HTTP upgrade on an established connection
+func handshake(conn net.Conn, target *url.URL, header http.Header) (*wsConn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        &url.URL{Path: target.Path, RawQuery: target.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       target.Host,
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket upgrade refused with status %d", resp.StatusCode)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, errors.New("invalid websocket handshake response")
+	}
+
+	return &wsConn{conn: conn, reader: reader, closed: make(chan struct{})}, nil
+}
+
+// wsAcceptKey derives the Sec-WebSocket-Accept value for a client key
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteText sends payload as a single text message
+func (ws *wsConn) WriteText(payload []byte, timeout time.Duration) error {
+	select {
+	case <-ws.closed:
+		return errWSClosed
+	default:
+	}
+	return ws.writeFrame(wsOpText, payload, timeout)
+}
+
+// writeFrame sends one final, masked frame as required for clients
+func (ws *wsConn) writeFrame(opcode byte, payload []byte, timeout time.Duration) error {
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if timeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// readFrame reads one frame, unmasking its payload if needed
+func readFrame(r io.Reader) (opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxFrame {
+		return 0, nil, fmt.Errorf("websocket frame of %d bytes exceeds limit", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// readLoop answers pings and notices when the peer closes the connection
+func (ws *wsConn) readLoop() {
+	defer ws.markClosed()
+	for {
+		opcode, payload, err := readFrame(ws.reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := ws.writeFrame(wsOpPong, payload, 5*time.Second); err != nil {
+				return
+			}
+		case wsOpClose:
+			ws.writeFrame(wsOpClose, nil, time.Second)
+			return
+		}
+	}
+}
+
+// Done returns a channel closed once the connection is no longer usable
+func (ws *wsConn) Done() <-chan struct{} {
+	return ws.closed
+}
+
+// markClosed closes the underlying connection once
+func (ws *wsConn) markClosed() {
+	ws.once.Do(func() {
+		close(ws.closed)
+		ws.conn.Close()
+	})
+}
+
+// Close sends a normal closure frame and closes the connection
+func (ws *wsConn) Close() error {
+	select {
+	case <-ws.closed:
+		return nil
+	default:
+	}
+	ws.writeFrame(wsOpClose, []byte{0x03, 0xE8}, time.Second) // 1000: normal closure
+	ws.markClosed()
+	return nil
+}
@@ -0,0 +1,235 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// wsMessage is the envelope of every message streamed over the WebSocket;
+// Type and IdempotencyKey mirror the X-Data-Type and Idempotency-Key headers
+// of the HTTP sender
+type wsMessage struct {
+	Type           string      `json:"type"`
+	IdempotencyKey string      `json:"idempotency_key"`
+	Data           interface{} `json:"data"`
+}
+
+// WSSender streams measurements over a persistent WebSocket connection to the
+// collector, avoiding the connection overhead of one HTTP request per spectrum.
+// The connection is opened on first use and re-established after failures.
+type WSSender struct {
+	url         string
+	clock       clock.Clock
+	dialTimeout time.Duration
+	sendTimeout time.Duration
+	mu          sync.Mutex
+	conn        *wsConn
+	healthy     bool
+	sharedGrid  bool
+	encoding    signal.EncodeOptions
+	sendLog     SendLog
+}
+
+// WebSocketURL derives the ws:// or wss:// URL of streamPath on the target's host
+func WebSocketURL(targetURL, streamPath string) (string, error) {
+	streamURL, err := HealthURL(targetURL, streamPath)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(streamURL, "https://"):
+		return "wss://" + strings.TrimPrefix(streamURL, "https://"), nil
+	case strings.HasPrefix(streamURL, "http://"):
+		return "ws://" + strings.TrimPrefix(streamURL, "http://"), nil
+	case strings.HasPrefix(streamURL, "ws://"), strings.HasPrefix(streamURL, "wss://"):
+		return streamURL, nil
+	}
+	return "", config.NewNetworkError(targetURL, 0, config.ErrInvalidURL)
+}
+
+// NewWSSender creates a sender streaming to streamPath on the target's host
+func NewWSSender(targetURL, streamPath string) (*WSSender, error) {
+	return NewWSSenderWithClock(targetURL, streamPath, clock.NewSystemClock())
+}
+
+// NewWSSenderWithClock creates a WebSocket sender stamping batches with the given clock
+func NewWSSenderWithClock(targetURL, streamPath string, c clock.Clock) (*WSSender, error) {
+	streamURL, err := WebSocketURL(targetURL, streamPath)
+	if err != nil {
+		return nil, err
+	}
+	return &WSSender{
+		url:         streamURL,
+		clock:       clock.OrSystem(c),
+		dialTimeout: 10 * time.Second,
+		sendTimeout: 10 * time.Second,
+		healthy:     true,
+	}, nil
+}
+
+// SendEISMeasurement streams a complete EIS measurement
+func (ws *WSSender) SendEISMeasurement(measurement signal.EISMeasurement) error {
+	jsonData, err := json.Marshal(measurement)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	return ws.send("EIS-Measurement", idempotencyKey(jsonData), measurement)
+}
+
+// SendImpedanceData streams impedance data as soon as it has been computed
+func (ws *WSSender) SendImpedanceData(impedanceData signal.ImpedanceData) error {
+	ws.mu.Lock()
+	encoding, sendLog := ws.encoding, ws.sendLog
+	ws.mu.Unlock()
+
+	jsonData, err := marshalImpedanceData(impedanceData, encoding)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	if err := ws.send("Impedance-Data", idempotencyKey(jsonData, impedanceData.ID), json.RawMessage(jsonData)); err != nil {
+		return err
+	}
+	logSent(sendLog, "", impedanceData)
+	return nil
+}
+
+// SendBatchImpedanceData streams a batch of impedance data as one message
+func (ws *WSSender) SendBatchImpedanceData(batch []signal.ImpedanceDataWithIteration) error {
+	now := ws.clock.Now()
+	batchData := signal.ImpedanceBatch{
+		BatchID:   fmt.Sprintf("batch_%d_%d", now.Unix(), len(batch)),
+		Timestamp: now,
+		Spectra:   batch,
+	}
+	ws.mu.Lock()
+	sharedGrid, encoding, sendLog := ws.sharedGrid, ws.encoding, ws.sendLog
+	ws.mu.Unlock()
+	if sharedGrid {
+		batchData = batchData.CompactGrid()
+	}
+	jsonData, err := marshalImpedanceBatch(batchData, encoding)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	if err := ws.send("Impedance-Batch", batchIdempotencyKey(batch), json.RawMessage(jsonData)); err != nil {
+		return err
+	}
+	logSentBatch(sendLog, fmt.Sprintf("Successfully streamed batch of %d spectra", len(batch)), batch)
+	return nil
+}
+
+// send wraps data in an envelope and writes it, reconnecting once if the
+// current connection turns out to be broken
+func (ws *WSSender) send(dataType, key string, data interface{}) error {
+	message, err := json.Marshal(wsMessage{Type: dataType, IdempotencyKey: key, Data: data})
+	if err != nil {
+		ws.setHealthy(false)
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = ws.connect(); err != nil {
+			break
+		}
+		if err = ws.conn.WriteText(message, ws.sendTimeout); err == nil {
+			ws.healthy = true
+			return nil
+		}
+		ws.conn.Close()
+		ws.conn = nil
+	}
+
+	ws.healthy = false
+	return config.NewNetworkError(ws.url, 0, fmt.Errorf("failed to stream %s: %w", dataType, err))
+}
+
+// connect dials the collector unless a live connection exists; ws.mu must be held
+func (ws *WSSender) connect() error {
+	if ws.conn != nil {
+		select {
+		case <-ws.conn.Done():
+			ws.conn = nil
+		default:
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ws.dialTimeout)
+	defer cancel()
+	conn, err := dialWebSocket(ctx, ws.url, http.Header{"X-Data-Type": {"Stream"}})
+	if err != nil {
+		return err
+	}
+	log.Printf("Connected WebSocket stream to %s", ws.url)
+	ws.conn = conn
+	return nil
+}
+
+// FormatAsJSON formats data as pretty-printed JSON
+func (ws *WSSender) FormatAsJSON(data interface{}) (string, error) {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", config.NewProcessingError("JSON formatting", config.ErrJSONMarshalFailed)
+	}
+	return string(jsonData), nil
+}
+
+// IsHealthy returns false after a message could not be delivered
+func (ws *WSSender) IsHealthy() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.healthy
+}
+
+// UseSharedGrid enables the shared-grid batch schema
+func (ws *WSSender) UseSharedGrid(enabled bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.sharedGrid = enabled
+}
+
+// UseEncoding selects the layout, precision and fields of streamed spectra
+func (ws *WSSender) UseEncoding(options signal.EncodeOptions) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.encoding = options
+}
+
+// UseSendLog selects what is logged about successfully streamed spectra
+func (ws *WSSender) UseSendLog(mode SendLog) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.sendLog = mode
+}
+
+// setHealthy updates the health status
+func (ws *WSSender) setHealthy(healthy bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.healthy = healthy
+}
+
+// Close closes the WebSocket connection, if any
+func (ws *WSSender) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return nil
+	}
+	err := ws.conn.Close()
+	ws.conn = nil
+	return err
+}
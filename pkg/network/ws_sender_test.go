@@ -0,0 +1,133 @@
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// wsTestServer accepts WebSocket connections and forwards every text message
+func wsTestServer(t *testing.T, messages chan<- wsMessage, connections *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eis-data/stream" || r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "not a websocket request", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		connections.Add(1)
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		reader := bufio.NewReader(rw)
+		for {
+			opcode, payload, err := readFrame(reader)
+			if err != nil || opcode == wsOpClose {
+				return
+			}
+			var message wsMessage
+			if err := json.Unmarshal(payload, &message); err != nil {
+				t.Errorf("invalid message %q: %v", payload, err)
+				return
+			}
+			messages <- message
+		}
+	}))
+}
+
+func TestWSSender_StreamsOverOneConnection(t *testing.T) {
+	messages := make(chan wsMessage, 10)
+	var connections atomic.Int32
+	server := wsTestServer(t, messages, &connections)
+	defer server.Close()
+
+	sender, err := NewWSSender(server.URL+"/eis-data", "/eis-data/stream")
+	if err != nil {
+		t.Fatalf("NewWSSender() error = %v", err)
+	}
+	defer sender.Close()
+
+	data := signal.ImpedanceData{Identity: signal.NewIdentity(), Frequencies: []float64{1, 10}}
+	for i := 0; i < 3; i++ {
+		if err := sender.SendImpedanceData(data); err != nil {
+			t.Fatalf("SendImpedanceData() error = %v", err)
+		}
+	}
+	if err := sender.SendBatchImpedanceData([]signal.ImpedanceDataWithIteration{{ImpedanceData: data}}); err != nil {
+		t.Fatalf("SendBatchImpedanceData() error = %v", err)
+	}
+
+	wantTypes := []string{"Impedance-Data", "Impedance-Data", "Impedance-Data", "Impedance-Batch"}
+	for _, want := range wantTypes {
+		select {
+		case message := <-messages:
+			if message.Type != want {
+				t.Errorf("message type = %q, want %q", message.Type, want)
+			}
+			if message.Type == "Impedance-Data" && message.IdempotencyKey != data.ID {
+				t.Errorf("idempotency key = %q, want measurement UUID %q", message.IdempotencyKey, data.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s message", want)
+		}
+	}
+
+	if got := connections.Load(); got != 1 {
+		t.Errorf("opened %d connections, want 1", got)
+	}
+	if !sender.IsHealthy() {
+		t.Errorf("sender should be healthy after successful sends")
+	}
+}
+
+func TestWSSender_UnreachableCollector(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	sender, err := NewWSSender(server.URL, "/eis-data/stream")
+	if err != nil {
+		t.Fatalf("NewWSSender() error = %v", err)
+	}
+
+	if err := sender.SendImpedanceData(signal.ImpedanceData{}); err == nil {
+		t.Errorf("expected error when the collector refuses the upgrade")
+	}
+	if sender.IsHealthy() {
+		t.Errorf("sender should be unhealthy after a failed send")
+	}
+	server.Close()
+}
+
+func TestWebSocketURL(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"http://localhost:8080/eis-data", "ws://localhost:8080/eis-data/stream"},
+		{"https://collector.example/eis-data", "wss://collector.example/eis-data/stream"},
+	}
+
+	for _, tt := range tests {
+		got, err := WebSocketURL(tt.target, "/eis-data/stream")
+		if err != nil {
+			t.Fatalf("WebSocketURL(%q) error = %v", tt.target, err)
+		}
+		if got != tt.want {
+			t.Errorf("WebSocketURL(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	if _, err := WebSocketURL("not a url", "/eis-data/stream"); err == nil {
+		t.Errorf("expected error for invalid target URL")
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// State tells whether an alert condition started or stopped holding
+type State string
+
+const (
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert describes a change of state of a monitored condition
+type Alert struct {
+	Condition string    `json:"condition"`
+	State     State     `json:"state"`
+	Message   string    `json:"message"`
+	Since     time.Time `json:"since"`
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname,omitempty"`
+}
+
+// Summary returns a one-line human readable description of the alert
+func (a Alert) Summary() string {
+	host := ""
+	if a.Hostname != "" {
+		host = fmt.Sprintf(" on %s", a.Hostname)
+	}
+	if a.State == StateResolved {
+		return fmt.Sprintf("[RESOLVED] %s%s after %v", a.Condition, host, a.Timestamp.Sub(a.Since).Round(time.Second))
+	}
+	return fmt.Sprintf("[FIRING] %s%s: %s (since %s)", a.Condition, host, a.Message, a.Since.Format(time.RFC3339))
+}
+
+// Condition is a named check evaluated periodically by a Monitor. It fires
+// once Check has reported true continuously for at least For, and resolves
+// when Check reports false again.
+type Condition struct {
+	Name  string
+	For   time.Duration
+	Check func() (active bool, message string)
+}
+
+// StalledCondition fires when progress reports no change for the given
+// duration while the source is expected to make progress
+func StalledCondition(name string, after time.Duration, progress func() (value int64, expected bool)) Condition {
+	last := int64(-1)
+	return Condition{
+		Name: name,
+		For:  after,
+		Check: func() (bool, string) {
+			value, expected := progress()
+			stalled := expected && value == last
+			last = value
+			return stalled, fmt.Sprintf("no progress past %d", value)
+		},
+	}
+}
@@ -0,0 +1,11 @@
+package notify
+
+import (
+	"context"
+)
+
+// Notifier delivers alerts to an external channel such as a webhook or mailbox
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+	Name() string
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+)
+
+// conditionState tracks how long a condition has been active
+type conditionState struct {
+	condition   Condition
+	activeSince time.Time
+	firing      bool
+}
+
+// Monitor periodically evaluates conditions and notifies every notifier when
+// one starts firing or resolves
+type Monitor struct {
+	notifiers  []Notifier
+	clock      clock.Clock
+	hostname   string
+	mu         sync.Mutex
+	conditions []*conditionState
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewMonitor creates a monitor delivering alerts to the given notifiers
+func NewMonitor(notifiers ...Notifier) *Monitor {
+	return NewMonitorWithClock(clock.NewSystemClock(), notifiers...)
+}
+
+// NewMonitorWithClock creates a monitor measuring condition durations with the given clock
+func NewMonitorWithClock(c clock.Clock, notifiers ...Notifier) *Monitor {
+	hostname, _ := os.Hostname()
+	return &Monitor{
+		notifiers: notifiers,
+		clock:     clock.OrSystem(c),
+		hostname:  hostname,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// AddCondition registers a condition to evaluate
+func (m *Monitor) AddCondition(condition Condition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conditions = append(m.conditions, &conditionState{condition: condition})
+}
+
+// Evaluate checks every condition once and sends alerts for state changes
+func (m *Monitor) Evaluate(ctx context.Context) {
+	m.mu.Lock()
+	now := m.clock.Now()
+	var alerts []Alert
+	for _, state := range m.conditions {
+		active, message := state.condition.Check()
+		switch {
+		case active && state.activeSince.IsZero():
+			state.activeSince = now
+		case !active && state.firing:
+			alerts = append(alerts, m.alert(state, StateResolved, message, now))
+		}
+		if !active {
+			state.activeSince = time.Time{}
+			state.firing = false
+			continue
+		}
+		if !state.firing && now.Sub(state.activeSince) >= state.condition.For {
+			state.firing = true
+			alerts = append(alerts, m.alert(state, StateFiring, message, now))
+		}
+	}
+	m.mu.Unlock()
+
+	for _, alert := range alerts {
+		log.Printf("Alert: %s", alert.Summary())
+		for _, notifier := range m.notifiers {
+			if err := notifier.Notify(ctx, alert); err != nil {
+				log.Printf("Failed to deliver alert via %s: %v", notifier.Name(), err)
+			}
+		}
+	}
+}
+
+// alert builds the alert for a state change of a condition
+func (m *Monitor) alert(state *conditionState, s State, message string, now time.Time) Alert {
+	return Alert{
+		Condition: state.condition.Name,
+		State:     s,
+		Message:   message,
+		Since:     state.activeSince,
+		Timestamp: now,
+		Hostname:  m.hostname,
+	}
+}
+
+// Start evaluates the conditions at every interval until Stop is called
+func (m *Monitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+				m.Evaluate(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop halts the periodic evaluation
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+	})
+	m.wg.Wait()
+}
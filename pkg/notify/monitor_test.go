@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+)
+
+// recordingNotifier keeps every alert it is asked to deliver
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (rn *recordingNotifier) Name() string { return "recording" }
+
+func (rn *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	rn.alerts = append(rn.alerts, alert)
+	return nil
+}
+
+func TestMonitor_FiresAfterDurationAndResolves(t *testing.T) {
+	simulated := clock.NewSimulatedClock(time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC))
+	recorder := &recordingNotifier{}
+	monitor := NewMonitorWithClock(simulated, recorder)
+
+	healthy := false
+	monitor.AddCondition(Condition{
+		Name:  "sender_unhealthy",
+		For:   5 * time.Minute,
+		Check: func() (bool, string) { return !healthy, "sender cannot reach target" },
+	})
+
+	steps := []struct {
+		advance time.Duration
+		healthy bool
+		want    []State
+	}{
+		{advance: 0, healthy: false, want: nil},
+		{advance: 4 * time.Minute, healthy: false, want: nil},
+		{advance: time.Minute, healthy: false, want: []State{StateFiring}},
+		{advance: time.Minute, healthy: false, want: []State{StateFiring}}, // Not repeated
+		{advance: time.Minute, healthy: true, want: []State{StateFiring, StateResolved}},
+		{advance: time.Minute, healthy: false, want: []State{StateFiring, StateResolved}}, // Timer restarts
+	}
+
+	for i, step := range steps {
+		simulated.Advance(step.advance)
+		healthy = step.healthy
+		monitor.Evaluate(context.Background())
+
+		var got []State
+		for _, alert := range recorder.alerts {
+			got = append(got, alert.State)
+		}
+		if len(got) != len(step.want) {
+			t.Fatalf("step %d: alerts = %v, want %v", i, got, step.want)
+		}
+		for j := range got {
+			if got[j] != step.want[j] {
+				t.Fatalf("step %d: alerts = %v, want %v", i, got, step.want)
+			}
+		}
+	}
+
+	if resolved := recorder.alerts[1]; resolved.Timestamp.Sub(resolved.Since) != 7*time.Minute {
+		t.Errorf("resolved alert spans %v, want 7m", resolved.Timestamp.Sub(resolved.Since))
+	}
+}
+
+func TestStalledCondition(t *testing.T) {
+	value, expected := int64(0), true
+	condition := StalledCondition("receiver_stalled", time.Minute, func() (int64, bool) { return value, expected })
+
+	steps := []struct {
+		value    int64
+		expected bool
+		want     bool
+	}{
+		{value: 1, expected: true, want: false},
+		{value: 2, expected: true, want: false},
+		{value: 2, expected: true, want: true},
+		{value: 2, expected: false, want: false}, // Finished sources do not stall
+		{value: 3, expected: true, want: false},
+	}
+
+	for i, step := range steps {
+		value, expected = step.value, step.expected
+		if got, _ := condition.Check(); got != step.want {
+			t.Errorf("step %d: stalled = %v, want %v", i, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// SMTPOptions configures delivery of alerts by email
+type SMTPOptions struct {
+	Addr     string // host:port of the mail server
+	From     string
+	To       []string
+	Username string // PLAIN authentication is used when set
+	Password string
+}
+
+// SMTPNotifier emails alerts through an SMTP server
+type SMTPNotifier struct {
+	options SMTPOptions
+}
+
+// NewSMTPNotifier creates a notifier sending alerts by email
+func NewSMTPNotifier(options SMTPOptions) (Notifier, error) {
+	if _, _, err := net.SplitHostPort(options.Addr); err != nil {
+		return nil, config.NewValidationError("SMTPAddr", fmt.Sprintf("invalid SMTP address '%s', expected host:port", options.Addr))
+	}
+	if options.From == "" {
+		return nil, config.NewValidationError("SMTPFrom", "sender address cannot be empty")
+	}
+	if len(options.To) == 0 {
+		return nil, config.NewValidationError("SMTPTo", "at least one recipient is required")
+	}
+	return &SMTPNotifier{options: options}, nil
+}
+
+// Name identifies the notifier in logs
+func (sn *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify sends the alert as a plain text email
+func (sn *SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if sn.options.Username != "" {
+		host, _, _ := net.SplitHostPort(sn.options.Addr)
+		auth = smtp.PlainAuth("", sn.options.Username, sn.options.Password, host)
+	}
+
+	if err := smtp.SendMail(sn.options.Addr, auth, sn.options.From, sn.options.To, sn.message(alert)); err != nil {
+		return config.NewNetworkError("smtp://"+sn.options.Addr, 0, fmt.Errorf("failed to send alert email: %w", err))
+	}
+	return nil
+}
+
+// message renders the alert as an RFC 5322 message
+func (sn *SMTPNotifier) message(alert Alert) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", sn.options.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(sn.options.To, ", "))
+	fmt.Fprintf(&buf, "Subject: masterapp %s\r\n", alert.Summary())
+	fmt.Fprintf(&buf, "Date: %s\r\n", alert.Timestamp.Format(time.RFC1123Z))
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&buf, "Condition: %s\r\nState: %s\r\nSince: %s\r\n", alert.Condition, alert.State, alert.Since.Format(time.RFC3339))
+	if alert.Hostname != "" {
+		fmt.Fprintf(&buf, "Host: %s\r\n", alert.Hostname)
+	}
+	fmt.Fprintf(&buf, "\r\n%s\r\n", alert.Message)
+	return buf.Bytes()
+}
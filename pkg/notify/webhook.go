@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// WebhookNotifier posts alerts as JSON to a generic HTTP endpoint
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting Alert JSON documents to url
+func NewWebhookNotifier(url string) Notifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name identifies the notifier in logs
+func (wn *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts the alert
+func (wn *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, wn.client, wn.url, alert)
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlackNotifier creates a notifier posting alert summaries to a Slack incoming webhook URL
+func NewSlackNotifier(url string) Notifier {
+	return &SlackNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name identifies the notifier in logs
+func (sn *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify posts the alert summary as a Slack message
+func (sn *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, sn.client, sn.url, map[string]string{"text": alert.Summary()})
+}
+
+// postJSON posts payload to url and expects a 2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return config.NewNetworkError(url, 0, fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return config.NewNetworkError(url, 0, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return config.NewNetworkError(url, resp.StatusCode, config.ErrInvalidHTTPResponse)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookAndSlackNotifiers(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	since := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	alert := Alert{
+		Condition: "receiver_stalled",
+		State:     StateFiring,
+		Message:   "no progress past 42",
+		Since:     since,
+		Timestamp: since.Add(2 * time.Minute),
+	}
+
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("webhook Notify() error = %v", err)
+	}
+	if body := <-bodies; body["condition"] != "receiver_stalled" || body["state"] != "firing" {
+		t.Errorf("webhook body = %v", body)
+	}
+
+	if err := NewSlackNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("slack Notify() error = %v", err)
+	}
+	text, _ := (<-bodies)["text"].(string)
+	if !strings.HasPrefix(text, "[FIRING] receiver_stalled") {
+		t.Errorf("slack text = %q", text)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := NewWebhookNotifier(failing.URL).Notify(context.Background(), alert); err == nil {
+		t.Errorf("expected error for a failing webhook")
+	}
+}
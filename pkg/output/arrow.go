@@ -0,0 +1,218 @@
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// arrowMagic opens and closes every Arrow IPC file
+const arrowMagic = "ARROW1"
+
+// Arrow flatbuffer enum and union values, see Schema.fbs and Message.fbs
+const (
+	arrowMetadataV5       = 4
+	arrowHeaderSchema     = 1
+	arrowHeaderRecord     = 3
+	arrowTypeInt          = 2
+	arrowTypeFloatingPt   = 3
+	arrowTypeTimestamp    = 10
+	arrowPrecisionDouble  = 2
+	arrowTimeUnitNanosecs = 3
+)
+
+// ArrowColumns lists the columns of the files ArrowWriter writes: one row per
+// impedance point, in the order of the spectra and their frequencies
+var ArrowColumns = []string{"spectrum", "timestamp", "frequency", "real", "imag", "quality_flags"}
+
+// arrowBlock locates one record batch message in the file
+type arrowBlock struct {
+	offset         int64
+	metadataLength int32
+	bodyLength     int64
+}
+
+// ArrowWriter writes spectra as an Apache Arrow IPC file (Feather v2) with the
+// columns spectrum (int64), timestamp (timestamp[ns, UTC]), frequency, real
+// and imag (float64) and quality_flags (int64, see signal.QualityFlags). Each WriteBatch call becomes one record batch, so
+// pyarrow.ipc.open_file or pyarrow.feather.read_table can memory-map files of
+// any size without parsing. The file is only readable after Close.
+type ArrowWriter struct {
+	w        io.Writer
+	position int64
+	blocks   []arrowBlock
+	err      error
+}
+
+// NewArrowWriter writes the file header and schema to w
+func NewArrowWriter(w io.Writer) (*ArrowWriter, error) {
+	aw := &ArrowWriter{w: w}
+	aw.write([]byte(arrowMagic + "\x00\x00"))
+	aw.writeMessage(arrowHeaderSchema, arrowSchema(), nil)
+	if aw.err != nil {
+		return nil, config.NewProcessingError("Arrow header writing", aw.err)
+	}
+	return aw, nil
+}
+
+// WriteBatch appends the points of all spectra as one record batch
+func (aw *ArrowWriter) WriteBatch(batch []signal.ImpedanceDataWithIteration) error {
+	if aw.err != nil {
+		return aw.err
+	}
+
+	rows := 0
+	for _, spectrum := range batch {
+		data := spectrum.ImpedanceData
+		if len(data.Frequencies) != len(data.Impedance) {
+			return config.NewValidationError("Frequencies", fmt.Sprintf("spectrum %d has %d frequencies for %d impedance values", spectrum.Iteration, len(data.Frequencies), len(data.Impedance)))
+		}
+		rows += len(data.Impedance)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	columns := make([][]byte, len(ArrowColumns))
+	for i := range columns {
+		columns[i] = make([]byte, 0, 8*rows)
+	}
+	for _, spectrum := range batch {
+		data := spectrum.ImpedanceData
+		timestamp := data.Timestamp.UnixNano()
+		for i, z := range data.Impedance {
+			columns[0] = binary.LittleEndian.AppendUint64(columns[0], uint64(spectrum.Iteration))
+			columns[1] = binary.LittleEndian.AppendUint64(columns[1], uint64(timestamp))
+			columns[2] = binary.LittleEndian.AppendUint64(columns[2], math.Float64bits(data.Frequencies[i]))
+			columns[3] = binary.LittleEndian.AppendUint64(columns[3], math.Float64bits(real(z)))
+			columns[4] = binary.LittleEndian.AppendUint64(columns[4], math.Float64bits(imag(z)))
+			columns[5] = binary.LittleEndian.AppendUint64(columns[5], uint64(data.QualityFlags))
+		}
+	}
+
+	// Every column has an empty validity bitmap (no nulls) and its values;
+	// 8-byte values keep all buffers 8-byte aligned within the body
+	var nodes, buffers []byte
+	offset := int64(0)
+	for _, column := range columns {
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(rows))
+		nodes = binary.LittleEndian.AppendUint64(nodes, 0)
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(offset))
+		buffers = binary.LittleEndian.AppendUint64(buffers, 0)
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(offset))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(column)))
+		offset += int64(len(column))
+	}
+	record := fbTable{
+		fbInt64(int64(rows)),
+		fbRef(fbStructs{count: len(columns), align: 8, data: nodes}),
+		fbRef(fbStructs{count: 2 * len(columns), align: 8, data: buffers}),
+	}
+
+	aw.writeMessage(arrowHeaderRecord, record, columns)
+	if aw.err != nil {
+		return config.NewProcessingError("Arrow record batch writing", aw.err)
+	}
+	return nil
+}
+
+// Close writes the end-of-stream marker and the footer indexing all record
+// batches; it does not close the underlying writer
+func (aw *ArrowWriter) Close() error {
+	if aw.err != nil {
+		return aw.err
+	}
+
+	var blocks []byte
+	for _, block := range aw.blocks {
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(block.offset))
+		blocks = binary.LittleEndian.AppendUint32(blocks, uint32(block.metadataLength))
+		blocks = binary.LittleEndian.AppendUint32(blocks, 0) // Struct padding
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(block.bodyLength))
+	}
+	footer := buildFlatbuffer(fbTable{
+		fbInt16(arrowMetadataV5),
+		fbRef(arrowSchema()),
+		fbRef(fbStructs{align: 8}),
+		fbRef(fbStructs{count: len(aw.blocks), align: 8, data: blocks}),
+	})
+
+	aw.write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	aw.write(footer)
+	aw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	aw.write([]byte(arrowMagic))
+	if aw.err != nil {
+		return config.NewProcessingError("Arrow footer writing", aw.err)
+	}
+	aw.err = config.NewValidationError("ArrowWriter", "writer is closed")
+	return nil
+}
+
+// writeMessage writes an encapsulated message: continuation marker, metadata
+// length, the Message flatbuffer padded to 8 bytes and the body buffers
+func (aw *ArrowWriter) writeMessage(headerType uint8, header fbTable, body [][]byte) {
+	bodyLength := int64(0)
+	for _, buffer := range body {
+		bodyLength += int64(len(buffer))
+	}
+	metadata := buildFlatbuffer(fbTable{
+		fbInt16(arrowMetadataV5),
+		fbUint8(headerType),
+		fbRef(header),
+		fbInt64(bodyLength),
+	})
+
+	start := aw.position
+	aw.write([]byte{0xff, 0xff, 0xff, 0xff})
+	aw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	aw.write(metadata)
+	for _, buffer := range body {
+		aw.write(buffer)
+	}
+	if headerType == arrowHeaderRecord {
+		aw.blocks = append(aw.blocks, arrowBlock{offset: start, metadataLength: int32(8 + len(metadata)), bodyLength: bodyLength})
+	}
+}
+
+// write writes p unless an earlier write failed
+func (aw *ArrowWriter) write(p []byte) {
+	if aw.err != nil {
+		return
+	}
+	n, err := aw.w.Write(p)
+	aw.position += int64(n)
+	aw.err = err
+}
+
+// arrowSchema describes the columns of ArrowColumns
+func arrowSchema() fbTable {
+	int64Type := fbTable{fbInt32(64), fbBool(true)}
+	timestampType := fbTable{fbInt16(arrowTimeUnitNanosecs), fbRef(fbString("UTC"))}
+	doubleType := fbTable{fbInt16(arrowPrecisionDouble)}
+
+	field := func(name string, typeID uint8, fieldType fbTable) fbObject {
+		return fbTable{
+			fbRef(fbString(name)),
+			fbBool(false),
+			fbUint8(typeID),
+			fbRef(fieldType),
+			nil,
+			fbRef(fbTables{}), // Readers require the children vector even when empty
+		}
+	}
+	return fbTable{
+		fbInt16(0), // Little endian
+		fbRef(fbTables{
+			field(ArrowColumns[0], arrowTypeInt, int64Type),
+			field(ArrowColumns[1], arrowTypeTimestamp, timestampType),
+			field(ArrowColumns[2], arrowTypeFloatingPt, doubleType),
+			field(ArrowColumns[3], arrowTypeFloatingPt, doubleType),
+			field(ArrowColumns[4], arrowTypeFloatingPt, doubleType),
+			field(ArrowColumns[5], arrowTypeInt, int64Type),
+		}),
+	}
+}
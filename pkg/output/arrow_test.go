@@ -0,0 +1,207 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// fbReader reads the flatbuffer tables the Arrow writer produces
+type fbReader []byte
+
+func (r fbReader) u32(position int) int { return int(binary.LittleEndian.Uint32(r[position:])) }
+
+// root returns the position of the root table
+func (r fbReader) root() int { return r.u32(0) }
+
+// field returns the position of a table field, or -1 when it is absent
+func (r fbReader) field(table, slot int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(r[table:])))
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(r[vtable:])) {
+		return -1
+	}
+	offset := int(binary.LittleEndian.Uint16(r[vtable+4+2*slot:]))
+	if offset == 0 {
+		return -1
+	}
+	return table + offset
+}
+
+// ref follows the uoffset of a table field
+func (r fbReader) ref(table, slot int) int {
+	position := r.field(table, slot)
+	return position + r.u32(position)
+}
+
+func (r fbReader) i64(table, slot int) int64 {
+	return int64(binary.LittleEndian.Uint64(r[r.field(table, slot):]))
+}
+
+func (r fbReader) str(table, slot int) string {
+	position := r.ref(table, slot)
+	return string(r[position+4 : position+4+r.u32(position)])
+}
+
+func TestArrowWriter(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 123, time.UTC)
+	spectrum := func(iteration int, values ...complex128) signal.ImpedanceDataWithIteration {
+		frequencies := make([]float64, len(values))
+		for i := range frequencies {
+			frequencies[i] = 10 * float64(i+1)
+		}
+		return signal.ImpedanceDataWithIteration{
+			Iteration: iteration,
+			ImpedanceData: signal.ImpedanceData{
+				Timestamp:    start.Add(time.Duration(iteration) * time.Second),
+				Frequencies:  frequencies,
+				Impedance:    values,
+				QualityFlags: signal.QualityFlags(iteration),
+			},
+		}
+	}
+	batches := [][]signal.ImpedanceDataWithIteration{
+		{spectrum(0, complex(10, -2), complex(8, -3)), spectrum(1, complex(9, -1))},
+		nil, // Empty batches are skipped
+		{spectrum(2, complex(7.5, -0.25), complex(6, 0), complex(5, 1e-9))},
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewArrowWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewArrowWriter() error = %v", err)
+	}
+	for _, batch := range batches {
+		if err := writer.WriteBatch(batch); err != nil {
+			t.Fatalf("WriteBatch() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := writer.WriteBatch(batches[0]); err == nil {
+		t.Error("WriteBatch() after Close succeeded")
+	}
+
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(file, []byte("ARROW1")) {
+		t.Fatalf("file lacks the Arrow magic: % x ... % x", file[:8], file[len(file)-6:])
+	}
+
+	footerSize := int(binary.LittleEndian.Uint32(file[len(file)-10:]))
+	footer := fbReader(file[len(file)-10-footerSize : len(file)-10])
+	root := footer.root()
+
+	schema := footer.ref(root, 1)
+	fields := footer.ref(schema, 1)
+	if n := footer.u32(fields); n != len(ArrowColumns) {
+		t.Fatalf("schema has %d fields, want %d", n, len(ArrowColumns))
+	}
+	wantTypes := []uint8{arrowTypeInt, arrowTypeTimestamp, arrowTypeFloatingPt, arrowTypeFloatingPt, arrowTypeFloatingPt, arrowTypeInt}
+	for i, name := range ArrowColumns {
+		position := fields + 4 + 4*i
+		field := position + footer.u32(position)
+		if got := footer.str(field, 0); got != name {
+			t.Errorf("field %d name = %q, want %q", i, got, name)
+		}
+		if got := footer[footer.field(field, 2)]; got != wantTypes[i] {
+			t.Errorf("field %s type = %d, want %d", name, got, wantTypes[i])
+		}
+	}
+
+	blocks := footer.ref(root, 3)
+	if n := footer.u32(blocks); n != 2 {
+		t.Fatalf("footer lists %d record batches, want 2", n)
+	}
+
+	var spectra, flags []int64
+	var timestamps []int64
+	var frequencies, reals, imags []float64
+	for i := 0; i < 2; i++ {
+		block := blocks + 4 + 24*i
+		offset := int(binary.LittleEndian.Uint64(footer[block:]))
+		metadataLength := int(binary.LittleEndian.Uint32(footer[block+8:]))
+		bodyLength := int(binary.LittleEndian.Uint64(footer[block+16:]))
+		if offset%8 != 0 || metadataLength%8 != 0 {
+			t.Errorf("block %d at %d with metadata %d is not 8-byte aligned", i, offset, metadataLength)
+		}
+		if binary.LittleEndian.Uint32(file[offset:]) != 0xffffffff {
+			t.Fatalf("block %d does not start with the continuation marker", i)
+		}
+
+		message := fbReader(file[offset+8 : offset+metadataLength])
+		if got := message[message.field(message.root(), 1)]; got != arrowHeaderRecord {
+			t.Fatalf("block %d header type = %d, want record batch", i, got)
+		}
+		if got := message.i64(message.root(), 3); int(got) != bodyLength {
+			t.Errorf("block %d body length = %d, footer says %d", i, got, bodyLength)
+		}
+		record := message.ref(message.root(), 2)
+		rows := int(message.i64(record, 0))
+		body := file[offset+metadataLength : offset+metadataLength+bodyLength]
+		buffers := message.ref(record, 2)
+
+		column := func(index int) []uint64 {
+			data := buffers + 4 + 16*(2*index+1)
+			start := int(binary.LittleEndian.Uint64(message[data:]))
+			length := int(binary.LittleEndian.Uint64(message[data+8:]))
+			if start%8 != 0 || length != 8*rows {
+				t.Errorf("column %d buffer at %d with %d bytes for %d rows", index, start, length, rows)
+			}
+			values := make([]uint64, rows)
+			for j := range values {
+				values[j] = binary.LittleEndian.Uint64(body[start+8*j:])
+			}
+			return values
+		}
+		for _, v := range column(0) {
+			spectra = append(spectra, int64(v))
+		}
+		for _, v := range column(1) {
+			timestamps = append(timestamps, int64(v))
+		}
+		for _, v := range column(5) {
+			flags = append(flags, int64(v))
+		}
+		for index, target := range []*[]float64{&frequencies, &reals, &imags} {
+			for _, v := range column(index + 2) {
+				*target = append(*target, math.Float64frombits(v))
+			}
+		}
+	}
+
+	row := 0
+	for _, batch := range batches {
+		for _, s := range batch {
+			for i, z := range s.ImpedanceData.Impedance {
+				if spectra[row] != int64(s.Iteration) || timestamps[row] != s.ImpedanceData.Timestamp.UnixNano() ||
+					frequencies[row] != s.ImpedanceData.Frequencies[i] || reals[row] != real(z) || imags[row] != imag(z) ||
+					flags[row] != int64(s.ImpedanceData.QualityFlags) {
+					t.Errorf("row %d = (%d, %d, %g, %g, %g), want spectrum %d point %d", row,
+						spectra[row], timestamps[row], frequencies[row], reals[row], imags[row], s.Iteration, i)
+				}
+				row++
+			}
+		}
+	}
+	if row != len(spectra) {
+		t.Errorf("file has %d rows, want %d", len(spectra), row)
+	}
+}
+
+func TestArrowWriter_MismatchedSpectrum(t *testing.T) {
+	writer, err := NewArrowWriter(&bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("NewArrowWriter() error = %v", err)
+	}
+	batch := []signal.ImpedanceDataWithIteration{{ImpedanceData: signal.ImpedanceData{
+		Frequencies: []float64{1, 2},
+		Impedance:   []complex128{complex(1, 0)},
+	}}}
+	if err := writer.WriteBatch(batch); err == nil {
+		t.Error("WriteBatch() accepted a spectrum with mismatched frequencies")
+	}
+}
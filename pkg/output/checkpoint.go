@@ -0,0 +1,110 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Checkpoint is the persisted position of a pipeline run. Only the field of
+// the checkpoint's mode is meaningful.
+type Checkpoint struct {
+	Mode        string    `json:"mode"`
+	Fingerprint string    `json:"fingerprint"`
+	FileIndex   int       `json:"file_index,omitempty"`   // Signal pairs processed from the voltage/current files
+	Spectrum    int       `json:"spectrum,omitempty"`     // Spectra generated in direct EIS mode
+	DataOffset  int64     `json:"data_offset,omitempty"`  // Length of the direct EIS data file at the checkpoint
+	SpectraRead int       `json:"spectra_read,omitempty"` // Spectra delivered from the impedance CSV
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Fingerprint identifies a run's inputs and settings, so that a checkpoint
+// written for different inputs is not resumed
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckpointStore persists checkpoints to a single JSON file
+type CheckpointStore struct {
+	path string
+}
+
+// NewCheckpointStore creates a store for the checkpoint file at path
+func NewCheckpointStore(path string) *CheckpointStore {
+	return &CheckpointStore{path: path}
+}
+
+// Path returns the checkpoint file path
+func (cs *CheckpointStore) Path() string {
+	return cs.path
+}
+
+// Load reads the checkpoint; found is false when no checkpoint file exists
+func (cs *CheckpointStore) Load() (checkpoint Checkpoint, found bool, err error) {
+	data, err := os.ReadFile(cs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, config.NewProcessingError("checkpoint loading", err)
+	}
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return Checkpoint{}, false, config.NewProcessingError("checkpoint loading",
+			fmt.Errorf("invalid checkpoint file %s: %w", cs.path, err))
+	}
+	return checkpoint, true, nil
+}
+
+// Save atomically replaces the checkpoint file, so a crash while saving
+// leaves the previous checkpoint intact
+func (cs *CheckpointStore) Save(checkpoint Checkpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+
+	dir := filepath.Dir(cs.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(cs.path)+".tmp*")
+	if err != nil {
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+	if err := os.Rename(tmp.Name(), cs.path); err != nil {
+		return config.NewProcessingError("checkpoint saving", err)
+	}
+	return nil
+}
+
+// Clear removes the checkpoint file once a run has completed
+func (cs *CheckpointStore) Clear() error {
+	if err := os.Remove(cs.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return config.NewProcessingError("checkpoint clearing", err)
+	}
+	return nil
+}
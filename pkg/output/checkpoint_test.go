@@ -0,0 +1,58 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	store := NewCheckpointStore(filepath.Join(t.TempDir(), "state", "checkpoint.json"))
+
+	if _, found, err := store.Load(); err != nil || found {
+		t.Fatalf("Load() on missing file = found %v, error %v; want not found, nil", found, err)
+	}
+
+	saved := Checkpoint{
+		Mode:        "file",
+		Fingerprint: Fingerprint("file", "voltage.csv", "current.csv"),
+		FileIndex:   7,
+		UpdatedAt:   time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	saved.FileIndex = 8
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, found, err := store.Load()
+	if err != nil || !found {
+		t.Fatalf("Load() = found %v, error %v", found, err)
+	}
+	if loaded != saved {
+		t.Errorf("Load() = %+v, want %+v", loaded, saved)
+	}
+	if Fingerprint("file", "a.csv", "b.csv") == Fingerprint("file", "a.csvb", ".csv") {
+		t.Errorf("Fingerprint() must separate its parts")
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, err := os.Stat(store.Path()); !os.IsNotExist(err) {
+		t.Errorf("checkpoint file still exists after Clear()")
+	}
+	if err := store.Clear(); err != nil {
+		t.Errorf("Clear() on missing file error = %v", err)
+	}
+
+	if err := os.WriteFile(store.Path(), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Load(); err == nil {
+		t.Errorf("expected error loading a corrupt checkpoint")
+	}
+}
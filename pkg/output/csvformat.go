@@ -0,0 +1,77 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// CSVFormat controls the separators and number formatting of CSV output. The
+// zero value keeps the format each writer uses by default: comma separated,
+// decimal point, and the writer's own notation and precision.
+type CSVFormat struct {
+	Delimiter        rune // Field separator; 0 = ','
+	DecimalSeparator rune // '.' or ','; 0 = '.'
+	Notation         byte // 'f' fixed, 'e' scientific or 'g' shortest; 0 = writer default
+	Precision        int  // Digits after the decimal point, significant digits for 'g'; 0 = writer default
+}
+
+// ParseCSVFormat builds a format from textual options: delimiters as for
+// signal.ParseCSVDialect, notation "fixed", "scientific", "shortest" or empty
+// for the writer default
+func ParseCSVFormat(delimiter, decimal, notation string, precision int) (CSVFormat, error) {
+	dialect, err := signal.ParseCSVDialect(delimiter, decimal, "", false)
+	if err != nil {
+		return CSVFormat{}, err
+	}
+	format := CSVFormat{Delimiter: dialect.Delimiter, DecimalSeparator: dialect.DecimalSeparator, Precision: precision}
+	switch notation {
+	case "":
+	case "fixed":
+		format.Notation = 'f'
+	case "scientific":
+		format.Notation = 'e'
+	case "shortest":
+		format.Notation = 'g'
+	default:
+		return CSVFormat{}, config.NewValidationError("Notation", fmt.Sprintf("unknown number notation '%s', must be fixed, scientific or shortest", notation))
+	}
+	if precision < 0 || precision > 17 {
+		return CSVFormat{}, config.NewValidationError("Precision", "precision must be between 0 (writer default) and 17 digits")
+	}
+	return format, nil
+}
+
+// NewWriter creates a csv.Writer using the format's delimiter
+func (f CSVFormat) NewWriter(w io.Writer) *csv.Writer {
+	writer := csv.NewWriter(w)
+	if f.Delimiter != 0 {
+		writer.Comma = f.Delimiter
+	}
+	return writer
+}
+
+// Float formats v in the configured notation and precision, falling back to
+// the writer's defaults, with the configured decimal separator. Shortest
+// notation without a precision writes the shortest exact representation.
+func (f CSVFormat) Float(v float64, notation byte, precision int) string {
+	if f.Notation != 0 {
+		notation = f.Notation
+		if f.Notation == 'g' && f.Precision == 0 {
+			precision = -1
+		}
+	}
+	if f.Precision != 0 {
+		precision = f.Precision
+	}
+	s := strconv.FormatFloat(v, notation, precision, 64)
+	if f.DecimalSeparator == ',' {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
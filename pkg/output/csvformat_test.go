@@ -0,0 +1,77 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCSVFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		delimiter string
+		decimal   string
+		notation  string
+		precision int
+		want      CSVFormat
+		wantErr   bool
+	}{
+		{"defaults", "", "", "", 0, CSVFormat{Delimiter: ',', DecimalSeparator: '.'}, false},
+		{"european", ";", ",", "fixed", 3, CSVFormat{Delimiter: ';', DecimalSeparator: ',', Notation: 'f', Precision: 3}, false},
+		{"tab scientific", "tab", ".", "scientific", 0, CSVFormat{Delimiter: '\t', DecimalSeparator: '.', Notation: 'e'}, false},
+		{"shortest", ",", ".", "shortest", 0, CSVFormat{Delimiter: ',', DecimalSeparator: '.', Notation: 'g'}, false},
+		{"unknown notation", ",", ".", "engineering", 0, CSVFormat{}, true},
+		{"negative precision", ",", ".", "", -1, CSVFormat{}, true},
+		{"excessive precision", ",", ".", "", 18, CSVFormat{}, true},
+		{"invalid decimal", ",", "_", "", 0, CSVFormat{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCSVFormat(tt.delimiter, tt.decimal, tt.notation, tt.precision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCSVFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCSVFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVFormatFloat(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    CSVFormat
+		notation  byte
+		precision int
+		v         float64
+		want      string
+	}{
+		{"writer default", CSVFormat{}, 'f', 6, 1.5, "1.500000"},
+		{"writer scientific", CSVFormat{}, 'e', 12, 1234.5, "1.234500000000e+03"},
+		{"precision override", CSVFormat{Precision: 2}, 'f', 6, 3.14159, "3.14"},
+		{"notation override", CSVFormat{Notation: 'e'}, 'f', 6, 1234.5, "1.234500e+03"},
+		{"shortest exact", CSVFormat{Notation: 'g'}, 'f', 6, 0.1, "0.1"},
+		{"shortest digits", CSVFormat{Notation: 'g', Precision: 3}, 'f', 6, 3.14159, "3.14"},
+		{"decimal comma", CSVFormat{DecimalSeparator: ','}, 'f', 2, -12.5, "-12,50"},
+		{"decimal comma scientific", CSVFormat{DecimalSeparator: ',', Notation: 'e', Precision: 1}, 'f', 6, 1500, "1,5e+03"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.Float(tt.v, tt.notation, tt.precision); got != tt.want {
+				t.Errorf("Float(%v) = %q, want %q", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVFormatNewWriter(t *testing.T) {
+	format := CSVFormat{Delimiter: ';', DecimalSeparator: ','}
+	var buf bytes.Buffer
+	writer := format.NewWriter(&buf)
+	writer.Write([]string{"frequency", "real"})
+	writer.Write([]string{format.Float(10, 'g', 6), format.Float(0.25, 'f', 3)})
+	writer.Flush()
+	if want := "frequency;real\n10;0,250\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// CSVCommentPrefix starts comment lines in CSV files; the CSV loaders skip them
+const CSVCommentPrefix = "# "
+
+// MetadataField is one "key: value" entry of a CSV metadata block
+type MetadataField struct {
+	Key   string
+	Value string
+}
+
+// CSVMetadata is a block of metadata written as comment lines above or below
+// the rows of a CSV file, keeping shared files self-describing
+type CSVMetadata []MetadataField
+
+// Add appends a field, formatting value with %v
+func (m *CSVMetadata) Add(key string, value interface{}) {
+	*m = append(*m, MetadataField{Key: key, Value: fmt.Sprint(value)})
+}
+
+// WriteTo writes the block as "# key: value" lines
+func (m CSVMetadata) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, field := range m {
+		value := strings.ReplaceAll(field.Value, "\n", " ")
+		n, err := fmt.Fprintf(w, "%s%s: %s\n", CSVCommentPrefix, field.Key, value)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// TrimCSVFooter removes the comment lines at the end of a CSV file, such as
+// a metadata footer, and leaves the file positioned at its new end so rows
+// can be appended. The file must be open for reading and writing.
+func TrimCSVFooter(file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	var offset, end int64
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if line != "" && !strings.HasPrefix(line, strings.TrimSpace(CSVCommentPrefix)) {
+			end = offset
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := file.Truncate(end); err != nil {
+		return err
+	}
+	_, err := file.Seek(end, io.SeekStart)
+	return err
+}
+
+// BuildVersion describes the running binary by its module version or, for
+// development builds, the VCS revision it was built from
+func BuildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	version, dirty := "(devel)", ""
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision":
+			version += " " + setting.Value
+		case setting.Key == "vcs.modified" && setting.Value == "true":
+			dirty = "+dirty"
+		}
+	}
+	return version + dirty
+}
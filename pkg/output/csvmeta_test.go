@@ -0,0 +1,65 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCSVMetadata_WriteTo(t *testing.T) {
+	metadata := CSVMetadata{}
+	metadata.Add("circuit", "medium")
+	metadata.Add("spectra", 100)
+	metadata.Add("note", "first\nsecond")
+
+	var out strings.Builder
+	if _, err := metadata.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := "# circuit: medium\n# spectra: 100\n# note: first second\n"
+	if out.String() != want {
+		t.Errorf("WriteTo() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestTrimCSVFooter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "footer", content: "# circuit: simple\nZ_real,Z_imag\n1,2\n3,4\n# finished: now\n# last_spectrum: 1\n", want: "# circuit: simple\nZ_real,Z_imag\n1,2\n3,4\n"},
+		{name: "no footer", content: "Z_real,Z_imag\n1,2\n", want: "Z_real,Z_imag\n1,2\n"},
+		{name: "header only", content: "# circuit: simple\n", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := TrimCSVFooter(file); err != nil {
+				t.Fatalf("TrimCSVFooter() error = %v", err)
+			}
+			if _, err := file.WriteString("5,6\n"); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want+"5,6\n" {
+				t.Errorf("file after trimming and appending = %q, want %q", got, tt.want+"5,6\n")
+			}
+		})
+	}
+}
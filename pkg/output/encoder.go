@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// JSONEncoder writes a measurement as JSON, indented for one file per
+// measurement or on a single line for JSON lines files
+type JSONEncoder struct {
+	Indent bool
+}
+
+// Extension returns "json"
+func (je JSONEncoder) Extension() string {
+	return "json"
+}
+
+// Encode writes the measurement; a single line ends with a newline
+func (je JSONEncoder) Encode(w io.Writer, measurement signal.Measurement) error {
+	var data []byte
+	var err error
+	if je.Indent {
+		data, err = json.MarshalIndent(measurement, "", "  ")
+	} else {
+		data, err = json.Marshal(measurement)
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	if _, err := w.Write(data); err != nil {
+		return config.NewProcessingError("JSON writing", err)
+	}
+	return nil
+}
+
+// CSVEncoder writes a measurement as CSV with a header and one row per point:
+// frequency, real, imag, magnitude_ohm and phase_deg if the points carry
+// them, quality_flags, then identity and, if the measurement carries any,
+// metadata as constant columns
+type CSVEncoder struct {
+	Format CSVFormat // Separators and number format
+}
+
+// Extension returns "csv"
+func (ce CSVEncoder) Extension() string {
+	return "csv"
+}
+
+// Encode writes the measurement
+func (ce CSVEncoder) Encode(w io.Writer, measurement signal.Measurement) error {
+	// Identity and metadata are repeated as constant trailing columns
+	metadataNames := []string{"id", "sequence"}
+	metadataValues := []string{measurement.ID, strconv.FormatUint(measurement.Sequence, 10)}
+	if !measurement.Metadata.IsZero() {
+		names, values := measurement.Metadata.Columns()
+		metadataNames = append(metadataNames, names...)
+		metadataValues = append(metadataValues, values...)
+	}
+
+	// Write CSV header
+	writer := ce.Format.NewWriter(w)
+	withBode := measurement.Points.HasBode()
+	header := []string{"frequency", "real", "imag", "quality_flags"}
+	if withBode {
+		header = []string{"frequency", "real", "imag", "magnitude_ohm", "phase_deg", "quality_flags"}
+	}
+	writer.Write(append(header, metadataNames...))
+
+	// Write impedance data
+	for _, point := range measurement.Points {
+		row := []string{
+			ce.Format.Float(point.Frequency, 'g', 6),
+			ce.Format.Float(point.Real, 'f', 6),
+			ce.Format.Float(point.Imag, 'f', 6),
+		}
+		if withBode {
+			row = append(row, ce.Format.Float(*point.MagnitudeOhm, 'f', 6), ce.Format.Float(*point.PhaseDeg, 'f', 4))
+		}
+		row = append(row, strconv.FormatUint(uint64(point.QualityFlags), 10))
+		writer.Write(append(row, metadataValues...))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return config.NewProcessingError("CSV writing", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// encoderTestMeasurement returns a one point measurement with identity
+func encoderTestMeasurement(withBode bool) signal.Measurement {
+	data := signal.ImpedanceData{Frequencies: []float64{1000}, Impedance: []complex128{complex(3, -4)}, QualityFlags: signal.FlagAliasing}
+	data.Identity = signal.Identity{ID: "id-9", Sequence: 9}
+	return signal.NewMeasurement(data, withBode)
+}
+
+func TestMeasurementEncoders(t *testing.T) {
+	tests := []struct {
+		name      string
+		encoder   MeasurementEncoder
+		withBode  bool
+		extension string
+		want      string
+	}{
+		{"json line", JSONEncoder{}, false, "json",
+			`{"id":"id-9","sequence":9,"quality_flags":32,"points":[{"frequency":1000,"real":3,"imag":-4,"quality_flags":32}]}` + "\n"},
+		{"csv", CSVEncoder{}, false, "csv",
+			"frequency,real,imag,quality_flags,id,sequence\n1000,3.000000,-4.000000,32,id-9,9\n"},
+		{"csv with bode", CSVEncoder{}, true, "csv",
+			"frequency,real,imag,magnitude_ohm,phase_deg,quality_flags,id,sequence\n1000,3.000000,-4.000000,5.000000,-53.1301,32,id-9,9\n"},
+		{"csv decimal comma", CSVEncoder{Format: CSVFormat{DecimalSeparator: ','}}, false, "csv",
+			"frequency,real,imag,quality_flags,id,sequence\n1000,\"3,000000\",\"-4,000000\",32,id-9,9\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.encoder.Encode(&buf, encoderTestMeasurement(tt.withBode)); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Encode() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+			if got := tt.encoder.Extension(); got != tt.extension {
+				t.Errorf("Extension() = %s, want %s", got, tt.extension)
+			}
+		})
+	}
+}
+
+func TestJSONEncoder_Indent(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONEncoder{Indent: true}).Encode(&buf, encoderTestMeasurement(true)); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "\n  \"points\": [") {
+		t.Errorf("expected indented JSON, got %s", buf.String())
+	}
+
+	var decoded signal.Measurement
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("measurement is not valid JSON: %v", err)
+	}
+	if decoded.ID != "id-9" || !decoded.Points.HasBode() || *decoded.Points[0].MagnitudeOhm != 5 {
+		t.Errorf("decoded measurement = %+v", decoded)
+	}
+}
@@ -0,0 +1,186 @@
+package output
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// fbObject is a flatbuffer table, vector or string referenced by offset
+type fbObject interface {
+	write(b *fbBuilder) int
+}
+
+// fbField is one table field: an inline little-endian scalar or a reference
+type fbField struct {
+	scalar []byte
+	ref    fbObject
+}
+
+// fbTable is a flatbuffer table whose fields are indexed by their slot in the
+// schema; nil fields are absent and read as their default
+type fbTable []*fbField
+
+// fbStructs is a vector of inline structs of the given alignment
+type fbStructs struct {
+	count int
+	align int
+	data  []byte
+}
+
+// fbTables is a vector of tables
+type fbTables []fbObject
+
+// fbString is a null-terminated flatbuffer string
+type fbString string
+
+// fbBuilder lays out flatbuffers front to back: every object is written
+// before the objects it references, so all offsets point forward as the
+// format requires. Scalars are aligned to their size relative to the buffer
+// start, which callers keep 8-byte aligned.
+type fbBuilder struct {
+	buf []byte
+}
+
+// buildFlatbuffer serializes root and pads the result to 8 bytes
+func buildFlatbuffer(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 512)}
+	position := root.write(b)
+	binary.LittleEndian.PutUint32(b.buf[0:], uint32(position))
+	b.pad(8)
+	return b.buf
+}
+
+// fbBool is a boolean table field
+func fbBool(v bool) *fbField {
+	if v {
+		return &fbField{scalar: []byte{1}}
+	}
+	return &fbField{scalar: []byte{0}}
+}
+
+// fbUint8 is a ubyte table field such as a union type
+func fbUint8(v uint8) *fbField {
+	return &fbField{scalar: []byte{v}}
+}
+
+// fbInt16 is a short table field such as an enum
+func fbInt16(v int16) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+
+// fbInt32 is an int table field
+func fbInt32(v int32) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint32(nil, uint32(v))}
+}
+
+// fbInt64 is a long table field
+func fbInt64(v int64) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))}
+}
+
+// fbRef is a table field referencing another object
+func fbRef(object fbObject) *fbField {
+	return &fbField{ref: object}
+}
+
+// pad appends zeros until the buffer length is a multiple of align
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch stores the forward offset from the uoffset at position to target
+func (b *fbBuilder) patch(position, target int) {
+	binary.LittleEndian.PutUint32(b.buf[position:], uint32(target-position))
+}
+
+// write lays out the vtable, then the table, then the referenced objects
+func (t fbTable) write(b *fbBuilder) int {
+	// Place the largest fields first so every field is aligned to its size
+	// within the 8-byte aligned table
+	order := make([]int, 0, len(t))
+	for slot, field := range t {
+		if field != nil {
+			order = append(order, slot)
+		}
+	}
+	size := func(field *fbField) int {
+		if field.ref != nil {
+			return 4
+		}
+		return len(field.scalar)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return size(t[order[i]]) > size(t[order[j]]) })
+
+	offsets := make([]int, len(t))
+	tableSize := 4 // soffset to the vtable
+	for _, slot := range order {
+		n := size(t[slot])
+		for tableSize%n != 0 {
+			tableSize++
+		}
+		offsets[slot] = tableSize
+		tableSize += n
+	}
+
+	b.pad(2)
+	vtable := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(tableSize))
+	for _, offset := range offsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(offset))
+	}
+
+	b.pad(8)
+	table := len(b.buf)
+	b.buf = append(b.buf, make([]byte, tableSize)...)
+	binary.LittleEndian.PutUint32(b.buf[table:], uint32(int32(table-vtable)))
+	for _, slot := range order {
+		if t[slot].ref == nil {
+			copy(b.buf[table+offsets[slot]:], t[slot].scalar)
+		}
+	}
+	for _, slot := range order {
+		if t[slot].ref != nil {
+			target := t[slot].ref.write(b)
+			b.patch(table+offsets[slot], target)
+		}
+	}
+	return table
+}
+
+// write places the length so that the first element is aligned
+func (v fbStructs) write(b *fbBuilder) int {
+	b.pad(4)
+	for (len(b.buf)+4)%v.align != 0 {
+		b.buf = append(b.buf, 0, 0, 0, 0)
+	}
+	position := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.count))
+	b.buf = append(b.buf, v.data...)
+	return position
+}
+
+// write stores the element offsets, then the tables they point to
+func (v fbTables) write(b *fbBuilder) int {
+	b.pad(4)
+	position := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, object := range v {
+		target := object.write(b)
+		b.patch(position+4+4*i, target)
+	}
+	return position
+}
+
+// write stores the length, the bytes and a terminating zero
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4)
+	position := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return position
+}
@@ -0,0 +1,28 @@
+package output
+
+import (
+	"io"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// RawChunkSink stores or forwards the raw time-domain chunks behind spectra
+type RawChunkSink interface {
+	WriteRawChunk(chunk signal.RawChunk) error
+}
+
+// Sink receives the spectra of a run, e.g. writing them to files or sending
+// them to the target. Flush completes buffered output, such as a partial
+// batch; Close flushes and releases the sink.
+type Sink interface {
+	Write(data signal.ImpedanceDataWithIteration) error
+	Flush() error
+	Close() error
+}
+
+// MeasurementEncoder writes measurements in one file format, named by the
+// extension of its files
+type MeasurementEncoder interface {
+	Encode(w io.Writer, measurement signal.Measurement) error
+	Extension() string
+}
@@ -0,0 +1,185 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// maxManifestErrors caps the error messages kept verbatim in a manifest
+const maxManifestErrors = 100
+
+// InputFile identifies an input by path, size and content hash
+type InputFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// TimingStats summarizes per-spectrum processing times in milliseconds
+type TimingStats struct {
+	Count  int64   `json:"count"`
+	MinMs  float64 `json:"min_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// Manifest records what a run consumed and produced, for reproducibility audits
+type Manifest struct {
+	StartedAt       time.Time   `json:"started_at"`
+	FinishedAt      time.Time   `json:"finished_at"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Mode            string      `json:"mode"`
+	Config          interface{} `json:"config"`
+	Inputs          []InputFile `json:"inputs"`
+	SpectraProduced int64       `json:"spectra_produced"`
+	SpectraPerSec   float64     `json:"spectra_per_second"`
+	Processing      TimingStats `json:"processing"`
+	ErrorCount      int64       `json:"error_count"`
+	Errors          []string    `json:"errors,omitempty"` // First maxManifestErrors messages
+	Outputs         []string    `json:"outputs"`
+}
+
+// Summary returns a one-line description of the run
+func (m Manifest) Summary() string {
+	return fmt.Sprintf("%s run: %d spectra in %.1fs (%.2f/s), %d errors, %d output files",
+		m.Mode, m.SpectraProduced, m.DurationSeconds, m.SpectraPerSec, m.ErrorCount, len(m.Outputs))
+}
+
+// ManifestRecorder accumulates a Manifest while the pipeline runs; it is safe
+// for concurrent use
+type ManifestRecorder struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	manifest Manifest
+	totalMs  float64
+}
+
+// NewManifestRecorder starts recording a run of the given mode and configuration
+func NewManifestRecorder(mode string, cfg interface{}, c clock.Clock) *ManifestRecorder {
+	c = clock.OrSystem(c)
+	return &ManifestRecorder{
+		clock: c,
+		manifest: Manifest{
+			StartedAt: c.Now(),
+			Mode:      mode,
+			Config:    cfg,
+			Inputs:    []InputFile{},
+			Outputs:   []string{},
+		},
+	}
+}
+
+// AddInput hashes an input file and records it
+func (mr *ManifestRecorder) AddInput(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return config.NewProcessingError("input hashing", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return config.NewProcessingError("input hashing", err)
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.manifest.Inputs = append(mr.manifest.Inputs, InputFile{
+		Path:   path,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+	return nil
+}
+
+// RecordSpectrum counts a produced spectrum and the time it took to compute
+// (zero when the spectrum was not computed, e.g. read from a file)
+func (mr *ManifestRecorder) RecordSpectrum(elapsed time.Duration) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.manifest.SpectraProduced++
+	if elapsed <= 0 {
+		return
+	}
+
+	ms := float64(elapsed) / float64(time.Millisecond)
+	stats := &mr.manifest.Processing
+	if stats.Count == 0 || ms < stats.MinMs {
+		stats.MinMs = ms
+	}
+	stats.MaxMs = math.Max(stats.MaxMs, ms)
+	stats.Count++
+	mr.totalMs += ms
+	stats.MeanMs = mr.totalMs / float64(stats.Count)
+}
+
+// RecordError counts an error, keeping the first messages verbatim
+func (mr *ManifestRecorder) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.manifest.ErrorCount++
+	if len(mr.manifest.Errors) < maxManifestErrors {
+		mr.manifest.Errors = append(mr.manifest.Errors, err.Error())
+	}
+}
+
+// RecordOutput records a file written by the run
+func (mr *ManifestRecorder) RecordOutput(path string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.manifest.Outputs = append(mr.manifest.Outputs, path)
+}
+
+// Finish stamps the end of the run and returns the completed manifest
+func (mr *ManifestRecorder) Finish() Manifest {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.manifest.FinishedAt = mr.clock.Now()
+	duration := mr.manifest.FinishedAt.Sub(mr.manifest.StartedAt).Seconds()
+	mr.manifest.DurationSeconds = duration
+	if duration > 0 {
+		mr.manifest.SpectraPerSec = float64(mr.manifest.SpectraProduced) / duration
+	}
+
+	manifest := mr.manifest
+	manifest.Inputs = append([]InputFile{}, mr.manifest.Inputs...)
+	manifest.Errors = append([]string(nil), mr.manifest.Errors...)
+	manifest.Outputs = append([]string{}, mr.manifest.Outputs...)
+	return manifest
+}
+
+// WriteManifest writes manifest as indented JSON to path, creating its directory
+func WriteManifest(path string, manifest Manifest) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return config.NewProcessingError("manifest writing", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return config.NewProcessingError("JSON marshaling", config.ErrJSONMarshalFailed)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return config.NewProcessingError("manifest writing", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+)
+
+func TestManifestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "voltage.csv")
+	if err := os.WriteFile(input, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	simulated := clock.NewSimulatedClock(time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC))
+	recorder := NewManifestRecorder("file", map[string]string{"circuit_type": "simple"}, simulated)
+	if err := recorder.AddInput(input); err != nil {
+		t.Fatalf("AddInput() error = %v", err)
+	}
+	if err := recorder.AddInput(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Errorf("expected error hashing a missing input")
+	}
+
+	recorder.RecordSpectrum(2 * time.Millisecond)
+	recorder.RecordSpectrum(4 * time.Millisecond)
+	recorder.RecordSpectrum(0) // Read, not computed
+	recorder.RecordError(errors.New("send failed"))
+	recorder.RecordOutput("output/json/a.json")
+	simulated.Advance(10 * time.Second)
+
+	manifest := recorder.Finish()
+	if manifest.SpectraProduced != 3 || manifest.ErrorCount != 1 || len(manifest.Outputs) != 1 {
+		t.Errorf("counts = %d spectra, %d errors, %d outputs, want 3, 1, 1",
+			manifest.SpectraProduced, manifest.ErrorCount, len(manifest.Outputs))
+	}
+	if got := manifest.Processing; got.Count != 2 || got.MinMs != 2 || got.MeanMs != 3 || got.MaxMs != 4 {
+		t.Errorf("processing = %+v, want 2 timed spectra of 2..4 ms, mean 3", got)
+	}
+	if manifest.DurationSeconds != 10 || manifest.SpectraPerSec != 0.3 {
+		t.Errorf("duration = %vs at %v/s, want 10s at 0.3/s", manifest.DurationSeconds, manifest.SpectraPerSec)
+	}
+	// SHA-256 of "abc"
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; len(manifest.Inputs) != 1 || manifest.Inputs[0].SHA256 != want || manifest.Inputs[0].Size != 3 {
+		t.Errorf("inputs = %+v, want one 3-byte file hashed to %s", manifest.Inputs, want)
+	}
+
+	path := filepath.Join(dir, "runs", "manifest.json")
+	if err := WriteManifest(path, manifest); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if decoded["mode"] != "file" || decoded["config"] == nil {
+		t.Errorf("decoded manifest = %v", decoded)
+	}
+}
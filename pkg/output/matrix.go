@@ -0,0 +1,97 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math/cmplx"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// matrixKeyColumns is the number of columns before the impedance, see Columns
+const matrixKeyColumns = 3
+
+// ImpedanceMatrix is a sequence of spectra on a shared frequency grid, the
+// Z(f, t) trajectory of dynamic EIS. Row i holds the spectrum taken at
+// Times[i]; column j the frequency Frequencies[j].
+type ImpedanceMatrix struct {
+	Spectra      []int                 // Iteration of each row
+	Times        []time.Time           // Timestamp of each row
+	QualityFlags []signal.QualityFlags // Quality flags of each row
+	Frequencies  []float64
+	Impedance    [][]complex128
+	Attributes   []MetadataField // Describing the whole matrix, e.g. circuit and device
+	Format       CSVFormat       // Of WriteCSV; values default to the shortest exact representation
+}
+
+// Add appends a spectrum as a row. The first spectrum sets the frequency
+// grid; spectra on other grids are interpolated onto it, see
+// signal.InterpolateImpedance, and are NaN at grid frequencies beyond their
+// own range rather than extrapolated.
+func (m *ImpedanceMatrix) Add(spectrum signal.ImpedanceDataWithIteration) error {
+	data := spectrum.ImpedanceData
+	if len(data.Frequencies) != len(data.Impedance) || len(data.Impedance) == 0 {
+		return config.NewValidationError("Frequencies", fmt.Sprintf("spectrum %d has %d frequencies for %d impedance values", spectrum.Iteration, len(data.Frequencies), len(data.Impedance)))
+	}
+	row := data.Impedance
+	if m.Frequencies == nil {
+		m.Frequencies = slices.Clone(data.Frequencies)
+	} else if !slices.Equal(m.Frequencies, data.Frequencies) {
+		row = signal.InterpolateImpedance(data.Frequencies, data.Impedance, m.Frequencies)
+		lowest, highest := slices.Min(data.Frequencies), slices.Max(data.Frequencies)
+		for j, f := range m.Frequencies {
+			if f < lowest || f > highest {
+				row[j] = cmplx.NaN()
+			}
+		}
+	}
+	m.Spectra = append(m.Spectra, spectrum.Iteration)
+	m.Times = append(m.Times, data.Timestamp)
+	m.QualityFlags = append(m.QualityFlags, data.QualityFlags)
+	m.Impedance = append(m.Impedance, slices.Clone(row))
+	return nil
+}
+
+// Columns names the columns of the wide table written by WriteCSV and
+// WriteParquet: spectrum, time, quality_flags, then re_<f> and im_<f> per
+// frequency in Hz
+func (m *ImpedanceMatrix) Columns() []string {
+	columns := []string{"spectrum", "time", "quality_flags"}
+	for _, part := range []string{"re_", "im_"} {
+		for _, f := range m.Frequencies {
+			columns = append(columns, part+strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return columns
+}
+
+// WriteCSV writes the matrix with one row per spectrum, see Columns, below
+// the attributes as a comment block
+func (m *ImpedanceMatrix) WriteCSV(w io.Writer) error {
+	if _, err := CSVMetadata(m.Attributes).WriteTo(w); err != nil {
+		return err
+	}
+	writer := m.Format.NewWriter(w)
+	if err := writer.Write(m.Columns()); err != nil {
+		return err
+	}
+	record := make([]string, matrixKeyColumns+2*len(m.Frequencies))
+	for i, row := range m.Impedance {
+		record[0] = strconv.Itoa(m.Spectra[i])
+		record[1] = m.Times[i].UTC().Format(time.RFC3339Nano)
+		record[2] = strconv.FormatUint(uint64(m.QualityFlags[i]), 10)
+		for j, z := range row {
+			record[matrixKeyColumns+j] = m.Format.Float(real(z), 'g', -1)
+			record[matrixKeyColumns+len(row)+j] = m.Format.Float(imag(z), 'g', -1)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
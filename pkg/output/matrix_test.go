@@ -0,0 +1,211 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// thriftStructValue is a decoded Thrift compact struct: field id to int64,
+// string, []any or thriftStructValue
+type thriftStructValue map[int16]any
+
+// thriftReader decodes the Thrift compact protocol subset WriteParquet uses
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (tr *thriftReader) varint() int64 {
+	v, n := binary.Varint(tr.buf[tr.pos:])
+	tr.pos += n
+	return v
+}
+
+func (tr *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return tr.varint()
+	case thriftBinary:
+		n, k := binary.Uvarint(tr.buf[tr.pos:])
+		tr.pos += k
+		s := string(tr.buf[tr.pos : tr.pos+int(n)])
+		tr.pos += int(n)
+		return s
+	case thriftList:
+		header := tr.buf[tr.pos]
+		tr.pos++
+		n := int(header >> 4)
+		if n == 15 {
+			size, k := binary.Uvarint(tr.buf[tr.pos:])
+			tr.pos += k
+			n = int(size)
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = tr.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		fields := thriftStructValue{}
+		var id int16
+		for {
+			header := tr.buf[tr.pos]
+			tr.pos++
+			if header == 0 {
+				return fields
+			}
+			if delta := header >> 4; delta != 0 {
+				id += int16(delta)
+			} else {
+				id = int16(tr.varint())
+			}
+			fields[id] = tr.value(header & 0x0f)
+		}
+	}
+	panic("unsupported thrift type")
+}
+
+func TestImpedanceMatrix_Add(t *testing.T) {
+	var matrix ImpedanceMatrix
+	start := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	matrix.Add(signal.ImpedanceDataWithIteration{Iteration: 1, ImpedanceData: signal.ImpedanceData{
+		Timestamp: start, Frequencies: []float64{10, 100}, Impedance: []complex128{complex(20, -5), complex(10, -2)},
+	}})
+	// A spectrum on a different grid is interpolated onto the first one
+	matrix.Add(signal.ImpedanceDataWithIteration{Iteration: 2, ImpedanceData: signal.ImpedanceData{
+		Timestamp: start.Add(time.Second), Frequencies: []float64{1, 100, 1000}, Impedance: []complex128{complex(30, 0), complex(12, -4), complex(8, 0)},
+		QualityFlags: signal.FlagClipped | signal.FlagLowSNR,
+	}})
+	// A spectrum not reaching 100 Hz is not extrapolated there
+	matrix.Add(signal.ImpedanceDataWithIteration{Iteration: 3, ImpedanceData: signal.ImpedanceData{
+		Timestamp: start.Add(2 * time.Second), Frequencies: []float64{10, 50}, Impedance: []complex128{complex(25, -6), complex(15, -3)},
+	}})
+	if err := matrix.Add(signal.ImpedanceDataWithIteration{Iteration: 4}); err == nil {
+		t.Error("Add() accepted an empty spectrum")
+	}
+
+	if len(matrix.Impedance) != 3 || len(matrix.Impedance[1]) != 2 {
+		t.Fatalf("matrix has %d rows, want 3 rows of 2 frequencies", len(matrix.Impedance))
+	}
+	if z := matrix.Impedance[2]; cmplx.IsNaN(z[0]) || !cmplx.IsNaN(z[1]) {
+		t.Errorf("row of the 10-50 Hz spectrum = %v, want Z(10 Hz) and NaN at 100 Hz", z)
+	}
+	// 10 Hz is halfway between 1 and 100 Hz in log frequency
+	if got := matrix.Impedance[1][0]; got != complex(21, -2) {
+		t.Errorf("interpolated Z(10 Hz) = %v, want (21-2i)", got)
+	}
+	if got := matrix.Impedance[1][1]; got != complex(12, -4) {
+		t.Errorf("Z(100 Hz) = %v, want (12-4i)", got)
+	}
+	want := []string{"spectrum", "time", "quality_flags", "re_10", "re_100", "im_10", "im_100"}
+	if got := matrix.Columns(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+
+	var csvOutput bytes.Buffer
+	if err := matrix.WriteCSV(&csvOutput); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	wantCSV := "spectrum,time,quality_flags,re_10,re_100,im_10,im_100\n" +
+		"1,2024-05-15T10:00:00Z,0,20,10,-5,-2\n" +
+		"2,2024-05-15T10:00:01Z,18,21,12,-2,-4\n" +
+		"3,2024-05-15T10:00:02Z,0,25,NaN,-6,NaN\n"
+	if csvOutput.String() != wantCSV {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", csvOutput.String(), wantCSV)
+	}
+}
+
+func TestImpedanceMatrix_WriteParquet(t *testing.T) {
+	matrix := ImpedanceMatrix{Attributes: []MetadataField{{"circuit", "R(CR)"}}}
+	start := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	frequencies := make([]float64, 20) // More columns than fit a short list header
+	for i := range frequencies {
+		frequencies[i] = float64(i + 1)
+	}
+	for row := range 3 {
+		impedance := make([]complex128, len(frequencies))
+		for i := range impedance {
+			impedance[i] = complex(float64(100*row+i), -float64(i))
+		}
+		matrix.Add(signal.ImpedanceDataWithIteration{Iteration: row + 1, ImpedanceData: signal.ImpedanceData{
+			Timestamp: start.Add(time.Duration(row) * time.Second), Frequencies: frequencies, Impedance: impedance,
+			QualityFlags: signal.QualityFlags(row) * signal.FlagNonlinearity,
+		}})
+	}
+
+	var buf bytes.Buffer
+	if err := matrix.WriteParquet(&buf); err != nil {
+		t.Fatalf("WriteParquet() error = %v", err)
+	}
+	file := buf.Bytes()
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("file does not start and end with PAR1")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	reader := &thriftReader{buf: file[len(file)-8-footerLength : len(file)-8]}
+	metadata := reader.value(thriftStruct).(thriftStructValue)
+	if reader.pos != footerLength {
+		t.Errorf("footer decoded %d of %d bytes", reader.pos, footerLength)
+	}
+	if metadata[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", metadata[3])
+	}
+	if kv := metadata[5].([]any)[0].(thriftStructValue); kv[1] != "circuit" || kv[2] != "R(CR)" {
+		t.Errorf("key-value metadata = %v, want circuit R(CR)", kv)
+	}
+
+	columns := matrix.Columns()
+	schema := metadata[2].([]any)
+	if len(schema) != len(columns)+1 || schema[0].(thriftStructValue)[5] != int64(len(columns)) {
+		t.Fatalf("schema has %d elements, want a root with %d children", len(schema), len(columns))
+	}
+	if schema[2].(thriftStructValue)[6] != int64(parquetTimestampMicros) {
+		t.Error("time column is not TIMESTAMP_MICROS")
+	}
+
+	chunks := metadata[4].([]any)[0].(thriftStructValue)[1].([]any)
+	readColumn := func(name string) []uint64 {
+		for i, element := range schema[1:] {
+			if element.(thriftStructValue)[4] != name {
+				continue
+			}
+			meta := chunks[i].(thriftStructValue)[3].(thriftStructValue)
+			if meta[3].([]any)[0] != name {
+				t.Errorf("column chunk %d path = %v, want %s", i, meta[3], name)
+			}
+			page := &thriftReader{buf: file, pos: int(meta[9].(int64))}
+			header := page.value(thriftStruct).(thriftStructValue)
+			values := file[page.pos : page.pos+int(header[3].(int64))]
+			result := make([]uint64, len(values)/8)
+			for j := range result {
+				result[j] = binary.LittleEndian.Uint64(values[8*j:])
+			}
+			return result
+		}
+		t.Fatalf("no column %s", name)
+		return nil
+	}
+
+	if got := readColumn("spectrum"); got[0] != 1 || got[2] != 3 {
+		t.Errorf("spectrum column = %v, want [1 2 3]", got)
+	}
+	if got := readColumn("time"); int64(got[1]) != start.Add(time.Second).UnixMicro() {
+		t.Errorf("time column = %v", got)
+	}
+	if got := readColumn("quality_flags"); got[1] != uint64(signal.FlagNonlinearity) {
+		t.Errorf("quality_flags column = %v, want nonlinearity in row 2", got)
+	}
+	if got := readColumn("re_20"); math.Float64frombits(got[2]) != 219 {
+		t.Errorf("re_20 of row 3 = %v, want 219", math.Float64frombits(got[2]))
+	}
+	if got := readColumn("im_5"); math.Float64frombits(got[0]) != -4 {
+		t.Errorf("im_5 of row 1 = %v, want -4", math.Float64frombits(got[0]))
+	}
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"sync"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// MemorySink keeps spectra and raw chunks in memory, for tests and for
+// applications embedding the pipeline that consume results in process. It is
+// safe for concurrent use.
+type MemorySink struct {
+	mu        sync.Mutex
+	spectra   []signal.ImpedanceData
+	rawChunks []signal.RawChunk
+}
+
+// NewMemorySink creates a sink keeping everything written to it
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// WriteSpectrum stores a spectrum
+func (ms *MemorySink) WriteSpectrum(data signal.ImpedanceData) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.spectra = append(ms.spectra, data)
+	return nil
+}
+
+// Write stores the spectrum of a run, making MemorySink an output Sink
+func (ms *MemorySink) Write(data signal.ImpedanceDataWithIteration) error {
+	return ms.WriteSpectrum(data.ImpedanceData)
+}
+
+// Flush does nothing, spectra are stored as they are written
+func (ms *MemorySink) Flush() error {
+	return nil
+}
+
+// Close does nothing, the stored spectra stay available
+func (ms *MemorySink) Close() error {
+	return nil
+}
+
+// WriteRawChunk stores a raw chunk
+func (ms *MemorySink) WriteRawChunk(chunk signal.RawChunk) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.rawChunks = append(ms.rawChunks, chunk)
+	return nil
+}
+
+// Spectra returns a copy of the stored spectra, oldest first
+func (ms *MemorySink) Spectra() []signal.ImpedanceData {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]signal.ImpedanceData(nil), ms.spectra...)
+}
+
+// RawChunks returns a copy of the stored raw chunks, oldest first
+func (ms *MemorySink) RawChunks() []signal.RawChunk {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]signal.RawChunk(nil), ms.rawChunks...)
+}
+
+// Reset discards everything stored
+func (ms *MemorySink) Reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.spectra, ms.rawChunks = nil, nil
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	var rawSink RawChunkSink = sink
+	var outputSink Sink = sink
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sink.WriteSpectrum(signal.ImpedanceData{Frequencies: []float64{1}})
+			rawSink.WriteRawChunk(signal.RawChunk{})
+			outputSink.Write(signal.ImpedanceDataWithIteration{ImpedanceData: signal.ImpedanceData{Frequencies: []float64{2}}})
+		}()
+	}
+	wg.Wait()
+
+	if got := len(sink.Spectra()); got != 20 {
+		t.Errorf("Spectra() returned %d spectra, want 20", got)
+	}
+	if got := len(sink.RawChunks()); got != 10 {
+		t.Errorf("RawChunks() returned %d chunks, want 10", got)
+	}
+
+	sink.Reset()
+	if len(sink.Spectra()) != 0 || len(sink.RawChunks()) != 0 {
+		t.Error("Reset() kept data")
+	}
+}
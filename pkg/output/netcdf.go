@@ -0,0 +1,193 @@
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// NetCDF classic format tags and types, see the NetCDF file format specification
+const (
+	netCDFMagic     = "CDF\x02" // 64-bit offset format, variables beyond 2 GiB
+	netCDFDimension = 0x0a
+	netCDFVariable  = 0x0b
+	netCDFAttribute = 0x0c
+	netCDFChar      = 2
+	netCDFInt       = 4
+	netCDFDouble    = 6
+)
+
+// netCDFVar is one variable of the file with its data in big-endian order
+type netCDFVar struct {
+	name       string
+	dimensions []int32
+	attributes []MetadataField
+	flagMasks  []int32 // CF flag_masks attribute, written after the text attributes
+	typ        int32
+	data       []byte
+}
+
+// WriteNetCDF writes the matrix as a NetCDF file (64-bit offset classic
+// format) with the dimensions time and frequency, as opened by
+// xarray.open_dataset: coordinates frequency (Hz) and time (CF seconds since
+// the first spectrum), the spectrum iteration and the CF flag variable
+// quality_flags along time and the impedance parts z_real and z_imag (Ω) over
+// (time, frequency). Attributes become global attributes. An empty matrix is
+// rejected: a zero length dimension would be read as the unlimited one.
+func (m *ImpedanceMatrix) WriteNetCDF(w io.Writer) error {
+	rows, columns := len(m.Impedance), len(m.Frequencies)
+	if rows == 0 || columns == 0 {
+		return config.NewValidationError("ImpedanceMatrix", fmt.Sprintf("NetCDF needs at least one spectrum and frequency, got %d × %d", rows, columns))
+	}
+	origin := m.Times[0].UTC()
+
+	frequency := netCDFVar{name: "frequency", dimensions: []int32{1}, typ: netCDFDouble, attributes: []MetadataField{
+		{"standard_name", "frequency"}, {"long_name", "Excitation frequency"}, {"units", "Hz"},
+	}}
+	for _, f := range m.Frequencies {
+		frequency.data = binary.BigEndian.AppendUint64(frequency.data, math.Float64bits(f))
+	}
+	timeVar := netCDFVar{name: "time", dimensions: []int32{0}, typ: netCDFDouble, attributes: []MetadataField{
+		{"standard_name", "time"}, {"units", "seconds since " + origin.Format("2006-01-02 15:04:05.999999999")}, {"calendar", "proleptic_gregorian"},
+	}}
+	spectrum := netCDFVar{name: "spectrum", dimensions: []int32{0}, typ: netCDFInt, attributes: []MetadataField{
+		{"long_name", "Spectrum number"},
+	}}
+	flagNames := signal.AllQualityFlags.Names()
+	flags := netCDFVar{name: "quality_flags", dimensions: []int32{0}, typ: netCDFInt, attributes: []MetadataField{
+		{"long_name", "Quality flags"}, {"flag_meanings", strings.Join(flagNames, " ")},
+	}}
+	for i := range flagNames {
+		flags.flagMasks = append(flags.flagMasks, 1<<i)
+	}
+	for i, t := range m.Times {
+		timeVar.data = binary.BigEndian.AppendUint64(timeVar.data, math.Float64bits(t.Sub(origin).Seconds()))
+		spectrum.data = binary.BigEndian.AppendUint32(spectrum.data, uint32(int32(m.Spectra[i])))
+		flags.data = binary.BigEndian.AppendUint32(flags.data, uint32(m.QualityFlags[i]))
+	}
+	zReal := netCDFVar{name: "z_real", dimensions: []int32{0, 1}, typ: netCDFDouble, attributes: []MetadataField{
+		{"long_name", "Real part of the impedance"}, {"units", "ohm"},
+	}}
+	zImag := netCDFVar{name: "z_imag", dimensions: []int32{0, 1}, typ: netCDFDouble, attributes: []MetadataField{
+		{"long_name", "Imaginary part of the impedance"}, {"units", "ohm"},
+	}}
+	zReal.data = make([]byte, 0, 8*rows*columns)
+	zImag.data = make([]byte, 0, 8*rows*columns)
+	for _, row := range m.Impedance {
+		for _, z := range row {
+			zReal.data = binary.BigEndian.AppendUint64(zReal.data, math.Float64bits(real(z)))
+			zImag.data = binary.BigEndian.AppendUint64(zImag.data, math.Float64bits(imag(z)))
+		}
+	}
+	variables := []netCDFVar{timeVar, frequency, spectrum, flags, zReal, zImag}
+	for _, v := range variables {
+		if len(v.data) > math.MaxUint32-3 {
+			return config.NewValidationError("ImpedanceMatrix", "variable too large for the NetCDF classic format")
+		}
+	}
+
+	global := append([]MetadataField{
+		{"Conventions", "CF-1.8"},
+		{"title", "Impedance trajectory Z(f, t)"},
+		{"source", "masterapp " + BuildVersion()},
+	}, m.Attributes...)
+
+	// The header length does not depend on the data offsets, so it is built
+	// once with zero offsets to place the data behind it
+	header := netCDFHeader(rows, columns, global, variables, nil)
+	offsets := make([]int64, len(variables))
+	offset := int64(len(header))
+	for i, v := range variables {
+		offsets[i] = offset
+		offset += int64(netCDFPadded(len(v.data)))
+	}
+	file := netCDFHeader(rows, columns, global, variables, offsets)
+	for _, v := range variables {
+		file = append(file, v.data...)
+		file = append(file, make([]byte, netCDFPadded(len(v.data))-len(v.data))...)
+	}
+	if _, err := w.Write(file); err != nil {
+		return config.NewProcessingError("NetCDF writing", err)
+	}
+	return nil
+}
+
+// netCDFHeader encodes the dimensions, global attributes and variables with
+// the given data offsets (zero if nil)
+func netCDFHeader(rows, columns int, global []MetadataField, variables []netCDFVar, offsets []int64) []byte {
+	buf := []byte(netCDFMagic)
+	buf = binary.BigEndian.AppendUint32(buf, 0) // No record variables
+
+	buf = binary.BigEndian.AppendUint32(buf, netCDFDimension)
+	buf = binary.BigEndian.AppendUint32(buf, 2)
+	buf = netCDFName(buf, "time")
+	buf = binary.BigEndian.AppendUint32(buf, uint32(rows))
+	buf = netCDFName(buf, "frequency")
+	buf = binary.BigEndian.AppendUint32(buf, uint32(columns))
+
+	buf = netCDFAttributes(buf, global, nil)
+
+	buf = binary.BigEndian.AppendUint32(buf, netCDFVariable)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(variables)))
+	for i, v := range variables {
+		buf = netCDFName(buf, v.name)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(v.dimensions)))
+		for _, dimension := range v.dimensions {
+			buf = binary.BigEndian.AppendUint32(buf, uint32(dimension))
+		}
+		buf = netCDFAttributes(buf, v.attributes, v.flagMasks)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(v.typ))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(netCDFPadded(len(v.data))))
+		var begin int64
+		if offsets != nil {
+			begin = offsets[i]
+		}
+		buf = binary.BigEndian.AppendUint64(buf, uint64(begin))
+	}
+	return buf
+}
+
+// netCDFAttributes encodes text attributes followed by flag_masks if any
+// masks are given, or an absent list
+func netCDFAttributes(buf []byte, attributes []MetadataField, flagMasks []int32) []byte {
+	count := len(attributes)
+	if len(flagMasks) > 0 {
+		count++
+	}
+	if count == 0 {
+		return append(buf, make([]byte, 8)...)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, netCDFAttribute)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(count))
+	for _, attribute := range attributes {
+		buf = netCDFName(buf, attribute.Key)
+		buf = binary.BigEndian.AppendUint32(buf, netCDFChar)
+		buf = netCDFName(buf, attribute.Value)
+	}
+	if len(flagMasks) > 0 {
+		buf = netCDFName(buf, "flag_masks")
+		buf = binary.BigEndian.AppendUint32(buf, netCDFInt)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(flagMasks)))
+		for _, mask := range flagMasks {
+			buf = binary.BigEndian.AppendUint32(buf, uint32(mask))
+		}
+	}
+	return buf
+}
+
+// netCDFName encodes a length prefixed string padded to 4 bytes
+func netCDFName(buf []byte, name string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(name)))
+	buf = append(buf, name...)
+	return append(buf, make([]byte, netCDFPadded(len(name))-len(name))...)
+}
+
+// netCDFPadded rounds n up to a multiple of 4
+func netCDFPadded(n int) int {
+	return (n + 3) &^ 3
+}
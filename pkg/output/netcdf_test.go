@@ -0,0 +1,167 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// netCDFReader reads the header of the files WriteNetCDF writes
+type netCDFReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *netCDFReader) u32() int {
+	v := binary.BigEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return int(v)
+}
+
+func (r *netCDFReader) name() string {
+	n := r.u32()
+	s := string(r.buf[r.pos : r.pos+n])
+	r.pos += netCDFPadded(n)
+	return s
+}
+
+func (r *netCDFReader) attributes() map[string]string {
+	tag, n := r.u32(), r.u32()
+	attributes := map[string]string{}
+	if tag == 0 {
+		return attributes
+	}
+	for range n {
+		key := r.name()
+		if r.u32() == netCDFChar {
+			attributes[key] = r.name()
+			continue
+		}
+		// NC_INT values are rendered space separated
+		var values []string
+		for range r.u32() {
+			values = append(values, strconv.Itoa(r.u32()))
+		}
+		attributes[key] = strings.Join(values, " ")
+	}
+	return attributes
+}
+
+func TestImpedanceMatrix_WriteNetCDF(t *testing.T) {
+	start := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	matrix := ImpedanceMatrix{Attributes: []MetadataField{{"circuit", "R(CR)"}, {"device_serial", "SN-42"}}}
+	for row := range 3 {
+		matrix.Add(signal.ImpedanceDataWithIteration{Iteration: row + 1, ImpedanceData: signal.ImpedanceData{
+			Timestamp:    start.Add(time.Duration(row) * 1500 * time.Millisecond),
+			Frequencies:  []float64{1000, 100},
+			Impedance:    []complex128{complex(10+float64(row), -1), complex(20, -5-float64(row))},
+			QualityFlags: signal.QualityFlags(row) * signal.FlagLowSNR,
+		}})
+	}
+
+	var buf bytes.Buffer
+	if err := matrix.WriteNetCDF(&buf); err != nil {
+		t.Fatalf("WriteNetCDF() error = %v", err)
+	}
+	r := &netCDFReader{buf: buf.Bytes()}
+	if string(r.buf[:4]) != netCDFMagic {
+		t.Fatalf("magic = %q, want %q", r.buf[:4], netCDFMagic)
+	}
+	r.pos = 4
+	r.u32() // numrecs
+
+	if tag, n := r.u32(), r.u32(); tag != netCDFDimension || n != 2 {
+		t.Fatalf("dimension list tag %x with %d entries, want 2 dimensions", tag, n)
+	}
+	dimensions := map[string]int{}
+	for range 2 {
+		name := r.name()
+		dimensions[name] = r.u32()
+	}
+	if dimensions["time"] != 3 || dimensions["frequency"] != 2 {
+		t.Errorf("dimensions = %v, want time 3 and frequency 2", dimensions)
+	}
+
+	global := r.attributes()
+	if global["circuit"] != "R(CR)" || global["device_serial"] != "SN-42" || global["Conventions"] != "CF-1.8" {
+		t.Errorf("global attributes = %v", global)
+	}
+
+	if tag := r.u32(); tag != netCDFVariable {
+		t.Fatalf("variable list tag %x", tag)
+	}
+	type variable struct {
+		dimensions []int
+		attributes map[string]string
+		typ        int
+		size       int
+		begin      int
+	}
+	variables := map[string]variable{}
+	for range r.u32() {
+		name := r.name()
+		var v variable
+		for range r.u32() {
+			v.dimensions = append(v.dimensions, r.u32())
+		}
+		v.attributes = r.attributes()
+		v.typ, v.size = r.u32(), r.u32()
+		v.begin = int(binary.BigEndian.Uint64(r.buf[r.pos:]))
+		r.pos += 8
+		variables[name] = v
+	}
+	double := func(name string, i int) float64 {
+		v := variables[name]
+		return math.Float64frombits(binary.BigEndian.Uint64(r.buf[v.begin+8*i:]))
+	}
+
+	if v := variables["z_imag"]; len(v.dimensions) != 2 || v.dimensions[0] != 0 || v.dimensions[1] != 1 || v.attributes["units"] != "ohm" || v.size != 48 {
+		t.Errorf("z_imag = %+v, want (time, frequency) doubles in ohm", v)
+	}
+	if got := double("frequency", 1); got != 100 {
+		t.Errorf("frequency[1] = %v, want 100", got)
+	}
+	if got := double("time", 2); got != 3 {
+		t.Errorf("time[2] = %v, want 3 seconds", got)
+	}
+	if units := variables["time"].attributes["units"]; units != "seconds since 2024-05-15 10:00:00" {
+		t.Errorf("time units = %q", units)
+	}
+	// Row 2, frequency 100 Hz
+	if got := double("z_imag", 2*2+1); got != -7 {
+		t.Errorf("z_imag[2, 1] = %v, want -7", got)
+	}
+	if got := double("z_real", 1*2+0); got != 11 {
+		t.Errorf("z_real[1, 0] = %v, want 11", got)
+	}
+	spectrum := variables["spectrum"]
+	if got := binary.BigEndian.Uint32(r.buf[spectrum.begin+8:]); got != 3 {
+		t.Errorf("spectrum[2] = %d, want 3", got)
+	}
+	flags := variables["quality_flags"]
+	if flags.attributes["flag_masks"] != "1 2 4 8 16 32 64 128" || !strings.HasPrefix(flags.attributes["flag_meanings"], "kk_fail low_snr ") {
+		t.Errorf("quality_flags attributes = %v, want CF flag masks and meanings", flags.attributes)
+	}
+	if got := binary.BigEndian.Uint32(r.buf[flags.begin+8:]); got != 4 {
+		t.Errorf("quality_flags[2] = %d, want 4", got)
+	}
+	if last := variables["z_imag"]; last.begin+last.size != len(r.buf) {
+		t.Errorf("file has %d bytes, last variable ends at %d", len(r.buf), last.begin+last.size)
+	}
+}
+
+func TestImpedanceMatrix_WriteNetCDFRejectsEmptyMatrix(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&ImpedanceMatrix{}).WriteNetCDF(&buf); err == nil {
+		t.Error("WriteNetCDF() accepted an empty matrix")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("WriteNetCDF() wrote %d bytes for an empty matrix", buf.Len())
+	}
+}
@@ -0,0 +1,243 @@
+package output
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// parquetMagic opens and closes every Parquet file
+const parquetMagic = "PAR1"
+
+// Parquet enum values, see parquet.thrift
+const (
+	parquetTypeInt64         = 2
+	parquetTypeDouble        = 5
+	parquetRequired          = 0
+	parquetTimestampMicros   = 10
+	parquetEncodingPlain     = 0
+	parquetEncodingRLE       = 3
+	parquetCodecUncompressed = 0
+	parquetPageData          = 0
+)
+
+// Thrift compact protocol type codes
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetColumn is one required, plain encoded column of 8-byte values
+type parquetColumn struct {
+	name          string
+	physical      int32
+	converted     int32 // 0 = none
+	values        []byte
+	rows          int
+	dataOffset    int64
+	chunkByteSize int64
+}
+
+// WriteParquet writes the matrix as a Parquet file with a single row group,
+// see Columns: spectrum and quality_flags are INT64, time INT64
+// TIMESTAMP_MICROS (UTC) and the impedance parts DOUBLE, all required, plain encoded and uncompressed, as
+// read by pyarrow.parquet.read_table or pandas.read_parquet. Attributes
+// become key-value metadata of the file.
+func (m *ImpedanceMatrix) WriteParquet(w io.Writer) error {
+	names := m.Columns()
+	columns := make([]parquetColumn, len(names))
+	for i, name := range names {
+		columns[i] = parquetColumn{name: name, physical: parquetTypeDouble, rows: len(m.Impedance)}
+	}
+	columns[0].physical = parquetTypeInt64
+	columns[1].physical = parquetTypeInt64
+	columns[1].converted = parquetTimestampMicros
+	columns[2].physical = parquetTypeInt64
+	for i, row := range m.Impedance {
+		columns[0].values = binary.LittleEndian.AppendUint64(columns[0].values, uint64(m.Spectra[i]))
+		columns[1].values = binary.LittleEndian.AppendUint64(columns[1].values, uint64(m.Times[i].UnixMicro()))
+		columns[2].values = binary.LittleEndian.AppendUint64(columns[2].values, uint64(m.QualityFlags[i]))
+		for j, z := range row {
+			re, im := &columns[matrixKeyColumns+j], &columns[matrixKeyColumns+len(row)+j]
+			re.values = binary.LittleEndian.AppendUint64(re.values, math.Float64bits(real(z)))
+			im.values = binary.LittleEndian.AppendUint64(im.values, math.Float64bits(imag(z)))
+		}
+	}
+
+	file := []byte(parquetMagic)
+	for i := range columns {
+		column := &columns[i]
+		if len(column.values) > math.MaxInt32 {
+			return config.NewValidationError("ImpedanceMatrix", "column too large for a single Parquet page")
+		}
+		// A required column without nesting has no repetition or
+		// definition levels, the page holds the values only
+		var header thriftWriter
+		header.i32(1, parquetPageData)
+		header.i32(2, int32(len(column.values)))
+		header.i32(3, int32(len(column.values)))
+		header.beginStruct(5)
+		header.i32(1, int32(column.rows))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.endStruct()
+		header.stop()
+
+		column.dataOffset = int64(len(file))
+		file = append(file, header.buf...)
+		file = append(file, column.values...)
+		column.chunkByteSize = int64(len(header.buf) + len(column.values))
+	}
+
+	var footer thriftWriter
+	footer.i32(1, 1) // Version
+	footer.beginList(2, thriftStruct, len(columns)+1)
+	footer.binary(4, "schema")
+	footer.i32(5, int32(len(columns)))
+	footer.stop()
+	for _, column := range columns {
+		footer.i32(1, column.physical)
+		footer.i32(3, parquetRequired)
+		footer.binary(4, column.name)
+		if column.converted != 0 {
+			footer.i32(6, column.converted)
+		}
+		footer.stop()
+	}
+	footer.endList()
+	footer.i64(3, int64(len(m.Impedance)))
+	footer.beginList(4, thriftStruct, 1)
+	var total int64
+	footer.beginList(1, thriftStruct, len(columns))
+	for _, column := range columns {
+		footer.i64(2, column.dataOffset)
+		footer.beginStruct(3)
+		footer.i32(1, column.physical)
+		footer.beginList(2, thriftI32, 1)
+		footer.listI32(parquetEncodingPlain)
+		footer.endList()
+		footer.beginList(3, thriftBinary, 1)
+		footer.listBinary(column.name)
+		footer.endList()
+		footer.i32(4, parquetCodecUncompressed)
+		footer.i64(5, int64(column.rows))
+		footer.i64(6, column.chunkByteSize)
+		footer.i64(7, column.chunkByteSize)
+		footer.i64(9, column.dataOffset)
+		footer.endStruct()
+		footer.stop()
+		total += column.chunkByteSize
+	}
+	footer.endList()
+	footer.i64(2, total)
+	footer.i64(3, int64(len(m.Impedance)))
+	footer.stop()
+	footer.endList()
+	if len(m.Attributes) > 0 {
+		footer.beginList(5, thriftStruct, len(m.Attributes))
+		for _, attribute := range m.Attributes {
+			footer.binary(1, attribute.Key)
+			footer.binary(2, attribute.Value)
+			footer.stop()
+		}
+		footer.endList()
+	}
+	footer.binary(6, "masterapp "+BuildVersion())
+	footer.stop()
+
+	file = append(file, footer.buf...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(footer.buf)))
+	file = append(file, parquetMagic...)
+	if _, err := w.Write(file); err != nil {
+		return config.NewProcessingError("Parquet writing", err)
+	}
+	return nil
+}
+
+// thriftWriter encodes structs in the Thrift compact protocol used by the
+// Parquet metadata. Fields must be written in ascending id order and every
+// struct, including those in lists, ends with stop.
+type thriftWriter struct {
+	buf     []byte
+	lastID  int16
+	enclose []int16 // Last field ids of the enclosing structs
+}
+
+func (tw *thriftWriter) field(id int16, typ byte) {
+	if delta := id - tw.lastID; delta > 0 && delta <= 15 {
+		tw.buf = append(tw.buf, byte(delta)<<4|typ)
+	} else {
+		tw.buf = append(tw.buf, typ)
+		tw.buf = binary.AppendVarint(tw.buf, int64(id))
+	}
+	tw.lastID = id
+}
+
+func (tw *thriftWriter) i32(id int16, v int32) {
+	tw.field(id, thriftI32)
+	tw.buf = binary.AppendVarint(tw.buf, int64(v))
+}
+
+func (tw *thriftWriter) i64(id int16, v int64) {
+	tw.field(id, thriftI64)
+	tw.buf = binary.AppendVarint(tw.buf, v)
+}
+
+func (tw *thriftWriter) binary(id int16, v string) {
+	tw.field(id, thriftBinary)
+	tw.listBinary(v)
+}
+
+// beginStruct opens a struct field, closed by endStruct
+func (tw *thriftWriter) beginStruct(id int16) {
+	tw.field(id, thriftStruct)
+	tw.enclose = append(tw.enclose, tw.lastID)
+	tw.lastID = 0
+}
+
+func (tw *thriftWriter) endStruct() {
+	tw.stop()
+	tw.lastID = tw.enclose[len(tw.enclose)-1]
+	tw.enclose = tw.enclose[:len(tw.enclose)-1]
+}
+
+// beginList opens a list field of n elements, closed by endList. Struct
+// elements are written as fields each followed by stop.
+func (tw *thriftWriter) beginList(id int16, elem byte, n int) {
+	tw.field(id, thriftList)
+	if n < 15 {
+		tw.buf = append(tw.buf, byte(n)<<4|elem)
+	} else {
+		tw.buf = append(tw.buf, 0xf0|elem)
+		tw.buf = binary.AppendUvarint(tw.buf, uint64(n))
+	}
+	tw.enclose = append(tw.enclose, tw.lastID)
+	tw.lastID = 0
+}
+
+func (tw *thriftWriter) endList() {
+	tw.lastID = tw.enclose[len(tw.enclose)-1]
+	tw.enclose = tw.enclose[:len(tw.enclose)-1]
+}
+
+func (tw *thriftWriter) listI32(v int32) {
+	tw.buf = binary.AppendVarint(tw.buf, int64(v))
+}
+
+func (tw *thriftWriter) listBinary(v string) {
+	tw.buf = binary.AppendUvarint(tw.buf, uint64(len(v)))
+	tw.buf = append(tw.buf, v...)
+}
+
+// stop ends the current struct; within a list it also starts the next
+// struct element
+func (tw *thriftWriter) stop() {
+	tw.buf = append(tw.buf, 0)
+	tw.lastID = 0
+}
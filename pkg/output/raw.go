@@ -0,0 +1,52 @@
+package output
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// RawFileSink writes every raw chunk as gzip-compressed JSON named after the
+// measurement UUID, e.g. <dir>/<id>.json.gz
+type RawFileSink struct {
+	dir string
+}
+
+// NewRawFileSink creates a sink storing raw chunks below dir
+func NewRawFileSink(dir string) *RawFileSink {
+	return &RawFileSink{dir: dir}
+}
+
+// Path returns the file a chunk with the given measurement UUID is written to
+func (rs *RawFileSink) Path(id string) string {
+	return filepath.Join(rs.dir, id+".json.gz")
+}
+
+// WriteRawChunk stores the chunk
+func (rs *RawFileSink) WriteRawChunk(chunk signal.RawChunk) error {
+	if chunk.ID == "" {
+		return config.NewValidationError("RawChunk", "raw chunk has no measurement UUID")
+	}
+	if err := os.MkdirAll(rs.dir, 0755); err != nil {
+		return config.NewProcessingError("raw chunk writing", err)
+	}
+
+	file, err := os.Create(rs.Path(chunk.ID))
+	if err != nil {
+		return config.NewProcessingError("raw chunk writing", err)
+	}
+	defer file.Close()
+
+	zw := gzip.NewWriter(file)
+	if err := json.NewEncoder(zw).Encode(chunk); err != nil {
+		return config.NewProcessingError("raw chunk writing", err)
+	}
+	if err := zw.Close(); err != nil {
+		return config.NewProcessingError("raw chunk writing", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestRawFileSink_WriteRawChunk(t *testing.T) {
+	sink := NewRawFileSink(t.TempDir())
+	chunk := signal.RawChunk{
+		Identity: signal.NewIdentity(),
+		Voltage:  signal.Signal{Values: []float64{1, 2, 3}, SampleRate: 100},
+		Current:  signal.Signal{Values: []float64{0.1, 0.2, 0.3}, SampleRate: 100},
+	}
+
+	if err := sink.WriteRawChunk(chunk); err != nil {
+		t.Fatalf("WriteRawChunk() error = %v", err)
+	}
+
+	file, err := os.Open(sink.Path(chunk.ID))
+	if err != nil {
+		t.Fatalf("raw chunk not written: %v", err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("raw chunk is not gzip compressed: %v", err)
+	}
+
+	var decoded signal.RawChunk
+	if err := json.NewDecoder(zr).Decode(&decoded); err != nil {
+		t.Fatalf("raw chunk is not valid JSON: %v", err)
+	}
+	if decoded.Identity != chunk.Identity || len(decoded.Voltage.Values) != 3 || decoded.Current.Values[2] != 0.3 {
+		t.Errorf("decoded chunk = %+v, want %+v", decoded, chunk)
+	}
+
+	if err := sink.WriteRawChunk(signal.RawChunk{}); err == nil {
+		t.Errorf("expected error for a chunk without measurement UUID")
+	}
+}
@@ -0,0 +1,168 @@
+package output
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// RetentionPolicy limits how much output is kept on disk; zero values disable a limit
+type RetentionPolicy struct {
+	MaxFiles      int
+	MaxTotalBytes int64
+	MaxAge        time.Duration
+}
+
+// Enabled reports whether any retention limit is configured
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxFiles > 0 || p.MaxTotalBytes > 0 || p.MaxAge > 0
+}
+
+// Janitor periodically removes the oldest output files exceeding a retention policy
+type Janitor struct {
+	dir        string
+	policy     RetentionPolicy
+	extensions map[string]bool
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewJanitor creates a janitor for JSON and CSV files below dir
+func NewJanitor(dir string, policy RetentionPolicy) *Janitor {
+	return &Janitor{
+		dir:    dir,
+		policy: policy,
+		extensions: map[string]bool{
+			".json": true,
+			".csv":  true,
+		},
+		stopChan: make(chan struct{}),
+	}
+}
+
+// outputFile describes a candidate file for removal
+type outputFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Cleanup applies the retention policy once and returns the number of removed files
+func (j *Janitor) Cleanup() (int, error) {
+	if !j.policy.Enabled() {
+		return 0, nil
+	}
+
+	var files []outputFile
+	err := filepath.WalkDir(j.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !j.extensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // File vanished while walking
+		}
+		files = append(files, outputFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return 0, config.NewProcessingError("output retention scan", err)
+	}
+
+	// Oldest first so they are removed first
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].modTime.Before(files[b].modTime)
+	})
+
+	var totalBytes int64
+	for _, f := range files {
+		totalBytes += f.size
+	}
+
+	cutoff := time.Time{}
+	if j.policy.MaxAge > 0 {
+		cutoff = time.Now().Add(-j.policy.MaxAge)
+	}
+
+	removed := 0
+	remaining := len(files)
+	for _, f := range files {
+		expired := !cutoff.IsZero() && f.modTime.Before(cutoff)
+		tooMany := j.policy.MaxFiles > 0 && remaining > j.policy.MaxFiles
+		tooBig := j.policy.MaxTotalBytes > 0 && totalBytes > j.policy.MaxTotalBytes
+		if !expired && !tooMany && !tooBig {
+			break
+		}
+
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, config.NewProcessingError("output retention removal", err)
+		}
+		removed++
+		remaining--
+		totalBytes -= f.size
+		j.removeEmptyParents(filepath.Dir(f.path))
+	}
+
+	return removed, nil
+}
+
+// removeEmptyParents deletes directories left empty by cleanup, up to the output root
+func (j *Janitor) removeEmptyParents(dir string) {
+	root := filepath.Clean(j.dir)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty or not removable
+		}
+	}
+}
+
+// Start runs Cleanup immediately and then at every interval until Stop is called
+func (j *Janitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if removed, err := j.Cleanup(); err != nil {
+				log.Printf("Output retention error: %v", err)
+			} else if removed > 0 {
+				log.Printf("Output retention removed %d old files from %s", removed, j.dir)
+			}
+
+			select {
+			case <-j.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop halts the periodic cleanup
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stopChan)
+	})
+	j.wg.Wait()
+}
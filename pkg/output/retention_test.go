@@ -0,0 +1,66 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAgedFile(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJanitor_Cleanup(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      RetentionPolicy
+		wantRemoved int
+	}{
+		{name: "disabled", policy: RetentionPolicy{}, wantRemoved: 0},
+		{name: "max files", policy: RetentionPolicy{MaxFiles: 2}, wantRemoved: 2},
+		{name: "max total size", policy: RetentionPolicy{MaxTotalBytes: 250}, wantRemoved: 2},
+		{name: "max age", policy: RetentionPolicy{MaxAge: 90 * time.Minute}, wantRemoved: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeAgedFile(t, filepath.Join(dir, "json", "old", "a.json"), 100, 4*time.Hour)
+			writeAgedFile(t, filepath.Join(dir, "csv", "b.csv"), 100, 3*time.Hour)
+			writeAgedFile(t, filepath.Join(dir, "json", "c.json"), 100, time.Hour)
+			writeAgedFile(t, filepath.Join(dir, "csv", "d.csv"), 100, time.Minute)
+			writeAgedFile(t, filepath.Join(dir, "notes.txt"), 1000, 10*time.Hour)
+
+			removed, err := NewJanitor(dir, tt.policy).Cleanup()
+			if err != nil {
+				t.Fatalf("Cleanup() error = %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("Cleanup() removed %d files, want %d", removed, tt.wantRemoved)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+				t.Errorf("non-output file should be kept: %v", err)
+			}
+			if tt.wantRemoved > 0 {
+				if _, err := os.Stat(filepath.Join(dir, "json", "old")); !os.IsNotExist(err) {
+					t.Errorf("expected emptied directory to be removed")
+				}
+				if _, err := os.Stat(filepath.Join(dir, "csv", "d.csv")); err != nil {
+					t.Errorf("newest file should be kept: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// FileSinkOptions configures the sinks writing measurements to files
+type FileSinkOptions struct {
+	Paths    *PathTemplate     // Layout of the files below the output directory
+	Clock    clock.Clock       // Time substituted into the paths
+	Bode     bool              // Add magnitude and phase to every point
+	Format   CSVFormat         // Separators and number format of CSV files
+	Manifest *ManifestRecorder // Records the written files; may be nil
+}
+
+// recordOutput adds a written file to the manifest if one is kept
+func (o FileSinkOptions) recordOutput(path string) {
+	if o.Manifest != nil {
+		o.Manifest.RecordOutput(path)
+	}
+}
+
+// create renders the path of the next file, creates its directory and the file
+func (o FileSinkOptions) create(counter int, format string) (*os.File, error) {
+	path := o.Paths.Render(PathFields{
+		Time:    o.Clock.Now(),
+		Counter: counter,
+		Format:  format,
+	})
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, config.NewProcessingError("output directory creation", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, config.NewProcessingError("output file creation", err)
+	}
+	return file, nil
+}
+
+// DirSink writes every measurement to its own file, encoded by a
+// MeasurementEncoder and named after the encoder's extension
+type DirSink struct {
+	options FileSinkOptions
+	encoder MeasurementEncoder
+	counter int
+}
+
+// NewDirSink creates a sink writing one file per measurement with encoder
+func NewDirSink(options FileSinkOptions, encoder MeasurementEncoder) *DirSink {
+	return &DirSink{options: options, encoder: encoder}
+}
+
+// NewJSONDirSink creates a sink writing one indented JSON file per measurement
+func NewJSONDirSink(options FileSinkOptions) *DirSink {
+	return NewDirSink(options, JSONEncoder{Indent: true})
+}
+
+// NewCSVDirSink creates a sink writing one CSV file per measurement
+func NewCSVDirSink(options FileSinkOptions) *DirSink {
+	return NewDirSink(options, CSVEncoder{Format: options.Format})
+}
+
+// Write stores the measurement in the next file
+func (ds *DirSink) Write(data signal.ImpedanceDataWithIteration) error {
+	ds.counter++
+	file, err := ds.options.create(ds.counter, ds.encoder.Extension())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := ds.encoder.Encode(file, signal.NewMeasurement(data.ImpedanceData, ds.options.Bode)); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return config.NewProcessingError("output file writing", err)
+	}
+
+	ds.options.recordOutput(file.Name())
+	log.Printf("EIS measurement saved to: %s", file.Name())
+	return nil
+}
+
+// Flush does nothing, every file is complete once written
+func (ds *DirSink) Flush() error {
+	return nil
+}
+
+// Close does nothing, no file is kept open
+func (ds *DirSink) Close() error {
+	return nil
+}
+
+// NDJSONSink appends every measurement as one line of JSON to a single file,
+// the document of the JSON directory sink without indentation
+type NDJSONSink struct {
+	options FileSinkOptions
+	file    *os.File
+	writer  *bufio.Writer
+}
+
+// NewNDJSONSink creates the file rendered for counter 0 and returns a sink
+// appending measurements to it
+func NewNDJSONSink(options FileSinkOptions) (*NDJSONSink, error) {
+	file, err := options.create(0, "ndjson")
+	if err != nil {
+		return nil, err
+	}
+	options.recordOutput(file.Name())
+	log.Printf("Writing EIS measurements to: %s", file.Name())
+	return &NDJSONSink{options: options, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Write appends the measurement as one line
+func (ns *NDJSONSink) Write(data signal.ImpedanceDataWithIteration) error {
+	return JSONEncoder{}.Encode(ns.writer, signal.NewMeasurement(data.ImpedanceData, ns.options.Bode))
+}
+
+// Flush writes the buffered lines to the file
+func (ns *NDJSONSink) Flush() error {
+	if err := ns.writer.Flush(); err != nil {
+		return config.NewProcessingError("NDJSON writing", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the file
+func (ns *NDJSONSink) Close() error {
+	err := ns.Flush()
+	if closeErr := ns.file.Close(); err == nil && closeErr != nil {
+		err = config.NewProcessingError("NDJSON writing", closeErr)
+	}
+	return err
+}
+
+// MultiSink hands every measurement to all of its sinks, e.g. to send spectra
+// and keep local copies in the same run. A failing sink does not keep the
+// measurement from the others; their errors are joined.
+type MultiSink []Sink
+
+// NewMultiSink combines sinks, returning a single sink as it is
+func NewMultiSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return MultiSink(sinks)
+}
+
+// Write hands the measurement to every sink
+func (ms MultiSink) Write(data signal.ImpedanceDataWithIteration) error {
+	var errs []error
+	for _, sink := range ms {
+		errs = append(errs, sink.Write(data))
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes every sink
+func (ms MultiSink) Flush() error {
+	var errs []error
+	for _, sink := range ms {
+		errs = append(errs, sink.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every sink
+func (ms MultiSink) Close() error {
+	var errs []error
+	for _, sink := range ms {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ConventionSink presents every measurement in an output convention before
+// handing it to the wrapped sink, see signal.Convention
+type ConventionSink struct {
+	Sink
+	convention signal.Convention
+}
+
+// NewConventionSink wraps sink, returning it as it is for the zero convention
+func NewConventionSink(sink Sink, convention signal.Convention) Sink {
+	if convention.IsZero() {
+		return sink
+	}
+	return &ConventionSink{Sink: sink, convention: convention}
+}
+
+// Write hands the measurement in the convention to the wrapped sink
+func (cs *ConventionSink) Write(data signal.ImpedanceDataWithIteration) error {
+	data.ImpedanceData = cs.convention.Apply(data.ImpedanceData)
+	return cs.Sink.Write(data)
+}
+
+// BandSink hands only the points of every measurement within a frequency
+// band to the wrapped sink, so out-of-band points are neither sent nor stored
+type BandSink struct {
+	Sink
+	fmin, fmax float64
+}
+
+// NewBandSink wraps sink to keep the points from fmin to fmax, fmax 0 setting
+// no upper limit; the sink is returned as it is without limits
+func NewBandSink(sink Sink, fmin, fmax float64) Sink {
+	if fmin == 0 && fmax == 0 {
+		return sink
+	}
+	return &BandSink{Sink: sink, fmin: fmin, fmax: fmax}
+}
+
+// Write hands the in-band points to the wrapped sink; a measurement without
+// any is an error
+func (bs *BandSink) Write(data signal.ImpedanceDataWithIteration) error {
+	data.ImpedanceData = data.ImpedanceData.Band(bs.fmin, bs.fmax)
+	if len(data.ImpedanceData.Impedance) == 0 {
+		return config.NewValidationError("Frequencies", fmt.Sprintf("spectrum %d has no points between %g and %g Hz", data.ImpedanceData.Sequence, bs.fmin, bs.fmax))
+	}
+	return bs.Sink.Write(data)
+}
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// sinkTestOptions returns file sink options writing below dir
+func sinkTestOptions(t *testing.T, dir string) FileSinkOptions {
+	t.Helper()
+	paths, err := NewPathTemplate(dir, "{format}/eis_{counter}.{ext}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return FileSinkOptions{Paths: paths, Clock: clock.NewSimulatedClock(time.Unix(0, 0))}
+}
+
+// sinkTestSpectrum returns a two point spectrum with identity and flags
+func sinkTestSpectrum(sequence uint64) signal.ImpedanceDataWithIteration {
+	data := signal.ImpedanceData{
+		Frequencies:  []float64{1, 100},
+		Impedance:    []complex128{complex(110, -20), complex(10.5, -0.25)},
+		QualityFlags: signal.FlagLowSNR,
+	}
+	data.Identity = signal.Identity{ID: "id-1", Sequence: sequence}
+	data.Metadata = signal.Metadata{Channel: "ch1"}
+	return signal.ImpedanceDataWithIteration{ImpedanceData: data, Iteration: int(sequence)}
+}
+
+func TestJSONDirSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	options := sinkTestOptions(t, dir)
+	options.Bode = true
+	sink := NewJSONDirSink(options)
+	for i := uint64(1); i <= 2; i++ {
+		if err := sink.Write(sinkTestSpectrum(i)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "json", "eis_002.json"))
+	if err != nil {
+		t.Fatalf("second measurement not written: %v", err)
+	}
+	var document struct {
+		ID           string                  `json:"id"`
+		Sequence     uint64                  `json:"sequence"`
+		QualityFlags signal.QualityFlags     `json:"quality_flags"`
+		Points       []signal.ImpedancePoint `json:"points"`
+	}
+	if err := json.Unmarshal(content, &document); err != nil {
+		t.Fatalf("measurement is not valid JSON: %v", err)
+	}
+	if document.ID != "id-1" || document.Sequence != 2 || document.QualityFlags != signal.FlagLowSNR {
+		t.Errorf("document = %+v, want identity id-1/2 and low_snr", document)
+	}
+	if len(document.Points) != 2 || document.Points[1].Real != 10.5 || document.Points[1].MagnitudeOhm == nil {
+		t.Errorf("points = %+v, want 2 points with Bode values", document.Points)
+	}
+}
+
+func TestCSVDirSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	options := sinkTestOptions(t, dir)
+	options.Format = CSVFormat{Delimiter: ';', DecimalSeparator: ','}
+	if err := NewCSVDirSink(options).Write(sinkTestSpectrum(7)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "csv", "eis_001.csv"))
+	if err != nil {
+		t.Fatalf("measurement not written: %v", err)
+	}
+	want := "frequency;real;imag;quality_flags;id;sequence;unit;channel;probe;device_serial\n" +
+		"1;110,000000;-20,000000;2;id-1;7;;ch1;;\n" +
+		"100;10,500000;-0,250000;2;id-1;7;;ch1;;\n"
+	if string(content) != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestNDJSONSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewNDJSONSink(sinkTestOptions(t, dir))
+	if err != nil {
+		t.Fatalf("NewNDJSONSink() error = %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := sink.Write(sinkTestSpectrum(i)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "ndjson", "eis_000.ndjson"))
+	if err != nil {
+		t.Fatalf("NDJSON file not written: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var sequences []uint64
+	for scanner.Scan() {
+		var document struct {
+			Sequence uint64 `json:"sequence"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &document); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", scanner.Text(), err)
+		}
+		sequences = append(sequences, document.Sequence)
+	}
+	if len(sequences) != 3 || sequences[0] != 1 || sequences[2] != 3 {
+		t.Errorf("sequences = %v, want [1 2 3]", sequences)
+	}
+}
+
+// failingSink counts the calls it receives and fails all of them
+type failingSink struct {
+	writes, flushes, closes int
+}
+
+func (fs *failingSink) Write(signal.ImpedanceDataWithIteration) error {
+	fs.writes++
+	return errors.New("write failed")
+}
+
+func (fs *failingSink) Flush() error {
+	fs.flushes++
+	return nil
+}
+
+func (fs *failingSink) Close() error {
+	fs.closes++
+	return errors.New("close failed")
+}
+
+func TestMultiSink(t *testing.T) {
+	dir := t.TempDir()
+	failing := &failingSink{}
+	sink := NewMultiSink(failing, NewCSVDirSink(sinkTestOptions(t, dir)))
+
+	err := sink.Write(sinkTestSpectrum(1))
+	if err == nil || !strings.Contains(err.Error(), "write failed") {
+		t.Errorf("Write() error = %v, want the failing sink's error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "csv", "eis_001.csv")); err != nil {
+		t.Errorf("a failing sink kept the measurement from the others: %v", err)
+	}
+	if err := sink.Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if err := sink.Close(); err == nil {
+		t.Error("Close() did not report the failing sink")
+	}
+	if failing.writes != 1 || failing.flushes != 1 || failing.closes != 1 {
+		t.Errorf("calls = %+v, want one of each", *failing)
+	}
+
+	single := &failingSink{}
+	if NewMultiSink(single) != Sink(single) {
+		t.Error("NewMultiSink() wrapped a single sink")
+	}
+}
+
+func TestConventionSink(t *testing.T) {
+	memory := NewMemorySink()
+	if NewConventionSink(memory, signal.Convention{}) != Sink(memory) {
+		t.Error("NewConventionSink() wrapped the sink for the zero convention")
+	}
+
+	sink := NewConventionSink(memory, signal.Convention{NegateImaginary: true, Order: signal.OrderDescending})
+	spectrum := sinkTestSpectrum(1)
+	if err := sink.Write(spectrum); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	written := memory.Spectra()
+	if len(written) != 1 || written[0].Frequencies[0] != 100 || written[0].Impedance[0] != complex(10.5, 0.25) {
+		t.Errorf("written = %+v, want descending frequencies with −Im Z", written)
+	}
+	if spectrum.ImpedanceData.Impedance[0] != complex(110, -20) {
+		t.Error("the convention changed the caller's spectrum")
+	}
+}
+
+func TestBandSink(t *testing.T) {
+	memory := NewMemorySink()
+	if NewBandSink(memory, 0, 0) != Sink(memory) {
+		t.Error("NewBandSink() wrapped the sink without limits")
+	}
+
+	sink := NewBandSink(memory, 10, 0)
+	if err := sink.Write(sinkTestSpectrum(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if written := memory.Spectra(); len(written) != 1 || len(written[0].Frequencies) != 1 || written[0].Frequencies[0] != 100 {
+		t.Errorf("written = %+v, want only the 100 Hz point", written)
+	}
+
+	if err := NewBandSink(memory, 200, 500).Write(sinkTestSpectrum(2)); err == nil {
+		t.Error("Write() accepted a spectrum without points in the band")
+	}
+	if len(memory.Spectra()) != 1 {
+		t.Error("a spectrum without points in the band was written")
+	}
+}
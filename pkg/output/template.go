@@ -0,0 +1,99 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// DefaultTemplate reproduces the historical output/<format>/eis_measurement_<timestamp>_<counter> layout
+const DefaultTemplate = "{format}/eis_measurement_{timestamp}_{counter}.{ext}"
+
+// placeholderPattern matches {name} placeholders in a path template
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// knownPlaceholders lists the placeholders understood by PathTemplate
+var knownPlaceholders = map[string]bool{
+	"date":      true, // 20060102
+	"time":      true, // 150405
+	"timestamp": true, // 20060102_150405
+	"counter":   true, // zero-padded measurement counter
+	"cell":      true, // configured cell identifier
+	"format":    true, // json or csv
+	"ext":       true, // file extension without dot
+}
+
+// PathFields holds the per-file values substituted into a path template
+type PathFields struct {
+	Time    time.Time
+	Counter int
+	Format  string
+}
+
+// PathTemplate renders output file paths below a base directory
+type PathTemplate struct {
+	dir     string
+	pattern string
+	cell    string
+}
+
+// NewPathTemplate creates a path template for a cell, rejecting unknown placeholders
+func NewPathTemplate(dir, pattern, cell string) (*PathTemplate, error) {
+	if pattern == "" {
+		pattern = DefaultTemplate
+	}
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(pattern, -1) {
+		if !knownPlaceholders[match[1]] {
+			return nil, config.NewValidationError("OutputTemplate", fmt.Sprintf("unknown placeholder {%s}", match[1]))
+		}
+	}
+
+	if cell == "" {
+		cell = "default"
+	}
+
+	return &PathTemplate{
+		dir:     dir,
+		pattern: pattern,
+		cell:    sanitizePathElement(cell),
+	}, nil
+}
+
+// Dir returns the base output directory
+func (t *PathTemplate) Dir() string {
+	return t.dir
+}
+
+// Render returns the file path for the given fields
+func (t *PathTemplate) Render(fields PathFields) string {
+	replacer := strings.NewReplacer(
+		"{date}", fields.Time.Format("20060102"),
+		"{time}", fields.Time.Format("150405"),
+		"{timestamp}", fields.Time.Format("20060102_150405"),
+		"{counter}", fmt.Sprintf("%03d", fields.Counter),
+		"{cell}", t.cell,
+		"{format}", fields.Format,
+		"{ext}", fields.Format,
+	)
+
+	rendered := filepath.FromSlash(replacer.Replace(t.pattern))
+	if filepath.IsAbs(rendered) {
+		return rendered
+	}
+	return filepath.Join(t.dir, rendered)
+}
+
+// sanitizePathElement prevents substituted values from escaping their directory
+func sanitizePathElement(value string) string {
+	value = strings.ReplaceAll(value, "/", "_")
+	value = strings.ReplaceAll(value, "\\", "_")
+	if value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPathTemplate_Render(t *testing.T) {
+	ts := time.Date(2025, 7, 25, 20, 22, 41, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		pattern string
+		cell    string
+		fields  PathFields
+		want    string
+	}{
+		{
+			name:    "default layout",
+			pattern: "",
+			fields:  PathFields{Time: ts, Counter: 7, Format: "json"},
+			want:    filepath.Join("output", "json", "eis_measurement_20250725_202241_007.json"),
+		},
+		{
+			name:    "date and cell directories",
+			pattern: "{date}/{cell}/{counter}.{ext}",
+			cell:    "cell-A",
+			fields:  PathFields{Time: ts, Counter: 12, Format: "csv"},
+			want:    filepath.Join("output", "20250725", "cell-A", "012.csv"),
+		},
+		{
+			name:    "cell cannot escape directory",
+			pattern: "{cell}/{counter}.json",
+			cell:    "../etc",
+			fields:  PathFields{Time: ts, Counter: 1, Format: "json"},
+			want:    filepath.Join("output", ".._etc", "001.json"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewPathTemplate("output", tt.pattern, tt.cell)
+			if err != nil {
+				t.Fatalf("NewPathTemplate() error = %v", err)
+			}
+			if got := tmpl.Render(tt.fields); got != tt.want {
+				t.Errorf("Render() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPathTemplate_UnknownPlaceholder(t *testing.T) {
+	if _, err := NewPathTemplate("output", "{date}/{nope}.json", ""); err == nil {
+		t.Errorf("expected error for unknown placeholder")
+	}
+}
@@ -0,0 +1,90 @@
+package plot
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Plot geometry in SVG user units
+const (
+	Width  = 640
+	Height = 480
+	margin = 60
+)
+
+// Palette holds distinguishable series colors, reused cyclically
+var Palette = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#17becf"}
+
+// Series is one polyline of a plot
+type Series struct {
+	Name   string
+	Color  string
+	X, Y   []float64
+	Points bool // Draw markers instead of a line
+}
+
+// WriteSVG renders the series on shared linear axes as a standalone SVG
+func WriteSVG(w io.Writer, title, xLabel, yLabel string, series []Series) error {
+	xMin, xMax, yMin, yMax := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for i := range s.X {
+			xMin, xMax = math.Min(xMin, s.X[i]), math.Max(xMax, s.X[i])
+			yMin, yMax = math.Min(yMin, s.Y[i]), math.Max(yMax, s.Y[i])
+		}
+	}
+	if math.IsInf(xMin, 0) {
+		xMin, xMax, yMin, yMax = 0, 1, 0, 1
+	}
+	if xMax == xMin {
+		xMin, xMax = xMin-1, xMax+1
+	}
+	if yMax == yMin {
+		yMin, yMax = yMin-1, yMax+1
+	}
+	px := func(x float64) float64 { return margin + (x-xMin)/(xMax-xMin)*(Width-2*margin) }
+	py := func(y float64) float64 {
+		return Height - margin - (y-yMin)/(yMax-yMin)*(Height-2*margin)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", Width, Height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%d" y="24" text-anchor="middle" font-size="14">%s</text>`+"\n", Width/2, Escape(title))
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="black"/>`+"\n",
+		margin, margin, Width-2*margin, Height-2*margin)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n", Width/2, Height-15, Escape(xLabel))
+	fmt.Fprintf(&b, `<text x="15" y="%d" text-anchor="middle" transform="rotate(-90 15 %d)">%s</text>`+"\n",
+		Height/2, Height/2, Escape(yLabel))
+	for i := 0; i <= 4; i++ {
+		x := xMin + (xMax-xMin)*float64(i)/4
+		y := yMin + (yMax-yMin)*float64(i)/4
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%.3g</text>`+"\n", px(x), Height-margin+16, x)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.3g</text>`+"\n", margin-4, py(y)+4, y)
+	}
+
+	for k, s := range series {
+		if s.Points {
+			for i := range s.X {
+				fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", px(s.X[i]), py(s.Y[i]), s.Color)
+			}
+		} else {
+			coords := make([]string, len(s.X))
+			for i := range s.X {
+				coords[i] = fmt.Sprintf("%.1f,%.1f", px(s.X[i]), py(s.Y[i]))
+			}
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", strings.Join(coords, " "), s.Color)
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", Width-margin-150, margin+16+16*k, s.Color, Escape(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Escape makes text safe inside SVG and HTML elements
+func Escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
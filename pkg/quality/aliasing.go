@@ -0,0 +1,138 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// NyquistGuardFraction is where the guard band below the Nyquist frequency
+// starts when the analog bandwidth is unknown, as a fraction of Nyquist
+const NyquistGuardFraction = 0.9
+
+// AliasingViolation is a channel with too much energy in the guard band
+// below the Nyquist frequency
+type AliasingViolation struct {
+	Channel string  // "voltage" or "current"
+	From    float64 // Lower edge of the guard band in Hz
+	Percent float64 // Share of the AC power in the guard band
+	Limit   float64 // Percentage it exceeds
+}
+
+// String formats the violation for log messages
+func (v AliasingViolation) String() string {
+	return fmt.Sprintf("%s: %.3g%% of the AC power above %g Hz exceeds %.3g%%", v.Channel, v.Percent, v.From, v.Limit)
+}
+
+// GuardBandChecker flags chunks with significant power between the guard
+// frequency and Nyquist. An anti-alias filter that does its job leaves almost
+// nothing there, so power in that band means content above Nyquist may have
+// folded onto the excitation lines and the impedance points are unreliable.
+type GuardBandChecker struct {
+	limit     float64
+	bandwidth float64
+	processor fft.Processor
+}
+
+// NewGuardBandChecker creates a checker allowing limitPercent of the AC power
+// in the guard band. The band starts at the analog bandwidth of the front end
+// in Hz, or at NyquistGuardFraction of Nyquist if that is lower or bandwidth
+// is 0 (unknown).
+func NewGuardBandChecker(limitPercent, bandwidth float64) (AliasingChecker, error) {
+	if limitPercent <= 0 || limitPercent > 100 || math.IsNaN(limitPercent) {
+		return nil, config.NewValidationError("AliasLimit", "alias limit must be a percentage above 0 and up to 100")
+	}
+	if bandwidth < 0 || math.IsNaN(bandwidth) || math.IsInf(bandwidth, 0) {
+		return nil, config.NewValidationError("AnalogBandwidth", "analog bandwidth must be a finite frequency, 0 if unknown")
+	}
+	return &GuardBandChecker{
+		limit:     limitPercent,
+		bandwidth: bandwidth,
+		processor: fft.NewProcessor(),
+	}, nil
+}
+
+// CheckAliasing returns the channels whose guard band power exceeds the
+// limit. A front end whose bandwidth exceeds the Nyquist frequency of the
+// chunk cannot prevent aliasing at all and is reported as an error.
+func (gc *GuardBandChecker) CheckAliasing(voltage, current signal.Signal) ([]AliasingViolation, error) {
+	nyquist := voltage.SampleRate / 2
+	if gc.bandwidth > nyquist {
+		return nil, config.NewValidationError("AnalogBandwidth", fmt.Sprintf("analog bandwidth %g Hz exceeds the Nyquist frequency %g Hz, content above Nyquist aliases", gc.bandwidth, nyquist))
+	}
+	from := GuardFrequency(voltage.SampleRate, gc.bandwidth)
+
+	var violations []AliasingViolation
+	for _, channel := range []struct {
+		name string
+		sig  signal.Signal
+	}{{"voltage", voltage}, {"current", current}} {
+		frequencies, powers, err := powerSpectrum(gc.processor, channel.sig)
+		if err != nil {
+			return nil, config.NewProcessingError(channel.name+" aliasing check", err)
+		}
+		if percent := bandPowerPercent(frequencies, powers, from); percent > gc.limit {
+			violations = append(violations, AliasingViolation{Channel: channel.name, From: from, Percent: percent, Limit: gc.limit})
+		}
+	}
+	return violations, nil
+}
+
+// GuardFrequency returns the lower edge of the guard band for a sample rate
+// and an analog bandwidth (0 = unknown)
+func GuardFrequency(sampleRate, bandwidth float64) float64 {
+	from := NyquistGuardFraction * sampleRate / 2
+	if bandwidth > 0 && bandwidth < from {
+		from = bandwidth
+	}
+	return from
+}
+
+// powerSpectrum returns the positive frequencies of the mean-free signal and
+// the power of each bin
+func powerSpectrum(processor fft.Processor, sig signal.Signal) ([]float64, []float64, error) {
+	mean := 0.0
+	for _, v := range sig.Values {
+		mean += v
+	}
+	mean /= float64(len(sig.Values))
+	centered := sig
+	centered.Values = make([]float64, len(sig.Values))
+	for i, v := range sig.Values {
+		centered.Values[i] = v - mean
+	}
+
+	spectrum, err := processor.ProcessSignal(centered)
+	if err != nil {
+		return nil, nil, err
+	}
+	positive, err := processor.GetPositiveFrequencies(spectrum)
+	if err != nil {
+		return nil, nil, err
+	}
+	powers := make([]float64, len(positive.Values))
+	for i, v := range positive.Values {
+		magnitude := cmplx.Abs(v)
+		powers[i] = magnitude * magnitude
+	}
+	return positive.Frequencies, powers, nil
+}
+
+// bandPowerPercent returns the share of the non-DC power at or above from
+func bandPowerPercent(frequencies, powers []float64, from float64) float64 {
+	var total, band float64
+	for i := 1; i < len(powers); i++ {
+		total += powers[i]
+		if frequencies[i] >= from {
+			band += powers[i]
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return band / total * 100
+}
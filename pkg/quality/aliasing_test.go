@@ -0,0 +1,71 @@
+package quality
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestGuardBandChecker_CheckAliasing(t *testing.T) {
+	const sampleRate, n = 1024.0, 1024
+	tones := func(amplitudes map[float64]float64) signal.Signal {
+		values := make([]float64, n)
+		for i := range values {
+			for frequency, amplitude := range amplitudes {
+				values[i] += amplitude * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate)
+			}
+		}
+		return signal.Signal{Timestamp: time.Now(), Values: values, SampleRate: sampleRate}
+	}
+	clean := tones(map[float64]float64{10: 1, 100: 0.5})
+
+	tests := []struct {
+		name         string
+		bandwidth    float64
+		current      signal.Signal
+		wantChannels []string
+	}{
+		{"clean", 0, clean, nil},
+		// 490 Hz lies above 90% of the 512 Hz Nyquist frequency
+		{"tone near Nyquist", 0, tones(map[float64]float64{10: 1, 490: 0.5}), []string{"current"}},
+		{"tone below guard band", 0, tones(map[float64]float64{10: 1, 400: 0.5}), nil},
+		{"tone above analog bandwidth", 200, tones(map[float64]float64{10: 1, 400: 0.5}), []string{"current"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewGuardBandChecker(1, tt.bandwidth)
+			if err != nil {
+				t.Fatalf("NewGuardBandChecker() error = %v", err)
+			}
+			violations, err := checker.CheckAliasing(clean, tt.current)
+			if err != nil {
+				t.Fatalf("CheckAliasing() error = %v", err)
+			}
+			if len(violations) != len(tt.wantChannels) {
+				t.Fatalf("got violations %v, want channels %v", violations, tt.wantChannels)
+			}
+			for i, v := range violations {
+				if v.Channel != tt.wantChannels[i] {
+					t.Errorf("violation %d on %s, want %s", i, v.Channel, tt.wantChannels[i])
+				}
+				// A fifth of the power: 0.5² against 1² + 0.5²
+				if math.Abs(v.Percent-20) > 0.1 {
+					t.Errorf("violation %d percent = %v, want 20", i, v.Percent)
+				}
+			}
+		})
+	}
+
+	checker, _ := NewGuardBandChecker(1, 600)
+	if _, err := checker.CheckAliasing(clean, clean); err == nil {
+		t.Error("CheckAliasing() accepted an analog bandwidth above Nyquist")
+	}
+	for _, limit := range []float64{0, -1, 101} {
+		if _, err := NewGuardBandChecker(limit, 0); err == nil {
+			t.Errorf("NewGuardBandChecker(%v) accepted an invalid limit", limit)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package quality
+
+import (
+	"math"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+const (
+	// excitationThreshold is the fraction of the strongest spectral line a
+	// line must reach to count as excitation rather than noise
+	excitationThreshold = 0.05
+
+	// leakageBins is the number of bins on each side of an excitation line
+	// whose power is attributed to the line
+	leakageBins = 1
+
+	// maxSNR caps the SNR of noise-free signals
+	maxSNR = 300.0
+)
+
+// DefaultAnalyzer computes time-domain statistics and an FFT based SNR estimate
+type DefaultAnalyzer struct {
+	processor fft.Processor
+	validator signal.Validator
+}
+
+// NewAnalyzer creates a new signal quality analyzer
+func NewAnalyzer() Analyzer {
+	return &DefaultAnalyzer{
+		processor: fft.NewProcessor(),
+		validator: signal.NewValidator(),
+	}
+}
+
+// AnalyzeChunk analyzes the voltage and current signals of one chunk
+func (qa *DefaultAnalyzer) AnalyzeChunk(voltageSignal, currentSignal signal.Signal) (signal.ChunkQuality, error) {
+	voltage, err := qa.Analyze(voltageSignal)
+	if err != nil {
+		return signal.ChunkQuality{}, config.NewProcessingError("voltage quality", err)
+	}
+	current, err := qa.Analyze(currentSignal)
+	if err != nil {
+		return signal.ChunkQuality{}, config.NewProcessingError("current quality", err)
+	}
+	return signal.ChunkQuality{Voltage: voltage, Current: current}, nil
+}
+
+// Analyze computes the quality of a single signal
+func (qa *DefaultAnalyzer) Analyze(sig signal.Signal) (signal.Quality, error) {
+	if err := qa.validator.ValidateSignal(sig); err != nil {
+		return signal.Quality{}, err
+	}
+
+	n := float64(len(sig.Values))
+	mean := 0.0
+	for _, v := range sig.Values {
+		mean += v
+	}
+	mean /= n
+
+	var power, peak float64
+	for _, v := range sig.Values {
+		power += (v - mean) * (v - mean)
+		peak = math.Max(peak, math.Abs(v-mean))
+	}
+	rms := math.Sqrt(power / n)
+
+	quality := signal.Quality{
+		RMS:             rms,
+		ClippingPercent: clippingPercent(sig.Values) * 100,
+		DCOffset:        mean,
+	}
+	if rms > 0 {
+		quality.CrestFactor = peak / rms
+	}
+
+	frequencies, powers, err := powerSpectrum(qa.processor, sig)
+	if err != nil {
+		return signal.Quality{}, err
+	}
+	quality.SNRdB = snr(powers)
+	quality.NyquistPowerPercent = bandPowerPercent(frequencies, powers, GuardFrequency(sig.SampleRate, 0))
+	return quality, nil
+}
+
+// snr estimates the ratio of the power in the excitation lines (including
+// their leakage bins) to the power in all other non-DC bins, in dB
+func snr(powers []float64) float64 {
+	strongest := 0.0
+	for i := 1; i < len(powers); i++ {
+		strongest = math.Max(strongest, math.Sqrt(powers[i]))
+	}
+	if strongest == 0 {
+		return 0 // No AC content at all
+	}
+
+	excitation := make([]bool, len(powers))
+	for i := 1; i < len(powers); i++ {
+		magnitude := math.Sqrt(powers[i])
+		isPeak := (i == 1 || powers[i] >= powers[i-1]) && (i+1 == len(powers) || powers[i] >= powers[i+1])
+		if !isPeak || magnitude < excitationThreshold*strongest {
+			continue
+		}
+		for j := max(1, i-leakageBins); j <= min(len(powers)-1, i+leakageBins); j++ {
+			excitation[j] = true
+		}
+	}
+
+	var signalPower, noisePower float64
+	for i := 1; i < len(powers); i++ {
+		if excitation[i] {
+			signalPower += powers[i]
+		} else {
+			noisePower += powers[i]
+		}
+	}
+	if noisePower == 0 {
+		return maxSNR
+	}
+	return math.Min(10*math.Log10(signalPower/noisePower), maxSNR)
+}
+
+// clippingPercent returns the fraction of samples that sit at the signal's
+// minimum or maximum within runs of at least two samples, i.e. flattened peaks
+func clippingPercent(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	if lo == hi {
+		return 1 // A constant signal is entirely stuck
+	}
+
+	clipped := 0
+	for i, v := range values {
+		if v != lo && v != hi {
+			continue
+		}
+		if (i > 0 && values[i-1] == v) || (i+1 < len(values) && values[i+1] == v) {
+			clipped++
+		}
+	}
+	return float64(clipped) / float64(len(values))
+}
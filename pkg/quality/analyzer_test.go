@@ -0,0 +1,89 @@
+package quality
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestDefaultAnalyzer_Analyze(t *testing.T) {
+	const sampleRate, n = 1024.0, 1024
+	sine := func(amplitude, offset, clip, noise float64) signal.Signal {
+		rng := rand.New(rand.NewSource(1))
+		values := make([]float64, n)
+		for i := range values {
+			v := amplitude * math.Sin(2*math.Pi*16*float64(i)/sampleRate)
+			v = math.Max(-clip, math.Min(clip, v))
+			values[i] = v + offset + noise*rng.NormFloat64()
+		}
+		return signal.Signal{Timestamp: time.Now(), Values: values, SampleRate: sampleRate}
+	}
+
+	tests := []struct {
+		name         string
+		sig          signal.Signal
+		wantRMS      float64
+		wantCrest    float64
+		wantDC       float64
+		wantClipping bool
+		minSNR       float64
+		maxSNR       float64
+	}{
+		{
+			name:      "clean sine with offset",
+			sig:       sine(2, 0.5, math.Inf(1), 0),
+			wantRMS:   math.Sqrt2,
+			wantCrest: math.Sqrt2,
+			wantDC:    0.5,
+			minSNR:    100,
+			maxSNR:    maxSNR,
+		},
+		{
+			name:         "clipped sine",
+			sig:          sine(2, 0, 1, 0),
+			wantDC:       0,
+			wantClipping: true,
+			minSNR:       10,
+			maxSNR:       maxSNR,
+		},
+		{
+			name:   "noisy sine",
+			sig:    sine(1, 0, math.Inf(1), 0.1),
+			wantDC: 0,
+			minSNR: 10,
+			maxSNR: 40,
+		},
+	}
+
+	analyzer := NewAnalyzer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quality, err := analyzer.Analyze(tt.sig)
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if tt.wantRMS != 0 && math.Abs(quality.RMS-tt.wantRMS) > 1e-9 {
+				t.Errorf("RMS = %v, want %v", quality.RMS, tt.wantRMS)
+			}
+			if tt.wantCrest != 0 && math.Abs(quality.CrestFactor-tt.wantCrest) > 1e-9 {
+				t.Errorf("CrestFactor = %v, want %v", quality.CrestFactor, tt.wantCrest)
+			}
+			if math.Abs(quality.DCOffset-tt.wantDC) > 0.02 {
+				t.Errorf("DCOffset = %v, want %v", quality.DCOffset, tt.wantDC)
+			}
+			if (quality.ClippingPercent > 0) != tt.wantClipping {
+				t.Errorf("ClippingPercent = %v, want clipping %v", quality.ClippingPercent, tt.wantClipping)
+			}
+			if quality.SNRdB < tt.minSNR || quality.SNRdB > tt.maxSNR {
+				t.Errorf("SNRdB = %v, want within [%v, %v]", quality.SNRdB, tt.minSNR, tt.maxSNR)
+			}
+		})
+	}
+
+	if _, err := analyzer.Analyze(signal.Signal{SampleRate: sampleRate}); err == nil {
+		t.Errorf("expected error for an empty signal")
+	}
+}
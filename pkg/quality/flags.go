@@ -0,0 +1,21 @@
+package quality
+
+import (
+	"math"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Flags returns the quality flags following from the quality of a chunk:
+// clipped if either channel has flattened peaks and low_snr if either SNR is
+// below minSNR in dB
+func Flags(q signal.ChunkQuality, minSNR float64) signal.QualityFlags {
+	var flags signal.QualityFlags
+	if q.Voltage.ClippingPercent > 0 || q.Current.ClippingPercent > 0 {
+		flags |= signal.FlagClipped
+	}
+	if math.Min(q.Voltage.SNRdB, q.Current.SNRdB) < minSNR {
+		flags |= signal.FlagLowSNR
+	}
+	return flags
+}
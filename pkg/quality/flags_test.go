@@ -0,0 +1,28 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestFlags(t *testing.T) {
+	clean := signal.Quality{SNRdB: 40}
+	tests := []struct {
+		name    string
+		quality signal.ChunkQuality
+		want    signal.QualityFlags
+	}{
+		{name: "clean", quality: signal.ChunkQuality{Voltage: clean, Current: clean}},
+		{name: "clipped current", quality: signal.ChunkQuality{Voltage: clean, Current: signal.Quality{SNRdB: 40, ClippingPercent: 0.5}}, want: signal.FlagClipped},
+		{name: "noisy voltage", quality: signal.ChunkQuality{Voltage: signal.Quality{SNRdB: 12}, Current: clean}, want: signal.FlagLowSNR},
+		{name: "both", quality: signal.ChunkQuality{Voltage: signal.Quality{SNRdB: 3, ClippingPercent: 2}, Current: clean}, want: signal.FlagLowSNR | signal.FlagClipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Flags(tt.quality, 20); got != tt.want {
+				t.Errorf("Flags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package quality
+
+import (
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Analyzer estimates the acquisition quality of time-domain signals
+type Analyzer interface {
+	Analyze(sig signal.Signal) (signal.Quality, error)
+	AnalyzeChunk(voltageSignal, currentSignal signal.Signal) (signal.ChunkQuality, error)
+}
+
+// LinearityChecker verifies that the excitation keeps the cell in its linear regime
+type LinearityChecker interface {
+	CheckLinearity(voltage signal.Signal) ([]AmplitudeViolation, error)
+}
+
+// AliasingChecker verifies that no significant content reached the band
+// where aliases of frequencies above Nyquist would appear
+type AliasingChecker interface {
+	CheckAliasing(voltage, current signal.Signal) ([]AliasingViolation, error)
+}
+
+// StationarityChecker verifies that the system did not change while a chunk
+// was acquired
+type StationarityChecker interface {
+	CheckStationarity(voltage, current signal.Signal) (signal.Stationarity, error)
+}
+
+// KKChecker verifies that a spectrum is consistent with the Kramers-Kronig
+// relations, i.e. was measured on a linear, causal and stable system
+type KKChecker interface {
+	CheckKramersKronig(data signal.ImpedanceData) (KKResult, error)
+}
@@ -0,0 +1,237 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+const (
+	// linKKMaxElements bounds the RC elements of the Lin-KK fit
+	linKKMaxElements = 50
+	// linKKMu is the overfitting threshold of the Lin-KK mu criterion: fits
+	// whose negative resistances reach 1 - linKKMu of the positive ones
+	// model noise rather than the system
+	linKKMu = 0.85
+)
+
+// KKResult is the outcome of a Kramers-Kronig test of a spectrum
+type KKResult struct {
+	Elements           int     // RC elements of the fitted Voigt circuit
+	MaxResidualPercent float64 // Largest real or imaginary residual relative to |Z|
+	Valid              bool    // Every residual within the limit
+}
+
+// LinKKChecker tests spectra against the Kramers-Kronig relations with the
+// Lin-KK method (Schönleber et al., 2014): a series resistance, inductance
+// and chain of RC elements with fixed time constants spanning the measured
+// range is fitted by linear least squares. Any linear, causal and stable
+// system can be represented by such a circuit, so residuals beyond noise
+// reveal drift, nonlinearity or artefacts.
+type LinKKChecker struct {
+	limit float64
+}
+
+// NewLinKKChecker creates a checker accepting residuals up to limitPercent
+// of |Z| at every frequency
+func NewLinKKChecker(limitPercent float64) (KKChecker, error) {
+	if limitPercent <= 0 || math.IsNaN(limitPercent) || math.IsInf(limitPercent, 0) {
+		return nil, config.NewValidationError("KKLimit", "Kramers-Kronig limit must be a positive finite percentage")
+	}
+	return &LinKKChecker{limit: limitPercent}, nil
+}
+
+// CheckKramersKronig fits the Lin-KK circuit to the spectrum with weights
+// 1/|Z| and returns the largest residual of the closest fit that does not
+// overfit. Points with zero impedance, i.e. without measurable
+// current, are skipped; at least 3 points must remain.
+func (kc *LinKKChecker) CheckKramersKronig(data signal.ImpedanceData) (KKResult, error) {
+	if len(data.Impedance) != len(data.Frequencies) {
+		return KKResult{}, config.NewProcessingError("Kramers-Kronig test",
+			fmt.Errorf("%d impedance values for %d frequencies", len(data.Impedance), len(data.Frequencies)))
+	}
+	measured := signal.ImpedanceData{}
+	omegaMin, omegaMax := math.Inf(1), 0.0
+	for i, f := range data.Frequencies {
+		z := data.Impedance[i]
+		if z == 0 {
+			continue
+		}
+		if f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return KKResult{}, config.NewProcessingError("Kramers-Kronig test", fmt.Errorf("invalid frequency %g Hz", f))
+		}
+		if cmplx.IsNaN(z) || cmplx.IsInf(z) {
+			return KKResult{}, config.NewProcessingError("Kramers-Kronig test", fmt.Errorf("invalid impedance %v at %g Hz", z, f))
+		}
+		measured.Frequencies = append(measured.Frequencies, f)
+		measured.Impedance = append(measured.Impedance, z)
+		omega := 2 * math.Pi * f
+		omegaMin, omegaMax = math.Min(omegaMin, omega), math.Max(omegaMax, omega)
+	}
+	if len(measured.Frequencies) < 3 {
+		return KKResult{}, config.NewProcessingError("Kramers-Kronig test",
+			fmt.Errorf("%d measured points are too few", len(measured.Frequencies)))
+	}
+	if omegaMin == omegaMax {
+		return KKResult{}, config.NewProcessingError("Kramers-Kronig test", fmt.Errorf("all points are at %g Hz", measured.Frequencies[0]))
+	}
+	data = measured
+
+	// Keep the closest fit among those not overfitting, shown by negative
+	// resistances; the mu criterion is not monotonic in the element count,
+	// so every count is tried, up to one parameter less than equations
+	maxElements := min(linKKMaxElements, 2*len(data.Frequencies)-3)
+	result := KKResult{MaxResidualPercent: math.Inf(1)}
+	for elements := 1; elements <= maxElements; elements++ {
+		resistances, fit, err := linKKFit(data, omegaMin, omegaMax, elements)
+		if err != nil {
+			break // More elements are even less determined
+		}
+		if elements > 1 && linKKOverfit(resistances) <= linKKMu {
+			continue
+		}
+		residual := 0.0
+		for i, z := range data.Impedance {
+			r := (z - fit[i]) / complex(cmplx.Abs(z), 0)
+			residual = math.Max(residual, 100*math.Max(math.Abs(real(r)), math.Abs(imag(r))))
+		}
+		if residual < result.MaxResidualPercent {
+			result.Elements, result.MaxResidualPercent = elements, residual
+		}
+	}
+	if result.Elements == 0 {
+		return KKResult{}, config.NewProcessingError("Kramers-Kronig test", fmt.Errorf("no solvable fit"))
+	}
+	result.Valid = result.MaxResidualPercent <= kc.limit
+	return result, nil
+}
+
+// linKKFit fits a series resistance, a series inductance and elements RC
+// elements with time constants spaced logarithmically from 1/omegaMax to
+// 1/omegaMin. It returns the element resistances and the fitted impedance.
+func linKKFit(data signal.ImpedanceData, omegaMin, omegaMax float64, elements int) ([]float64, []complex128, error) {
+	tau := make([]float64, elements)
+	for k := range tau {
+		tau[k] = 1 / omegaMax
+		if elements > 1 {
+			tau[k] *= math.Pow(omegaMax/omegaMin, float64(k)/float64(elements-1))
+		}
+	}
+
+	// Real and imaginary parts as separate equations weighted by 1/|Z|;
+	// columns are the series resistance, the inductance and the elements
+	n := len(data.Frequencies)
+	design := make([][]float64, 2*n)
+	target := make([]float64, 2*n)
+	for i, f := range data.Frequencies {
+		omega := 2 * math.Pi * f
+		weight := 1 / cmplx.Abs(data.Impedance[i])
+		re, im := make([]float64, elements+2), make([]float64, elements+2)
+		re[0] = weight
+		im[1] = omega * weight
+		for k, t := range tau {
+			element := 1 / complex(1, omega*t)
+			re[k+2] = real(element) * weight
+			im[k+2] = imag(element) * weight
+		}
+		design[2*i], design[2*i+1] = re, im
+		target[2*i], target[2*i+1] = real(data.Impedance[i])*weight, imag(data.Impedance[i])*weight
+	}
+	parameters, err := leastSquares(design, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fitted := make([]complex128, n)
+	for i, f := range data.Frequencies {
+		omega := 2 * math.Pi * f
+		z := complex(parameters[0], omega*parameters[1])
+		for k, t := range tau {
+			z += complex(parameters[k+2], 0) / complex(1, omega*t)
+		}
+		fitted[i] = z
+	}
+	return parameters[2:], fitted, nil
+}
+
+// linKKOverfit returns the Lin-KK mu criterion of the element resistances,
+// 1 minus the ratio of negative to positive resistance. It falls from 1 as
+// the fit starts to model noise.
+func linKKOverfit(resistances []float64) float64 {
+	var positive, negative float64
+	for _, r := range resistances {
+		if r >= 0 {
+			positive += r
+		} else {
+			negative -= r
+		}
+	}
+	if positive == 0 {
+		return 0
+	}
+	return 1 - negative/positive
+}
+
+// leastSquares solves the overdetermined system a x = b in the least squares
+// sense by Householder QR decomposition. a is overwritten.
+func leastSquares(a [][]float64, b []float64) ([]float64, error) {
+	rows, cols := len(a), len(a[0])
+	b = append([]float64(nil), b...)
+	for j := 0; j < cols; j++ {
+		// Reflect column j below the diagonal onto its first element
+		norm := 0.0
+		for i := j; i < rows; i++ {
+			norm = math.Hypot(norm, a[i][j])
+		}
+		if norm == 0 {
+			return nil, fmt.Errorf("rank deficient fit at parameter %d", j)
+		}
+		if a[j][j] > 0 {
+			norm = -norm
+		}
+		v := make([]float64, rows-j)
+		for i := j; i < rows; i++ {
+			v[i-j] = a[i][j]
+		}
+		v[0] -= norm
+		vv := 0.0
+		for _, x := range v {
+			vv += x * x
+		}
+		reflect := func(column func(i int) *float64) {
+			dot := 0.0
+			for i := range v {
+				dot += v[i] * *column(i + j)
+			}
+			scale := 2 * dot / vv
+			for i := range v {
+				*column(i + j) -= scale * v[i]
+			}
+		}
+		for k := j; k < cols; k++ {
+			reflect(func(i int) *float64 { return &a[i][k] })
+		}
+		reflect(func(i int) *float64 { return &b[i] })
+	}
+
+	// Back substitution on the upper triangle
+	x := make([]float64, cols)
+	largest := 0.0
+	for j := 0; j < cols; j++ {
+		largest = math.Max(largest, math.Abs(a[j][j]))
+	}
+	for j := cols - 1; j >= 0; j-- {
+		if math.Abs(a[j][j]) <= 1e-12*largest {
+			return nil, fmt.Errorf("rank deficient fit at parameter %d", j)
+		}
+		sum := b[j]
+		for k := j + 1; k < cols; k++ {
+			sum -= a[j][k] * x[k]
+		}
+		x[j] = sum / a[j][j]
+	}
+	return x, nil
+}
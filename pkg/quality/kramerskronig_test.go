@@ -0,0 +1,86 @@
+package quality
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestLinKKChecker_CheckKramersKronig(t *testing.T) {
+	// Randles cell with a constant phase element, sampled at 5 points per decade
+	var frequencies []float64
+	for exponent := -1.0; exponent <= 4; exponent += 0.2 {
+		frequencies = append(frequencies, math.Pow(10, exponent))
+	}
+	randles := func(f float64) complex128 {
+		cpe := 1e-5 * cmplx.Pow(complex(0, 2*math.Pi*f), 0.85)
+		return 10 + 20/(1+20*cpe)
+	}
+	spectrum := func(distort func(i int, z complex128) complex128) signal.ImpedanceData {
+		data := signal.ImpedanceData{Frequencies: frequencies, Impedance: make([]complex128, len(frequencies))}
+		for i, f := range frequencies {
+			data.Impedance[i] = distort(i, randles(f))
+		}
+		return data
+	}
+
+	tests := []struct {
+		name      string
+		data      signal.ImpedanceData
+		wantValid bool
+	}{
+		{"randles cell", spectrum(func(i int, z complex128) complex128 { return z }), true},
+		{"noise of 0.1%", spectrum(func(i int, z complex128) complex128 {
+			return z * complex(1+0.001*math.Sin(float64(7*i)), 0.001*math.Cos(float64(5*i)))
+		}), true},
+		{"drifting real part", spectrum(func(i int, z complex128) complex128 {
+			return z + complex(0.25*float64(i), 0) // Resistance growing while the sweep runs
+		}), false},
+		{"conjugated high frequencies", spectrum(func(i int, z complex128) complex128 {
+			if frequencies[i] > 100 {
+				return cmplx.Conj(z)
+			}
+			return z
+		}), false},
+	}
+
+	checker, err := NewLinKKChecker(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := checker.CheckKramersKronig(tt.data)
+			if err != nil {
+				t.Fatalf("CheckKramersKronig() error = %v", err)
+			}
+			if result.Valid != tt.wantValid {
+				t.Errorf("Valid = %v with %d elements and a %.3g%% residual, want %v", result.Valid, result.Elements, result.MaxResidualPercent, tt.wantValid)
+			}
+		})
+	}
+
+	if _, err := checker.CheckKramersKronig(signal.ImpedanceData{Frequencies: []float64{1, 2}, Impedance: []complex128{1, 1}}); err == nil {
+		t.Error("CheckKramersKronig() of 2 points succeeded")
+	}
+	if _, err := NewLinKKChecker(0); err == nil {
+		t.Error("NewLinKKChecker(0) succeeded")
+	}
+}
+
+func TestLeastSquares(t *testing.T) {
+	// y = 2 + 3x sampled without noise
+	a := [][]float64{{1, 0}, {1, 1}, {1, 2}, {1, 3}}
+	x, err := leastSquares(a, []float64{2, 5, 8, 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(x[0]-2) > 1e-9 || math.Abs(x[1]-3) > 1e-9 {
+		t.Errorf("leastSquares() = %v, want [2 3]", x)
+	}
+	if _, err := leastSquares([][]float64{{1, 2}, {2, 4}, {3, 6}}, []float64{1, 2, 3}); err == nil {
+		t.Error("leastSquares() of dependent columns succeeded")
+	}
+}
@@ -0,0 +1,75 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// AmplitudeViolation is an excitation line whose amplitude exceeds the linearity limit
+type AmplitudeViolation struct {
+	Frequency float64 // Hz
+	RMS       float64 // RMS amplitude of the line in signal units
+	Limit     float64 // RMS limit it exceeds
+}
+
+// String formats the violation for log messages
+func (v AmplitudeViolation) String() string {
+	return fmt.Sprintf("%g Hz: %.4g RMS exceeds %.4g", v.Frequency, v.RMS, v.Limit)
+}
+
+// AmplitudeLimitChecker flags excitation lines whose RMS amplitude exceeds a
+// fixed limit. Electrochemical impedance is only meaningful while the cell
+// responds linearly, typically below about 10 mV RMS per frequency.
+type AmplitudeLimitChecker struct {
+	limit     float64
+	processor fft.Processor
+}
+
+// NewAmplitudeLimitChecker creates a checker with the given per-frequency RMS limit
+func NewAmplitudeLimitChecker(limitRMS float64) (LinearityChecker, error) {
+	if limitRMS <= 0 || math.IsNaN(limitRMS) || math.IsInf(limitRMS, 0) {
+		return nil, config.NewValidationError("LinearityLimit", "linearity limit must be a positive finite amplitude")
+	}
+	return &AmplitudeLimitChecker{
+		limit:     limitRMS,
+		processor: fft.NewProcessorWithMode(fft.SpectrumSingleSided),
+	}, nil
+}
+
+// CheckLinearity returns the spectral peaks of the voltage whose RMS amplitude
+// exceeds the limit, in ascending frequency order. Neighbouring leakage bins
+// are not reported separately.
+func (lc *AmplitudeLimitChecker) CheckLinearity(voltage signal.Signal) ([]AmplitudeViolation, error) {
+	spectrum, err := lc.processor.ProcessSignal(voltage)
+	if err != nil {
+		return nil, config.NewProcessingError("linearity check", err)
+	}
+	positive, err := lc.processor.GetPositiveFrequencies(spectrum)
+	if err != nil {
+		return nil, config.NewProcessingError("linearity check", err)
+	}
+
+	amplitudes := make([]float64, len(positive.Values))
+	for i, v := range positive.Values {
+		amplitudes[i] = cmplx.Abs(v)
+	}
+
+	var violations []AmplitudeViolation
+	for i := 1; i < len(amplitudes); i++ {
+		rms := amplitudes[i] / math.Sqrt2
+		if rms <= lc.limit {
+			continue
+		}
+		isPeak := (i == 1 || amplitudes[i] >= amplitudes[i-1]) && (i+1 == len(amplitudes) || amplitudes[i] >= amplitudes[i+1])
+		if !isPeak {
+			continue
+		}
+		violations = append(violations, AmplitudeViolation{Frequency: positive.Frequencies[i], RMS: rms, Limit: lc.limit})
+	}
+	return violations, nil
+}
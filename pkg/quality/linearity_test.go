@@ -0,0 +1,63 @@
+package quality
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestAmplitudeLimitChecker_CheckLinearity(t *testing.T) {
+	const sampleRate, n = 1024.0, 1024
+	multisine := func(amplitudes map[float64]float64) signal.Signal {
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = 1.0 // DC bias never counts as excitation
+			for frequency, amplitude := range amplitudes {
+				values[i] += amplitude * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate)
+			}
+		}
+		return signal.Signal{Timestamp: time.Now(), Values: values, SampleRate: sampleRate}
+	}
+
+	tests := []struct {
+		name            string
+		amplitudes      map[float64]float64 // Peak amplitudes by frequency
+		wantFrequencies []float64
+	}{
+		{"all below limit", map[float64]float64{4: 0.01, 32: 0.014}, nil},
+		{"one line above", map[float64]float64{4: 0.02, 32: 0.005}, []float64{4}},
+		{"all above", map[float64]float64{4: 0.1, 32: 0.05, 100: 0.03}, []float64{4, 32, 100}},
+	}
+
+	checker, err := NewAmplitudeLimitChecker(0.01)
+	if err != nil {
+		t.Fatalf("NewAmplitudeLimitChecker() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := checker.CheckLinearity(multisine(tt.amplitudes))
+			if err != nil {
+				t.Fatalf("CheckLinearity() error = %v", err)
+			}
+			if len(violations) != len(tt.wantFrequencies) {
+				t.Fatalf("got %d violations %v, want frequencies %v", len(violations), violations, tt.wantFrequencies)
+			}
+			for i, v := range violations {
+				if v.Frequency != tt.wantFrequencies[i] {
+					t.Errorf("violation %d at %v Hz, want %v Hz", i, v.Frequency, tt.wantFrequencies[i])
+				}
+				want := tt.amplitudes[v.Frequency] / math.Sqrt2
+				if math.Abs(v.RMS-want) > 1e-9 {
+					t.Errorf("violation %d RMS = %v, want %v", i, v.RMS, want)
+				}
+			}
+		})
+	}
+
+	if _, err := NewAmplitudeLimitChecker(0); err == nil {
+		t.Errorf("expected error for zero limit")
+	}
+}
@@ -0,0 +1,161 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// minSubWindowSamples is the shortest sub-window a chunk may be split into
+const minSubWindowSamples = 16
+
+// SpectrogramChecker splits a chunk into consecutive sub-windows, computes
+// the impedance at the excitation lines of each and reports how far it
+// drifts between them. Dynamic EIS assumes the system does not change during
+// a chunk; a cell that charges, heats or corrodes within it produces a
+// spectrum that belongs to no single state.
+type SpectrogramChecker struct {
+	windows        int
+	magnitudeLimit float64
+	phaseLimit     float64
+	processor      fft.Processor
+}
+
+// NewSpectrogramChecker creates a checker comparing windows sub-windows and
+// accepting up to magnitudeLimitPercent of |Z| range relative to its mean and
+// phaseLimitDeg of phase range at every excitation line
+func NewSpectrogramChecker(windows int, magnitudeLimitPercent, phaseLimitDeg float64) (StationarityChecker, error) {
+	if windows < 2 {
+		return nil, config.NewValidationError("StationarityWindows", "at least 2 sub-windows are needed for a stationarity check")
+	}
+	if magnitudeLimitPercent <= 0 || phaseLimitDeg <= 0 || math.IsNaN(magnitudeLimitPercent) || math.IsNaN(phaseLimitDeg) {
+		return nil, config.NewValidationError("StationarityLimit", "stationarity limits must be positive")
+	}
+	return &SpectrogramChecker{
+		windows:        windows,
+		magnitudeLimit: magnitudeLimitPercent,
+		phaseLimit:     phaseLimitDeg,
+		processor:      fft.NewProcessorWithMode(fft.SpectrumSingleSided),
+	}, nil
+}
+
+// CheckStationarity returns the impedance drift between the sub-windows of
+// the chunk. The excitation lines are the peaks of the sub-window voltage
+// spectra reaching excitationThreshold of the strongest; they must lie on
+// the coarser bins of a sub-window to be found.
+func (sc *SpectrogramChecker) CheckStationarity(voltage, current signal.Signal) (signal.Stationarity, error) {
+	if err := signal.ValidateSignalsMatch(voltage, current); err != nil {
+		return signal.Stationarity{}, config.NewProcessingError("stationarity check", err)
+	}
+	size := len(voltage.Values) / sc.windows
+	if size < minSubWindowSamples {
+		return signal.Stationarity{}, config.NewProcessingError("stationarity check",
+			fmt.Errorf("%d samples are too few for %d sub-windows of at least %d", len(voltage.Values), sc.windows, minSubWindowSamples))
+	}
+
+	// Spectrogram of both channels, one row per sub-window
+	voltageRows := make([]signal.ComplexSignal, sc.windows)
+	currentRows := make([]signal.ComplexSignal, sc.windows)
+	for k := range sc.windows {
+		var err error
+		if voltageRows[k], err = sc.spectrum(voltage, k*size, size); err != nil {
+			return signal.Stationarity{}, config.NewProcessingError("stationarity check", err)
+		}
+		if currentRows[k], err = sc.spectrum(current, k*size, size); err != nil {
+			return signal.Stationarity{}, config.NewProcessingError("stationarity check", err)
+		}
+	}
+
+	result := signal.Stationarity{Windows: sc.windows}
+	for _, bin := range excitationBins(voltageRows) {
+		magnitudes := make([]float64, sc.windows)
+		phases := make([]float64, sc.windows)
+		usable := true
+		for k := range sc.windows {
+			if currentRows[k].Values[bin] == 0 {
+				usable = false
+				break
+			}
+			z := voltageRows[k].Values[bin] / currentRows[k].Values[bin]
+			magnitudes[k] = cmplx.Abs(z)
+			phases[k] = cmplx.Phase(z)
+		}
+		if !usable {
+			continue
+		}
+		result.MagnitudeDriftPercent = math.Max(result.MagnitudeDriftPercent, relativeRange(magnitudes)*100)
+		result.PhaseDriftDeg = math.Max(result.PhaseDriftDeg, phaseRange(phases)*180/math.Pi)
+	}
+	result.Stationary = result.MagnitudeDriftPercent <= sc.magnitudeLimit && result.PhaseDriftDeg <= sc.phaseLimit
+	return result, nil
+}
+
+// spectrum returns the positive frequencies of size samples of sig from start
+func (sc *SpectrogramChecker) spectrum(sig signal.Signal, start, size int) (signal.ComplexSignal, error) {
+	window := sig
+	window.Values = sig.Values[start : start+size]
+	spectrum, err := sc.processor.ProcessSignal(window)
+	if err != nil {
+		return signal.ComplexSignal{}, err
+	}
+	return sc.processor.GetPositiveFrequencies(spectrum)
+}
+
+// excitationBins returns the non-DC bins that are peaks of the mean
+// magnitude of the rows and reach excitationThreshold of the strongest
+func excitationBins(rows []signal.ComplexSignal) []int {
+	magnitudes := make([]float64, len(rows[0].Values))
+	for _, row := range rows {
+		for i, v := range row.Values {
+			magnitudes[i] += cmplx.Abs(v) / float64(len(rows))
+		}
+	}
+	strongest := 0.0
+	for i := 1; i < len(magnitudes); i++ {
+		strongest = math.Max(strongest, magnitudes[i])
+	}
+
+	var bins []int
+	for i := 1; i < len(magnitudes); i++ {
+		isPeak := magnitudes[i] >= magnitudes[i-1] && (i+1 == len(magnitudes) || magnitudes[i] >= magnitudes[i+1])
+		if strongest > 0 && isPeak && magnitudes[i] >= excitationThreshold*strongest {
+			bins = append(bins, i)
+		}
+	}
+	return bins
+}
+
+// relativeRange returns the range of values divided by their mean
+func relativeRange(values []float64) float64 {
+	lo, hi, mean := values[0], values[0], 0.0
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+		mean += v / float64(len(values))
+	}
+	if mean == 0 {
+		return 0
+	}
+	return (hi - lo) / mean
+}
+
+// phaseRange returns the range of angles in radians, measured around their
+// circular mean so that angles either side of ±π are not a full turn apart
+func phaseRange(phases []float64) float64 {
+	var sum complex128
+	for _, p := range phases {
+		sum += cmplx.Rect(1, p)
+	}
+	center := cmplx.Phase(sum)
+	lo, hi := 0.0, 0.0
+	for _, p := range phases {
+		d := math.Remainder(p-center, 2*math.Pi)
+		lo = math.Min(lo, d)
+		hi = math.Max(hi, d)
+	}
+	return hi - lo
+}
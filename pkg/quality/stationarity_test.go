@@ -0,0 +1,76 @@
+package quality
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSpectrogramChecker_CheckStationarity(t *testing.T) {
+	const sampleRate, n = 1024.0, 1024
+	frequencies := []float64{8, 32} // On the 4 Hz bins of 256-sample sub-windows
+	// Current through an impedance whose magnitude and phase at time t are
+	// given by the functions
+	chunk := func(magnitude, phase func(t float64) float64) (signal.Signal, signal.Signal) {
+		voltage := make([]float64, n)
+		current := make([]float64, n)
+		for i := range voltage {
+			t := float64(i) / sampleRate
+			for _, f := range frequencies {
+				voltage[i] += math.Sin(2 * math.Pi * f * t)
+				current[i] += math.Sin(2*math.Pi*f*t-phase(t)) / magnitude(t)
+			}
+		}
+		now := time.Now()
+		return signal.Signal{Timestamp: now, Values: voltage, SampleRate: sampleRate},
+			signal.Signal{Timestamp: now, Values: current, SampleRate: sampleRate}
+	}
+	constant := func(v float64) func(float64) float64 { return func(float64) float64 { return v } }
+
+	tests := []struct {
+		name           string
+		magnitude      func(t float64) float64
+		phase          func(t float64) float64
+		wantStationary bool
+	}{
+		{"constant impedance", constant(10), constant(-0.3), true},
+		{"charging cell", func(t float64) float64 { return 10 + 4*t }, constant(-0.3), false},
+		{"phase drift", constant(10), func(t float64) float64 { return -0.3 + 0.3*t }, false},
+	}
+
+	checker, err := NewSpectrogramChecker(4, 5, 3)
+	if err != nil {
+		t.Fatalf("NewSpectrogramChecker() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voltage, current := chunk(tt.magnitude, tt.phase)
+			stationarity, err := checker.CheckStationarity(voltage, current)
+			if err != nil {
+				t.Fatalf("CheckStationarity() error = %v", err)
+			}
+			if stationarity.Stationary != tt.wantStationary {
+				t.Errorf("Stationary = %v, want %v (%+v)", stationarity.Stationary, tt.wantStationary, stationarity)
+			}
+			if stationarity.Windows != 4 {
+				t.Errorf("Windows = %d, want 4", stationarity.Windows)
+			}
+		})
+	}
+
+	short := signal.Signal{Timestamp: time.Now(), Values: make([]float64, 32), SampleRate: sampleRate}
+	if _, err := checker.CheckStationarity(short, short); err == nil {
+		t.Error("CheckStationarity() accepted sub-windows of 8 samples")
+	}
+	if _, err := NewSpectrogramChecker(1, 5, 3); err == nil {
+		t.Error("NewSpectrogramChecker() accepted a single sub-window")
+	}
+}
+
+func TestPhaseRange_WrapsAroundPi(t *testing.T) {
+	if got := phaseRange([]float64{math.Pi - 0.01, -math.Pi + 0.01}); math.Abs(got-0.02) > 1e-9 {
+		t.Errorf("phaseRange() = %v, want 0.02", got)
+	}
+}
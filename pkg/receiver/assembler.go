@@ -0,0 +1,97 @@
+package receiver
+
+import (
+	"math"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// sampleAssembler cuts voltage and current samples that arrive separately,
+// e.g. as updates of two device variables, into pairs of equally long signal
+// chunks. Both streams are aligned on their first sample timestamps, so a
+// stream that started earlier loses its leading samples.
+type sampleAssembler struct {
+	sampleRate   float64
+	chunk        int
+	voltage      []float64
+	current      []float64
+	voltageStart time.Time // Timestamp of voltage[0]
+	currentStart time.Time // Timestamp of current[0]
+}
+
+// newSampleAssembler creates an assembler emitting chunks of chunk samples
+func newSampleAssembler(sampleRate float64, chunk int) *sampleAssembler {
+	return &sampleAssembler{sampleRate: sampleRate, chunk: chunk}
+}
+
+// addVoltage appends voltage samples, the first one taken at t
+func (a *sampleAssembler) addVoltage(t time.Time, values ...float64) {
+	if len(a.voltage) == 0 {
+		a.voltageStart = t
+	}
+	a.voltage = append(a.voltage, values...)
+}
+
+// addCurrent appends current samples, the first one taken at t
+func (a *sampleAssembler) addCurrent(t time.Time, values ...float64) {
+	if len(a.current) == 0 {
+		a.currentStart = t
+	}
+	a.current = append(a.current, values...)
+}
+
+// reset discards all buffered samples, e.g. after a connection loss
+func (a *sampleAssembler) reset() {
+	a.voltage = a.voltage[:0]
+	a.current = a.current[:0]
+}
+
+// next returns the next complete chunk pair, if both streams hold one
+func (a *sampleAssembler) next() (signal.Signal, signal.Signal, bool) {
+	a.align()
+	if len(a.voltage) < a.chunk || len(a.current) < a.chunk {
+		return signal.Signal{}, signal.Signal{}, false
+	}
+
+	voltage := signal.Signal{
+		Timestamp:  a.voltageStart,
+		Values:     append([]float64(nil), a.voltage[:a.chunk]...),
+		SampleRate: a.sampleRate,
+		Metadata:   signal.Metadata{Unit: signal.UnitVolt},
+	}
+	current := signal.Signal{
+		Timestamp:  a.voltageStart,
+		Values:     append([]float64(nil), a.current[:a.chunk]...),
+		SampleRate: a.sampleRate,
+		Metadata:   signal.Metadata{Unit: signal.UnitAmpere},
+	}
+	a.voltage = append(a.voltage[:0], a.voltage[a.chunk:]...)
+	a.current = append(a.current[:0], a.current[a.chunk:]...)
+	a.voltageStart = a.voltageStart.Add(a.duration(a.chunk))
+	a.currentStart = a.currentStart.Add(a.duration(a.chunk))
+	return voltage, current, true
+}
+
+// align drops the samples one stream holds from before the other one started
+func (a *sampleAssembler) align() {
+	if len(a.voltage) == 0 || len(a.current) == 0 {
+		return
+	}
+	offset := int(math.Round(a.currentStart.Sub(a.voltageStart).Seconds() * a.sampleRate))
+	switch {
+	case offset > 0:
+		drop := min(offset, len(a.voltage))
+		a.voltage = append(a.voltage[:0], a.voltage[drop:]...)
+		a.voltageStart = a.voltageStart.Add(a.duration(drop))
+	case offset < 0:
+		drop := min(-offset, len(a.current))
+		a.current = append(a.current[:0], a.current[drop:]...)
+		a.currentStart = a.currentStart.Add(a.duration(drop))
+	}
+}
+
+// duration returns the time n samples span
+func (a *sampleAssembler) duration(n int) time.Duration {
+	return time.Duration(float64(n) / a.sampleRate * float64(time.Second))
+}
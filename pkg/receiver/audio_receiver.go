@@ -0,0 +1,354 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Audio sample formats, all little-endian
+const (
+	AudioS16   = "s16"
+	AudioS24   = "s24" // Packed in 3 bytes
+	AudioS32   = "s32"
+	AudioFloat = "float" // 32-bit IEEE float
+)
+
+// AudioOptions selects the capture device, format and calibration of an AudioReceiver
+type AudioOptions struct {
+	Device         string   // Capture device, e.g. "hw:1,0" for ALSA; empty = default device
+	Command        []string // Capture command writing interleaved raw PCM to stdout; empty = arecord (Linux) or sox (macOS)
+	Format         string   // Sample format: s16, s24, s32 or float
+	SampleRate     float64  // Frames per second
+	Channels       int      // Interleaved channels delivered by the device
+	VoltageChannel int      // Zero-based channel carrying the voltage
+	CurrentChannel int      // Zero-based channel carrying the current
+	VoltageScale   float64  // Volts at digital full scale (1.0)
+	CurrentScale   float64  // Amperes at digital full scale (1.0)
+	ChunkSamples   int      // Frames per emitted signal
+}
+
+// audioSampleBytes returns the bytes of one sample of format, or 0 for
+// unknown formats
+func audioSampleBytes(format string) int {
+	switch format {
+	case AudioS16:
+		return 2
+	case AudioS24:
+		return 3
+	case AudioS32, AudioFloat:
+		return 4
+	}
+	return 0
+}
+
+// decodeAudioSample returns one sample normalized to full scale [-1, 1)
+func decodeAudioSample(b []byte, format string) float64 {
+	switch format {
+	case AudioS16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case AudioS24:
+		raw := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(raw) / (1 << 23)
+	case AudioS32:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+	return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+}
+
+// AudioReceiver captures two channels of a sound interface in real time, as
+// used by low-budget EIS rigs with audio ADCs. A capture command such as
+// arecord or sox streams interleaved raw PCM, which is cut into chunks and
+// calibrated from full scale to volts and amperes. The capture is restarted
+// with backoff if the command exits.
+type AudioReceiver struct {
+	options        AudioOptions
+	open           func(ctx context.Context) (io.ReadCloser, error)
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	lifecycle      *lifecycle
+	stats          statsTracker
+	mu             sync.Mutex
+	stream         io.ReadCloser
+}
+
+// NewAudioReceiver creates a receiver capturing with the configured command
+func NewAudioReceiver(options AudioOptions) (*AudioReceiver, error) {
+	if err := validateAudioOptions(options); err != nil {
+		return nil, err
+	}
+	if len(options.Command) == 0 {
+		command, err := defaultAudioCommand(options)
+		if err != nil {
+			return nil, err
+		}
+		options.Command = command
+	}
+	ar := newAudioReceiver(options)
+	ar.open = ar.startCommand
+	return ar, nil
+}
+
+func newAudioReceiver(options AudioOptions) *AudioReceiver {
+	return &AudioReceiver{
+		options:        options,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		lifecycle:      newLifecycle(),
+	}
+}
+
+// validateAudioOptions checks the format, channel layout and calibration
+func validateAudioOptions(options AudioOptions) error {
+	if audioSampleBytes(options.Format) == 0 {
+		return config.NewValidationError("AudioFormat", fmt.Sprintf("unknown sample format '%s'", options.Format))
+	}
+	if options.SampleRate <= 0 {
+		return config.NewValidationError("AudioSampleRate", "sample rate must be positive")
+	}
+	if options.Channels < 2 {
+		return config.NewValidationError("AudioChannels", "at least two channels are required")
+	}
+	for _, channel := range []int{options.VoltageChannel, options.CurrentChannel} {
+		if channel < 0 || channel >= options.Channels {
+			return config.NewValidationError("AudioChannel", fmt.Sprintf("channel %d outside of the %d captured channels", channel, options.Channels))
+		}
+	}
+	if options.VoltageChannel == options.CurrentChannel {
+		return config.NewValidationError("AudioChannel", "voltage and current must use different channels")
+	}
+	if options.VoltageScale == 0 || options.CurrentScale == 0 {
+		return config.NewValidationError("AudioScale", "calibration scales cannot be zero")
+	}
+	if options.ChunkSamples <= 0 {
+		return config.NewValidationError("ChunkSamples", "chunk size must be positive")
+	}
+	return nil
+}
+
+// defaultAudioCommand builds the arecord (ALSA) or sox (CoreAudio) invocation
+// writing raw interleaved PCM of the configured format to stdout
+func defaultAudioCommand(options AudioOptions) ([]string, error) {
+	rate := strconv.FormatFloat(options.SampleRate, 'f', -1, 64)
+	channels := strconv.Itoa(options.Channels)
+	switch runtime.GOOS {
+	case "linux":
+		formats := map[string]string{AudioS16: "S16_LE", AudioS24: "S24_3LE", AudioS32: "S32_LE", AudioFloat: "FLOAT_LE"}
+		command := []string{"arecord", "-q", "-t", "raw", "-f", formats[options.Format], "-c", channels, "-r", rate}
+		if options.Device != "" {
+			command = append(command, "-D", options.Device)
+		}
+		return command, nil
+	case "darwin":
+		bits := strconv.Itoa(8 * audioSampleBytes(options.Format))
+		encoding := "signed-integer"
+		if options.Format == AudioFloat {
+			encoding = "floating-point"
+		}
+		input := []string{"-d"}
+		if options.Device != "" {
+			input = []string{"-t", "coreaudio", options.Device}
+		}
+		command := append([]string{"sox", "-q"}, input...)
+		return append(command, "-t", "raw", "-b", bits, "-e", encoding, "-L", "-c", channels, "-r", rate, "-"), nil
+	}
+	return nil, config.NewValidationError("AudioCommand", fmt.Sprintf("no default capture command on %s, set one explicitly", runtime.GOOS))
+}
+
+// startCommand starts the capture command and returns its stdout; closing it
+// stops the command and reports its stderr if it failed
+func (ar *AudioReceiver) startCommand(ctx context.Context) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, ar.options.Command[0], ar.options.Command[1:]...)
+	stderr := &tailBuffer{limit: 2048}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	log.Printf("Started audio capture: %s", strings.Join(ar.options.Command, " "))
+	return &commandStream{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// commandStream is the stdout of a running command
+type commandStream struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *tailBuffer
+	once   sync.Once
+	err    error
+}
+
+// Close stops the command and waits for it to exit; repeated calls return
+// the result of the first
+func (cs *commandStream) Close() error {
+	cs.once.Do(func() {
+		cs.ReadCloser.Close()
+		cs.cmd.Process.Kill()
+		if err := cs.cmd.Wait(); err != nil && cs.stderr.Len() > 0 {
+			cs.err = fmt.Errorf("%w: %s", err, strings.TrimSpace(cs.stderr.String()))
+		}
+	})
+	return cs.err
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   bytes.Buffer
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.buf.Write(p)
+	if excess := tb.buf.Len() - tb.limit; excess > 0 {
+		tb.buf.Next(excess)
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) Len() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return tb.buf.Len()
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return tb.buf.String()
+}
+
+// StartReceiving captures signal pairs until ctx is cancelled or Stop is
+// called. The signal channels are closed when it returns.
+func (ar *AudioReceiver) StartReceiving(ctx context.Context) error {
+	defer ar.lifecycle.closeChannels(ar.voltageChannel, ar.currentChannel)
+
+	ar.stats.start(0, time.Duration(float64(ar.options.ChunkSamples)/ar.options.SampleRate*float64(time.Second)))
+	defer ar.stats.stop()
+	log.Printf("Starting audio capture at %g Hz (%s, voltage on channel %d, current on channel %d)",
+		ar.options.SampleRate, ar.options.Format, ar.options.VoltageChannel, ar.options.CurrentChannel)
+
+	return reconnect(ctx, ar.lifecycle, "audio capture", func() error {
+		return ar.capture(ctx)
+	})
+}
+
+// capture reads chunks from one capture stream until it ends or fails
+func (ar *AudioReceiver) capture(ctx context.Context) error {
+	stream, err := ar.open(ctx)
+	if err != nil {
+		return err
+	}
+	if !ar.attach(stream) {
+		stream.Close()
+		return nil
+	}
+
+	sampleBytes := audioSampleBytes(ar.options.Format)
+	frameBytes := sampleBytes * ar.options.Channels
+	chunk := make([]byte, frameBytes*ar.options.ChunkSamples)
+	started := time.Now()
+	frames := 0
+	for {
+		_, err = io.ReadFull(stream, chunk)
+		if err != nil {
+			break
+		}
+		timestamp := started.Add(time.Duration(float64(frames) / ar.options.SampleRate * float64(time.Second)))
+		frames += ar.options.ChunkSamples
+
+		voltage := make([]float64, ar.options.ChunkSamples)
+		current := make([]float64, ar.options.ChunkSamples)
+		for i := range voltage {
+			frame := chunk[i*frameBytes:]
+			voltage[i] = decodeAudioSample(frame[ar.options.VoltageChannel*sampleBytes:], ar.options.Format) * ar.options.VoltageScale
+			current[i] = decodeAudioSample(frame[ar.options.CurrentChannel*sampleBytes:], ar.options.Format) * ar.options.CurrentScale
+		}
+		emitPair(ar.voltageChannel, ar.currentChannel,
+			signal.Signal{Timestamp: timestamp, Values: voltage, SampleRate: ar.options.SampleRate, Metadata: signal.Metadata{Unit: signal.UnitVolt}},
+			signal.Signal{Timestamp: timestamp, Values: current, SampleRate: ar.options.SampleRate, Metadata: signal.Metadata{Unit: signal.UnitAmpere}},
+			&ar.stats)
+		ar.stats.advance()
+	}
+
+	closeErr := ar.detach(stream)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errors.New("capture stream ended")
+	}
+	if closeErr != nil {
+		err = closeErr
+	}
+	return err
+}
+
+// attach makes stream the active capture unless the receiver has stopped
+func (ar *AudioReceiver) attach(stream io.ReadCloser) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	select {
+	case <-ar.lifecycle.stopping():
+		return false
+	default:
+	}
+	ar.stream = stream
+	return true
+}
+
+// detach closes stream
+func (ar *AudioReceiver) detach(stream io.ReadCloser) error {
+	ar.mu.Lock()
+	ar.stream = nil
+	ar.mu.Unlock()
+	return stream.Close()
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (ar *AudioReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return ar.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (ar *AudioReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return ar.currentChannel
+}
+
+// Done returns nil: live capture never runs out of data
+func (ar *AudioReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (ar *AudioReceiver) Stats() Stats {
+	return ar.stats.snapshot()
+}
+
+// Stop asks the receiver to stop and ends the capture; the channels are
+// closed once StartReceiving returns. It is safe to call more than once.
+func (ar *AudioReceiver) Stop() error {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.lifecycle.stop()
+	if ar.stream != nil {
+		ar.stream.Close()
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package receiver
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestAudioReceiver_CaptureCommand(t *testing.T) {
+	// Three channels: current, unused, voltage
+	var pcm []byte
+	for i := 0; i < 8; i++ {
+		for _, sample := range []int16{int16(-1024 * i), 12345, int16(4096 * i)} {
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(sample))
+		}
+	}
+	path := filepath.Join(t.TempDir(), "capture.raw")
+	if err := os.WriteFile(path, pcm, 0o644); err != nil {
+		t.Fatalf("write capture: %v", err)
+	}
+
+	receiver, err := NewAudioReceiver(AudioOptions{
+		Command:        []string{"cat", path},
+		Format:         AudioS16,
+		SampleRate:     48000,
+		Channels:       3,
+		VoltageChannel: 2,
+		CurrentChannel: 0,
+		VoltageScale:   2,    // 2 V at full scale
+		CurrentScale:   0.01, // 10 mA at full scale
+		ChunkSamples:   4,
+	})
+	if err != nil {
+		t.Fatalf("NewAudioReceiver() error = %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+
+	var voltages, currents []signal.Signal
+	for len(voltages) < 2 {
+		select {
+		case v := <-receiver.GetVoltageChannel():
+			voltages = append(voltages, v)
+			currents = append(currents, <-receiver.GetCurrentChannel())
+		case <-time.After(5 * time.Second):
+			t.Fatal("no signal pair received")
+		}
+	}
+	receiver.Stop()
+	if err := <-result; err != nil {
+		t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+	}
+
+	if !reflect.DeepEqual(voltages[1].Values, []float64{1, 1.25, 1.5, 1.75}) {
+		t.Errorf("second voltage chunk = %v, want samples 4-7 of channel 2 scaled to 2 V", voltages[1].Values)
+	}
+	if !reflect.DeepEqual(currents[0].Values, []float64{0, -0.0003125, -0.000625, -0.0009375}) {
+		t.Errorf("first current chunk = %v, want samples 0-3 of channel 0 scaled to 10 mA", currents[0].Values)
+	}
+	if gap := voltages[1].Timestamp.Sub(voltages[0].Timestamp); gap != 4*time.Second/48000 {
+		t.Errorf("chunk timestamps %v apart, want 4 frames", gap)
+	}
+	if voltages[0].SampleRate != 48000 || voltages[0].Metadata.Unit != signal.UnitVolt || currents[0].Metadata.Unit != signal.UnitAmpere {
+		t.Errorf("signal = %v Hz %q/%q, want 48000 Hz V/A", voltages[0].SampleRate, voltages[0].Metadata.Unit, currents[0].Metadata.Unit)
+	}
+}
+
+func TestDecodeAudioSample(t *testing.T) {
+	tests := []struct {
+		format string
+		bytes  []byte
+		want   float64
+	}{
+		{AudioS16, []byte{0x00, 0x80}, -1},
+		{AudioS16, []byte{0x00, 0x40}, 0.5},
+		{AudioS24, []byte{0x00, 0x00, 0xC0}, -0.5},
+		{AudioS24, []byte{0x00, 0x00, 0x20}, 0.25},
+		{AudioS32, []byte{0x00, 0x00, 0x00, 0x80}, -1},
+		{AudioFloat, binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.125)), -0.125},
+	}
+	for _, tt := range tests {
+		if got := decodeAudioSample(tt.bytes, tt.format); got != tt.want {
+			t.Errorf("decodeAudioSample(% X, %s) = %v, want %v", tt.bytes, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package receiver
+
+import (
+	"sync"
+)
+
+// completion signals that a receiver has delivered all of its input
+type completion struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+// newCompletion creates a pending completion
+func newCompletion() *completion {
+	return &completion{ch: make(chan struct{})}
+}
+
+// done returns a channel that is closed once complete has been called
+func (c *completion) done() <-chan struct{} {
+	return c.ch
+}
+
+// complete marks the input as exhausted; repeated calls are ignored
+func (c *completion) complete() {
+	c.once.Do(func() { close(c.ch) })
+}
@@ -0,0 +1,91 @@
+package receiver
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// RateMismatchPolicy selects what happens when the timestamps of file data
+// disagree with the configured sample rate
+type RateMismatchPolicy string
+
+const (
+	// RateMismatchWarn logs the mismatch and keeps the configured rate
+	RateMismatchWarn RateMismatchPolicy = "warn"
+	// RateMismatchCorrect loads the files with the estimated rate
+	RateMismatchCorrect RateMismatchPolicy = "correct"
+	// RateMismatchAbort refuses to load the files
+	RateMismatchAbort RateMismatchPolicy = "abort"
+)
+
+// CheckFileSampleRate compares sampleRate with the rates estimated by
+// signal.InspectFile from the timestamps of the voltage and current files
+// and returns the rate to load the files with. A relative difference up to
+// tolerance is accepted as is. Files without usable timestamps cannot be
+// checked and keep sampleRate. A correction needs the estimates of both
+// files to agree within tolerance, since they are loaded with one rate.
+func CheckFileSampleRate(voltageFile, currentFile string, sampleRate float64, dialect signal.CSVDialect, tolerance float64, policy RateMismatchPolicy) (float64, error) {
+	var estimates, mismatchRates []float64
+	var mismatches []string
+	for _, file := range []string{voltageFile, currentFile} {
+		report, err := signal.InspectFile(file, &dialect)
+		if err != nil {
+			return sampleRate, fmt.Errorf("failed to inspect %s: %w", file, err)
+		}
+		estimated := report.EstimatedSampleRate
+		if estimated <= 0 {
+			log.Printf("Sample rate of %s cannot be estimated from its timestamps, using %g Hz", file, sampleRate)
+			continue
+		}
+		estimates = append(estimates, estimated)
+		deviation := math.Abs(estimated-sampleRate) / sampleRate
+		if deviation > tolerance {
+			mismatches = append(mismatches, fmt.Sprintf("timestamps of %s give %.6g Hz, %.1f%% off the configured %g Hz", file, estimated, 100*deviation, sampleRate))
+			mismatchRates = append(mismatchRates, estimated)
+		}
+	}
+	if len(mismatches) == 0 {
+		return sampleRate, nil
+	}
+	mismatch := strings.Join(mismatches, "; ")
+
+	switch policy {
+	case RateMismatchCorrect:
+		if len(estimates) == 2 && math.Abs(estimates[1]-estimates[0]) > tolerance*estimates[0] {
+			return sampleRate, config.NewValidationError("SampleRate", fmt.Sprintf("%s; the files disagree, so no single rate corrects both", mismatch))
+		}
+		log.Printf("Warning: %s; using the estimated rate", mismatch)
+		return signal.RoundSampleRate(estimates[0]), nil
+	case RateMismatchAbort:
+		return sampleRate, config.NewValidationError("SampleRate", mismatch)
+	default:
+		for i, m := range mismatches {
+			log.Printf("Warning: %s; its frequency axis is scaled by %.4g", m, sampleRate/mismatchRates[i])
+		}
+		return sampleRate, nil
+	}
+}
+
+// DetectFileSampleRate derives the sample rate of file data from the
+// timestamps of the voltage and current files with signal.DetectSampleRate.
+// Both must be regular and agree within the relative tolerance; the voltage
+// file's rate is returned.
+func DetectFileSampleRate(voltageFile, currentFile string, dialect signal.CSVDialect, tolerance float64) (float64, error) {
+	voltageRate, err := signal.DetectSampleRate(voltageFile, &dialect)
+	if err != nil {
+		return 0, err
+	}
+	currentRate, err := signal.DetectSampleRate(currentFile, &dialect)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(currentRate-voltageRate) > tolerance*voltageRate {
+		return 0, config.NewValidationError("SampleRate", fmt.Sprintf("timestamps of %s give %.6g Hz but those of %s give %.6g Hz", voltageFile, voltageRate, currentFile, currentRate))
+	}
+	return voltageRate, nil
+}
@@ -0,0 +1,110 @@
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// writeRateCSV writes rows samples spaced 1/rate seconds apart in the
+// timestamp,time_offset,<column> layout and returns the path
+func writeRateCSV(t *testing.T, column string, rate float64, rows int) string {
+	t.Helper()
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	fmt.Fprintf(&b, "timestamp,time_offset,%s\n", column)
+	for i := 0; i < rows; i++ {
+		offset := float64(i) / rate
+		fmt.Fprintf(&b, "%s,%.6f,%.3f\n", start.Add(time.Duration(offset*float64(time.Second))).Format(time.RFC3339Nano), offset, float64(i%7))
+	}
+	path := filepath.Join(t.TempDir(), column+".csv")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckFileSampleRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		voltageRate float64
+		currentRate float64
+		rows        int
+		rate        float64
+		tolerance   float64
+		policy      RateMismatchPolicy
+		want        float64
+		wantErr     bool
+	}{
+		{name: "matching rate", voltageRate: 1000, currentRate: 1000, rows: 50, rate: 1000, tolerance: 0.01, policy: RateMismatchAbort, want: 1000},
+		{name: "within tolerance", voltageRate: 1000, currentRate: 1000, rows: 50, rate: 1005, tolerance: 0.01, policy: RateMismatchAbort, want: 1005},
+		{name: "warn keeps configured rate", voltageRate: 1000, currentRate: 1000, rows: 50, rate: 200000, tolerance: 0.01, policy: RateMismatchWarn, want: 200000},
+		{name: "correct uses estimate", voltageRate: 1000, currentRate: 1000, rows: 50, rate: 200000, tolerance: 0.01, policy: RateMismatchCorrect, want: 1000},
+		{name: "abort fails", voltageRate: 1000, currentRate: 1000, rows: 50, rate: 200000, tolerance: 0.01, policy: RateMismatchAbort, want: 200000, wantErr: true},
+		{name: "current file off aborts", voltageRate: 1000, currentRate: 500, rows: 50, rate: 1000, tolerance: 0.01, policy: RateMismatchAbort, want: 1000, wantErr: true},
+		{name: "current file off warns", voltageRate: 1000, currentRate: 500, rows: 50, rate: 1000, tolerance: 0.01, policy: RateMismatchWarn, want: 1000},
+		{name: "disagreeing files cannot be corrected", voltageRate: 1000, currentRate: 500, rows: 50, rate: 1000, tolerance: 0.01, policy: RateMismatchCorrect, want: 1000, wantErr: true},
+		{name: "single sample cannot be checked", voltageRate: 1000, currentRate: 1000, rows: 1, rate: 200000, tolerance: 0.01, policy: RateMismatchAbort, want: 200000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voltage := writeRateCSV(t, "voltage", tt.voltageRate, tt.rows)
+			current := writeRateCSV(t, "current", tt.currentRate, tt.rows)
+
+			got, err := CheckFileSampleRate(voltage, current, tt.rate, signal.DefaultCSVDialect, tt.tolerance, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckFileSampleRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var validationErr config.ValidationError
+			if tt.wantErr && !errors.As(err, &validationErr) {
+				t.Errorf("CheckFileSampleRate() error = %T, want config.ValidationError", err)
+			}
+			if diff := got - tt.want; diff > 1e-6*tt.want || diff < -1e-6*tt.want {
+				t.Errorf("CheckFileSampleRate() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFileSampleRate_MissingFile(t *testing.T) {
+	current := writeRateCSV(t, "current", 1000, 10)
+	if _, err := CheckFileSampleRate(filepath.Join(t.TempDir(), "missing.csv"), current, 1000, signal.DefaultCSVDialect, 0.01, RateMismatchWarn); err == nil {
+		t.Error("CheckFileSampleRate() expected error for a missing voltage file")
+	}
+}
+
+func TestDetectFileSampleRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		voltageRate float64
+		currentRate float64
+		want        float64
+		wantErr     bool
+	}{
+		{name: "files agree", voltageRate: 1000, currentRate: 1000, want: 1000},
+		{name: "files disagree", voltageRate: 1000, currentRate: 500, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voltage := writeRateCSV(t, "voltage", tt.voltageRate, 50)
+			current := writeRateCSV(t, "current", tt.currentRate, 50)
+
+			got, err := DetectFileSampleRate(voltage, current, signal.DefaultCSVDialect, 0.01)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectFileSampleRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFileSampleRate() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package receiver
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -18,16 +19,22 @@ type FileReceiver struct {
 	sampleRate       float64
 	validator        signal.Validator
 	loader           signal.DataLoader
-	running          bool
+	lifecycle        *lifecycle
 	voltageSignals   []signal.Signal
 	currentSignals   []signal.Signal
 	currentIndex     int
+	stats            statsTracker
+	completion       *completion
 }
 
-// NewFileReceiver creates a new file-based data receiver
+// NewFileReceiver creates a new file-based data receiver for comma separated files
 func NewFileReceiver(voltageFile, currentFile string, sampleRate float64) (DataReceiver, error) {
-	loader := signal.NewDataLoader()
-	validator := signal.NewValidator()
+	return NewFileReceiverWithOptions(voltageFile, currentFile, sampleRate, signal.LoaderOptions{Dialect: signal.DefaultCSVDialect})
+}
+
+// NewFileReceiverWithOptions creates a new file-based data receiver using the given CSV dialect and parse mode
+func NewFileReceiverWithOptions(voltageFile, currentFile string, sampleRate float64, options signal.LoaderOptions) (DataReceiver, error) {
+	loader := signal.NewDataLoaderWithOptions(options)
 
 	// Pre-load all signals from files
 	voltageSignals, currentSignals, err := loader.LoadVoltageAndCurrentFromCSV(voltageFile, currentFile, sampleRate)
@@ -36,30 +43,96 @@ func NewFileReceiver(voltageFile, currentFile string, sampleRate float64) (DataR
 	}
 
 	log.Printf("Loaded %d signal pairs from files", len(voltageSignals))
+	logParseReports(loader)
 	
 	// Get data info for logging
-	info, err := signal.GetDataInfo(voltageFile, currentFile)
+	info, err := signal.GetDataInfoWithDialect(voltageFile, currentFile, options.Dialect)
 	if err == nil {
 		log.Printf("Data info: %+v", info)
 	}
 
+	return newFileReceiver(voltageFile, currentFile, sampleRate, loader, voltageSignals, currentSignals), nil
+}
+
+// NewElectrodeFileReceiver creates a file-based data receiver for multi-electrode
+// cells. The electrode file holds the potential of every terminal; each chunk
+// of the current file is emitted once per pair, with the pair's potential
+// difference as voltage.
+func NewElectrodeFileReceiver(electrodeFile, currentFile string, pairs []signal.ElectrodePair, sampleRate float64, options signal.LoaderOptions) (DataReceiver, error) {
+	if len(pairs) == 0 {
+		return nil, config.NewValidationError("ElectrodePairs", "at least one electrode pair is required")
+	}
+	loader := signal.NewDataLoaderWithOptions(options)
+
+	electrodeSignals, err := loader.LoadElectrodeSignalsFromCSV(electrodeFile, sampleRate)
+	if err != nil {
+		return nil, config.NewProcessingError("electrode loading", err)
+	}
+	currents, err := loader.LoadSignalFromCSV(currentFile, sampleRate)
+	if err != nil {
+		return nil, config.NewProcessingError("current loading", err)
+	}
+	if len(electrodeSignals) != len(currents) {
+		return nil, config.NewValidationError("DataLength",
+			fmt.Sprintf("electrode potentials and current must have same number of signals: got %d and %d", len(electrodeSignals), len(currents)))
+	}
+
+	voltageSignals := make([]signal.Signal, 0, len(currents)*len(pairs))
+	currentSignals := make([]signal.Signal, 0, len(currents)*len(pairs))
+	for i, potentials := range electrodeSignals {
+		current := currents[i]
+		current.Metadata = current.Metadata.WithUnit(signal.UnitAmpere)
+		for _, pair := range pairs {
+			voltage, err := potentials.Voltage(pair)
+			if err != nil {
+				return nil, config.NewProcessingError(fmt.Sprintf("signal %d", i), err)
+			}
+			if err := signal.ValidateSignalsMatch(voltage, current); err != nil {
+				return nil, config.NewProcessingError(fmt.Sprintf("signal pair %d validation", i), err)
+			}
+			voltageSignals = append(voltageSignals, voltage)
+			currentSignals = append(currentSignals, current)
+		}
+	}
+
+	log.Printf("Loaded %d signals for electrode pairs %v from files", len(currents), pairs)
+	logParseReports(loader)
+
+	return newFileReceiver(electrodeFile, currentFile, sampleRate, loader, voltageSignals, currentSignals), nil
+}
+
+// newFileReceiver creates a file receiver emitting pre-loaded signal pairs
+func newFileReceiver(voltageFile, currentFile string, sampleRate float64, loader signal.DataLoader, voltageSignals, currentSignals []signal.Signal) *FileReceiver {
 	return &FileReceiver{
 		voltageChannel: make(chan signal.Signal, 10),
 		currentChannel: make(chan signal.Signal, 10),
 		voltageFile:    voltageFile,
 		currentFile:    currentFile,
 		sampleRate:     sampleRate,
-		validator:      validator,
+		validator:      signal.NewValidator(),
 		loader:         loader,
-		running:        false,
+		lifecycle:      newLifecycle(),
 		voltageSignals: voltageSignals,
 		currentSignals: currentSignals,
 		currentIndex:   0,
-	}, nil
+		completion:     newCompletion(),
+	}
 }
 
-// StartReceiving begins file-based data reception at 1-second intervals
+// logParseReports logs the parse reports of files with skipped or repaired rows
+func logParseReports(loader signal.DataLoader) {
+	for _, report := range loader.ParseReports() {
+		if !report.Clean() {
+			log.Printf("Parse report: %s", report)
+		}
+	}
+}
+
+// StartReceiving begins file-based data reception at 1-second intervals. The
+// signal channels are closed when it returns.
 func (fr *FileReceiver) StartReceiving(ctx context.Context) error {
+	defer fr.lifecycle.closeChannels(fr.voltageChannel, fr.currentChannel)
+
 	if len(fr.voltageSignals) == 0 {
 		return config.NewValidationError("Data", "no signals loaded from files")
 	}
@@ -67,22 +140,21 @@ func (fr *FileReceiver) StartReceiving(ctx context.Context) error {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	fr.running = true
+	fr.stats.start(len(fr.voltageSignals), time.Second)
+	defer fr.stats.stop()
 	log.Printf("Starting file-based data reception from %s and %s", fr.voltageFile, fr.currentFile)
 	log.Printf("Will process %d signal pairs over %d seconds", len(fr.voltageSignals), len(fr.voltageSignals))
+	if fr.currentIndex > 0 {
+		log.Printf("Resuming after signal pair %d/%d", fr.currentIndex, len(fr.voltageSignals))
+	}
 
-	for fr.running && fr.currentIndex < len(fr.voltageSignals) {
+	for fr.currentIndex < len(fr.voltageSignals) {
 		select {
 		case <-ctx.Done():
-			fr.running = false
 			return ctx.Err()
+		case <-fr.lifecycle.stopping():
+			return nil
 		case <-ticker.C:
-			if fr.currentIndex >= len(fr.voltageSignals) {
-				log.Println("All data processed, stopping receiver")
-				fr.running = false
-				return nil
-			}
-
 			voltageSignal := fr.voltageSignals[fr.currentIndex]
 			currentSignal := fr.currentSignals[fr.currentIndex]
 
@@ -90,27 +162,19 @@ func (fr *FileReceiver) StartReceiving(ctx context.Context) error {
 			if err := fr.validator.ValidateSignal(voltageSignal); err != nil {
 				log.Printf("Invalid voltage signal at index %d: %v", fr.currentIndex, err)
 				fr.currentIndex++
+				fr.stats.advance()
 				continue
 			}
 
 			if err := fr.validator.ValidateSignal(currentSignal); err != nil {
 				log.Printf("Invalid current signal at index %d: %v", fr.currentIndex, err)
 				fr.currentIndex++
+				fr.stats.advance()
 				continue
 			}
 
 			// Send signals to channels
-			select {
-			case fr.voltageChannel <- voltageSignal:
-			default:
-				log.Println("Warning: Voltage channel buffer full, dropping sample")
-			}
-
-			select {
-			case fr.currentChannel <- currentSignal:
-			default:
-				log.Println("Warning: Current channel buffer full, dropping sample")
-			}
+			emitPair(fr.voltageChannel, fr.currentChannel, voltageSignal, currentSignal, &fr.stats)
 
 			log.Printf("Sent signal pair %d/%d (%.1f%% complete) - Time: %v", 
 				fr.currentIndex+1, len(fr.voltageSignals), 
@@ -118,16 +182,30 @@ func (fr *FileReceiver) StartReceiving(ctx context.Context) error {
 				voltageSignal.Timestamp.Format("15:04:05"))
 
 			fr.currentIndex++
+			fr.stats.advance()
 		}
 	}
 
-	if fr.currentIndex >= len(fr.voltageSignals) {
-		log.Println("✅ All file data has been processed successfully")
-	}
+	log.Println("✅ All file data has been processed successfully")
+	fr.completion.complete()
+	return nil
+}
 
+// Seek skips the first position signal pairs so reception resumes after them
+func (fr *FileReceiver) Seek(position int) error {
+	if position < 0 || position > len(fr.voltageSignals) {
+		return config.NewValidationError("Position", fmt.Sprintf("position %d outside of the %d loaded signal pairs", position, len(fr.voltageSignals)))
+	}
+	fr.currentIndex = position
+	fr.stats.seek(position)
 	return nil
 }
 
+// Done returns a channel that is closed once all file data has been emitted
+func (fr *FileReceiver) Done() <-chan struct{} {
+	return fr.completion.done()
+}
+
 // GetVoltageChannel returns the channel for voltage signals
 func (fr *FileReceiver) GetVoltageChannel() <-chan signal.Signal {
 	return fr.voltageChannel
@@ -138,30 +216,33 @@ func (fr *FileReceiver) GetCurrentChannel() <-chan signal.Signal {
 	return fr.currentChannel
 }
 
-// Stop gracefully stops the receiver and closes channels
+// Stop asks the receiver to stop; the channels are closed once StartReceiving
+// returns. It is safe to call more than once.
 func (fr *FileReceiver) Stop() error {
-	fr.running = false
-	close(fr.voltageChannel)
-	close(fr.currentChannel)
-	log.Printf("File receiver stopped after processing %d/%d signals", fr.currentIndex, len(fr.voltageSignals))
+	fr.lifecycle.stop()
+	stats := fr.Stats()
+	log.Printf("File receiver stopped after processing %d/%d signals", stats.Processed, stats.Total)
 	return nil
 }
 
+// Stats returns the current reception statistics
+func (fr *FileReceiver) Stats() Stats {
+	stats := fr.stats.snapshot()
+	if stats.Total == 0 {
+		// Report the loaded size even before reception starts
+		stats.Total = len(fr.voltageSignals)
+		stats.Remaining = time.Duration(stats.Total) * time.Second
+	}
+	return stats
+}
+
 // GetProgress returns the current progress of file processing
 func (fr *FileReceiver) GetProgress() (current, total int, percentage float64) {
-	total = len(fr.voltageSignals)
-	current = fr.currentIndex
-	if total > 0 {
-		percentage = float64(current) / float64(total) * 100
-	}
-	return current, total, percentage
+	stats := fr.Stats()
+	return stats.Processed, stats.Total, stats.Progress
 }
 
 // GetRemainingTime estimates remaining processing time
 func (fr *FileReceiver) GetRemainingTime() time.Duration {
-	remaining := len(fr.voltageSignals) - fr.currentIndex
-	if remaining <= 0 {
-		return 0
-	}
-	return time.Duration(remaining) * time.Second
+	return fr.Stats().Remaining
 }
\ No newline at end of file
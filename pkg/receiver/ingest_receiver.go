@@ -0,0 +1,283 @@
+package receiver
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+const (
+	// ingestMaxBody limits the decoded size of one pushed chunk
+	ingestMaxBody = 64 << 20
+	// ingestRecentIDs is how many chunk IDs are remembered to drop retries
+	ingestRecentIDs = 1024
+	// ingestIdempotencyHeader carries the chunk ID, as sent by the raw chunk HTTP sink
+	ingestIdempotencyHeader = "Idempotency-Key"
+)
+
+// IngestOptions configures the endpoints of an IngestReceiver
+type IngestOptions struct {
+	Addr       string               // Listen address
+	Path       string               // Path accepting pushed chunks
+	SampleRate float64              // Sample rate of uploaded CSV files unless the upload sets one
+	Loader     signal.LoaderOptions // CSV dialect and parse mode of uploaded files
+}
+
+// IngestReceiver receives raw voltage/current chunks pushed over HTTP by
+// remote acquisition agents, so one central instance can process the data of
+// many acquirers. Each POST to the ingest path carries one signal.RawChunk as
+// JSON, optionally gzip encoded, which is the format written by the raw chunk
+// HTTP sink. Chunks are rejected with 503 while the pipeline is behind, so
+// agents retry instead of losing data, and retried chunk IDs are accepted
+// only once.
+//
+// Voltage/current CSV pairs may also be uploaded as multipart forms to
+// IngestUploadPath. Uploads are queued as jobs whose spectra are collected
+// through RecordResult and served at IngestUploadPath/<job ID>.
+type IngestReceiver struct {
+	options        IngestOptions
+	server         *http.Server
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	lifecycle      *lifecycle
+	stats          statsTracker
+	mu             sync.Mutex
+	closed         bool
+	recent         map[string]bool
+	recentOrder    []string
+	uploads        chan *uploadJob
+	jobsMu         sync.Mutex
+	jobs           map[string]*uploadJob
+	jobOrder       []string
+}
+
+// NewIngestReceiver creates a receiver serving the push and upload endpoints
+func NewIngestReceiver(options IngestOptions) (*IngestReceiver, error) {
+	if options.Addr == "" {
+		return nil, config.NewValidationError("IngestAddr", "listen address cannot be empty")
+	}
+	if !strings.HasPrefix(options.Path, "/") || strings.HasPrefix(options.Path, IngestUploadPath) {
+		return nil, config.NewValidationError("IngestPath", fmt.Sprintf("path must start with '/' and lie outside of %s", IngestUploadPath))
+	}
+	if options.SampleRate <= 0 {
+		return nil, config.NewValidationError("SampleRate", "sample rate of uploads must be positive")
+	}
+	ir := &IngestReceiver{
+		options:        options,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		lifecycle:      newLifecycle(),
+		recent:         make(map[string]bool),
+		uploads:        make(chan *uploadJob, uploadQueueSize),
+		jobs:           make(map[string]*uploadJob),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(options.Path, ir)
+	mux.HandleFunc(IngestUploadPath, ir.handleUpload)
+	mux.HandleFunc(IngestUploadPath+"/", ir.handleJob)
+	ir.server = &http.Server{
+		Addr:              options.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return ir, nil
+}
+
+// StartReceiving serves the ingest endpoint until ctx is cancelled or Stop
+// is called. The signal channels are closed when it returns.
+func (ir *IngestReceiver) StartReceiving(ctx context.Context) error {
+	defer func() {
+		ir.mu.Lock()
+		defer ir.mu.Unlock()
+		ir.closed = true
+		ir.lifecycle.closeChannels(ir.voltageChannel, ir.currentChannel)
+	}()
+
+	listener, err := net.Listen("tcp", ir.options.Addr)
+	if err != nil {
+		return config.NewNetworkError(ir.options.Addr, 0, err)
+	}
+	ir.stats.start(0, 0)
+	defer ir.stats.stop()
+	log.Printf("Accepting pushed signal chunks on %s%s and CSV uploads on %s", listener.Addr(), ir.options.Path, IngestUploadPath)
+
+	served := make(chan error, 1)
+	go func() { served <- ir.server.Serve(listener) }()
+	uploadsDone := make(chan struct{})
+	go func() {
+		defer close(uploadsDone)
+		ir.runUploads(ctx)
+	}()
+	defer func() { <-uploadsDone }()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-ir.lifecycle.stopping():
+	case err = <-served:
+		ir.lifecycle.stop()
+		return config.NewNetworkError(ir.options.Addr, 0, err)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ir.server.Shutdown(shutdownCtx)
+	return err
+}
+
+// ServeHTTP accepts one pushed chunk
+func (ir *IngestReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chunk, err := decodeIngestChunk(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if chunk.ID == "" {
+		chunk.ID = r.Header.Get(ingestIdempotencyHeader)
+	}
+
+	ir.mu.Lock()
+	duplicate := chunk.ID != "" && ir.recent[chunk.ID]
+	ir.mu.Unlock()
+	if duplicate {
+		writeIngestResponse(w, http.StatusOK, chunk.ID, "duplicate")
+		return
+	}
+
+	sent, err := ir.offer(chunk.Voltage, chunk.Current)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case !sent:
+		ir.stats.recordDropped()
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "pipeline busy, retry later", http.StatusServiceUnavailable)
+	default:
+		ir.remember(chunk.ID)
+		writeIngestResponse(w, http.StatusAccepted, chunk.ID, "accepted")
+	}
+}
+
+// offer hands a signal pair to the pipeline if both buffers have room and
+// reports whether it did. Pairs are only sent here, under mu, so the
+// buffers cannot fill up between the check and the send.
+func (ir *IngestReceiver) offer(voltage, current signal.Signal) (bool, error) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	if ir.closed {
+		return false, fmt.Errorf("receiver stopped")
+	}
+	if len(ir.voltageChannel) == cap(ir.voltageChannel) || len(ir.currentChannel) == cap(ir.currentChannel) {
+		return false, nil
+	}
+	ir.voltageChannel <- voltage
+	ir.currentChannel <- current
+	ir.stats.recordEmitted()
+	ir.stats.advance()
+	return true, nil
+}
+
+// decodeIngestChunk reads and checks the chunk in the request body
+func decodeIngestChunk(r *http.Request) (signal.RawChunk, error) {
+	var chunk signal.RawChunk
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return chunk, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	decoder := json.NewDecoder(io.LimitReader(body, ingestMaxBody))
+	if err := decoder.Decode(&chunk); err != nil {
+		return chunk, fmt.Errorf("invalid chunk: %w", err)
+	}
+
+	for _, s := range []signal.Signal{chunk.Voltage, chunk.Current} {
+		if err := config.ValidateSignalData(s.Values, s.SampleRate); err != nil {
+			return chunk, err
+		}
+	}
+	if err := config.ValidateSignalsMatch(len(chunk.Voltage.Values), len(chunk.Current.Values), chunk.Voltage.SampleRate, chunk.Current.SampleRate); err != nil {
+		return chunk, err
+	}
+	if unit := chunk.Voltage.Metadata.Unit; unit != "" && unit != signal.UnitVolt {
+		return chunk, fmt.Errorf("voltage unit must be %s, got %q", signal.UnitVolt, unit)
+	}
+	if unit := chunk.Current.Metadata.Unit; unit != "" && unit != signal.UnitAmpere {
+		return chunk, fmt.Errorf("current unit must be %s, got %q", signal.UnitAmpere, unit)
+	}
+	chunk.Voltage.Metadata.Unit = signal.UnitVolt
+	chunk.Current.Metadata.Unit = signal.UnitAmpere
+	if chunk.Voltage.Timestamp.IsZero() {
+		now := time.Now()
+		chunk.Voltage.Timestamp, chunk.Current.Timestamp = now, now
+	}
+	return chunk, nil
+}
+
+// remember records id as received, forgetting the oldest ID beyond the limit
+func (ir *IngestReceiver) remember(id string) {
+	if id == "" {
+		return
+	}
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	ir.recent[id] = true
+	ir.recentOrder = append(ir.recentOrder, id)
+	if len(ir.recentOrder) > ingestRecentIDs {
+		delete(ir.recent, ir.recentOrder[0])
+		ir.recentOrder = ir.recentOrder[1:]
+	}
+}
+
+// writeIngestResponse reports the outcome for a chunk as JSON
+func writeIngestResponse(w http.ResponseWriter, status int, id, outcome string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"id": id, "status": outcome})
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (ir *IngestReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return ir.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (ir *IngestReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return ir.currentChannel
+}
+
+// Done returns nil: agents may push chunks at any time
+func (ir *IngestReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics; rejected chunks count as
+// dropped
+func (ir *IngestReceiver) Stats() Stats {
+	return ir.stats.snapshot()
+}
+
+// Stop stops accepting chunks; the channels are closed once StartReceiving
+// returns. It is safe to call more than once.
+func (ir *IngestReceiver) Stop() error {
+	ir.lifecycle.stop()
+	return nil
+}
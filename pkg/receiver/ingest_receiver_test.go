@@ -0,0 +1,199 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/network"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestIngestReceiver_AcceptsRawChunks(t *testing.T) {
+	receiver, err := NewIngestReceiver(IngestOptions{Addr: "127.0.0.1:0", Path: "/ingest", SampleRate: 100})
+	if err != nil {
+		t.Fatalf("NewIngestReceiver() error = %v", err)
+	}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// An agent pushing with the raw chunk HTTP sink
+	sink, err := network.NewRawHTTPSink(server.URL, "/ingest")
+	if err != nil {
+		t.Fatalf("NewRawHTTPSink() error = %v", err)
+	}
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	chunk := signal.RawChunk{
+		Identity: signal.Identity{ID: "chunk-1", Sequence: 1},
+		Voltage:  signal.Signal{Timestamp: timestamp, Values: []float64{1, 2, 3}, SampleRate: 100, Metadata: signal.Metadata{DeviceSerial: "agent-7"}},
+		Current:  signal.Signal{Timestamp: timestamp, Values: []float64{0.1, 0.2, 0.3}, SampleRate: 100},
+	}
+	if err := sink.WriteRawChunk(chunk); err != nil {
+		t.Fatalf("WriteRawChunk() error = %v", err)
+	}
+	// A retry of the same chunk is acknowledged but not processed again
+	if err := sink.WriteRawChunk(chunk); err != nil {
+		t.Fatalf("WriteRawChunk() retry error = %v", err)
+	}
+
+	voltage := <-receiver.GetVoltageChannel()
+	current := <-receiver.GetCurrentChannel()
+	if !reflect.DeepEqual(voltage.Values, []float64{1, 2, 3}) || !voltage.Timestamp.Equal(timestamp) || voltage.Metadata.DeviceSerial != "agent-7" {
+		t.Errorf("voltage = %+v, want the pushed signal", voltage)
+	}
+	if voltage.Metadata.Unit != signal.UnitVolt || current.Metadata.Unit != signal.UnitAmpere {
+		t.Errorf("units = %q/%q, want V/A", voltage.Metadata.Unit, current.Metadata.Unit)
+	}
+	select {
+	case duplicate := <-receiver.GetVoltageChannel():
+		t.Errorf("retried chunk emitted again: %+v", duplicate)
+	default:
+	}
+	if stats := receiver.Stats(); stats.SignalsEmitted != 1 {
+		t.Errorf("SignalsEmitted = %d, want 1", stats.SignalsEmitted)
+	}
+}
+
+func TestIngestReceiver_Rejections(t *testing.T) {
+	valid := `{"voltage":{"values":[1,2],"sample_rate":10},"current":{"values":[3,4],"sample_rate":10}}`
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"accepted", http.MethodPost, valid, http.StatusAccepted},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"malformed", http.MethodPost, "{", http.StatusBadRequest},
+		{"length mismatch", http.MethodPost, `{"voltage":{"values":[1,2],"sample_rate":10},"current":{"values":[3],"sample_rate":10}}`, http.StatusBadRequest},
+		{"no sample rate", http.MethodPost, `{"voltage":{"values":[1]},"current":{"values":[3]}}`, http.StatusBadRequest},
+		{"wrong unit", http.MethodPost, `{"voltage":{"values":[1],"sample_rate":10,"metadata":{"unit":"A"}},"current":{"values":[3],"sample_rate":10}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		receiver, _ := NewIngestReceiver(IngestOptions{Addr: "127.0.0.1:0", Path: "/ingest", SampleRate: 100})
+		recorder := httptest.NewRecorder()
+		receiver.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/ingest", strings.NewReader(tt.body)))
+		if recorder.Code != tt.want {
+			t.Errorf("%s: status = %d (%s), want %d", tt.name, recorder.Code, strings.TrimSpace(recorder.Body.String()), tt.want)
+		}
+	}
+}
+
+func TestIngestReceiver_BusyAndStopped(t *testing.T) {
+	receiver, _ := NewIngestReceiver(IngestOptions{Addr: "127.0.0.1:0", Path: "/ingest", SampleRate: 100})
+	body := `{"voltage":{"values":[1],"sample_rate":10},"current":{"values":[2],"sample_rate":10}}`
+	post := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		return recorder
+	}
+	for i := 0; i < cap(receiver.voltageChannel); i++ {
+		if code := post().Code; code != http.StatusAccepted {
+			t.Fatalf("chunk %d: status = %d, want %d", i, code, http.StatusAccepted)
+		}
+	}
+	busy := post()
+	if busy.Code != http.StatusServiceUnavailable || busy.Header().Get("Retry-After") == "" {
+		t.Errorf("full buffer: status = %d, Retry-After = %q, want 503 with Retry-After", busy.Code, busy.Header().Get("Retry-After"))
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+	receiver.Stop()
+	if err := <-result; err != nil {
+		t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+	}
+	if code := post().Code; code != http.StatusServiceUnavailable {
+		t.Errorf("after Stop: status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	drained := 0
+	for range receiver.GetVoltageChannel() {
+		drained++
+	}
+	if drained != cap(receiver.voltageChannel) {
+		t.Errorf("drained %d buffered chunks, want %d", drained, cap(receiver.voltageChannel))
+	}
+}
+
+func TestIngestReceiver_UploadJob(t *testing.T) {
+	receiver, err := NewIngestReceiver(IngestOptions{Addr: "127.0.0.1:0", Path: "/ingest", SampleRate: 4})
+	if err != nil {
+		t.Fatalf("NewIngestReceiver() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go receiver.runUploads(ctx)
+	server := httptest.NewServer(receiver.server.Handler)
+	defer server.Close()
+
+	// Two 1-second chunks at 4 Hz
+	var voltageCSV, currentCSV strings.Builder
+	voltageCSV.WriteString("timestamp,time_offset,value\n")
+	currentCSV.WriteString("timestamp,time_offset,value\n")
+	for i := 0; i < 8; i++ {
+		offset := float64(i) / 4
+		fmt.Fprintf(&voltageCSV, "2024-05-01T12:00:00Z,%g,%g\n", offset, float64(i))
+		fmt.Fprintf(&currentCSV, "2024-05-01T12:00:00Z,%g,%g\n", offset, float64(i)/10)
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for field, content := range map[string]string{"voltage": voltageCSV.String(), "current": currentCSV.String()} {
+		part, _ := form.CreateFormFile(field, field+".csv")
+		part.Write([]byte(content))
+	}
+	form.Close()
+
+	resp, err := http.Post(server.URL+IngestUploadPath, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	var queued map[string]string
+	json.NewDecoder(resp.Body).Decode(&queued)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || queued["results_url"] != IngestUploadPath+"/"+queued["id"] {
+		t.Fatalf("upload = %d %v, want 202 with the results URL", resp.StatusCode, queued)
+	}
+
+	// Stand in for the pipeline: one spectrum for the first chunk, none for the second
+	for i := 0; i < 2; i++ {
+		voltage := <-receiver.GetVoltageChannel()
+		<-receiver.GetCurrentChannel()
+		if voltage.Metadata.Labels[UploadJobLabel] != queued["id"] || len(voltage.Values) != 4 {
+			t.Fatalf("chunk %d = %+v, want 4 samples labelled with the job", i, voltage)
+		}
+		var result *signal.ImpedanceData
+		if i == 0 {
+			result = &signal.ImpedanceData{Frequencies: []float64{1}, Magnitude: []float64{10}, Phase: []float64{0}}
+		}
+		receiver.RecordResult(voltage, result)
+	}
+
+	// The job completes once the feeder has handed over its last chunk
+	var job UploadJob
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err = http.Get(server.URL + queued["results_url"])
+		if err != nil {
+			t.Fatalf("results: %v", err)
+		}
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if job.Status == UploadDone {
+			break
+		}
+	}
+	if job.Status != UploadDone || job.Chunks != 2 || job.Failed != 1 || len(job.Results) != 1 {
+		t.Errorf("job = %+v, want done with one spectrum and one failed chunk", job)
+	}
+
+	if resp, _ := http.Get(server.URL + IngestUploadPath + "/unknown"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", resp.StatusCode)
+	}
+}
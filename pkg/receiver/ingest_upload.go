@@ -0,0 +1,290 @@
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+const (
+	// IngestUploadPath accepts multipart CSV uploads; job results are served below it
+	IngestUploadPath = "/uploads"
+	// UploadJobLabel tags the chunks and spectra of an upload with its job ID
+	UploadJobLabel = "upload_job"
+	// uploadMaxBody limits the size of one upload
+	uploadMaxBody = 256 << 20
+	// uploadQueueSize is how many uploads may wait for processing
+	uploadQueueSize = 16
+	// uploadKeepJobs is how many jobs are kept for polling, oldest first out
+	uploadKeepJobs = 100
+)
+
+// Upload job states
+const (
+	UploadQueued     = "queued"
+	UploadProcessing = "processing"
+	UploadDone       = "done"
+	UploadFailed     = "failed"
+)
+
+// UploadJob is the state of one uploaded voltage/current CSV pair as served
+// at its results URL
+type UploadJob struct {
+	ID      string                 `json:"id"`
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Created time.Time              `json:"created"`
+	Chunks  int                    `json:"chunks"`  // Signal pairs loaded from the files
+	Failed  int                    `json:"failed"`  // Chunks the pipeline produced no spectrum for
+	Results []signal.ImpedanceData `json:"results"` // Spectra in the order they were processed
+}
+
+// uploadJob tracks an UploadJob while its files wait in dir
+type uploadJob struct {
+	mu          sync.Mutex
+	state       UploadJob
+	dir         string
+	voltageFile string
+	currentFile string
+	sampleRate  float64
+	fed         bool // All chunks were handed to the pipeline
+}
+
+// snapshot returns a copy of the job state
+func (job *uploadJob) snapshot() UploadJob {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	state := job.state
+	state.Results = append([]signal.ImpedanceData{}, job.state.Results...)
+	return state
+}
+
+// setStatus moves the job to status, recording err if it failed
+func (job *uploadJob) setStatus(status string, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.state.Status = status
+	if err != nil {
+		job.state.Error = err.Error()
+	}
+}
+
+// record adds the outcome of one chunk and completes the job after the last
+func (job *uploadJob) record(result *signal.ImpedanceData) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if result != nil {
+		job.state.Results = append(job.state.Results, *result)
+	} else {
+		job.state.Failed++
+	}
+	job.complete()
+}
+
+// complete marks the job done once every fed chunk has an outcome. It must
+// be called with mu held.
+func (job *uploadJob) complete() {
+	if job.fed && len(job.state.Results)+job.state.Failed >= job.state.Chunks {
+		job.state.Status = UploadDone
+	}
+}
+
+// handleUpload queues an uploaded voltage/current CSV pair as a job
+func (ir *IngestReceiver) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, uploadMaxBody)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, fmt.Sprintf("invalid multipart upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	sampleRate := ir.options.SampleRate
+	if value := r.FormValue("sample_rate"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			http.Error(w, fmt.Sprintf("invalid sample_rate %q", value), http.StatusBadRequest)
+			return
+		}
+		sampleRate = rate
+	}
+
+	dir, err := os.MkdirTemp("", "masterapp-upload-")
+	if err != nil {
+		http.Error(w, "cannot store upload", http.StatusInternalServerError)
+		return
+	}
+	job := &uploadJob{
+		state:      UploadJob{ID: signal.NewUUID(), Status: UploadQueued, Created: time.Now()},
+		dir:        dir,
+		sampleRate: sampleRate,
+	}
+	for field, path := range map[string]*string{"voltage": &job.voltageFile, "current": &job.currentFile} {
+		*path = filepath.Join(dir, field+".csv")
+		if err := saveFormFile(r, field, *path); err != nil {
+			os.RemoveAll(dir)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Register the job before queuing it so that no result can miss it
+	ir.addJob(job)
+	select {
+	case ir.uploads <- job:
+	default:
+		ir.jobsMu.Lock()
+		delete(ir.jobs, job.state.ID)
+		ir.jobsMu.Unlock()
+		os.RemoveAll(dir)
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "upload queue full, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("Queued upload %s", job.state.ID)
+
+	resultsURL := IngestUploadPath + "/" + job.state.ID
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", resultsURL)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": job.state.ID, "status": UploadQueued, "results_url": resultsURL})
+}
+
+// saveFormFile copies the uploaded file of field to path
+func saveFormFile(r *http.Request, field, path string) error {
+	upload, _, err := r.FormFile(field)
+	if err != nil {
+		return fmt.Errorf("missing %s file: %w", field, err)
+	}
+	defer upload.Close()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, upload); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// handleJob serves the state and spectra of an upload job
+func (ir *IngestReceiver) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job := ir.lookupJob(strings.TrimPrefix(r.URL.Path, IngestUploadPath+"/"))
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.snapshot())
+}
+
+// addJob makes job available for polling, forgetting the oldest jobs
+func (ir *IngestReceiver) addJob(job *uploadJob) {
+	ir.jobsMu.Lock()
+	defer ir.jobsMu.Unlock()
+	ir.jobs[job.state.ID] = job
+	ir.jobOrder = append(ir.jobOrder, job.state.ID)
+	if len(ir.jobOrder) > uploadKeepJobs {
+		delete(ir.jobs, ir.jobOrder[0])
+		ir.jobOrder = ir.jobOrder[1:]
+	}
+}
+
+// lookupJob returns the job with id, or nil
+func (ir *IngestReceiver) lookupJob(id string) *uploadJob {
+	ir.jobsMu.Lock()
+	defer ir.jobsMu.Unlock()
+	return ir.jobs[id]
+}
+
+// runUploads feeds queued uploads into the pipeline one at a time
+func (ir *IngestReceiver) runUploads(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ir.lifecycle.stopping():
+			return
+		case job := <-ir.uploads:
+			if err := ir.feed(ctx, job); err != nil {
+				log.Printf("Upload %s failed: %v", job.state.ID, err)
+				job.setStatus(UploadFailed, err)
+			}
+		}
+	}
+}
+
+// feed loads the files of job and hands its chunks to the pipeline, waiting
+// while the pipeline is busy
+func (ir *IngestReceiver) feed(ctx context.Context, job *uploadJob) error {
+	job.setStatus(UploadProcessing, nil)
+	loader := signal.NewDataLoaderWithOptions(ir.options.Loader)
+	voltages, currents, err := loader.LoadVoltageAndCurrentFromCSV(job.voltageFile, job.currentFile, job.sampleRate)
+	os.RemoveAll(job.dir)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	job.state.Chunks = len(voltages)
+	job.mu.Unlock()
+
+	labels := map[string]string{UploadJobLabel: job.state.ID}
+	for i := range voltages {
+		voltages[i].Metadata = voltages[i].Metadata.Merge(signal.Metadata{Labels: labels})
+		currents[i].Metadata = currents[i].Metadata.Merge(signal.Metadata{Labels: labels})
+		for {
+			sent, err := ir.offer(voltages[i], currents[i])
+			if err != nil {
+				return err
+			}
+			if sent {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ir.lifecycle.stopping():
+				return fmt.Errorf("receiver stopped after %d of %d chunks", i, len(voltages))
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.fed = true
+	job.complete()
+	return nil
+}
+
+// RecordResult adds the spectrum computed from an uploaded chunk to its job;
+// result is nil if the pipeline produced none
+func (ir *IngestReceiver) RecordResult(voltage signal.Signal, result *signal.ImpedanceData) {
+	id := voltage.Metadata.Labels[UploadJobLabel]
+	if id == "" {
+		return
+	}
+	if job := ir.lookupJob(id); job != nil {
+		job.record(result)
+	}
+}
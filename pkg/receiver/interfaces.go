@@ -6,10 +6,28 @@ import (
 	"github.com/adam/masterapp/pkg/signal"
 )
 
-// DataReceiver defines the interface for real-time signal reception
+// DataReceiver defines the interface for real-time signal reception.
+// Done returns a channel that is closed once a finite source has emitted all
+// of its signals; it is never closed for unbounded sources.
 type DataReceiver interface {
 	StartReceiving(ctx context.Context) error
 	GetVoltageChannel() <-chan signal.Signal
 	GetCurrentChannel() <-chan signal.Signal
+	Done() <-chan struct{}
+	Stats() Stats
 	Stop() error
-}
\ No newline at end of file
+}
+
+// Seeker is implemented by receivers replaying recorded data that can skip
+// the first position signal pairs, e.g. to resume from a checkpoint. Seek must
+// be called before StartReceiving.
+type Seeker interface {
+	Seek(position int) error
+}
+
+// ResultRecorder is implemented by receivers that report the outcome of
+// processing back to the producer of a chunk, e.g. to uploaders polling for
+// their spectra. result is nil if the pair produced no spectrum.
+type ResultRecorder interface {
+	RecordResult(voltage signal.Signal, result *signal.ImpedanceData)
+}
@@ -0,0 +1,41 @@
+package receiver
+
+import (
+	"sync"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// lifecycle coordinates shutdown between Stop and the producing goroutine.
+// Stop only signals the run loop; the output channels are closed by the
+// producer once its loop has exited, so no send can race with a close.
+type lifecycle struct {
+	stopOnce  sync.Once
+	closeOnce sync.Once
+	stopped   chan struct{}
+}
+
+// newLifecycle creates the lifecycle of a receiver that has not been stopped
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopped: make(chan struct{})}
+}
+
+// stop asks the run loop to exit; repeated calls are ignored
+func (l *lifecycle) stop() {
+	l.stopOnce.Do(func() { close(l.stopped) })
+}
+
+// stopping returns a channel that is closed once stop has been called
+func (l *lifecycle) stopping() <-chan struct{} {
+	return l.stopped
+}
+
+// closeChannels closes the producer's output channels exactly once. It must
+// only be called by the producer after its last send.
+func (l *lifecycle) closeChannels(channels ...chan signal.Signal) {
+	l.closeOnce.Do(func() {
+		for _, ch := range channels {
+			close(ch)
+		}
+	})
+}
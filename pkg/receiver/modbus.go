@@ -0,0 +1,246 @@
+package receiver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Modbus register formats
+const (
+	ModbusInt16   = "int16"
+	ModbusUint16  = "uint16"
+	ModbusInt32   = "int32"
+	ModbusUint32  = "uint32"
+	ModbusFloat32 = "float32"
+)
+
+// modbusReadInputRegisters is the function code reading input registers
+const modbusReadInputRegisters = 0x04
+
+// modbusMaxRegisters is the most registers one read may return
+const modbusMaxRegisters = 125
+
+// modbusExceptions names the standard exception codes
+var modbusExceptions = map[byte]string{
+	0x01: "illegal function",
+	0x02: "illegal data address",
+	0x03: "illegal data value",
+	0x04: "server device failure",
+	0x06: "server device busy",
+	0x0A: "gateway path unavailable",
+	0x0B: "gateway target device failed to respond",
+}
+
+// modbusRegisterCount returns the registers a value of format occupies, or 0
+// for unknown formats
+func modbusRegisterCount(format string) int {
+	switch format {
+	case ModbusInt16, ModbusUint16:
+		return 1
+	case ModbusInt32, ModbusUint32, ModbusFloat32:
+		return 2
+	}
+	return 0
+}
+
+// decodeModbusValue converts the registers of one value; 32-bit values are
+// stored high word first unless wordSwap is set
+func decodeModbusValue(registers []uint16, format string, wordSwap bool) float64 {
+	switch format {
+	case ModbusInt16:
+		return float64(int16(registers[0]))
+	case ModbusUint16:
+		return float64(registers[0])
+	}
+	high, low := registers[0], registers[1]
+	if wordSwap {
+		high, low = low, high
+	}
+	raw := uint32(high)<<16 | uint32(low)
+	switch format {
+	case ModbusInt32:
+		return float64(int32(raw))
+	case ModbusFloat32:
+		return float64(math.Float32frombits(raw))
+	}
+	return float64(raw)
+}
+
+// modbusCRC computes the CRC-16/MODBUS checksum of an RTU frame
+func modbusCRC(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// modbusConn is the byte stream to a Modbus device
+type modbusConn interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+}
+
+// modbusClient reads input registers over Modbus TCP or Modbus RTU, the
+// latter on a serial device or tunnelled through a TCP serial gateway
+type modbusClient struct {
+	conn        modbusConn
+	rtu         bool
+	unit        byte
+	timeout     time.Duration
+	transaction uint16
+}
+
+// dialModbus connects to tcp://host:port (Modbus TCP), rtu+tcp://host:port
+// (RTU frames through a serial gateway) or rtu:///dev/ttyUSB0 (RTU on a
+// serial port whose baud rate and parity are already configured)
+func dialModbus(address string, unit byte, timeout time.Duration) (*modbusClient, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	client := &modbusClient{unit: unit, timeout: timeout}
+	switch parsed.Scheme {
+	case "tcp", "rtu+tcp":
+		host := parsed.Host
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), "502")
+		}
+		conn, err := net.DialTimeout("tcp", host, timeout)
+		if err != nil {
+			return nil, err
+		}
+		client.conn = conn
+		client.rtu = parsed.Scheme == "rtu+tcp"
+	case "rtu":
+		device, err := os.OpenFile(parsed.Path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		client.conn = device
+		client.rtu = true
+	default:
+		return nil, fmt.Errorf("unsupported modbus scheme '%s'", parsed.Scheme)
+	}
+	return client, nil
+}
+
+// readInputRegisters reads count input registers starting at address
+func (c *modbusClient) readInputRegisters(address, count uint16) ([]uint16, error) {
+	pdu := []byte{modbusReadInputRegisters, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(pdu[1:], address)
+	binary.BigEndian.PutUint16(pdu[3:], count)
+
+	// Devices without deadline support, e.g. some serial drivers, block instead
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	var response []byte
+	var err error
+	if c.rtu {
+		response, err = c.roundTripRTU(pdu)
+	} else {
+		response, err = c.roundTripTCP(pdu)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if response[0] == modbusReadInputRegisters|0x80 {
+		if len(response) < 2 {
+			return nil, errors.New("truncated modbus exception response")
+		}
+		name := modbusExceptions[response[1]]
+		if name == "" {
+			name = "unknown exception"
+		}
+		return nil, fmt.Errorf("modbus exception 0x%02X (%s) reading %d registers at %d", response[1], name, count, address)
+	}
+	if response[0] != modbusReadInputRegisters || len(response) < 2 || int(response[1]) != 2*int(count) || len(response) != 2+2*int(count) {
+		return nil, fmt.Errorf("malformed modbus response % X", response)
+	}
+	registers := make([]uint16, count)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(response[2+2*i:])
+	}
+	return registers, nil
+}
+
+// roundTripTCP sends pdu with an MBAP header and returns the response PDU
+func (c *modbusClient) roundTripTCP(pdu []byte) ([]byte, error) {
+	c.transaction++
+	frame := make([]byte, 7, 7+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], c.transaction)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(pdu)+1))
+	frame[6] = c.unit
+	if _, err := c.conn.Write(append(frame, pdu...)); err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [7]byte
+		if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length < 2 || length > 254 {
+			return nil, fmt.Errorf("invalid modbus TCP length %d", length)
+		}
+		response := make([]byte, length-1)
+		if _, err := io.ReadFull(c.conn, response); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(header[0:]) == c.transaction {
+			return response, nil
+		}
+		// A late response to an earlier request that timed out
+	}
+}
+
+// roundTripRTU sends pdu in an RTU frame and returns the response PDU
+func (c *modbusClient) roundTripRTU(pdu []byte) ([]byte, error) {
+	frame := append([]byte{c.unit}, pdu...)
+	frame = binary.LittleEndian.AppendUint16(frame, modbusCRC(frame))
+	if _, err := c.conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	// Unit and function code, then the exception code or byte count
+	response := make([]byte, 3, 3+255+2)
+	if _, err := io.ReadFull(c.conn, response); err != nil {
+		return nil, err
+	}
+	remaining := 2 // CRC
+	if response[1]&0x80 == 0 {
+		remaining += int(response[2])
+	}
+	response = response[:3+remaining]
+	if _, err := io.ReadFull(c.conn, response[3:]); err != nil {
+		return nil, err
+	}
+	body := response[:len(response)-2]
+	if binary.LittleEndian.Uint16(response[len(response)-2:]) != modbusCRC(body) {
+		return nil, errors.New("modbus RTU response with bad CRC")
+	}
+	if body[0] != c.unit {
+		return nil, fmt.Errorf("modbus RTU response from unit %d, want %d", body[0], c.unit)
+	}
+	return body[1:], nil
+}
+
+// Close closes the connection to the device
+func (c *modbusClient) Close() error {
+	return c.conn.Close()
+}
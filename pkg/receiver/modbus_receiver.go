@@ -0,0 +1,226 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// ModbusOptions selects the device, registers and scaling of a ModbusReceiver
+type ModbusOptions struct {
+	Address         string        // tcp://host:502, rtu+tcp://gateway:port or rtu:///dev/ttyUSB0
+	Unit            byte          // Unit (slave) id of the device
+	VoltageRegister uint16        // Input register address of the voltage reading
+	CurrentRegister uint16        // Input register address of the current reading
+	Format          string        // Register format: int16, uint16, int32, uint32 or float32
+	WordSwap        bool          // 32-bit values are stored low word first
+	VoltageScale    float64       // Volts per raw unit
+	VoltageOffset   float64       // Volts added after scaling
+	CurrentScale    float64       // Amperes per raw unit
+	CurrentOffset   float64       // Amperes added after scaling
+	PollInterval    time.Duration // Interval between readings; its inverse is the sample rate
+	ChunkSamples    int           // Samples per emitted signal
+}
+
+// ModbusReceiver polls the voltage and current input registers of a Modbus
+// acquisition module at a fixed rate and cuts the scaled readings into signal
+// chunks. Registers close to each other are read in a single request so both
+// readings are taken together. A failed poll discards the partial chunk and
+// the connection is re-established with backoff until the receiver is stopped.
+type ModbusReceiver struct {
+	options        ModbusOptions
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	timeout        time.Duration
+	lifecycle      *lifecycle
+	stats          statsTracker
+	mu             sync.Mutex
+	client         *modbusClient
+}
+
+// NewModbusReceiver creates a receiver polling the given device
+func NewModbusReceiver(options ModbusOptions) (*ModbusReceiver, error) {
+	if err := ValidateModbusAddress(options.Address); err != nil {
+		return nil, err
+	}
+	if modbusRegisterCount(options.Format) == 0 {
+		return nil, config.NewValidationError("ModbusFormat", fmt.Sprintf("unknown register format '%s'", options.Format))
+	}
+	if options.VoltageScale == 0 || options.CurrentScale == 0 {
+		return nil, config.NewValidationError("ModbusScale", "scale factors cannot be zero")
+	}
+	if options.PollInterval <= 0 {
+		return nil, config.NewValidationError("ModbusPollInterval", "poll interval must be positive")
+	}
+	if options.ChunkSamples <= 0 {
+		return nil, config.NewValidationError("ChunkSamples", "chunk size must be positive")
+	}
+
+	return &ModbusReceiver{
+		options:        options,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		timeout:        max(time.Second, 2*options.PollInterval),
+		lifecycle:      newLifecycle(),
+	}, nil
+}
+
+// ValidateModbusAddress checks that address names a supported Modbus transport
+func ValidateModbusAddress(address string) error {
+	for _, prefix := range []string{"tcp://", "rtu+tcp://", "rtu://"} {
+		if strings.HasPrefix(address, prefix) && len(address) > len(prefix) {
+			return nil
+		}
+	}
+	return config.NewValidationError("ModbusAddress", fmt.Sprintf("address '%s' must start with tcp://, rtu+tcp:// or rtu://", address))
+}
+
+// sampleRate returns the rate of the polled readings
+func (mr *ModbusReceiver) sampleRate() float64 {
+	return float64(time.Second) / float64(mr.options.PollInterval)
+}
+
+// StartReceiving polls the device and emits signal pairs until ctx is
+// cancelled or Stop is called. The signal channels are closed when it returns.
+func (mr *ModbusReceiver) StartReceiving(ctx context.Context) error {
+	defer mr.lifecycle.closeChannels(mr.voltageChannel, mr.currentChannel)
+
+	mr.stats.start(0, time.Duration(mr.options.ChunkSamples)*mr.options.PollInterval)
+	defer mr.stats.stop()
+	log.Printf("Starting Modbus reception from %s (unit %d, registers %d/%d, every %v)",
+		mr.options.Address, mr.options.Unit, mr.options.VoltageRegister, mr.options.CurrentRegister, mr.options.PollInterval)
+
+	assembler := newSampleAssembler(mr.sampleRate(), mr.options.ChunkSamples)
+	return reconnect(ctx, mr.lifecycle, mr.options.Address, func() error {
+		assembler.reset()
+		return mr.poll(ctx, assembler)
+	})
+}
+
+// poll connects and reads both registers on every tick until a read fails
+// or the receiver stops
+func (mr *ModbusReceiver) poll(ctx context.Context, assembler *sampleAssembler) error {
+	client, err := dialModbus(mr.options.Address, mr.options.Unit, mr.timeout)
+	if err != nil {
+		return err
+	}
+	if !mr.attach(client) {
+		client.Close()
+		return nil
+	}
+	defer mr.detach(client)
+
+	ticker := time.NewTicker(mr.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-mr.lifecycle.stopping():
+			return nil
+		case t := <-ticker.C:
+			voltage, current, err := mr.read(client)
+			if err != nil {
+				return err
+			}
+			assembler.addVoltage(t, voltage)
+			assembler.addCurrent(t, current)
+			for {
+				voltageSignal, currentSignal, ok := assembler.next()
+				if !ok {
+					break
+				}
+				emitPair(mr.voltageChannel, mr.currentChannel, voltageSignal, currentSignal, &mr.stats)
+				mr.stats.advance()
+			}
+		}
+	}
+}
+
+// read returns the scaled voltage and current readings, taken with one
+// request when both values fit into it
+func (mr *ModbusReceiver) read(client *modbusClient) (float64, float64, error) {
+	count := modbusRegisterCount(mr.options.Format)
+	first := min(mr.options.VoltageRegister, mr.options.CurrentRegister)
+	last := max(mr.options.VoltageRegister, mr.options.CurrentRegister)
+	var voltageRaw, currentRaw []uint16
+	if span := int(last-first) + count; span <= modbusMaxRegisters {
+		registers, err := client.readInputRegisters(first, uint16(span))
+		if err != nil {
+			return 0, 0, err
+		}
+		voltageRaw = registers[mr.options.VoltageRegister-first:]
+		currentRaw = registers[mr.options.CurrentRegister-first:]
+	} else {
+		var err error
+		if voltageRaw, err = client.readInputRegisters(mr.options.VoltageRegister, uint16(count)); err != nil {
+			return 0, 0, err
+		}
+		if currentRaw, err = client.readInputRegisters(mr.options.CurrentRegister, uint16(count)); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	voltage := decodeModbusValue(voltageRaw, mr.options.Format, mr.options.WordSwap)*mr.options.VoltageScale + mr.options.VoltageOffset
+	current := decodeModbusValue(currentRaw, mr.options.Format, mr.options.WordSwap)*mr.options.CurrentScale + mr.options.CurrentOffset
+	return voltage, current, nil
+}
+
+// attach makes client the active connection unless the receiver has stopped
+func (mr *ModbusReceiver) attach(client *modbusClient) bool {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	select {
+	case <-mr.lifecycle.stopping():
+		return false
+	default:
+	}
+	mr.client = client
+	return true
+}
+
+// detach closes client
+func (mr *ModbusReceiver) detach(client *modbusClient) {
+	mr.mu.Lock()
+	mr.client = nil
+	mr.mu.Unlock()
+	client.Close()
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (mr *ModbusReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return mr.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (mr *ModbusReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return mr.currentChannel
+}
+
+// Done returns nil: polling never runs out of data
+func (mr *ModbusReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (mr *ModbusReceiver) Stats() Stats {
+	return mr.stats.snapshot()
+}
+
+// Stop asks the receiver to stop and aborts a pending read; the channels are
+// closed once StartReceiving returns. It is safe to call more than once.
+func (mr *ModbusReceiver) Stop() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.lifecycle.stop()
+	if mr.client != nil {
+		mr.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package receiver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// modbusTestServer serves input register reads over Modbus TCP or RTU framing
+// from register; addresses it reports as invalid are answered with an
+// illegal data address exception
+type modbusTestServer struct {
+	listener net.Listener
+	rtu      bool
+	unit     byte
+	mu       sync.Mutex
+	register func(address uint16) (uint16, bool)
+	reads    [][2]uint16 // Start address and count of every read
+}
+
+func newModbusTestServer(t *testing.T, rtu bool, register func(address uint16) (uint16, bool)) *modbusTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &modbusTestServer{listener: listener, rtu: rtu, unit: 3, register: register}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *modbusTestServer) address() string {
+	if s.rtu {
+		return "rtu+tcp://" + s.listener.Addr().String()
+	}
+	return "tcp://" + s.listener.Addr().String()
+}
+
+func (s *modbusTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var header, request []byte
+		if s.rtu {
+			request = make([]byte, 8)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			if binary.LittleEndian.Uint16(request[6:]) != modbusCRC(request[:6]) {
+				return
+			}
+			request = request[1:6]
+		} else {
+			header = make([]byte, 7)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			request = make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+		}
+
+		start, count := binary.BigEndian.Uint16(request[1:]), binary.BigEndian.Uint16(request[3:])
+		s.mu.Lock()
+		s.reads = append(s.reads, [2]uint16{start, count})
+		s.mu.Unlock()
+		response := []byte{request[0], byte(2 * count)}
+		for address := start; address < start+count; address++ {
+			value, ok := s.register(address)
+			if !ok {
+				response = []byte{request[0] | 0x80, 0x02}
+				break
+			}
+			response = binary.BigEndian.AppendUint16(response, value)
+		}
+
+		if s.rtu {
+			frame := append([]byte{s.unit}, response...)
+			conn.Write(binary.LittleEndian.AppendUint16(frame, modbusCRC(frame)))
+		} else {
+			binary.BigEndian.PutUint16(header[4:], uint16(len(response)+1))
+			conn.Write(append(header, response...))
+		}
+	}
+}
+
+func TestModbusReceiver_TCP(t *testing.T) {
+	var mu sync.Mutex
+	polls := map[uint16]int16{}
+	server := newModbusTestServer(t, false, func(address uint16) (uint16, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls[address]++
+		switch address {
+		case 0, 2: // Both count polls; the scales turn them into mV and -0.1 mA steps
+			return uint16(polls[address]), true
+		case 1:
+			return 0, true
+		}
+		return 0, false
+	})
+	defer server.listener.Close()
+
+	receiver, err := NewModbusReceiver(ModbusOptions{
+		Address:         server.address(),
+		Unit:            3,
+		VoltageRegister: 0,
+		CurrentRegister: 2,
+		Format:          ModbusInt16,
+		VoltageScale:    0.001,
+		VoltageOffset:   1,
+		CurrentScale:    -1e-4,
+		PollInterval:    2 * time.Millisecond,
+		ChunkSamples:    5,
+	})
+	if err != nil {
+		t.Fatalf("NewModbusReceiver() error = %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+
+	var voltage, current signal.Signal
+	select {
+	case voltage = <-receiver.GetVoltageChannel():
+		current = <-receiver.GetCurrentChannel()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal pair received")
+	}
+	receiver.Stop()
+	if err := <-result; err != nil {
+		t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+	}
+
+	for i := range voltage.Values {
+		wantVoltage, wantCurrent := float64(i+1)*0.001+1, float64(i+1)*-1e-4
+		if math.Abs(voltage.Values[i]-wantVoltage) > 1e-12 || math.Abs(current.Values[i]-wantCurrent) > 1e-12 {
+			t.Errorf("sample %d = %v V, %v A, want %v V, %v A", i, voltage.Values[i], current.Values[i], wantVoltage, wantCurrent)
+		}
+	}
+	if len(voltage.Values) != 5 || len(current.Values) != 5 {
+		t.Errorf("chunk lengths = %d/%d, want 5", len(voltage.Values), len(current.Values))
+	}
+	if voltage.SampleRate != 500 || !voltage.Timestamp.Equal(current.Timestamp) {
+		t.Errorf("signals at %v Hz, %v/%v, want 500 Hz with equal timestamps", voltage.SampleRate, voltage.Timestamp, current.Timestamp)
+	}
+	if voltage.Metadata.Unit != signal.UnitVolt || current.Metadata.Unit != signal.UnitAmpere {
+		t.Errorf("units = %q/%q, want V/A", voltage.Metadata.Unit, current.Metadata.Unit)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, read := range server.reads {
+		if read != [2]uint16{0, 3} {
+			t.Errorf("read %v, want both readings in one read of registers 0-2", read)
+			break
+		}
+	}
+}
+
+func TestModbusClient_RTU(t *testing.T) {
+	registers := map[uint16]uint16{}
+	put := func(address uint16, v float32) { // Low word first
+		bits := math.Float32bits(v)
+		registers[address], registers[address+1] = uint16(bits), uint16(bits>>16)
+	}
+	put(10, 0.25)
+	put(300, -0.5)
+	server := newModbusTestServer(t, true, func(address uint16) (uint16, bool) {
+		value, ok := registers[address]
+		return value, ok
+	})
+	defer server.listener.Close()
+
+	client, err := dialModbus(server.address(), 3, time.Second)
+	if err != nil {
+		t.Fatalf("dialModbus() error = %v", err)
+	}
+	defer client.Close()
+	receiver := &ModbusReceiver{options: ModbusOptions{
+		VoltageRegister: 10,
+		CurrentRegister: 300,
+		Format:          ModbusFloat32,
+		WordSwap:        true,
+		VoltageScale:    2,
+		CurrentScale:    1,
+	}}
+	voltage, current, err := receiver.read(client)
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if voltage != 0.5 || current != -0.5 {
+		t.Errorf("read() = %v, %v, want 0.5, -0.5", voltage, current)
+	}
+	server.mu.Lock()
+	if len(server.reads) != 2 {
+		t.Errorf("registers 290 apart read with %d requests, want 2", len(server.reads))
+	}
+	server.mu.Unlock()
+
+	if _, err := client.readInputRegisters(20, 1); err == nil || !strings.Contains(err.Error(), "illegal data address") {
+		t.Errorf("readInputRegisters() of a missing register error = %v, want an illegal data address exception", err)
+	}
+}
+
+func TestDecodeModbusValue(t *testing.T) {
+	tests := []struct {
+		registers []uint16
+		format    string
+		wordSwap  bool
+		want      float64
+	}{
+		{[]uint16{0xFFFE}, ModbusInt16, false, -2},
+		{[]uint16{0xFFFE}, ModbusUint16, false, 65534},
+		{[]uint16{0xFFFF, 0xFFFD}, ModbusInt32, false, -3},
+		{[]uint16{0x0001, 0x0000}, ModbusUint32, false, 65536},
+		{[]uint16{0x0000, 0x0001}, ModbusUint32, true, 65536},
+		{[]uint16{0x3FC0, 0x0000}, ModbusFloat32, false, 1.5},
+		{[]uint16{0x0000, 0x3FC0}, ModbusFloat32, true, 1.5},
+	}
+	for _, tt := range tests {
+		if got := decodeModbusValue(tt.registers, tt.format, tt.wordSwap); got != tt.want {
+			t.Errorf("decodeModbusValue(%04X, %s, %v) = %v, want %v", tt.registers, tt.format, tt.wordSwap, got, tt.want)
+		}
+	}
+}
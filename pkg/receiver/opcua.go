@@ -0,0 +1,994 @@
+package receiver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OPC UA binary encoding ids (namespace 0) of the messages the client exchanges
+const (
+	uaOpenSecureChannelRequest   = 446
+	uaOpenSecureChannelResponse  = 449
+	uaCreateSessionRequest       = 461
+	uaCreateSessionResponse      = 464
+	uaActivateSessionRequest     = 467
+	uaActivateSessionResponse    = 470
+	uaCreateMonitoredItemsReq    = 751
+	uaCreateMonitoredItemsResp   = 754
+	uaCreateSubscriptionRequest  = 787
+	uaCreateSubscriptionResponse = 790
+	uaPublishRequest             = 826
+	uaPublishResponse            = 829
+	uaServiceFault               = 397
+	uaAnonymousIdentityToken     = 321
+	uaDataChangeFilter           = 724
+	uaDataChangeNotification     = 811
+)
+
+// uaSecurityPolicyNone is the only security policy the client supports
+const uaSecurityPolicyNone = "http://opcfoundation.org/UA/SecurityPolicy#None"
+
+// uaDefaultPort is the registered port of opc.tcp
+const uaDefaultPort = "4840"
+
+// uaEpochOffset is the number of seconds from 1601-01-01, the origin of OPC UA
+// DateTime values counted in 100 ns ticks, to the Unix epoch
+const uaEpochOffset = 11644473600
+
+// uaNodeID identifies a node; exactly one of the identifier fields is used
+// according to kind
+type uaNodeID struct {
+	namespace uint16
+	kind      byte // 'i' numeric, 's' string, 'g' GUID, 'b' opaque
+	numeric   uint32
+	text      string
+	raw       []byte // GUID or opaque identifier
+}
+
+// parseNodeID parses the standard string form such as "ns=2;s=Cell1.Voltage",
+// "i=2258", "ns=3;g=<guid>" or "ns=1;b=<base64>"
+func parseNodeID(s string) (uaNodeID, error) {
+	var id uaNodeID
+	rest := s
+	if strings.HasPrefix(rest, "ns=") {
+		namespace, identifier, ok := strings.Cut(rest[3:], ";")
+		if !ok {
+			return id, fmt.Errorf("invalid node id '%s'", s)
+		}
+		ns, err := strconv.ParseUint(namespace, 10, 16)
+		if err != nil {
+			return id, fmt.Errorf("invalid namespace in node id '%s'", s)
+		}
+		id.namespace = uint16(ns)
+		rest = identifier
+	}
+	if len(rest) < 3 || rest[1] != '=' {
+		return id, fmt.Errorf("invalid node id '%s'", s)
+	}
+	id.kind = rest[0]
+	value := rest[2:]
+	switch id.kind {
+	case 'i':
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return id, fmt.Errorf("invalid numeric identifier in node id '%s'", s)
+		}
+		id.numeric = uint32(n)
+	case 's':
+		id.text = value
+	case 'g':
+		raw, err := hex.DecodeString(strings.ReplaceAll(value, "-", ""))
+		if err != nil || len(raw) != 16 {
+			return id, fmt.Errorf("invalid GUID in node id '%s'", s)
+		}
+		// Data1..Data3 are little-endian on the wire
+		id.raw = []byte{raw[3], raw[2], raw[1], raw[0], raw[5], raw[4], raw[7], raw[6]}
+		id.raw = append(id.raw, raw[8:]...)
+	case 'b':
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return id, fmt.Errorf("invalid opaque identifier in node id '%s'", s)
+		}
+		id.raw = raw
+	default:
+		return id, fmt.Errorf("unknown identifier type in node id '%s'", s)
+	}
+	return id, nil
+}
+
+// numericNodeID returns a namespace 0 numeric node id
+func numericNodeID(id uint32) uaNodeID {
+	return uaNodeID{kind: 'i', numeric: id}
+}
+
+// uaEncoder appends values in the OPC UA binary encoding
+type uaEncoder struct {
+	buf []byte
+}
+
+func (e *uaEncoder) byte(v byte)      { e.buf = append(e.buf, v) }
+func (e *uaEncoder) uint16(v uint16)  { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *uaEncoder) uint32(v uint32)  { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *uaEncoder) int32(v int32)    { e.uint32(uint32(v)) }
+func (e *uaEncoder) int64(v int64)    { e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *uaEncoder) double(v float64) { e.int64(int64(math.Float64bits(v))) }
+
+func (e *uaEncoder) boolean(v bool) {
+	if v {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+// string writes s, or a null string when s is empty
+func (e *uaEncoder) string(s string) {
+	if s == "" {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// byteString writes b, or a null byte string when b is nil
+func (e *uaEncoder) byteString(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *uaEncoder) dateTime(t time.Time) {
+	if t.IsZero() {
+		e.int64(0)
+		return
+	}
+	e.int64((t.Unix()+uaEpochOffset)*10000000 + int64(t.Nanosecond()/100))
+}
+
+// nodeID writes id in its most compact encoding
+func (e *uaEncoder) nodeID(id uaNodeID) {
+	switch id.kind {
+	case 'i':
+		switch {
+		case id.namespace == 0 && id.numeric <= 0xFF:
+			e.byte(0x00)
+			e.byte(byte(id.numeric))
+		case id.namespace <= 0xFF && id.numeric <= 0xFFFF:
+			e.byte(0x01)
+			e.byte(byte(id.namespace))
+			e.uint16(uint16(id.numeric))
+		default:
+			e.byte(0x02)
+			e.uint16(id.namespace)
+			e.uint32(id.numeric)
+		}
+	case 's':
+		e.byte(0x03)
+		e.uint16(id.namespace)
+		e.string(id.text)
+	case 'g':
+		e.byte(0x04)
+		e.uint16(id.namespace)
+		e.buf = append(e.buf, id.raw...)
+	case 'b':
+		e.byte(0x05)
+		e.uint16(id.namespace)
+		e.byteString(id.raw)
+	default: // Null node id
+		e.byte(0x00)
+		e.byte(0x00)
+	}
+}
+
+// nullExtensionObject writes an extension object without body
+func (e *uaEncoder) nullExtensionObject() {
+	e.nodeID(uaNodeID{})
+	e.byte(0x00)
+}
+
+// extensionObject writes a binary encoded body of the given encoding id
+func (e *uaEncoder) extensionObject(encodingID uint32, body []byte) {
+	e.nodeID(numericNodeID(encodingID))
+	e.byte(0x01)
+	e.byteString(body)
+}
+
+// requestHeader writes the header every service request starts with
+func (e *uaEncoder) requestHeader(authToken uaNodeID, handle uint32, timeout time.Duration) {
+	e.nodeID(authToken)
+	e.dateTime(time.Now())
+	e.uint32(handle)
+	e.uint32(0) // No diagnostics
+	e.string("")
+	e.uint32(uint32(timeout.Milliseconds()))
+	e.nullExtensionObject()
+}
+
+// uaDecoder reads values in the OPC UA binary encoding; the first error sticks
+// and makes all later reads return zero values
+type uaDecoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+// take returns the next n bytes
+func (d *uaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *uaDecoder) byte() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *uaDecoder) boolean() bool { return d.byte() != 0 }
+
+func (d *uaDecoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *uaDecoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *uaDecoder) int32() int32 { return int32(d.uint32()) }
+
+func (d *uaDecoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *uaDecoder) double() float64 { return math.Float64frombits(d.uint64()) }
+
+// byteString returns nil for a null byte string
+func (d *uaDecoder) byteString() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+func (d *uaDecoder) string() string { return string(d.byteString()) }
+
+func (d *uaDecoder) dateTime() time.Time {
+	ticks := int64(d.uint64())
+	if ticks <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ticks/10000000-uaEpochOffset, ticks%10000000*100).UTC()
+}
+
+// arrayLength reads an array length; null arrays have no elements
+func (d *uaDecoder) arrayLength() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.buf)-d.pos { // Every element takes at least one byte
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return n
+}
+
+// nodeID reads a node id or an expanded node id
+func (d *uaDecoder) nodeID() uaNodeID {
+	encoding := d.byte()
+	var id uaNodeID
+	switch encoding & 0x3F {
+	case 0x00:
+		id = uaNodeID{kind: 'i', numeric: uint32(d.byte())}
+	case 0x01:
+		id = uaNodeID{kind: 'i', namespace: uint16(d.byte())}
+		id.numeric = uint32(d.uint16())
+	case 0x02:
+		id = uaNodeID{kind: 'i', namespace: d.uint16()}
+		id.numeric = d.uint32()
+	case 0x03:
+		id = uaNodeID{kind: 's', namespace: d.uint16()}
+		id.text = d.string()
+	case 0x04:
+		id = uaNodeID{kind: 'g', namespace: d.uint16()}
+		id.raw = append([]byte(nil), d.take(16)...)
+	case 0x05:
+		id = uaNodeID{kind: 'b', namespace: d.uint16()}
+		id.raw = append([]byte(nil), d.byteString()...)
+	default:
+		d.fail(fmt.Errorf("unknown node id encoding 0x%02x", encoding))
+	}
+	if encoding&0x80 != 0 { // Namespace URI of an expanded node id
+		d.string()
+	}
+	if encoding&0x40 != 0 { // Server index of an expanded node id
+		d.uint32()
+	}
+	return id
+}
+
+// localizedText skips a localized text
+func (d *uaDecoder) localizedText() {
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		d.string()
+	}
+	if mask&0x02 != 0 {
+		d.string()
+	}
+}
+
+// diagnosticInfo skips a diagnostic info
+func (d *uaDecoder) diagnosticInfo() {
+	mask := d.byte()
+	for _, bit := range []byte{0x01, 0x02, 0x04, 0x08} {
+		if mask&bit != 0 {
+			d.int32()
+		}
+	}
+	if mask&0x10 != 0 {
+		d.string()
+	}
+	if mask&0x20 != 0 {
+		d.uint32()
+	}
+	if mask&0x40 != 0 && d.err == nil {
+		d.diagnosticInfo()
+	}
+}
+
+// extensionObject returns the encoding id and body of an extension object
+func (d *uaDecoder) extensionObject() (uint32, []byte) {
+	id := d.nodeID()
+	if d.byte() == 0x00 {
+		return id.numeric, nil
+	}
+	return id.numeric, d.byteString()
+}
+
+// responseHeader reads the header of a service response and returns its
+// service result as an error if it is bad
+func (d *uaDecoder) responseHeader() error {
+	d.dateTime()
+	d.uint32() // Request handle
+	status := d.uint32()
+	d.diagnosticInfo()
+	for i, n := 0, d.arrayLength(); i < n; i++ {
+		d.string()
+	}
+	d.extensionObject()
+	if d.err != nil {
+		return d.err
+	}
+	return uaStatusError(status)
+}
+
+// variant reads a numeric scalar or array as float64 values
+func (d *uaDecoder) variant() ([]float64, error) {
+	mask := d.byte()
+	count := 1
+	if mask&0x80 != 0 {
+		count = d.arrayLength()
+	}
+	values := make([]float64, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		var v float64
+		switch mask & 0x3F {
+		case 1, 2, 3: // Boolean, SByte, Byte
+			b := d.byte()
+			if mask&0x3F == 2 {
+				v = float64(int8(b))
+			} else {
+				v = float64(b)
+			}
+		case 4:
+			v = float64(int16(d.uint16()))
+		case 5:
+			v = float64(d.uint16())
+		case 6:
+			v = float64(d.int32())
+		case 7:
+			v = float64(d.uint32())
+		case 8:
+			v = float64(int64(d.uint64()))
+		case 9:
+			v = float64(d.uint64())
+		case 10:
+			v = float64(math.Float32frombits(d.uint32()))
+		case 11:
+			v = d.double()
+		default:
+			return nil, fmt.Errorf("unsupported variant type %d, expected a number", mask&0x3F)
+		}
+		values = append(values, v)
+	}
+	if mask&0x40 != 0 { // Array dimensions
+		for i, n := 0, d.arrayLength(); i < n; i++ {
+			d.int32()
+		}
+	}
+	return values, d.err
+}
+
+// uaDataValue is a decoded data value with numeric contents
+type uaDataValue struct {
+	values          []float64
+	status          uint32
+	sourceTimestamp time.Time
+	serverTimestamp time.Time
+}
+
+// dataValue reads a data value
+func (d *uaDecoder) dataValue() (uaDataValue, error) {
+	var value uaDataValue
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		values, err := d.variant()
+		if err != nil {
+			return value, err
+		}
+		value.values = values
+	}
+	if mask&0x02 != 0 {
+		value.status = d.uint32()
+	}
+	if mask&0x04 != 0 {
+		value.sourceTimestamp = d.dateTime()
+	}
+	if mask&0x10 != 0 {
+		d.uint16()
+	}
+	if mask&0x08 != 0 {
+		value.serverTimestamp = d.dateTime()
+	}
+	if mask&0x20 != 0 {
+		d.uint16()
+	}
+	return value, d.err
+}
+
+// fail records err unless an earlier error is pending
+func (d *uaDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// uaStatusError returns a bad status code as an error, nil otherwise
+func uaStatusError(status uint32) error {
+	if status&0x80000000 == 0 {
+		return nil
+	}
+	return fmt.Errorf("opc ua status 0x%08X", status)
+}
+
+// uaClient is a minimal OPC UA client over opc.tcp without message security.
+// It runs one request at a time, so it must not be used concurrently.
+type uaClient struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	endpoint    string
+	timeout     time.Duration
+	channelID   uint32
+	tokenID     uint32
+	renewAt     time.Time
+	sequence    uint32
+	requestID   uint32
+	authToken   uaNodeID
+	closeOnce   sync.Once
+	maxResponse int
+}
+
+// dialOPCUA connects to an opc.tcp endpoint and opens a secure channel with
+// the None security policy
+func dialOPCUA(ctx context.Context, endpoint string, timeout time.Duration) (*uaClient, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "opc.tcp" {
+		return nil, fmt.Errorf("unsupported opc ua scheme '%s'", parsed.Scheme)
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), uaDefaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	c := &uaClient{
+		conn:        conn,
+		reader:      bufio.NewReader(conn),
+		endpoint:    endpoint,
+		timeout:     timeout,
+		maxResponse: 64 << 20,
+	}
+	if err := c.hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.openSecureChannel(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// hello exchanges the HEL and ACK messages
+func (c *uaClient) hello() error {
+	var e uaEncoder
+	e.uint32(0)       // Protocol version
+	e.uint32(1 << 16) // Receive buffer size
+	e.uint32(1 << 16) // Send buffer size
+	e.uint32(0)       // Max message size: no limit
+	e.uint32(0)       // Max chunk count: no limit
+	e.string(c.endpoint)
+	if err := c.writeMessage("HEL", e.buf); err != nil {
+		return err
+	}
+
+	messageType, body, err := c.readChunk(c.timeout)
+	if err != nil {
+		return err
+	}
+	if messageType != "ACK" {
+		return fmt.Errorf("expected ACK from opc ua server, got %s", messageType)
+	}
+	d := &uaDecoder{buf: body}
+	d.uint32() // Protocol version
+	d.uint32() // Receive buffer size
+	d.uint32() // Send buffer size
+	if maxMessage := d.uint32(); maxMessage > 0 && int(maxMessage) < c.maxResponse {
+		c.maxResponse = int(maxMessage)
+	}
+	return d.err
+}
+
+// openSecureChannel issues or renews the security token of the channel
+func (c *uaClient) openSecureChannel(renew bool) error {
+	var body uaEncoder
+	body.nodeID(numericNodeID(uaOpenSecureChannelRequest))
+	body.requestHeader(uaNodeID{}, c.nextRequestID(), c.timeout)
+	body.uint32(0) // Client protocol version
+	if renew {
+		body.int32(1)
+	} else {
+		body.int32(0)
+	}
+	body.int32(1) // Security mode None
+	body.byteString([]byte{})
+	body.uint32(uint32(time.Hour.Milliseconds()))
+
+	var e uaEncoder
+	e.uint32(c.channelID)
+	e.string(uaSecurityPolicyNone)
+	e.byteString(nil) // Sender certificate
+	e.byteString(nil) // Receiver certificate thumbprint
+	e.uint32(c.nextSequence())
+	e.uint32(c.requestID)
+	e.buf = append(e.buf, body.buf...)
+	if err := c.writeMessage("OPN", e.buf); err != nil {
+		return err
+	}
+
+	d, err := c.readResponse(uaOpenSecureChannelResponse, c.timeout)
+	if err != nil {
+		return err
+	}
+	d.uint32() // Server protocol version
+	c.channelID = d.uint32()
+	c.tokenID = d.uint32()
+	d.dateTime()
+	lifetime := time.Duration(d.uint32()) * time.Millisecond
+	if d.err != nil {
+		return d.err
+	}
+	c.renewAt = time.Now().Add(lifetime * 3 / 4)
+	return nil
+}
+
+// call sends a service request and decodes the header of its response;
+// the returned decoder is positioned after the response header
+func (c *uaClient) call(requestType uint32, body func(e *uaEncoder), responseType uint32, timeout time.Duration) (*uaDecoder, error) {
+	if time.Now().After(c.renewAt) {
+		if err := c.openSecureChannel(true); err != nil {
+			return nil, fmt.Errorf("renewing secure channel: %w", err)
+		}
+	}
+
+	var request uaEncoder
+	request.nodeID(numericNodeID(requestType))
+	request.requestHeader(c.authToken, c.nextRequestID(), timeout)
+	body(&request)
+
+	var e uaEncoder
+	e.uint32(c.channelID)
+	e.uint32(c.tokenID)
+	e.uint32(c.nextSequence())
+	e.uint32(c.requestID)
+	e.buf = append(e.buf, request.buf...)
+	if err := c.writeMessage("MSG", e.buf); err != nil {
+		return nil, err
+	}
+	return c.readResponse(responseType, timeout+c.timeout)
+}
+
+// readResponse reads chunks until a complete response arrives and checks
+// its type and service result
+func (c *uaClient) readResponse(responseType uint32, timeout time.Duration) (*uaDecoder, error) {
+	var message []byte
+	for {
+		messageType, chunk, err := c.readChunk(timeout)
+		if err != nil {
+			return nil, err
+		}
+		d := &uaDecoder{buf: chunk}
+		d.uint32() // Secure channel id
+		if messageType == "OPN" {
+			d.string()     // Security policy
+			d.byteString() // Sender certificate
+			d.byteString() // Receiver thumbprint
+		} else {
+			d.uint32() // Token id
+		}
+		d.uint32() // Sequence number
+		d.uint32() // Request id
+		if d.err != nil {
+			return nil, d.err
+		}
+
+		final := chunk[len(chunk)-1] // Chunk type, appended by readChunk
+		payload := d.buf[d.pos : len(chunk)-1]
+		switch final {
+		case 'A':
+			abort := &uaDecoder{buf: payload}
+			status := abort.uint32()
+			return nil, fmt.Errorf("opc ua server aborted the response: %v %s", uaStatusError(status), abort.string())
+		case 'C':
+			message = append(message, payload...)
+			if len(message) > c.maxResponse {
+				return nil, fmt.Errorf("opc ua response exceeds %d bytes", c.maxResponse)
+			}
+			continue
+		}
+		message = append(message, payload...)
+		break
+	}
+
+	d := &uaDecoder{buf: message}
+	typeID := d.nodeID()
+	if typeID.numeric == uaServiceFault {
+		if err := d.responseHeader(); err != nil {
+			return nil, fmt.Errorf("opc ua service fault: %w", err)
+		}
+		return nil, errors.New("opc ua service fault")
+	}
+	if typeID.numeric != responseType {
+		return nil, fmt.Errorf("unexpected opc ua response type %d, want %d", typeID.numeric, responseType)
+	}
+	if err := d.responseHeader(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readChunk reads one message chunk; the chunk type byte ('F', 'C' or 'A')
+// is appended to the returned body
+func (c *uaClient) readChunk(timeout time.Duration) (string, []byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	var header [8]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return "", nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header[4:]))
+	if size < 8 || size > c.maxResponse+8 {
+		return "", nil, fmt.Errorf("invalid opc ua message size %d", size)
+	}
+	body := make([]byte, size-8, size-7)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return "", nil, err
+	}
+	messageType := string(header[:3])
+	if messageType == "ERR" {
+		d := &uaDecoder{buf: body}
+		status := d.uint32()
+		return "", nil, fmt.Errorf("opc ua server error: %v %s", uaStatusError(status), d.string())
+	}
+	return messageType, append(body, header[3]), nil
+}
+
+// writeMessage writes a single final chunk
+func (c *uaClient) writeMessage(messageType string, body []byte) error {
+	message := make([]byte, 0, len(body)+8)
+	message = append(message, messageType...)
+	message = append(message, 'F')
+	message = binary.LittleEndian.AppendUint32(message, uint32(len(body)+8))
+	message = append(message, body...)
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(message)
+	return err
+}
+
+func (c *uaClient) nextSequence() uint32 {
+	c.sequence++
+	return c.sequence
+}
+
+func (c *uaClient) nextRequestID() uint32 {
+	c.requestID++
+	return c.requestID
+}
+
+// createSession creates and activates an anonymous session
+func (c *uaClient) createSession(name string, sessionTimeout time.Duration) error {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	d, err := c.call(uaCreateSessionRequest, func(e *uaEncoder) {
+		e.string("urn:masterapp:client") // Application URI
+		e.string("urn:masterapp")        // Product URI
+		e.byte(0x02)                     // Localized application name
+		e.string("masterapp")
+		e.int32(1) // Client application
+		e.string("")
+		e.string("")
+		e.int32(-1) // Discovery URLs
+		e.string("")
+		e.string(c.endpoint)
+		e.string(name)
+		e.byteString(nonce)
+		e.byteString(nil) // Client certificate
+		e.double(float64(sessionTimeout.Milliseconds()))
+		e.uint32(0) // Max response message size: no limit
+	}, uaCreateSessionResponse, c.timeout)
+	if err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+
+	d.nodeID() // Session id
+	c.authToken = d.nodeID()
+	d.double()     // Revised session timeout
+	d.byteString() // Server nonce
+	d.byteString() // Server certificate
+	policyID := ""
+	for i, n := 0, d.arrayLength(); i < n && d.err == nil; i++ {
+		if id, ok := d.anonymousPolicy(); ok && policyID == "" {
+			policyID = id
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("decoding session: %w", d.err)
+	}
+	if policyID == "" {
+		return errors.New("opc ua server offers no anonymous login without message security")
+	}
+
+	_, err = c.call(uaActivateSessionRequest, func(e *uaEncoder) {
+		e.string("") // Client signature algorithm
+		e.byteString(nil)
+		e.int32(-1) // Client software certificates
+		e.int32(-1) // Locale ids
+		var token uaEncoder
+		token.string(policyID)
+		e.extensionObject(uaAnonymousIdentityToken, token.buf)
+		e.string("") // User token signature algorithm
+		e.byteString(nil)
+	}, uaActivateSessionResponse, c.timeout)
+	if err != nil {
+		return fmt.Errorf("activating session: %w", err)
+	}
+	return nil
+}
+
+// anonymousPolicy reads an endpoint description and returns the policy id
+// of its anonymous user token if the endpoint uses no message security
+func (d *uaDecoder) anonymousPolicy() (string, bool) {
+	d.string() // Endpoint URL
+	d.string() // Application URI
+	d.string() // Product URI
+	d.localizedText()
+	d.int32() // Application type
+	d.string()
+	d.string()
+	for i, n := 0, d.arrayLength(); i < n; i++ {
+		d.string()
+	}
+	d.byteString() // Server certificate
+	securityMode := d.int32()
+	securityPolicy := d.string()
+
+	policyID, found := "", false
+	for i, n := 0, d.arrayLength(); i < n; i++ {
+		id := d.string()
+		tokenType := d.int32()
+		d.string() // Issued token type
+		d.string() // Issuer endpoint URL
+		d.string() // Security policy URI
+		if tokenType == 0 && !found {
+			policyID, found = id, true
+		}
+	}
+	d.string() // Transport profile URI
+	d.byte()   // Security level
+	if securityMode != 1 || securityPolicy != uaSecurityPolicyNone {
+		return "", false
+	}
+	return policyID, found
+}
+
+// createSubscription creates a subscription publishing at interval
+func (c *uaClient) createSubscription(interval time.Duration) (uint32, time.Duration, error) {
+	d, err := c.call(uaCreateSubscriptionRequest, func(e *uaEncoder) {
+		e.double(float64(interval) / float64(time.Millisecond))
+		e.uint32(60) // Lifetime count
+		e.uint32(10) // Max keep-alive count
+		e.uint32(0)  // Notifications per publish: no limit
+		e.boolean(true)
+		e.byte(0)
+	}, uaCreateSubscriptionResponse, c.timeout)
+	if err != nil {
+		return 0, 0, fmt.Errorf("creating subscription: %w", err)
+	}
+	id := d.uint32()
+	revised := time.Duration(d.double() * float64(time.Millisecond))
+	return id, revised, d.err
+}
+
+// uaMonitoredItem is a node to sample with its client handle
+type uaMonitoredItem struct {
+	node      uaNodeID
+	handle    uint32
+	queueSize uint32
+}
+
+// createMonitoredItems samples the value attribute of every item at interval
+// and reports each sample, also unchanged values, with its source timestamp
+func (c *uaClient) createMonitoredItems(subscription uint32, items []uaMonitoredItem, interval time.Duration) error {
+	d, err := c.call(uaCreateMonitoredItemsReq, func(e *uaEncoder) {
+		e.uint32(subscription)
+		e.int32(2) // Return source and server timestamps
+		e.int32(int32(len(items)))
+		for _, item := range items {
+			e.nodeID(item.node)
+			e.uint32(13) // Value attribute
+			e.string("")
+			e.uint16(0) // Default data encoding
+			e.string("")
+			e.int32(2) // Reporting
+			e.uint32(item.handle)
+			e.double(float64(interval) / float64(time.Millisecond))
+			var filter uaEncoder
+			filter.int32(2)  // Trigger on status, value or timestamp
+			filter.uint32(0) // No deadband
+			filter.double(0)
+			e.extensionObject(uaDataChangeFilter, filter.buf)
+			e.uint32(item.queueSize)
+			e.boolean(true) // Discard oldest
+		}
+	}, uaCreateMonitoredItemsResp, c.timeout)
+	if err != nil {
+		return fmt.Errorf("creating monitored items: %w", err)
+	}
+
+	n := d.arrayLength()
+	for i := 0; i < n; i++ {
+		status := d.uint32()
+		d.uint32() // Monitored item id
+		d.double() // Revised sampling interval
+		d.uint32() // Revised queue size
+		d.extensionObject()
+		if err := uaStatusError(status); err != nil && i < len(items) {
+			return fmt.Errorf("monitoring node handle %d: %w", items[i].handle, err)
+		}
+	}
+	return d.err
+}
+
+// uaSample is one monitored item update
+type uaSample struct {
+	handle uint32
+	value  uaDataValue
+}
+
+// publish acknowledges the previous notification and waits for the next one,
+// returning its samples and sequence number (0 for keep-alives)
+func (c *uaClient) publish(subscription, acknowledge uint32, wait time.Duration) ([]uaSample, uint32, error) {
+	d, err := c.call(uaPublishRequest, func(e *uaEncoder) {
+		if acknowledge == 0 {
+			e.int32(0)
+			return
+		}
+		e.int32(1)
+		e.uint32(subscription)
+		e.uint32(acknowledge)
+	}, uaPublishResponse, wait)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	d.uint32() // Subscription id
+	for i, n := 0, d.arrayLength(); i < n; i++ {
+		d.uint32() // Available sequence numbers
+	}
+	d.boolean() // More notifications
+	sequence := d.uint32()
+	d.dateTime()
+
+	var samples []uaSample
+	notifications := d.arrayLength()
+	for i := 0; i < notifications && d.err == nil; i++ {
+		encoding, body := d.extensionObject()
+		if encoding != uaDataChangeNotification {
+			continue // Status change or event notifications
+		}
+		n := &uaDecoder{buf: body}
+		for j, items := 0, n.arrayLength(); j < items && n.err == nil; j++ {
+			handle := n.uint32()
+			value, err := n.dataValue()
+			if err != nil {
+				return nil, 0, fmt.Errorf("decoding monitored item %d: %w", handle, err)
+			}
+			samples = append(samples, uaSample{handle: handle, value: value})
+		}
+		if n.err != nil {
+			return nil, 0, n.err
+		}
+	}
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+	if notifications == 0 {
+		sequence = 0 // Keep-alive: nothing to acknowledge
+	}
+	return samples, sequence, nil
+}
+
+// Close closes the connection; it is safe to call from another goroutine to
+// abort a pending publish
+func (c *uaClient) Close() error {
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+	})
+	return nil
+}
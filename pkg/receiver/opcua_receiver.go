@@ -0,0 +1,247 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Client handles of the monitored items
+const (
+	opcuaVoltageHandle = 1
+	opcuaCurrentHandle = 2
+)
+
+// OPCUAOptions selects the server, nodes and sampling of an OPCUAReceiver
+type OPCUAOptions struct {
+	Endpoint           string        // opc.tcp:// endpoint URL
+	VoltageNode        string        // Node id of the voltage variable, e.g. "ns=2;s=Cell1.Voltage"
+	CurrentNode        string        // Node id of the current variable
+	SamplingInterval   time.Duration // Server-side sampling interval of both nodes
+	PublishingInterval time.Duration // Interval at which the server sends queued samples
+	SampleRate         float64       // Sample rate of the values; 0 derives it from SamplingInterval
+	ChunkSamples       int           // Samples per emitted signal
+}
+
+// OPCUAReceiver subscribes to a voltage and a current variable on an OPC UA
+// server and cuts their updates into signal chunks. Every update contributes
+// its value, or all elements of an array value, as consecutive samples.
+// Updates with a bad status are skipped. Lost connections are re-established
+// with backoff until the receiver is stopped.
+type OPCUAReceiver struct {
+	options        OPCUAOptions
+	voltageNode    uaNodeID
+	currentNode    uaNodeID
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	timeout        time.Duration
+	lifecycle      *lifecycle
+	stats          statsTracker
+	mu             sync.Mutex
+	client         *uaClient
+}
+
+// NewOPCUAReceiver creates a receiver for the given server and nodes
+func NewOPCUAReceiver(options OPCUAOptions) (*OPCUAReceiver, error) {
+	if !strings.HasPrefix(options.Endpoint, "opc.tcp://") {
+		return nil, config.NewValidationError("OPCUAEndpoint", fmt.Sprintf("endpoint '%s' must start with opc.tcp://", options.Endpoint))
+	}
+	voltageNode, err := parseNodeID(options.VoltageNode)
+	if err != nil {
+		return nil, config.NewValidationError("OPCUAVoltageNode", err.Error())
+	}
+	currentNode, err := parseNodeID(options.CurrentNode)
+	if err != nil {
+		return nil, config.NewValidationError("OPCUACurrentNode", err.Error())
+	}
+	if options.SamplingInterval <= 0 {
+		return nil, config.NewValidationError("OPCUASamplingInterval", "sampling interval must be positive")
+	}
+	if options.PublishingInterval <= 0 {
+		return nil, config.NewValidationError("OPCUAPublishingInterval", "publishing interval must be positive")
+	}
+	if options.SampleRate < 0 {
+		return nil, config.NewValidationError("OPCUASampleRate", "sample rate cannot be negative")
+	}
+	if options.SampleRate == 0 {
+		options.SampleRate = float64(time.Second) / float64(options.SamplingInterval)
+	}
+	if options.ChunkSamples <= 0 {
+		return nil, config.NewValidationError("ChunkSamples", "chunk size must be positive")
+	}
+
+	return &OPCUAReceiver{
+		options:        options,
+		voltageNode:    voltageNode,
+		currentNode:    currentNode,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		timeout:        10 * time.Second,
+		lifecycle:      newLifecycle(),
+	}, nil
+}
+
+// StartReceiving subscribes to the nodes and emits signal pairs until ctx is
+// cancelled or Stop is called. The signal channels are closed when it returns.
+func (ur *OPCUAReceiver) StartReceiving(ctx context.Context) error {
+	defer ur.lifecycle.closeChannels(ur.voltageChannel, ur.currentChannel)
+
+	ur.stats.start(0, time.Duration(float64(ur.options.ChunkSamples)/ur.options.SampleRate*float64(time.Second)))
+	defer ur.stats.stop()
+	log.Printf("Starting OPC UA reception from %s (voltage %s, current %s)", ur.options.Endpoint, ur.options.VoltageNode, ur.options.CurrentNode)
+
+	assembler := newSampleAssembler(ur.options.SampleRate, ur.options.ChunkSamples)
+	return reconnect(ctx, ur.lifecycle, ur.options.Endpoint, func() error {
+		assembler.reset()
+		return ur.session(ctx, assembler)
+	})
+}
+
+// session runs one connection: it sets up the subscription and publishes
+// until the connection fails or the receiver stops
+func (ur *OPCUAReceiver) session(ctx context.Context, assembler *sampleAssembler) error {
+	dialCtx, cancel := context.WithTimeout(ctx, ur.timeout)
+	client, err := dialOPCUA(dialCtx, ur.options.Endpoint, ur.timeout)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !ur.attach(client) {
+		client.Close()
+		return nil
+	}
+	defer ur.detach(client)
+
+	// Abort a pending publish when the context ends
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-watchDone:
+		}
+	}()
+
+	if err := client.createSession("masterapp", time.Minute); err != nil {
+		return err
+	}
+	subscription, interval, err := client.createSubscription(ur.options.PublishingInterval)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		interval = ur.options.PublishingInterval
+	}
+	// Keep two publishing intervals of samples so none are lost between publishes
+	queueSize := uint32(2*interval/ur.options.SamplingInterval) + 1
+	items := []uaMonitoredItem{
+		{node: ur.voltageNode, handle: opcuaVoltageHandle, queueSize: queueSize},
+		{node: ur.currentNode, handle: opcuaCurrentHandle, queueSize: queueSize},
+	}
+	if err := client.createMonitoredItems(subscription, items, ur.options.SamplingInterval); err != nil {
+		return err
+	}
+	log.Printf("Subscribed to OPC UA server %s (publishing every %v)", ur.options.Endpoint, interval)
+
+	var acknowledge uint32
+	for {
+		samples, sequence, err := client.publish(subscription, acknowledge, 12*interval)
+		if err != nil {
+			return err
+		}
+		acknowledge = sequence
+		ur.consume(samples, assembler)
+	}
+}
+
+// consume feeds monitored item updates to the assembler and emits every
+// completed chunk pair
+func (ur *OPCUAReceiver) consume(samples []uaSample, assembler *sampleAssembler) {
+	for _, sample := range samples {
+		if uaStatusError(sample.value.status) != nil || len(sample.value.values) == 0 {
+			continue
+		}
+		t := sample.value.sourceTimestamp
+		if t.IsZero() {
+			t = sample.value.serverTimestamp
+		}
+		if t.IsZero() {
+			t = time.Now()
+		}
+		switch sample.handle {
+		case opcuaVoltageHandle:
+			assembler.addVoltage(t, sample.value.values...)
+		case opcuaCurrentHandle:
+			assembler.addCurrent(t, sample.value.values...)
+		}
+	}
+
+	for {
+		voltage, current, ok := assembler.next()
+		if !ok {
+			return
+		}
+		emitPair(ur.voltageChannel, ur.currentChannel, voltage, current, &ur.stats)
+		ur.stats.advance()
+	}
+}
+
+// attach makes client the active connection unless the receiver has stopped
+func (ur *OPCUAReceiver) attach(client *uaClient) bool {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	select {
+	case <-ur.lifecycle.stopping():
+		return false
+	default:
+	}
+	ur.client = client
+	return true
+}
+
+// detach closes client; the server discards the session once it times out
+func (ur *OPCUAReceiver) detach(client *uaClient) {
+	ur.mu.Lock()
+	ur.client = nil
+	ur.mu.Unlock()
+	client.Close()
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (ur *OPCUAReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return ur.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (ur *OPCUAReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return ur.currentChannel
+}
+
+// Done returns nil: a subscription never runs out of data
+func (ur *OPCUAReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (ur *OPCUAReceiver) Stats() Stats {
+	return ur.stats.snapshot()
+}
+
+// Stop asks the receiver to stop and aborts a pending publish; the channels
+// are closed once StartReceiving returns. It is safe to call more than once.
+func (ur *OPCUAReceiver) Stop() error {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	ur.lifecycle.stop()
+	if ur.client != nil {
+		ur.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+package receiver
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// uaTestServer is a scripted OPC UA server that accepts one connection,
+// answers the session and subscription setup, delivers the given
+// notification in its first publish response and keep-alives afterwards
+type uaTestServer struct {
+	listener     net.Listener
+	notification []byte                 // DataChangeNotification body
+	acks         chan []uint32          // Acknowledged sequence numbers per publish
+	tokens       chan string            // Identity token policy ids of ActivateSession
+	items        chan []uaMonitoredItem // Monitored items as requested
+	done         chan struct{}
+	t            *testing.T
+}
+
+func newUATestServer(t *testing.T, notification []byte) *uaTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &uaTestServer{
+		listener:     listener,
+		notification: notification,
+		acks:         make(chan []uint32, 64),
+		tokens:       make(chan string, 1),
+		items:        make(chan []uaMonitoredItem, 1),
+		done:         make(chan struct{}),
+		t:            t,
+	}
+	go s.serve()
+	return s
+}
+
+func (s *uaTestServer) endpoint() string {
+	return "opc.tcp://" + s.listener.Addr().String() + "/masterapp"
+}
+
+func (s *uaTestServer) Close() {
+	s.listener.Close()
+	<-s.done
+}
+
+// write sends one final chunk
+func (s *uaTestServer) write(conn net.Conn, messageType string, body []byte) {
+	message := append([]byte(messageType+"F"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)+8))...)
+	conn.Write(append(message, body...))
+}
+
+// read returns the type and body of the next chunk
+func (s *uaTestServer) read(reader *bufio.Reader) (string, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return "", nil, err
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header[4:])-8)
+	_, err := io.ReadFull(reader, body)
+	return string(header[:3]), body, err
+}
+
+// responseHeader writes a good response header
+func uaTestResponseHeader(e *uaEncoder) {
+	e.dateTime(time.Now())
+	e.uint32(0)
+	e.uint32(0)
+	e.byte(0)   // No diagnostics
+	e.int32(-1) // No string table
+	e.nullExtensionObject()
+}
+
+func (s *uaTestServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if messageType, _, err := s.read(reader); err != nil || messageType != "HEL" {
+		s.t.Errorf("expected HEL, got %s (%v)", messageType, err)
+		return
+	}
+	var ack uaEncoder
+	for _, v := range []uint32{0, 1 << 16, 1 << 16, 0, 0} {
+		ack.uint32(v)
+	}
+	s.write(conn, "ACK", ack.buf)
+
+	sequence := uint32(0)
+	for {
+		messageType, body, err := s.read(reader)
+		if err != nil {
+			return
+		}
+		d := &uaDecoder{buf: body}
+		d.uint32() // Channel id
+		if messageType == "OPN" {
+			d.string()
+			d.byteString()
+			d.byteString()
+		} else {
+			d.uint32() // Token id
+		}
+		d.uint32()
+		requestID := d.uint32()
+		typeID := d.nodeID().numeric
+		d.nodeID() // Authentication token
+		d.dateTime()
+		d.uint32()
+		d.uint32()
+		d.string()
+		d.uint32()
+		d.extensionObject()
+		if d.err != nil {
+			s.t.Errorf("malformed %s request: %v", messageType, d.err)
+			return
+		}
+
+		var response uaEncoder
+		switch typeID {
+		case uaOpenSecureChannelRequest:
+			response.nodeID(numericNodeID(uaOpenSecureChannelResponse))
+			uaTestResponseHeader(&response)
+			response.uint32(0)
+			response.uint32(7) // Channel id
+			response.uint32(1) // Token id
+			response.dateTime(time.Now())
+			response.uint32(3600000)
+			response.byteString(nil)
+			var message uaEncoder
+			message.uint32(7)
+			message.string(uaSecurityPolicyNone)
+			message.byteString(nil)
+			message.byteString(nil)
+			message.uint32(1)
+			message.uint32(requestID)
+			s.write(conn, "OPN", append(message.buf, response.buf...))
+			continue
+		case uaCreateSessionRequest:
+			response.nodeID(numericNodeID(uaCreateSessionResponse))
+			uaTestResponseHeader(&response)
+			response.nodeID(uaNodeID{kind: 'i', namespace: 1, numeric: 100})
+			response.nodeID(uaNodeID{kind: 'b', namespace: 0, raw: []byte("token")})
+			response.double(60000)
+			response.byteString(nil)
+			response.byteString(nil)
+			response.int32(1) // One endpoint
+			response.string(s.endpoint())
+			response.string("urn:test")
+			response.string("")
+			response.byte(0) // Empty application name
+			response.int32(0)
+			response.string("")
+			response.string("")
+			response.int32(-1)
+			response.byteString(nil)
+			response.int32(1) // Security mode None
+			response.string(uaSecurityPolicyNone)
+			response.int32(2)
+			for _, token := range []struct {
+				id        string
+				tokenType int32
+			}{{"username", 1}, {"anonymous", 0}} {
+				response.string(token.id)
+				response.int32(token.tokenType)
+				response.string("")
+				response.string("")
+				response.string("")
+			}
+			response.string("")
+			response.byte(0)
+		case uaActivateSessionRequest:
+			d.string()
+			d.byteString()
+			d.arrayLength()
+			d.arrayLength()
+			_, token := d.extensionObject()
+			s.tokens <- (&uaDecoder{buf: token}).string()
+			response.nodeID(numericNodeID(uaActivateSessionResponse))
+			uaTestResponseHeader(&response)
+		case uaCreateSubscriptionRequest:
+			response.nodeID(numericNodeID(uaCreateSubscriptionResponse))
+			uaTestResponseHeader(&response)
+			response.uint32(42)
+			response.double(d.double())
+			response.uint32(60)
+			response.uint32(10)
+		case uaCreateMonitoredItemsReq:
+			d.uint32()
+			d.int32()
+			var items []uaMonitoredItem
+			for i, n := 0, d.arrayLength(); i < n; i++ {
+				node := d.nodeID()
+				d.uint32()
+				d.string()
+				d.uint16()
+				d.string()
+				d.int32()
+				handle := d.uint32()
+				d.double()
+				d.extensionObject()
+				queueSize := d.uint32()
+				d.boolean()
+				items = append(items, uaMonitoredItem{node: node, handle: handle, queueSize: queueSize})
+			}
+			s.items <- items
+			response.nodeID(numericNodeID(uaCreateMonitoredItemsResp))
+			uaTestResponseHeader(&response)
+			response.int32(int32(len(items)))
+			for i := range items {
+				response.uint32(0)
+				response.uint32(uint32(i + 1))
+				response.double(1)
+				response.uint32(items[i].queueSize)
+				response.nullExtensionObject()
+			}
+			response.int32(-1)
+		case uaPublishRequest:
+			var acks []uint32
+			for i, n := 0, d.arrayLength(); i < n; i++ {
+				d.uint32()
+				acks = append(acks, d.uint32())
+			}
+			select {
+			case s.acks <- acks:
+			default:
+			}
+			response.nodeID(numericNodeID(uaPublishResponse))
+			uaTestResponseHeader(&response)
+			response.uint32(42)
+			response.int32(-1)
+			response.boolean(false)
+			if sequence == 0 {
+				sequence++
+				response.uint32(sequence)
+				response.dateTime(time.Now())
+				response.int32(2)
+				response.extensionObject(uaDataChangeNotification, s.notification)
+				var status uaEncoder // StatusChangeNotification, ignored by the client
+				status.uint32(0)
+				status.byte(0)
+				response.extensionObject(820, status.buf)
+			} else {
+				time.Sleep(20 * time.Millisecond)
+				response.uint32(sequence + 1) // Keep-alives announce the next sequence number
+				response.dateTime(time.Now())
+				response.int32(0)
+			}
+			response.int32(-1)
+			response.int32(-1)
+		default:
+			s.t.Errorf("unexpected request type %d", typeID)
+			return
+		}
+
+		var message uaEncoder
+		message.uint32(7)
+		message.uint32(1)
+		message.uint32(1)
+		message.uint32(requestID)
+		s.write(conn, "MSG", append(message.buf, response.buf...))
+	}
+}
+
+// uaTestNotification encodes a DataChangeNotification: the voltage as one
+// array update starting at start and the current as scalar updates whose
+// first sample is taken two sampling intervals later
+func uaTestNotification(start time.Time, voltage, current []float64) []byte {
+	var e uaEncoder
+	e.int32(int32(len(current) + 2))
+	e.uint32(opcuaVoltageHandle)
+	e.byte(0x01 | 0x04)
+	e.byte(0x80 | 11)
+	e.int32(int32(len(voltage)))
+	for _, v := range voltage {
+		e.double(v)
+	}
+	e.dateTime(start)
+
+	// An update with a bad status, e.g. while the sensor reconnects
+	e.uint32(opcuaCurrentHandle)
+	e.byte(0x01 | 0x02 | 0x04)
+	e.byte(11)
+	e.double(99)
+	e.uint32(0x80320000)
+	e.dateTime(start)
+
+	for i, c := range current {
+		e.uint32(opcuaCurrentHandle)
+		e.byte(0x01 | 0x04 | 0x08)
+		e.byte(10) // Float
+		e.uint32(math.Float32bits(float32(c)))
+		e.dateTime(start.Add(time.Duration(i+2) * time.Millisecond))
+		e.dateTime(time.Now())
+	}
+	return e.buf
+}
+
+func TestOPCUAReceiver_Subscription(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := newUATestServer(t, uaTestNotification(start,
+		[]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8},
+		[]float64{1, 2, 3, 4, 5}))
+	defer server.Close()
+
+	receiver, err := NewOPCUAReceiver(OPCUAOptions{
+		Endpoint:           server.endpoint(),
+		VoltageNode:        "ns=2;s=Cell1.Voltage",
+		CurrentNode:        "ns=2;i=5001",
+		SamplingInterval:   time.Millisecond,
+		PublishingInterval: 10 * time.Millisecond,
+		ChunkSamples:       4,
+	})
+	if err != nil {
+		t.Fatalf("NewOPCUAReceiver() error = %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+
+	var voltage, current signal.Signal
+	select {
+	case voltage = <-receiver.GetVoltageChannel():
+		current = <-receiver.GetCurrentChannel()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal pair received")
+	}
+
+	// Wait for the publish acknowledging the notification
+	for acks := range server.acks {
+		if len(acks) > 0 {
+			if !reflect.DeepEqual(acks, []uint32{1}) {
+				t.Errorf("acknowledged sequence numbers = %v, want [1]", acks)
+			}
+			break
+		}
+	}
+	receiver.Stop()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartReceiving() did not return after Stop")
+	}
+
+	wantStart := start.Add(2 * time.Millisecond)
+	if !voltage.Timestamp.Equal(wantStart) || !current.Timestamp.Equal(wantStart) {
+		t.Errorf("timestamps = %v/%v, want %v", voltage.Timestamp, current.Timestamp, wantStart)
+	}
+	if !reflect.DeepEqual(voltage.Values, []float64{0.3, 0.4, 0.5, 0.6}) {
+		t.Errorf("voltage = %v, want the samples aligned with the current", voltage.Values)
+	}
+	if !reflect.DeepEqual(current.Values, []float64{1, 2, 3, 4}) {
+		t.Errorf("current = %v, want [1 2 3 4]", current.Values)
+	}
+	if voltage.SampleRate != 1000 || voltage.Metadata.Unit != signal.UnitVolt || current.Metadata.Unit != signal.UnitAmpere {
+		t.Errorf("signal = %v Hz %q/%q, want 1000 Hz V/A", voltage.SampleRate, voltage.Metadata.Unit, current.Metadata.Unit)
+	}
+	if _, open := <-receiver.GetVoltageChannel(); open {
+		t.Error("voltage channel still open after StartReceiving returned")
+	}
+
+	if token := <-server.tokens; token != "anonymous" {
+		t.Errorf("identity token policy = %q, want the anonymous policy", token)
+	}
+	items := <-server.items
+	wantItems := []uaMonitoredItem{
+		{node: uaNodeID{kind: 's', namespace: 2, text: "Cell1.Voltage"}, handle: opcuaVoltageHandle, queueSize: 21},
+		{node: uaNodeID{kind: 'i', namespace: 2, numeric: 5001}, handle: opcuaCurrentHandle, queueSize: 21},
+	}
+	if !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("monitored items = %+v, want %+v", items, wantItems)
+	}
+}
+
+func TestParseNodeID(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uaNodeID
+		wantErr bool
+	}{
+		{"i=2258", uaNodeID{kind: 'i', numeric: 2258}, false},
+		{"ns=2;s=Cell1.Voltage", uaNodeID{kind: 's', namespace: 2, text: "Cell1.Voltage"}, false},
+		{"ns=1;b=AQI=", uaNodeID{kind: 'b', namespace: 1, raw: []byte{1, 2}}, false},
+		{"ns=3;g=72962B91-FA75-4AE6-8D28-B404DC7DAF63", uaNodeID{kind: 'g', namespace: 3,
+			raw: []byte{0x91, 0x2B, 0x96, 0x72, 0x75, 0xFA, 0xE6, 0x4A, 0x8D, 0x28, 0xB4, 0x04, 0xDC, 0x7D, 0xAF, 0x63}}, false},
+		{"s=Voltage", uaNodeID{kind: 's', text: "Voltage"}, false},
+		{"ns=x;s=Voltage", uaNodeID{}, true},
+		{"ns=2", uaNodeID{}, true},
+		{"Voltage", uaNodeID{}, true},
+		{"x=1", uaNodeID{}, true},
+		{"i=-1", uaNodeID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseNodeID(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseNodeID(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNodeID(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/adam/masterapp/pkg/clock"
 	"github.com/adam/masterapp/pkg/config"
 	"github.com/adam/masterapp/pkg/signal"
 )
@@ -17,30 +18,49 @@ type DefaultReceiver struct {
 	samplesPerSecond int
 	validator        signal.Validator
 	generator        signal.Generator
-	running          bool
+	clock            clock.Clock
+	lifecycle        *lifecycle
+	stats            statsTracker
 }
 
 // NewReceiver creates a new data receiver
 func NewReceiver(sampleRate float64, samplesPerSecond int) DataReceiver {
+	return NewReceiverWithClock(sampleRate, samplesPerSecond, clock.NewSystemClock())
+}
+
+// NewReceiverWithClock creates a new data receiver whose signals are timestamped
+// by the given clock. A simulated clock is advanced by the duration of every
+// generated signal pair.
+func NewReceiverWithClock(sampleRate float64, samplesPerSecond int, c clock.Clock) DataReceiver {
+	c = clock.OrSystem(c)
+	return NewReceiverWithGenerator(sampleRate, samplesPerSecond, c, signal.NewGeneratorWithClock(c))
+}
+
+// NewReceiverWithGenerator creates a new data receiver emitting the signals of
+// generator, timestamped and paced like NewReceiverWithClock
+func NewReceiverWithGenerator(sampleRate float64, samplesPerSecond int, c clock.Clock, generator signal.Generator) DataReceiver {
+	c = clock.OrSystem(c)
 	return &DefaultReceiver{
 		voltageChannel:   make(chan signal.Signal, 10),
 		currentChannel:   make(chan signal.Signal, 10),
 		sampleRate:       sampleRate,
 		samplesPerSecond: samplesPerSecond,
 		validator:        signal.NewValidator(),
-		generator:        signal.NewGenerator(),
-		running:          false,
+		generator:        generator,
+		clock:            c,
+		lifecycle:        newLifecycle(),
 	}
 }
 
-// StartReceiving begins real-time data reception at 1-second intervals
+// StartReceiving begins real-time data reception at 1-second intervals. The
+// signal channels are closed when it returns.
 func (dr *DefaultReceiver) StartReceiving(ctx context.Context) error {
+	defer dr.lifecycle.closeChannels(dr.voltageChannel, dr.currentChannel)
+
 	// Validate configuration
-	cfg := &config.Config{
-		SampleRate:       dr.sampleRate,
-		SamplesPerSecond: dr.samplesPerSecond,
-		TargetURL:        "dummy", // Not used in receiver validation
-	}
+	cfg := config.NewConfig()
+	cfg.SampleRate = dr.sampleRate
+	cfg.SamplesPerSecond = dr.samplesPerSecond
 	if err := cfg.Validate(); err != nil {
 		return config.NewProcessingError("configuration validation", err)
 	}
@@ -48,14 +68,16 @@ func (dr *DefaultReceiver) StartReceiving(ctx context.Context) error {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	dr.running = true
+	dr.stats.start(0, time.Second)
+	defer dr.stats.stop()
 	log.Println("Starting real-time data reception (1-second intervals)")
 
-	for dr.running {
+	for {
 		select {
 		case <-ctx.Done():
-			dr.running = false
 			return ctx.Err()
+		case <-dr.lifecycle.stopping():
+			return nil
 		case <-ticker.C:
 			voltageSignal, err := dr.generator.GenerateVoltageSignal(dr.sampleRate, dr.samplesPerSecond)
 			if err != nil {
@@ -68,6 +90,7 @@ func (dr *DefaultReceiver) StartReceiving(ctx context.Context) error {
 				log.Printf("Error generating current signal: %v", err)
 				continue
 			}
+			clock.AdvanceSamples(dr.clock, dr.samplesPerSecond, dr.sampleRate)
 
 			if err := dr.validator.ValidateSignal(voltageSignal); err != nil {
 				log.Printf("Invalid voltage signal: %v", err)
@@ -79,23 +102,12 @@ func (dr *DefaultReceiver) StartReceiving(ctx context.Context) error {
 				continue
 			}
 
-			select {
-			case dr.voltageChannel <- voltageSignal:
-			default:
-				log.Println("Warning: Voltage channel buffer full, dropping sample")
-			}
-
-			select {
-			case dr.currentChannel <- currentSignal:
-			default:
-				log.Println("Warning: Current channel buffer full, dropping sample")
-			}
+			emitPair(dr.voltageChannel, dr.currentChannel, voltageSignal, currentSignal, &dr.stats)
+			dr.stats.advance()
 
 			log.Printf("Received data at %v", time.Now().Format("15:04:05"))
 		}
 	}
-
-	return nil
 }
 
 // GetVoltageChannel returns the channel for voltage signals
@@ -108,10 +120,19 @@ func (dr *DefaultReceiver) GetCurrentChannel() <-chan signal.Signal {
 	return dr.currentChannel
 }
 
-// Stop gracefully stops the receiver and closes channels
+// Done returns nil: synthetic data never runs out, and receiving from a nil channel blocks forever
+func (dr *DefaultReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (dr *DefaultReceiver) Stats() Stats {
+	return dr.stats.snapshot()
+}
+
+// Stop asks the receiver to stop; the channels are closed once StartReceiving
+// returns. It is safe to call more than once.
 func (dr *DefaultReceiver) Stop() error {
-	dr.running = false
-	close(dr.voltageChannel)
-	close(dr.currentChannel)
+	dr.lifecycle.stop()
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package receiver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDefaultReceiver_StopClosesChannelsFromProducer(t *testing.T) {
+	dr := NewReceiver(200000, 200)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- dr.StartReceiving(context.Background())
+	}()
+
+	// Stopping repeatedly, also concurrently with the run loop, must not panic
+	for i := 0; i < 3; i++ {
+		if err := dr.Stop(); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("StartReceiving() error = %v, want nil after Stop", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartReceiving() did not return after Stop")
+	}
+
+	for range dr.GetVoltageChannel() {
+	}
+	for range dr.GetCurrentChannel() {
+	}
+}
@@ -0,0 +1,38 @@
+package receiver
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// reconnect runs session until ctx is cancelled or the lifecycle stops,
+// starting it again after failures with a backoff doubling from 1 s to 30 s.
+// The backoff is reset once a session has run for a minute.
+func reconnect(ctx context.Context, l *lifecycle, source string, session func() error) error {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := session()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.stopping():
+			return nil
+		default:
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("Connection to %s lost: %v; reconnecting in %v", source, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.stopping():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
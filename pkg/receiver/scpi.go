@@ -0,0 +1,243 @@
+package receiver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// scpiDefaultPort is the raw SCPI socket port of LXI instruments
+const scpiDefaultPort = "5025"
+
+// SCPIProfile describes how to acquire waveform buffers from one instrument
+// model. Commands may contain the placeholders {points}, {rate} and
+// {interval} (1/rate in seconds), replaced with the acquisition settings.
+type SCPIProfile struct {
+	Name           string
+	Description    string
+	Setup          []string // Sent once after connecting
+	Arm            []string // Sent to start every acquisition
+	WaitQuery      string   // Polled after arming until it answers WaitDone
+	WaitDone       string
+	VoltageFetch   []string // Commands selecting the voltage trace; the last one is the data query
+	CurrentFetch   []string // Commands selecting the current trace; the last one is the data query
+	RateQuery      string   // Query answering the sample rate, or the sample interval with RateIsInterval
+	RateIsInterval bool
+	// SplitCurrent measures voltage and current on two instruments, e.g. a
+	// pair of DMMs; CurrentSetup replaces Setup on the current instrument
+	SplitCurrent bool
+	CurrentSetup []string
+}
+
+// SCPIProfiles are the built-in instrument profiles
+var SCPIProfiles = map[string]SCPIProfile{
+	"keysight-dsox": {
+		Name:        "keysight-dsox",
+		Description: "Keysight InfiniiVision oscilloscopes: voltage on channel 1, current (probe or shunt) on channel 2",
+		Setup: []string{
+			":WAVeform:FORMat ASCii",
+			":WAVeform:POINts:MODE RAW",
+			":WAVeform:POINts {points}",
+		},
+		Arm:            []string{":DIGitize CHANnel1,CHANnel2"},
+		WaitQuery:      "*OPC?",
+		WaitDone:       "1",
+		VoltageFetch:   []string{":WAVeform:SOURce CHANnel1", ":WAVeform:DATA?"},
+		CurrentFetch:   []string{":WAVeform:SOURce CHANnel2", ":WAVeform:DATA?"},
+		RateQuery:      ":WAVeform:XINCrement?",
+		RateIsInterval: true,
+	},
+	"rigol-ds1000z": {
+		Name:        "rigol-ds1000z",
+		Description: "Rigol DS1000Z oscilloscopes: voltage on channel 1, current (probe or shunt) on channel 2, screen record",
+		Setup: []string{
+			":WAV:MODE NORM",
+			":WAV:FORM ASC",
+		},
+		Arm:            []string{":SING"},
+		WaitQuery:      ":TRIG:STAT?",
+		WaitDone:       "STOP",
+		VoltageFetch:   []string{":WAV:SOUR CHAN1", ":WAV:DATA?"},
+		CurrentFetch:   []string{":WAV:SOUR CHAN2", ":WAV:DATA?"},
+		RateQuery:      ":WAV:XINC?",
+		RateIsInterval: true,
+	},
+	"keysight-34465a": {
+		Name:        "keysight-34465a",
+		Description: "Keysight Truevolt DMMs digitizing at a fixed rate: voltage on the first, current on a second instrument",
+		Setup: []string{
+			"CONF:VOLT:DC AUTO",
+			"VOLT:DC:NPLC 0.02",
+			"TRIG:SOUR IMM",
+			"SAMP:SOUR TIM",
+			"SAMP:TIM {interval}",
+			"SAMP:COUN {points}",
+		},
+		CurrentSetup: []string{
+			"CONF:CURR:DC AUTO",
+			"CURR:DC:NPLC 0.02",
+			"TRIG:SOUR IMM",
+			"SAMP:SOUR TIM",
+			"SAMP:TIM {interval}",
+			"SAMP:COUN {points}",
+		},
+		Arm:          []string{"INIT"},
+		WaitQuery:    "*OPC?",
+		WaitDone:     "1",
+		VoltageFetch: []string{"FETC?"},
+		CurrentFetch: []string{"FETC?"},
+		SplitCurrent: true,
+	},
+}
+
+// LookupSCPIProfile returns the built-in profile called name
+func LookupSCPIProfile(name string) (SCPIProfile, error) {
+	profile, ok := SCPIProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(SCPIProfiles))
+		for known := range SCPIProfiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return SCPIProfile{}, config.NewValidationError("SCPIProfile", fmt.Sprintf("unknown instrument profile '%s' (known: %s)", name, strings.Join(names, ", ")))
+	}
+	return profile, nil
+}
+
+// scpiConn is a raw socket connection to an LXI instrument
+type scpiConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// dialSCPI connects to host[:port], port 5025 by default
+func dialSCPI(ctx context.Context, address string, timeout time.Duration) (*scpiConn, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, scpiDefaultPort)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &scpiConn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// command sends one command line
+func (sc *scpiConn) command(cmd string) error {
+	sc.conn.SetWriteDeadline(time.Now().Add(sc.timeout))
+	_, err := io.WriteString(sc.conn, cmd+"\n")
+	return err
+}
+
+// query sends cmd and returns the response line
+func (sc *scpiConn) query(cmd string) (string, error) {
+	if err := sc.command(cmd); err != nil {
+		return "", err
+	}
+	sc.conn.SetReadDeadline(time.Now().Add(sc.timeout))
+	if err := sc.skipTerminators(); err != nil {
+		return "", fmt.Errorf("%s: %w", cmd, err)
+	}
+	line, err := sc.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", cmd, err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// skipTerminators discards line terminators left over from a block response
+// whose terminator arrived late
+func (sc *scpiConn) skipTerminators() error {
+	for {
+		next, err := sc.reader.Peek(1)
+		if err != nil {
+			return err
+		}
+		if next[0] != '\n' && next[0] != '\r' {
+			return nil
+		}
+		sc.reader.ReadByte()
+	}
+}
+
+// queryData sends cmd and returns its response, which is either an IEEE
+// 488.2 block ("#<digits><length><data>" or "#0<data>\n") or a plain line
+func (sc *scpiConn) queryData(cmd string) ([]byte, error) {
+	if err := sc.command(cmd); err != nil {
+		return nil, err
+	}
+	sc.conn.SetReadDeadline(time.Now().Add(sc.timeout))
+	if err := sc.skipTerminators(); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	first, err := sc.reader.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	if first[0] != '#' {
+		line, err := sc.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cmd, err)
+		}
+		return line, nil
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(sc.reader, header); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	digits := int(header[1] - '0')
+	if digits < 0 || digits > 9 {
+		return nil, fmt.Errorf("%s: malformed block header %q", cmd, header)
+	}
+	if digits == 0 { // Indefinite length block, terminated by a newline
+		return sc.reader.ReadBytes('\n')
+	}
+	lengthField := make([]byte, digits)
+	if _, err := io.ReadFull(sc.reader, lengthField); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	length, err := strconv.Atoi(string(lengthField))
+	if err != nil {
+		return nil, fmt.Errorf("%s: malformed block length %q", cmd, lengthField)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(sc.reader, data); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+	// The terminator after the block is skipped before the next response
+	return data, nil
+}
+
+// Close closes the connection
+func (sc *scpiConn) Close() error {
+	return sc.conn.Close()
+}
+
+// parseSCPIValues parses a comma separated list of ASCII numbers
+func parseSCPIValues(data []byte) ([]float64, error) {
+	fields := strings.Split(strings.TrimSpace(string(data)), ",")
+	values := make([]float64, 0, len(fields))
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" && i == len(fields)-1 {
+			break // Trailing separator
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q at position %d", field, i)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
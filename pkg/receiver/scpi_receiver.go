@@ -0,0 +1,376 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SCPIOptions selects the instruments, profile and acquisition of an SCPIReceiver
+type SCPIOptions struct {
+	Address        string      // host[:port] of the instrument, port 5025 by default
+	CurrentAddress string      // Second instrument measuring the current with a SplitCurrent profile
+	Profile        SCPIProfile // Commands of the instrument model
+	Points         int         // Samples per acquisition
+	SampleRate     float64     // Requested sample rate; also the rate of profiles without RateQuery
+	VoltageScale   float64     // Volts per instrument unit of the voltage trace
+	CurrentScale   float64     // Amperes per instrument unit of the current trace, e.g. 1/R of a shunt
+	Timeout        time.Duration
+
+	// Optional function generator driving the excitation while receiving
+	ExcitationAddress   string
+	ExcitationFrequency float64 // Hz
+	ExcitationAmplitude float64 // V peak-to-peak
+	ExcitationOffset    float64 // V
+}
+
+// SCPIReceiver fetches voltage and current waveform buffers from bench
+// instruments speaking SCPI over a raw LXI socket. Every acquisition is armed,
+// awaited and read back as one signal pair, so no CSV export is needed. A
+// function generator, if configured, is switched on while receiving and off
+// again when the receiver stops.
+type SCPIReceiver struct {
+	options        SCPIOptions
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	lifecycle      *lifecycle
+	stats          statsTracker
+	mu             sync.Mutex
+	conns          []*scpiConn
+}
+
+// NewSCPIReceiver creates a receiver for the given instruments
+func NewSCPIReceiver(options SCPIOptions) (*SCPIReceiver, error) {
+	if options.Address == "" {
+		return nil, config.NewValidationError("SCPIAddress", "instrument address is required")
+	}
+	if options.Profile.SplitCurrent && options.CurrentAddress == "" {
+		return nil, config.NewValidationError("SCPICurrentAddress", fmt.Sprintf("profile '%s' measures current on a second instrument; its address is required", options.Profile.Name))
+	}
+	if len(options.Profile.VoltageFetch) == 0 || len(options.Profile.CurrentFetch) == 0 {
+		return nil, config.NewValidationError("SCPIProfile", fmt.Sprintf("profile '%s' has no data queries", options.Profile.Name))
+	}
+	if options.Points <= 0 {
+		return nil, config.NewValidationError("SCPIPoints", "points per acquisition must be positive")
+	}
+	if options.SampleRate <= 0 && options.Profile.RateQuery == "" {
+		return nil, config.NewValidationError("SCPISampleRate", fmt.Sprintf("profile '%s' needs a sample rate", options.Profile.Name))
+	}
+	if options.VoltageScale == 0 || options.CurrentScale == 0 {
+		return nil, config.NewValidationError("SCPIScale", "scale factors cannot be zero")
+	}
+	if options.ExcitationAddress != "" && (options.ExcitationFrequency <= 0 || options.ExcitationAmplitude <= 0) {
+		return nil, config.NewValidationError("SCPIExcitation", "excitation frequency and amplitude must be positive")
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 10 * time.Second
+	}
+
+	return &SCPIReceiver{
+		options:        options,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		lifecycle:      newLifecycle(),
+	}, nil
+}
+
+// expand replaces the acquisition placeholders of a profile command
+func (sr *SCPIReceiver) expand(cmd string) string {
+	replacer := strings.NewReplacer(
+		"{points}", strconv.Itoa(sr.options.Points),
+		"{rate}", strconv.FormatFloat(sr.options.SampleRate, 'g', -1, 64),
+		"{interval}", strconv.FormatFloat(1/sr.options.SampleRate, 'g', -1, 64),
+	)
+	return replacer.Replace(cmd)
+}
+
+// StartReceiving acquires signal pairs until ctx is cancelled or Stop is
+// called. The signal channels are closed when it returns.
+func (sr *SCPIReceiver) StartReceiving(ctx context.Context) error {
+	defer sr.lifecycle.closeChannels(sr.voltageChannel, sr.currentChannel)
+
+	sr.stats.start(0, 0)
+	defer sr.stats.stop()
+	log.Printf("Starting SCPI reception from %s (profile %s, %d points per acquisition)", sr.options.Address, sr.options.Profile.Name, sr.options.Points)
+
+	if sr.options.ExcitationAddress != "" {
+		if err := sr.excitation(ctx, true); err != nil {
+			return config.NewNetworkError(sr.options.ExcitationAddress, 0, fmt.Errorf("failed to start excitation: %w", err))
+		}
+		defer func() {
+			offCtx, cancel := context.WithTimeout(context.Background(), sr.options.Timeout)
+			defer cancel()
+			if err := sr.excitation(offCtx, false); err != nil {
+				log.Printf("Warning: failed to switch off excitation at %s: %v", sr.options.ExcitationAddress, err)
+			}
+		}()
+	}
+
+	return reconnect(ctx, sr.lifecycle, sr.options.Address, func() error {
+		return sr.session(ctx)
+	})
+}
+
+// excitation switches the sine output of the function generator on or off
+func (sr *SCPIReceiver) excitation(ctx context.Context, on bool) error {
+	generator, err := dialSCPI(ctx, sr.options.ExcitationAddress, sr.options.Timeout)
+	if err != nil {
+		return err
+	}
+	defer generator.Close()
+
+	commands := []string{"OUTP OFF"}
+	if on {
+		commands = []string{
+			"FUNC SIN",
+			"FREQ " + strconv.FormatFloat(sr.options.ExcitationFrequency, 'g', -1, 64),
+			"VOLT " + strconv.FormatFloat(sr.options.ExcitationAmplitude, 'g', -1, 64),
+			"VOLT:OFFS " + strconv.FormatFloat(sr.options.ExcitationOffset, 'g', -1, 64),
+			"OUTP ON",
+		}
+	}
+	for _, cmd := range commands {
+		if err := generator.command(cmd); err != nil {
+			return err
+		}
+	}
+	// Wait until the generator has processed the commands
+	_, err = generator.query("*OPC?")
+	return err
+}
+
+// session connects to the instruments, sets them up and acquires until a
+// command fails or the receiver stops
+func (sr *SCPIReceiver) session(ctx context.Context) error {
+	profile := sr.options.Profile
+	addresses := []string{sr.options.Address}
+	if profile.SplitCurrent {
+		addresses = append(addresses, sr.options.CurrentAddress)
+	}
+
+	var conns []*scpiConn
+	for _, address := range addresses {
+		dialCtx, cancel := context.WithTimeout(ctx, sr.options.Timeout)
+		conn, err := dialSCPI(dialCtx, address, sr.options.Timeout)
+		cancel()
+		if err != nil {
+			closeSCPI(conns)
+			return err
+		}
+		conns = append(conns, conn)
+	}
+	if !sr.attach(conns) {
+		closeSCPI(conns)
+		return nil
+	}
+	defer sr.detach(conns)
+
+	for i, conn := range conns {
+		identity, err := conn.query("*IDN?")
+		if err != nil {
+			return err
+		}
+		log.Printf("Connected to %s: %s", addresses[i], identity)
+		setup := profile.Setup
+		if i == 1 && profile.CurrentSetup != nil {
+			setup = profile.CurrentSetup
+		}
+		if err := sr.send(conn, setup); err != nil {
+			return err
+		}
+	}
+
+	voltageConn, currentConn := conns[0], conns[len(conns)-1]
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sr.lifecycle.stopping():
+			return nil
+		default:
+		}
+
+		started := time.Now()
+		for _, conn := range conns {
+			if err := sr.send(conn, profile.Arm); err != nil {
+				return err
+			}
+		}
+		for _, conn := range conns {
+			if err := sr.wait(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		voltage, err := sr.fetch(voltageConn, profile.VoltageFetch, sr.options.VoltageScale)
+		if err != nil {
+			return fmt.Errorf("fetching voltage: %w", err)
+		}
+		current, err := sr.fetch(currentConn, profile.CurrentFetch, sr.options.CurrentScale)
+		if err != nil {
+			return fmt.Errorf("fetching current: %w", err)
+		}
+		rate, err := sr.sampleRate(voltageConn)
+		if err != nil {
+			return err
+		}
+		if len(voltage) != len(current) {
+			log.Printf("Warning: SCPI traces differ in length (%d voltage, %d current samples), truncating", len(voltage), len(current))
+			n := min(len(voltage), len(current))
+			voltage, current = voltage[:n], current[:n]
+		}
+		if len(voltage) == 0 {
+			return fmt.Errorf("instrument returned empty traces")
+		}
+
+		emitPair(sr.voltageChannel, sr.currentChannel,
+			signal.Signal{Timestamp: started, Values: voltage, SampleRate: rate, Metadata: signal.Metadata{Unit: signal.UnitVolt}},
+			signal.Signal{Timestamp: started, Values: current, SampleRate: rate, Metadata: signal.Metadata{Unit: signal.UnitAmpere}},
+			&sr.stats)
+		sr.stats.advance()
+	}
+}
+
+// send sends expanded profile commands
+func (sr *SCPIReceiver) send(conn *scpiConn, commands []string) error {
+	for _, cmd := range commands {
+		if err := conn.command(sr.expand(cmd)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait polls the profile's completion query until the acquisition is done
+func (sr *SCPIReceiver) wait(ctx context.Context, conn *scpiConn) error {
+	profile := sr.options.Profile
+	if profile.WaitQuery == "" {
+		return nil
+	}
+	deadline := time.Now().Add(sr.options.Timeout)
+	for {
+		response, err := conn.query(profile.WaitQuery)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(response, profile.WaitDone) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquisition not complete after %v (%s answered %q)", sr.options.Timeout, profile.WaitQuery, response)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sr.lifecycle.stopping():
+			return nil
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// fetch runs the commands of a trace and scales the values of its data query
+func (sr *SCPIReceiver) fetch(conn *scpiConn, commands []string, scale float64) ([]float64, error) {
+	if err := sr.send(conn, commands[:len(commands)-1]); err != nil {
+		return nil, err
+	}
+	data, err := conn.queryData(sr.expand(commands[len(commands)-1]))
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseSCPIValues(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range values {
+		values[i] *= scale
+	}
+	return values, nil
+}
+
+// sampleRate returns the rate reported by the instrument, or the configured
+// one for profiles without rate query
+func (sr *SCPIReceiver) sampleRate(conn *scpiConn) (float64, error) {
+	profile := sr.options.Profile
+	if profile.RateQuery == "" {
+		return sr.options.SampleRate, nil
+	}
+	response, err := conn.query(profile.RateQuery)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(response, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid sample rate response %q to %s", response, profile.RateQuery)
+	}
+	if profile.RateIsInterval {
+		return 1 / value, nil
+	}
+	return value, nil
+}
+
+// attach makes conns the active connections unless the receiver has stopped
+func (sr *SCPIReceiver) attach(conns []*scpiConn) bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	select {
+	case <-sr.lifecycle.stopping():
+		return false
+	default:
+	}
+	sr.conns = conns
+	return true
+}
+
+// detach closes conns
+func (sr *SCPIReceiver) detach(conns []*scpiConn) {
+	sr.mu.Lock()
+	sr.conns = nil
+	sr.mu.Unlock()
+	closeSCPI(conns)
+}
+
+// closeSCPI closes all connections
+func closeSCPI(conns []*scpiConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (sr *SCPIReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return sr.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (sr *SCPIReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return sr.currentChannel
+}
+
+// Done returns nil: instruments acquire until stopped
+func (sr *SCPIReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (sr *SCPIReceiver) Stats() Stats {
+	return sr.stats.snapshot()
+}
+
+// Stop asks the receiver to stop and aborts a pending acquisition; the
+// channels are closed once StartReceiving returns. It is safe to call more
+// than once.
+func (sr *SCPIReceiver) Stop() error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.lifecycle.stop()
+	closeSCPI(sr.conns)
+	return nil
+}
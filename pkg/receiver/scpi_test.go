@@ -0,0 +1,211 @@
+package receiver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// scpiTestInstrument answers every command line with the raw bytes returned
+// by respond (nothing for an empty string) and records the commands
+type scpiTestInstrument struct {
+	listener net.Listener
+	mu       sync.Mutex
+	commands []string
+}
+
+func newSCPITestInstrument(t *testing.T, respond func(cmd string) string) *scpiTestInstrument {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	instrument := &scpiTestInstrument{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					cmd := scanner.Text()
+					instrument.mu.Lock()
+					instrument.commands = append(instrument.commands, cmd)
+					instrument.mu.Unlock()
+					if response := respond(cmd); response != "" {
+						conn.Write([]byte(response))
+					}
+				}
+			}()
+		}
+	}()
+	return instrument
+}
+
+func (si *scpiTestInstrument) recorded() []string {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return append([]string(nil), si.commands...)
+}
+
+// scpiBlock wraps data in a definite length block
+func scpiBlock(data string) string {
+	length := fmt.Sprint(len(data))
+	return fmt.Sprintf("#%d%s%s\n", len(length), length, data)
+}
+
+func TestSCPIReceiver_OscilloscopeWithExcitation(t *testing.T) {
+	var mu sync.Mutex
+	source := ""
+	scope := newSCPITestInstrument(t, func(cmd string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case cmd == "*IDN?":
+			return "KEYSIGHT TECHNOLOGIES,DSOX1204G,CN00000000,02.12\n"
+		case cmd == "*OPC?":
+			return "1\n"
+		case strings.HasPrefix(cmd, ":WAVeform:SOURce "):
+			source = strings.TrimPrefix(cmd, ":WAVeform:SOURce ")
+		case cmd == ":WAVeform:DATA?" && source == "CHANnel1":
+			return scpiBlock("1.0E-01,2.0E-01,3.0E-01,4.0E-01")
+		case cmd == ":WAVeform:DATA?" && source == "CHANnel2":
+			return scpiBlock(" 1.0E-02, -1.0E-02, 2.0E-02, -2.0E-02,")
+		case cmd == ":WAVeform:XINCrement?":
+			return "+2.00000000E-03\n"
+		}
+		return ""
+	})
+	defer scope.listener.Close()
+	generator := newSCPITestInstrument(t, func(cmd string) string {
+		if cmd == "*OPC?" {
+			return "1\n"
+		}
+		return ""
+	})
+	defer generator.listener.Close()
+
+	receiver, err := NewSCPIReceiver(SCPIOptions{
+		Address:             scope.listener.Addr().String(),
+		Profile:             SCPIProfiles["keysight-dsox"],
+		Points:              4,
+		VoltageScale:        1,
+		CurrentScale:        0.1, // 10 ohm shunt
+		ExcitationAddress:   generator.listener.Addr().String(),
+		ExcitationFrequency: 50,
+		ExcitationAmplitude: 0.02,
+	})
+	if err != nil {
+		t.Fatalf("NewSCPIReceiver() error = %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+
+	var voltage, current signal.Signal
+	select {
+	case voltage = <-receiver.GetVoltageChannel():
+		current = <-receiver.GetCurrentChannel()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal pair received")
+	}
+	receiver.Stop()
+	if err := <-result; err != nil {
+		t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+	}
+
+	if !reflect.DeepEqual(voltage.Values, []float64{0.1, 0.2, 0.3, 0.4}) {
+		t.Errorf("voltage = %v", voltage.Values)
+	}
+	if !reflect.DeepEqual(current.Values, []float64{1e-3, -1e-3, 2e-3, -2e-3}) {
+		t.Errorf("current = %v, want the scaled channel 2 trace", current.Values)
+	}
+	if voltage.SampleRate != 500 || current.SampleRate != 500 {
+		t.Errorf("sample rates = %v/%v, want 500 from the sample interval", voltage.SampleRate, current.SampleRate)
+	}
+
+	commands := scope.recorded()
+	wantSetup := []string{"*IDN?", ":WAVeform:FORMat ASCii", ":WAVeform:POINts:MODE RAW", ":WAVeform:POINts 4", ":DIGitize CHANnel1,CHANnel2", "*OPC?"}
+	if len(commands) < len(wantSetup) || !reflect.DeepEqual(commands[:len(wantSetup)], wantSetup) {
+		t.Errorf("scope commands = %q, want to start with %q", commands, wantSetup)
+	}
+	wantExcitation := []string{"FUNC SIN", "FREQ 50", "VOLT 0.02", "VOLT:OFFS 0", "OUTP ON", "*OPC?", "OUTP OFF", "*OPC?"}
+	if got := generator.recorded(); !reflect.DeepEqual(got, wantExcitation) {
+		t.Errorf("generator commands = %q, want %q", got, wantExcitation)
+	}
+}
+
+func TestSCPIConn_QueryData(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"definite block", scpiBlock("1,2,3"), "1,2,3"},
+		{"block without terminator", "#15abcde", "abcde"},
+		{"indefinite block", "#01,2\n", "1,2\n"},
+		{"plain line", "4,5\r\n", "4,5\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instrument := newSCPITestInstrument(t, func(cmd string) string {
+				if cmd == "*IDN?" {
+					return "TEST\n"
+				}
+				return tt.response
+			})
+			defer instrument.listener.Close()
+			conn, err := dialSCPI(context.Background(), instrument.listener.Addr().String(), time.Second)
+			if err != nil {
+				t.Fatalf("dialSCPI() error = %v", err)
+			}
+			defer conn.Close()
+
+			got, err := conn.queryData("DATA?")
+			if err != nil || string(got) != tt.want {
+				t.Errorf("queryData() = %q, %v, want %q", got, err, tt.want)
+			}
+			// The connection stays usable for the next query
+			if identity, err := conn.query("*IDN?"); err != nil || identity != "TEST" {
+				t.Errorf("query() after block = %q, %v, want TEST", identity, err)
+			}
+		})
+	}
+}
+
+func TestNewSCPIReceiver_Validation(t *testing.T) {
+	valid := SCPIOptions{Address: "scope", Profile: SCPIProfiles["keysight-dsox"], Points: 1000, VoltageScale: 1, CurrentScale: 1}
+	tests := []struct {
+		name    string
+		modify  func(o *SCPIOptions)
+		wantErr bool
+	}{
+		{"valid", func(o *SCPIOptions) {}, false},
+		{"no address", func(o *SCPIOptions) { o.Address = "" }, true},
+		{"no points", func(o *SCPIOptions) { o.Points = 0 }, true},
+		{"split without current address", func(o *SCPIOptions) { o.Profile = SCPIProfiles["keysight-34465a"]; o.SampleRate = 1000 }, true},
+		{"split without rate", func(o *SCPIOptions) { o.Profile = SCPIProfiles["keysight-34465a"]; o.CurrentAddress = "dmm2" }, true},
+		{"split", func(o *SCPIOptions) {
+			o.Profile = SCPIProfiles["keysight-34465a"]
+			o.CurrentAddress = "dmm2"
+			o.SampleRate = 1000
+		}, false},
+		{"excitation without amplitude", func(o *SCPIOptions) { o.ExcitationAddress = "awg"; o.ExcitationFrequency = 10 }, true},
+	}
+	for _, tt := range tests {
+		options := valid
+		tt.modify(&options)
+		if _, err := NewSCPIReceiver(options); (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewSCPIReceiver() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
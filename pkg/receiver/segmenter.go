@@ -0,0 +1,381 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/fft"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Segmenter regroups a stream of contiguous signal chunks into analysis
+// windows of a fixed length, advancing by a hop shorter than the window when
+// overlap is configured. Period-based segmenters size each window to an integer
+// number of periods of the lowest excitation frequency to avoid leakage.
+type Segmenter struct {
+	windowLength time.Duration
+	periods      int
+	fundamental  float64
+	overlap      float64
+	sampleRate   float64
+	window       int
+	hop          int
+	buffer       []float64
+	start        time.Time // Timestamp of buffer[0]
+	metadata     signal.Metadata
+	repairs      []bufferedRepair
+}
+
+// bufferedRepair locates a buffered chunk containing repaired samples
+type bufferedRepair struct {
+	begin, end int // Buffer range of the chunk
+	samples    int // Repaired samples in the chunk
+}
+
+// NewSegmenter creates a segmenter producing windows of windowLength that
+// overlap by the given fraction (0 <= overlap < 1)
+func NewSegmenter(windowLength time.Duration, overlap float64) (*Segmenter, error) {
+	if windowLength <= 0 {
+		return nil, config.NewValidationError("WindowLength", "window length must be greater than 0")
+	}
+	if overlap < 0 || overlap >= 1 {
+		return nil, config.NewValidationError("WindowOverlap", "window overlap must be in [0, 1)")
+	}
+	return &Segmenter{windowLength: windowLength, overlap: overlap}, nil
+}
+
+// NewPeriodSegmenter creates a segmenter whose windows span the given number
+// of periods of fundamental (Hz), overlapping by the given fraction
+func NewPeriodSegmenter(fundamental float64, periods int, overlap float64) (*Segmenter, error) {
+	if fundamental <= 0 {
+		return nil, config.NewValidationError("ExcitationFrequency", "excitation frequency must be greater than 0")
+	}
+	if periods <= 0 {
+		return nil, config.NewValidationError("WindowPeriods", "window periods must be greater than 0")
+	}
+	segmenter, err := NewSegmenter(time.Duration(float64(periods)/fundamental*float64(time.Second)), overlap)
+	if err != nil {
+		return nil, err
+	}
+	segmenter.periods = periods
+	segmenter.fundamental = fundamental
+	return segmenter, nil
+}
+
+// Push appends a chunk and returns every window completed by it. A change of
+// sample rate, or a chunk ending at an acquisition dropout, discards any
+// partially filled window, so no window spans discontinuous data.
+func (s *Segmenter) Push(chunk signal.Signal) []signal.Signal {
+	if len(chunk.Values) == 0 || chunk.SampleRate <= 0 {
+		return nil
+	}
+	if chunk.SampleRate != s.sampleRate {
+		s.configure(chunk.SampleRate)
+	}
+	if len(s.buffer) == 0 {
+		s.start = chunk.Timestamp
+	}
+	if chunk.RepairedSamples > 0 {
+		s.repairs = append(s.repairs, bufferedRepair{begin: len(s.buffer), end: len(s.buffer) + len(chunk.Values), samples: chunk.RepairedSamples})
+	}
+	s.buffer = append(s.buffer, chunk.Values...)
+	s.metadata = chunk.Metadata
+
+	var windows []signal.Signal
+	for len(s.buffer) >= s.window {
+		values := make([]float64, s.window)
+		copy(values, s.buffer[:s.window])
+		windows = append(windows, signal.Signal{
+			Timestamp:       s.start,
+			Values:          values,
+			SampleRate:      s.sampleRate,
+			Metadata:        s.metadata,
+			RepairedSamples: s.repairedSamples(),
+		})
+
+		s.buffer = append(s.buffer[:0], s.buffer[s.hop:]...)
+		s.dropRepairs(s.hop)
+		s.start = s.start.Add(time.Duration(float64(s.hop) / s.sampleRate * float64(time.Second)))
+	}
+	if chunk.Gap > 0 {
+		s.Reset()
+	}
+	return windows
+}
+
+// Reset discards any buffered samples
+func (s *Segmenter) Reset() {
+	s.buffer = s.buffer[:0]
+	s.repairs = nil
+}
+
+// repairedSamples counts the repaired samples of the chunks overlapping the
+// current window. Where exactly a chunk was repaired is unknown, so a window
+// covering part of it is charged with all its repairs.
+func (s *Segmenter) repairedSamples() int {
+	samples := 0
+	for _, repair := range s.repairs {
+		if repair.begin < s.window {
+			samples += repair.samples
+		}
+	}
+	return samples
+}
+
+// dropRepairs shifts the repaired chunks by the samples removed from the
+// front of the buffer and forgets those no longer buffered
+func (s *Segmenter) dropRepairs(removed int) {
+	kept := s.repairs[:0]
+	for _, repair := range s.repairs {
+		repair.begin -= removed
+		repair.end -= removed
+		if repair.end > 0 {
+			kept = append(kept, repair)
+		}
+	}
+	s.repairs = kept
+}
+
+// configure derives window and hop sizes in samples for a sample rate
+func (s *Segmenter) configure(sampleRate float64) {
+	length := s.windowLength.Seconds()
+	if s.periods > 0 {
+		length = float64(s.periods) / s.fundamental
+	}
+	s.sampleRate = sampleRate
+	s.window = int(math.Max(1, math.Round(length*sampleRate)))
+	s.hop = int(math.Max(1, math.Round(float64(s.window)*(1-s.overlap))))
+	s.Reset()
+}
+
+// excitationThreshold is the fraction of the strongest spectral component a
+// peak must reach to count as excitation during detection
+const excitationThreshold = 0.1
+
+// SegmentOptions configures how a SegmentingReceiver cuts analysis windows
+type SegmentOptions struct {
+	WindowLength        time.Duration // Fixed window length, used when Periods is 0
+	Overlap             float64       // Fraction of consecutive windows that overlaps, in [0, 1)
+	Periods             int           // Periods of the lowest excitation frequency per window
+	ExcitationFrequency float64       // Lowest excitation frequency in Hz; 0 detects it from the first voltage signal
+}
+
+// String describes the window layout for logging
+func (o SegmentOptions) String() string {
+	switch {
+	case o.Periods > 0 && o.ExcitationFrequency > 0:
+		return fmt.Sprintf("%d periods of %g Hz with %.0f%% overlap", o.Periods, o.ExcitationFrequency, o.Overlap*100)
+	case o.Periods > 0:
+		return fmt.Sprintf("%d periods of the detected excitation with %.0f%% overlap", o.Periods, o.Overlap*100)
+	default:
+		return fmt.Sprintf("%v windows with %.0f%% overlap", o.WindowLength, o.Overlap*100)
+	}
+}
+
+// SegmentingReceiver wraps a DataReceiver and re-emits its voltage and current
+// signals as overlapping analysis windows
+type SegmentingReceiver struct {
+	source         DataReceiver
+	options        SegmentOptions
+	voltage        *Segmenter
+	current        *Segmenter
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	stats          statsTracker
+	completion     *completion
+	lifecycle      *lifecycle
+}
+
+// NewSegmentingReceiver creates a receiver emitting analysis windows cut from
+// the signals of source according to options
+func NewSegmentingReceiver(source DataReceiver, options SegmentOptions) (DataReceiver, error) {
+	sr := &SegmentingReceiver{
+		source:         source,
+		options:        options,
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		completion:     newCompletion(),
+		lifecycle:      newLifecycle(),
+	}
+
+	if options.Periods > 0 && options.ExcitationFrequency == 0 {
+		if options.Overlap < 0 || options.Overlap >= 1 {
+			return nil, config.NewValidationError("WindowOverlap", "window overlap must be in [0, 1)")
+		}
+		return sr, nil // Segmenters are created once the excitation is detected
+	}
+
+	if err := sr.createSegmenters(options.ExcitationFrequency); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+// createSegmenters builds the voltage and current segmenters, using fundamental
+// for period-based windows
+func (sr *SegmentingReceiver) createSegmenters(fundamental float64) error {
+	newSegmenter := func() (*Segmenter, error) {
+		if sr.options.Periods > 0 {
+			return NewPeriodSegmenter(fundamental, sr.options.Periods, sr.options.Overlap)
+		}
+		return NewSegmenter(sr.options.WindowLength, sr.options.Overlap)
+	}
+
+	voltage, err := newSegmenter()
+	if err != nil {
+		return err
+	}
+	current, err := newSegmenter()
+	if err != nil {
+		return err
+	}
+
+	sr.voltage, sr.current = voltage, current
+	return nil
+}
+
+// StartReceiving runs the source receiver and segments its signal pairs until
+// the context is cancelled or the source stops
+func (sr *SegmentingReceiver) StartReceiving(ctx context.Context) error {
+	defer sr.lifecycle.closeChannels(sr.voltageChannel, sr.currentChannel)
+
+	sr.stats.start(0, 0)
+	defer sr.stats.stop()
+	log.Printf("Segmenting signals into %s", sr.options)
+
+	sourceDone := make(chan error, 1)
+	go func() {
+		sourceDone <- sr.source.StartReceiving(ctx)
+	}()
+
+	for {
+		var voltageSignal, currentSignal signal.Signal
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sr.lifecycle.stopping():
+			return nil
+		case err := <-sourceDone:
+			sr.drain()
+			select {
+			case <-sr.source.Done():
+				sr.completion.complete()
+			default:
+			}
+			return err
+		case <-sr.source.Done():
+			sr.drain()
+			sr.completion.complete()
+			return nil
+		case voltageSignal, ok = <-sr.source.GetVoltageChannel():
+			if !ok {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case currentSignal, ok = <-sr.source.GetCurrentChannel():
+			if !ok {
+				return nil
+			}
+		}
+
+		sr.process(voltageSignal, currentSignal)
+	}
+}
+
+// drain segments signal pairs still buffered after the source has finished
+func (sr *SegmentingReceiver) drain() {
+	for {
+		select {
+		case voltageSignal, ok := <-sr.source.GetVoltageChannel():
+			if !ok {
+				return
+			}
+			select {
+			case currentSignal, ok := <-sr.source.GetCurrentChannel():
+				if !ok {
+					return
+				}
+				sr.process(voltageSignal, currentSignal)
+			default:
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// process pushes a signal pair through the segmenters and emits completed windows
+func (sr *SegmentingReceiver) process(voltageSignal, currentSignal signal.Signal) {
+	if sr.voltage == nil {
+		fundamental, err := fft.LowestExcitationFrequency(voltageSignal, excitationThreshold)
+		if err == nil {
+			err = sr.createSegmenters(fundamental)
+		}
+		if err != nil {
+			log.Printf("Warning: excitation detection failed, skipping signal pair: %v", err)
+			return
+		}
+		log.Printf("Detected lowest excitation frequency %g Hz; windows span %v", fundamental, sr.voltage.windowLength)
+	}
+
+	voltageWindows := sr.voltage.Push(voltageSignal)
+	currentWindows := sr.current.Push(currentSignal)
+	if len(voltageWindows) != len(currentWindows) {
+		log.Printf("Warning: voltage and current windows out of step (%d vs %d), resetting segmenters",
+			len(voltageWindows), len(currentWindows))
+		sr.voltage.Reset()
+		sr.current.Reset()
+		return
+	}
+
+	for i := range voltageWindows {
+		emitPair(sr.voltageChannel, sr.currentChannel, voltageWindows[i], currentWindows[i], &sr.stats)
+	}
+}
+
+// GetVoltageChannel returns the channel for windowed voltage signals
+func (sr *SegmentingReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return sr.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for windowed current signals
+func (sr *SegmentingReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return sr.currentChannel
+}
+
+// Done returns a channel that is closed once the source is exhausted and all
+// complete windows have been emitted
+func (sr *SegmentingReceiver) Done() <-chan struct{} {
+	return sr.completion.done()
+}
+
+// Stats returns the source's progress together with the number of windows emitted
+func (sr *SegmentingReceiver) Stats() Stats {
+	stats := sr.source.Stats()
+	windows := sr.stats.snapshot()
+	stats.SignalsEmitted = windows.SignalsEmitted
+	stats.SignalsDropped += windows.SignalsDropped
+	stats.Rate = windows.Rate
+	return stats
+}
+
+// Stop stops the source receiver; the window channels are closed once
+// StartReceiving returns. It is safe to call more than once.
+func (sr *SegmentingReceiver) Stop() error {
+	sr.lifecycle.stop()
+	if err := sr.source.Stop(); err != nil {
+		return fmt.Errorf("failed to stop source receiver: %w", err)
+	}
+	return nil
+}
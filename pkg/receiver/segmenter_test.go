@@ -0,0 +1,204 @@
+package receiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+func TestSegmenter_Push(t *testing.T) {
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	chunk := func(second int) signal.Signal {
+		values := make([]float64, 10)
+		for i := range values {
+			values[i] = float64(second*10 + i)
+		}
+		chunk := signal.Signal{Timestamp: start.Add(time.Duration(second) * time.Second), Values: values, SampleRate: 10}
+		if second == 1 {
+			chunk.RepairedSamples = 2
+		}
+		return chunk
+	}
+
+	tests := []struct {
+		name        string
+		length      time.Duration
+		overlap     float64
+		wantWindows []int       // windows completed after each of three chunks
+		wantStarts  []time.Time // start of every window in order
+		wantRepairs []int       // repaired samples of every window in order
+	}{
+		{
+			name:        "disjoint 1s windows",
+			length:      time.Second,
+			wantWindows: []int{1, 1, 1},
+			wantStarts:  []time.Time{start, start.Add(time.Second), start.Add(2 * time.Second)},
+			wantRepairs: []int{0, 2, 0},
+		},
+		{
+			name:        "2s windows with 50% overlap",
+			length:      2 * time.Second,
+			overlap:     0.5,
+			wantWindows: []int{0, 1, 1},
+			wantStarts:  []time.Time{start, start.Add(time.Second)},
+			wantRepairs: []int{2, 2},
+		},
+		{
+			name:        "400ms windows with 50% overlap",
+			length:      400 * time.Millisecond,
+			overlap:     0.5,
+			wantWindows: []int{4, 5, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segmenter, err := NewSegmenter(tt.length, tt.overlap)
+			if err != nil {
+				t.Fatalf("NewSegmenter() error = %v", err)
+			}
+
+			var windows []signal.Signal
+			for second, want := range tt.wantWindows {
+				got := segmenter.Push(chunk(second))
+				if len(got) != want {
+					t.Fatalf("chunk %d: expected %d windows, got %d", second, want, len(got))
+				}
+				windows = append(windows, got...)
+			}
+
+			expectedLength := int(tt.length.Seconds() * 10)
+			for i, window := range windows {
+				if len(window.Values) != expectedLength {
+					t.Errorf("window %d: expected %d samples, got %d", i, expectedLength, len(window.Values))
+				}
+				if i < len(tt.wantStarts) && !window.Timestamp.Equal(tt.wantStarts[i]) {
+					t.Errorf("window %d: expected start %v, got %v", i, tt.wantStarts[i], window.Timestamp)
+				}
+				if i < len(tt.wantRepairs) && window.RepairedSamples != tt.wantRepairs[i] {
+					t.Errorf("window %d: expected %d repaired samples, got %d", i, tt.wantRepairs[i], window.RepairedSamples)
+				}
+			}
+			if len(windows) > 1 && tt.overlap == 0.5 {
+				hop := int(float64(expectedLength) * 0.5)
+				if windows[1].Values[0] != windows[0].Values[hop] {
+					t.Errorf("expected second window to start %d samples into the first", hop)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmenter_DiscardsWindowAcrossGap(t *testing.T) {
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	segmenter, err := NewSegmenter(time.Second, 0)
+	if err != nil {
+		t.Fatalf("NewSegmenter() error = %v", err)
+	}
+
+	// 0.6 s cut short by a dropout, then 1 s of data after it
+	beforeGap := signal.Signal{Timestamp: start, Values: make([]float64, 6), SampleRate: 10, Gap: 3 * time.Second}
+	if windows := segmenter.Push(beforeGap); len(windows) != 0 {
+		t.Fatalf("expected no window before the dropout, got %d", len(windows))
+	}
+	afterGap := signal.Signal{Timestamp: start.Add(3600 * time.Millisecond), Values: make([]float64, 10), SampleRate: 10}
+	windows := segmenter.Push(afterGap)
+	if len(windows) != 1 || !windows[0].Timestamp.Equal(afterGap.Timestamp) {
+		t.Fatalf("expected one window starting after the dropout, got %+v", windows)
+	}
+}
+
+func TestNewSegmenter_InvalidOptions(t *testing.T) {
+	if _, err := NewSegmenter(0, 0); err == nil {
+		t.Errorf("expected error for zero window length")
+	}
+	if _, err := NewSegmenter(time.Second, 1); err == nil {
+		t.Errorf("expected error for overlap of 1")
+	}
+}
+
+func TestNewPeriodSegmenter_WindowSpansWholePeriods(t *testing.T) {
+	segmenter, err := NewPeriodSegmenter(4, 3, 0)
+	if err != nil {
+		t.Fatalf("NewPeriodSegmenter() error = %v", err)
+	}
+
+	windows := segmenter.Push(signal.Signal{Timestamp: time.Now(), Values: make([]float64, 1000), SampleRate: 1000})
+	if len(windows) != 1 {
+		t.Fatalf("expected 1 window, got %d", len(windows))
+	}
+	if got := len(windows[0].Values); got != 750 {
+		t.Errorf("expected 3 periods of 4 Hz at 1 kHz (750 samples), got %d", got)
+	}
+
+	if _, err := NewPeriodSegmenter(0, 3, 0); err == nil {
+		t.Errorf("expected error for zero excitation frequency")
+	}
+}
+
+// finiteSource is a DataReceiver whose signals are buffered up front and
+// whose input is already exhausted
+type finiteSource struct {
+	voltage chan signal.Signal
+	current chan signal.Signal
+	done    chan struct{}
+}
+
+func newFiniteSource(chunks []signal.Signal) *finiteSource {
+	fs := &finiteSource{
+		voltage: make(chan signal.Signal, len(chunks)),
+		current: make(chan signal.Signal, len(chunks)),
+		done:    make(chan struct{}),
+	}
+	for _, chunk := range chunks {
+		fs.voltage <- chunk
+		fs.current <- chunk
+	}
+	close(fs.done)
+	return fs
+}
+
+func (fs *finiteSource) StartReceiving(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+func (fs *finiteSource) GetVoltageChannel() <-chan signal.Signal { return fs.voltage }
+func (fs *finiteSource) GetCurrentChannel() <-chan signal.Signal { return fs.current }
+func (fs *finiteSource) Done() <-chan struct{}                   { return fs.done }
+func (fs *finiteSource) Stats() Stats                            { return Stats{} }
+func (fs *finiteSource) Stop() error                             { return nil }
+
+func TestSegmentingReceiver_CompletesWithSource(t *testing.T) {
+	start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+	chunks := make([]signal.Signal, 3)
+	for i := range chunks {
+		chunks[i] = signal.Signal{Timestamp: start.Add(time.Duration(i) * time.Second), Values: make([]float64, 10), SampleRate: 10}
+	}
+
+	sr, err := NewSegmentingReceiver(newFiniteSource(chunks), SegmentOptions{WindowLength: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("NewSegmentingReceiver() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sr.StartReceiving(ctx); err != nil {
+		t.Fatalf("StartReceiving() error = %v, want nil once the source is exhausted", err)
+	}
+
+	select {
+	case <-sr.Done():
+	default:
+		t.Fatal("Done() not closed after the source was exhausted")
+	}
+
+	windows := 0
+	for range sr.GetVoltageChannel() {
+		windows++
+	}
+	if windows != 1 {
+		t.Errorf("emitted %d windows, want 1 (3 s of input in 2 s windows)", windows)
+	}
+}
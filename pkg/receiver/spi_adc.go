@@ -0,0 +1,141 @@
+package receiver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Supported SPI ADC chips
+const (
+	SPIChipMCP3004 = "mcp3004"
+	SPIChipMCP3008 = "mcp3008"
+	SPIChipMCP3202 = "mcp3202"
+	SPIChipMCP3204 = "mcp3204"
+	SPIChipMCP3208 = "mcp3208"
+	SPIChipADS1256 = "ads1256"
+)
+
+// spiSegment is one part of an SPI message: tx is clocked out while as many
+// bytes are read, then the bus pauses for delay with chip select held
+type spiSegment struct {
+	tx    []byte
+	delay time.Duration
+}
+
+// spiBus is an open SPI device
+type spiBus interface {
+	// Transfer runs the segments as one message with chip select asserted
+	// throughout and returns the bytes read during all of them
+	Transfer(segments ...spiSegment) ([]byte, error)
+	Close() error
+}
+
+// spiADC describes the conversion protocol of one ADC family
+type spiADC struct {
+	mode     uint8 // SPI clock mode
+	channels int
+	setup    []spiSegment // Sent once after opening the device
+	// convert reads one single-ended conversion of channel as a fraction of
+	// the reference voltage
+	convert func(bus spiBus, channel int) (float64, error)
+}
+
+// spiADCs are the supported chips by name
+var spiADCs = map[string]spiADC{
+	SPIChipMCP3004: {channels: 4, convert: convertMCP300x},
+	SPIChipMCP3008: {channels: 8, convert: convertMCP300x},
+	SPIChipMCP3202: {channels: 2, convert: convertMCP3202},
+	SPIChipMCP3204: {channels: 4, convert: convertMCP320x},
+	SPIChipMCP3208: {channels: 8, convert: convertMCP320x},
+	SPIChipADS1256: {mode: 1, channels: 8, setup: ads1256Setup, convert: convertADS1256},
+}
+
+// lookupSPIADC returns the chip called name
+func lookupSPIADC(name string) (spiADC, error) {
+	adc, ok := spiADCs[name]
+	if !ok {
+		names := make([]string, 0, len(spiADCs))
+		for known := range spiADCs {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return spiADC{}, config.NewValidationError("SPIChip", fmt.Sprintf("unknown ADC '%s' (known: %s)", name, strings.Join(names, ", ")))
+	}
+	return adc, nil
+}
+
+// convertMCP300x reads a 10-bit MCP3004/MCP3008 conversion: a start bit,
+// the single-ended flag and the channel, answered by the result in the
+// last 10 bits
+func convertMCP300x(bus spiBus, channel int) (float64, error) {
+	rx, err := bus.Transfer(spiSegment{tx: []byte{0x01, byte(0x08|channel) << 4, 0x00}})
+	if err != nil {
+		return 0, err
+	}
+	return float64(int(rx[1]&0x03)<<8|int(rx[2])) / 1024, nil
+}
+
+// convertMCP320x reads a 12-bit MCP3204/MCP3208 conversion
+func convertMCP320x(bus spiBus, channel int) (float64, error) {
+	rx, err := bus.Transfer(spiSegment{tx: []byte{0x06 | byte(channel>>2), byte(channel&0x03) << 6, 0x00}})
+	if err != nil {
+		return 0, err
+	}
+	return float64(int(rx[1]&0x0F)<<8|int(rx[2])) / 4096, nil
+}
+
+// convertMCP3202 reads a 12-bit MCP3202 conversion, MSB first
+func convertMCP3202(bus spiBus, channel int) (float64, error) {
+	rx, err := bus.Transfer(spiSegment{tx: []byte{0x01, 0xA0 | byte(channel)<<6, 0x00}})
+	if err != nil {
+		return 0, err
+	}
+	return float64(int(rx[1]&0x0F)<<8|int(rx[2])) / 4096, nil
+}
+
+// ADS1256 commands and timing at its 7.68 MHz master clock
+const (
+	ads1256WREG    = 0x50
+	ads1256RDATA   = 0x01
+	ads1256SYNC    = 0xFC
+	ads1256WAKEUP  = 0x00
+	ads1256SDATAC  = 0x0F
+	ads1256SELFCAL = 0xF0
+	ads1256Command = 4 * time.Microsecond   // t11: 24 master clocks between commands
+	ads1256Data    = 7 * time.Microsecond   // t6: 50 master clocks before reading data
+	ads1256Settle  = 250 * time.Microsecond // Conversion after WAKEUP at 30 kSPS
+)
+
+// ads1256Setup stops continuous reads, selects PGA 1 at 30 kSPS and runs a
+// self calibration
+var ads1256Setup = []spiSegment{
+	{tx: []byte{ads1256SDATAC}, delay: ads1256Command},
+	// STATUS, MUX, ADCON (clock output off, PGA 1), DRATE (30 kSPS)
+	{tx: []byte{ads1256WREG | 0x00, 0x03, 0x00, 0x08, 0x00, 0xF0}, delay: ads1256Command},
+	{tx: []byte{ads1256SELFCAL}, delay: 5 * time.Millisecond},
+}
+
+// convertADS1256 multiplexes channel against AINCOM, restarts the
+// conversion and reads the 24-bit result. DRDY is not wired to the SPI
+// port, so the read waits out the settling time at 30 kSPS instead of
+// polling it, which limits a conversion to about 0.3 ms.
+func convertADS1256(bus spiBus, channel int) (float64, error) {
+	rx, err := bus.Transfer(
+		spiSegment{tx: []byte{ads1256WREG | 0x01, 0x00, byte(channel)<<4 | 0x08}, delay: ads1256Command},
+		spiSegment{tx: []byte{ads1256SYNC}, delay: ads1256Command},
+		spiSegment{tx: []byte{ads1256WAKEUP}, delay: ads1256Settle},
+		spiSegment{tx: []byte{ads1256RDATA}, delay: ads1256Data},
+		spiSegment{tx: []byte{0x00, 0x00, 0x00}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	data := rx[len(rx)-3:]
+	raw := int32(uint32(data[0])<<24|uint32(data[1])<<16|uint32(data[2])<<8) >> 8
+	// Full scale is ±2 VREF at PGA 1
+	return 2 * float64(raw) / 0x7FFFFF, nil
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package receiver
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// spidev ioctl requests from linux/spi/spidev.h
+const (
+	spiIOCWrMode        = 0x40016b01
+	spiIOCWrBitsPerWord = 0x40016b03
+	spiIOCWrMaxSpeedHz  = 0x40046b04
+	spiIOCMessageBase   = 0x40006b00 // SPI_IOC_MESSAGE(n) adds n*32 as the size field
+)
+
+// spiIOCTransfer mirrors struct spi_ioc_transfer
+type spiIOCTransfer struct {
+	txBuf       uint64
+	rxBuf       uint64
+	length      uint32
+	speedHz     uint32
+	delayUsecs  uint16
+	bitsPerWord uint8
+	csChange    uint8
+	txNbits     uint8
+	rxNbits     uint8
+	wordDelay   uint8
+	pad         uint8
+}
+
+// spidevBus is a Linux spidev character device such as /dev/spidev0.0
+type spidevBus struct {
+	file    *os.File
+	speedHz uint32
+}
+
+// openSPI opens a spidev device with the given SPI mode and clock
+func openSPI(device string, mode uint8, speedHz uint32) (spiBus, error) {
+	file, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	bits := uint8(8)
+	for _, setting := range []struct {
+		request uintptr
+		value   unsafe.Pointer
+	}{
+		{spiIOCWrMode, unsafe.Pointer(&mode)},
+		{spiIOCWrBitsPerWord, unsafe.Pointer(&bits)},
+		{spiIOCWrMaxSpeedHz, unsafe.Pointer(&speedHz)},
+	} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), setting.request, uintptr(setting.value)); errno != 0 {
+			file.Close()
+			return nil, fmt.Errorf("configuring %s: %w", device, errno)
+		}
+	}
+	return &spidevBus{file: file, speedHz: speedHz}, nil
+}
+
+// Transfer runs the segments as one spidev message
+func (b *spidevBus) Transfer(segments ...spiSegment) ([]byte, error) {
+	total := 0
+	for _, segment := range segments {
+		total += len(segment.tx)
+	}
+	tx := make([]byte, 0, total)
+	rx := make([]byte, total)
+	transfers := make([]spiIOCTransfer, len(segments))
+	for i, segment := range segments {
+		if len(segment.tx) == 0 {
+			return nil, fmt.Errorf("empty SPI transfer segment")
+		}
+		offset := len(tx)
+		tx = append(tx, segment.tx...)
+		transfers[i] = spiIOCTransfer{
+			txBuf:       uint64(uintptr(unsafe.Pointer(&tx[offset]))),
+			rxBuf:       uint64(uintptr(unsafe.Pointer(&rx[offset]))),
+			length:      uint32(len(segment.tx)),
+			speedHz:     b.speedHz,
+			delayUsecs:  uint16(min(segment.delay.Microseconds(), 0xFFFF)),
+			bitsPerWord: 8,
+		}
+	}
+	request := uintptr(spiIOCMessageBase | len(transfers)*int(unsafe.Sizeof(spiIOCTransfer{}))<<16)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), request, uintptr(unsafe.Pointer(&transfers[0])))
+	runtime.KeepAlive(tx) // Only referenced through the transfer addresses
+	if errno != 0 {
+		return nil, errno
+	}
+	return rx, nil
+}
+
+// Close closes the device
+func (b *spidevBus) Close() error {
+	return b.file.Close()
+}
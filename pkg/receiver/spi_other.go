@@ -0,0 +1,13 @@
+//go:build !linux
+
+package receiver
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openSPI is unavailable outside Linux, which provides the spidev interface
+func openSPI(device string, mode uint8, speedHz uint32) (spiBus, error) {
+	return nil, fmt.Errorf("SPI access through spidev is not supported on %s", runtime.GOOS)
+}
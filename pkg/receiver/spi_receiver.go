@@ -0,0 +1,200 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// SPIOptions selects the ADC, channel mapping and sample timing of an SPIReceiver
+type SPIOptions struct {
+	Device         string  // spidev device, e.g. /dev/spidev0.0
+	SpeedHz        uint32  // SPI clock
+	Chip           string  // ADC chip, e.g. mcp3008 or ads1256
+	VRef           float64 // ADC reference voltage in V
+	VoltageChannel int     // Single-ended ADC input carrying the voltage
+	CurrentChannel int     // Single-ended ADC input carrying the current
+	VoltageScale   float64 // Volts per volt at the ADC input
+	VoltageOffset  float64 // Volts added after scaling
+	CurrentScale   float64 // Amperes per volt at the ADC input, e.g. 0.1 for a 10 ohm shunt
+	CurrentOffset  float64 // Amperes added after scaling
+	SampleRate     float64 // Voltage/current pairs per second
+	ChunkSamples   int     // Pairs per emitted signal
+}
+
+// SPIReceiver samples voltage and current from an ADC on the SPI bus of a
+// single-board computer such as a Raspberry Pi, so masterapp can run
+// standalone next to the cell. Each sample reads the voltage channel and then
+// the current channel on a fixed schedule; the two conversions are one
+// conversion time apart. Timestamps follow the schedule, and late samples
+// are logged rather than hidden.
+type SPIReceiver struct {
+	options        SPIOptions
+	adc            spiADC
+	open           func() (spiBus, error)
+	voltageChannel chan signal.Signal
+	currentChannel chan signal.Signal
+	lifecycle      *lifecycle
+	stats          statsTracker
+}
+
+// NewSPIReceiver creates a receiver reading the configured spidev device
+func NewSPIReceiver(options SPIOptions) (*SPIReceiver, error) {
+	adc, err := lookupSPIADC(options.Chip)
+	if err != nil {
+		return nil, err
+	}
+	if options.Device == "" {
+		return nil, config.NewValidationError("SPIDevice", "device cannot be empty")
+	}
+	if options.SpeedHz == 0 {
+		return nil, config.NewValidationError("SPISpeed", "clock must be positive")
+	}
+	for _, channel := range []int{options.VoltageChannel, options.CurrentChannel} {
+		if channel < 0 || channel >= adc.channels {
+			return nil, config.NewValidationError("SPIChannel", fmt.Sprintf("channel %d outside of the %d inputs of the %s", channel, adc.channels, options.Chip))
+		}
+	}
+	if options.VoltageChannel == options.CurrentChannel {
+		return nil, config.NewValidationError("SPIChannel", "voltage and current must use different channels")
+	}
+	if options.VRef <= 0 {
+		return nil, config.NewValidationError("SPIVRef", "reference voltage must be positive")
+	}
+	if options.VoltageScale == 0 || options.CurrentScale == 0 {
+		return nil, config.NewValidationError("SPIScale", "scale factors cannot be zero")
+	}
+	if options.SampleRate <= 0 {
+		return nil, config.NewValidationError("SPISampleRate", "sample rate must be positive")
+	}
+	if options.ChunkSamples <= 0 {
+		return nil, config.NewValidationError("ChunkSamples", "chunk size must be positive")
+	}
+
+	return &SPIReceiver{
+		options: options,
+		adc:     adc,
+		open: func() (spiBus, error) {
+			return openSPI(options.Device, adc.mode, options.SpeedHz)
+		},
+		voltageChannel: make(chan signal.Signal, 10),
+		currentChannel: make(chan signal.Signal, 10),
+		lifecycle:      newLifecycle(),
+	}, nil
+}
+
+// StartReceiving samples signal pairs until ctx is cancelled or Stop is
+// called. The signal channels are closed when it returns.
+func (sr *SPIReceiver) StartReceiving(ctx context.Context) error {
+	defer sr.lifecycle.closeChannels(sr.voltageChannel, sr.currentChannel)
+
+	sr.stats.start(0, time.Duration(float64(sr.options.ChunkSamples)/sr.options.SampleRate*float64(time.Second)))
+	defer sr.stats.stop()
+	log.Printf("Starting SPI sampling of %s on %s at %g Hz (voltage on channel %d, current on channel %d)",
+		sr.options.Chip, sr.options.Device, sr.options.SampleRate, sr.options.VoltageChannel, sr.options.CurrentChannel)
+
+	return reconnect(ctx, sr.lifecycle, sr.options.Device, func() error {
+		return sr.sample(ctx)
+	})
+}
+
+// sample opens the bus and reads pairs on schedule until a transfer fails
+// or the receiver stops
+func (sr *SPIReceiver) sample(ctx context.Context) error {
+	bus, err := sr.open()
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	if len(sr.adc.setup) > 0 {
+		if _, err := bus.Transfer(sr.adc.setup...); err != nil {
+			return fmt.Errorf("ADC setup: %w", err)
+		}
+	}
+
+	period := time.Duration(float64(time.Second) / sr.options.SampleRate)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	started := time.Now()
+	for n := 0; ; n += sr.options.ChunkSamples {
+		voltage := make([]float64, sr.options.ChunkSamples)
+		current := make([]float64, sr.options.ChunkSamples)
+		late := 0
+		for i := range voltage {
+			due := started.Add(time.Duration(n+i) * period)
+			if wait := time.Until(due); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-sr.lifecycle.stopping():
+					return nil
+				case <-timer.C:
+				}
+			} else if -wait > period {
+				late++
+			}
+
+			v, err := sr.adc.convert(bus, sr.options.VoltageChannel)
+			if err != nil {
+				return err
+			}
+			c, err := sr.adc.convert(bus, sr.options.CurrentChannel)
+			if err != nil {
+				return err
+			}
+			voltage[i] = v*sr.options.VRef*sr.options.VoltageScale + sr.options.VoltageOffset
+			current[i] = c*sr.options.VRef*sr.options.CurrentScale + sr.options.CurrentOffset
+		}
+		if late > 0 {
+			log.Printf("SPI sampling fell behind for %d of %d samples; lower the sample rate or raise the SPI clock", late, sr.options.ChunkSamples)
+		}
+
+		timestamp := started.Add(time.Duration(n) * period)
+		emitPair(sr.voltageChannel, sr.currentChannel,
+			signal.Signal{Timestamp: timestamp, Values: voltage, SampleRate: sr.options.SampleRate, Metadata: signal.Metadata{Unit: signal.UnitVolt}},
+			signal.Signal{Timestamp: timestamp, Values: current, SampleRate: sr.options.SampleRate, Metadata: signal.Metadata{Unit: signal.UnitAmpere}},
+			&sr.stats)
+		sr.stats.advance()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sr.lifecycle.stopping():
+			return nil
+		default:
+		}
+	}
+}
+
+// GetVoltageChannel returns the channel for voltage signals
+func (sr *SPIReceiver) GetVoltageChannel() <-chan signal.Signal {
+	return sr.voltageChannel
+}
+
+// GetCurrentChannel returns the channel for current signals
+func (sr *SPIReceiver) GetCurrentChannel() <-chan signal.Signal {
+	return sr.currentChannel
+}
+
+// Done returns nil: live sampling never runs out of data
+func (sr *SPIReceiver) Done() <-chan struct{} {
+	return nil
+}
+
+// Stats returns the current reception statistics
+func (sr *SPIReceiver) Stats() Stats {
+	return sr.stats.snapshot()
+}
+
+// Stop asks the receiver to stop after the sample in progress; the channels
+// are closed once StartReceiving returns. It is safe to call more than once.
+func (sr *SPIReceiver) Stop() error {
+	sr.lifecycle.stop()
+	return nil
+}
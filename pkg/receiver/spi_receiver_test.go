@@ -0,0 +1,159 @@
+package receiver
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// fakeMCP3008 answers MCP3008 conversions with the code of each channel
+type fakeMCP3008 struct {
+	mu     sync.Mutex
+	codes  [8]int
+	closed bool
+}
+
+func (f *fakeMCP3008) Transfer(segments ...spiSegment) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tx := segments[0].tx
+	code := f.codes[(tx[1]>>4)&0x07]
+	return []byte{0x00, byte(code >> 8), byte(code)}, nil
+}
+
+func (f *fakeMCP3008) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestSPIReceiver_ChannelMapping(t *testing.T) {
+	bus := &fakeMCP3008{}
+	bus.codes[2] = 512 // Voltage: half of VREF
+	bus.codes[5] = 256 // Current: a quarter of VREF across the shunt
+	receiver, err := NewSPIReceiver(SPIOptions{
+		Device:         "/dev/spidev0.0",
+		SpeedHz:        1000000,
+		Chip:           SPIChipMCP3008,
+		VRef:           3.2,
+		VoltageChannel: 2,
+		CurrentChannel: 5,
+		VoltageScale:   2, // 1:2 divider
+		VoltageOffset:  -1,
+		CurrentScale:   0.1, // 10 ohm shunt
+		SampleRate:     1000,
+		ChunkSamples:   5,
+	})
+	if err != nil {
+		t.Fatalf("NewSPIReceiver() error = %v", err)
+	}
+	receiver.open = func() (spiBus, error) { return bus, nil }
+	result := make(chan error, 1)
+	go func() { result <- receiver.StartReceiving(context.Background()) }()
+
+	var voltages, currents []signal.Signal
+	for len(voltages) < 2 {
+		select {
+		case v := <-receiver.GetVoltageChannel():
+			voltages = append(voltages, v)
+			currents = append(currents, <-receiver.GetCurrentChannel())
+		case <-time.After(5 * time.Second):
+			t.Fatal("no signal pair received")
+		}
+	}
+	receiver.Stop()
+	if err := <-result; err != nil {
+		t.Errorf("StartReceiving() error = %v, want nil after Stop", err)
+	}
+
+	for i := range voltages[0].Values {
+		if math.Abs(voltages[0].Values[i]-2.2) > 1e-12 || math.Abs(currents[0].Values[i]-0.08) > 1e-12 {
+			t.Fatalf("sample %d = %v V, %v A, want 2.2 V, 0.08 A", i, voltages[0].Values[i], currents[0].Values[i])
+		}
+	}
+	if gap := voltages[1].Timestamp.Sub(voltages[0].Timestamp); gap != 5*time.Millisecond {
+		t.Errorf("chunk timestamps %v apart, want 5 ms at 1 kHz", gap)
+	}
+	if voltages[0].SampleRate != 1000 || voltages[0].Metadata.Unit != signal.UnitVolt || currents[0].Metadata.Unit != signal.UnitAmpere {
+		t.Errorf("signal = %v Hz %q/%q, want 1000 Hz V/A", voltages[0].SampleRate, voltages[0].Metadata.Unit, currents[0].Metadata.Unit)
+	}
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if !bus.closed {
+		t.Error("SPI device not closed after Stop")
+	}
+}
+
+// recordingBus records the bytes sent and answers with canned bytes
+type recordingBus struct {
+	response []byte
+	sent     []byte
+}
+
+func (r *recordingBus) Transfer(segments ...spiSegment) ([]byte, error) {
+	for _, segment := range segments {
+		r.sent = append(r.sent, segment.tx...)
+	}
+	return r.response, nil
+}
+
+func (r *recordingBus) Close() error { return nil }
+
+func TestSPIADCConversions(t *testing.T) {
+	tests := []struct {
+		chip     string
+		channel  int
+		response []byte
+		wantSent []byte
+		want     float64
+	}{
+		{SPIChipMCP3008, 3, []byte{0x00, 0x03, 0xFF}, []byte{0x01, 0xB0, 0x00}, 1023.0 / 1024},
+		{SPIChipMCP3004, 0, []byte{0x00, 0x02, 0x00}, []byte{0x01, 0x80, 0x00}, 0.5},
+		{SPIChipMCP3208, 6, []byte{0x00, 0x04, 0x00}, []byte{0x07, 0x80, 0x00}, 0.25},
+		{SPIChipMCP3202, 1, []byte{0x00, 0x08, 0x00}, []byte{0x01, 0xE0, 0x00}, 0.5},
+		{SPIChipADS1256, 4, []byte{0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00}, []byte{0x51, 0x00, 0x48, 0xFC, 0x00, 0x01, 0x00, 0x00, 0x00}, 2 * float64(0x400000) / 0x7FFFFF},
+		{SPIChipADS1256, 0, []byte{0, 0, 0, 0, 0, 0, 0xC0, 0x00, 0x00}, []byte{0x51, 0x00, 0x08, 0xFC, 0x00, 0x01, 0x00, 0x00, 0x00}, -2 * float64(0x400000) / 0x7FFFFF},
+	}
+	for _, tt := range tests {
+		adc, err := lookupSPIADC(tt.chip)
+		if err != nil {
+			t.Fatalf("lookupSPIADC(%s) error = %v", tt.chip, err)
+		}
+		bus := &recordingBus{response: tt.response}
+		got, err := adc.convert(bus, tt.channel)
+		if err != nil || got != tt.want {
+			t.Errorf("%s channel %d = %v, %v, want %v", tt.chip, tt.channel, got, err, tt.want)
+		}
+		if string(bus.sent) != string(tt.wantSent) {
+			t.Errorf("%s channel %d sent % X, want % X", tt.chip, tt.channel, bus.sent, tt.wantSent)
+		}
+	}
+}
+
+func TestNewSPIReceiver_Validation(t *testing.T) {
+	valid := SPIOptions{Device: "/dev/spidev0.0", SpeedHz: 1000000, Chip: SPIChipMCP3008, VRef: 3.3, CurrentChannel: 1, VoltageScale: 1, CurrentScale: 1, SampleRate: 100, ChunkSamples: 100}
+	tests := []struct {
+		name    string
+		modify  func(o *SPIOptions)
+		wantErr bool
+	}{
+		{"valid", func(o *SPIOptions) {}, false},
+		{"unknown chip", func(o *SPIOptions) { o.Chip = "mcp9999" }, true},
+		{"channel beyond chip", func(o *SPIOptions) { o.Chip = SPIChipMCP3202; o.CurrentChannel = 2 }, true},
+		{"same channel", func(o *SPIOptions) { o.CurrentChannel = 0 }, true},
+		{"no reference", func(o *SPIOptions) { o.VRef = 0 }, true},
+		{"no sample rate", func(o *SPIOptions) { o.SampleRate = 0 }, true},
+	}
+	for _, tt := range tests {
+		options := valid
+		tt.modify(&options)
+		if _, err := NewSPIReceiver(options); (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewSPIReceiver() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package receiver
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+// Stats summarizes the progress and throughput of a data receiver
+type Stats struct {
+	Running        bool          `json:"running"`
+	StartedAt      time.Time     `json:"started_at"`
+	SignalsEmitted int64         `json:"signals_emitted"`
+	SignalsDropped int64         `json:"signals_dropped"`
+	Processed      int           `json:"processed"`
+	Total          int           `json:"total"` // 0 for unbounded sources
+	Progress       float64       `json:"progress_percent"`
+	Rate           float64       `json:"rate_per_second"`
+	Remaining      time.Duration `json:"remaining_ns"`
+}
+
+// dropReportInterval is the minimum time between two aggregate warnings about
+// samples dropped because a channel buffer was full
+const dropReportInterval = time.Minute
+
+// statsTracker accumulates receiver statistics safely across goroutines
+type statsTracker struct {
+	mu        sync.Mutex
+	running   bool
+	startedAt time.Time
+	emitted   int64
+	dropped   int64
+	processed int
+	total     int
+	interval  time.Duration
+
+	// Full buffers are warned about once, later drops are counted in
+	// unreported and logged as one aggregate line per dropReportInterval
+	reportedAt time.Time
+	unreported int64
+}
+
+// start marks the beginning of a reception run
+func (st *statsTracker) start(total int, interval time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.running = true
+	st.startedAt = time.Now()
+	st.total = total
+	st.interval = interval
+	st.reportedAt = time.Time{}
+	st.unreported = 0
+}
+
+// stop marks the end of a reception run, logging drops not reported yet
+func (st *statsTracker) stop() {
+	st.mu.Lock()
+	st.running = false
+	line := st.dropReport(time.Now())
+	st.mu.Unlock()
+	if line != "" {
+		log.Print(line)
+	}
+}
+
+// recordEmitted counts a signal pair delivered to the channels
+func (st *statsTracker) recordEmitted() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.emitted++
+}
+
+// recordDropped counts a signal dropped because a channel buffer was full
+func (st *statsTracker) recordDropped() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.dropped++
+}
+
+// recordBufferFull counts a sample dropped because the buffer of channel was
+// full. Only the first drop is logged right away; the drops that follow are
+// logged as an aggregate count at most once per dropReportInterval, as full
+// buffers would otherwise flood the log at high sample rates.
+func (st *statsTracker) recordBufferFull(channel string) {
+	now := time.Now()
+	st.mu.Lock()
+	st.dropped++
+	var line string
+	if st.reportedAt.IsZero() {
+		st.reportedAt = now
+		line = fmt.Sprintf("Warning: %s channel buffer full, dropping samples; further drops are summarized every %v", channel, dropReportInterval)
+	} else {
+		st.unreported++
+		if now.Sub(st.reportedAt) >= dropReportInterval {
+			line = st.dropReport(now)
+		}
+	}
+	st.mu.Unlock()
+	if line != "" {
+		log.Print(line)
+	}
+}
+
+// dropReport returns the aggregate warning about the unreported drops, if
+// any, and starts a new period; st.mu must be held
+func (st *statsTracker) dropReport(now time.Time) string {
+	if st.unreported == 0 {
+		return ""
+	}
+	line := fmt.Sprintf("Warning: dropped %d samples in last %v (channel buffer full)", st.unreported, now.Sub(st.reportedAt).Round(time.Second))
+	st.reportedAt = now
+	st.unreported = 0
+	return line
+}
+
+// advance counts one input item as consumed, whether emitted or skipped
+func (st *statsTracker) advance() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.processed++
+}
+
+// seek counts the first n input items as consumed without emitting them
+func (st *statsTracker) seek(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.processed = n
+}
+
+// snapshot returns a consistent copy of the current statistics
+func (st *statsTracker) snapshot() Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	stats := Stats{
+		Running:        st.running,
+		StartedAt:      st.startedAt,
+		SignalsEmitted: st.emitted,
+		SignalsDropped: st.dropped,
+		Processed:      st.processed,
+		Total:          st.total,
+	}
+
+	if st.total > 0 {
+		stats.Progress = float64(st.processed) / float64(st.total) * 100
+		if remaining := st.total - st.processed; remaining > 0 {
+			stats.Remaining = time.Duration(remaining) * st.interval
+		}
+	}
+
+	if !st.startedAt.IsZero() {
+		if elapsed := time.Since(st.startedAt).Seconds(); elapsed > 0 {
+			stats.Rate = float64(st.emitted) / elapsed
+		}
+	}
+
+	return stats
+}
+
+// emitPair sends a signal pair without blocking, counting drops when a buffer is full
+func emitPair(voltageChannel, currentChannel chan signal.Signal, voltageSignal, currentSignal signal.Signal, stats *statsTracker) {
+	delivered := true
+
+	select {
+	case voltageChannel <- voltageSignal:
+	default:
+		stats.recordBufferFull("Voltage")
+		delivered = false
+	}
+
+	select {
+	case currentChannel <- currentSignal:
+	default:
+		stats.recordBufferFull("Current")
+		delivered = false
+	}
+
+	if delivered {
+		stats.recordEmitted()
+	}
+}
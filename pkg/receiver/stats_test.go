@@ -0,0 +1,48 @@
+package receiver
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsTracker_BufferFullLogSampling(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	var st statsTracker
+	st.start(0, time.Second)
+
+	// The first drop is logged, the following ones only counted
+	for i := 0; i < 5; i++ {
+		st.recordBufferFull("Voltage")
+	}
+	if lines := strings.Count(logs.String(), "\n"); lines != 1 || !strings.Contains(logs.String(), "Voltage channel buffer full") {
+		t.Fatalf("log = %q, want one buffer full warning", logs.String())
+	}
+
+	// Once the report interval has passed the next drop logs the aggregate
+	logs.Reset()
+	st.mu.Lock()
+	st.reportedAt = st.reportedAt.Add(-dropReportInterval)
+	st.mu.Unlock()
+	st.recordBufferFull("Current")
+	if !strings.Contains(logs.String(), "dropped 5 samples in last 1m0s") {
+		t.Errorf("log = %q, want the aggregate count of 5 drops", logs.String())
+	}
+
+	// Stopping reports what is left
+	logs.Reset()
+	st.recordBufferFull("Current")
+	st.recordBufferFull("Voltage")
+	st.stop()
+	if !strings.Contains(logs.String(), "dropped 2 samples") {
+		t.Errorf("log = %q, want the remaining 2 drops at stop", logs.String())
+	}
+	if stats := st.snapshot(); stats.SignalsDropped != 8 {
+		t.Errorf("SignalsDropped = %d, want 8", stats.SignalsDropped)
+	}
+}
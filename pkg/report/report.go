@@ -0,0 +1,321 @@
+// Package report renders the output directory of a run into a standalone
+// HTML measurement report
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+	"github.com/adam/masterapp/pkg/output"
+	"github.com/adam/masterapp/pkg/plot"
+	"github.com/adam/masterapp/pkg/signal"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+// Spectrum is one spectrum of a run as written by console (JSON) output
+type Spectrum struct {
+	signal.Identity
+	Path         string                   `json:"-"` // Relative to the run directory
+	Metadata     signal.Metadata          `json:"metadata"`
+	Quality      *signal.ChunkQuality     `json:"quality"`
+	QualityFlags signal.QualityFlags      `json:"quality_flags"`
+	Stationarity *signal.Stationarity     `json:"stationarity"`
+	Features     *signal.SpectrumFeatures `json:"features"`
+	Prediction   *signal.Prediction       `json:"prediction"`
+	Points       signal.EISMeasurement    `json:"points"`
+}
+
+// Run is the content of a run directory
+type Run struct {
+	Dir      string
+	Manifest *output.Manifest // Nil without manifest.json
+	Spectra  []Spectrum       // In sequence order
+	Skipped  []string         // JSON files that are no spectra
+}
+
+// Load reads manifest.json and every spectrum JSON file below dir
+func Load(dir string) (*Run, error) {
+	run := &Run{Dir: dir}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(dir, path)
+		if relative == "manifest.json" {
+			var manifest output.Manifest
+			if err := json.Unmarshal(content, &manifest); err != nil {
+				return config.NewProcessingError("manifest reading", err)
+			}
+			run.Manifest = &manifest
+			return nil
+		}
+		var spectrum Spectrum
+		if err := json.Unmarshal(content, &spectrum); err != nil || len(spectrum.Points) == 0 {
+			run.Skipped = append(run.Skipped, relative)
+			return nil
+		}
+		spectrum.Path = relative
+		run.Spectra = append(run.Spectra, spectrum)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(run.Spectra) == 0 && run.Manifest == nil {
+		return nil, config.NewValidationError("Dir", fmt.Sprintf("%s holds neither spectra nor a manifest", dir))
+	}
+	sort.SliceStable(run.Spectra, func(i, j int) bool {
+		if run.Spectra[i].Sequence != run.Spectra[j].Sequence {
+			return run.Spectra[i].Sequence < run.Spectra[j].Sequence
+		}
+		return run.Spectra[i].Path < run.Spectra[j].Path
+	})
+	return run, nil
+}
+
+// Options tune the report
+type Options struct {
+	Title          string
+	OverlaySpectra int // Spectra drawn in the Nyquist and Bode overlays, evenly spread over the run
+	Generated      time.Time
+}
+
+// DefaultOptions returns the options of `masterapp report`
+func DefaultOptions() Options {
+	return Options{Title: "Measurement report", OverlaySpectra: 8}
+}
+
+// Flags returns the names of the quality flags the pipeline stored with the
+// spectrum, empty if there are none
+func (s Spectrum) Flags() []string {
+	return s.QualityFlags.Names()
+}
+
+// flaggedSpectrum is a row of the quality table
+type flaggedSpectrum struct {
+	Spectrum
+	Flags []string
+}
+
+// flagCount is a row of the flag summary
+type flagCount struct {
+	Flag  string
+	Count int
+}
+
+// WriteHTML renders the run as a standalone HTML page with inline SVG plots
+func (r *Run) WriteHTML(w io.Writer, options Options) error {
+	page, err := template.New("report").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(reportTemplate)
+	if err != nil {
+		return config.NewProcessingError("report template", err)
+	}
+
+	var flagged []flaggedSpectrum
+	counts := map[string]int{}
+	withQuality, withFeatures := false, false
+	for _, s := range r.Spectra {
+		withQuality = withQuality || s.Quality != nil || s.Stationarity != nil
+		withFeatures = withFeatures || s.Features != nil
+		if flags := s.Flags(); len(flags) > 0 {
+			flagged = append(flagged, flaggedSpectrum{s, flags})
+			for _, flag := range flags {
+				counts[flag]++
+			}
+		}
+	}
+	var summary []flagCount
+	for flag, count := range counts {
+		summary = append(summary, flagCount{flag, count})
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Flag < summary[j].Flag })
+
+	plots := map[string]template.HTML{}
+	for name, render := range map[string]func(io.Writer) error{
+		"nyquist":   func(w io.Writer) error { return r.writeNyquist(w, options) },
+		"bode":      func(w io.Writer) error { return r.writeBode(w, options) },
+		"features":  r.writeFeatureTrends,
+		"frequency": r.writeFrequencyTrend,
+		"snr":       r.writeSNRTrend,
+	} {
+		var svg bytes.Buffer
+		if err := render(&svg); err != nil {
+			if errors.Is(err, errNoData) {
+				continue
+			}
+			return config.NewProcessingError("report plotting", err)
+		}
+		plots[name] = template.HTML(svg.String()) // Escaped by the plot package
+	}
+
+	generated := options.Generated
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	return page.Execute(w, map[string]any{
+		"Title":        options.Title,
+		"Generated":    generated.Format(time.RFC3339),
+		"Version":      output.BuildVersion(),
+		"Run":          r,
+		"Range":        r.frequencyRange(),
+		"Plots":        plots,
+		"Flagged":      flagged,
+		"FlagSummary":  summary,
+		"WithQuality":  withQuality,
+		"WithFeatures": withFeatures,
+		"Options":      options,
+	})
+}
+
+// errNoData skips plots without anything to draw
+var errNoData = errors.New("no data to plot")
+
+// overlay returns up to n spectra evenly spread over the run
+func (r *Run) overlay(n int) []Spectrum {
+	if len(r.Spectra) <= n || n < 2 {
+		return r.Spectra[:min(len(r.Spectra), max(n, 1))]
+	}
+	picked := make([]Spectrum, n)
+	for i := range picked {
+		picked[i] = r.Spectra[i*(len(r.Spectra)-1)/(n-1)]
+	}
+	return picked
+}
+
+// label names a spectrum in plot legends
+func (s Spectrum) label() string {
+	if s.Sequence != 0 {
+		return fmt.Sprintf("#%d", s.Sequence)
+	}
+	return s.Path
+}
+
+func (r *Run) writeNyquist(w io.Writer, options Options) error {
+	var series []plot.Series
+	for i, s := range r.overlay(options.OverlaySpectra) {
+		line := plot.Series{Name: s.label(), Color: plot.Palette[i%len(plot.Palette)]}
+		for _, p := range s.Points {
+			line.X = append(line.X, p.Real)
+			line.Y = append(line.Y, -p.Imag)
+		}
+		series = append(series, line)
+	}
+	if len(series) == 0 {
+		return errNoData
+	}
+	return plot.WriteSVG(w, "Nyquist plot", "Re Z (Ω)", "-Im Z (Ω)", series)
+}
+
+func (r *Run) writeBode(w io.Writer, options Options) error {
+	var series []plot.Series
+	for i, s := range r.overlay(options.OverlaySpectra) {
+		line := plot.Series{Name: s.label(), Color: plot.Palette[i%len(plot.Palette)]}
+		for _, p := range s.Points {
+			if p.Frequency <= 0 {
+				continue
+			}
+			line.X = append(line.X, math.Log10(p.Frequency))
+			line.Y = append(line.Y, cmplx.Abs(complex(p.Real, p.Imag)))
+		}
+		series = append(series, line)
+	}
+	if len(series) == 0 {
+		return errNoData
+	}
+	return plot.WriteSVG(w, "Bode magnitude", "log10 f (Hz)", "|Z| (Ω)", series)
+}
+
+func (r *Run) writeFeatureTrends(w io.Writer) error {
+	series := []plot.Series{
+		{Name: "HF intercept", Color: plot.Palette[0]},
+		{Name: "LF intercept", Color: plot.Palette[1]},
+		{Name: "semicircle diameter", Color: plot.Palette[2]},
+	}
+	for i, s := range r.Spectra {
+		if s.Features == nil {
+			continue
+		}
+		x := sequenceX(s, i)
+		for k, v := range []float64{s.Features.HighFrequencyIntercept, s.Features.LowFrequencyIntercept, s.Features.SemicircleDiameter} {
+			series[k].X, series[k].Y = append(series[k].X, x), append(series[k].Y, v)
+		}
+	}
+	if len(series[0].X) == 0 {
+		return errNoData
+	}
+	return plot.WriteSVG(w, "Parameter trends", "spectrum", "resistance (Ω)", series)
+}
+
+func (r *Run) writeFrequencyTrend(w io.Writer) error {
+	line := plot.Series{Name: "characteristic frequency", Color: plot.Palette[3]}
+	for i, s := range r.Spectra {
+		if s.Features != nil {
+			line.X, line.Y = append(line.X, sequenceX(s, i)), append(line.Y, s.Features.CharacteristicFrequency)
+		}
+	}
+	if len(line.X) == 0 {
+		return errNoData
+	}
+	return plot.WriteSVG(w, "Characteristic frequency", "spectrum", "frequency (Hz)", []plot.Series{line})
+}
+
+func (r *Run) writeSNRTrend(w io.Writer) error {
+	voltage := plot.Series{Name: "voltage SNR", Color: plot.Palette[0]}
+	current := plot.Series{Name: "current SNR", Color: plot.Palette[1]}
+	for i, s := range r.Spectra {
+		if s.Quality == nil {
+			continue
+		}
+		x := sequenceX(s, i)
+		voltage.X, voltage.Y = append(voltage.X, x), append(voltage.Y, s.Quality.Voltage.SNRdB)
+		current.X, current.Y = append(current.X, x), append(current.Y, s.Quality.Current.SNRdB)
+	}
+	if len(voltage.X) == 0 {
+		return errNoData
+	}
+	return plot.WriteSVG(w, "Signal-to-noise ratio", "spectrum", "SNR (dB)", []plot.Series{voltage, current})
+}
+
+// sequenceX places a spectrum on trend axes: its sequence number, or its
+// position in the run if it has none
+func sequenceX(s Spectrum, index int) float64 {
+	if s.Sequence != 0 {
+		return float64(s.Sequence)
+	}
+	return float64(index + 1)
+}
+
+// frequencyRange describes the frequencies covered by the spectra
+func (r *Run) frequencyRange() string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range r.Spectra {
+		for _, p := range s.Points {
+			lo, hi = math.Min(lo, p.Frequency), math.Max(hi, p.Frequency)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return "none"
+	}
+	return fmt.Sprintf("%.4g Hz to %.4g Hz", lo, hi)
+}
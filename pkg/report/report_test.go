@@ -0,0 +1,78 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adam/masterapp/pkg/output"
+)
+
+func TestLoadAndWriteHTML(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output.WriteManifest(filepath.Join(dir, "manifest.json"), output.Manifest{Mode: "file", SpectraProduced: 2, ErrorCount: 1, Errors: []string{"chunk <3> too short"}})
+	points := `"points":[{"frequency":1000,"real":10,"imag":-1},{"frequency":10,"real":20,"imag":-8}]`
+	quality := func(snr float64) string {
+		channel := fmt.Sprintf(`{"rms":1,"crest_factor":1.4,"clipping_percent":0,"snr_db":%g,"dc_offset":0,"nyquist_power_percent":0}`, snr)
+		return `"quality":{"voltage":` + channel + `,"current":` + channel + `}`
+	}
+	features := `"features":{"hf_intercept":10,"lf_intercept":20,"semicircle_diameter":10,"characteristic_frequency":100}`
+	// Written out of order, in a subdirectory as by an output template
+	write("2024/eis_002.json", `{"id":"b","sequence":2,`+quality(12)+`,"quality_flags":66,"stationarity":{"windows":4,"magnitude_drift_percent":9,"phase_drift_deg":1,"stationary":false},`+features+`,`+points+`}`)
+	write("eis_001.json", `{"id":"a","sequence":1,`+quality(40)+`,`+features+`,`+points+`}`)
+	write("checkpoint.json", `{"mode":"file","file_index":2}`)
+
+	run, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if run.Manifest == nil || run.Manifest.ErrorCount != 1 {
+		t.Errorf("manifest = %+v, want the written one", run.Manifest)
+	}
+	if len(run.Spectra) != 2 || run.Spectra[0].ID != "a" || run.Spectra[1].Path != filepath.Join("2024", "eis_002.json") {
+		t.Fatalf("spectra = %+v, want a then b", run.Spectra)
+	}
+	if len(run.Skipped) != 1 || run.Skipped[0] != "checkpoint.json" {
+		t.Errorf("skipped = %v, want checkpoint.json", run.Skipped)
+	}
+
+	options := DefaultOptions()
+	options.Generated = time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	if flags := run.Spectra[0].Flags(); len(flags) != 0 {
+		t.Errorf("Flags() = %v for a spectrum without quality flags", flags)
+	}
+	if flags := run.Spectra[1].Flags(); strings.Join(flags, ",") != "low_snr,non_stationary" {
+		t.Errorf("Flags() = %v, want low_snr and non_stationary", flags)
+	}
+	var html bytes.Buffer
+	if err := run.WriteHTML(&html, options); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	page := html.String()
+	for _, want := range []string{
+		"<title>Measurement report</title>", "Nyquist plot", "Bode magnitude", "Parameter trends",
+		"Signal-to-noise ratio", "non_stationary", "Fit results", "chunk &lt;3&gt; too short", "10 Hz to 1000 Hz",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+	if strings.Count(page, "<svg") != 5 {
+		t.Errorf("report has %d plots, want 5", strings.Count(page, "<svg"))
+	}
+
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("Load() accepted an empty directory")
+	}
+}
@@ -0,0 +1,186 @@
+// Package schedule parses cron expressions and computes their trigger times.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// searchLimit bounds the search for the next trigger; expressions such as
+// "0 0 30 2 *" never match
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// shortcuts are the named expressions of Vixie cron
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the range and names of one cron field
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of min, min+1, ...
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Cron is a parsed five field cron expression (minute, hour, day of month,
+// month, day of week) with lists, ranges, steps, month and weekday names and
+// the @daily style shortcuts. As in Vixie cron, a day matches if either day
+// field matches when both are restricted, and 7 is Sunday like 0.
+type Cron struct {
+	expr               string
+	minutes, hours     uint64
+	days, months       uint64
+	weekdays           uint64
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+// Parse parses a cron expression
+func Parse(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if shortcut, ok := shortcuts[strings.ToLower(spec)]; ok {
+		spec = shortcut
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, config.NewValidationError("Schedule", fmt.Sprintf("'%s' must have 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(parts)))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, config.NewValidationError("Schedule", fmt.Sprintf("'%s': %v", expr, err))
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1 // Sunday
+	}
+	return &Cron{
+		expr:               expr,
+		minutes:            sets[0],
+		hours:              sets[1],
+		days:               sets[2],
+		months:             sets[3],
+		weekdays:           sets[4],
+		daysRestricted:     parts[2] != "*",
+		weekdaysRestricted: parts[4] != "*",
+	}, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into
+// a bit set
+func parseField(spec string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(spec, ",") {
+		rangeSpec, step := item, 1
+		if slash := strings.IndexByte(item, '/'); slash >= 0 {
+			rangeSpec = item[:slash]
+			n, err := strconv.Atoi(item[slash+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s '%s'", f.name, item)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangeSpec == "*":
+		case strings.Contains(rangeSpec, "-"):
+			bounds := strings.SplitN(rangeSpec, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("empty %s range '%s'", f.name, rangeSpec)
+			}
+		default:
+			value, err := parseValue(rangeSpec, f)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			if step == 1 {
+				high = value // A single value, unless it starts a step like 5/15
+			}
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a number or name within the range of f
+func parseValue(spec string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(spec, name) {
+			return f.min + i, nil
+		}
+	}
+	value, err := strconv.Atoi(spec)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("%s '%s' outside of %d-%d", f.name, spec, f.min, f.max)
+	}
+	return value, nil
+}
+
+// String returns the expression as written
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first trigger time after t, in the location of t, or the
+// zero time if the expression never matches
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(searchLimit)
+	for next.Before(limit) {
+		switch {
+		case c.months&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !c.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case c.hours&(1<<uint(next.Hour())) == 0:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case c.minutes&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the day of month and day of week fields to t
+func (c *Cron) dayMatches(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.daysRestricted && c.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
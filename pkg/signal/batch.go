@@ -0,0 +1,62 @@
+package signal
+
+// SharedGridSchema marks batches whose spectra share the batch-level frequency grid
+const SharedGridSchema = "shared-grid"
+
+// CompactGrid returns a copy of the batch in the shared-grid schema when all
+// spectra use the same frequency grid, and the batch unchanged otherwise
+func (b ImpedanceBatch) CompactGrid() ImpedanceBatch {
+	if b.Schema == SharedGridSchema || len(b.Spectra) == 0 {
+		return b
+	}
+
+	grid := b.Spectra[0].ImpedanceData.Frequencies
+	for _, spectrum := range b.Spectra[1:] {
+		if !equalGrid(grid, spectrum.ImpedanceData.Frequencies) {
+			return b
+		}
+	}
+
+	compact := b
+	compact.Schema = SharedGridSchema
+	compact.Frequencies = grid
+	compact.Spectra = make([]ImpedanceDataWithIteration, len(b.Spectra))
+	for i, spectrum := range b.Spectra {
+		spectrum.ImpedanceData.Frequencies = nil
+		compact.Spectra[i] = spectrum
+	}
+	return compact
+}
+
+// ExpandGrid returns a copy of the batch in which every spectrum carries its
+// own frequencies again; batches in the per-spectrum schema are returned unchanged
+func (b ImpedanceBatch) ExpandGrid() ImpedanceBatch {
+	if b.Schema != SharedGridSchema {
+		return b
+	}
+
+	expanded := b
+	expanded.Schema = ""
+	expanded.Frequencies = nil
+	expanded.Spectra = make([]ImpedanceDataWithIteration, len(b.Spectra))
+	for i, spectrum := range b.Spectra {
+		if len(spectrum.ImpedanceData.Frequencies) == 0 {
+			spectrum.ImpedanceData.Frequencies = b.Frequencies
+		}
+		expanded.Spectra[i] = spectrum
+	}
+	return expanded
+}
+
+// equalGrid reports whether two frequency grids are identical
+func equalGrid(a, b []float64) bool {
+	if len(a) != len(b) || len(a) == 0 {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
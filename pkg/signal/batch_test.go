@@ -0,0 +1,73 @@
+package signal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestImpedanceBatch_SharedGridRoundTrip(t *testing.T) {
+	grid := []float64{1, 10, 100}
+	spectrum := func(iteration int, frequencies []float64) ImpedanceDataWithIteration {
+		return ImpedanceDataWithIteration{
+			ImpedanceData: ImpedanceData{
+				Identity:    NewIdentity(),
+				Frequencies: frequencies,
+				Impedance:   []complex128{complex(float64(iteration), -1), 2 - 2i, 3 - 3i},
+			},
+			Iteration: iteration,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		batch      ImpedanceBatch
+		wantSchema string
+	}{
+		{
+			name:       "shared grid",
+			batch:      ImpedanceBatch{BatchID: "b1", Spectra: []ImpedanceDataWithIteration{spectrum(1, grid), spectrum(2, []float64{1, 10, 100})}},
+			wantSchema: SharedGridSchema,
+		},
+		{
+			name:  "different grids",
+			batch: ImpedanceBatch{BatchID: "b2", Spectra: []ImpedanceDataWithIteration{spectrum(1, grid), spectrum(2, []float64{2, 20, 200})}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compact := tt.batch.CompactGrid()
+			if compact.Schema != tt.wantSchema {
+				t.Fatalf("schema = %q, want %q", compact.Schema, tt.wantSchema)
+			}
+
+			jsonData, err := json.Marshal(compact)
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			wantGrids := len(tt.batch.Spectra)
+			if tt.wantSchema == SharedGridSchema {
+				wantGrids = 1
+			}
+			if got := strings.Count(string(jsonData), `"frequencies"`); got != wantGrids {
+				t.Errorf("frequency grids serialized %d times, want %d", got, wantGrids)
+			}
+
+			var decoded ImpedanceBatch
+			if err := json.Unmarshal(jsonData, &decoded); err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+			expanded := decoded.ExpandGrid()
+			for i, item := range expanded.Spectra {
+				want := tt.batch.Spectra[i].ImpedanceData
+				if !equalGrid(item.ImpedanceData.Frequencies, want.Frequencies) {
+					t.Errorf("spectrum %d frequencies = %v, want %v", i, item.ImpedanceData.Frequencies, want.Frequencies)
+				}
+				if item.ImpedanceData.ID != want.ID || item.ImpedanceData.Impedance[0] != want.Impedance[0] {
+					t.Errorf("spectrum %d = %+v, want %+v", i, item.ImpedanceData, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// RandlesCircuit is the R_s + (R_ct || CPE) equivalent circuit also used by
+// the direct EIS generator
+type RandlesCircuit struct {
+	Rs  float64 // Solution resistance in Ω
+	Rct float64 // Charge transfer resistance in Ω
+	Q   float64 // CPE coefficient
+	N   float64 // CPE exponent, 1 = ideal capacitor
+}
+
+// DefaultCircuit returns the circuit of the synthetic FFT-mode signals: about
+// 30 Ω at low and 10 Ω at high frequencies with a dispersion around 10 Hz
+func DefaultCircuit() RandlesCircuit {
+	return RandlesCircuit{Rs: 10, Rct: 20, Q: 8e-4, N: 0.9}
+}
+
+// Impedance returns Z(f); at DC the CPE blocks and Z = R_s + R_ct
+func (c RandlesCircuit) Impedance(frequency float64) complex128 {
+	if frequency <= 0 {
+		return complex(c.Rs+c.Rct, 0)
+	}
+
+	w := 2 * math.Pi * frequency
+	zCPE := 1 / (complex(c.Q, 0) * cmplx.Pow(complex(0, w), complex(c.N, 0)))
+	rct := complex(c.Rct, 0)
+	return complex(c.Rs, 0) + rct*zCPE/(rct+zCPE)
+}
+
+// Validate checks that the parameters describe a physical cell: finite
+// resistances R_s > 0 and R_ct > 0, Q > 0 and 0 < n ≤ 1
+func (c RandlesCircuit) Validate() error {
+	for _, parameter := range []struct {
+		name  string
+		value float64
+	}{{"R_s", c.Rs}, {"R_ct", c.Rct}, {"Q", c.Q}} {
+		if !(parameter.value > 0) || math.IsInf(parameter.value, 0) {
+			return config.NewValidationError(parameter.name, fmt.Sprintf("%s must be finite and greater than 0, got %g", parameter.name, parameter.value))
+		}
+	}
+	if !(c.N > 0 && c.N <= 1) {
+		return config.NewValidationError("n", fmt.Sprintf("CPE exponent must be in (0, 1], got %g", c.N))
+	}
+	return nil
+}
+
+// CharacteristicFrequency returns the frequency of the arc apex,
+// 1 / (2π (R_ct Q)^(1/n))
+func (c RandlesCircuit) CharacteristicFrequency() float64 {
+	return 1 / (2 * math.Pi * math.Pow(c.Rct*c.Q, 1/c.N))
+}
+
+// EffectiveCapacitance returns the capacitance equivalent to the CPE in
+// parallel with R_ct, Q^(1/n) R_ct^((1−n)/n)
+func (c RandlesCircuit) EffectiveCapacitance() float64 {
+	return math.Pow(c.Q, 1/c.N) * math.Pow(c.Rct, (1-c.N)/c.N)
+}
+
+// Plausibility describes valid but physically implausible aspects of the
+// circuit for a measurement from fmin to fmax Hz: a CPE exponent below 0.5
+// (diffusion rather than a double layer), an effective capacitance outside
+// 1 pF–10 F, an arc apex more than a decade outside the measured range, or an
+// arc below 1% of R_s that the spectrum hardly resolves
+func (c RandlesCircuit) Plausibility(fmin, fmax float64) []string {
+	var warnings []string
+	if c.N < 0.5 {
+		warnings = append(warnings, fmt.Sprintf("CPE exponent n=%g below 0.5 describes diffusion rather than a double layer", c.N))
+	}
+	if capacitance := c.EffectiveCapacitance(); capacitance < 1e-12 || capacitance > 10 {
+		warnings = append(warnings, fmt.Sprintf("effective capacitance %.3g F is outside 1 pF to 10 F", capacitance))
+	}
+	if apex := c.CharacteristicFrequency(); fmin > 0 && fmax > 0 && (apex < fmin/10 || apex > fmax*10) {
+		warnings = append(warnings, fmt.Sprintf("arc apex at %.3g Hz is more than a decade outside the measured %g to %g Hz", apex, fmin, fmax))
+	}
+	if c.Rct < 0.01*c.Rs {
+		warnings = append(warnings, fmt.Sprintf("R_ct=%g Ω is below 1%% of R_s=%g Ω, the arc is hardly resolvable", c.Rct, c.Rs))
+	}
+	return warnings
+}
+
+// Tone is one sinusoidal component of an excitation signal
+type Tone struct {
+	Frequency float64 // Hz
+	Amplitude float64 // Peak amplitude
+	Phase     float64 // Radians, of a sine
+}
+
+// DefaultExcitation returns the multisine voltage excitation of the synthetic
+// FFT-mode signals, with amplitudes decreasing towards high frequencies
+func DefaultExcitation() []Tone {
+	frequencies := []float64{1, 5, 10, 25, 50, 100, 250, 500}
+	amplitudes := []float64{0.2, 0.15, 0.12, 0.1, 0.08, 0.06, 0.04, 0.02}
+
+	tones := make([]Tone, len(frequencies))
+	for i := range frequencies {
+		tones[i] = Tone{Frequency: frequencies[i], Amplitude: amplitudes[i]}
+	}
+	return tones
+}
+
+// phasors returns the complex line spectrum of the tones multiplied by the
+// transfer function h, using the sine convention A·sin(ωt + φ) = Im(A·e^{jφ}·e^{jωt})
+func phasors(tones []Tone, h func(frequency float64) complex128) []complex128 {
+	result := make([]complex128, len(tones))
+	for i, tone := range tones {
+		result[i] = cmplx.Rect(tone.Amplitude, tone.Phase) * h(tone.Frequency)
+	}
+	return result
+}
+
+// synthesize evaluates the inverse transform of a line spectrum at n samples
+func synthesize(tones []Tone, lines []complex128, sampleRate float64, n int) []float64 {
+	values := make([]float64, n)
+	for i := range tones {
+		w := 2 * math.Pi * tones[i].Frequency / sampleRate
+		amplitude, phase := cmplx.Abs(lines[i]), cmplx.Phase(lines[i])
+		for k := range values {
+			values[k] += amplitude * math.Sin(w*float64(k)+phase)
+		}
+	}
+	return values
+}
@@ -0,0 +1,68 @@
+package signal
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRandlesCircuit_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		circuit RandlesCircuit
+		wantErr bool
+	}{
+		{name: "default", circuit: DefaultCircuit()},
+		{name: "ideal capacitor", circuit: RandlesCircuit{Rs: 1, Rct: 100, Q: 1e-6, N: 1}},
+		{name: "zero R_s", circuit: RandlesCircuit{Rs: 0, Rct: 20, Q: 1e-5, N: 0.9}, wantErr: true},
+		{name: "negative R_ct", circuit: RandlesCircuit{Rs: 10, Rct: -20, Q: 1e-5, N: 0.9}, wantErr: true},
+		{name: "zero Q", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 0, N: 0.9}, wantErr: true},
+		{name: "NaN Q", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: math.NaN(), N: 0.9}, wantErr: true},
+		{name: "infinite R_ct", circuit: RandlesCircuit{Rs: 10, Rct: math.Inf(1), Q: 1e-5, N: 0.9}, wantErr: true},
+		{name: "n above 1", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 1e-5, N: 1.1}, wantErr: true},
+		{name: "zero n", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 1e-5, N: 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.circuit.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRandlesCircuit_Plausibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		circuit      RandlesCircuit
+		wantWarnings []string // Substrings of the expected warnings, in order
+	}{
+		{name: "default", circuit: DefaultCircuit()},
+		{name: "diffusion-like CPE", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 8e-4, N: 0.4}, wantWarnings: []string{"below 0.5"}},
+		{name: "huge capacitance", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 100, N: 1}, wantWarnings: []string{"effective capacitance", "arc apex"}},
+		{name: "apex above the range", circuit: RandlesCircuit{Rs: 10, Rct: 20, Q: 1e-9, N: 1}, wantWarnings: []string{"arc apex at 7.96e+06 Hz"}},
+		{name: "tiny arc", circuit: RandlesCircuit{Rs: 1000, Rct: 5, Q: 2e-3, N: 1}, wantWarnings: []string{"below 1% of R_s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := tt.circuit.Plausibility(1, 500)
+			if len(warnings) != len(tt.wantWarnings) {
+				t.Fatalf("Plausibility() = %q, want %d warnings", warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(warnings[i], want) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, warnings[i], want)
+				}
+			}
+		})
+	}
+
+	// The apex is where -Im Z peaks
+	circuit := DefaultCircuit()
+	apex := circuit.CharacteristicFrequency()
+	if peak, below, above := -imag(circuit.Impedance(apex)), -imag(circuit.Impedance(apex*0.9)), -imag(circuit.Impedance(apex*1.1)); peak < below || peak < above {
+		t.Errorf("-Im Z at the apex %.3g Hz = %g, neighbours %g and %g", apex, peak, below, above)
+	}
+}
@@ -0,0 +1,128 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// FrequencyOrder selects the order of the points of output spectra
+type FrequencyOrder string
+
+const (
+	// OrderAsComputed keeps the points in the order of the estimator
+	OrderAsComputed FrequencyOrder = ""
+	// OrderAscending sorts the points from the lowest frequency
+	OrderAscending FrequencyOrder = "ascending"
+	// OrderDescending sorts the points from the highest frequency, the usual
+	// sweep direction of potentiostats
+	OrderDescending FrequencyOrder = "descending"
+)
+
+// Convention describes how output spectra present the impedance, as tools
+// disagree on the sign of the imaginary part, the phase unit and the order
+// of the points. The zero value keeps the computed spectra: Im Z, radians
+// and the order of the estimator.
+type Convention struct {
+	NegateImaginary bool           // Report −Im Z, the phase following the reported sign
+	PhaseDegrees    bool           // Phase arrays in degrees instead of radians
+	Order           FrequencyOrder // Order of the points
+}
+
+// ParseConvention converts the textual settings: imagSign 'positive' or
+// 'negative', phaseUnit 'rad' or 'deg' and order 'as-computed', 'ascending'
+// or 'descending'; empty strings select the defaults
+func ParseConvention(imagSign, phaseUnit, order string) (Convention, error) {
+	var convention Convention
+	switch strings.ToLower(strings.TrimSpace(imagSign)) {
+	case "", "positive":
+	case "negative":
+		convention.NegateImaginary = true
+	default:
+		return Convention{}, config.NewValidationError("ImagSign",
+			fmt.Sprintf("unknown imaginary sign %q, must be positive or negative", imagSign))
+	}
+	switch strings.ToLower(strings.TrimSpace(phaseUnit)) {
+	case "", "rad":
+	case "deg":
+		convention.PhaseDegrees = true
+	default:
+		return Convention{}, config.NewValidationError("PhaseUnit",
+			fmt.Sprintf("unknown phase unit %q, must be rad or deg", phaseUnit))
+	}
+	var err error
+	if convention.Order, err = ParseFrequencyOrder(order); err != nil {
+		return Convention{}, err
+	}
+	return convention, nil
+}
+
+// ParseFrequencyOrder converts 'as-computed', 'ascending' or 'descending';
+// an empty string keeps the computed order
+func ParseFrequencyOrder(value string) (FrequencyOrder, error) {
+	switch order := FrequencyOrder(strings.ToLower(strings.TrimSpace(value))); order {
+	case OrderAsComputed, "as-computed":
+		return OrderAsComputed, nil
+	case OrderAscending, OrderDescending:
+		return order, nil
+	default:
+		return "", config.NewValidationError("FrequencyOrder",
+			fmt.Sprintf("unknown frequency order %q, must be as-computed, ascending or descending", value))
+	}
+}
+
+// IsZero reports whether the convention leaves spectra unchanged
+func (c Convention) IsZero() bool {
+	return c == Convention{}
+}
+
+// Apply returns a copy of data presented in the convention, with magnitude
+// and phase recomputed from the presented impedance
+func (c Convention) Apply(data ImpedanceData) ImpedanceData {
+	if c.IsZero() {
+		return data
+	}
+	result := data
+	result.Impedance = make([]complex128, len(data.Impedance))
+	copy(result.Impedance, data.Impedance)
+	if c.Order != OrderAsComputed && len(data.Frequencies) == len(data.Impedance) {
+		result.Frequencies, result.Impedance = sortByFrequency(data.Frequencies, result.Impedance, c.Order == OrderDescending)
+	}
+	if c.NegateImaginary {
+		for i, z := range result.Impedance {
+			result.Impedance[i] = complex(real(z), -imag(z))
+		}
+	}
+	result.Magnitude, result.Phase = result.CalculateMagnitudePhase()
+	if c.PhaseDegrees {
+		for i := range result.Phase {
+			result.Phase[i] *= 180 / math.Pi
+		}
+	}
+	return result
+}
+
+// sortByFrequency returns copies of frequencies and impedance sorted by
+// frequency; points of equal frequency keep their order
+func sortByFrequency(frequencies []float64, impedance []complex128, descending bool) ([]float64, []complex128) {
+	order := make([]int, len(frequencies))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if descending {
+			return frequencies[order[a]] > frequencies[order[b]]
+		}
+		return frequencies[order[a]] < frequencies[order[b]]
+	})
+	sortedFrequencies := make([]float64, len(order))
+	sortedImpedance := make([]complex128, len(order))
+	for i, j := range order {
+		sortedFrequencies[i] = frequencies[j]
+		sortedImpedance[i] = impedance[j]
+	}
+	return sortedFrequencies, sortedImpedance
+}
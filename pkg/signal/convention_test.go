@@ -0,0 +1,58 @@
+package signal
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseConvention(t *testing.T) {
+	tests := []struct {
+		imagSign, phaseUnit, order string
+		want                       Convention
+		wantErr                    bool
+	}{
+		{"", "", "", Convention{}, false},
+		{"positive", "rad", "as-computed", Convention{}, false},
+		{"negative", "deg", "descending", Convention{NegateImaginary: true, PhaseDegrees: true, Order: OrderDescending}, false},
+		{"", "", "Ascending", Convention{Order: OrderAscending}, false},
+		{"minus", "", "", Convention{}, true},
+		{"", "grad", "", Convention{}, true},
+		{"", "", "random", Convention{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseConvention(tt.imagSign, tt.phaseUnit, tt.order)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseConvention(%q, %q, %q) error = %v, wantErr %v", tt.imagSign, tt.phaseUnit, tt.order, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseConvention(%q, %q, %q) = %+v, want %+v", tt.imagSign, tt.phaseUnit, tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestConvention_Apply(t *testing.T) {
+	data := NewImpedanceData(time.Unix(0, 0), []float64{10, 1000, 100}, []complex128{complex(20, -5), complex(10, -1), complex(15, -3)})
+	original := slices.Clone(data.Impedance)
+
+	presented := Convention{NegateImaginary: true, PhaseDegrees: true, Order: OrderDescending}.Apply(data)
+	if want := []float64{1000, 100, 10}; !slices.Equal(presented.Frequencies, want) {
+		t.Errorf("frequencies = %v, want %v", presented.Frequencies, want)
+	}
+	if want := []complex128{complex(10, 1), complex(15, 3), complex(20, 5)}; !slices.Equal(presented.Impedance, want) {
+		t.Errorf("impedance = %v, want %v", presented.Impedance, want)
+	}
+	if want := math.Atan2(1, 10) * 180 / math.Pi; math.Abs(presented.Phase[0]-want) > 1e-12 {
+		t.Errorf("phase = %g, want %g degrees", presented.Phase[0], want)
+	}
+	if presented.ID != data.ID || !slices.Equal(data.Impedance, original) || data.Frequencies[0] != 10 {
+		t.Error("Apply() changed the identity or the original spectrum")
+	}
+
+	if ascending := (Convention{Order: OrderAscending}).Apply(data); !slices.Equal(ascending.Frequencies, []float64{10, 100, 1000}) || ascending.Impedance[1] != complex(15, -3) {
+		t.Errorf("ascending = %v %v", ascending.Frequencies, ascending.Impedance)
+	}
+}
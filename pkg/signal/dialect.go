@@ -0,0 +1,117 @@
+package signal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// CSVDialect describes how CSV input files are formatted
+type CSVDialect struct {
+	Delimiter          rune // Field separator, e.g. ',' or ';'
+	DecimalSeparator   rune // Decimal mark, '.' or ','
+	ThousandsSeparator rune // Digit grouping mark stripped before parsing, 0 for none
+	LazyQuotes         bool // Accept quotes appearing in unquoted fields
+}
+
+// DefaultCSVDialect is US-style comma separated values with a decimal point
+var DefaultCSVDialect = CSVDialect{
+	Delimiter:        ',',
+	DecimalSeparator: '.',
+}
+
+// ParseCSVDialect builds a dialect from textual options. Delimiters may be given
+// literally or as "comma", "semicolon", "tab" or "space".
+func ParseCSVDialect(delimiter, decimal, thousands string, lazyQuotes bool) (CSVDialect, error) {
+	dialect := DefaultCSVDialect
+	dialect.LazyQuotes = lazyQuotes
+
+	var err error
+	if dialect.Delimiter, err = parseDialectRune("Delimiter", delimiter, ','); err != nil {
+		return CSVDialect{}, err
+	}
+	if dialect.DecimalSeparator, err = parseDialectRune("DecimalSeparator", decimal, '.'); err != nil {
+		return CSVDialect{}, err
+	}
+	if dialect.ThousandsSeparator, err = parseDialectRune("ThousandsSeparator", thousands, 0); err != nil {
+		return CSVDialect{}, err
+	}
+
+	if err := dialect.Validate(); err != nil {
+		return CSVDialect{}, err
+	}
+	return dialect, nil
+}
+
+// Validate checks that the dialect's separators do not conflict
+func (d CSVDialect) Validate() error {
+	if d.Delimiter == '"' || d.Delimiter == '\r' || d.Delimiter == '\n' || !utf8.ValidRune(d.Delimiter) {
+		return config.NewValidationError("Delimiter", fmt.Sprintf("invalid delimiter %q", d.Delimiter))
+	}
+	if d.DecimalSeparator != '.' && d.DecimalSeparator != ',' {
+		return config.NewValidationError("DecimalSeparator", "decimal separator must be '.' or ','")
+	}
+	if d.DecimalSeparator == d.Delimiter {
+		return config.NewValidationError("DecimalSeparator", "decimal separator cannot equal the delimiter")
+	}
+	if d.ThousandsSeparator != 0 && (d.ThousandsSeparator == d.DecimalSeparator || d.ThousandsSeparator == d.Delimiter) {
+		return config.NewValidationError("ThousandsSeparator", "thousands separator conflicts with decimal separator or delimiter")
+	}
+	return nil
+}
+
+// NewReader creates a csv.Reader configured for the dialect
+func (d CSVDialect) NewReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	if d.Delimiter != 0 {
+		reader.Comma = d.Delimiter
+	}
+	reader.LazyQuotes = d.LazyQuotes
+	if reader.Comma != '#' {
+		reader.Comment = '#' // Metadata blocks of generated files
+	}
+	return reader
+}
+
+// ParseFloat parses a number written in the dialect's locale
+func (d CSVDialect) ParseFloat(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if d.ThousandsSeparator != 0 {
+		value = strings.ReplaceAll(value, string(d.ThousandsSeparator), "")
+	}
+	if d.DecimalSeparator == ',' {
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseDialectRune converts a textual separator option to a rune
+func parseDialectRune(field, value string, fallback rune) (rune, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return fallback, nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "tab", "\\t":
+		return '\t', nil
+	case "space":
+		return ' ', nil
+	case "dot", "point":
+		return '.', nil
+	case "none":
+		return 0, nil
+	}
+
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, config.NewValidationError(field, fmt.Sprintf("separator must be a single character, got '%s'", value))
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
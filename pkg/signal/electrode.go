@@ -0,0 +1,224 @@
+package signal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Electrode names a terminal of an electrochemical cell
+type Electrode string
+
+const (
+	ElectrodeWorking   Electrode = "WE"
+	ElectrodeCounter   Electrode = "CE"
+	ElectrodeReference Electrode = "RE"
+	ElectrodeGround    Electrode = "GND" // Instrument ground; its potential is 0 and it has no column
+)
+
+// electrodeColumnNames maps normalized header names to electrodes
+var electrodeColumnNames = map[string]Electrode{
+	"we": ElectrodeWorking, "working": ElectrodeWorking, "workingelectrode": ElectrodeWorking, "ewe": ElectrodeWorking,
+	"ce": ElectrodeCounter, "counter": ElectrodeCounter, "counterelectrode": ElectrodeCounter, "ece": ElectrodeCounter,
+	"re": ElectrodeReference, "reference": ElectrodeReference, "referenceelectrode": ElectrodeReference, "ere": ElectrodeReference,
+}
+
+// ParseElectrode converts an electrode name such as "WE", "reference" or "GND"
+func ParseElectrode(name string) (Electrode, error) {
+	normalized := normalizeHeader(name)
+	if normalized == "gnd" || normalized == "ground" {
+		return ElectrodeGround, nil
+	}
+	if electrode, ok := electrodeColumnNames[normalized]; ok {
+		return electrode, nil
+	}
+	return "", config.NewValidationError("Electrode", fmt.Sprintf("unknown electrode %q, must be WE, CE, RE or GND", name))
+}
+
+// ElectrodePair selects the potential difference Positive - Negative between
+// two terminals, e.g. WE-RE for the working electrode potential of a
+// three-electrode cell
+type ElectrodePair struct {
+	Positive Electrode
+	Negative Electrode
+}
+
+// String formats the pair as "WE-RE"
+func (p ElectrodePair) String() string {
+	return string(p.Positive) + "-" + string(p.Negative)
+}
+
+// ParseElectrodePair converts a pair such as "WE-RE"; a single electrode is
+// measured against ground
+func ParseElectrodePair(value string) (ElectrodePair, error) {
+	positive, negative, found := strings.Cut(strings.TrimSpace(value), "-")
+	if !found {
+		negative = string(ElectrodeGround)
+	}
+
+	var pair ElectrodePair
+	var err error
+	if pair.Positive, err = ParseElectrode(positive); err != nil {
+		return ElectrodePair{}, err
+	}
+	if pair.Negative, err = ParseElectrode(negative); err != nil {
+		return ElectrodePair{}, err
+	}
+	if pair.Positive == pair.Negative {
+		return ElectrodePair{}, config.NewValidationError("ElectrodePair", fmt.Sprintf("pair %q measures an electrode against itself", value))
+	}
+	return pair, nil
+}
+
+// ParseElectrodePairs converts a comma separated list of pairs
+func ParseElectrodePairs(list string) ([]ElectrodePair, error) {
+	var pairs []ElectrodePair
+	for _, field := range strings.Split(list, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		pair, err := ParseElectrodePair(field)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	if len(pairs) == 0 {
+		return nil, config.NewValidationError("ElectrodePairs", "at least one electrode pair is required")
+	}
+	return pairs, nil
+}
+
+// ElectrodeSignals holds one chunk of the potential of each cell terminal
+// against instrument ground
+type ElectrodeSignals map[Electrode]Signal
+
+// Voltage returns the potential difference of the pair, annotated with the
+// pair as channel
+func (es ElectrodeSignals) Voltage(pair ElectrodePair) (Signal, error) {
+	var reference Signal
+	found := false
+	for _, electrode := range []Electrode{pair.Positive, pair.Negative} {
+		if electrode == ElectrodeGround {
+			continue
+		}
+		sig, ok := es[electrode]
+		if !ok {
+			return Signal{}, config.NewValidationError("ElectrodePair", fmt.Sprintf("no potential recorded for %s", electrode))
+		}
+		if found && len(sig.Values) != len(reference.Values) {
+			return Signal{}, config.NewValidationError("ElectrodePair", fmt.Sprintf("%s potentials have different lengths", pair))
+		}
+		if !found {
+			reference, found = sig, true
+		}
+	}
+
+	values := make([]float64, len(reference.Values))
+	if pair.Positive != ElectrodeGround {
+		for i, v := range es[pair.Positive].Values {
+			values[i] += v
+		}
+	}
+	if pair.Negative != ElectrodeGround {
+		for i, v := range es[pair.Negative].Values {
+			values[i] -= v
+		}
+	}
+
+	return Signal{
+		Timestamp:   reference.Timestamp,
+		Values:      values,
+		SampleRate:  reference.SampleRate,
+		Metadata:    reference.Metadata.Merge(Metadata{Unit: UnitVolt, Channel: pair.String()}),
+		SampleTimes: reference.SampleTimes,
+		Gap:         reference.Gap,
+	}, nil
+}
+
+// LoadElectrodeSignalsFromCSV loads the terminal potentials of a multi-electrode
+// cell. Expected CSV format: timestamp,time_offset followed by one column per
+// electrode named after it (we/working, ce/counter, re/reference) in any order.
+// Rows skipped in lenient mode must be skipped for every electrode alike.
+func (loader *CSVDataLoader) LoadElectrodeSignalsFromCSV(filename string, sampleRate float64) ([]ElectrodeSignals, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", filename, err))
+	}
+	defer file.Close()
+
+	reader := loader.dialect.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, config.NewProcessingError("CSV reading", fmt.Errorf("failed to read CSV: %w", err))
+	}
+	if len(records) < 2 {
+		return nil, config.NewValidationError("Data", "CSV file must have at least header and one data row")
+	}
+
+	columns := make(map[Electrode]int)
+	for i, name := range records[0] {
+		if electrode, ok := electrodeColumnNames[normalizeHeader(name)]; ok && i >= 2 {
+			if _, duplicate := columns[electrode]; duplicate {
+				return nil, config.NewValidationError("Header", fmt.Sprintf("%s has more than one %s column", filename, electrode))
+			}
+			columns[electrode] = i
+		}
+	}
+	if len(columns) == 0 {
+		return nil, config.NewValidationError("Header",
+			fmt.Sprintf("%s header must name electrode columns (we, ce, re) after timestamp,time_offset, got %v", filename, records[0]))
+	}
+
+	// Project each electrode column onto the timestamp,time_offset,value layout
+	chunks := make(map[Electrode][]Signal, len(columns))
+	count := -1
+	for electrode, column := range columns {
+		projected := make([][]string, len(records)-1)
+		for i, record := range records[1:] {
+			projected[i] = record[:min(len(record), 2)] // Too short, reported by the parser
+			if len(record) > column {
+				projected[i] = []string{record[0], record[1], record[column]}
+			}
+		}
+
+		samples, report, err := loader.parseSamples(fmt.Sprintf("%s (%s)", filename, electrode), projected, sampleRate)
+		loader.reports = append(loader.reports, report)
+		if err != nil {
+			return nil, err
+		}
+		samples = loader.window.apply(samples)
+		if len(samples) == 0 {
+			return nil, config.NewValidationError("TimeWindow",
+				fmt.Sprintf("%s has no samples within time window %s", filename, loader.window))
+		}
+		if count >= 0 && len(samples) != count {
+			return nil, config.NewValidationError("Data",
+				fmt.Sprintf("%s: electrode columns have different numbers of valid samples, use parse mode repair", filename))
+		}
+		count = len(samples)
+
+		ranges, err := loader.chunkRanges(sampleRate, samples)
+		if err != nil {
+			return nil, err
+		}
+		if chunks[electrode], err = loader.chunkSignals(samples, ranges, sampleRate); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []ElectrodeSignals
+	for electrode, signals := range chunks {
+		for i, sig := range signals {
+			if i == len(result) {
+				result = append(result, make(ElectrodeSignals, len(chunks)))
+			}
+			sig.Metadata = sig.Metadata.Merge(Metadata{Unit: UnitVolt, Channel: string(electrode)})
+			result[i][electrode] = sig
+		}
+	}
+	return result, nil
+}
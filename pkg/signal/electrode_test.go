@@ -0,0 +1,92 @@
+package signal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseElectrodePair(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ElectrodePair
+		wantErr bool
+	}{
+		{"WE-RE", ElectrodePair{ElectrodeWorking, ElectrodeReference}, false},
+		{" counter - working ", ElectrodePair{ElectrodeCounter, ElectrodeWorking}, false},
+		{"we", ElectrodePair{ElectrodeWorking, ElectrodeGround}, false},
+		{"WE-WE", ElectrodePair{}, true},
+		{"WE-XX", ElectrodePair{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseElectrodePair(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseElectrodePair() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseElectrodePair() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVDataLoader_LoadElectrodeSignalsFromCSV(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("timestamp,time_offset,RE,WE,CE\n")
+	rows := []string{
+		"2024-01-01T00:00:00Z,0,0.2,1.0,-0.5",
+		"2024-01-01T00:00:00.25Z,0.25,0.2,1.1,-0.6",
+		"2024-01-01T00:00:00.5Z,0.5,0.2,1.2,-0.7",
+		"2024-01-01T00:00:00.75Z,0.75,0.2,1.3,-0.8",
+		"2024-01-01T00:00:01Z,1,0.3,1.4,-0.9",
+		"2024-01-01T00:00:01.25Z,1.25,0.3,1.5,-1.0",
+	}
+	content.WriteString(strings.Join(rows, "\n"))
+	path := writeTestFile(t, "electrodes.csv", content.String())
+
+	loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect})
+	chunks, err := loader.LoadElectrodeSignalsFromCSV(path, 4)
+	if err != nil {
+		t.Fatalf("LoadElectrodeSignalsFromCSV() error = %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+
+	tests := []struct {
+		pair string
+		want []float64
+	}{
+		{"WE-RE", []float64{0.8, 0.9, 1.0, 1.1}},
+		{"WE-CE", []float64{1.5, 1.7, 1.9, 2.1}},
+		{"GND-RE", []float64{-0.2, -0.2, -0.2, -0.2}},
+	}
+	for _, tt := range tests {
+		pair, err := ParseElectrodePair(tt.pair)
+		if err != nil {
+			t.Fatal(err)
+		}
+		voltage, err := chunks[0].Voltage(pair)
+		if err != nil {
+			t.Fatalf("Voltage(%s) error = %v", pair, err)
+		}
+		if voltage.Metadata.Channel != pair.String() || voltage.Metadata.Unit != UnitVolt {
+			t.Errorf("Voltage(%s) metadata = %+v", pair, voltage.Metadata)
+		}
+		for i, want := range tt.want {
+			if diff := voltage.Values[i] - want; diff > 1e-12 || diff < -1e-12 {
+				t.Errorf("Voltage(%s)[%d] = %v, want %v", pair, i, voltage.Values[i], want)
+			}
+		}
+	}
+
+	if _, err := (ElectrodeSignals{ElectrodeWorking: chunks[0][ElectrodeWorking]}).Voltage(ElectrodePair{ElectrodeWorking, ElectrodeReference}); err == nil {
+		t.Errorf("expected error for missing reference potential")
+	}
+
+	single := writeTestFile(t, "single.csv", "timestamp,time_offset,value\n2024-01-01T00:00:00Z,0,1\n")
+	if _, err := loader.LoadElectrodeSignalsFromCSV(single, 4); err == nil {
+		t.Errorf("expected error for file without electrode columns")
+	}
+}
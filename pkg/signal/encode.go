@@ -0,0 +1,332 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// encoderFlushSize is the buffered output after which the encoder writes to
+// the underlying writer, so large spectra never sit in memory as a whole
+const encoderFlushSize = 32 * 1024
+
+// EncodeOptions selects the layout, precision and fields of encoded spectra.
+// The zero value writes the same JSON as json.Marshal.
+type EncodeOptions struct {
+	Flat               bool // Parallel "impedance_real" and "impedance_imag" arrays instead of one object per point
+	Precision          int  // Significant digits of the spectrum arrays; 0 = shortest exact representation
+	OmitMagnitudePhase bool // Leave out magnitude and phase, which decoders derive from the impedance
+	CompactFrequencies bool // Replace evenly spaced frequencies by "frequency_start" and "frequency_step"
+	Float32            bool // Write every value in the shortest form that round-trips through float32
+	Summary            bool // Add the BatchSummary to batches without one, before their spectra
+}
+
+// ImpedanceEncoder writes impedance data as JSON directly to a writer. Numbers
+// are formatted into one reused buffer instead of building the document through
+// reflection and a map per point. With the default options the output is
+// byte-identical to json.Marshal.
+type ImpedanceEncoder struct {
+	w       io.Writer
+	buf     []byte
+	options EncodeOptions
+	err     error
+}
+
+// NewImpedanceEncoder creates an encoder writing to w
+func NewImpedanceEncoder(w io.Writer) *ImpedanceEncoder {
+	return &ImpedanceEncoder{w: w}
+}
+
+// SetOptions selects the layout, precision and fields of the following encodes
+func (e *ImpedanceEncoder) SetOptions(options EncodeOptions) {
+	e.options = options
+}
+
+// Encode writes one spectrum
+func (e *ImpedanceEncoder) Encode(data ImpedanceData) error {
+	e.err = nil
+	e.spectrum(data)
+	return e.flush(true)
+}
+
+// EncodeBatch writes a batch, streaming its spectra one after another
+func (e *ImpedanceEncoder) EncodeBatch(batch ImpedanceBatch) error {
+	e.err = nil
+	e.buf = append(e.buf, `{"batch_id":`...)
+	e.value(batch.BatchID)
+	e.buf = append(e.buf, `,"timestamp":`...)
+	e.timestamp(batch.Timestamp)
+	if batch.Schema != "" {
+		e.buf = append(e.buf, `,"schema":`...)
+		e.value(batch.Schema)
+	}
+	if len(batch.Frequencies) > 0 {
+		e.buf = append(e.buf, `,"frequencies":`...)
+		e.floats(batch.Frequencies)
+	}
+	summary := batch.Summary
+	if summary == nil && e.options.Summary {
+		summary = batch.Summarize()
+	}
+	if summary != nil {
+		e.buf = append(e.buf, `,"summary":`...)
+		e.value(summary)
+	}
+	e.buf = append(e.buf, `,"spectra":`...)
+	if batch.Spectra == nil {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.buf = append(e.buf, '[')
+		for i, spectrum := range batch.Spectra {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = append(e.buf, `{"impedance_data":`...)
+			e.spectrum(spectrum.ImpedanceData)
+			e.buf = append(e.buf, `,"iteration":`...)
+			e.buf = strconv.AppendInt(e.buf, int64(spectrum.Iteration), 10)
+			e.buf = append(e.buf, '}')
+			if e.flush(false) != nil {
+				return e.err
+			}
+		}
+		e.buf = append(e.buf, ']')
+	}
+	e.buf = append(e.buf, '}')
+	return e.flush(true)
+}
+
+// spectrum appends one ImpedanceData object in the field order of its MarshalJSON
+func (e *ImpedanceEncoder) spectrum(data ImpedanceData) {
+	if e.options.Flat {
+		e.buf = append(e.buf, `{"impedance_real":`...)
+		e.complexParts(data.Impedance, func(z complex128) float64 { return real(z) })
+		e.buf = append(e.buf, `,"impedance_imag":`...)
+		e.complexParts(data.Impedance, func(z complex128) float64 { return imag(z) })
+	} else {
+		e.buf = append(e.buf, `{"impedance":[`...)
+		for i, z := range data.Impedance {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = append(e.buf, `{"imag":`...)
+			e.float(imag(z))
+			e.buf = append(e.buf, `,"real":`...)
+			e.float(real(z))
+			e.buf = append(e.buf, '}')
+			if len(e.buf) >= encoderFlushSize {
+				e.flush(false)
+			}
+		}
+		e.buf = append(e.buf, ']')
+	}
+
+	if data.ID != "" {
+		e.buf = append(e.buf, `,"id":`...)
+		e.value(data.ID)
+	}
+	if data.Sequence != 0 {
+		e.buf = append(e.buf, `,"sequence":`...)
+		e.buf = strconv.AppendUint(e.buf, data.Sequence, 10)
+	}
+	e.buf = append(e.buf, `,"timestamp":`...)
+	e.timestamp(data.Timestamp)
+	if start, step, ok := e.uniformGrid(data.Frequencies); ok {
+		e.buf = append(e.buf, `,"frequency_start":`...)
+		e.exact(start)
+		e.buf = append(e.buf, `,"frequency_step":`...)
+		e.exact(step) // Unrounded, decoders multiply it up to the last bin
+	} else if len(data.Frequencies) > 0 {
+		e.buf = append(e.buf, `,"frequencies":`...)
+		e.floats(data.Frequencies)
+	}
+	if !e.options.OmitMagnitudePhase {
+		e.buf = append(e.buf, `,"magnitude":`...)
+		e.floats(data.Magnitude)
+		e.buf = append(e.buf, `,"phase":`...)
+		e.floats(data.Phase)
+	}
+	if !data.Metadata.IsZero() {
+		e.buf = append(e.buf, `,"metadata":`...)
+		e.value(data.Metadata)
+	}
+	if data.Quality != nil {
+		e.buf = append(e.buf, `,"quality":`...)
+		e.value(data.Quality)
+	}
+	if data.QualityFlags != 0 {
+		e.buf = append(e.buf, `,"quality_flags":`...)
+		e.buf = strconv.AppendUint(e.buf, uint64(data.QualityFlags), 10)
+	}
+	if data.Stationarity != nil {
+		e.buf = append(e.buf, `,"stationarity":`...)
+		e.value(data.Stationarity)
+	}
+	if data.Features != nil {
+		e.buf = append(e.buf, `,"features":`...)
+		e.value(data.Features)
+	}
+	if data.Prediction != nil {
+		e.buf = append(e.buf, `,"prediction":`...)
+		e.value(data.Prediction)
+	}
+	e.buf = append(e.buf, '}')
+}
+
+// uniformGrid returns the start and step of evenly spaced frequencies when
+// CompactFrequencies is enabled
+func (e *ImpedanceEncoder) uniformGrid(frequencies []float64) (float64, float64, bool) {
+	if !e.options.CompactFrequencies || len(frequencies) < 2 {
+		return 0, 0, false
+	}
+	start, step := frequencies[0], frequencies[1]-frequencies[0]
+	if step <= 0 {
+		return 0, 0, false
+	}
+	tolerance := 1e-9 * math.Max(math.Abs(start), math.Abs(frequencies[len(frequencies)-1]))
+	for i, f := range frequencies {
+		if math.Abs(f-(start+float64(i)*step)) > tolerance {
+			return 0, 0, false
+		}
+	}
+	return start, step, true
+}
+
+// complexParts appends one part of every impedance value as an array
+func (e *ImpedanceEncoder) complexParts(values []complex128, part func(complex128) float64) {
+	e.buf = append(e.buf, '[')
+	for i, z := range values {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.float(part(z))
+		if len(e.buf) >= encoderFlushSize {
+			e.flush(false)
+		}
+	}
+	e.buf = append(e.buf, ']')
+}
+
+// floats appends a float array, or null for a nil slice like encoding/json
+func (e *ImpedanceEncoder) floats(values []float64) {
+	if values == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.buf = append(e.buf, '[')
+	for i, v := range values {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.float(v)
+		if len(e.buf) >= encoderFlushSize {
+			e.flush(false)
+		}
+	}
+	e.buf = append(e.buf, ']')
+}
+
+// float appends v rounded to the configured precision
+func (e *ImpedanceEncoder) float(v float64) {
+	if e.options.Precision > 0 {
+		v = roundSignificant(v, e.options.Precision)
+	}
+	e.exact(v)
+}
+
+// exact appends v formatted exactly like encoding/json
+func (e *ImpedanceEncoder) exact(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		if e.err == nil {
+			e.err = config.NewValidationError("Value", fmt.Sprintf("%g cannot be encoded as JSON", v))
+		}
+		e.buf = append(e.buf, '0')
+		return
+	}
+
+	bits := 64
+	if e.options.Float32 {
+		bits = 32
+		if v = float64(float32(v)); math.IsInf(v, 0) {
+			if e.err == nil {
+				e.err = config.NewValidationError("Value", "value exceeds the float32 range")
+			}
+			e.buf = append(e.buf, '0')
+			return
+		}
+	}
+
+	format := byte('f')
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	e.buf = strconv.AppendFloat(e.buf, v, format, -1, bits)
+	if format == 'e' {
+		// Shorten e-09 to e-9 as encoding/json does
+		n := len(e.buf)
+		if n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+}
+
+// roundSignificant rounds v to the given number of significant decimal
+// digits, so its shortest representation has at most that many. Values whose
+// scaling power of ten is not exact in float64 are returned unchanged.
+func roundSignificant(v float64, digits int) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	exponent := digits - 1 - int(math.Floor(math.Log10(math.Abs(v))))
+	switch {
+	case exponent >= 0 && exponent <= 22:
+		scale := math.Pow10(exponent)
+		return math.Round(v*scale) / scale
+	case exponent < 0 && exponent >= -22:
+		scale := math.Pow10(-exponent)
+		return math.Round(v/scale) * scale
+	}
+	return v
+}
+
+// timestamp appends t in the RFC 3339 form of time.Time.MarshalJSON
+func (e *ImpedanceEncoder) timestamp(t time.Time) {
+	e.buf = append(e.buf, '"')
+	e.buf = t.AppendFormat(e.buf, time.RFC3339Nano)
+	e.buf = append(e.buf, '"')
+}
+
+// value appends a small value such as a string or the metadata through encoding/json
+func (e *ImpedanceEncoder) value(v interface{}) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return
+	}
+	e.buf = append(e.buf, encoded...)
+}
+
+// flush writes the buffered output once it is large enough, or always when
+// final is set. Nothing is written after an error.
+func (e *ImpedanceEncoder) flush(final bool) error {
+	if e.err != nil {
+		e.buf = e.buf[:0]
+		return e.err
+	}
+	if !final && len(e.buf) < encoderFlushSize {
+		return nil
+	}
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.err = err
+	}
+	e.buf = e.buf[:0]
+	return e.err
+}
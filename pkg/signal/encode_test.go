@@ -0,0 +1,245 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reflectiveJSON encodes data the way MarshalJSON did before ImpedanceEncoder,
+// through encoding/json and one map per impedance point
+func reflectiveJSON(t *testing.T, data ImpedanceData) []byte {
+	t.Helper()
+	type Alias ImpedanceData
+	points := make([]map[string]float64, len(data.Impedance))
+	for i, z := range data.Impedance {
+		points[i] = map[string]float64{"real": real(z), "imag": imag(z)}
+	}
+	encoded, err := json.Marshal(&struct {
+		Impedance []map[string]float64 `json:"impedance"`
+		*Alias
+	}{Impedance: points, Alias: (*Alias)(&data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+// randomSpectrum returns a spectrum with values across many decades
+func randomSpectrum(r *rand.Rand, n int) ImpedanceData {
+	data := ImpedanceData{
+		Identity:    NewIdentity(),
+		Timestamp:   time.Date(2024, 5, 6, 7, 8, 9, r.Intn(1e9), time.UTC),
+		Impedance:   make([]complex128, n),
+		Frequencies: make([]float64, n),
+	}
+	for i := range data.Impedance {
+		scale := math.Pow(10, float64(r.Intn(50)-25))
+		data.Impedance[i] = complex(scale*r.NormFloat64(), -scale*r.NormFloat64())
+		data.Frequencies[i] = math.Pow(10, 6*r.Float64()-2)
+	}
+	data.Magnitude, data.Phase = data.CalculateMagnitudePhase()
+	return data
+}
+
+func TestImpedanceEncoder_MatchesEncodingJSON(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	slope := 0.5
+	full := randomSpectrum(r, 20)
+	full.Metadata = Metadata{Unit: UnitOhm, Channel: "ch<1>", Labels: map[string]string{"cell": "a&b"}}
+	full.Quality = &ChunkQuality{}
+	full.QualityFlags = FlagLowSNR | FlagClipped
+	full.Features = &SpectrumFeatures{HighFrequencyIntercept: 10, WarburgSlope: &slope}
+	full.Prediction = &Prediction{Model: "model.onnx", Scores: []float64{0.1, 0.9}}
+
+	tests := []struct {
+		name string
+		data ImpedanceData
+	}{
+		{name: "empty", data: ImpedanceData{Timestamp: time.Unix(0, 0).UTC()}},
+		{name: "nil magnitude", data: ImpedanceData{Impedance: []complex128{1 + 2i}, Frequencies: []float64{1}}},
+		{name: "all fields", data: full},
+		{name: "large", data: randomSpectrum(r, 5000)},
+		{name: "tiny and huge values", data: ImpedanceData{
+			Impedance: []complex128{complex(1e-7, -1e21), complex(1.5e-300, 123456789), complex(0, math.Copysign(0, -1))},
+			Magnitude: []float64{1e20, 1e-6, 9.999999e-7},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewImpedanceEncoder(&buf).Encode(tt.data); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if want := reflectiveJSON(t, tt.data); !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("Encode() =\n%.300s\nwant\n%.300s", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestImpedanceEncoder_Batch(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	batch := ImpedanceBatch{BatchID: "batch_1_3", Timestamp: time.Unix(1700000000, 0).UTC()}
+	for i := 0; i < 3; i++ {
+		batch.Spectra = append(batch.Spectra, ImpedanceDataWithIteration{ImpedanceData: randomSpectrum(r, 2000), Iteration: i + 1})
+	}
+
+	for _, b := range []ImpedanceBatch{batch, batch.CompactGrid(), {BatchID: "empty"}} {
+		var buf bytes.Buffer
+		if err := NewImpedanceEncoder(&buf).EncodeBatch(b); err != nil {
+			t.Fatalf("EncodeBatch() error = %v", err)
+		}
+		want, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("EncodeBatch(%s) differs from json.Marshal", b.BatchID)
+		}
+	}
+}
+
+func TestImpedanceEncoder_FlatRoundTrip(t *testing.T) {
+	data := randomSpectrum(rand.New(rand.NewSource(3)), 100)
+
+	var buf bytes.Buffer
+	encoder := NewImpedanceEncoder(&buf)
+	encoder.SetOptions(EncodeOptions{Flat: true})
+	if err := encoder.Encode(data); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.Contains(buf.String(), `"impedance":`) || !strings.Contains(buf.String(), `"impedance_real":[`) {
+		t.Fatalf("flat layout = %.200s", buf.String())
+	}
+
+	var decoded ImpedanceData
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.ID != data.ID || len(decoded.Impedance) != len(data.Impedance) {
+		t.Fatalf("decoded %s with %d points, want %s with %d", decoded.ID, len(decoded.Impedance), data.ID, len(data.Impedance))
+	}
+	for i, z := range data.Impedance {
+		if decoded.Impedance[i] != z {
+			t.Fatalf("Impedance[%d] = %v, want %v", i, decoded.Impedance[i], z)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"impedance_real":[1,2],"impedance_imag":[3]}`), &decoded); err == nil {
+		t.Error("Unmarshal() accepted flat arrays of different length")
+	}
+}
+
+func TestImpedanceEncoder_Options(t *testing.T) {
+	fft := ImpedanceData{
+		Timestamp:   time.Unix(0, 0).UTC(),
+		Impedance:   []complex128{complex(10.123456, -math.Pi), complex(9.87654321e-9, 1234567.89), 0},
+		Frequencies: []float64{0, 1.0 / 3, 2.0 / 3},
+	}
+	fft.Magnitude, fft.Phase = fft.CalculateMagnitudePhase()
+	lockIn := fft
+	lockIn.Frequencies = []float64{1, 10, 100}
+
+	tests := []struct {
+		name    string
+		data    ImpedanceData
+		options EncodeOptions
+		want    string
+	}{
+		{
+			name:    "precision",
+			data:    ImpedanceData{Timestamp: fft.Timestamp, Impedance: fft.Impedance[:2], Frequencies: []float64{1.0 / 3, 1e6 / 7}},
+			options: EncodeOptions{Precision: 4, OmitMagnitudePhase: true},
+			want:    `{"impedance":[{"imag":-3.142,"real":10.12},{"imag":1235000,"real":9.877e-9}],"timestamp":"1970-01-01T00:00:00Z","frequencies":[0.3333,142900]}`,
+		},
+		{
+			name:    "compact uniform grid",
+			data:    fft,
+			options: EncodeOptions{Flat: true, Precision: 3, OmitMagnitudePhase: true, CompactFrequencies: true},
+			want:    `{"impedance_real":[10.1,9.88e-9,0],"impedance_imag":[-3.14,1230000,0],"timestamp":"1970-01-01T00:00:00Z","frequency_start":0,"frequency_step":0.3333333333333333}`,
+		},
+		{
+			name:    "uneven grid kept",
+			data:    lockIn,
+			options: EncodeOptions{Flat: true, Precision: 2, OmitMagnitudePhase: true, CompactFrequencies: true},
+			want:    `{"impedance_real":[10,9.9e-9,0],"impedance_imag":[-3.1,1200000,0],"timestamp":"1970-01-01T00:00:00Z","frequencies":[1,10,100]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewImpedanceEncoder(&buf)
+			encoder.SetOptions(tt.options)
+			if err := encoder.Encode(tt.data); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Encode() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+
+			// Compacted grids and omitted magnitude and phase are restored on decoding
+			var decoded ImpedanceData
+			if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if len(decoded.Frequencies) != len(tt.data.Frequencies) || len(decoded.Magnitude) != len(tt.data.Impedance) {
+				t.Fatalf("decoded %d frequencies and %d magnitudes, want %d and %d",
+					len(decoded.Frequencies), len(decoded.Magnitude), len(tt.data.Frequencies), len(tt.data.Impedance))
+			}
+			for i, f := range tt.data.Frequencies {
+				if math.Abs(decoded.Frequencies[i]-f) > 1e-3*math.Max(f, 1) {
+					t.Errorf("frequency %d = %g, want %g", i, decoded.Frequencies[i], f)
+				}
+			}
+		})
+	}
+}
+
+func TestImpedanceEncoder_RejectsNonFinite(t *testing.T) {
+	var buf bytes.Buffer
+	data := ImpedanceData{Impedance: []complex128{complex(math.NaN(), 0)}}
+	if err := NewImpedanceEncoder(&buf).Encode(data); err == nil {
+		t.Error("Encode() accepted NaN")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode() wrote %q after an error", buf.String())
+	}
+}
+
+func BenchmarkImpedanceDataJSON(b *testing.B) {
+	data := randomSpectrum(rand.New(rand.NewSource(4)), 100000)
+
+	b.Run("reflective", func(b *testing.B) {
+		b.ReportAllocs()
+		type Alias ImpedanceData
+		for i := 0; i < b.N; i++ {
+			points := make([]map[string]float64, len(data.Impedance))
+			for j, z := range data.Impedance {
+				points[j] = map[string]float64{"real": real(z), "imag": imag(z)}
+			}
+			if _, err := json.Marshal(&struct {
+				Impedance []map[string]float64 `json:"impedance"`
+				*Alias
+			}{Impedance: points, Alias: (*Alias)(&data)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf bytes.Buffer
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := NewImpedanceEncoder(&buf).Encode(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
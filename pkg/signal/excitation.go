@@ -0,0 +1,139 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Sweep selects how the instantaneous frequency of a chirp changes
+type Sweep string
+
+const (
+	SweepLinear      Sweep = "linear"      // Constant rate in Hz/s; equal energy per Hz
+	SweepLogarithmic Sweep = "logarithmic" // Constant rate in decades/s; equal energy per decade
+)
+
+// ChirpOptions configures a swept sine excitation
+type ChirpOptions struct {
+	StartFrequency float64 // Hz, > 0 for logarithmic sweeps
+	EndFrequency   float64 // Hz, below Nyquist
+	Duration       time.Duration
+	Amplitude      float64 // Peak amplitude
+	Sweep          Sweep
+	SampleRate     float64
+}
+
+// Chirp generates a swept sine from StartFrequency to EndFrequency.
+//
+// Frequency coverage: the excitation energy lies between the start and end
+// frequencies; a linear sweep spreads it evenly per Hz and favours high
+// frequencies on a log axis, a logarithmic sweep spreads it evenly per decade
+// as usual for EIS. The spectrum is not flat near the band edges, so analyse
+// only frequencies well inside [StartFrequency, EndFrequency], and make the
+// sweep at least a few periods of StartFrequency long.
+func Chirp(options ChirpOptions) (Signal, error) {
+	if options.SampleRate <= 0 {
+		return Signal{}, config.ErrInvalidSampleRate
+	}
+	if options.Duration <= 0 {
+		return Signal{}, config.NewValidationError("Duration", "chirp duration must be greater than 0")
+	}
+	if options.StartFrequency < 0 || options.EndFrequency <= 0 || options.EndFrequency > options.SampleRate/2 {
+		return Signal{}, config.NewValidationError("EndFrequency",
+			fmt.Sprintf("chirp frequencies must lie in [0, %g] Hz", options.SampleRate/2))
+	}
+
+	n := int(math.Round(options.Duration.Seconds() * options.SampleRate))
+	duration := options.Duration.Seconds()
+	f0, f1 := options.StartFrequency, options.EndFrequency
+	values := make([]float64, n)
+
+	switch options.Sweep {
+	case SweepLinear, "":
+		rate := (f1 - f0) / duration
+		for i := range values {
+			t := float64(i) / options.SampleRate
+			values[i] = options.Amplitude * math.Sin(2*math.Pi*(f0*t+rate*t*t/2))
+		}
+	case SweepLogarithmic:
+		if f0 <= 0 || f0 == f1 {
+			return Signal{}, config.NewValidationError("StartFrequency", "logarithmic sweeps need distinct frequencies greater than 0")
+		}
+		k := math.Log(f1/f0) / duration
+		for i := range values {
+			t := float64(i) / options.SampleRate
+			values[i] = options.Amplitude * math.Sin(2*math.Pi*f0*(math.Exp(k*t)-1)/k)
+		}
+	default:
+		return Signal{}, config.NewValidationError("Sweep", fmt.Sprintf("unknown sweep '%s'", options.Sweep))
+	}
+
+	return Signal{Values: values, SampleRate: options.SampleRate, Metadata: Metadata{Unit: UnitVolt}}, nil
+}
+
+// prbsTaps are feedback taps of maximum-length LFSRs by register order
+var prbsTaps = map[int][]int{
+	5: {5, 3}, 6: {6, 5}, 7: {7, 6}, 8: {8, 6, 5, 4}, 9: {9, 5}, 10: {10, 7},
+	11: {11, 9}, 12: {12, 11, 10, 4}, 13: {13, 12, 11, 8}, 14: {14, 13, 12, 2},
+	15: {15, 14}, 16: {16, 15, 13, 4},
+}
+
+// PRBSOptions configures a pseudo-random binary sequence excitation
+type PRBSOptions struct {
+	Order      int     // LFSR register length, 5 to 16; the sequence repeats after 2^Order - 1 bits
+	BitRate    float64 // Bits per second, at most SampleRate
+	Periods    int     // Number of sequence repetitions; at least 1
+	Amplitude  float64 // Output is ±Amplitude
+	SampleRate float64
+}
+
+// PRBS generates a maximum-length pseudo-random binary sequence.
+//
+// Frequency coverage: one period of 2^Order - 1 bits at BitRate has lines
+// spaced BitRate / (2^Order - 1) Hz apart, from that spacing up to about
+// BitRate / 3, where the sinc-shaped envelope has fallen by ~1.6 dB; it
+// reaches zero at BitRate. Analyse whole periods to see discrete lines.
+// The signal has the lowest crest factor (1) of all broadband excitations.
+func PRBS(options PRBSOptions) (Signal, error) {
+	taps, ok := prbsTaps[options.Order]
+	if !ok {
+		return Signal{}, config.NewValidationError("Order", "PRBS order must be between 5 and 16")
+	}
+	if options.SampleRate <= 0 {
+		return Signal{}, config.ErrInvalidSampleRate
+	}
+	if options.BitRate <= 0 || options.BitRate > options.SampleRate {
+		return Signal{}, config.NewValidationError("BitRate", "PRBS bit rate must be in (0, sample rate]")
+	}
+	periods := options.Periods
+	if periods < 1 {
+		periods = 1
+	}
+
+	length := 1<<options.Order - 1
+	bits := make([]float64, length)
+	register := uint32(1)
+	for i := range bits {
+		bits[i] = options.Amplitude
+		if register&1 == 0 {
+			bits[i] = -options.Amplitude
+		}
+		var feedback uint32
+		for _, tap := range taps {
+			feedback ^= register >> (options.Order - tap) & 1
+		}
+		register = register>>1 | feedback<<(options.Order-1)
+	}
+
+	samplesPerBit := options.SampleRate / options.BitRate
+	n := int(math.Round(float64(length*periods) * samplesPerBit))
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = bits[int(float64(i)/samplesPerBit)%length]
+	}
+
+	return Signal{Values: values, SampleRate: options.SampleRate, Metadata: Metadata{Unit: UnitVolt}}, nil
+}
@@ -0,0 +1,67 @@
+package signal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPRBS_MaximumLength(t *testing.T) {
+	for order := range prbsTaps {
+		sig, err := PRBS(PRBSOptions{Order: order, BitRate: 1, Periods: 2, Amplitude: 1, SampleRate: 1})
+		if err != nil {
+			t.Fatalf("PRBS(order %d) error = %v", order, err)
+		}
+
+		length := 1<<order - 1
+		if len(sig.Values) != 2*length {
+			t.Fatalf("order %d: %d samples, want %d", order, len(sig.Values), 2*length)
+		}
+
+		// An m-sequence has one more high than low bit per period
+		var high int
+		for _, v := range sig.Values[:length] {
+			if v > 0 {
+				high++
+			}
+		}
+		if high != 1<<(order-1) {
+			t.Errorf("order %d: %d high bits of %d, not a maximum-length sequence", order, high, length)
+		}
+	}
+
+	if _, err := PRBS(PRBSOptions{Order: 4, BitRate: 1, SampleRate: 1}); err == nil {
+		t.Errorf("expected error for unsupported order")
+	}
+}
+
+func TestChirp_Sweeps(t *testing.T) {
+	tests := []struct {
+		sweep Sweep
+		want  float64 // Cycles between 10 and 100 Hz in one second
+	}{
+		{sweep: SweepLinear, want: 55},
+		{sweep: SweepLogarithmic, want: 90 / math.Log(10)},
+	}
+
+	for _, tt := range tests {
+		sig, err := Chirp(ChirpOptions{StartFrequency: 10, EndFrequency: 100, Duration: time.Second, Amplitude: 1, Sweep: tt.sweep, SampleRate: 10000})
+		if err != nil {
+			t.Fatalf("Chirp(%s) error = %v", tt.sweep, err)
+		}
+
+		crossings := 0
+		for i := 1; i < len(sig.Values); i++ {
+			if (sig.Values[i-1] < 0) != (sig.Values[i] < 0) {
+				crossings++
+			}
+		}
+		if cycles := float64(crossings) / 2; math.Abs(cycles-tt.want) > 1 {
+			t.Errorf("Chirp(%s) has %.1f cycles, want %.1f", tt.sweep, cycles, tt.want)
+		}
+	}
+
+	if _, err := Chirp(ChirpOptions{StartFrequency: 10, EndFrequency: 600, Duration: time.Second, SampleRate: 1000}); err == nil {
+		t.Errorf("expected error for end frequency above Nyquist")
+	}
+}
@@ -0,0 +1,24 @@
+package signal
+
+// SpectrumFeatures are scalar features of an impedance spectrum read off its
+// Nyquist plot without fitting an equivalent circuit. Resistances are in Ω.
+type SpectrumFeatures struct {
+	HighFrequencyIntercept  float64  `json:"hf_intercept"`             // Re Z where the arc starts on the real axis (≈ R_s)
+	LowFrequencyIntercept   float64  `json:"lf_intercept"`             // Re Z where the arc ends (≈ R_s + R_ct)
+	SemicircleDiameter      float64  `json:"semicircle_diameter"`      // LF minus HF intercept (≈ R_ct)
+	CharacteristicFrequency float64  `json:"characteristic_frequency"` // Frequency in Hz at the arc apex, 1/(2π R_ct C)
+	WarburgSlope            *float64 `json:"warburg_slope,omitempty"`  // d(-Im Z)/d(Re Z) of the low-frequency tail; 1 for ideal diffusion, absent without a tail
+}
+
+// Vector returns the features in a fixed order for model input; a missing
+// Warburg slope is 0
+func (f SpectrumFeatures) Vector() []float64 {
+	slope := 0.0
+	if f.WarburgSlope != nil {
+		slope = *f.WarburgSlope
+	}
+	return []float64{f.HighFrequencyIntercept, f.LowFrequencyIntercept, f.SemicircleDiameter, f.CharacteristicFrequency, slope}
+}
+
+// FeatureNames names the elements of SpectrumFeatures.Vector
+var FeatureNames = []string{"hf_intercept", "lf_intercept", "semicircle_diameter", "characteristic_frequency", "warburg_slope"}
@@ -0,0 +1,67 @@
+package signal
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// QualityFlags is a bitfield of the problems found with a spectrum or one of
+// its points. It is written as the same integer in every output format, so
+// consumers test bits instead of parsing reports.
+type QualityFlags uint32
+
+const (
+	// FlagKKFail marks data inconsistent with the Kramers-Kronig relations
+	FlagKKFail QualityFlags = 1 << iota
+	// FlagLowSNR marks a chunk whose voltage or current SNR is below the limit
+	FlagLowSNR
+	// FlagNonlinearity marks an excitation exceeding the linearity limit
+	FlagNonlinearity
+	// FlagRepairedSamples marks a chunk containing samples interpolated by
+	// the repair parse mode
+	FlagRepairedSamples
+	// FlagClipped marks a chunk with flattened peaks in either channel
+	FlagClipped
+	// FlagAliasing marks a chunk with power near the Nyquist frequency above
+	// the alias limit
+	FlagAliasing
+	// FlagNonStationary marks a chunk during which the system changed
+	FlagNonStationary
+	// FlagGap marks a chunk cut short by an acquisition dropout
+	FlagGap
+)
+
+// AllQualityFlags has every defined flag set
+const AllQualityFlags = FlagGap<<1 - 1
+
+// qualityFlagNames names the flags in bit order
+var qualityFlagNames = []string{"kk_fail", "low_snr", "nonlinearity", "repaired_samples", "clipped", "aliasing", "non_stationary", "gap"}
+
+// Has reports whether all flags of other are set
+func (f QualityFlags) Has(other QualityFlags) bool {
+	return f&other == other
+}
+
+// Names returns the names of the set flags in bit order
+func (f QualityFlags) Names() []string {
+	names := make([]string, 0, bits.OnesCount32(uint32(f)))
+	for i, name := range qualityFlagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// String joins the flag names with '|', "none" if no flag is set
+func (f QualityFlags) String() string {
+	if f == 0 {
+		return "none"
+	}
+	names := f.Names()
+	if unknown := f &^ (1<<len(qualityFlagNames) - 1); unknown != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(unknown)))
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,27 @@
+package signal
+
+import "testing"
+
+func TestQualityFlags_String(t *testing.T) {
+	tests := []struct {
+		flags QualityFlags
+		want  string
+	}{
+		{flags: 0, want: "none"},
+		{flags: FlagClipped, want: "clipped"},
+		{flags: FlagNonStationary | FlagLowSNR | FlagKKFail, want: "kk_fail|low_snr|non_stationary"},
+		{flags: FlagRepairedSamples | 1<<20, want: "repaired_samples|0x100000"},
+	}
+	for _, tt := range tests {
+		if got := tt.flags.String(); got != tt.want {
+			t.Errorf("QualityFlags(%d).String() = %q, want %q", uint32(tt.flags), got, tt.want)
+		}
+	}
+
+	if !(FlagLowSNR | FlagClipped).Has(FlagClipped) || FlagClipped.Has(FlagLowSNR|FlagClipped) {
+		t.Error("Has() must require every flag of its argument")
+	}
+	if names := AllQualityFlags.Names(); len(names) != 8 || names[7] != "gap" {
+		t.Errorf("AllQualityFlags.Names() = %v, want all 8 flags", names)
+	}
+}
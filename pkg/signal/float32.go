@@ -0,0 +1,118 @@
+package signal
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// ImpedanceData32 is the float32 wire form of ImpedanceData. It halves the
+// size of binary payloads and stored spectra where about 7 significant digits
+// suffice; magnitude and phase are derived again by ToFloat64.
+type ImpedanceData32 struct {
+	Identity
+	Timestamp    time.Time
+	Impedance    []complex64
+	Frequencies  []float32
+	Metadata     Metadata
+	Quality      *ChunkQuality
+	QualityFlags QualityFlags
+	Stationarity *Stationarity
+	Features     *SpectrumFeatures
+	Prediction   *Prediction
+}
+
+// ToFloat32 converts the spectrum to its float32 wire form. Values beyond the
+// float32 range become infinite, see Float32Error.
+func (id ImpedanceData) ToFloat32() ImpedanceData32 {
+	result := ImpedanceData32{
+		Identity:     id.Identity,
+		Timestamp:    id.Timestamp,
+		Impedance:    ComplexToFloat32(id.Impedance),
+		Metadata:     id.Metadata,
+		Quality:      id.Quality,
+		QualityFlags: id.QualityFlags,
+		Stationarity: id.Stationarity,
+		Features:     id.Features,
+		Prediction:   id.Prediction,
+	}
+	if id.Frequencies != nil {
+		result.Frequencies = FloatsToFloat32(id.Frequencies)
+	}
+	return result
+}
+
+// ToFloat64 restores the spectrum including magnitude and phase
+func (id ImpedanceData32) ToFloat64() ImpedanceData {
+	result := ImpedanceData{
+		Identity:     id.Identity,
+		Timestamp:    id.Timestamp,
+		Impedance:    ComplexToFloat64(id.Impedance),
+		Metadata:     id.Metadata,
+		Quality:      id.Quality,
+		QualityFlags: id.QualityFlags,
+		Stationarity: id.Stationarity,
+		Features:     id.Features,
+		Prediction:   id.Prediction,
+	}
+	if id.Frequencies != nil {
+		result.Frequencies = FloatsToFloat64(id.Frequencies)
+	}
+	result.Magnitude, result.Phase = result.CalculateMagnitudePhase()
+	return result
+}
+
+// ComplexToFloat32 converts complex values to single precision
+func ComplexToFloat32(values []complex128) []complex64 {
+	result := make([]complex64, len(values))
+	for i, v := range values {
+		result[i] = complex64(v)
+	}
+	return result
+}
+
+// ComplexToFloat64 converts complex values back to double precision
+func ComplexToFloat64(values []complex64) []complex128 {
+	result := make([]complex128, len(values))
+	for i, v := range values {
+		result[i] = complex128(v)
+	}
+	return result
+}
+
+// FloatsToFloat32 converts values to single precision
+func FloatsToFloat32(values []float64) []float32 {
+	result := make([]float32, len(values))
+	for i, v := range values {
+		result[i] = float32(v)
+	}
+	return result
+}
+
+// FloatsToFloat64 converts values back to double precision
+func FloatsToFloat64(values []float32) []float64 {
+	result := make([]float64, len(values))
+	for i, v := range values {
+		result[i] = float64(v)
+	}
+	return result
+}
+
+// Float32Error returns the largest relative error float32 conversion causes
+// in the impedance |Z32 - Z| / |Z| and in the frequencies, so callers can
+// check that single precision suffices. It is about 6e-8 for values within
+// the normal float32 range and infinite for values beyond it.
+func Float32Error(data ImpedanceData) (float64, float64) {
+	var impedance, frequency float64
+	for _, z := range data.Impedance {
+		if magnitude := cmplx.Abs(z); magnitude > 0 {
+			impedance = math.Max(impedance, cmplx.Abs(complex128(complex64(z))-z)/magnitude)
+		}
+	}
+	for _, f := range data.Frequencies {
+		if f != 0 {
+			frequency = math.Max(frequency, math.Abs(float64(float32(f))-f)/math.Abs(f))
+		}
+	}
+	return impedance, frequency
+}
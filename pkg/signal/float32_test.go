@@ -0,0 +1,58 @@
+package signal
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestImpedanceData_Float32RoundTrip(t *testing.T) {
+	data := randomSpectrum(rand.New(rand.NewSource(5)), 500)
+	for i := range data.Impedance {
+		// Keep the values within the normal float32 range
+		data.Impedance[i] = complex(math.Mod(real(data.Impedance[i]), 1e30)+1e-30, imag(data.Impedance[i]))
+	}
+	data.Metadata = Metadata{Unit: UnitOhm}
+
+	restored := data.ToFloat32().ToFloat64()
+	if restored.ID != data.ID || restored.Metadata.Unit != UnitOhm || len(restored.Magnitude) != len(data.Impedance) {
+		t.Fatalf("restored %+v, want identity, metadata and magnitude of the original", restored.Identity)
+	}
+
+	impedanceError, frequencyError := Float32Error(data)
+	const epsilon = 1.0 / (1 << 23) // float32 machine epsilon
+	if impedanceError > epsilon || frequencyError > epsilon {
+		t.Errorf("Float32Error() = %g, %g, want at most %g", impedanceError, frequencyError, epsilon)
+	}
+	for i, f := range data.Frequencies {
+		if math.Abs(restored.Frequencies[i]-f) > frequencyError*f {
+			t.Fatalf("frequency %d = %g, want %g within %g", i, restored.Frequencies[i], f, frequencyError)
+		}
+	}
+
+	overflow := ImpedanceData{Impedance: []complex128{complex(1e39, 0)}}
+	if impedanceError, _ := Float32Error(overflow); !math.IsInf(impedanceError, 1) {
+		t.Errorf("Float32Error() = %g for a value beyond float32, want +Inf", impedanceError)
+	}
+}
+
+func TestImpedanceEncoder_Float32(t *testing.T) {
+	data := ImpedanceData{Impedance: []complex128{complex(1.0/3, -2.0/3)}, Frequencies: []float64{math.Pi}}
+
+	var buf bytes.Buffer
+	encoder := NewImpedanceEncoder(&buf)
+	encoder.SetOptions(EncodeOptions{Float32: true, OmitMagnitudePhase: true})
+	if err := encoder.Encode(data); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `{"impedance":[{"imag":-0.6666667,"real":0.33333334}],"timestamp":"0001-01-01T00:00:00Z","frequencies":[3.1415927]}`
+	if buf.String() != want {
+		t.Errorf("Encode() = %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := encoder.Encode(ImpedanceData{Impedance: []complex128{complex(1e39, 0)}}); err == nil {
+		t.Error("Encode() accepted a value beyond the float32 range")
+	}
+}
@@ -0,0 +1,151 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fuzzFile writes fuzz input to a file the loaders can open
+func fuzzFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fuzzLoader returns a loader for one of the parse modes and dialects
+func fuzzLoader(mode, dialect uint8) *CSVDataLoader {
+	modes := []ParseMode{ParseModeDefault, ParseModeStrict, ParseModeLenient, ParseModeRepair}
+	dialects := []CSVDialect{DefaultCSVDialect, {Delimiter: ';', DecimalSeparator: ',', ThousandsSeparator: '.'}}
+	return NewDataLoaderWithOptions(LoaderOptions{
+		Dialect:   dialects[int(dialect)%len(dialects)],
+		ParseMode: modes[int(mode)%len(modes)],
+	}).(*CSVDataLoader)
+}
+
+func FuzzLoadSignalFromCSV(f *testing.F) {
+	f.Add([]byte("timestamp,time_offset,voltage\n2025-07-25T20:00:00Z,0,1.5\n2025-07-25T20:00:00.5Z,0.5,1.6\n"), 2.0, uint8(0), uint8(0))
+	f.Add([]byte("timestamp,time_offset,voltage\nbad,0,NaN\n2025-07-25T20:00:00Z,0,1e308\n"), 4.0, uint8(3), uint8(0))
+	f.Add([]byte("timestamp;time_offset;voltage\n2025-07-25T20:00:00Z;0;1,5\n"), 0.5, uint8(2), uint8(1))
+	f.Add([]byte("\"unterminated\n"), 1000.0, uint8(1), uint8(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, sampleRate float64, mode, dialect uint8) {
+		signals, err := fuzzLoader(mode, dialect).LoadSignalFromCSV(fuzzFile(t, "signal.csv", data), sampleRate)
+		if err != nil {
+			return
+		}
+		for i, sig := range signals {
+			if len(sig.Values) == 0 || len(sig.Values) > int(sampleRate) {
+				t.Fatalf("chunk %d has %d samples at %g Hz", i, len(sig.Values), sampleRate)
+			}
+			for _, v := range sig.Values {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					t.Fatalf("chunk %d contains non-finite value %v", i, v)
+				}
+			}
+		}
+	})
+}
+
+func FuzzLoadImpedanceFromCSV(f *testing.F) {
+	f.Add([]byte("Frequency_Hz,Z_real,Z_imag,Spectrum_Number\n1000,10.5,-1.2,1\n100,12,-4.5,1\n1000,10.6,-1.3,2\n"), uint8(0), uint8(0))
+	f.Add([]byte("freq;re;im\n1000;10,5;-1,2\n"), uint8(2), uint8(1))
+	f.Add([]byte("1000,NaN,Inf,1\n100,1e400,-1,99999999999999999999\n"), uint8(1), uint8(0))
+	f.Add([]byte("# comment\nZ_real,Z_imag,Spectrum_Number,Frequency_Hz\n10,-1,0,1e5\n"), uint8(0), uint8(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, mode, dialect uint8) {
+		path := fuzzFile(t, "impedance.csv", data)
+		spectra, err := fuzzLoader(mode, dialect).LoadImpedanceFromCSV(path)
+		if err == nil {
+			checkFuzzedSpectra(t, spectra)
+		}
+
+		var streamed []ImpedanceDataWithIteration
+		err = fuzzLoader(mode, dialect).StreamImpedanceFromCSV(path, func(spectrum ImpedanceDataWithIteration, progress float64) error {
+			if progress < 0 || progress > 1 {
+				t.Fatalf("progress %g outside [0, 1]", progress)
+			}
+			streamed = append(streamed, spectrum)
+			return nil
+		})
+		if err == nil {
+			checkFuzzedSpectra(t, streamed)
+		}
+	})
+}
+
+// checkFuzzedSpectra verifies the invariants every loaded spectrum must hold
+func checkFuzzedSpectra(t *testing.T, spectra []ImpedanceDataWithIteration) {
+	t.Helper()
+	for _, spectrum := range spectra {
+		data := spectrum.ImpedanceData
+		if len(data.Frequencies) == 0 || len(data.Frequencies) != len(data.Impedance) {
+			t.Fatalf("spectrum %d has %d frequencies and %d impedances", spectrum.Iteration, len(data.Frequencies), len(data.Impedance))
+		}
+		for i, z := range data.Impedance {
+			if math.IsNaN(data.Frequencies[i]) || math.IsInf(data.Frequencies[i], 0) ||
+				math.IsNaN(real(z)) || math.IsNaN(imag(z)) || math.IsInf(real(z), 0) || math.IsInf(imag(z), 0) {
+				t.Fatalf("spectrum %d contains non-finite point %g Hz, %v", spectrum.Iteration, data.Frequencies[i], z)
+			}
+		}
+	}
+}
+
+func FuzzInspectFile(f *testing.F) {
+	f.Add([]byte("timestamp,time_offset,voltage\n2025-07-25T20:00:00Z,0,1.5\n2025-07-25T20:00:00.001Z,0.001,1.6\n"))
+	f.Add([]byte("Frequency_Hz;Z_real;Z_imag\n1000;10,5;-1,2\n"))
+	f.Add([]byte("\t\t\n,,,\n;;\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := SniffCSVDialect(data).Validate(); err != nil {
+			t.Fatalf("sniffed an invalid dialect: %v", err)
+		}
+		InspectFile(fuzzFile(t, "input.csv", data), nil)
+	})
+}
+
+func FuzzLoadElectrodeSignalsFromCSV(f *testing.F) {
+	f.Add([]byte("timestamp,time_offset,we,re,ce\n2025-07-25T20:00:00Z,0,1,0.5,0\n2025-07-25T20:00:00.5Z,0.5,1.1,0.5,0\n"), uint8(0))
+	f.Add([]byte("timestamp,time_offset,we,we\n2025-07-25T20:00:00Z,0,1\n"), uint8(3))
+
+	f.Fuzz(func(t *testing.T, data []byte, mode uint8) {
+		chunks, err := fuzzLoader(mode, 0).LoadElectrodeSignalsFromCSV(fuzzFile(t, "electrodes.csv", data), 2)
+		if err != nil {
+			return
+		}
+		for i, chunk := range chunks {
+			length := -1
+			for electrode, sig := range chunk {
+				if length >= 0 && len(sig.Values) != length {
+					t.Fatalf("chunk %d: %s has %d samples, others %d", i, electrode, len(sig.Values), length)
+				}
+				length = len(sig.Values)
+			}
+		}
+	})
+}
+
+func FuzzLoadWaveformWAV(f *testing.F) {
+	var wav bytes.Buffer
+	samples := []int16{0, 16384, 0, -16384}
+	wav.WriteString("RIFF")
+	binary.Write(&wav, binary.LittleEndian, uint32(36+2*len(samples)))
+	wav.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{uint32(16), uint16(1), uint16(1), uint32(8000), uint32(16000), uint16(2), uint16(16)} {
+		binary.Write(&wav, binary.LittleEndian, field)
+	}
+	wav.WriteString("data")
+	binary.Write(&wav, binary.LittleEndian, uint32(2*len(samples)))
+	binary.Write(&wav, binary.LittleEndian, samples)
+	f.Add(wav.Bytes())
+	f.Add([]byte("RIFF\x00\x00\x00\x00WAVEdata\xff\xff\xff\xff"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		LoadWaveform(fuzzFile(t, "waveform.wav", data), 8000, DefaultCSVDialect)
+	})
+}
@@ -1,106 +1,135 @@
 package signal
 
 import (
-	"math"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/adam/masterapp/pkg/clock"
 	"github.com/adam/masterapp/pkg/config"
 )
 
-// DefaultGenerator implements signal generation for testing and simulation
-type DefaultGenerator struct{}
+// GeneratorOptions configures the simulated cell and measurement noise
+type GeneratorOptions struct {
+	Circuit      CircuitModel // Impedance the current is derived through
+	Excitation   []Tone       // Voltage excitation tones
+	VoltageDC    float64      // DC bias of the voltage in V
+	VoltageNoise float64      // RMS of Gaussian voltage measurement noise in V
+	CurrentNoise float64      // RMS of Gaussian current measurement noise in A
+	Seed         int64        // Noise seed; 0 seeds from the clock
+}
 
-// NewGenerator creates a new signal generator
+// DefaultGeneratorOptions returns the default simulated cell
+func DefaultGeneratorOptions() GeneratorOptions {
+	return GeneratorOptions{
+		Circuit:      DefaultCircuit(),
+		Excitation:   DefaultExcitation(),
+		VoltageDC:    1.0,
+		VoltageNoise: 0.003,
+		CurrentNoise: 0.0002,
+	}
+}
+
+// DefaultGenerator implements signal generation for testing and simulation.
+// The current is derived from the voltage excitation through the circuit
+// model: its line spectrum is multiplied by the admittance 1/Z(f) and
+// transformed back to the time domain, before independent noise is added.
+type DefaultGenerator struct {
+	clock   clock.Clock
+	options GeneratorOptions
+	mu      sync.Mutex
+	rng     *rand.Rand
+}
+
+// NewGenerator creates a new signal generator timestamping with the wall clock
 func NewGenerator() Generator {
-	return &DefaultGenerator{}
+	return NewGeneratorWithClock(clock.NewSystemClock())
 }
 
-// GenerateVoltageSignal generates a realistic voltage signal with sine wave and noise
-func (sg *DefaultGenerator) GenerateVoltageSignal(sampleRate float64, samplesPerSecond int) (Signal, error) {
-	if sampleRate <= 0 {
-		return Signal{}, config.ErrInvalidSampleRate
+// NewGeneratorWithClock creates a new signal generator timestamping with the given clock
+func NewGeneratorWithClock(c clock.Clock) Generator {
+	return NewGeneratorWithOptions(c, DefaultGeneratorOptions())
+}
+
+// NewGeneratorWithOptions creates a signal generator simulating the given cell
+func NewGeneratorWithOptions(c clock.Clock, options GeneratorOptions) Generator {
+	defaults := DefaultGeneratorOptions()
+	if options.Circuit == nil {
+		options.Circuit = defaults.Circuit
 	}
-	
-	if samplesPerSecond <= 0 {
-		return Signal{}, config.NewValidationError("SamplesPerSecond", "samples per second must be greater than 0")
+	if len(options.Excitation) == 0 {
+		options.Excitation = defaults.Excitation
+	}
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &DefaultGenerator{
+		clock:   clock.OrSystem(c),
+		options: options,
+		rng:     rand.New(rand.NewSource(seed)),
 	}
+}
 
-	values := make([]float64, samplesPerSecond)
-	now := time.Now()
-	
-	for i := 0; i < samplesPerSecond; i++ {
-		t := float64(i) / sampleRate
-		
-		// Generate multi-frequency voltage excitation based on impedance_data.csv pattern
-		// This creates a broadband signal that will result in EIS-like frequency response
-		signal := 0.0
-		
-		// Add multiple frequency components with decreasing amplitude (realistic EIS excitation)
-		frequencies := []float64{1, 5, 10, 25, 50, 100, 250, 500}
-		amplitudes := []float64{0.2, 0.15, 0.12, 0.1, 0.08, 0.06, 0.04, 0.02}
-		
-		for j, freq := range frequencies {
-			if j < len(amplitudes) {
-				signal += amplitudes[j] * math.Sin(2*math.Pi*freq*t)
-			}
-		}
-		
-		// Add DC component and small measurement noise
-		values[i] = 1.0 + signal + 0.01*(rand.Float64()-0.5)
+// GenerateVoltageSignal generates the multisine voltage excitation with DC bias and noise
+func (sg *DefaultGenerator) GenerateVoltageSignal(sampleRate float64, samplesPerSecond int) (Signal, error) {
+	if err := validateGeneration(sampleRate, samplesPerSecond); err != nil {
+		return Signal{}, err
 	}
 
+	identity := func(float64) complex128 { return 1 }
+	lines := phasors(sg.options.Excitation, identity)
+	values := synthesize(sg.options.Excitation, lines, sampleRate, samplesPerSecond)
+	sg.addNoise(values, sg.options.VoltageDC, sg.options.VoltageNoise)
+
 	return Signal{
-		Timestamp:  now,
+		Timestamp:  sg.clock.Now(),
 		Values:     values,
 		SampleRate: sampleRate,
+		Metadata:   Metadata{Unit: UnitVolt},
 	}, nil
 }
 
-// GenerateCurrentSignal generates a corresponding current signal with phase shift and noise
+// GenerateCurrentSignal generates the current response of the circuit model
+// to the voltage excitation, with noise
 func (sg *DefaultGenerator) GenerateCurrentSignal(sampleRate float64, samplesPerSecond int) (Signal, error) {
-	if sampleRate <= 0 {
-		return Signal{}, config.ErrInvalidSampleRate
-	}
-	
-	if samplesPerSecond <= 0 {
-		return Signal{}, config.NewValidationError("SamplesPerSecond", "samples per second must be greater than 0")
+	if err := validateGeneration(sampleRate, samplesPerSecond); err != nil {
+		return Signal{}, err
 	}
 
-	values := make([]float64, samplesPerSecond)
-	now := time.Now()
-	
-	for i := 0; i < samplesPerSecond; i++ {
-		t := float64(i) / sampleRate
-		
-		// Generate current response simulating R(RC) electrochemical behavior
-		// Current response has frequency-dependent amplitude and phase based on impedance_data.csv
-		signal := 0.0
-		
-		// Same frequencies as voltage but with impedance-modified amplitude and phase
-		frequencies := []float64{1, 5, 10, 25, 50, 100, 250, 500}
-		voltageAmps := []float64{0.2, 0.15, 0.12, 0.1, 0.08, 0.06, 0.04, 0.02}
-		
-		for j, freq := range frequencies {
-			if j < len(voltageAmps) {
-				// Simulate R(RC) circuit response: |I| = |U|/|Z| and phase shift
-				// Higher frequencies: lower impedance (~10-11 Ω), less phase shift
-				// Lower frequencies: higher impedance (~30 Ω), more phase shift
-				impedanceMagnitude := 10.0 + 20.0/(1.0 + freq/10.0) // Simplified R(RC) model
-				phaseShift := math.Atan(freq/50.0) * 0.5 // Capacitive phase shift
-				
-				currentAmplitude := voltageAmps[j] / impedanceMagnitude
-				signal += currentAmplitude * math.Sin(2*math.Pi*freq*t - phaseShift)
-			}
-		}
-		
-		// Add DC component and measurement noise
-		values[i] = 0.05 + signal + 0.005*(rand.Float64()-0.5)
-	}
+	admittance := func(frequency float64) complex128 { return 1 / sg.options.Circuit.Impedance(frequency) }
+	lines := phasors(sg.options.Excitation, admittance)
+	values := synthesize(sg.options.Excitation, lines, sampleRate, samplesPerSecond)
+	currentDC := real(admittance(0)) * sg.options.VoltageDC
+	sg.addNoise(values, currentDC, sg.options.CurrentNoise)
 
 	return Signal{
-		Timestamp:  now,
+		Timestamp:  sg.clock.Now(),
 		Values:     values,
 		SampleRate: sampleRate,
+		Metadata:   Metadata{Unit: UnitAmpere},
 	}, nil
-}
\ No newline at end of file
+}
+
+// addNoise adds a DC offset and Gaussian noise of the given RMS to values
+func (sg *DefaultGenerator) addNoise(values []float64, dc, rms float64) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	for i := range values {
+		values[i] += dc
+		if rms > 0 {
+			values[i] += rms * sg.rng.NormFloat64()
+		}
+	}
+}
+
+// validateGeneration checks the requested sample rate and length
+func validateGeneration(sampleRate float64, samplesPerSecond int) error {
+	if sampleRate <= 0 {
+		return config.ErrInvalidSampleRate
+	}
+	if samplesPerSecond <= 0 {
+		return config.NewValidationError("SamplesPerSecond", "samples per second must be greater than 0")
+	}
+	return nil
+}
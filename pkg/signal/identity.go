@@ -0,0 +1,42 @@
+package signal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+)
+
+// Identity uniquely identifies a measurement so sinks and collectors can
+// detect duplicates (same ID) and losses (gaps in Sequence)
+type Identity struct {
+	ID       string `json:"id,omitempty"`
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// sequence is the last sequence number handed out by NewIdentity
+var sequence atomic.Uint64
+
+// NewIdentity returns a random UUID together with the next sequence number of
+// this process. Sequence numbers start at 1 and increase monotonically.
+func NewIdentity() Identity {
+	return Identity{
+		ID:       NewUUID(),
+		Sequence: sequence.Add(1),
+	}
+}
+
+// IsZero reports whether no identity has been assigned
+func (id Identity) IsZero() bool {
+	return id.ID == "" && id.Sequence == 0
+}
+
+// NewUUID returns a random RFC 4122 version 4 UUID
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,434 @@
+package signal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// File kinds recognized by InspectFile
+const (
+	FileKindUnknown    = "unknown"
+	FileKindTimeDomain = "time-domain"
+	FileKindImpedance  = "impedance"
+)
+
+// maxReportedProblems caps the number of individual problems listed in a report
+const maxReportedProblems = 20
+
+const (
+	// irregularStepTolerance is the relative deviation from the median
+	// sample period beyond which a time step counts as irregular
+	irregularStepTolerance = 0.1
+	// maxIrregularStepFraction is the share of irregular time steps up to
+	// which DetectSampleRate trusts the median period
+	maxIrregularStepFraction = 0.01
+)
+
+// FileReport describes the structure and content of an input file
+type FileReport struct {
+	Path        string   `json:"path"`
+	Kind        string   `json:"kind"`
+	Delimiter   string   `json:"delimiter"`
+	Decimal     string   `json:"decimal"`
+	HasHeader   bool     `json:"has_header"`
+	Columns     []string `json:"columns"`
+	Rows        int      `json:"rows"`
+	InvalidRows int      `json:"invalid_rows"`
+
+	// Time-domain files
+	StartTime           *time.Time `json:"start_time,omitempty"`
+	EndTime             *time.Time `json:"end_time,omitempty"`
+	DurationSeconds     float64    `json:"duration_seconds,omitempty"`
+	EstimatedSampleRate float64    `json:"estimated_sample_rate,omitempty"`
+	TimestampJitter     float64    `json:"timestamp_jitter_seconds,omitempty"`
+	Gaps                int        `json:"gaps,omitempty"`
+	IrregularSteps      int        `json:"irregular_steps,omitempty"` // Steps off the median period by more than 10%, including gaps and non-increasing steps
+	ValueMin            float64    `json:"value_min,omitempty"`
+	ValueMax            float64    `json:"value_max,omitempty"`
+
+	// Impedance files
+	Spectra           int     `json:"spectra,omitempty"`
+	MinPointsSpectrum int     `json:"min_points_per_spectrum,omitempty"`
+	MaxPointsSpectrum int     `json:"max_points_per_spectrum,omitempty"`
+	FrequencyMin      float64 `json:"frequency_min_hz,omitempty"`
+	FrequencyMax      float64 `json:"frequency_max_hz,omitempty"`
+
+	Problems []string `json:"problems"`
+}
+
+// addProblem records a problem, summarizing once the list gets long
+func (r *FileReport) addProblem(format string, args ...interface{}) {
+	if len(r.Problems) < maxReportedProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	} else if len(r.Problems) == maxReportedProblems {
+		r.Problems = append(r.Problems, "further problems omitted")
+	}
+}
+
+// SniffCSVDialect guesses delimiter and decimal separator from the first lines of a file
+func SniffCSVDialect(sample []byte) CSVDialect {
+	lines := nonEmptyLines(sample, 20)
+	dialect := DefaultCSVDialect
+
+	bestScore := 0
+	for _, candidate := range []rune{',', ';', '\t'} {
+		counts := make([]int, len(lines))
+		for i, line := range lines {
+			counts[i] = strings.Count(line, string(candidate))
+		}
+		if len(counts) == 0 || counts[0] == 0 {
+			continue
+		}
+
+		// Prefer delimiters that split every line into the same number of fields
+		consistent := 0
+		for _, c := range counts {
+			if c == counts[0] {
+				consistent++
+			}
+		}
+		score := consistent*100 + counts[0]
+		if score > bestScore {
+			bestScore = score
+			dialect.Delimiter = candidate
+		}
+	}
+
+	// Decimal commas are only possible when comma is not the delimiter
+	if dialect.Delimiter != ',' {
+		for _, line := range lines {
+			for _, field := range strings.Split(line, string(dialect.Delimiter)) {
+				field = strings.TrimSpace(field)
+				if strings.Contains(field, ",") && !strings.Contains(field, ":") {
+					if _, err := (CSVDialect{Delimiter: dialect.Delimiter, DecimalSeparator: ','}).ParseFloat(field); err == nil {
+						dialect.DecimalSeparator = ','
+						return dialect
+					}
+				}
+			}
+		}
+	}
+
+	return dialect
+}
+
+// nonEmptyLines returns up to max non-empty lines from data, skipping comments
+func nonEmptyLines(data []byte, max int) []string {
+	lines := make([]string, 0, max)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() && len(lines) < max {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// InspectFile examines an input file and reports its detected layout and content.
+// The dialect is sniffed from the file unless one is given.
+func InspectFile(path string, dialect *CSVDialect) (*FileReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", path, err))
+	}
+	defer file.Close()
+
+	sample := make([]byte, 8192)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, config.NewProcessingError("file reading", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, config.NewProcessingError("file seeking", err)
+	}
+
+	d := SniffCSVDialect(sample[:n])
+	if dialect != nil {
+		d = *dialect
+	}
+
+	report := &FileReport{
+		Path:      path,
+		Kind:      FileKindUnknown,
+		Delimiter: string(d.Delimiter),
+		Decimal:   string(d.DecimalSeparator),
+		Problems:  []string{},
+	}
+
+	reader := d.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	first, err := reader.Read()
+	if err == io.EOF {
+		report.addProblem("file is empty")
+		return report, nil
+	}
+	if err != nil {
+		return nil, config.NewProcessingError("CSV reading", err)
+	}
+
+	var pending []string
+	if columns, isHeader, err := detectImpedanceColumns(first); isHeader {
+		report.HasHeader = true
+		report.Columns = append([]string(nil), first...)
+		if err != nil {
+			report.addProblem("%v", err)
+			return report, nil
+		}
+		report.Kind = FileKindImpedance
+		return report, inspectImpedance(reader, d, columns, report, nil)
+	}
+
+	if isTimeDomainHeader(first) {
+		report.HasHeader = true
+		report.Columns = append([]string(nil), first...)
+	} else {
+		pending = first
+	}
+
+	switch {
+	case report.HasHeader:
+		report.Kind = FileKindTimeDomain
+	case len(first) > 0 && isTimestamp(first[0]):
+		report.Kind = FileKindTimeDomain
+	case len(first) >= 3:
+		report.Kind = FileKindImpedance
+		return report, inspectImpedance(reader, d, defaultImpedanceColumns, report, pending)
+	default:
+		report.addProblem("unrecognized layout with %d columns", len(first))
+		return report, nil
+	}
+
+	return report, inspectTimeDomain(reader, d, report, pending)
+}
+
+// isTimeDomainHeader reports whether a header row names timestamp/time columns
+func isTimeDomainHeader(header []string) bool {
+	for _, name := range header {
+		switch normalizeHeader(name) {
+		case "timestamp", "time", "timeoffset", "t", "ts":
+			return true
+		}
+	}
+	return false
+}
+
+// isTimestamp reports whether value is an RFC 3339 timestamp
+func isTimestamp(value string) bool {
+	_, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+	return err == nil
+}
+
+// forEachRecord calls fn for the pending record (if any) and then every remaining record
+func forEachRecord(reader *csv.Reader, pending []string, fn func(line int, record []string)) error {
+	line := 1
+	if pending != nil {
+		fn(line, pending)
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+		if err != nil {
+			return config.NewProcessingError("CSV reading", fmt.Errorf("line %d: %w", line, err))
+		}
+		fn(line, record)
+	}
+}
+
+// inspectTimeDomain analyzes timestamp,time_offset,value rows
+func inspectTimeDomain(reader *csv.Reader, dialect CSVDialect, report *FileReport, pending []string) error {
+	var offsets []float64
+	var firstTime, lastTime time.Time
+	report.ValueMin = math.Inf(1)
+	report.ValueMax = math.Inf(-1)
+
+	err := forEachRecord(reader, pending, func(line int, record []string) {
+		report.Rows++
+		if len(record) < 3 {
+			report.InvalidRows++
+			report.addProblem("line %d: expected 3 columns, got %d", line, len(record))
+			return
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(record[0]))
+		if err != nil {
+			report.InvalidRows++
+			report.addProblem("line %d: invalid timestamp '%s'", line, record[0])
+			return
+		}
+		value, err := dialect.ParseFloat(record[2])
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			report.InvalidRows++
+			report.addProblem("line %d: invalid value '%s'", line, record[2])
+			return
+		}
+
+		if firstTime.IsZero() {
+			firstTime = ts
+		}
+		lastTime = ts
+
+		offset := ts.Sub(firstTime).Seconds()
+		if parsed, err := dialect.ParseFloat(record[1]); err == nil {
+			offset = parsed
+		}
+		offsets = append(offsets, offset)
+
+		report.ValueMin = math.Min(report.ValueMin, value)
+		report.ValueMax = math.Max(report.ValueMax, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(offsets) == 0 {
+		report.ValueMin, report.ValueMax = 0, 0
+		report.addProblem("no valid samples")
+		return nil
+	}
+
+	report.StartTime = &firstTime
+	report.EndTime = &lastTime
+
+	if len(offsets) < 2 {
+		report.addProblem("only one valid sample; sample rate cannot be estimated")
+		return nil
+	}
+
+	deltas := make([]float64, 0, len(offsets)-1)
+	nonMonotonic := 0
+	for i := 1; i < len(offsets); i++ {
+		delta := offsets[i] - offsets[i-1]
+		if delta <= 0 {
+			nonMonotonic++
+			continue
+		}
+		deltas = append(deltas, delta)
+	}
+	if nonMonotonic > 0 {
+		report.addProblem("%d non-increasing time steps", nonMonotonic)
+	}
+	report.IrregularSteps = nonMonotonic
+	if len(deltas) == 0 {
+		report.addProblem("time offsets never increase")
+		return nil
+	}
+
+	median := medianOf(deltas)
+	report.EstimatedSampleRate = 1 / median
+	report.DurationSeconds = offsets[len(offsets)-1] - offsets[0] + median
+
+	for _, delta := range deltas {
+		report.TimestampJitter = math.Max(report.TimestampJitter, math.Abs(delta-median))
+		if math.Abs(delta-median) > irregularStepTolerance*median {
+			report.IrregularSteps++
+		}
+		if delta > 1.5*median {
+			report.Gaps++
+		}
+	}
+	if report.Gaps > 0 {
+		report.addProblem("%d gaps longer than 1.5 sample periods", report.Gaps)
+	}
+
+	return nil
+}
+
+// inspectImpedance analyzes impedance rows grouped by spectrum number
+func inspectImpedance(reader *csv.Reader, dialect CSVDialect, columns impedanceColumns, report *FileReport, pending []string) error {
+	pointsBySpectrum := make(map[int]int)
+	report.FrequencyMin = math.Inf(1)
+	report.FrequencyMax = math.Inf(-1)
+
+	err := forEachRecord(reader, pending, func(line int, record []string) {
+		report.Rows++
+		row, reason := columns.parse(record, dialect)
+		if reason != "" {
+			report.InvalidRows++
+			report.addProblem("line %d: %s", line, reason)
+			return
+		}
+		if row.frequency <= 0 {
+			report.addProblem("line %d: non-positive frequency %g", line, row.frequency)
+		}
+		pointsBySpectrum[row.spectrum]++
+		report.FrequencyMin = math.Min(report.FrequencyMin, row.frequency)
+		report.FrequencyMax = math.Max(report.FrequencyMax, row.frequency)
+	})
+	if err != nil {
+		return err
+	}
+
+	report.Spectra = len(pointsBySpectrum)
+	if report.Spectra == 0 {
+		report.FrequencyMin, report.FrequencyMax = 0, 0
+		report.addProblem("no valid impedance rows")
+		return nil
+	}
+
+	report.MinPointsSpectrum = math.MaxInt
+	for _, points := range pointsBySpectrum {
+		if points < report.MinPointsSpectrum {
+			report.MinPointsSpectrum = points
+		}
+		if points > report.MaxPointsSpectrum {
+			report.MaxPointsSpectrum = points
+		}
+	}
+	if report.MinPointsSpectrum != report.MaxPointsSpectrum {
+		report.addProblem("spectra have different point counts (%d-%d)", report.MinPointsSpectrum, report.MaxPointsSpectrum)
+	}
+
+	return nil
+}
+
+// DetectSampleRate derives the sample rate of a time-domain file from the
+// median step of its timestamps. The dialect is sniffed unless one is given.
+// Files without usable timestamps, or whose steps are too irregular for the
+// median to stand for the sampling (more than 1% of them off by over 10%),
+// are rejected.
+func DetectSampleRate(path string, dialect *CSVDialect) (float64, error) {
+	report, err := InspectFile(path, dialect)
+	if err != nil {
+		return 0, err
+	}
+	if report.EstimatedSampleRate <= 0 {
+		return 0, config.NewValidationError("SampleRate", fmt.Sprintf("%s has no usable timestamps to derive the sample rate from", path))
+	}
+	steps := report.Rows - report.InvalidRows - 1
+	if float64(report.IrregularSteps) > maxIrregularStepFraction*float64(steps) {
+		return 0, config.NewValidationError("SampleRate", fmt.Sprintf("timestamps of %s are irregular: %d of %d steps differ from the median period of %.6g s by more than %.0f%%",
+			path, report.IrregularSteps, steps, 1/report.EstimatedSampleRate, 100*irregularStepTolerance))
+	}
+	return RoundSampleRate(report.EstimatedSampleRate), nil
+}
+
+// RoundSampleRate drops the rounding noise of a rate estimated from timestamp
+// differences, so that 1 ms steps give exactly 1000 Hz
+func RoundSampleRate(rate float64) float64 {
+	return roundSignificant(rate, 9)
+}
+
+// medianOf returns the median of values without modifying the input
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
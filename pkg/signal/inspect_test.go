@@ -0,0 +1,96 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspectFile(t *testing.T) {
+	timeDomain := writeTestFile(t, "voltage.csv", `timestamp;time_offset;voltage
+2025-07-25T20:22:41.000+02:00;0,000;1,0
+2025-07-25T20:22:41.001+02:00;0,001;1,1
+2025-07-25T20:22:41.002+02:00;0,002;1,2
+2025-07-25T20:22:41.005+02:00;0,005;1,3
+2025-07-25T20:22:41.006+02:00;0,006;oops
+`)
+
+	report, err := InspectFile(timeDomain, nil)
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+	if report.Kind != FileKindTimeDomain || report.Delimiter != ";" || report.Decimal != "," {
+		t.Errorf("unexpected detection: kind=%s delimiter=%q decimal=%q", report.Kind, report.Delimiter, report.Decimal)
+	}
+	if report.Rows != 5 || report.InvalidRows != 1 {
+		t.Errorf("expected 5 rows with 1 invalid, got %d/%d", report.Rows, report.InvalidRows)
+	}
+	if math.Abs(report.EstimatedSampleRate-1000) > 1e-6 {
+		t.Errorf("expected 1000 Hz estimate, got %v", report.EstimatedSampleRate)
+	}
+	if report.Gaps != 1 {
+		t.Errorf("expected 1 gap, got %d", report.Gaps)
+	}
+
+	impedance := writeTestFile(t, "spectra.csv", "freq\tZre\tZim\tspectrum\n100\t1\t-1\t0\n10\t2\t-2\t0\n100\t1\t-1\t1\n")
+	report, err = InspectFile(impedance, nil)
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+	if report.Kind != FileKindImpedance || report.Delimiter != "\t" {
+		t.Errorf("unexpected detection: kind=%s delimiter=%q", report.Kind, report.Delimiter)
+	}
+	if report.Spectra != 2 || report.MinPointsSpectrum != 1 || report.MaxPointsSpectrum != 2 {
+		t.Errorf("unexpected spectra summary: %d spectra, %d-%d points", report.Spectra, report.MinPointsSpectrum, report.MaxPointsSpectrum)
+	}
+	if len(report.Problems) == 0 {
+		t.Errorf("expected a problem for unequal point counts")
+	}
+}
+
+func TestDetectSampleRate(t *testing.T) {
+	// timeDomain builds 201 samples at 1 kHz, moving the given steps by 0.5 ms
+	timeDomain := func(shifted ...int) string {
+		var b strings.Builder
+		b.WriteString("timestamp,time_offset,voltage\n")
+		start := time.Date(2025, 7, 25, 20, 0, 0, 0, time.UTC)
+		offset := 0.0
+		for i := 0; i <= 200; i++ {
+			if i > 0 {
+				offset += 0.001
+				if slices.Contains(shifted, i) {
+					offset += 0.0005
+				}
+			}
+			fmt.Fprintf(&b, "%s,%.6f,1.0\n", start.Add(time.Duration(offset*float64(time.Second))).Format(time.RFC3339Nano), offset)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    float64
+		wantErr bool
+	}{
+		{name: "regular", content: timeDomain(), want: 1000},
+		{name: "one late sample", content: timeDomain(50), want: 1000},
+		{name: "irregular", content: timeDomain(10, 20, 30, 40, 50), wantErr: true},
+		{name: "single sample", content: "timestamp,time_offset,voltage\n2025-07-25T20:00:00Z,0,1.0\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectSampleRate(writeTestFile(t, "voltage.csv", tt.content), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectSampleRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectSampleRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
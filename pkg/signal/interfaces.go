@@ -18,4 +18,13 @@ type Generator interface {
 type DataLoader interface {
 	LoadSignalFromCSV(filename string, sampleRate float64) ([]Signal, error)
 	LoadVoltageAndCurrentFromCSV(voltageFile, currentFile string, sampleRate float64) ([]Signal, []Signal, error)
-}
\ No newline at end of file
+	LoadElectrodeSignalsFromCSV(filename string, sampleRate float64) ([]ElectrodeSignals, error)
+	LoadImpedanceFromCSV(filename string) ([]ImpedanceDataWithIteration, error)
+	StreamImpedanceFromCSV(filename string, handler func(spectrum ImpedanceDataWithIteration, progress float64) error) error
+	ParseReports() []ParseReport
+}
+
+// CircuitModel describes the impedance of a simulated cell
+type CircuitModel interface {
+	Impedance(frequency float64) complex128
+}
@@ -0,0 +1,39 @@
+package signal
+
+import (
+	"math"
+	"sort"
+)
+
+// InterpolateImpedance evaluates a spectrum at the given frequencies, linearly
+// in log frequency between its points. The spectrum may be in any frequency
+// order; frequencies outside it take the value of the nearest edge point.
+func InterpolateImpedance(frequencies []float64, impedance []complex128, at []float64) []complex128 {
+	order := make([]int, len(frequencies))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return frequencies[order[a]] < frequencies[order[b]] })
+	sorted := make([]float64, len(order))
+	for i, j := range order {
+		sorted[i] = frequencies[j]
+	}
+
+	result := make([]complex128, len(at))
+	n := len(sorted)
+	for i, f := range at {
+		j := sort.SearchFloat64s(sorted, f)
+		switch {
+		case j == 0:
+			result[i] = impedance[order[0]]
+		case j == n:
+			result[i] = impedance[order[n-1]]
+		default:
+			f0, f1 := sorted[j-1], sorted[j]
+			z0, z1 := impedance[order[j-1]], impedance[order[j]]
+			t := math.Log(f/f0) / math.Log(f1/f0)
+			result[i] = z0 + complex(t, 0)*(z1-z0)
+		}
+	}
+	return result
+}
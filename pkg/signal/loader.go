@@ -1,12 +1,16 @@
 package signal
 
 import (
-	"encoding/csv"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/adam/masterapp/pkg/clock"
 	"github.com/adam/masterapp/pkg/config"
 )
 
@@ -14,25 +18,73 @@ import (
 // CSVDataLoader implements loading signals from CSV files
 type CSVDataLoader struct {
 	validator Validator
+	dialect   CSVDialect
+	parseMode ParseMode
+	window    TimeWindow
+	clock     clock.Clock
+	times     bool
+	gapLimit  float64
+	chunkSize int
+	chunkTime time.Duration
+	reports   []ParseReport
 }
 
-// NewDataLoader creates a new CSV data loader
+// NewDataLoader creates a new CSV data loader for comma separated files
 func NewDataLoader() DataLoader {
+	return NewDataLoaderWithDialect(DefaultCSVDialect)
+}
+
+// NewDataLoaderWithDialect creates a new CSV data loader for the given dialect
+func NewDataLoaderWithDialect(dialect CSVDialect) DataLoader {
+	return NewDataLoaderWithOptions(LoaderOptions{Dialect: dialect})
+}
+
+// NewDataLoaderWithOptions creates a new CSV data loader with the given dialect and parse mode
+func NewDataLoaderWithOptions(options LoaderOptions) DataLoader {
 	return &CSVDataLoader{
 		validator: NewValidator(),
+		dialect:   options.Dialect,
+		parseMode: options.ParseMode,
+		window:    options.Window,
+		clock:     options.Clock,
+		times:     options.SampleTimes,
+		gapLimit:  options.GapThreshold,
+		chunkSize: options.ChunkSamples,
+		chunkTime: options.ChunkDuration,
 	}
 }
 
+// ParseReports returns the parse reports of all files loaded so far
+func (loader *CSVDataLoader) ParseReports() []ParseReport {
+	return append([]ParseReport(nil), loader.reports...)
+}
+
 // LoadSignalFromCSV loads signal data from a CSV file
 // Expected CSV format: timestamp,time_offset,value
 func (loader *CSVDataLoader) LoadSignalFromCSV(filename string, sampleRate float64) ([]Signal, error) {
+	samples, err := loader.readSamples(filename, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := loader.chunkRanges(sampleRate, samples)
+	if err != nil {
+		return nil, err
+	}
+	return loader.chunkSignals(samples, chunks, sampleRate)
+}
+
+// readSamples parses the samples of a time-domain CSV file within the
+// loader's time window
+func (loader *CSVDataLoader) readSamples(filename string, sampleRate float64) ([]timeSample, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", filename, err))
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader := loader.dialect.NewReader(file)
+	reader.FieldsPerRecord = -1 // Short rows are handled according to the parse mode
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, config.NewProcessingError("CSV reading", fmt.Errorf("failed to read CSV: %w", err))
@@ -43,23 +95,146 @@ func (loader *CSVDataLoader) LoadSignalFromCSV(filename string, sampleRate float
 	}
 
 	// Skip header row
-	records = records[1:]
+	samples, report, err := loader.parseSamples(filename, records[1:], sampleRate)
+	loader.reports = append(loader.reports, report)
+	if err != nil {
+		return nil, err
+	}
+
+	samples = loader.window.apply(samples)
+	if len(samples) == 0 {
+		return nil, config.NewValidationError("TimeWindow",
+			fmt.Sprintf("%s has no samples within time window %s", filename, loader.window))
+	}
+	return samples, nil
+}
 
-	// Group data into 1-second chunks (assuming 1000 samples per second)
-	samplesPerSecond := int(sampleRate)
-	totalSignals := (len(records) + samplesPerSecond - 1) / samplesPerSecond
-	signals := make([]Signal, 0, totalSignals)
+// chunkRange is the sample index range of one loaded signal
+type chunkRange struct {
+	start, end int
+	gap        time.Duration // Dropout after the range, 0 when it has full size
+}
 
-	for i := 0; i < len(records); i += samplesPerSecond {
-		end := i + samplesPerSecond
-		if end > len(records) {
-			end = len(records)
+// chunkRanges splits sample indices into chunks of the configured chunk size,
+// 1 second of samples by default. With a gap threshold a chunk ends early at
+// an acquisition dropout, so no signal spans discontinuous data; the next
+// chunk starts after the dropout. A dropout in any of the series ends the
+// chunk in all of them, so recordings of the same acquisition stay aligned.
+func (loader *CSVDataLoader) chunkRanges(sampleRate float64, series ...[]timeSample) ([]chunkRange, error) {
+	size := loader.chunkSize
+	if size <= 0 && loader.chunkTime > 0 {
+		size = max(1, int(math.Round(loader.chunkTime.Seconds()*sampleRate)))
+	}
+	if size <= 0 {
+		if !(sampleRate >= 1) || sampleRate > math.MaxInt32 {
+			return nil, config.NewValidationError("SampleRate", fmt.Sprintf("sample rate %g Hz cannot form 1-second chunks", sampleRate))
 		}
+		size = int(sampleRate)
+	}
 
-		chunk := records[i:end]
-		signal, err := loader.parseSignalChunk(chunk, sampleRate)
-		if err != nil {
-			return nil, config.NewProcessingError("signal parsing", err)
+	n := 0
+	for _, samples := range series {
+		n = max(n, len(samples))
+	}
+	ranges := make([]chunkRange, 0, (n+size-1)/size)
+
+	maxStep := time.Duration(loader.gapLimit / sampleRate * float64(time.Second))
+	period := time.Duration(float64(time.Second) / sampleRate)
+	for i := 0; i < n; {
+		chunk := chunkRange{start: i, end: min(i+size, n)}
+		if loader.gapLimit > 0 {
+			for j := i + 1; j < chunk.end && chunk.gap == 0; j++ {
+				for _, samples := range series {
+					if j >= len(samples) {
+						continue
+					}
+					if step := samples[j].timestamp.Sub(samples[j-1].timestamp); step > maxStep {
+						chunk.end, chunk.gap = j, max(chunk.gap, step-period)
+					}
+				}
+			}
+		}
+		ranges = append(ranges, chunk)
+		i = chunk.end
+	}
+	return ranges, nil
+}
+
+// timeSpan is a stretch of time covered by samples without a dropout
+type timeSpan struct {
+	from, to time.Time
+}
+
+// alignAtGaps keeps only the samples of voltage and current taken while the
+// other logger recorded as well, i.e. not before its first sample, after its
+// last or within one of its dropouts, with half a sample period of slack.
+// Samples at the same index then belong to the same instant even when only
+// one of the loggers dropped out. Without a gap threshold nothing is dropped.
+func (loader *CSVDataLoader) alignAtGaps(sampleRate float64, voltage, current []timeSample) ([]timeSample, []timeSample) {
+	if loader.gapLimit <= 0 {
+		return voltage, current
+	}
+	maxStep := time.Duration(loader.gapLimit / sampleRate * float64(time.Second))
+	slack := time.Duration(float64(time.Second) / sampleRate / 2)
+	voltageSpans, currentSpans := coveredSpans(voltage, maxStep), coveredSpans(current, maxStep)
+	return keepCovered(voltage, currentSpans, slack), keepCovered(current, voltageSpans, slack)
+}
+
+// coveredSpans returns the stretches of samples separated by steps above maxStep
+func coveredSpans(samples []timeSample, maxStep time.Duration) []timeSpan {
+	var spans []timeSpan
+	for i, s := range samples {
+		if i == 0 || s.timestamp.Sub(samples[i-1].timestamp) > maxStep {
+			spans = append(spans, timeSpan{from: s.timestamp})
+		}
+		spans[len(spans)-1].to = s.timestamp
+	}
+	return spans
+}
+
+// keepCovered returns the samples within slack of one of the spans
+func keepCovered(samples []timeSample, spans []timeSpan, slack time.Duration) []timeSample {
+	kept := samples[:0:0]
+	for _, s := range samples {
+		i := sort.Search(len(spans), func(i int) bool { return !spans[i].to.Add(slack).Before(s.timestamp) })
+		if i < len(spans) && !s.timestamp.Before(spans[i].from.Add(-slack)) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// chunkSignals turns the given ranges of samples into validated signals.
+// Ranges past the end of samples are left out.
+func (loader *CSVDataLoader) chunkSignals(samples []timeSample, ranges []chunkRange, sampleRate float64) ([]Signal, error) {
+	signals := make([]Signal, 0, len(ranges))
+	for _, r := range ranges {
+		if r.start >= len(samples) {
+			break
+		}
+		chunk := samples[r.start:min(r.end, len(samples))]
+		values := make([]float64, len(chunk))
+		repaired := 0
+		for j, s := range chunk {
+			values[j] = s.value
+			if s.repaired {
+				repaired++
+			}
+		}
+
+		// The first sample sets the timestamp for the whole signal
+		signal := Signal{
+			Timestamp:       chunk[0].timestamp,
+			Values:          values,
+			SampleRate:      sampleRate,
+			RepairedSamples: repaired,
+			Gap:             r.gap,
+		}
+		if loader.times {
+			signal.SampleTimes = make([]float64, len(chunk))
+			for j, s := range chunk {
+				signal.SampleTimes[j] = s.timestamp.Sub(chunk[0].timestamp).Seconds()
+			}
 		}
 
 		if err := loader.validator.ValidateSignal(signal); err != nil {
@@ -72,18 +247,48 @@ func (loader *CSVDataLoader) LoadSignalFromCSV(filename string, sampleRate float
 	return signals, nil
 }
 
-// LoadVoltageAndCurrentFromCSV loads both voltage and current signals from separate CSV files
+// LoadVoltageAndCurrentFromCSV loads both voltage and current signals from
+// separate CSV files. With a gap threshold the samples of each file recorded
+// while the other logger dropped out, or before or after it recorded, are
+// dropped, and both are split into chunks at the same sample indices, at the
+// dropouts of either file.
 func (loader *CSVDataLoader) LoadVoltageAndCurrentFromCSV(voltageFile, currentFile string, sampleRate float64) ([]Signal, []Signal, error) {
-	voltageSignals, err := loader.LoadSignalFromCSV(voltageFile, sampleRate)
+	voltageSamples, err := loader.readSamples(voltageFile, sampleRate)
+	if err != nil {
+		return nil, nil, config.NewProcessingError("voltage loading", err)
+	}
+
+	currentSamples, err := loader.readSamples(currentFile, sampleRate)
+	if err != nil {
+		return nil, nil, config.NewProcessingError("current loading", err)
+	}
+
+	voltageSamples, currentSamples = loader.alignAtGaps(sampleRate, voltageSamples, currentSamples)
+	if len(voltageSamples) == 0 || len(currentSamples) == 0 {
+		return nil, nil, config.NewValidationError("Data", fmt.Sprintf("%s and %s were not recorded at the same time", voltageFile, currentFile))
+	}
+	chunks, err := loader.chunkRanges(sampleRate, voltageSamples, currentSamples)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	voltageSignals, err := loader.chunkSignals(voltageSamples, chunks, sampleRate)
 	if err != nil {
 		return nil, nil, config.NewProcessingError("voltage loading", err)
 	}
 
-	currentSignals, err := loader.LoadSignalFromCSV(currentFile, sampleRate)
+	currentSignals, err := loader.chunkSignals(currentSamples, chunks, sampleRate)
 	if err != nil {
 		return nil, nil, config.NewProcessingError("current loading", err)
 	}
 
+	for i := range voltageSignals {
+		voltageSignals[i].Metadata = voltageSignals[i].Metadata.WithUnit(UnitVolt)
+	}
+	for i := range currentSignals {
+		currentSignals[i].Metadata = currentSignals[i].Metadata.WithUnit(UnitAmpere)
+	}
+
 	if len(voltageSignals) != len(currentSignals) {
 		return nil, nil, config.NewValidationError("DataLength", 
 			fmt.Sprintf("voltage and current must have same number of signals: got %d voltage, %d current", 
@@ -100,196 +305,522 @@ func (loader *CSVDataLoader) LoadVoltageAndCurrentFromCSV(voltageFile, currentFi
 	return voltageSignals, currentSignals, nil
 }
 
-// parseSignalChunk converts a chunk of CSV records into a Signal
-func (loader *CSVDataLoader) parseSignalChunk(records [][]string, sampleRate float64) (Signal, error) {
-	if len(records) == 0 {
-		return Signal{}, config.NewValidationError("Records", "empty record chunk")
-	}
+// timeSample is a single parsed row of a time-domain CSV file
+type timeSample struct {
+	timestamp time.Time
+	value     float64
+	hasTime   bool
+	hasValue  bool
+	repaired  bool
+}
 
-	values := make([]float64, len(records))
-	var timestamp time.Time
+// parseSamples converts data records into samples according to the loader's
+// parse mode. Strict is the default for time-domain files.
+func (loader *CSVDataLoader) parseSamples(filename string, records [][]string, sampleRate float64) ([]timeSample, ParseReport, error) {
+	mode := loader.parseMode.orDefault(ParseModeStrict)
+	report := ParseReport{File: filename, Mode: mode, Rows: len(records)}
+	samples := make([]timeSample, 0, len(records))
 
 	for i, record := range records {
-		if len(record) < 3 {
-			return Signal{}, config.NewValidationError("Record", fmt.Sprintf("record %d must have at least 3 columns", i))
+		line := i + 2 // 1-based line number after the header
+		sample, reason := loader.parseSampleRecord(record)
+		if reason == "" {
+			samples = append(samples, sample)
+			continue
 		}
 
-		// Parse timestamp (first record sets the timestamp for the whole signal)
-		if i == 0 {
-			parsedTime, err := time.Parse(time.RFC3339Nano, record[0])
-			if err != nil {
-				return Signal{}, config.NewProcessingError("timestamp parsing", 
-					fmt.Errorf("invalid timestamp format in record %d: %w", i, err))
-			}
-			timestamp = parsedTime
+		switch mode {
+		case ParseModeLenient:
+			report.Skipped = append(report.Skipped, RowIssue{Line: line, Reason: reason})
+		case ParseModeRepair:
+			report.Repaired = append(report.Repaired, RowIssue{Line: line, Reason: reason})
+			sample.repaired = true
+			samples = append(samples, sample)
+		default:
+			return nil, report, rowError(filename, line, reason)
 		}
+	}
 
-		// Parse value (third column)
-		value, err := strconv.ParseFloat(record[2], 64)
-		if err != nil {
-			return Signal{}, config.NewProcessingError("value parsing", 
-				fmt.Errorf("invalid value in record %d: %w", i, err))
+	if mode == ParseModeRepair {
+		if err := repairSamples(samples, sampleRate); err != nil {
+			return nil, report, config.NewProcessingError("sample repair", fmt.Errorf("%s: %w", filename, err))
 		}
+	}
 
-		values[i] = value
+	if len(samples) == 0 {
+		return nil, report, config.NewValidationError("Data", fmt.Sprintf("%s contains no valid data rows", filename))
 	}
 
-	return Signal{
-		Timestamp:  timestamp,
-		Values:     values,
-		SampleRate: sampleRate,
-	}, nil
+	return samples, report, nil
 }
 
-// LoadImpedanceFromCSV loads impedance data from a combined CSV file
-// Expected CSV format: Frequency_Hz,Z_real,Z_imag,Spectrum_Number
-func (loader *CSVDataLoader) LoadImpedanceFromCSV(filename string) ([]ImpedanceDataWithIteration, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", filename, err))
+// parseSampleRecord parses a timestamp,time_offset,value record. It returns a
+// non-empty reason describing the first problem found.
+func (loader *CSVDataLoader) parseSampleRecord(record []string) (timeSample, string) {
+	if len(record) < 3 {
+		return timeSample{}, fmt.Sprintf("expected at least 3 columns, got %d", len(record))
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, config.NewProcessingError("CSV reading", fmt.Errorf("failed to read CSV: %w", err))
+	var sample timeSample
+	var reasons []string
+
+	if parsedTime, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(record[0])); err == nil {
+		sample.timestamp = parsedTime
+		sample.hasTime = true
+	} else {
+		reasons = append(reasons, fmt.Sprintf("invalid timestamp %q", record[0]))
 	}
 
-	if len(records) < 2 {
-		return nil, config.NewValidationError("Data", "CSV file must have at least header and one data row")
+	// Parse value (third column)
+	if value, err := loader.dialect.ParseFloat(record[2]); err == nil && isFinite(value) {
+		sample.value = value
+		sample.hasValue = true
+	} else {
+		reasons = append(reasons, fmt.Sprintf("invalid value %q", record[2]))
 	}
 
-	// Check if first line looks like headers
-	firstLine := records[0]
-	hasHeaders := len(firstLine) > 0 && (firstLine[0] == "Frequency_Hz" || firstLine[0] == "frequency")
-	
-	startIndex := 0
-	if hasHeaders {
-		startIndex = 1
+	return sample, strings.Join(reasons, ", ")
+}
+
+// isFinite reports whether v is neither NaN nor infinite
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// repairSamples fills missing values by linear interpolation between the
+// nearest valid neighbours (or copies the nearest one at the edges) and
+// reconstructs missing timestamps from the sample rate.
+func repairSamples(samples []timeSample, sampleRate float64) error {
+	prevValue, prevTime := -1, -1
+	for i := range samples {
+		if samples[i].hasValue {
+			prevValue = i
+		} else {
+			next := -1
+			for j := i + 1; j < len(samples); j++ {
+				if samples[j].hasValue {
+					next = j
+					break
+				}
+			}
+			switch {
+			case prevValue >= 0 && next >= 0:
+				fraction := float64(i-prevValue) / float64(next-prevValue)
+				samples[i].value = samples[prevValue].value + fraction*(samples[next].value-samples[prevValue].value)
+			case prevValue >= 0:
+				samples[i].value = samples[prevValue].value
+			case next >= 0:
+				samples[i].value = samples[next].value
+			default:
+				return fmt.Errorf("no valid values to interpolate from")
+			}
+		}
+
+		if samples[i].hasTime {
+			prevTime = i
+		} else if prevTime >= 0 {
+			samples[i].timestamp = samples[prevTime].timestamp.Add(sampleOffset(i-prevTime, sampleRate))
+		} else {
+			next := -1
+			for j := i + 1; j < len(samples); j++ {
+				if samples[j].hasTime {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				return fmt.Errorf("no valid timestamps to reconstruct from")
+			}
+			samples[i].timestamp = samples[next].timestamp.Add(-sampleOffset(next-i, sampleRate))
+		}
 	}
+	return nil
+}
+
+// sampleOffset returns the duration spanned by n samples at the given rate
+func sampleOffset(n int, sampleRate float64) time.Duration {
+	return time.Duration(float64(n) / sampleRate * float64(time.Second))
+}
 
-	// Group data by spectrum number
+// LoadImpedanceFromCSV loads impedance data from a combined CSV file.
+// Columns are located by header name in any order (see impedanceColumnNames);
+// files without a header use Frequency_Hz,Z_real,Z_imag[,Spectrum_Number].
+// Rows without a spectrum number column belong to a single spectrum.
+func (loader *CSVDataLoader) LoadImpedanceFromCSV(filename string) ([]ImpedanceDataWithIteration, error) {
+	// Group data by spectrum number; rows of a spectrum need not be contiguous
 	dataBySpectrum := make(map[int]*spectrumData)
-	
-	for i := startIndex; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 3 {
-			continue // Skip incomplete lines
+	order := make([]int, 0)
+
+	err := loader.readImpedanceRows(filename, func(row impedanceRow, progress float64) error {
+		spectrum, exists := dataBySpectrum[row.spectrum]
+		if !exists {
+			spectrum = &spectrumData{}
+			dataBySpectrum[row.spectrum] = spectrum
+			order = append(order, row.spectrum)
 		}
 
-		frequency, err := strconv.ParseFloat(record[0], 64)
-		if err != nil {
-			continue // Skip invalid frequency
+		spectrum.frequencies = append(spectrum.frequencies, row.frequency)
+		spectrum.impedances = append(spectrum.impedances, row.impedance)
+		spectrum.flags |= row.flags
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataBySpectrum) == 0 {
+		return nil, config.NewValidationError("Data", "No valid impedance data found in CSV")
+	}
+
+	// Process spectra in ascending spectrum number order
+	sort.Ints(order)
+	result := make([]ImpedanceDataWithIteration, 0, len(order))
+	for _, spectrumNum := range order {
+		spectrum := dataBySpectrum[spectrumNum]
+		result = append(result, ImpedanceDataWithIteration{
+			ImpedanceData: ImpedanceData{
+				Identity:     NewIdentity(),
+				Timestamp:    clock.OrSystem(loader.clock).Now(),
+				Frequencies:  spectrum.frequencies,
+				Impedance:    spectrum.impedances,
+				Metadata:     Metadata{Unit: UnitOhm},
+				QualityFlags: spectrum.flags,
+			},
+			Iteration: spectrumNum,
+		})
+	}
+
+	return result, nil
+}
+
+// StreamImpedanceFromCSV reads impedance data spectrum by spectrum without loading
+// the whole file, calling handler for every completed spectrum together with the
+// fraction of the file consumed so far. Rows of one spectrum must be contiguous.
+// Accepts the same column layouts as LoadImpedanceFromCSV.
+func (loader *CSVDataLoader) StreamImpedanceFromCSV(filename string, handler func(spectrum ImpedanceDataWithIteration, progress float64) error) error {
+	var current *spectrumData
+	currentNumber := 0
+	emitted := 0
+
+	flush := func(progress float64) error {
+		if current == nil || len(current.frequencies) == 0 {
+			return nil
 		}
+		emitted++
+		return handler(ImpedanceDataWithIteration{
+			ImpedanceData: ImpedanceData{
+				Identity:     NewIdentity(),
+				Timestamp:    clock.OrSystem(loader.clock).Now(),
+				Frequencies:  current.frequencies,
+				Impedance:    current.impedances,
+				Metadata:     Metadata{Unit: UnitOhm},
+				QualityFlags: current.flags,
+			},
+			Iteration: currentNumber,
+		}, progress)
+	}
 
-		zReal, err := strconv.ParseFloat(record[1], 64)
-		if err != nil {
-			continue // Skip invalid real part
+	lastProgress := 0.0
+	err := loader.readImpedanceRows(filename, func(row impedanceRow, progress float64) error {
+		if current == nil || row.spectrum != currentNumber {
+			if err := flush(progress); err != nil {
+				return err
+			}
+			current = &spectrumData{}
+			currentNumber = row.spectrum
 		}
 
-		zImag, err := strconv.ParseFloat(record[2], 64)
-		if err != nil {
-			continue // Skip invalid imaginary part
+		current.frequencies = append(current.frequencies, row.frequency)
+		current.impedances = append(current.impedances, row.impedance)
+		current.flags |= row.flags
+		lastProgress = progress
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := flush(math.Max(lastProgress, 1)); err != nil {
+		return err
+	}
+
+	if emitted == 0 {
+		return config.NewValidationError("Data", "No valid impedance data found in CSV")
+	}
+
+	return nil
+}
+
+// impedanceColumnNames maps normalized header names to impedance columns
+var impedanceColumnNames = map[string]string{
+	"frequencyhz":    "frequency",
+	"frequency":      "frequency",
+	"freq":           "frequency",
+	"freqhz":         "frequency",
+	"f":              "frequency",
+	"zreal":          "real",
+	"zre":            "real",
+	"real":           "real",
+	"re":             "real",
+	"zrealohm":       "real",
+	"zimag":          "imag",
+	"zim":            "imag",
+	"imag":           "imag",
+	"im":             "imag",
+	"zimagohm":       "imag",
+	"spectrumnumber": "spectrum",
+	"spectrum":       "spectrum",
+	"iteration":      "spectrum",
+	"qualityflags":   "flags",
+}
+
+// impedanceColumns holds the column index of each impedance field; spectrum
+// and flags are -1 when absent
+type impedanceColumns struct {
+	frequency int
+	real      int
+	imag      int
+	spectrum  int
+	flags     int
+}
+
+// defaultImpedanceColumns is the positional layout used for header-less files
+var defaultImpedanceColumns = impedanceColumns{frequency: 0, real: 1, imag: 2, spectrum: 3, flags: -1}
+
+// impedanceRow is a single parsed impedance CSV row
+type impedanceRow struct {
+	frequency float64
+	impedance complex128
+	spectrum  int
+	flags     QualityFlags
+}
+
+// detectImpedanceColumns maps a header row to column indices. It returns false
+// if the row does not look like a header.
+func detectImpedanceColumns(header []string) (impedanceColumns, bool, error) {
+	columns := impedanceColumns{frequency: -1, real: -1, imag: -1, spectrum: -1, flags: -1}
+	recognized := 0
+
+	for i, name := range header {
+		field, ok := impedanceColumnNames[normalizeHeader(name)]
+		if !ok {
+			continue
 		}
+		recognized++
+		switch field {
+		case "frequency":
+			columns.frequency = i
+		case "real":
+			columns.real = i
+		case "imag":
+			columns.imag = i
+		case "spectrum":
+			columns.spectrum = i
+		case "flags":
+			columns.flags = i
+		}
+	}
 
-		// If there's a 4th column, use it as spectrum number, otherwise treat as single spectrum
-		spectrumNumber := 1
-		if len(record) >= 4 {
-			if num, err := strconv.Atoi(record[3]); err == nil {
-				spectrumNumber = num
-			}
+	if recognized == 0 {
+		return impedanceColumns{}, false, nil
+	}
+
+	if columns.frequency < 0 || columns.real < 0 || columns.imag < 0 {
+		return impedanceColumns{}, true, config.NewValidationError("Header",
+			fmt.Sprintf("impedance CSV header must contain frequency, real and imaginary columns, got %v", header))
+	}
+
+	return columns, true, nil
+}
+
+// normalizeHeader lowercases a header and strips everything but letters and digits
+func normalizeHeader(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
 		}
+	}
+	return b.String()
+}
 
-		// Initialize spectrum data if not exists
-		if dataBySpectrum[spectrumNumber] == nil {
-			dataBySpectrum[spectrumNumber] = &spectrumData{
-				frequencies: make([]float64, 0),
-				impedances:  make([]complex128, 0),
-			}
+// parse converts a record using the column layout and dialect, returning a
+// non-empty reason for invalid rows. Rows without a spectrum number belong to spectrum 1.
+func (c impedanceColumns) parse(record []string, dialect CSVDialect) (impedanceRow, string) {
+	maxIndex := c.frequency
+	if c.real > maxIndex {
+		maxIndex = c.real
+	}
+	if c.imag > maxIndex {
+		maxIndex = c.imag
+	}
+	if len(record) <= maxIndex {
+		return impedanceRow{}, fmt.Sprintf("expected at least %d columns, got %d", maxIndex+1, len(record))
+	}
+
+	frequency, err := dialect.ParseFloat(record[c.frequency])
+	if err != nil || !isFinite(frequency) {
+		return impedanceRow{}, fmt.Sprintf("invalid frequency %q", record[c.frequency])
+	}
+	zReal, err := dialect.ParseFloat(record[c.real])
+	if err != nil || !isFinite(zReal) {
+		return impedanceRow{}, fmt.Sprintf("invalid real part %q", record[c.real])
+	}
+	zImag, err := dialect.ParseFloat(record[c.imag])
+	if err != nil || !isFinite(zImag) {
+		return impedanceRow{}, fmt.Sprintf("invalid imaginary part %q", record[c.imag])
+	}
+
+	// If there's a spectrum column, use it as spectrum number, otherwise treat as single spectrum
+	spectrumNumber := 1
+	if c.spectrum >= 0 && c.spectrum < len(record) {
+		if num, err := strconv.Atoi(strings.TrimSpace(record[c.spectrum])); err == nil {
+			spectrumNumber = num
 		}
+	}
 
-		// Add data point
-		dataBySpectrum[spectrumNumber].frequencies = append(dataBySpectrum[spectrumNumber].frequencies, frequency)
-		dataBySpectrum[spectrumNumber].impedances = append(dataBySpectrum[spectrumNumber].impedances, complex(zReal, zImag))
+	// Quality flags written by the CSV output mode are kept for the spectrum
+	var flags QualityFlags
+	if c.flags >= 0 && c.flags < len(record) {
+		if value, err := strconv.ParseUint(strings.TrimSpace(record[c.flags]), 10, 32); err == nil {
+			flags = QualityFlags(value)
+		}
 	}
 
-	if len(dataBySpectrum) == 0 {
-		return nil, config.NewValidationError("Data", "No valid impedance data found in CSV")
+	return impedanceRow{
+		frequency: frequency,
+		impedance: complex(zReal, zImag),
+		spectrum:  spectrumNumber,
+		flags:     flags,
+	}, ""
+}
+
+// readImpedanceRows streams valid rows of an impedance CSV file to fn together
+// with the fraction of the file consumed. Invalid rows fail the read in strict
+// mode and are skipped otherwise, which is the default for impedance files.
+func (loader *CSVDataLoader) readImpedanceRows(filename string, fn func(row impedanceRow, progress float64) error) error {
+	report := ParseReport{File: filename, Mode: loader.parseMode.orDefault(ParseModeLenient)}
+	err := loader.readImpedanceRecords(filename, &report, fn)
+	loader.reports = append(loader.reports, report)
+	return err
+}
+
+// readImpedanceRecords does the work of readImpedanceRows, filling in report
+func (loader *CSVDataLoader) readImpedanceRecords(filename string, report *ParseReport, fn func(row impedanceRow, progress float64) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", filename, err))
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return config.NewProcessingError("file stat", err)
+	}
+
+	counter := &countingReader{reader: file}
+	reader := loader.dialect.NewReader(counter)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	progress := func() float64 {
+		if info.Size() <= 0 {
+			return 1
+		}
+		return math.Min(float64(counter.count)/float64(info.Size()), 1)
 	}
 
-	// Convert to ImpedanceDataWithIteration array
-	result := make([]ImpedanceDataWithIteration, 0, len(dataBySpectrum))
-	
-	// Process spectra in order
-	for spectrumNum := 1; spectrumNum <= len(dataBySpectrum); spectrumNum++ {
-		if spectrum, exists := dataBySpectrum[spectrumNum]; exists {
-			impedanceData := ImpedanceData{
-				Timestamp:   time.Now(),
-				Frequencies: spectrum.frequencies,
-				Impedance:   spectrum.impedances,
+	columns := defaultImpedanceColumns
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return config.NewProcessingError("CSV reading", fmt.Errorf("failed to read CSV line %d: %w", line+1, err))
+		}
+
+		if line == 0 {
+			detected, isHeader, err := detectImpedanceColumns(record)
+			if err != nil {
+				return err
+			}
+			if isHeader {
+				columns = detected
+				continue
+			}
+		}
+
+		report.Rows++
+		row, reason := columns.parse(record, loader.dialect)
+		if reason != "" {
+			if report.Mode == ParseModeStrict {
+				return rowError(filename, line+1, reason)
 			}
+			report.Skipped = append(report.Skipped, RowIssue{Line: line + 1, Reason: reason})
+			continue
+		}
 
-			result = append(result, ImpedanceDataWithIteration{
-				ImpedanceData: impedanceData,
-				Iteration:     spectrumNum,
-			})
+		if err := fn(row, progress()); err != nil {
+			return err
 		}
 	}
+}
 
-	return result, nil
+// countingReader tracks how many bytes have been read from the underlying reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
 }
 
 // spectrumData holds frequency and impedance data for a single spectrum
 type spectrumData struct {
 	frequencies []float64
 	impedances  []complex128
+	flags       QualityFlags // Union of the flags of its rows
 }
 
-// GetDataInfo returns information about the loaded data files
+// GetDataInfo returns information about comma separated data files
 func GetDataInfo(voltageFile, currentFile string) (map[string]interface{}, error) {
+	return GetDataInfoWithDialect(voltageFile, currentFile, DefaultCSVDialect)
+}
+
+// GetDataInfoWithDialect returns information about data files in the given dialect,
+// summarizing the reports produced by InspectFile
+func GetDataInfoWithDialect(voltageFile, currentFile string, dialect CSVDialect) (map[string]interface{}, error) {
 	info := make(map[string]interface{})
 
 	// Check voltage file
-	vFile, err := os.Open(voltageFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open voltage file: %w", err)
-	}
-	defer vFile.Close()
-
-	vReader := csv.NewReader(vFile)
-	vRecords, err := vReader.ReadAll()
+	vReport, err := InspectFile(voltageFile, &dialect)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read voltage CSV: %w", err)
+		return nil, fmt.Errorf("failed to inspect voltage file: %w", err)
 	}
 
 	// Check current file
-	cFile, err := os.Open(currentFile)
+	cReport, err := InspectFile(currentFile, &dialect)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open current file: %w", err)
+		return nil, fmt.Errorf("failed to inspect current file: %w", err)
 	}
-	defer cFile.Close()
 
-	cReader := csv.NewReader(cFile)
-	cRecords, err := cReader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read current CSV: %w", err)
-	}
-
-	info["voltage_samples"] = len(vRecords) - 1 // Exclude header
-	info["current_samples"] = len(cRecords) - 1 // Exclude header
+	info["voltage_samples"] = vReport.Rows
+	info["current_samples"] = cReport.Rows
 	info["voltage_file"] = voltageFile
 	info["current_file"] = currentFile
 
-	if len(vRecords) > 1 && len(cRecords) > 1 {
-		// Parse first and last timestamps to get duration
-		firstTime, _ := time.Parse(time.RFC3339Nano, vRecords[1][0])
-		lastTime, _ := time.Parse(time.RFC3339Nano, vRecords[len(vRecords)-1][0])
-		duration := lastTime.Sub(firstTime).Seconds()
-		
-		info["duration_seconds"] = duration
-		info["estimated_sample_rate"] = float64(len(vRecords)-1) / duration
+	if vReport.EstimatedSampleRate > 0 {
+		info["duration_seconds"] = vReport.DurationSeconds
+		info["estimated_sample_rate"] = vReport.EstimatedSampleRate
+	}
+
+	if problems := append(vReport.Problems, cReport.Problems...); len(problems) > 0 {
+		info["problems"] = problems
 	}
 
 	return info, nil
-}
\ No newline at end of file
+}
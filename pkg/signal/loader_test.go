@@ -0,0 +1,464 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestCSVDataLoader_StreamImpedanceFromCSV(t *testing.T) {
+	path := writeTestFile(t, "impedance.csv", `Frequency_Hz,Z_real,Z_imag,Spectrum_Number
+1000,10.5,-1.2,1
+100,12.0,-4.5,1
+10,20.1,-8.0,1
+1000,10.6,-1.3,2
+bad,row,here,2
+100,12.2,-4.6,2
+`)
+
+	loader := &CSVDataLoader{validator: NewValidator()}
+
+	var spectra []ImpedanceDataWithIteration
+	var lastProgress float64
+	err := loader.StreamImpedanceFromCSV(path, func(spectrum ImpedanceDataWithIteration, progress float64) error {
+		spectra = append(spectra, spectrum)
+		lastProgress = progress
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamImpedanceFromCSV() error = %v", err)
+	}
+
+	if len(spectra) != 2 {
+		t.Fatalf("expected 2 spectra, got %d", len(spectra))
+	}
+	if spectra[0].Iteration != 1 || len(spectra[0].ImpedanceData.Impedance) != 3 {
+		t.Errorf("unexpected first spectrum: iteration %d, %d points", spectra[0].Iteration, len(spectra[0].ImpedanceData.Impedance))
+	}
+	if spectra[1].Iteration != 2 || len(spectra[1].ImpedanceData.Impedance) != 2 {
+		t.Errorf("unexpected second spectrum: iteration %d, %d points", spectra[1].Iteration, len(spectra[1].ImpedanceData.Impedance))
+	}
+	if got := spectra[1].ImpedanceData.Impedance[1]; got != complex(12.2, -4.6) {
+		t.Errorf("unexpected impedance value %v", got)
+	}
+	if lastProgress != 1 {
+		t.Errorf("expected progress 1 after last spectrum, got %v", lastProgress)
+	}
+}
+
+func TestCSVDataLoader_LoadImpedanceFromCSV_Layouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		wantSpectra   []int
+		wantFirstFreq float64
+		wantFirstZ    complex128
+		wantFlags     QualityFlags
+		wantErr       bool
+	}{
+		{
+			name:          "standard header",
+			content:       "Frequency_Hz,Z_real,Z_imag,Spectrum_Number\n100,1,-2,1\n10,3,-4,1\n100,5,-6,2\n",
+			wantSpectra:   []int{1, 2},
+			wantFirstFreq: 100,
+			wantFirstZ:    complex(1, -2),
+		},
+		{
+			name:          "generator column order with zero-based spectra",
+			content:       "Z_real,Z_imag,Spectrum_Number,Frequency_Hz\n1,-2,0,100\n3,-4,1,100\n",
+			wantSpectra:   []int{0, 1},
+			wantFirstFreq: 100,
+			wantFirstZ:    complex(1, -2),
+		},
+		{
+			name:          "short aliases without spectrum column",
+			content:       "freq,Zre,Zim\n1000,7,-0.5\n100,8,-1.5\n",
+			wantSpectra:   []int{1},
+			wantFirstFreq: 1000,
+			wantFirstZ:    complex(7, -0.5),
+		},
+		{
+			name:          "no header",
+			content:       "50,2,-1\n5,4,-3\n",
+			wantSpectra:   []int{1},
+			wantFirstFreq: 50,
+			wantFirstZ:    complex(2, -1),
+		},
+		{
+			name:          "csv output with quality flags",
+			content:       "frequency,real,imag,quality_flags,id\n10,1,-1,2,a\n20,2,-2,18,a\n",
+			wantSpectra:   []int{1},
+			wantFirstFreq: 10,
+			wantFirstZ:    complex(1, -1),
+			wantFlags:     FlagLowSNR | FlagClipped,
+		},
+		{
+			name:    "header missing imaginary column",
+			content: "freq,Zre\n1000,7\n",
+			wantErr: true,
+		},
+	}
+
+	loader := NewDataLoader()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, "impedance.csv", tt.content)
+			spectra, err := loader.LoadImpedanceFromCSV(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadImpedanceFromCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(spectra) != len(tt.wantSpectra) {
+				t.Fatalf("expected %d spectra, got %d", len(tt.wantSpectra), len(spectra))
+			}
+			for i, want := range tt.wantSpectra {
+				if spectra[i].Iteration != want {
+					t.Errorf("spectrum %d: expected iteration %d, got %d", i, want, spectra[i].Iteration)
+				}
+			}
+			first := spectra[0].ImpedanceData
+			if first.Frequencies[0] != tt.wantFirstFreq || first.Impedance[0] != tt.wantFirstZ {
+				t.Errorf("unexpected first point: f=%v Z=%v", first.Frequencies[0], first.Impedance[0])
+			}
+			if first.QualityFlags != tt.wantFlags {
+				t.Errorf("quality flags = %v, want %v", first.QualityFlags, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestCSVDataLoader_EuropeanDialect(t *testing.T) {
+	dialect, err := ParseCSVDialect("semicolon", ",", ".", false)
+	if err != nil {
+		t.Fatalf("ParseCSVDialect() error = %v", err)
+	}
+	loader := NewDataLoaderWithDialect(dialect)
+
+	impedancePath := writeTestFile(t, "impedance.csv", "Frequency_Hz;Z_real;Z_imag;Spectrum_Number\n1.000,5;10,25;-1,5;1\n")
+	spectra, err := loader.LoadImpedanceFromCSV(impedancePath)
+	if err != nil {
+		t.Fatalf("LoadImpedanceFromCSV() error = %v", err)
+	}
+	first := spectra[0].ImpedanceData
+	if first.Frequencies[0] != 1000.5 || first.Impedance[0] != complex(10.25, -1.5) {
+		t.Errorf("unexpected point: f=%v Z=%v", first.Frequencies[0], first.Impedance[0])
+	}
+
+	signalPath := writeTestFile(t, "voltage.csv", "timestamp;time_offset;voltage\n2025-07-25T20:22:41.79809+02:00;0,000;1,25\n2025-07-25T20:22:41.79909+02:00;0,001;-0,5\n")
+	signals, err := loader.LoadSignalFromCSV(signalPath, 1000)
+	if err != nil {
+		t.Fatalf("LoadSignalFromCSV() error = %v", err)
+	}
+	if got := signals[0].Values; len(got) != 2 || got[0] != 1.25 || got[1] != -0.5 {
+		t.Errorf("unexpected values %v", got)
+	}
+}
+
+func TestParseCSVDialect_Conflicts(t *testing.T) {
+	if _, err := ParseCSVDialect(",", ",", "", false); err == nil {
+		t.Errorf("expected error when decimal separator equals delimiter")
+	}
+	if _, err := ParseCSVDialect(";;", ".", "", false); err == nil {
+		t.Errorf("expected error for multi-character delimiter")
+	}
+}
+
+func TestCSVDataLoader_ParseModes(t *testing.T) {
+	content := "timestamp,time_offset,voltage\n" +
+		"2025-07-25T20:22:41.000+02:00,0.000,1.0\n" +
+		"2025-07-25T20:22:41.001+02:00,0.001,oops\n" +
+		"not-a-time,0.002,3.0\n" +
+		"2025-07-25T20:22:41.003+02:00,0.003,4.0\n"
+	path := writeTestFile(t, "voltage.csv", content)
+
+	tests := []struct {
+		name         string
+		mode         ParseMode
+		wantErr      bool
+		wantValues   []float64
+		wantSkipped  int
+		wantRepaired int
+	}{
+		{name: "default is strict", mode: ParseModeDefault, wantErr: true},
+		{name: "strict", mode: ParseModeStrict, wantErr: true},
+		{name: "lenient", mode: ParseModeLenient, wantValues: []float64{1, 4}, wantSkipped: 2},
+		{name: "repair", mode: ParseModeRepair, wantValues: []float64{1, 2, 3, 4}, wantRepaired: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, ParseMode: tt.mode})
+			signals, err := loader.LoadSignalFromCSV(path, 1000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSignalFromCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "line 3") {
+					t.Errorf("expected error to name line 3, got %v", err)
+				}
+				return
+			}
+
+			if got := signals[0].Values; !reflect.DeepEqual(got, tt.wantValues) {
+				t.Errorf("expected values %v, got %v", tt.wantValues, got)
+			}
+			if signals[0].RepairedSamples != tt.wantRepaired {
+				t.Errorf("signal has %d repaired samples, want %d", signals[0].RepairedSamples, tt.wantRepaired)
+			}
+			reports := loader.ParseReports()
+			if len(reports) != 1 || len(reports[0].Skipped) != tt.wantSkipped || len(reports[0].Repaired) != tt.wantRepaired {
+				t.Errorf("unexpected parse reports %+v", reports)
+			}
+		})
+	}
+}
+
+func TestCSVDataLoader_ImpedanceStrictMode(t *testing.T) {
+	path := writeTestFile(t, "impedance.csv", "freq,Zre,Zim\n1000,5,-1\nbad,5,-1\n")
+
+	if _, err := NewDataLoader().LoadImpedanceFromCSV(path); err != nil {
+		t.Fatalf("default mode should skip bad impedance rows, got %v", err)
+	}
+
+	strict := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, ParseMode: ParseModeStrict})
+	if _, err := strict.LoadImpedanceFromCSV(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected strict mode to fail on line 3, got %v", err)
+	}
+}
+
+func TestCSVDataLoader_TimeWindow(t *testing.T) {
+	content := "timestamp,time_offset,voltage\n" +
+		"2025-07-25T20:22:41.000Z,0.000,1.0\n" +
+		"2025-07-25T20:22:41.001Z,0.001,2.0\n" +
+		"2025-07-25T20:22:41.002Z,0.002,3.0\n" +
+		"2025-07-25T20:22:41.003Z,0.003,4.0\n"
+	path := writeTestFile(t, "voltage.csv", content)
+
+	tests := []struct {
+		name       string
+		from, to   string
+		wantValues []float64
+		wantErr    bool
+	}{
+		{name: "offsets", from: "1ms", to: "0.003", wantValues: []float64{2, 3}},
+		{name: "open end", from: "2ms", wantValues: []float64{3, 4}},
+		{name: "timestamps", from: "2025-07-25T20:22:41.001Z", to: "2025-07-25T20:22:41.002Z", wantValues: []float64{2}},
+		{name: "outside recording", from: "1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ParseTimeWindow(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("ParseTimeWindow() error = %v", err)
+			}
+			loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, Window: window})
+			signals, err := loader.LoadSignalFromCSV(path, 1000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSignalFromCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := signals[0].Values; !reflect.DeepEqual(got, tt.wantValues) {
+				t.Errorf("expected values %v, got %v", tt.wantValues, got)
+			}
+		})
+	}
+
+	if _, err := ParseTimeWindow("10s", "5s"); err == nil {
+		t.Errorf("expected error for window ending before it starts")
+	}
+	if _, err := ParseTimeWindow("yesterday", ""); err == nil {
+		t.Errorf("expected error for unparseable bound")
+	}
+}
+
+func TestCSVDataLoader_SampleTimes(t *testing.T) {
+	path := writeTestFile(t, "voltage.csv", "timestamp,time_offset,voltage\n"+
+		"2025-07-25T20:22:41.0000Z,0.0000,1.0\n"+
+		"2025-07-25T20:22:41.0012Z,0.0012,2.0\n"+
+		"2025-07-25T20:22:41.0019Z,0.0019,3.0\n"+
+		"2025-07-25T20:22:41.0031Z,0.0031,4.0\n")
+
+	for _, keep := range []bool{false, true} {
+		loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, SampleTimes: keep})
+		signals, err := loader.LoadSignalFromCSV(path, 2)
+		if err != nil {
+			t.Fatalf("LoadSignalFromCSV() error = %v", err)
+		}
+		for i, sig := range signals {
+			var wantTimes []float64
+			if keep {
+				wantTimes = []float64{0, 0.0012} // Second chunk starts at 1.9 ms
+			}
+			if len(sig.SampleTimes) != len(wantTimes) {
+				t.Fatalf("SampleTimes=%v: chunk %d has sample times %v, want %v", keep, i, sig.SampleTimes, wantTimes)
+			}
+			for j := range wantTimes {
+				if math.Abs(sig.SampleTimes[j]-wantTimes[j]) > 1e-9 {
+					t.Errorf("SampleTimes=%v: chunk %d has sample times %v, want %v", keep, i, sig.SampleTimes, wantTimes)
+				}
+			}
+		}
+	}
+}
+
+func TestCSVDataLoader_ChunkSize(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("timestamp,time_offset,voltage\n")
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&b, "2025-07-25T20:22:41.%03dZ,%.3f,%d\n", i*10, float64(i)/100, i)
+	}
+	path := writeTestFile(t, "voltage.csv", b.String())
+
+	tests := []struct {
+		name      string
+		options   LoaderOptions
+		wantSizes []int
+	}{
+		{name: "one second by default", wantSizes: []int{25}},
+		{name: "samples", options: LoaderOptions{ChunkSamples: 10}, wantSizes: []int{10, 10, 5}},
+		{name: "duration", options: LoaderOptions{ChunkDuration: 80 * time.Millisecond}, wantSizes: []int{8, 8, 8, 1}},
+		{name: "samples win", options: LoaderOptions{ChunkSamples: 20, ChunkDuration: 80 * time.Millisecond}, wantSizes: []int{20, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Dialect = DefaultCSVDialect
+			signals, err := NewDataLoaderWithOptions(tt.options).LoadSignalFromCSV(path, 100)
+			if err != nil {
+				t.Fatalf("LoadSignalFromCSV() error = %v", err)
+			}
+			sizes := make([]int, len(signals))
+			for i, sig := range signals {
+				sizes[i] = len(sig.Values)
+			}
+			if !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("chunk sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+		})
+	}
+}
+
+func TestCSVDataLoader_SplitsAtGaps(t *testing.T) {
+	path := writeTestFile(t, "voltage.csv", "timestamp,time_offset,voltage\n"+
+		"2025-07-25T20:22:41.000Z,0.000,1.0\n"+
+		"2025-07-25T20:22:41.001Z,0.001,2.0\n"+
+		"2025-07-25T20:22:41.002Z,0.002,3.0\n"+
+		"2025-07-25T20:22:41.010Z,0.010,4.0\n"+
+		"2025-07-25T20:22:41.011Z,0.011,5.0\n")
+
+	tests := []struct {
+		name      string
+		threshold float64
+		wantSizes []int
+		wantGaps  []time.Duration
+	}{
+		{name: "concatenated", threshold: 0, wantSizes: []int{5}, wantGaps: []time.Duration{0}},
+		{name: "split at dropout", threshold: 1.5, wantSizes: []int{3, 2}, wantGaps: []time.Duration{7 * time.Millisecond, 0}},
+		{name: "dropout below threshold", threshold: 10, wantSizes: []int{5}, wantGaps: []time.Duration{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, GapThreshold: tt.threshold})
+			signals, err := loader.LoadSignalFromCSV(path, 1000)
+			if err != nil {
+				t.Fatalf("LoadSignalFromCSV() error = %v", err)
+			}
+			if len(signals) != len(tt.wantSizes) {
+				t.Fatalf("expected %d chunks, got %d", len(tt.wantSizes), len(signals))
+			}
+			for i, sig := range signals {
+				if len(sig.Values) != tt.wantSizes[i] || sig.Gap != tt.wantGaps[i] {
+					t.Errorf("chunk %d: %d samples with gap %v, want %d with gap %v", i, len(sig.Values), sig.Gap, tt.wantSizes[i], tt.wantGaps[i])
+				}
+			}
+			if len(signals) == 2 && !signals[1].Timestamp.Equal(time.Date(2025, 7, 25, 20, 22, 41, 10e6, time.UTC)) {
+				t.Errorf("chunk after the dropout starts at %v", signals[1].Timestamp)
+			}
+		})
+	}
+}
+
+func TestCSVDataLoader_SplitsBothFilesAtGaps(t *testing.T) {
+	row := func(ms int, value float64) string {
+		return fmt.Sprintf("2025-07-25T20:22:41.%03dZ,%.3f,%g\n", ms, float64(ms)/1000, value)
+	}
+	voltage := "timestamp,time_offset,voltage\n"
+	for ms := range 10 {
+		voltage += row(ms, float64(ms))
+	}
+	// Only the current logger drops out, after its second sample
+	current := "timestamp,time_offset,current\n"
+	for _, ms := range []int{0, 1, 6, 7, 8, 9} {
+		current += row(ms, float64(ms)/10)
+	}
+
+	loader := NewDataLoaderWithOptions(LoaderOptions{Dialect: DefaultCSVDialect, GapThreshold: 1.5})
+	voltageSignals, currentSignals, err := loader.LoadVoltageAndCurrentFromCSV(writeTestFile(t, "voltage.csv", voltage), writeTestFile(t, "current.csv", current), 1000)
+	if err != nil {
+		t.Fatalf("LoadVoltageAndCurrentFromCSV() error = %v", err)
+	}
+
+	// The voltage samples at 2 to 5 ms have no current and are dropped
+	wantSizes := []int{2, 4}
+	wantGaps := []time.Duration{4 * time.Millisecond, 0}
+	wantFirst := []float64{0, 6}
+	for name, signals := range map[string][]Signal{"voltage": voltageSignals, "current": currentSignals} {
+		if len(signals) != len(wantSizes) {
+			t.Fatalf("%s: expected %d chunks, got %d", name, len(wantSizes), len(signals))
+		}
+		for i, sig := range signals {
+			if len(sig.Values) != wantSizes[i] || sig.Gap != wantGaps[i] {
+				t.Errorf("%s chunk %d: %d samples with gap %v, want %d with gap %v", name, i, len(sig.Values), sig.Gap, wantSizes[i], wantGaps[i])
+			}
+		}
+	}
+	for i := range voltageSignals {
+		if !voltageSignals[i].Timestamp.Equal(currentSignals[i].Timestamp) || voltageSignals[i].Values[0] != wantFirst[i] || currentSignals[i].Values[0] != wantFirst[i]/10 {
+			t.Errorf("chunk %d: voltage %v at %v, current %v at %v, want both from %g ms",
+				i, voltageSignals[i].Values, voltageSignals[i].Timestamp, currentSignals[i].Values, currentSignals[i].Timestamp, wantFirst[i])
+		}
+	}
+
+	// Files without a common time are rejected
+	late := "timestamp,time_offset,current\n" + row(500, 1) + row(501, 1)
+	if _, _, err := loader.LoadVoltageAndCurrentFromCSV(writeTestFile(t, "voltage2.csv", voltage), writeTestFile(t, "late.csv", late), 1000); err == nil {
+		t.Error("LoadVoltageAndCurrentFromCSV() accepted files recorded at different times")
+	}
+}
+
+func TestCSVDataLoader_SkipsMetadataComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generated.csv")
+	content := "# circuit: simple\n# spectra: 1\nZ_real,Z_imag,Spectrum_Number,Frequency_Hz\n10,-1,0,100\n11,-2,0,10\n# finished: 2024-01-01T00:00:00Z\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spectra, err := NewDataLoader().LoadImpedanceFromCSV(path)
+	if err != nil {
+		t.Fatalf("LoadImpedanceFromCSV() error = %v", err)
+	}
+	if len(spectra) != 1 || len(spectra[0].ImpedanceData.Frequencies) != 2 {
+		t.Fatalf("loaded %d spectra, want 1 spectrum with 2 points", len(spectra))
+	}
+}
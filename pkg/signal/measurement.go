@@ -0,0 +1,30 @@
+package signal
+
+// Measurement is the document the file outputs write for one spectrum: its
+// points together with the identity, metadata, signal quality, features and
+// prediction of the spectrum
+type Measurement struct {
+	Identity
+	Metadata     Metadata          `json:"metadata,omitzero"`
+	Quality      *ChunkQuality     `json:"quality,omitempty"`
+	QualityFlags QualityFlags      `json:"quality_flags,omitempty"`
+	Stationarity *Stationarity     `json:"stationarity,omitempty"`
+	Features     *SpectrumFeatures `json:"features,omitempty"`
+	Prediction   *Prediction       `json:"prediction,omitempty"`
+	Points       EISMeasurement    `json:"points"`
+}
+
+// NewMeasurement wraps the points of data, with magnitude and phase if
+// withBode is set, see EISMeasurementFromData
+func NewMeasurement(data ImpedanceData, withBode bool) Measurement {
+	return Measurement{
+		Identity:     data.Identity,
+		Metadata:     data.Metadata,
+		Quality:      data.Quality,
+		QualityFlags: data.QualityFlags,
+		Stationarity: data.Stationarity,
+		Features:     data.Features,
+		Prediction:   data.Prediction,
+		Points:       EISMeasurementFromData(data, withBode),
+	}
+}
@@ -0,0 +1,103 @@
+package signal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// Units of the values carried by signals and impedance data
+const (
+	UnitVolt   = "V"
+	UnitAmpere = "A"
+	UnitOhm    = "Ω"
+)
+
+// Metadata annotates measured values so downstream consumers can interpret
+// them without out-of-band information
+type Metadata struct {
+	Unit         string            `json:"unit,omitempty"`
+	Channel      string            `json:"channel,omitempty"`
+	Probe        string            `json:"probe,omitempty"`
+	DeviceSerial string            `json:"device_serial,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// IsZero reports whether no annotation is set
+func (m Metadata) IsZero() bool {
+	return m.Unit == "" && m.Channel == "" && m.Probe == "" && m.DeviceSerial == "" && len(m.Labels) == 0
+}
+
+// Merge returns a copy of m overridden by the non-empty fields of other.
+// Labels are combined, with other winning on duplicate keys.
+func (m Metadata) Merge(other Metadata) Metadata {
+	result := m
+	if other.Unit != "" {
+		result.Unit = other.Unit
+	}
+	if other.Channel != "" {
+		result.Channel = other.Channel
+	}
+	if other.Probe != "" {
+		result.Probe = other.Probe
+	}
+	if other.DeviceSerial != "" {
+		result.DeviceSerial = other.DeviceSerial
+	}
+	if len(m.Labels)+len(other.Labels) > 0 {
+		result.Labels = make(map[string]string, len(m.Labels)+len(other.Labels))
+		for k, v := range m.Labels {
+			result.Labels[k] = v
+		}
+		for k, v := range other.Labels {
+			result.Labels[k] = v
+		}
+	}
+	return result
+}
+
+// WithUnit returns a copy of m with the given unit
+func (m Metadata) WithUnit(unit string) Metadata {
+	return m.Merge(Metadata{Unit: unit})
+}
+
+// Columns returns the metadata as ordered CSV column names and values: unit,
+// channel, probe, device_serial, then labels sorted by key
+func (m Metadata) Columns() ([]string, []string) {
+	names := []string{"unit", "channel", "probe", "device_serial"}
+	values := []string{m.Unit, m.Channel, m.Probe, m.DeviceSerial}
+
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		names = append(names, k)
+		values = append(values, m.Labels[k])
+	}
+	return names, values
+}
+
+// ParseLabels parses free-form labels given as "key=value,key=value"
+func ParseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, config.NewValidationError("Labels", fmt.Sprintf("label %q must have the form key=value", pair))
+		}
+		labels[key] = strings.TrimSpace(val)
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
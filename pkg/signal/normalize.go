@@ -0,0 +1,79 @@
+package signal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// DuplicateFrequencyTolerance is the relative difference below which two
+// frequencies are the same bin
+const DuplicateFrequencyTolerance = 1e-9
+
+// NormalizeReport describes what NormalizeFrequencies changed
+type NormalizeReport struct {
+	Reordered bool // The points were not in the requested order
+	Merged    int  // Number of points merged into a neighbour of the same frequency
+}
+
+// NormalizeFrequencies returns a copy of data sorted by frequency in order,
+// ascending unless OrderDescending is given, with the points of duplicate
+// frequency bins merged into one carrying their mean frequency and mean
+// impedance. Magnitude and phase are recomputed. Frequencies that are not
+// finite and non-negative, or that do not match the impedance, are
+// rejected, so the result is strictly monotonic.
+func NormalizeFrequencies(data ImpedanceData, order FrequencyOrder) (ImpedanceData, NormalizeReport, error) {
+	var report NormalizeReport
+	if len(data.Frequencies) != len(data.Impedance) {
+		return ImpedanceData{}, report, config.NewValidationError("Frequencies",
+			fmt.Sprintf("%d frequencies for %d impedance values", len(data.Frequencies), len(data.Impedance)))
+	}
+	for i, f := range data.Frequencies {
+		if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+			return ImpedanceData{}, report, config.NewValidationError("Frequencies",
+				fmt.Sprintf("frequency %g at index %d is not finite and non-negative", f, i))
+		}
+	}
+
+	descending := order == OrderDescending
+	for i := 1; i < len(data.Frequencies); i++ {
+		if (data.Frequencies[i] < data.Frequencies[i-1]) != descending && data.Frequencies[i] != data.Frequencies[i-1] {
+			report.Reordered = true
+			break
+		}
+	}
+	frequencies, impedance := sortByFrequency(data.Frequencies, data.Impedance, descending)
+
+	// Sorting puts duplicates next to each other
+	var mergedFrequencies []float64
+	var mergedImpedance []complex128
+	for start := 0; start < len(frequencies); {
+		end := start + 1
+		for end < len(frequencies) && sameFrequency(frequencies[start], frequencies[end]) {
+			end++
+		}
+		var frequency float64
+		var z complex128
+		for i := start; i < end; i++ {
+			frequency += frequencies[i]
+			z += impedance[i]
+		}
+		count := float64(end - start)
+		mergedFrequencies = append(mergedFrequencies, frequency/count)
+		mergedImpedance = append(mergedImpedance, z/complex(count, 0))
+		report.Merged += end - start - 1
+		start = end
+	}
+
+	result := data
+	result.Frequencies = mergedFrequencies
+	result.Impedance = mergedImpedance
+	result.Magnitude, result.Phase = result.CalculateMagnitudePhase()
+	return result, report, nil
+}
+
+// sameFrequency reports whether a and b are the same frequency bin
+func sameFrequency(a, b float64) bool {
+	return math.Abs(a-b) <= DuplicateFrequencyTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
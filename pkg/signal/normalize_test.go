@@ -0,0 +1,89 @@
+package signal
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNormalizeFrequencies(t *testing.T) {
+	tests := []struct {
+		name            string
+		frequencies     []float64
+		impedance       []complex128
+		order           FrequencyOrder
+		wantFrequencies []float64
+		wantImpedance   []complex128
+		wantReport      NormalizeReport
+		wantErr         bool
+	}{
+		{
+			name:            "already ascending",
+			frequencies:     []float64{1, 10, 100},
+			impedance:       []complex128{3, 2, 1},
+			wantFrequencies: []float64{1, 10, 100},
+			wantImpedance:   []complex128{3, 2, 1},
+		},
+		{
+			name:            "vendor descending to ascending",
+			frequencies:     []float64{100, 10, 1},
+			impedance:       []complex128{1, 2, 3},
+			order:           OrderAscending,
+			wantFrequencies: []float64{1, 10, 100},
+			wantImpedance:   []complex128{3, 2, 1},
+			wantReport:      NormalizeReport{Reordered: true},
+		},
+		{
+			name:            "duplicate bins averaged",
+			frequencies:     []float64{10, 100, 10 * (1 + 1e-12), 1},
+			impedance:       []complex128{complex(2, -2), 1, complex(4, -4), 5},
+			order:           OrderDescending,
+			wantFrequencies: []float64{100, 10, 1},
+			wantImpedance:   []complex128{1, complex(3, -3), 5},
+			wantReport:      NormalizeReport{Reordered: true, Merged: 1},
+		},
+		{
+			name:        "not finite",
+			frequencies: []float64{1, math.NaN()},
+			impedance:   []complex128{1, 2},
+			wantErr:     true,
+		},
+		{
+			name:        "length mismatch",
+			frequencies: []float64{1},
+			impedance:   []complex128{1, 2},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := NewImpedanceData(time.Unix(0, 0), tt.frequencies, tt.impedance)
+			got, report, err := NormalizeFrequencies(data, tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeFrequencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got.Frequencies) != len(tt.wantFrequencies) {
+				t.Fatalf("frequencies = %v, want %v", got.Frequencies, tt.wantFrequencies)
+			}
+			for i, f := range tt.wantFrequencies {
+				if math.Abs(got.Frequencies[i]-f) > 1e-9*f {
+					t.Errorf("frequencies = %v, want %v", got.Frequencies, tt.wantFrequencies)
+				}
+			}
+			if !slices.Equal(got.Impedance, tt.wantImpedance) {
+				t.Errorf("impedance = %v, want %v", got.Impedance, tt.wantImpedance)
+			}
+			if len(got.Magnitude) != len(got.Impedance) || got.ID != data.ID {
+				t.Errorf("magnitude or identity not carried over: %+v", got)
+			}
+			if report != tt.wantReport {
+				t.Errorf("report = %+v, want %+v", report, tt.wantReport)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package signal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/clock"
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// ParseMode controls how data loaders react to rows that cannot be parsed
+type ParseMode string
+
+const (
+	// ParseModeDefault keeps each loader's historical behaviour: strict for
+	// time-domain signals, lenient for impedance files
+	ParseModeDefault ParseMode = ""
+	// ParseModeStrict fails on the first bad row, reporting its line number
+	ParseModeStrict ParseMode = "strict"
+	// ParseModeLenient skips bad rows and records them in the parse report
+	ParseModeLenient ParseMode = "lenient"
+	// ParseModeRepair replaces bad samples by linear interpolation between
+	// their valid neighbours. Impedance rows cannot be interpolated and are
+	// skipped as in lenient mode.
+	ParseModeRepair ParseMode = "repair"
+)
+
+// ParseParseMode converts a textual mode; an empty string selects the default
+func ParseParseMode(value string) (ParseMode, error) {
+	mode := ParseMode(strings.ToLower(strings.TrimSpace(value)))
+	switch mode {
+	case ParseModeDefault, ParseModeStrict, ParseModeLenient, ParseModeRepair:
+		return mode, nil
+	default:
+		return "", config.NewValidationError("ParseMode",
+			fmt.Sprintf("unknown parse mode %q, must be strict, lenient or repair", value))
+	}
+}
+
+// orDefault resolves ParseModeDefault to the given loader-specific mode
+func (m ParseMode) orDefault(fallback ParseMode) ParseMode {
+	if m == ParseModeDefault {
+		return fallback
+	}
+	return m
+}
+
+// LoaderOptions configures a CSV data loader
+type LoaderOptions struct {
+	Dialect   CSVDialect
+	ParseMode ParseMode
+	Window    TimeWindow  // Portion of time-domain recordings to load; impedance files are not windowed
+	Clock     clock.Clock // Timestamps impedance spectra, which carry no time of their own; nil uses the wall clock
+	// SampleTimes keeps the timestamp of every sample in Signal.SampleTimes
+	// for estimators that handle irregular sampling
+	SampleTimes bool
+	// GapThreshold is the time step, in sample periods, above which samples
+	// are taken to be separated by an acquisition dropout and chunks are
+	// split there (0 = never split)
+	GapThreshold float64
+	// ChunkSamples, or else ChunkDuration at the sample rate of the file,
+	// sets the number of samples per loaded signal (both 0 = 1 second)
+	ChunkSamples  int
+	ChunkDuration time.Duration
+}
+
+// RowIssue describes a row that was skipped or repaired while loading
+type RowIssue struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// ParseReport summarizes how the rows of one file were handled
+type ParseReport struct {
+	File     string     `json:"file"`
+	Mode     ParseMode  `json:"mode"`
+	Rows     int        `json:"rows"`
+	Skipped  []RowIssue `json:"skipped,omitempty"`
+	Repaired []RowIssue `json:"repaired,omitempty"`
+}
+
+// Clean reports whether every row parsed without intervention
+func (r ParseReport) Clean() bool {
+	return len(r.Skipped) == 0 && len(r.Repaired) == 0
+}
+
+// String renders a one-line summary including the first few affected lines
+func (r ParseReport) String() string {
+	summary := fmt.Sprintf("%s (%s): %d rows, %d skipped, %d repaired",
+		r.File, r.Mode, r.Rows, len(r.Skipped), len(r.Repaired))
+
+	issues := append(append([]RowIssue{}, r.Skipped...), r.Repaired...)
+	const maxListed = 3
+	for i, issue := range issues {
+		if i == maxListed {
+			summary += fmt.Sprintf("; ... %d more", len(issues)-maxListed)
+			break
+		}
+		summary += fmt.Sprintf("; line %d: %s", issue.Line, issue.Reason)
+	}
+	return summary
+}
+
+// rowError builds the error returned for a bad row in strict mode
+func rowError(filename string, line int, reason string) error {
+	return config.NewValidationError("Row", fmt.Sprintf("%s line %d: %s", filename, line, reason))
+}
@@ -0,0 +1,10 @@
+package signal
+
+// Prediction is the output of a model run on the features of a spectrum,
+// e.g. a health state classification or an anomaly score
+type Prediction struct {
+	Model  string    `json:"model"`           // Model file name
+	Class  int       `json:"class"`           // Index of the highest score
+	Label  string    `json:"label,omitempty"` // Name of Class, if the model's classes are named
+	Scores []float64 `json:"scores"`          // Raw model outputs; a single score for anomaly detectors
+}
@@ -0,0 +1,21 @@
+package signal
+
+// Quality describes the acquisition quality of one channel of a chunk
+type Quality struct {
+	RMS             float64 `json:"rms"`              // Root mean square of the AC component
+	CrestFactor     float64 `json:"crest_factor"`     // Peak over RMS of the AC component (√2 for a sine)
+	ClippingPercent float64 `json:"clipping_percent"` // Share of samples stuck at the signal's extremes
+	SNRdB           float64 `json:"snr_db"`           // Excitation lines over the remaining spectrum
+	DCOffset        float64 `json:"dc_offset"`        // Mean value
+
+	// Share of the AC power in the top tenth of the band below Nyquist, where
+	// aliases of content above Nyquist appear
+	NyquistPowerPercent float64 `json:"nyquist_power_percent"`
+}
+
+// ChunkQuality holds the quality of the voltage and current signals an
+// impedance spectrum was computed from
+type ChunkQuality struct {
+	Voltage Quality `json:"voltage"`
+	Current Quality `json:"current"`
+}
@@ -0,0 +1,37 @@
+package signal
+
+import (
+	"fmt"
+)
+
+// RawChunk holds the time-domain voltage and current signals a spectrum was
+// computed from, linked to the spectrum by its Identity
+type RawChunk struct {
+	Identity
+	Voltage Signal `json:"voltage"`
+	Current Signal `json:"current"`
+}
+
+// Downsample returns the signal decimated by factor, averaging each block of
+// factor samples to limit aliasing. A trailing partial block is averaged too.
+func (s Signal) Downsample(factor int) (Signal, error) {
+	if factor < 1 {
+		return Signal{}, fmt.Errorf("downsampling factor must be at least 1, got %d", factor)
+	}
+	if factor == 1 {
+		return s, nil
+	}
+
+	result := s
+	result.SampleRate = s.SampleRate / float64(factor)
+	result.Values = make([]float64, 0, (len(s.Values)+factor-1)/factor)
+	for start := 0; start < len(s.Values); start += factor {
+		end := min(start+factor, len(s.Values))
+		sum := 0.0
+		for _, v := range s.Values[start:end] {
+			sum += v
+		}
+		result.Values = append(result.Values, sum/float64(end-start))
+	}
+	return result, nil
+}
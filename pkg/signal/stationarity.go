@@ -0,0 +1,10 @@
+package signal
+
+// Stationarity describes how much the impedance changed within the chunk a
+// spectrum was computed from, from the spectra of consecutive sub-windows
+type Stationarity struct {
+	Windows               int     `json:"windows"`                 // Sub-windows the chunk was split into
+	MagnitudeDriftPercent float64 `json:"magnitude_drift_percent"` // Largest |Z| range across sub-windows relative to its mean
+	PhaseDriftDeg         float64 `json:"phase_drift_deg"`         // Largest phase range across sub-windows
+	Stationary            bool    `json:"stationary"`              // Both drifts within their limits
+}
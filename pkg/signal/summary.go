@@ -0,0 +1,95 @@
+package signal
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// BatchSummary condenses a batch for consumers that do not parse every
+// spectrum: the spread of |Z| per frequency and the trend of the charge
+// transfer resistance R_ct across the iterations of the batch
+type BatchSummary struct {
+	Spectra int `json:"spectra"`
+	// Per frequency statistics, present only if all spectra share one grid
+	Frequencies   []float64 `json:"frequencies,omitempty"`
+	MeanMagnitude []float64 `json:"mean_magnitude,omitempty"` // Mean |Z| in ohms
+	StdMagnitude  []float64 `json:"std_magnitude,omitempty"`  // Standard deviation of |Z| in ohms
+	// R_ct is the semicircle diameter if features were extracted and the
+	// difference of Re Z at the lowest and highest frequency otherwise
+	Rct      []float64 `json:"rct"`       // Per spectrum, in batch order
+	RctMean  float64   `json:"rct_mean"`  // Mean R_ct in ohms
+	RctSlope float64   `json:"rct_slope"` // Least squares R_ct change in ohms per iteration
+}
+
+// Summarize computes the summary of the batch in either schema; an empty
+// batch has none
+func (b ImpedanceBatch) Summarize() *BatchSummary {
+	b = b.ExpandGrid()
+	if len(b.Spectra) == 0 {
+		return nil
+	}
+	summary := &BatchSummary{Spectra: len(b.Spectra), Rct: make([]float64, len(b.Spectra))}
+
+	// |Z| statistics per frequency on a common grid
+	grid := b.Spectra[0].ImpedanceData.Frequencies
+	shared := len(grid) > 0
+	for _, spectrum := range b.Spectra {
+		shared = shared && equalGrid(grid, spectrum.ImpedanceData.Frequencies) && len(spectrum.ImpedanceData.Impedance) == len(grid)
+	}
+	if shared {
+		summary.Frequencies = grid
+		summary.MeanMagnitude = make([]float64, len(grid))
+		summary.StdMagnitude = make([]float64, len(grid))
+		for i := range grid {
+			var sum, sumSquares float64
+			for _, spectrum := range b.Spectra {
+				magnitude := cmplx.Abs(spectrum.ImpedanceData.Impedance[i])
+				sum += magnitude
+				sumSquares += magnitude * magnitude
+			}
+			mean := sum / float64(len(b.Spectra))
+			summary.MeanMagnitude[i] = mean
+			summary.StdMagnitude[i] = math.Sqrt(math.Max(sumSquares/float64(len(b.Spectra))-mean*mean, 0))
+		}
+	}
+
+	// R_ct trend as the least squares slope over the iterations
+	var sumX, sumY, sumXY, sumXX float64
+	for i, spectrum := range b.Spectra {
+		rct := estimateRct(spectrum.ImpedanceData)
+		summary.Rct[i] = rct
+		x := float64(spectrum.Iteration)
+		sumX += x
+		sumY += rct
+		sumXY += x * rct
+		sumXX += x * x
+	}
+	n := float64(len(b.Spectra))
+	summary.RctMean = sumY / n
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		summary.RctSlope = (n*sumXY - sumX*sumY) / denominator
+	}
+	return summary
+}
+
+// estimateRct returns the semicircle diameter of the spectrum's features or
+// the difference of Re Z at its lowest and highest frequency
+func estimateRct(data ImpedanceData) float64 {
+	if data.Features != nil {
+		return data.Features.SemicircleDiameter
+	}
+	points := min(len(data.Frequencies), len(data.Impedance))
+	if points == 0 {
+		return 0
+	}
+	low, high := 0, 0
+	for i := 1; i < points; i++ {
+		if data.Frequencies[i] < data.Frequencies[low] {
+			low = i
+		}
+		if data.Frequencies[i] > data.Frequencies[high] {
+			high = i
+		}
+	}
+	return real(data.Impedance[low]) - real(data.Impedance[high])
+}
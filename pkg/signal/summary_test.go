@@ -0,0 +1,90 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestImpedanceBatch_Summarize(t *testing.T) {
+	spectrum := func(iteration int, rct float64) ImpedanceDataWithIteration {
+		return ImpedanceDataWithIteration{
+			ImpedanceData: ImpedanceData{
+				Frequencies: []float64{1000, 1},
+				Impedance:   []complex128{complex(10, 0), complex(10+rct, 0)},
+			},
+			Iteration: iteration,
+		}
+	}
+	batch := ImpedanceBatch{Spectra: []ImpedanceDataWithIteration{spectrum(1, 100), spectrum(2, 110), spectrum(3, 120)}}
+
+	summary := batch.Summarize()
+	if summary == nil {
+		t.Fatal("Summarize() = nil")
+	}
+	if summary.Spectra != 3 {
+		t.Errorf("Spectra = %d, want 3", summary.Spectra)
+	}
+	if got := summary.MeanMagnitude; len(got) != 2 || got[0] != 10 || got[1] != 120 {
+		t.Errorf("MeanMagnitude = %v, want [10 120]", got)
+	}
+	if got := summary.StdMagnitude[1]; math.Abs(got-math.Sqrt(200.0/3)) > 1e-9 {
+		t.Errorf("StdMagnitude[1] = %g, want %g", got, math.Sqrt(200.0/3))
+	}
+	if summary.RctMean != 110 || summary.RctSlope != 10 {
+		t.Errorf("RctMean, RctSlope = %g, %g, want 110, 10", summary.RctMean, summary.RctSlope)
+	}
+
+	// The compact schema summarizes like the expanded one
+	if compact := batch.CompactGrid().Summarize(); compact.RctSlope != summary.RctSlope || len(compact.MeanMagnitude) != 2 {
+		t.Errorf("compact Summarize() = %+v, want %+v", compact, summary)
+	}
+
+	// Extracted features take precedence, differing grids skip the statistics
+	mixed := ImpedanceBatch{Spectra: []ImpedanceDataWithIteration{spectrum(1, 100), spectrum(2, 110)}}
+	mixed.Spectra[1].ImpedanceData.Frequencies = []float64{2000, 1}
+	mixed.Spectra[0].ImpedanceData.Features = &SpectrumFeatures{SemicircleDiameter: 90}
+	summary = mixed.Summarize()
+	if summary.MeanMagnitude != nil {
+		t.Errorf("MeanMagnitude = %v for differing grids, want none", summary.MeanMagnitude)
+	}
+	if summary.Rct[0] != 90 || summary.Rct[1] != 110 {
+		t.Errorf("Rct = %v, want [90 110]", summary.Rct)
+	}
+
+	if (ImpedanceBatch{}).Summarize() != nil {
+		t.Error("Summarize() of an empty batch is not nil")
+	}
+}
+
+func TestImpedanceEncoder_Summary(t *testing.T) {
+	batch := ImpedanceBatch{BatchID: "batch_1_1", Spectra: []ImpedanceDataWithIteration{{
+		ImpedanceData: ImpedanceData{Frequencies: []float64{10, 1}, Impedance: []complex128{5, 15}},
+		Iteration:     1,
+	}}}
+
+	var buf bytes.Buffer
+	encoder := NewImpedanceEncoder(&buf)
+	encoder.SetOptions(EncodeOptions{Summary: true})
+	if err := encoder.EncodeBatch(batch); err != nil {
+		t.Fatalf("EncodeBatch() error = %v", err)
+	}
+	var decoded ImpedanceBatch
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+	if decoded.Summary == nil || decoded.Summary.RctMean != 10 {
+		t.Errorf("decoded summary = %+v, want R_ct mean 10", decoded.Summary)
+	}
+
+	// The summary precedes the spectra and matches encoding/json
+	batch.Summary = batch.Summarize()
+	want, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("EncodeBatch() = %s, want %s", buf.Bytes(), want)
+	}
+}
@@ -1,16 +1,24 @@
 package signal
 
 import (
+	"bytes"
 	"encoding/json"
+	"math"
 	"math/cmplx"
 	"time"
+
+	"github.com/adam/masterapp/pkg/config"
 )
 
 // Signal represents a time-domain signal with associated metadata
 type Signal struct {
-	Timestamp  time.Time `json:"timestamp"`
-	Values     []float64 `json:"values"`
-	SampleRate float64   `json:"sample_rate"`
+	Timestamp       time.Time     `json:"timestamp"`
+	Values          []float64     `json:"values"`
+	SampleRate      float64       `json:"sample_rate"`
+	Metadata        Metadata      `json:"metadata,omitzero"`
+	RepairedSamples int           `json:"repaired_samples,omitempty"` // Values interpolated by ParseModeRepair
+	SampleTimes     []float64     `json:"sample_times,omitempty"`     // Seconds since Timestamp of every value, kept for irregularly sampled files
+	Gap             time.Duration `json:"gap,omitempty"`              // Acquisition dropout that ended the chunk early
 }
 
 // DataPoint represents a single measurement point
@@ -36,15 +44,12 @@ type ComplexSignal struct {
 // MarshalJSON custom JSON marshaling for ComplexSignal
 func (cs ComplexSignal) MarshalJSON() ([]byte, error) {
 	type Alias ComplexSignal
-	complexValues := make([]map[string]float64, len(cs.Values))
+	complexValues := make([]complexPoint, len(cs.Values))
 	for i, v := range cs.Values {
-		complexValues[i] = map[string]float64{
-			"real": real(v),
-			"imag": imag(v),
-		}
+		complexValues[i] = complexPoint{Real: real(v), Imag: imag(v)}
 	}
 	return json.Marshal(&struct {
-		Values []map[string]float64 `json:"values"`
+		Values []complexPoint `json:"values"`
 		*Alias
 	}{
 		Values: complexValues,
@@ -52,55 +57,211 @@ func (cs ComplexSignal) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// complexPoint is the JSON object of one complex value, with its keys in the
+// sorted order the previous map-based encoding produced
+type complexPoint struct {
+	Imag float64 `json:"imag"`
+	Real float64 `json:"real"`
+}
+
 // ImpedanceData represents calculated impedance with magnitude and phase
 type ImpedanceData struct {
-	Timestamp   time.Time    `json:"timestamp"`
-	Impedance   []complex128 `json:"-"`
-	Frequencies []float64    `json:"frequencies"`
-	Magnitude   []float64    `json:"magnitude"`
-	Phase       []float64    `json:"phase"`
+	Identity
+	Timestamp    time.Time         `json:"timestamp"`
+	Impedance    []complex128      `json:"-"`
+	Frequencies  []float64         `json:"frequencies,omitempty"` // Omitted when a batch carries a shared grid
+	Magnitude    []float64         `json:"magnitude"`
+	Phase        []float64         `json:"phase"`
+	Metadata     Metadata          `json:"metadata,omitzero"`
+	Quality      *ChunkQuality     `json:"quality,omitempty"`
+	QualityFlags QualityFlags      `json:"quality_flags,omitempty"`
+	Stationarity *Stationarity     `json:"stationarity,omitempty"`
+	Features     *SpectrumFeatures `json:"features,omitempty"`
+	Prediction   *Prediction       `json:"prediction,omitempty"`
 }
 
-// MarshalJSON custom JSON marshaling for ImpedanceData
+// MarshalJSON custom JSON marshaling for ImpedanceData, see ImpedanceEncoder
 func (id ImpedanceData) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewImpedanceEncoder(&buf).Encode(id); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON restores the complex impedance written by MarshalJSON or an
+// ImpedanceEncoder with any EncodeOptions: flat arrays, a compacted frequency
+// grid and omitted magnitude and phase are expanded again
+func (id *ImpedanceData) UnmarshalJSON(data []byte) error {
 	type Alias ImpedanceData
-	complexImpedance := make([]map[string]float64, len(id.Impedance))
-	for i, v := range id.Impedance {
-		complexImpedance[i] = map[string]float64{
-			"real": real(v),
-			"imag": imag(v),
+	aux := struct {
+		Impedance      []complexPoint `json:"impedance"`
+		ImpedanceReal  []float64      `json:"impedance_real"`
+		ImpedanceImag  []float64      `json:"impedance_imag"`
+		FrequencyStart *float64       `json:"frequency_start"`
+		FrequencyStep  *float64       `json:"frequency_step"`
+		*Alias
+	}{Alias: (*Alias)(id)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.Impedance == nil && (aux.ImpedanceReal != nil || aux.ImpedanceImag != nil) {
+		if len(aux.ImpedanceReal) != len(aux.ImpedanceImag) {
+			return config.NewValidationError("Impedance", "impedance_real and impedance_imag differ in length")
+		}
+		id.Impedance = make([]complex128, len(aux.ImpedanceReal))
+		for i := range aux.ImpedanceReal {
+			id.Impedance[i] = complex(aux.ImpedanceReal[i], aux.ImpedanceImag[i])
+		}
+	} else {
+		id.Impedance = make([]complex128, len(aux.Impedance))
+		for i, v := range aux.Impedance {
+			id.Impedance[i] = complex(v.Real, v.Imag)
 		}
 	}
-	return json.Marshal(&struct {
-		Impedance []map[string]float64 `json:"impedance"`
-		*Alias
-	}{
-		Impedance: complexImpedance,
-		Alias:     (*Alias)(&id),
-	})
+
+	if aux.FrequencyStart != nil && aux.FrequencyStep != nil && id.Frequencies == nil {
+		id.Frequencies = make([]float64, len(id.Impedance))
+		for i := range id.Frequencies {
+			id.Frequencies[i] = *aux.FrequencyStart + float64(i)*(*aux.FrequencyStep)
+		}
+	}
+	if id.Magnitude == nil && id.Phase == nil && len(id.Impedance) > 0 {
+		id.Magnitude, id.Phase = id.CalculateMagnitudePhase()
+	}
+	return nil
 }
 
-// ImpedancePoint represents a single impedance measurement point
+// ImpedancePoint represents a single impedance measurement point. The Bode
+// fields are only present when requested, see EISMeasurement.WithBode.
 type ImpedancePoint struct {
-	Frequency float64 `json:"frequency"`
-	Real      float64 `json:"real"`
-	Imag      float64 `json:"imag"`
+	Frequency    float64      `json:"frequency"`
+	Real         float64      `json:"real"`
+	Imag         float64      `json:"imag"`
+	MagnitudeOhm *float64     `json:"magnitude_ohm,omitempty"`
+	PhaseDeg     *float64     `json:"phase_deg,omitempty"`
+	QualityFlags QualityFlags `json:"quality_flags,omitempty"`
 }
 
 // EISMeasurement represents a complete electrochemical impedance spectroscopy measurement
 type EISMeasurement []ImpedancePoint
 
+// WithBode returns a copy of the measurement with |Z| in ohms and the phase
+// of Z in degrees filled in for every point
+func (m EISMeasurement) WithBode() EISMeasurement {
+	result := make(EISMeasurement, len(m))
+	for i, point := range m {
+		z := complex(point.Real, point.Imag)
+		magnitude := cmplx.Abs(z)
+		phase := cmplx.Phase(z) * 180 / math.Pi
+		point.MagnitudeOhm = &magnitude
+		point.PhaseDeg = &phase
+		result[i] = point
+	}
+	return result
+}
+
+// HasBode reports whether every point carries magnitude and phase
+func (m EISMeasurement) HasBode() bool {
+	for _, point := range m {
+		if point.MagnitudeOhm == nil || point.PhaseDeg == nil {
+			return false
+		}
+	}
+	return len(m) > 0
+}
+
+// NewImpedanceData creates the impedance data of a new measurement taken at
+// timestamp: a fresh identity, impedance in ohms and magnitude and phase
+// computed from the impedance
+func NewImpedanceData(timestamp time.Time, frequencies []float64, impedance []complex128) ImpedanceData {
+	data := ImpedanceData{
+		Identity:    NewIdentity(),
+		Timestamp:   timestamp,
+		Impedance:   impedance,
+		Frequencies: frequencies,
+		Metadata:    Metadata{Unit: UnitOhm},
+	}
+	data.Magnitude, data.Phase = data.CalculateMagnitudePhase()
+	return data
+}
+
+// Band returns a copy of the spectrum keeping only the points from fmin to
+// fmax inclusive, with magnitude and phase of the kept points; fmax 0 sets
+// no upper limit
+func (id ImpedanceData) Band(fmin, fmax float64) ImpedanceData {
+	result := id
+	result.Frequencies = nil
+	result.Impedance = nil
+	for i, f := range id.Frequencies {
+		if f >= fmin && (fmax == 0 || f <= fmax) && i < len(id.Impedance) {
+			result.Frequencies = append(result.Frequencies, f)
+			result.Impedance = append(result.Impedance, id.Impedance[i])
+		}
+	}
+	result.Magnitude, result.Phase = result.CalculateMagnitudePhase()
+	return result
+}
+
+// ToPoints returns one point per frequency, each carrying the quality flags
+// of the spectrum
+func (id ImpedanceData) ToPoints() EISMeasurement {
+	measurement := make(EISMeasurement, len(id.Impedance))
+	for i, z := range id.Impedance {
+		measurement[i] = ImpedancePoint{
+			Frequency:    id.Frequencies[i],
+			Real:         real(z),
+			Imag:         imag(z),
+			QualityFlags: id.QualityFlags,
+		}
+	}
+	return measurement
+}
+
+// EISMeasurementFromData returns the points of data, with magnitude and
+// phase filled in if withBode is set
+func EISMeasurementFromData(data ImpedanceData, withBode bool) EISMeasurement {
+	measurement := data.ToPoints()
+	if withBode {
+		measurement = measurement.WithBode()
+	}
+	return measurement
+}
+
+// ToImpedanceData returns the impedance data of the points, with magnitude
+// and phase computed and the quality flags of all points combined. Identity
+// and timestamp are left for the caller, points carry neither.
+func (m EISMeasurement) ToImpedanceData() ImpedanceData {
+	var data ImpedanceData
+	data.Frequencies = make([]float64, len(m))
+	data.Impedance = make([]complex128, len(m))
+	for i, point := range m {
+		data.Frequencies[i] = point.Frequency
+		data.Impedance[i] = complex(point.Real, point.Imag)
+		data.QualityFlags |= point.QualityFlags
+	}
+	data.Magnitude, data.Phase = data.CalculateMagnitudePhase()
+	return data
+}
+
 // ImpedanceDataWithIteration represents impedance data with iteration number for batch processing
 type ImpedanceDataWithIteration struct {
 	ImpedanceData ImpedanceData `json:"impedance_data"`
 	Iteration     int           `json:"iteration"`
 }
 
-// ImpedanceBatch represents a batch of impedance measurements for efficient processing
+// ImpedanceBatch represents a batch of impedance measurements for efficient processing.
+// In the shared-grid schema the frequency grid common to all spectra is stored
+// once in Frequencies and omitted from the spectra, see CompactGrid. Summary
+// is only present if requested, see EncodeOptions.Summary.
 type ImpedanceBatch struct {
-	BatchID   string                        `json:"batch_id"`
-	Timestamp time.Time                     `json:"timestamp"`
-	Spectra   []ImpedanceDataWithIteration  `json:"spectra"`
+	BatchID     string                       `json:"batch_id"`
+	Timestamp   time.Time                    `json:"timestamp"`
+	Schema      string                       `json:"schema,omitempty"`
+	Frequencies []float64                    `json:"frequencies,omitempty"`
+	Summary     *BatchSummary                `json:"summary,omitempty"`
+	Spectra     []ImpedanceDataWithIteration `json:"spectra"`
 }
 
 // CalculateMagnitudePhase calculates the magnitude and phase from complex impedance values
@@ -0,0 +1,227 @@
+package signal
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEISMeasurement_WithBode(t *testing.T) {
+	measurement := EISMeasurement{
+		{Frequency: 10, Real: 3, Imag: -4},
+		{Frequency: 100, Real: 5, Imag: 0},
+	}
+
+	if measurement.HasBode() {
+		t.Fatalf("plain measurement should not report Bode fields")
+	}
+
+	bode := measurement.WithBode()
+	if !bode.HasBode() {
+		t.Fatalf("expected Bode fields on every point")
+	}
+	if measurement[0].MagnitudeOhm != nil {
+		t.Errorf("WithBode must not modify the original measurement")
+	}
+
+	if got := *bode[0].MagnitudeOhm; got != 5 {
+		t.Errorf("expected |Z| = 5, got %v", got)
+	}
+	if got := *bode[0].PhaseDeg; math.Abs(got-(-53.130102)) > 1e-6 {
+		t.Errorf("expected phase -53.13 deg, got %v", got)
+	}
+	if got := *bode[1].PhaseDeg; got != 0 {
+		t.Errorf("expected phase 0 deg, got %v", got)
+	}
+
+	data, err := json.Marshal(bode[1])
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"magnitude_ohm":5`) || !strings.Contains(string(data), `"phase_deg":0`) {
+		t.Errorf("expected Bode fields in JSON, got %s", data)
+	}
+}
+
+func TestImpedanceData_PointConversions(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0).UTC()
+	data := NewImpedanceData(timestamp, []float64{10, 100}, []complex128{complex(3, -4), complex(5, 0)})
+	if data.ID == "" || !data.Timestamp.Equal(timestamp) || data.Metadata.Unit != UnitOhm {
+		t.Errorf("NewImpedanceData() identity, timestamp or unit missing: %+v", data)
+	}
+	if data.Magnitude[0] != 5 || data.Phase[1] != 0 {
+		t.Errorf("NewImpedanceData() magnitude = %v, phase = %v", data.Magnitude, data.Phase)
+	}
+
+	data.QualityFlags = FlagClipped
+	points := data.ToPoints()
+	if len(points) != 2 || points[0].Frequency != 10 || points[0].Real != 3 || points[0].Imag != -4 {
+		t.Fatalf("ToPoints() = %+v", points)
+	}
+	for _, point := range points {
+		if point.QualityFlags != FlagClipped || point.MagnitudeOhm != nil {
+			t.Errorf("point %+v should carry the spectrum flags and no Bode fields", point)
+		}
+	}
+	if !EISMeasurementFromData(data, true).HasBode() || EISMeasurementFromData(data, false).HasBode() {
+		t.Error("EISMeasurementFromData() ignored withBode")
+	}
+
+	points[1].QualityFlags |= FlagAliasing
+	restored := points.ToImpedanceData()
+	if restored.QualityFlags != FlagClipped|FlagAliasing {
+		t.Errorf("ToImpedanceData() flags = %v, want clipped|aliasing", restored.QualityFlags)
+	}
+	for i := range data.Impedance {
+		if restored.Frequencies[i] != data.Frequencies[i] || restored.Impedance[i] != data.Impedance[i] || restored.Magnitude[i] != data.Magnitude[i] {
+			t.Errorf("ToImpedanceData() point %d = %v Hz %v, want %v Hz %v", i, restored.Frequencies[i], restored.Impedance[i], data.Frequencies[i], data.Impedance[i])
+		}
+	}
+}
+
+func TestImpedanceData_Band(t *testing.T) {
+	data := NewImpedanceData(time.Unix(0, 0), []float64{0, 1, 10, 100, 1000}, []complex128{1, 2, 3, complex(0, 4), 5})
+
+	tests := []struct {
+		name       string
+		fmin, fmax float64
+		want       []float64
+	}{
+		{"both limits inclusive", 1, 100, []float64{1, 10, 100}},
+		{"no upper limit", 10, 0, []float64{10, 100, 1000}},
+		{"outside the spectrum", 2000, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			band := data.Band(tt.fmin, tt.fmax)
+			if len(band.Frequencies) != len(tt.want) || len(band.Impedance) != len(tt.want) || len(band.Phase) != len(tt.want) {
+				t.Fatalf("Band() = %v Hz with %d values, want %v Hz", band.Frequencies, len(band.Impedance), tt.want)
+			}
+			for i, f := range tt.want {
+				if band.Frequencies[i] != f {
+					t.Errorf("Band() frequencies = %v, want %v", band.Frequencies, tt.want)
+				}
+			}
+		})
+	}
+
+	band := data.Band(100, 100)
+	if band.Magnitude[0] != 4 || band.Phase[0] != math.Pi/2 || band.ID != data.ID || len(data.Frequencies) != 5 {
+		t.Errorf("Band() = %+v, want the 100 Hz point with identity and the original untouched", band)
+	}
+}
+
+func TestMetadata_MergeAndJSON(t *testing.T) {
+	labels, err := ParseLabels("campaign=aging, temp=25C")
+	if err != nil {
+		t.Fatalf("ParseLabels() error = %v", err)
+	}
+	if _, err := ParseLabels("campaign"); err == nil {
+		t.Errorf("expected error for label without value")
+	}
+
+	base := Metadata{Unit: UnitVolt, Labels: map[string]string{"temp": "20C", "cell": "A1"}}
+	merged := base.Merge(Metadata{DeviceSerial: "SN-42", Labels: labels}).WithUnit(UnitOhm)
+
+	if merged.Unit != UnitOhm || merged.DeviceSerial != "SN-42" {
+		t.Errorf("unexpected merged metadata %+v", merged)
+	}
+	if merged.Labels["temp"] != "25C" || merged.Labels["cell"] != "A1" || merged.Labels["campaign"] != "aging" {
+		t.Errorf("unexpected merged labels %v", merged.Labels)
+	}
+	if base.Labels["temp"] != "20C" {
+		t.Errorf("Merge must not modify the receiver's labels")
+	}
+
+	names, values := merged.Columns()
+	if strings.Join(names, ",") != "unit,channel,probe,device_serial,campaign,cell,temp" ||
+		strings.Join(values, ",") != "Ω,,,SN-42,aging,A1,25C" {
+		t.Errorf("unexpected columns %v = %v", names, values)
+	}
+
+	plain, err := json.Marshal(Signal{SampleRate: 1})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(plain), "metadata") {
+		t.Errorf("empty metadata should be omitted, got %s", plain)
+	}
+	annotated, err := json.Marshal(Signal{SampleRate: 1, Metadata: Metadata{Unit: UnitAmpere}})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(annotated), `"metadata":{"unit":"A"}`) {
+		t.Errorf("expected unit in JSON, got %s", annotated)
+	}
+}
+
+func TestNewIdentity_UniqueAndMonotonic(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	var last uint64
+	for i := 0; i < 100; i++ {
+		id := NewIdentity()
+		if !uuidPattern.MatchString(id.ID) {
+			t.Fatalf("invalid UUID %q", id.ID)
+		}
+		if seen[id.ID] {
+			t.Fatalf("duplicate UUID %q", id.ID)
+		}
+		seen[id.ID] = true
+		if id.Sequence <= last {
+			t.Fatalf("sequence %d not greater than previous %d", id.Sequence, last)
+		}
+		last = id.Sequence
+	}
+
+	data, err := json.Marshal(ImpedanceData{Identity: Identity{ID: "x", Sequence: 7}})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"id":"x","sequence":7`) {
+		t.Errorf("expected identity in ImpedanceData JSON, got %s", data)
+	}
+}
+
+func TestSignal_Downsample(t *testing.T) {
+	sig := Signal{Values: []float64{1, 3, 5, 7, 9}, SampleRate: 1000}
+
+	tests := []struct {
+		factor   int
+		want     []float64
+		wantRate float64
+		wantErr  bool
+	}{
+		{factor: 1, want: []float64{1, 3, 5, 7, 9}, wantRate: 1000},
+		{factor: 2, want: []float64{2, 6, 9}, wantRate: 500},
+		{factor: 5, want: []float64{5}, wantRate: 200},
+		{factor: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := sig.Downsample(tt.factor)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Downsample(%d) error = %v, wantErr %v", tt.factor, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got.SampleRate != tt.wantRate || len(got.Values) != len(tt.want) {
+			t.Fatalf("Downsample(%d) = %v at %v Hz, want %v at %v Hz", tt.factor, got.Values, got.SampleRate, tt.want, tt.wantRate)
+		}
+		for i := range tt.want {
+			if got.Values[i] != tt.want[i] {
+				t.Errorf("Downsample(%d) = %v, want %v", tt.factor, got.Values, tt.want)
+				break
+			}
+		}
+	}
+	if sig.Values[1] != 3 {
+		t.Errorf("Downsample modified the original signal")
+	}
+}
@@ -152,4 +152,25 @@ func ValidateSignalsMatch(voltageSignal, currentSignal Signal) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// ValidateSpectraMatch validates that voltage and current spectra share their
+// frequency grid and were taken at about the same time
+func ValidateSpectraMatch(voltageSpectrum, currentSpectrum ComplexSignal) error {
+	if len(voltageSpectrum.Values) != len(currentSpectrum.Values) || len(voltageSpectrum.Frequencies) != len(currentSpectrum.Frequencies) {
+		return config.ErrMismatchedSignalLength
+	}
+
+	for i, f := range voltageSpectrum.Frequencies {
+		if math.Abs(f-currentSpectrum.Frequencies[i]) > 1e-9*math.Max(math.Abs(f), 1) {
+			return config.NewValidationError("Frequencies", fmt.Sprintf("voltage and current spectra differ at index %d (%g Hz vs %g Hz)", i, f, currentSpectrum.Frequencies[i]))
+		}
+	}
+
+	timeDiff := voltageSpectrum.Timestamp.Sub(currentSpectrum.Timestamp)
+	if math.Abs(timeDiff.Seconds()) > 0.1 { // Same tolerance as ValidateSignalsMatch
+		return config.NewValidationError("Timestamp", "voltage and current spectra have significantly different timestamps")
+	}
+
+	return nil
+}
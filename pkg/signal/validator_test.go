@@ -133,4 +133,29 @@ func TestValidateSignalsMatch(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateSpectraMatch(t *testing.T) {
+	now := time.Now()
+	spectrum := ComplexSignal{Timestamp: now, Values: []complex128{1, 2}, Frequencies: []float64{1, 2}}
+
+	tests := []struct {
+		name    string
+		current ComplexSignal
+		wantErr bool
+	}{
+		{name: "matching spectra", current: ComplexSignal{Timestamp: now, Values: []complex128{3, 4}, Frequencies: []float64{1, 2}}},
+		{name: "mismatched length", current: ComplexSignal{Timestamp: now, Values: []complex128{3}, Frequencies: []float64{1}}, wantErr: true},
+		{name: "different frequencies", current: ComplexSignal{Timestamp: now, Values: []complex128{3, 4}, Frequencies: []float64{1, 3}}, wantErr: true},
+		{name: "different timestamps", current: ComplexSignal{Timestamp: now.Add(time.Second), Values: []complex128{3, 4}, Frequencies: []float64{1, 2}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSpectraMatch(spectrum, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpectraMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
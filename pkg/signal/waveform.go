@@ -0,0 +1,187 @@
+package signal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// LoadWaveform loads one period of an arbitrary excitation waveform. WAV files
+// (PCM 8/16/24/32 bit or 32/64 bit float; the first channel is used) carry
+// their own sample rate and are normalized to ±1. CSV files hold one sample
+// per row in their last column, e.g. "value" or "time_offset,value", and are
+// sampled at sampleRate.
+func LoadWaveform(path string, sampleRate float64, dialect CSVDialect) (Signal, error) {
+	var waveform Signal
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		waveform, err = loadWAV(path)
+	} else {
+		waveform, err = loadWaveformCSV(path, sampleRate, dialect)
+	}
+	if err != nil {
+		return Signal{}, err
+	}
+
+	if len(waveform.Values) < 2 {
+		return Signal{}, config.NewValidationError("Waveform", fmt.Sprintf("%s holds fewer than 2 samples", path))
+	}
+	if waveform.SampleRate <= 0 {
+		return Signal{}, config.ErrInvalidSampleRate
+	}
+	waveform.Metadata = Metadata{Unit: UnitVolt}
+	return waveform, nil
+}
+
+// loadWaveformCSV reads the last column of every row, skipping a header
+func loadWaveformCSV(path string, sampleRate float64, dialect CSVDialect) (Signal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Signal{}, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", path, err))
+	}
+	defer file.Close()
+
+	reader := dialect.NewReader(file)
+	reader.FieldsPerRecord = -1
+	var values []float64
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Signal{}, config.NewProcessingError("CSV reading", fmt.Errorf("failed to read CSV line %d: %w", line, err))
+		}
+
+		value, err := dialect.ParseFloat(record[len(record)-1])
+		if err != nil {
+			if len(values) == 0 {
+				continue // Header
+			}
+			return Signal{}, rowError(path, line, fmt.Sprintf("invalid sample '%s'", record[len(record)-1]))
+		}
+		values = append(values, value)
+	}
+	return Signal{Values: values, SampleRate: sampleRate}, nil
+}
+
+// wavFormat is the fmt chunk of a WAV file
+type wavFormat struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// WAV audio formats
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavExtensible = 0xFFFE
+)
+
+// loadWAV reads the first channel of a RIFF/WAVE file
+func loadWAV(path string) (Signal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Signal{}, config.NewProcessingError("file opening", fmt.Errorf("failed to open %s: %w", path, err))
+	}
+	defer file.Close()
+
+	invalid := func(reason string) error {
+		return config.NewProcessingError("WAV reading", fmt.Errorf("%s: %s", path, reason))
+	}
+
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return Signal{}, invalid("not a RIFF/WAVE file")
+	}
+
+	var format *wavFormat
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(file, chunk[:]); err != nil {
+			return Signal{}, invalid("no data chunk")
+		}
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			format = &wavFormat{}
+			if err := binary.Read(io.LimitReader(file, 16), binary.LittleEndian, format); err != nil {
+				return Signal{}, invalid("truncated fmt chunk")
+			}
+			if _, err := file.Seek(size-16+size%2, io.SeekCurrent); err != nil {
+				return Signal{}, invalid("truncated fmt chunk")
+			}
+		case "data":
+			if format == nil {
+				return Signal{}, invalid("data chunk before fmt chunk")
+			}
+			if info, err := file.Stat(); err != nil || size > info.Size() {
+				return Signal{}, invalid("truncated data chunk") // Before allocating a corrupt size
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(file, data); err != nil {
+				return Signal{}, invalid("truncated data chunk")
+			}
+			values, err := decodeWAVSamples(*format, data)
+			if err != nil {
+				return Signal{}, invalid(err.Error())
+			}
+			return Signal{Values: values, SampleRate: float64(format.SampleRate)}, nil
+		default:
+			if _, err := file.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return Signal{}, invalid("truncated chunk")
+			}
+		}
+	}
+}
+
+// decodeWAVSamples converts the first channel of interleaved frames to floats in ±1
+func decodeWAVSamples(format wavFormat, data []byte) ([]float64, error) {
+	width := int(format.BitsPerSample / 8)
+	if format.Channels == 0 || width == 0 || int(format.BlockAlign) < width {
+		return nil, fmt.Errorf("invalid format")
+	}
+	frames := len(data) / int(format.BlockAlign)
+	values := make([]float64, frames)
+
+	audioFormat := format.AudioFormat
+	if audioFormat == wavExtensible {
+		audioFormat = wavPCM // Sub-format is assumed to be PCM, or float for 64-bit
+		if format.BitsPerSample == 64 {
+			audioFormat = wavFloat
+		}
+	}
+
+	for i := range values {
+		sample := data[i*int(format.BlockAlign):][:width]
+		switch {
+		case audioFormat == wavPCM && width == 1:
+			values[i] = (float64(sample[0]) - 128) / 128
+		case audioFormat == wavPCM && width == 2:
+			values[i] = float64(int16(binary.LittleEndian.Uint16(sample))) / (1 << 15)
+		case audioFormat == wavPCM && width == 3:
+			v := int32(sample[0]) | int32(sample[1])<<8 | int32(int8(sample[2]))<<16
+			values[i] = float64(v) / (1 << 23)
+		case audioFormat == wavPCM && width == 4:
+			values[i] = float64(int32(binary.LittleEndian.Uint32(sample))) / (1 << 31)
+		case audioFormat == wavFloat && width == 4:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(sample)))
+		case audioFormat == wavFloat && width == 8:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(sample))
+		default:
+			return nil, fmt.Errorf("unsupported sample format %d with %d bits", format.AudioFormat, format.BitsPerSample)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,85 @@
+package signal
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadWaveform(t *testing.T) {
+	dir := t.TempDir()
+
+	samples := []int16{0, 16384, -16384, 32767}
+	var data []byte
+	for _, s := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(s))
+	}
+	wav := []byte("RIFF")
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(36+len(data)))
+	wav = append(wav, "WAVEfmt "...)
+	wav = binary.LittleEndian.AppendUint32(wav, 16)
+	wav = binary.LittleEndian.AppendUint16(wav, wavPCM)
+	wav = binary.LittleEndian.AppendUint16(wav, 1)    // Channels
+	wav = binary.LittleEndian.AppendUint32(wav, 8000) // Sample rate
+	wav = binary.LittleEndian.AppendUint32(wav, 16000)
+	wav = binary.LittleEndian.AppendUint16(wav, 2)
+	wav = binary.LittleEndian.AppendUint16(wav, 16)
+	wav = append(wav, "data"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(data)))
+	wav = append(wav, data...)
+
+	files := map[string]string{
+		"waveform.csv": "time_offset,value\n0,0.1\n0.001,-0.2\n0.002,0.3\n",
+		"single.csv":   "value\n1.0\n",
+		"bad.csv":      "value\n1.0\nx\n",
+		"bad.wav":      "not a wave file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "waveform.wav"), wav, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		wantValues []float64
+		wantRate   float64
+		wantErr    bool
+	}{
+		{"CSV", "waveform.csv", []float64{0.1, -0.2, 0.3}, 1000, false},
+		{"16-bit WAV", "waveform.wav", []float64{0, 0.5, -0.5, 32767.0 / 32768}, 8000, false},
+		{"too short", "single.csv", nil, 0, true},
+		{"invalid sample", "bad.csv", nil, 0, true},
+		{"invalid WAV", "bad.wav", nil, 0, true},
+		{"missing", "missing.csv", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waveform, err := LoadWaveform(filepath.Join(dir, tt.file), 1000, DefaultCSVDialect)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadWaveform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if waveform.SampleRate != tt.wantRate {
+				t.Errorf("sample rate = %v, want %v", waveform.SampleRate, tt.wantRate)
+			}
+			if len(waveform.Values) != len(tt.wantValues) {
+				t.Fatalf("got %d samples, want %d", len(waveform.Values), len(tt.wantValues))
+			}
+			for i, want := range tt.wantValues {
+				if math.Abs(waveform.Values[i]-want) > 1e-12 {
+					t.Errorf("sample %d = %v, want %v", i, waveform.Values[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package signal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adam/masterapp/pkg/config"
+)
+
+// TimeBound is one end of a TimeWindow, either an offset from the first sample
+// of a recording or an absolute timestamp. The zero value is unbounded.
+type TimeBound struct {
+	offset   time.Duration
+	absolute time.Time
+	set      bool
+}
+
+// OffsetBound returns a bound at the given offset from the first sample
+func OffsetBound(offset time.Duration) TimeBound {
+	return TimeBound{offset: offset, set: true}
+}
+
+// AbsoluteBound returns a bound at the given wall-clock time
+func AbsoluteBound(t time.Time) TimeBound {
+	return TimeBound{absolute: t, set: true}
+}
+
+// ParseTimeBound parses a duration ("90s", "1h30m"), plain seconds ("12.5")
+// or an RFC 3339 timestamp. An empty string yields an unbounded TimeBound.
+func ParseTimeBound(value string) (TimeBound, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return TimeBound{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return OffsetBound(d), nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return OffsetBound(time.Duration(seconds * float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return AbsoluteBound(t), nil
+	}
+	return TimeBound{}, config.NewValidationError("TimeBound",
+		fmt.Sprintf("%q is neither a time offset nor an RFC 3339 timestamp", value))
+}
+
+// IsSet reports whether the bound limits the window
+func (b TimeBound) IsSet() bool {
+	return b.set
+}
+
+// resolve returns the bound as an absolute time for a recording starting at start
+func (b TimeBound) resolve(start time.Time) time.Time {
+	if !b.absolute.IsZero() {
+		return b.absolute
+	}
+	return start.Add(b.offset)
+}
+
+// String renders the bound the way it would be parsed
+func (b TimeBound) String() string {
+	switch {
+	case !b.set:
+		return "unbounded"
+	case !b.absolute.IsZero():
+		return b.absolute.Format(time.RFC3339Nano)
+	default:
+		return b.offset.String()
+	}
+}
+
+// TimeWindow selects the samples of a recording in [From, To)
+type TimeWindow struct {
+	From TimeBound
+	To   TimeBound
+}
+
+// ParseTimeWindow parses the -from and -to options
+func ParseTimeWindow(from, to string) (TimeWindow, error) {
+	var window TimeWindow
+	var err error
+	if window.From, err = ParseTimeBound(from); err != nil {
+		return TimeWindow{}, err
+	}
+	if window.To, err = ParseTimeBound(to); err != nil {
+		return TimeWindow{}, err
+	}
+
+	if window.From.set && window.To.set && window.From.absolute.IsZero() == window.To.absolute.IsZero() {
+		if window.To.resolve(time.Time{}).Sub(window.From.resolve(time.Time{})) <= 0 {
+			return TimeWindow{}, config.NewValidationError("TimeWindow",
+				fmt.Sprintf("window end %s must be after start %s", window.To, window.From))
+		}
+	}
+	return window, nil
+}
+
+// IsSet reports whether either end of the window is bounded
+func (w TimeWindow) IsSet() bool {
+	return w.From.set || w.To.set
+}
+
+// String describes the window for logging
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("[%s, %s)", w.From, w.To)
+}
+
+// apply keeps the samples whose timestamps fall inside the window, measuring
+// offsets from the first sample of the recording
+func (w TimeWindow) apply(samples []timeSample) []timeSample {
+	if !w.IsSet() || len(samples) == 0 {
+		return samples
+	}
+
+	start := samples[0].timestamp
+	from, to := w.From.resolve(start), w.To.resolve(start)
+
+	selected := make([]timeSample, 0, len(samples))
+	for _, s := range samples {
+		if w.From.set && s.timestamp.Before(from) {
+			continue
+		}
+		if w.To.set && !s.timestamp.Before(to) {
+			continue
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}